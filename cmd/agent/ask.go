@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/champly/ai-agent/pkg/agent"
+)
+
+// defaultMaxInput 标准输入默认保留的最大字符数
+const defaultMaxInput = 16000
+
+// runAsk 单次提问，支持通过管道附加标准输入内容：cat error.log | agent ask "what's wrong here"
+func runAsk(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ask", flag.ExitOnError)
+	server := fs.String("server", "", "远程 Agent 地址（如 http://localhost:8080），为空时在进程内启动")
+	model := fs.String("model", "", "使用的模型（默认使用配置中的模型）")
+	conversationID := fs.String("conversation", "", "继续已有对话")
+	persona := fs.String("persona", "", "使用的人设（配置中的 personas），继续对话时默认沿用对话的人设")
+	lang := fs.String("lang", "", "回答语言（zh、en 等），继续对话时默认沿用对话的语言")
+	schemaFile := fs.String("schema", "", "JSON Schema 文件，要求回答为符合该 Schema 的 JSON")
+	validate := fs.String("validate", "", "额外使用的回答校验规则（配置中的 output_validation.validators），多个以逗号分隔")
+	imageFiles := fs.String("image", "", "随问题发送给视觉模型的图片文件，多个以逗号分隔")
+	var stop []string
+	fs.Func("stop", "停止序列，模型输出该序列时停止生成，可重复指定", func(s string) error {
+		stop = append(stop, s)
+		return nil
+	})
+	maxInput := fs.Int("max-input", defaultMaxInput, "标准输入保留的最大字符数，超出时保留首尾并截断中间")
+	output := addOutputFlag(fs)
+	words := parseArgs(fs, args)
+
+	question := strings.TrimSpace(strings.Join(words, " "))
+
+	stdin, err := readStdin()
+	if err != nil {
+		return err
+	}
+	if question == "" && stdin == "" {
+		return fmt.Errorf("usage: [cat FILE |] ask \"question\"")
+	}
+
+	var schema json.RawMessage
+	if *schemaFile != "" {
+		if schema, err = os.ReadFile(*schemaFile); err != nil {
+			return fmt.Errorf("read schema: %w", err)
+		}
+	}
+
+	var validators []string
+	if *validate != "" {
+		validators = strings.Split(*validate, ",")
+	}
+
+	var images []string
+	if *imageFiles != "" {
+		for _, path := range strings.Split(*imageFiles, ",") {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("read image: %w", err)
+			}
+			images = append(images, base64.StdEncoding.EncodeToString(data))
+		}
+	}
+
+	req := &agent.ChatRequest{
+		Message:        buildAskMessage(question, truncateMiddle(stdin, *maxInput)),
+		ConversationID: *conversationID,
+		Model:          *model,
+		Persona:        *persona,
+		Language:       *lang,
+		Schema:         schema,
+		Validators:     validators,
+		Images:         images,
+		Stop:           stop,
+	}
+
+	var resp *agent.ChatResponse
+	if *server != "" {
+		resp = &agent.ChatResponse{}
+		if err := callRemote(ctx, *server, "/api/chat", req, resp); err != nil {
+			return err
+		}
+	} else {
+		ag, err := startLocalAgent(ctx)
+		if err != nil {
+			return err
+		}
+		defer ag.Stop(ctx)
+
+		if resp, err = ag.Chat(ctx, req); err != nil {
+			return err
+		}
+	}
+
+	return render(*output, resp, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, resp.Response)
+		return err
+	}, func(w io.Writer) error {
+		return writeChatMarkdown(w, resp)
+	})
+}
+
+// writeChatMarkdown 以 Markdown 格式输出聊天结果
+func writeChatMarkdown(w io.Writer, resp *agent.ChatResponse) error {
+	if resp.Reasoning != "" {
+		fmt.Fprintf(w, "## Reasoning\n\n%s\n\n", strings.TrimSpace(resp.Reasoning))
+	}
+	fmt.Fprintf(w, "## Response\n\n%s\n\n", strings.TrimSpace(resp.Response))
+	if len(resp.ToolCalls) > 0 {
+		fmt.Fprintf(w, "## Tool Calls\n\n")
+		for i, tc := range resp.ToolCalls {
+			args, _ := json.Marshal(tc.Arguments)
+			fmt.Fprintf(w, "%d. `%s` `%s`\n", i+1, tc.Tool, args)
+		}
+		fmt.Fprintln(w)
+	}
+	_, err := fmt.Fprintf(w, "_conversation: %s_\n", resp.ConversationID)
+	return err
+}
+
+// readStdin 读取管道输入，标准输入为终端时返回空
+func readStdin() (string, error) {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return "", nil
+	}
+	if info.Mode()&os.ModeCharDevice != 0 {
+		return "", nil
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("read stdin: %w", err)
+	}
+	return string(data), nil
+}
+
+// truncateMiddle 超出长度时保留首尾内容（日志的开头和结尾通常最有价值）
+func truncateMiddle(s string, limit int) string {
+	runes := []rune(s)
+	if limit <= 0 || len(runes) <= limit {
+		return s
+	}
+
+	head := limit / 2
+	tail := limit - head
+	omitted := len(runes) - limit
+	return fmt.Sprintf("%s\n\n... [省略 %d 个字符] ...\n\n%s", string(runes[:head]), omitted, string(runes[len(runes)-tail:]))
+}
+
+// buildAskMessage 拼接问题与标准输入内容
+func buildAskMessage(question, stdin string) string {
+	if stdin == "" {
+		return question
+	}
+	if question == "" {
+		question = "请分析以下内容。"
+	}
+	return fmt.Sprintf("%s\n\n以下是通过标准输入提供的内容：\n```\n%s\n```", question, strings.TrimRight(stdin, "\n"))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/champly/ai-agent/pkg/audit"
+)
+
+// runAudit 审计日志子命令：audit verify [FILE] [--public-key KEY]
+func runAudit(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "verify" {
+		return fmt.Errorf("usage: audit verify [FILE] [--public-key KEY]")
+	}
+
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	publicKey := fs.String("public-key", "", "Ed25519 公钥文件（PEM），设置后同时校验每条记录的签名")
+	positional := parseArgs(fs, args[1:])
+
+	// 未指定文件时使用配置中的审计日志
+	var path string
+	if len(positional) > 0 {
+		path = positional[0]
+	} else {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if path = cfg.Audit.Path; path == "" {
+			return fmt.Errorf("audit.path is not configured, specify the audit log file")
+		}
+	}
+
+	var pub ed25519.PublicKey
+	if *publicKey != "" {
+		var err error
+		if pub, err = audit.LoadPublicKey(*publicKey); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	defer f.Close()
+
+	summary, err := audit.Verify(f, pub)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("OK: %d records (seq %d-%d), signatures checked: %t\n", summary.Records, summary.FirstSeq, summary.LastSeq, pub != nil)
+	fmt.Printf("last hash: %s\n", summary.LastHash)
+	return nil
+}
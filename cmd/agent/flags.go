@@ -0,0 +1,17 @@
+package main
+
+import "flag"
+
+// parseArgs 解析参数，允许位置参数与 flag 交错出现（如 `ingest docs --collection x`）
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
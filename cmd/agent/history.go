@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/champly/ai-agent/pkg/store"
+)
+
+// timeLayout 时间显示格式
+const timeLayout = "2006-01-02 15:04:05"
+
+// runHistory 对话历史子命令：history list | history show <id> | history export <id>
+func runHistory(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: history list | history show <id> | history export <id> [--format json|markdown] [--output FILE]")
+	}
+
+	switch args[0] {
+	case "list":
+		return runHistoryList(ctx, args[1:])
+	case "show":
+		return runHistoryShow(ctx, args[1:])
+	case "export":
+		return runHistoryExport(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown history command: %s", args[0])
+	}
+}
+
+// historySource 对话历史来源：远程 Agent 或本地持久化存储
+type historySource struct {
+	ctx    context.Context
+	server string
+	store  store.Store
+}
+
+// newHistorySource 创建历史来源，未指定 server 时直接读取配置中的存储（无需启动 Agent）
+func newHistorySource(ctx context.Context, server string) (*historySource, error) {
+	src := &historySource{ctx: ctx, server: server}
+	if server != "" {
+		return src, nil
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	st, err := store.New(cfg.Conversation, cfg.Redis)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, fmt.Errorf("conversation store is %q, history is only available via --server", cfg.Conversation.Store)
+	}
+	src.store = st
+	return src, nil
+}
+
+// list 列出对话
+func (h *historySource) list() ([]store.Summary, error) {
+	if h.store != nil {
+		return h.store.List()
+	}
+	var resp struct {
+		Conversations []store.Summary `json:"conversations"`
+	}
+	if err := callRemote(h.ctx, h.server, "/api/conversations", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Conversations, nil
+}
+
+// get 获取对话
+func (h *historySource) get(id string) (*store.Record, error) {
+	if h.store != nil {
+		return h.store.Load(id)
+	}
+	var rec store.Record
+	if err := callRemote(h.ctx, h.server, "/api/conversations/"+id, nil, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// runHistoryList 列出所有对话
+func runHistoryList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("history list", flag.ExitOnError)
+	server := fs.String("server", "", "远程 Agent 地址，为空时读取本地对话存储")
+	output := addOutputFlag(fs)
+	fs.Parse(args)
+
+	src, err := newHistorySource(ctx, *server)
+	if err != nil {
+		return err
+	}
+	summaries, err := src.list()
+	if err != nil {
+		return err
+	}
+
+	return render(*output, map[string]any{"conversations": summaries}, func(out io.Writer) error {
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUPDATED\tMESSAGES\tTITLE")
+		for _, s := range summaries {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", s.ID, s.UpdatedAt.Local().Format(timeLayout), s.MessageCount, s.Title)
+		}
+		return w.Flush()
+	}, func(w io.Writer) error {
+		fmt.Fprintln(w, "| ID | Updated | Messages | Title |")
+		fmt.Fprintln(w, "|----|---------|----------|-------|")
+		for _, s := range summaries {
+			fmt.Fprintf(w, "| %s | %s | %d | %s |\n", s.ID, s.UpdatedAt.Local().Format(timeLayout), s.MessageCount, s.Title)
+		}
+		return nil
+	})
+}
+
+// runHistoryShow 以可读格式显示对话
+func runHistoryShow(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("history show", flag.ExitOnError)
+	server := fs.String("server", "", "远程 Agent 地址，为空时读取本地对话存储")
+	output := addOutputFlag(fs)
+	positional := parseArgs(fs, args)
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: history show <id>")
+	}
+
+	src, err := newHistorySource(ctx, *server)
+	if err != nil {
+		return err
+	}
+	rec, err := src.get(positional[0])
+	if err != nil {
+		return err
+	}
+	return render(*output, rec, func(w io.Writer) error {
+		return writeMarkdown(w, rec)
+	}, nil)
+}
+
+// runHistoryExport 导出对话
+func runHistoryExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("history export", flag.ExitOnError)
+	server := fs.String("server", "", "远程 Agent 地址，为空时读取本地对话存储")
+	format := fs.String("format", "json", "导出格式：json 或 markdown")
+	output := fs.String("output", "", "输出文件（默认输出到标准输出）")
+	positional := parseArgs(fs, args)
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: history export <id> [--format json|markdown] [--output FILE]")
+	}
+
+	src, err := newHistorySource(ctx, *server)
+	if err != nil {
+		return err
+	}
+	rec, err := src.get(positional[0])
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("create output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rec)
+	case "markdown", "md":
+		return writeMarkdown(w, rec)
+	default:
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+}
+
+// writeMarkdown 以 Markdown 格式输出对话
+func writeMarkdown(w io.Writer, rec *store.Record) error {
+	fmt.Fprintf(w, "# Conversation %s\n\n", rec.ID)
+	fmt.Fprintf(w, "- Created: %s\n- Updated: %s\n- Messages: %d\n\n",
+		rec.CreatedAt.Local().Format(timeLayout), rec.UpdatedAt.Local().Format(timeLayout), len(rec.Messages))
+
+	for _, msg := range rec.Messages {
+		fmt.Fprintf(w, "## %s\n\n", msg.Role)
+		if content := strings.TrimSpace(msg.Content); content != "" {
+			fmt.Fprintf(w, "%s\n\n", content)
+		}
+		for _, tc := range msg.ToolCalls {
+			args, _ := json.Marshal(tc.Function.Arguments)
+			fmt.Fprintf(w, "- tool call `%s` `%s`\n", tc.Function.Name, args)
+		}
+		if len(msg.ToolCalls) > 0 {
+			fmt.Fprintln(w)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/champly/ai-agent/pkg/ollama"
+)
+
+// initProbeTimeout 探测 Ollama 的超时时间
+const initProbeTimeout = 5 * time.Second
+
+// mcpCandidate 可自动发现的 MCP 服务器
+type mcpCandidate struct {
+	Name    string
+	Binary  string   // 需要在 PATH（或指定路径）中存在的可执行文件
+	Command string   // 写入配置的命令，为空时使用 Binary
+	Args    []string // 写入配置的参数
+	Enabled bool     // 发现后是否默认启用
+}
+
+// mcpCandidates 常见 MCP 服务器
+var mcpCandidates = []mcpCandidate{
+	{Name: "builtin-filesystem", Binary: "./bin/mcp-server", Args: []string{"--allow-root", "."}, Enabled: true},
+	{Name: "gopls", Binary: "gopls", Args: []string{"mcp"}},
+	{Name: "filesystem", Binary: "npx", Args: []string{"-y", "@modelcontextprotocol/server-filesystem", "."}},
+	{Name: "git", Binary: "uvx", Args: []string{"mcp-server-git", "--repository", "."}},
+	{Name: "fetch", Binary: "uvx", Args: []string{"mcp-server-fetch"}},
+}
+
+// initConfig 生成配置所需的参数
+type initConfig struct {
+	Listen     string
+	Host       string
+	Model      string
+	EmbedModel string
+	MCPServers []mcpCandidate
+}
+
+// runInit 交互式（或通过 flag）生成配置文件
+func runInit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	output := fs.String("output", *configFile, "生成的配置文件路径")
+	host := fs.String("host", "http://localhost:11434", "Ollama 地址")
+	model := fs.String("model", "", "聊天模型（为空时从 Ollama 已有模型中选择）")
+	embedModel := fs.String("embed-model", "", "嵌入模型（为空时从 Ollama 已有模型中选择）")
+	listen := fs.String("listen", "localhost:8080", "HTTP 监听地址")
+	yes := fs.Bool("yes", false, "非交互模式，全部使用默认值或 flag 指定的值")
+	force := fs.Bool("force", false, "覆盖已存在的配置文件")
+	fs.Parse(args)
+
+	if _, err := os.Stat(*output); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use --force to overwrite", *output)
+	}
+
+	p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout, auto: *yes}
+	cfg := initConfig{}
+
+	cfg.Listen = p.ask("HTTP 监听地址", *listen)
+	cfg.Host = p.ask("Ollama 地址", *host)
+
+	// 探测 Ollama 并列出模型
+	models, err := probeModels(ctx, cfg.Host)
+	if err != nil {
+		fmt.Fprintf(p.out, "! 无法连接 Ollama (%v)，请手动填写模型名称\n", err)
+	} else {
+		fmt.Fprintf(p.out, "✓ 已连接 Ollama，发现 %d 个模型\n", len(models))
+	}
+
+	cfg.Model = p.choose("聊天模型", models, *model, pickModel(models, false))
+	cfg.EmbedModel = p.choose("嵌入模型", models, *embedModel, pickModel(models, true))
+
+	// 发现 MCP 服务器
+	for _, c := range discoverMCPServers() {
+		c.Enabled = p.confirm(fmt.Sprintf("发现 MCP 服务器 %s (%s)，是否启用", c.Name, c.Command), c.Enabled)
+		cfg.MCPServers = append(cfg.MCPServers, c)
+	}
+
+	if err := writeConfig(*output, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "✓ 配置已写入 %s\n", *output)
+	return nil
+}
+
+// probeModels 查询 Ollama 已有的模型
+func probeModels(ctx context.Context, host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, initProbeTimeout)
+	defer cancel()
+
+	client, err := ollama.NewClient(host, "", ollama.Options{ConnectTimeout: initProbeTimeout, ResponseTimeout: initProbeTimeout})
+	if err != nil {
+		return nil, err
+	}
+	return client.ListModels(ctx)
+}
+
+// pickModel 根据名称猜测默认模型
+func pickModel(models []string, embed bool) string {
+	for _, m := range models {
+		if strings.Contains(m, "embed") == embed {
+			return m
+		}
+	}
+	if embed {
+		return "nomic-embed-text:latest"
+	}
+	return "qwen3-coder:480b-cloud"
+}
+
+// discoverMCPServers 在 PATH 中查找常见 MCP 服务器
+func discoverMCPServers() []mcpCandidate {
+	var found []mcpCandidate
+	for _, c := range mcpCandidates {
+		path, err := exec.LookPath(c.Binary)
+		if err != nil {
+			continue
+		}
+		if c.Command == "" {
+			c.Command = c.Binary
+			if filepath.IsAbs(path) && !strings.Contains(c.Binary, "/") {
+				c.Command = path
+			}
+		}
+		found = append(found, c)
+	}
+	return found
+}
+
+// configTemplate 生成的配置文件模板
+var configTemplate = template.Must(template.New("config").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+	"args": func(args []string) string {
+		quoted := make([]string, len(args))
+		for i, a := range args {
+			quoted[i] = strconv.Quote(a)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	},
+}).Parse(`# AIAgent 配置文件（由 agent init 生成）
+# 服务器配置
+server:
+  name: "AIAgent"
+  version: "v1.0.0"
+  listen: {{quote .Listen}}
+  debug: false
+# Ollama 配置
+ollama:
+  host: {{quote .Host}}
+  model: {{quote .Model}}
+  timeout: 600s
+  max_retries: 3
+# RAG 配置
+rag:
+  embed_model: {{quote .EmbedModel}}
+  chunk_size: 500
+  chunk_overlap: 50
+  top_k: 3
+  documents_dir: "docs/rag"
+  store_path: "data/rag.json"
+# MCP 服务器配置
+mcp_servers:{{if not .MCPServers}} []{{end}}
+{{- range .MCPServers}}
+  - name: {{quote .Name}}
+    command: {{quote .Command}}
+    args: {{args .Args}}
+    transport: "stdio"
+    enabled: {{.Enabled}}
+{{- end}}
+`))
+
+// writeConfig 渲染并写入配置文件
+func writeConfig(path string, cfg initConfig) error {
+	var sb strings.Builder
+	if err := configTemplate.Execute(&sb, cfg); err != nil {
+		return fmt.Errorf("render config: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("create config directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
+
+// prompter 交互式输入
+type prompter struct {
+	in   *bufio.Reader
+	out  io.Writer
+	auto bool // 非交互模式，直接返回默认值
+}
+
+// ask 询问字符串，回车使用默认值
+func (p *prompter) ask(label, def string) string {
+	if p.auto {
+		return def
+	}
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", label, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", label)
+	}
+	line, _ := p.in.ReadString('\n')
+	if line = strings.TrimSpace(line); line == "" {
+		return def
+	}
+	return line
+}
+
+// choose 从候选列表中选择（支持输入序号或名称），flagValue 非空时直接使用
+func (p *prompter) choose(label string, options []string, flagValue, def string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if p.auto || len(options) == 0 {
+		return p.ask(label, def)
+	}
+
+	fmt.Fprintf(p.out, "%s:\n", label)
+	for i, opt := range options {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, opt)
+	}
+	answer := p.ask("请选择序号或输入名称", def)
+	if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+		return options[n-1]
+	}
+	return answer
+}
+
+// confirm 询问是否确认
+func (p *prompter) confirm(label string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	answer := strings.ToLower(p.ask(label+" ("+hint+")", ""))
+	switch answer {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
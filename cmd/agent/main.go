@@ -6,62 +6,211 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sort"
 	"syscall"
+	"time"
 
 	"github.com/champly/ai-agent/pkg/agent"
 	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/email"
+	"github.com/champly/ai-agent/pkg/operator"
+	"github.com/champly/ai-agent/pkg/secrets"
 	"github.com/champly/ai-agent/pkg/server"
+	"github.com/champly/ai-agent/pkg/slack"
+	"github.com/champly/ai-agent/pkg/telegram"
+	"github.com/champly/ai-agent/pkg/watcher"
 	"k8s.io/klog/v2"
 )
 
 var configFile = flag.String("config", "config.yaml", "配置文件路径")
 
+// command 子命令
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+// commands 子命令列表，未指定子命令时默认运行 serve
+var commands = map[string]command{
+	"serve":   {"启动 HTTP API 服务（默认）", runServe},
+	"init":    {"探测本地环境并生成配置文件", runInit},
+	"tui":     {"启动终端交互界面", runTUI},
+	"ask":     {"单次提问，支持管道输入：cat error.log | agent ask \"what's wrong\"", runAsk},
+	"tools":   {"工具调试：tools list | tools call <name> --args '{...}'", runTools},
+	"history": {"对话历史：history list | history show <id> | history export <id>", runHistory},
+	"rag":     {"知识库：rag ingest <path|url> [--collection X] | rag search \"query\"", runRAG},
+	"audit":   {"审计日志：audit verify [FILE] [--public-key KEY]", runAudit},
+	"mcp":     {"以 stdio 传输把 Agent 作为 MCP 服务器（ask_agent 工具）提供给 IDE 等 MCP 客户端", runMCP},
+}
+
 func main() {
 	// 初始化 klog
 	klog.InitFlags(nil)
+	flag.Usage = usage
 	flag.Parse()
 
-	// 加载配置
-	cfg, err := config.Load(*configFile)
-	if err != nil {
-		klog.ErrorS(err, "Failed to load config", "file", *configFile)
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	name, args := "serve", flag.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(ctx, args); err != nil {
+		klog.ErrorS(err, "Command failed", "command", name)
+		klog.Flush()
 		os.Exit(1)
 	}
+	klog.Flush()
+}
+
+// usage 打印帮助信息
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] <command> [args]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// loadConfig 加载配置并设置日志级别
+func loadConfig() (*config.Config, error) {
+	_, cfg, err := loadConfigs()
+	return cfg, err
+}
+
+// loadConfigs 加载配置文件，返回原始配置和解析了密钥引用的配置
+func loadConfigs() (raw, cfg *config.Config, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	raw, cfg, err = secrets.Load(ctx, *configFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load config %s: %w", *configFile, err)
+	}
 
 	// 设置日志级别
 	if cfg.Server.Debug {
 		flag.Set("v", "3")
 	}
+	return raw, cfg, nil
+}
+
+// startAgent 创建并启动进程内代理
+func startAgent(ctx context.Context, cfg *config.Config) (*agent.Agent, error) {
+	ag, err := agent.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create agent: %w", err)
+	}
+	if err := ag.Start(ctx); err != nil {
+		return nil, fmt.Errorf("start agent: %w", err)
+	}
+	return ag, nil
+}
+
+// runServe 运行 Bridge 模式（HTTP API 服务器）
+func runServe(ctx context.Context, args []string) error {
+	rawCfg, cfg, err := loadConfigs()
+	if err != nil {
+		return err
+	}
 
 	klog.InfoS("Starting AIAgent",
 		"name", cfg.Server.Name,
 		"version", cfg.Server.Version)
 
-	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ag, err := startAgent(ctx, cfg)
+	if err != nil {
+		return err
+	}
 
-	// 启动 Bridge（HTTP API 服务器）
-	runBridge(ctx, cfg)
-}
+	// 密钥轮换：重新解析后重启凭证变化的 MCP 服务器（operator 模式下 MCP 服务器由 ToolProfile 管理）
+	if cfg.Secrets.Provider != "" {
+		secretsCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go secrets.Watch(secretsCtx, rawCfg, cfg, func(next *config.Config) {
+			if cfg.Operator.Enabled {
+				return
+			}
+			if err := ag.ReconcileMCPServers(secretsCtx, next.MCPServers); err != nil {
+				klog.ErrorS(err, "Failed to apply rotated MCP server credentials")
+			}
+		})
+	}
 
-// runBridge 运行 Bridge 模式
-func runBridge(ctx context.Context, cfg *config.Config) {
-	// 创建代理
-	ag, err := agent.New(cfg)
-	if err != nil {
-		klog.ErrorS(err, "Failed to create agent")
-		os.Exit(1)
+	// 声明式配置：所有副本都根据集群中的 Agent 资源调整运行配置
+	if cfg.Operator.Enabled {
+		controller, err := operator.New(cfg.Operator, ag)
+		if err != nil {
+			return fmt.Errorf("create operator: %w", err)
+		}
+		opCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go controller.Run(opCtx)
 	}
 
-	// 启动代理
-	if err := ag.Start(ctx); err != nil {
-		klog.ErrorS(err, "Failed to start agent")
-		os.Exit(1)
+	// 监听 Kubernetes 告警事件并自动诊断（仅主节点执行）
+	if cfg.Watcher.Enabled {
+		w, err := watcher.New(cfg.Watcher, ag)
+		if err != nil {
+			return fmt.Errorf("create kubernetes watcher: %w", err)
+		}
+		ag.RegisterLeaderJob("k8s-watcher", w.Run)
+	}
+
+	// 聊天平台连接器：Slack 在每个副本各自建立 Socket Mode 连接（Slack 把每个事件只投递给其中一个连接），
+	// Telegram 的 Bot API 只允许一个连接获取更新、邮箱轮询避免重复回复，仅主节点运行
+	if cfg.Slack.Enabled {
+		if err := ag.RegisterConnector(slack.New(cfg.Slack)); err != nil {
+			return fmt.Errorf("register slack connector: %w", err)
+		}
 	}
+	if cfg.Telegram.Enabled {
+		if err := ag.RegisterConnector(telegram.New(cfg.Telegram)); err != nil {
+			return fmt.Errorf("register telegram connector: %w", err)
+		}
+	}
+	if cfg.Email.Enabled {
+		if err := ag.RegisterConnector(email.New(cfg.Email)); err != nil {
+			return fmt.Errorf("register email connector: %w", err)
+		}
+	}
+
+	// 参与主节点选举，主节点负责执行后台任务
+	ag.StartBackground(ctx)
 
 	// 创建 HTTP API 服务器
 	apiServer := server.NewServer(cfg.Server.Listen, ag)
+	if err := apiServer.EnableTLS(cfg.Server.TLS); err != nil {
+		return fmt.Errorf("configure tls: %w", err)
+	}
+	if cfg.A2A.Enabled {
+		apiServer.EnableA2A(cfg.A2A, cfg.Server)
+	}
+	if cfg.MCPEndpoint.Enabled {
+		apiServer.EnableMCP(cfg.MCPEndpoint, cfg.Server)
+	}
+	if !cfg.Server.DisableUI {
+		apiServer.EnableUI()
+	}
+	if err := apiServer.EnableAuth(ctx, cfg.Server.Auth); err != nil {
+		return fmt.Errorf("configure auth: %w", err)
+	}
 
 	// 启动服务器（在 goroutine 中）
 	go func() {
@@ -70,7 +219,24 @@ func runBridge(ctx context.Context, cfg *config.Config) {
 		}
 	}()
 
-	klog.InfoS("AIAgent ready", "listen", cfg.Server.Listen)
+	// 创建 gRPC API 服务器，与 HTTP API 共用 Agent、TLS 和认证配置
+	var grpcServer *server.GRPCServer
+	if cfg.Server.GRPCListen != "" {
+		grpcServer = server.NewGRPCServer(cfg.Server.GRPCListen, ag)
+		if err := grpcServer.EnableTLS(cfg.Server.TLS); err != nil {
+			return fmt.Errorf("configure grpc tls: %w", err)
+		}
+		if err := grpcServer.EnableAuth(ctx, cfg.Server.Auth); err != nil {
+			return fmt.Errorf("configure grpc auth: %w", err)
+		}
+		go func() {
+			if err := grpcServer.Start(); err != nil {
+				klog.ErrorS(err, "gRPC server failed")
+			}
+		}()
+	}
+
+	klog.InfoS("AIAgent ready", "listen", cfg.Server.Listen, "grpcListen", cfg.Server.GRPCListen)
 
 	// 等待信号
 	sigCh := make(chan os.Signal, 1)
@@ -83,13 +249,17 @@ func runBridge(ctx context.Context, cfg *config.Config) {
 	if err := apiServer.Stop(ctx); err != nil {
 		klog.ErrorS(err, "Failed to stop server")
 	}
+	if grpcServer != nil {
+		if err := grpcServer.Stop(ctx); err != nil {
+			klog.ErrorS(err, "Failed to stop gRPC server")
+		}
+	}
 
 	if err := ag.Stop(ctx); err != nil {
 		klog.ErrorS(err, "Failed to stop agent")
 	}
 
 	klog.InfoS("AIAgent shutdown complete")
-	klog.Flush()
-
 	fmt.Println("Goodbye!")
+	return nil
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/server"
+)
+
+// runMCP 在进程内启动 Agent，通过 stdio 提供 ask_agent 工具，直到客户端关闭连接。
+// stdout 用于 MCP 协议，日志输出到 stderr
+func runMCP(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
+	persona := fs.String("persona", "", "新对话默认使用的人设（默认使用配置中的 mcp_endpoint.persona）")
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if *persona != "" {
+		cfg.MCPEndpoint.Persona = *persona
+	}
+
+	ag, err := startAgent(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer ag.Stop(ctx)
+
+	klog.InfoS("Serving agent over MCP stdio", "persona", cfg.MCPEndpoint.Persona)
+	return server.NewAgentMCPServer(ag, cfg.MCPEndpoint, cfg.Server).Run(ctx, &mcp.StdioTransport{})
+}
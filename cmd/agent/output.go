@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 输出格式
+const (
+	outputText     = "text"
+	outputJSON     = "json"
+	outputYAML     = "yaml"
+	outputMarkdown = "markdown"
+)
+
+// addOutputFlag 为子命令添加 --output 参数
+func addOutputFlag(fs *flag.FlagSet) *string {
+	return fs.String("output", outputText, "输出格式：text、json、yaml 或 markdown")
+}
+
+// render 按指定格式输出结果
+// json/yaml 直接序列化 data（字段名以 json tag 为准，保证两种格式结构一致），
+// text 与 markdown 分别使用对应的渲染函数，markdown 未提供时回退到 text。
+func render(format string, data any, text, markdown func(io.Writer) error) error {
+	w := os.Stdout
+
+	switch format {
+	case outputText, "":
+		return text(w)
+	case outputMarkdown, "md":
+		if markdown == nil {
+			return text(w)
+		}
+		return markdown(w)
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	case outputYAML:
+		// 先经过 JSON 转换，复用 json tag 作为字段名
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		var generic any
+		if err := json.Unmarshal(raw, &generic); err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		defer enc.Close()
+		return enc.Encode(generic)
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/champly/ai-agent/pkg/rag"
+)
+
+// runRAG 知识库子命令：rag ingest <path|url> | rag search "query"
+func runRAG(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: rag ingest <path|url> [--collection X] | rag search \"query\" [--collection X]")
+	}
+
+	switch args[0] {
+	case "ingest":
+		return runRAGIngest(ctx, args[1:])
+	case "search":
+		return runRAGSearch(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown rag command: %s", args[0])
+	}
+}
+
+// runRAGIngest 导入文件、目录或 URL 到知识库
+func runRAGIngest(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("rag ingest", flag.ExitOnError)
+	collection := fs.String("collection", rag.DefaultCollection, "导入的目标集合")
+	server := fs.String("server", "", "远程 Agent 地址（如 http://localhost:8080），为空时在进程内导入")
+	targets := parseArgs(fs, args)
+	if len(targets) == 0 {
+		return fmt.Errorf("usage: rag ingest <path|url>... [--collection X]")
+	}
+
+	if *server != "" {
+		for _, target := range targets {
+			var resp struct {
+				Documents int `json:"documents"`
+			}
+			req := map[string]string{"source": target, "collection": *collection}
+			if err := callRemote(ctx, *server, "/api/rag/ingest", req, &resp); err != nil {
+				return err
+			}
+			fmt.Printf("%s: %d documents ingested into %q\n", target, resp.Documents, *collection)
+		}
+		return nil
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.RAG.StorePath == "" && cfg.RAG.Backend == "memory" {
+		return fmt.Errorf("rag.store_path is not configured, in-process ingestion would be lost; set it or use --server")
+	}
+
+	ag, err := startAgent(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer ag.Stop(ctx)
+
+	for _, target := range targets {
+		loaded, err := ag.IngestRAG(ctx, *collection, target)
+		if err != nil {
+			return fmt.Errorf("ingest %s: %w", target, err)
+		}
+		fmt.Printf("%s: %d documents ingested into %q\n", target, loaded, *collection)
+	}
+	saved := cfg.RAG.StorePath
+	if cfg.RAG.Backend != "memory" {
+		saved = cfg.RAG.Backend + " backend"
+	}
+	fmt.Printf("total chunks: %d (saved to %s)\n", ag.RAGDocumentCount(), saved)
+	return nil
+}
+
+// searchHit 检索结果（与 /api/rag/search 返回结构一致）
+type searchHit struct {
+	ID         string            `json:"id"`
+	Collection string            `json:"collection"`
+	Content    string            `json:"content"`
+	Score      float32           `json:"score"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// runRAGSearch 检索知识库
+func runRAGSearch(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("rag search", flag.ExitOnError)
+	collection := fs.String("collection", "", "检索的集合（为空时检索所有集合）")
+	topK := fs.Int("top-k", 0, "返回结果数（默认使用配置中的 top_k）")
+	server := fs.String("server", "", "远程 Agent 地址（如 http://localhost:8080），为空时在进程内检索")
+	output := addOutputFlag(fs)
+	words := parseArgs(fs, args)
+	query := strings.Join(words, " ")
+	if query == "" {
+		return fmt.Errorf("usage: rag search \"query\" [--collection X] [--top-k N]")
+	}
+
+	var hits []searchHit
+	if *server != "" {
+		var resp struct {
+			Results []searchHit `json:"results"`
+		}
+		req := map[string]any{"query": query, "collection": *collection, "top_k": *topK}
+		if err := callRemote(ctx, *server, "/api/rag/search", req, &resp); err != nil {
+			return err
+		}
+		hits = resp.Results
+	} else {
+		ag, err := startLocalAgent(ctx)
+		if err != nil {
+			return err
+		}
+		defer ag.Stop(ctx)
+
+		results, err := ag.SearchRAG(ctx, *collection, query, *topK)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			hits = append(hits, searchHit{
+				ID:         r.Document.ID,
+				Collection: r.Document.Collection,
+				Content:    r.Document.Content,
+				Score:      r.Score,
+				Metadata:   r.Document.Metadata,
+			})
+		}
+	}
+
+	data := map[string]any{"query": query, "results": hits, "count": len(hits)}
+	return render(*output, data, func(w io.Writer) error {
+		if len(hits) == 0 {
+			fmt.Fprintln(w, "no results")
+			return nil
+		}
+		for i, hit := range hits {
+			fmt.Fprintf(w, "[%d] %s (collection: %s, score: %.3f, source: %s)\n%s\n\n",
+				i+1, hit.ID, hit.Collection, hit.Score, hit.Metadata["source"], hit.Content)
+		}
+		return nil
+	}, func(w io.Writer) error {
+		for i, hit := range hits {
+			fmt.Fprintf(w, "### %d. %s\n\n- collection: %s\n- score: %.3f\n- source: %s\n\n%s\n\n",
+				i+1, hit.ID, hit.Collection, hit.Score, hit.Metadata["source"], hit.Content)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// remoteTimeout 访问远程 Agent 的超时时间
+const remoteTimeout = 10 * time.Minute
+
+// callRemote 调用远程 Agent 的 HTTP API，in 为空时使用 GET
+func callRemote(ctx context.Context, server, path string, in, out any) error {
+	method := http.MethodGet
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		method = http.MethodPost
+		body = bytes.NewReader(data)
+	}
+
+	url := strings.TrimRight(server, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := os.Getenv("AI_AGENT_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	transport, err := remoteTransport()
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: remoteTimeout, Transport: transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("request %s: %s: %s", url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// remoteTransport 根据环境变量配置访问 HTTPS Agent 的 CA 和客户端证书（mTLS）
+//   - AI_AGENT_CA_FILE：校验服务端证书的 CA
+//   - AI_AGENT_CERT_FILE / AI_AGENT_KEY_FILE：客户端证书和私钥
+func remoteTransport() (http.RoundTripper, error) {
+	caFile := os.Getenv("AI_AGENT_CA_FILE")
+	certFile, keyFile := os.Getenv("AI_AGENT_CERT_FILE"), os.Getenv("AI_AGENT_KEY_FILE")
+	if caFile == "" && certFile == "" {
+		return http.DefaultTransport, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/champly/ai-agent/pkg/agent"
+)
+
+// runTools 工具调试子命令：tools list | tools call <name> --args '{...}'
+func runTools(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: tools list | tools call <name> [--args JSON]")
+	}
+
+	switch args[0] {
+	case "list":
+		return runToolsList(ctx, args[1:])
+	case "call":
+		return runToolsCall(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown tools command: %s", args[0])
+	}
+}
+
+// runToolsList 列出所有已注册的工具
+func runToolsList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("tools list", flag.ExitOnError)
+	server := fs.String("server", "", "远程 Agent 地址（如 http://localhost:8080），为空时在进程内启动")
+	output := addOutputFlag(fs)
+	fs.Parse(args)
+
+	var tools []map[string]string
+	if *server != "" {
+		var resp struct {
+			Tools []map[string]string `json:"tools"`
+		}
+		if err := callRemote(ctx, *server, "/api/tools", nil, &resp); err != nil {
+			return err
+		}
+		tools = resp.Tools
+	} else {
+		ag, err := startLocalAgent(ctx)
+		if err != nil {
+			return err
+		}
+		defer ag.Stop(ctx)
+		tools = ag.ListTools(ctx)
+	}
+
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i]["name"] < tools[j]["name"]
+	})
+
+	return render(*output, map[string]any{"tools": tools}, func(out io.Writer) error {
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSOURCE\tCAPABILITIES\tDESCRIPTION")
+		for _, tool := range tools {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", tool["name"], tool["source"], tool["capabilities"], tool["description"])
+		}
+		return w.Flush()
+	}, func(w io.Writer) error {
+		fmt.Fprintln(w, "| Name | Source | Capabilities | Description |")
+		fmt.Fprintln(w, "|------|--------|--------------|-------------|")
+		for _, tool := range tools {
+			fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", tool["name"], tool["source"], tool["capabilities"], tool["description"])
+		}
+		return nil
+	})
+}
+
+// runToolsCall 直接调用指定工具
+func runToolsCall(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("tools call", flag.ExitOnError)
+	rawArgs := fs.String("args", "{}", "工具参数（JSON 对象）")
+	server := fs.String("server", "", "远程 Agent 地址（如 http://localhost:8080），为空时在进程内启动")
+	output := addOutputFlag(fs)
+	positional := parseArgs(fs, args)
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: tools call <name> [--args JSON] [--server URL]")
+	}
+	name := positional[0]
+
+	var toolArgs map[string]any
+	if err := json.Unmarshal([]byte(*rawArgs), &toolArgs); err != nil {
+		return fmt.Errorf("parse --args: %w", err)
+	}
+
+	var result string
+	if *server != "" {
+		var resp struct {
+			Result string `json:"result"`
+		}
+		req := map[string]any{"name": name, "arguments": toolArgs}
+		if err := callRemote(ctx, *server, "/api/tools/call", req, &resp); err != nil {
+			return err
+		}
+		result = resp.Result
+	} else {
+		ag, err := startLocalAgent(ctx)
+		if err != nil {
+			return err
+		}
+		defer ag.Stop(ctx)
+
+		if result, err = ag.CallTool(ctx, name, toolArgs); err != nil {
+			return err
+		}
+	}
+
+	data := map[string]any{"tool": name, "arguments": toolArgs, "result": result}
+	return render(*output, data, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, result)
+		return err
+	}, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "## %s\n\n```\n%s\n```\n", name, result)
+		return err
+	})
+}
+
+// startLocalAgent 加载配置并在进程内启动代理
+func startLocalAgent(ctx context.Context) (*agent.Agent, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return startAgent(ctx, cfg)
+}
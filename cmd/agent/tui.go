@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/tui"
+)
+
+// runTUI 运行终端交互界面
+func runTUI(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	model := fs.String("model", "", "使用的模型（默认使用配置中的模型）")
+	logFile := fs.String("log-file", filepath.Join(os.TempDir(), "ai-agent-tui.log"), "日志输出文件")
+	fs.Parse(args)
+
+	// 日志写入文件，避免破坏终端界面
+	f, err := os.OpenFile(*logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	defer f.Close()
+	klog.LogToStderr(false)
+	klog.SetOutput(f)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if *model == "" {
+		*model = cfg.Ollama.Model
+	}
+
+	ag, err := startAgent(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer ag.Stop(ctx)
+
+	return tui.Run(ctx, ag, *model)
+}
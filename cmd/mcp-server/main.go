@@ -3,15 +3,80 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"k8s.io/klog/v2"
 
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/egress"
+	"github.com/champly/ai-agent/pkg/exectools"
+	"github.com/champly/ai-agent/pkg/hosttools"
+	"github.com/champly/ai-agent/pkg/k8stools"
 	"github.com/champly/ai-agent/pkg/mcpserver"
+	"github.com/champly/ai-agent/pkg/promtools"
+	"github.com/champly/ai-agent/pkg/sandbox"
+	"github.com/champly/ai-agent/pkg/secretscan"
+	"github.com/champly/ai-agent/pkg/shelltools"
 )
 
-var allowRoot = flag.String("allow-root", "/tmp", "允许访问的根目录")
+var (
+	allowRoot = flag.String("allow-root", "/tmp", "允许访问的根目录")
+
+	// Kubernetes 工具集（只读）
+	enableKubernetes  = flag.Bool("kubernetes", false, "启用 Kubernetes 只读工具集")
+	kubeconfig        = flag.String("kubeconfig", "", "kubeconfig 路径（为空时优先使用集群内凭证）")
+	kubeContext       = flag.String("kube-context", "", "kubeconfig 上下文")
+	kubeNamespaces    = flag.String("kube-namespaces", "", "允许访问的命名空间（逗号分隔，* 表示全部，默认为当前命名空间）")
+	kubeClusterScoped = flag.Bool("kube-allow-cluster-scoped", false, "允许查询集群级资源（如 nodes）")
+
+	// 容器内命令执行（kubernetes 运行时复用上面的 kubeconfig 与命名空间参数）
+	execRuntime       = flag.String("exec", "", "启用容器内命令执行工具：docker 或 kubernetes")
+	execAllowedImages = flag.String("exec-allowed-images", "", "允许执行命令的镜像（逗号分隔，结尾 * 为前缀匹配，* 表示全部）")
+	execTimeout       = flag.Duration("exec-timeout", 30*time.Second, "单条命令最大执行时间")
+	execMaxOutput     = flag.Int("exec-max-output", 64<<10, "stdout/stderr 各自的最大返回字节数")
+
+	// shell 命令与补丁（工作目录为 --allow-root）
+	enableShell    = flag.Bool("shell", false, "启用 run_shell 和 apply_patch 工具")
+	shellTimeout   = flag.Duration("shell-timeout", 60*time.Second, "单条命令最大执行时间")
+	shellMaxOutput = flag.Int("shell-max-output", 64<<10, "stdout/stderr 各自的最大返回字节数")
+	sandboxBackend = flag.String("sandbox", sandbox.BackendBubblewrap, "shell 工具默认使用的沙箱：bubblewrap、docker 或 none")
+	sandboxTools   = flag.String("sandbox-tools", "", "按工具覆盖沙箱类型，如 apply_patch=none,run_shell=docker")
+	sandboxImage   = flag.String("sandbox-image", "debian:stable-slim", "docker 沙箱使用的镜像（需要包含 sh 和 patch）")
+	sandboxCPUs    = flag.Float64("sandbox-cpus", 1, "沙箱 CPU 核数上限，0 表示不限制")
+	sandboxMemory  = flag.String("sandbox-memory", "512m", "沙箱内存上限，如 512m、2g，0 表示不限制")
+	sandboxNetwork = flag.Bool("sandbox-network", false, "允许沙箱内的命令访问网络")
+
+	// Prometheus 查询
+	prometheusURL   = flag.String("prometheus-url", "", "Prometheus 地址，设置后启用 query_prometheus 工具")
+	prometheusToken = flag.String("prometheus-token", "", "访问 Prometheus 的 Bearer Token（也可通过 PROMETHEUS_TOKEN 环境变量设置）")
+
+	// 文件下载工具
+	downloadHosts   = flag.String("download-allowed-hosts", "", "允许下载的主机名（逗号分隔，支持 * 通配），设置后启用 download_file 工具")
+	downloadMaxSize = flag.String("download-max-size", "100m", "下载文件的大小上限，如 1g，0 表示不限制")
+	downloadTimeout = flag.Duration("download-timeout", 10*time.Minute, "单次下载的最长时间")
+
+	// 主机状态工具
+	enableHost = flag.Bool("host", false, "启用 system_info 和 list_processes 工具")
+	hostDisks  = flag.String("host-disks", "/", "system_info 报告使用情况的挂载点（逗号分隔）")
+
+	// 出站请求策略（Prometheus 等访问网络的工具）
+	egressHosts   = flag.String("egress-allowed-hosts", "", "允许访问的主机名（逗号分隔，支持 * 通配），--prometheus-url 的主机自动加入")
+	egressCIDRs   = flag.String("egress-allowed-cidrs", "", "允许连接的地址段（逗号分隔）；与 --egress-allowed-hosts 都为空时仅拒绝内部地址")
+	egressMaxSize = flag.String("egress-max-response", "16m", "响应体大小上限，如 10m，0 表示不限制")
+
+	// 写入前的凭证扫描（write_file、apply_patch、run_shell 中的 git commit）
+	secretScan          = flag.String("secret-scan", secretscan.ModeBlock, "发现疑似凭证时的处理：block（拦截）、warn（写入并在结果中警告）或 off")
+	secretScanDetectors = flag.String("secret-scan-detectors", strings.Join(secretscan.DefaultDetectors, ","), "使用的检测器（逗号分隔），可选 api_key、private_key、jwt、email 等内容过滤检测器")
+
+	// 压缩包工具（extract_archive、create_archive）的限制
+	archiveMaxSize    = flag.String("archive-max-size", "512m", "解压后或打包前的文件总大小上限，如 1g")
+	archiveMaxEntries = flag.Int("archive-max-entries", mcpserver.DefaultArchiveMaxEntries, "压缩包中文件和目录的总数上限")
+)
 
 func main() {
 	klog.InitFlags(nil)
@@ -24,10 +89,139 @@ func main() {
 		os.Exit(1)
 	}
 
+	// 创建凭证扫描器
+	var detectors []string
+	if *secretScanDetectors != "" {
+		detectors = strings.Split(*secretScanDetectors, ",")
+	}
+	scanner, err := secretscan.New(secretscan.Config{Mode: *secretScan, Detectors: detectors})
+	if err != nil {
+		klog.ErrorS(err, "Failed to create secret scanner")
+		os.Exit(1)
+	}
+	server.SetSecretScanner(scanner)
+
+	archiveMaxBytes, err := sandbox.ParseSize(*archiveMaxSize)
+	if err != nil || archiveMaxBytes <= 0 || *archiveMaxEntries <= 0 {
+		klog.ErrorS(err, "Invalid archive limits", "maxSize", *archiveMaxSize, "maxEntries", *archiveMaxEntries)
+		os.Exit(1)
+	}
+	server.SetArchiveLimits(mcpserver.ArchiveLimits{MaxBytes: archiveMaxBytes, MaxEntries: *archiveMaxEntries})
+
+	// 注册 Kubernetes 工具集
+	if *enableKubernetes {
+		var namespaces []string
+		if *kubeNamespaces != "" {
+			namespaces = strings.Split(*kubeNamespaces, ",")
+		}
+		toolset, err := k8stools.New(k8stools.Config{
+			Kubeconfig:         *kubeconfig,
+			Context:            *kubeContext,
+			Namespaces:         namespaces,
+			AllowClusterScoped: *kubeClusterScoped,
+		})
+		if err != nil {
+			klog.ErrorS(err, "Failed to create kubernetes toolset")
+			os.Exit(1)
+		}
+		toolset.Register(server.MCP())
+	}
+
+	// 注册容器内命令执行工具
+	if *execRuntime != "" {
+		var images, namespaces []string
+		if *execAllowedImages != "" {
+			images = strings.Split(*execAllowedImages, ",")
+		}
+		if *kubeNamespaces != "" {
+			namespaces = strings.Split(*kubeNamespaces, ",")
+		}
+		toolset, err := exectools.New(exectools.Config{
+			Runtime:       *execRuntime,
+			AllowedImages: images,
+			Namespaces:    namespaces,
+			Kubeconfig:    *kubeconfig,
+			Context:       *kubeContext,
+			Timeout:       *execTimeout,
+			MaxOutput:     *execMaxOutput,
+		})
+		if err != nil {
+			klog.ErrorS(err, "Failed to create container exec toolset")
+			os.Exit(1)
+		}
+		toolset.Register(server.MCP())
+	}
+
+	// 注册 shell 命令与补丁工具
+	if *enableShell {
+		sandboxes, err := newSandboxes()
+		if err != nil {
+			klog.ErrorS(err, "Failed to create sandbox")
+			os.Exit(1)
+		}
+		toolset, err := shelltools.New(shelltools.Config{
+			Root:      *allowRoot,
+			Sandboxes: sandboxes,
+			Timeout:   *shellTimeout,
+			MaxOutput: *shellMaxOutput,
+			Scanner:   scanner,
+		})
+		if err != nil {
+			klog.ErrorS(err, "Failed to create shell toolset")
+			os.Exit(1)
+		}
+		toolset.Register(server.MCP())
+	}
+
+	// 注册 Prometheus 查询工具
+	if *prometheusURL != "" {
+		token := *prometheusToken
+		if token == "" {
+			token = os.Getenv("PROMETHEUS_TOKEN")
+		}
+		policy, err := newEgressPolicy(*prometheusURL)
+		if err != nil {
+			klog.ErrorS(err, "Failed to create egress policy")
+			os.Exit(1)
+		}
+		toolset, err := promtools.New(promtools.Config{URL: *prometheusURL, BearerToken: token, Egress: policy})
+		if err != nil {
+			klog.ErrorS(err, "Failed to create prometheus toolset")
+			os.Exit(1)
+		}
+		toolset.Register(server.MCP())
+	}
+
+	// 注册文件下载工具
+	if *downloadHosts != "" {
+		maxSize, err := sandbox.ParseSize(*downloadMaxSize)
+		if err != nil {
+			klog.ErrorS(err, "Invalid --download-max-size")
+			os.Exit(1)
+		}
+		// 只允许列出的主机，重定向到其他主机时同样拒绝
+		policy, err := egress.New(config.EgressConfig{AllowedHosts: strings.Split(*downloadHosts, ","), MaxResponseBytes: maxSize})
+		if err != nil {
+			klog.ErrorS(err, "Failed to create download egress policy")
+			os.Exit(1)
+		}
+		server.EnableDownload(policy, maxSize, *downloadTimeout)
+	}
+
+	// 注册主机状态工具
+	if *enableHost {
+		toolset, err := hosttools.New(hosttools.Config{Disks: strings.Split(*hostDisks, ",")})
+		if err != nil {
+			klog.ErrorS(err, "Failed to create host toolset")
+			os.Exit(1)
+		}
+		toolset.Register(server.MCP())
+	}
+
 	// 使用 stdio 传输
 	transport := &mcp.StdioTransport{}
 
-	klog.InfoS("Starting builtin MCP Server", "allowRoot", *allowRoot)
+	klog.InfoS("Starting builtin MCP Server", "allowRoot", *allowRoot, "kubernetes", *enableKubernetes, "exec", *execRuntime, "shell", *enableShell, "prometheus", *prometheusURL, "host", *enableHost, "download", *downloadHosts)
 
 	// 启动 MCP Server（阻塞）
 	ctx := context.Background()
@@ -36,3 +230,66 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newSandboxes 根据 --sandbox 和 --sandbox-tools 为每个 shell 工具创建沙箱
+func newSandboxes() (map[string]*sandbox.Sandbox, error) {
+	memory, err := sandbox.ParseSize(*sandboxMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	backends := map[string]string{
+		shelltools.ToolShell: *sandboxBackend,
+		shelltools.ToolPatch: *sandboxBackend,
+	}
+	if *sandboxTools != "" {
+		for _, item := range strings.Split(*sandboxTools, ",") {
+			tool, backend, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if _, known := backends[tool]; !ok || !known {
+				return nil, fmt.Errorf("invalid --sandbox-tools entry %q", item)
+			}
+			backends[tool] = backend
+		}
+	}
+
+	sandboxes := make(map[string]*sandbox.Sandbox, len(backends))
+	for tool, backend := range backends {
+		sb, err := sandbox.New(sandbox.Config{
+			Backend: backend,
+			Workdir: *allowRoot,
+			Image:   *sandboxImage,
+			CPUs:    *sandboxCPUs,
+			Memory:  memory,
+			Network: *sandboxNetwork,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tool, err)
+		}
+		sandboxes[tool] = sb
+	}
+	return sandboxes, nil
+}
+
+// newEgressPolicy 根据 --egress-* 参数创建出站策略，trustedURLs 的主机视为已允许
+func newEgressPolicy(trustedURLs ...string) (*egress.Policy, error) {
+	maxSize, err := sandbox.ParseSize(*egressMaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --egress-max-response: %w", err)
+	}
+
+	cfg := config.EgressConfig{MaxResponseBytes: maxSize}
+	if *egressHosts != "" {
+		cfg.AllowedHosts = strings.Split(*egressHosts, ",")
+	}
+	if *egressCIDRs != "" {
+		cfg.AllowedCIDRs = strings.Split(*egressCIDRs, ",")
+	}
+	for _, raw := range trustedURLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", raw, err)
+		}
+		cfg.AllowedHosts = append(cfg.AllowedHosts, u.Hostname())
+	}
+	return egress.New(cfg)
+}
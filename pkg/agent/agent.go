@@ -1,56 +1,188 @@
 package agent
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/ollama/ollama/api"
 	"k8s.io/klog/v2"
 
+	"github.com/champly/ai-agent/pkg/analyzer"
+	"github.com/champly/ai-agent/pkg/audit"
+	"github.com/champly/ai-agent/pkg/cache"
 	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/egress"
+	"github.com/champly/ai-agent/pkg/embedding"
+	"github.com/champly/ai-agent/pkg/escalation"
+	"github.com/champly/ai-agent/pkg/experiment"
+	"github.com/champly/ai-agent/pkg/filter"
+	"github.com/champly/ai-agent/pkg/github"
+	"github.com/champly/ai-agent/pkg/guard"
+	"github.com/champly/ai-agent/pkg/images"
+	"github.com/champly/ai-agent/pkg/kgraph"
+	"github.com/champly/ai-agent/pkg/leader"
+	"github.com/champly/ai-agent/pkg/locale"
+	"github.com/champly/ai-agent/pkg/modelstats"
+	"github.com/champly/ai-agent/pkg/notes"
 	"github.com/champly/ai-agent/pkg/ollama"
+	"github.com/champly/ai-agent/pkg/plugin"
+	"github.com/champly/ai-agent/pkg/policy"
+	"github.com/champly/ai-agent/pkg/priority"
+	"github.com/champly/ai-agent/pkg/profile"
+	"github.com/champly/ai-agent/pkg/prompt"
+	"github.com/champly/ai-agent/pkg/quota"
 	"github.com/champly/ai-agent/pkg/rag"
+	"github.com/champly/ai-agent/pkg/router"
+	"github.com/champly/ai-agent/pkg/spill"
+	"github.com/champly/ai-agent/pkg/store"
+	"github.com/champly/ai-agent/pkg/toolprovider"
+	"github.com/champly/ai-agent/pkg/toolstats"
+	"github.com/champly/ai-agent/pkg/validator"
+	"github.com/champly/ai-agent/pkg/webhook"
+	"github.com/champly/ai-agent/pkg/workerpool"
+	"github.com/champly/ai-agent/pkg/workflow"
 )
 
 // Agent AI 代理
 type Agent struct {
 	cfg    *config.Config
-	ollama *ollama.Client
+	ollama *ollama.Pool
 
 	// 对话管理
 	conversations sync.Map // map[string]*Conversation
+	// 对话持久化存储（memory 模式下为 nil）
+	store store.Store
 
 	// 工具管理
 	toolRegistry *ToolRegistry
+	// 工具调用权限策略
+	policy *policy.Engine
+	// 内容过滤（用户消息、工具结果、模型输出）
+	filters *filter.Pipeline
+	// 最终回答的校验规则
+	validators *validator.Set
+	// 工具结果的提示注入防护
+	guard *guard.Guard
+	// 用户用量统计和配额
+	quota *quota.Manager
+	// 出站 HTTP 请求策略（网页导入等）
+	egress *egress.Policy
+	// 审计日志
+	audit *audit.Logger
+	// 模型响应缓存
+	cache *cache.Cache
+	// 工具结果缓存
+	toolCache *cache.ToolCache
+	toolStats *toolstats.Recorder
+	// modelStats 各模型的 token 数、生成速度和截断统计
+	modelStats *modelstats.Recorder
+	images     *images.Store
+	router     *router.Router
+	escalation *escalation.Escalator
+	models     *modelRegistry
+	// 聊天请求的并发限制
+	workers *workerpool.Pool
+	// 大工具结果转存
+	spill *spill.Store
+	// 知识图谱的抽取队列，未启用时为 nil
+	graphs *graphExtractor
+	// 用户资料及其学习队列，未启用或不学习时为 nil
+	profiles     *profile.Store
+	profileQueue *learnQueue
+	// 后台任务
+	tasks *taskManager
+	// 后台知识库导入
+	ingests *ingestManager
+	// YAML 定义的工作流
+	workflows *workflow.Engine
+	// 组合工具，以工作流的方式执行
+	composites *workflow.Engine
+	// 提示模板
+	prompts *prompt.Library
+	// 提示 A/B 实验
+	experiments *experiment.Experiments
+	// 工具使用说明和调用示例
+	hints *toolHints
+	// 入站 webhook
+	webhooks *webhook.Receiver
+	// 仓库分析
+	analyzer *analyzer.Analyzer
 
 	// 外部 MCP 客户端管理器
 	mcpClient *MCPClient
+	// mcpToolsMu 串行化 MCP 工具的重新注册
+	mcpToolsMu sync.Mutex
+	// 外部工具插件
+	plugins *plugin.Manager
+	// 远程 gRPC 工具服务
+	toolProviders *toolprovider.Manager
 
 	// RAG 模块
 	rag *rag.RAG
+
+	// 主节点选举（后台任务仅在主节点执行）
+	leader *leader.Manager
+	// 聊天平台连接器
+	connectors *connectorManager
+	// GitHub 拉取请求审查，未启用时为 nil
+	github *githubReviewer
+
+	// 默认模型，可在运行时调整（如 operator 模式）
+	modelMu sync.RWMutex
+	model   string
 }
 
 // New 创建 AI 代理
 func New(cfg *config.Config) (*Agent, error) {
 	agent := &Agent{
 		cfg:          cfg,
-		toolRegistry: NewToolRegistry(),
+		toolRegistry: NewToolRegistry(cfg.ToolCapabilities),
+		ingests:      newIngestManager(cfg.RAG.IngestJobs, cfg.Tasks.TTL),
+		model:        cfg.Ollama.Model,
 	}
 
-	// 初始化 Ollama 客户端
-	client, err := ollama.NewClient(
-		cfg.Ollama.Host,
-		cfg.Ollama.Model,
-		cfg.Ollama.Timeout,
-	)
+	tasks, err := newTaskManager(cfg.Tasks, cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task queue: %w", err)
+	}
+	agent.tasks = tasks
+	tasks.queue.Register(chatJobKind, agent.runTask)
+	tasks.queue.Register(analyzeJobKind, agent.runTask)
+	tasks.queue.Register(reviewJobKind, agent.runTask)
+
+	// 初始化 Ollama 客户端（支持多主机）
+	ollamaOpts := ollama.Options{
+		ConnectTimeout:    cfg.Ollama.ConnectTimeout,
+		ResponseTimeout:   cfg.Ollama.Timeout,
+		StreamIdleTimeout: cfg.Ollama.StreamIdleTimeout,
+		MaxIdleConns:      cfg.Ollama.MaxIdleConns,
+		IdleConnTimeout:   cfg.Ollama.IdleConnTimeout,
+		KeepAlive:         cfg.Ollama.KeepAlive,
+	}
+	client, err := ollama.NewPool(ollama.PoolConfig{
+		Hosts:          cfg.Ollama.Hosts,
+		Model:          cfg.Ollama.Model,
+		Options:        ollamaOpts,
+		Routing:        cfg.Ollama.Routing,
+		HealthInterval: cfg.Ollama.HealthInterval,
+		MaxConcurrent:  cfg.Ollama.MaxConcurrent,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ollama client: %w", err)
 	}
 	agent.ollama = client
+	agent.models = newModelRegistry(client)
 
 	// 初始化 RAG 模块
 	ragCfg := &rag.Config{
@@ -58,12 +190,183 @@ func New(cfg *config.Config) (*Agent, error) {
 		ChunkSize:    cfg.RAG.ChunkSize,
 		ChunkOverlap: cfg.RAG.ChunkOverlap,
 	}
-	agent.rag = rag.New(ragCfg, func(ctx context.Context, text string) ([]float32, error) {
-		return client.Embed(ctx, cfg.RAG.EmbedModel, text)
-	})
+	embed, err := embedding.New(cfg.RAG.Embedding, cfg.RAG.EmbedModel, client, ollamaOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding provider: %w", err)
+	}
+	agent.rag = rag.New(ragCfg, rag.EmbeddingFunc(embed))
+	switch {
+	case cfg.RAG.Backend == "redis":
+		backend, err := rag.NewRedisBackend(cfg.Redis, cfg.RAG.EmbedModel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create rag backend: %w", err)
+		}
+		if err := agent.rag.SetBackend(context.Background(), backend); err != nil {
+			return nil, fmt.Errorf("failed to load rag backend: %w", err)
+		}
+	case cfg.RAG.StorePath != "":
+		if err := agent.rag.Load(cfg.RAG.StorePath); err != nil {
+			return nil, fmt.Errorf("failed to load rag store: %w", err)
+		}
+	}
+
+	// 初始化工具策略
+	engine, err := policy.New(cfg.Policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tool policy: %w", err)
+	}
+	agent.policy = engine
+
+	// 初始化内容过滤
+	filters, err := filter.New(cfg.Filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load content filters: %w", err)
+	}
+	agent.filters = filters
+
+	// 初始化回答校验规则
+	agent.validators, err = validator.New(cfg.OutputValidation.Validators)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load output validators: %w", err)
+	}
+
+	// 初始化提示注入防护
+	agent.guard, err = guard.New(cfg.Guard, agent.classifyChat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tool result guard: %w", err)
+	}
+
+	// 初始化按任务类型的模型路由
+	agent.router, err = router.New(cfg.Routing, agent.routeChat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create model router: %w", err)
+	}
+
+	// 初始化小模型优先的升级策略
+	agent.escalation, err = escalation.New(cfg.Escalation, agent.escalationChat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create model escalation: %w", err)
+	}
+
+	// 初始化用量配额
+	agent.quota, err = quota.New(cfg.Quota, cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quota manager: %w", err)
+	}
+
+	// 初始化出站策略，OpenAPI 工具的请求同样经过该策略
+	agent.egress, err = egress.New(cfg.Egress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load egress policy: %w", err)
+	}
+
+	// 初始化审计日志
+	agent.audit, err = audit.New(cfg.Audit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	// 初始化响应缓存
+	agent.cache, err = cache.New(cfg.Cache, cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create response cache: %w", err)
+	}
+	agent.toolCache = cache.NewToolCache(cfg.ToolCache)
+	agent.toolStats = toolstats.New()
+	agent.modelStats = modelstats.New()
+	agent.images, err = images.New(cfg.Images, cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image store: %w", err)
+	}
+
+	agent.workers = workerpool.New(cfg.Workers)
+
+	agent.spill, err = spill.New(cfg.ToolResults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tool result store: %w", err)
+	}
+	if agent.spill != nil {
+		agent.toolRegistry.Register(newReadMoreTool(agent.spill))
+	}
+	noteStore, err := notes.New(cfg.Notes, cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create note store: %w", err)
+	}
+	if noteStore != nil {
+		for _, tool := range newNoteTools(noteStore) {
+			agent.toolRegistry.Register(tool)
+		}
+	}
+	graph, err := kgraph.New(cfg.KnowledgeGraph)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open knowledge graph: %w", err)
+	}
+	if graph != nil {
+		agent.graphs = newGraphExtractor(agent, graph, cfg.KnowledgeGraph)
+		agent.toolRegistry.Register(newKnowledgeGraphTool(agent))
+	}
+	agent.profiles, err = profile.New(cfg.Profiles, cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profile store: %w", err)
+	}
+	if agent.profiles != nil && !cfg.Profiles.SkipLearning {
+		agent.profileQueue = newLearnQueue("profiles", 1, cfg.Profiles.QueueSize)
+	}
+	if cfg.GitHub.Enabled {
+		client, err := github.New(cfg.GitHub)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create github client: %w", err)
+		}
+		agent.github = &githubReviewer{cfg: cfg.GitHub, client: client, seen: make(map[string]time.Time)}
+		for _, tool := range newGitHubTools(agent.github) {
+			agent.toolRegistry.Register(tool)
+		}
+	}
+
+	agent.workflows, err = workflow.Load(cfg.Workflows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflows: %w", err)
+	}
+	agent.composites, err = loadCompositeTools(cfg.CompositeTools, cfg.Workflows.MaxSteps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load composite tools: %w", err)
+	}
+	agent.prompts, err = prompt.Load(cfg.Prompts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt templates: %w", err)
+	}
+	agent.experiments = experiment.New(cfg.Experiments)
+	agent.hints = newToolHints(cfg.ToolHints)
+
+	agent.webhooks, err = webhook.New(cfg.Webhooks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhooks: %w", err)
+	}
+	agent.analyzer = analyzer.New(cfg.Analysis)
+
+	// 初始化对话存储
+	convStore, err := store.New(cfg.Conversation, cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conversation store: %w", err)
+	}
+	agent.store = convStore
+
+	// 初始化主节点选举
+	elector, err := leader.New(cfg.Leader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create leader elector: %w", err)
+	}
+	agent.leader = elector
+	agent.registerGitSources()
+
+	connectors, err := newConnectorManager(cfg.Connectors, cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connector manager: %w", err)
+	}
+	agent.connectors = connectors
 
 	klog.InfoS("Ollama client initialized",
-		"host", cfg.Ollama.Host,
+		"hosts", cfg.Ollama.Hosts,
 		"model", cfg.Ollama.Model)
 	klog.InfoS("RAG module initialized",
 		"embedModel", cfg.RAG.EmbedModel,
@@ -82,22 +385,33 @@ func (a *Agent) Start(ctx context.Context) error {
 	if err := a.ollama.Ping(ctx); err != nil {
 		return fmt.Errorf("failed to connect to Ollama: %w", err)
 	}
-	klog.InfoS("Successfully connected to Ollama", "host", a.cfg.Ollama.Host)
+	klog.InfoS("Successfully connected to Ollama", "hosts", a.cfg.Ollama.Hosts)
+	a.ollama.StartHealthCheck()
+	a.models.discover(ctx, a.configuredModels())
+	a.warmup(ctx)
 
 	// 启动外部 MCP 客户端管理器
+	a.mcpClient = NewMCPClient(a.cfg.MCPServers)
+	a.mcpClient.OnToolsChanged(a.registerMCPTools)
 	if len(a.cfg.MCPServers) > 0 {
-		a.mcpClient = NewMCPClient(a.cfg.MCPServers)
 		if err := a.mcpClient.Start(ctx); err != nil {
 			return fmt.Errorf("failed to start MCP manager: %w", err)
 		}
+		a.registerMCPTools()
+	}
 
-		// 注册外部 MCP 工具
-		externalTools := a.mcpClient.GetAllTools()
-		for _, tool := range externalTools {
-			a.toolRegistry.Register(tool)
-		}
-		klog.InfoS("External MCP tools registered", "count", len(externalTools))
+	// 启动插件目录中的工具插件，插件工具不覆盖同名的 MCP 工具
+	plugins, err := plugin.Start(ctx, a.cfg.Plugins)
+	if err != nil {
+		return fmt.Errorf("failed to start plugins: %w", err)
 	}
+	a.plugins = plugins
+	a.registerPluginTools()
+	a.registerOpenAPITools(ctx)
+	a.toolProviders = toolprovider.Start(ctx, a.cfg.GRPCTools)
+	a.registerGRPCTools()
+	// 组合工具最后注册，不覆盖其他来源的同名工具
+	a.registerCompositeTools()
 
 	totalTools := a.toolRegistry.Count()
 	klog.InfoS("AIAgent started successfully", "totalTools", totalTools)
@@ -109,27 +423,142 @@ func (a *Agent) Start(ctx context.Context) error {
 func (a *Agent) Stop(ctx context.Context) error {
 	klog.InfoS("Stopping AIAgent")
 
+	// 停止后台任务和连接器
+	a.leader.Stop()
+	a.stopConnectors()
+	a.tasks.close()
+	a.ingests.close()
+	a.graphs.close()
+	if a.profileQueue != nil {
+		a.profileQueue.close()
+	}
+	a.workers.Close()
+	a.ollama.Close()
+
 	// 停止 MCP 管理器
 	if a.mcpClient != nil {
 		if err := a.mcpClient.Stop(ctx); err != nil {
 			klog.ErrorS(err, "Failed to stop MCP manager")
 		}
 	}
+	a.plugins.Close()
+	a.toolProviders.Close()
+
+	if err := a.audit.Close(); err != nil {
+		klog.ErrorS(err, "Failed to close audit log")
+	}
+	if err := a.spill.Close(); err != nil {
+		klog.ErrorS(err, "Failed to remove spilled tool results")
+	}
 
 	klog.InfoS("AIAgent stopped")
 	return nil
 }
 
-// ListTools 列出所有工具
-func (a *Agent) ListTools() []map[string]string {
-	tools := a.toolRegistry.List()
+// StartBackground 开始执行任务队列和已注册的连接器，并参与主节点选举，成为主节点后启动后台任务
+// 仅常驻服务（serve）需要调用，一次性命令行操作不执行队列中的任务，也不参与选举。
+func (a *Agent) StartBackground(ctx context.Context) {
+	a.tasks.start()
+	a.startConnectors(ctx)
+	a.leader.Start(ctx)
+}
+
+// RegisterLeaderJob 注册仅在主节点执行的后台任务（需在 StartBackground 之前调用）
+func (a *Agent) RegisterLeaderJob(name string, job leader.Job) {
+	a.leader.Register(name, job)
+}
+
+// IsLeader 当前实例是否为主节点
+func (a *Agent) IsLeader() bool {
+	return a.leader.IsLeader()
+}
+
+// Identity 返回当前实例标识
+func (a *Agent) Identity() string {
+	return a.leader.Identity()
+}
+
+// warmup 在后台加载 ollama.warmup 中的模型，不阻塞启动；加载失败只记录日志
+func (a *Agent) warmup(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	for _, model := range a.cfg.Ollama.Warmup {
+		go func() {
+			start := time.Now()
+			if err := a.ollama.Load(ctx, model); err != nil {
+				klog.ErrorS(err, "Failed to warm up model", "model", model)
+				return
+			}
+			klog.InfoS("Model warmed up", "model", model, "duration", time.Since(start))
+		}()
+	}
+}
+
+// LoadModel 在所有已拉取该模型的主机上加载模型并按 ollama.keep_alive 保持，model 为空时使用默认模型
+func (a *Agent) LoadModel(ctx context.Context, model string) error {
+	return a.ollama.Load(ctx, cmp.Or(model, a.DefaultModel()))
+}
+
+// UnloadModel 在所有已拉取该模型的主机上卸载模型，model 为空时使用默认模型
+func (a *Agent) UnloadModel(ctx context.Context, model string) error {
+	return a.ollama.Unload(ctx, cmp.Or(model, a.DefaultModel()))
+}
+
+// registerMCPTools 以外部 MCP 客户端的当前工具替换注册表中的 MCP 工具
+func (a *Agent) registerMCPTools() {
+	// 工具列表刷新可能同时触发，串行替换避免旧的工具列表覆盖新的
+	a.mcpToolsMu.Lock()
+	defer a.mcpToolsMu.Unlock()
+
+	externalTools := a.mcpClient.GetAllTools()
+	a.toolRegistry.ReplaceSource(mcpSourcePrefix, externalTools)
+	klog.InfoS("External MCP tools registered", "count", len(externalTools))
+}
+
+// ReconcileMCPServers 将运行中的外部 MCP 服务器调整为给定配置：
+// 启动新增的、重启配置变化的、停止已移除的，并刷新工具注册表。
+func (a *Agent) ReconcileMCPServers(ctx context.Context, servers []config.MCPServerConfig) error {
+	if a.mcpClient == nil {
+		return fmt.Errorf("agent not started")
+	}
+	changed, err := a.mcpClient.Reconcile(ctx, servers)
+	if changed {
+		a.registerMCPTools()
+	}
+	return err
+}
+
+// DefaultModel 返回当前默认模型
+func (a *Agent) DefaultModel() string {
+	a.modelMu.RLock()
+	defer a.modelMu.RUnlock()
+	return a.model
+}
+
+// SetDefaultModel 调整默认模型，对之后的请求生效
+func (a *Agent) SetDefaultModel(model string) {
+	if model == "" {
+		model = a.DefaultModel()
+	}
+
+	a.modelMu.Lock()
+	defer a.modelMu.Unlock()
+	if a.model != model {
+		klog.InfoS("Default model changed", "from", a.model, "to", model)
+		a.model = model
+	}
+}
+
+// ListTools 列出 context 中用户可用的工具
+func (a *Agent) ListTools(ctx context.Context) []map[string]string {
+	tools := a.tenantTools(ctx)
 	result := make([]map[string]string, 0, len(tools))
 
 	for _, tool := range tools {
 		result = append(result, map[string]string{
-			"name":        tool.Name,
-			"description": tool.MCPTool.Description,
-			"source":      tool.Source,
+			"name":         tool.Name,
+			"description":  tool.MCPTool.Description,
+			"source":       tool.Source,
+			"capabilities": strings.Join(tool.Capabilities.Names(), ","),
 		})
 	}
 
@@ -137,35 +566,188 @@ func (a *Agent) ListTools() []map[string]string {
 }
 
 // Chat 处理聊天请求
-func (a *Agent) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
-	// 获取或创建对话
-	conv := a.getOrCreateConversation(req.ConversationID)
+func (a *Agent) Chat(ctx context.Context, req *ChatRequest) (resp *ChatResponse, err error) {
+	selected, err := a.persona(req.Persona)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := a.answerChecks(req)
+	if err != nil {
+		return nil, err
+	}
+	lang, err := locale.Normalize(req.Language)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCapabilities(req.DenyCapabilities); err != nil {
+		return nil, err
+	}
+	if err := checkStop(req.Stop); err != nil {
+		return nil, err
+	}
+	attached, err := a.images.Resolve(ctx, UserFromContext(ctx), req.Images, req.ImageIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 分配实验变体，渲染提示模板并过滤用户消息
+	id, assign := a.assignExperiment(req)
+	system, message, err := a.requestPrompt(ctx, req, assign)
+	if err != nil {
+		return nil, err
+	}
+	if message, err = a.filters.Apply(filter.StageInput, message); err != nil {
+		return nil, err
+	}
+
+	// 检查并计入请求配额，无效或被拦截的请求不计入
+	if err := a.chargeRequest(ctx); err != nil {
+		return nil, err
+	}
+
+	// 获取或创建对话，使用对话的人设；对话忙时退还请求配额
+	turnCtx, conv, endTurn, err := a.beginTurn(ctx, id, req.NoWait)
+	if err != nil {
+		a.refundRequest(ctx)
+		return nil, err
+	}
+	ctx = turnCtx
+	defer func() { err = endTurn(err) }()
+	defer func() { a.recordExperiment(conv, assign, resp, err) }()
+	persona := a.conversationPersona(conv, selected)
+	ctx = withPersona(ctx, persona)
+	checks = a.personaChecks(checks, persona)
+	ctx = withLanguage(ctx, a.conversationLanguage(conv, lang))
+	ctx = withDeniedCapabilities(ctx, req.DenyCapabilities)
+	ctx = withStop(ctx, req.Stop)
+	ctx = a.routeRequest(ctx, req.Model, persona, message)
+	a.snapshotProfile(ctx, conv)
 
 	// 添加用户消息
 	conv.AddMessage(api.Message{
 		Role:    "user",
-		Content: req.Message,
+		Content: message,
+		Images:  attached,
 	})
 
 	// 获取所有可用工具
-	tools := a.getAllOllamaTools()
+	tools := a.getAllOllamaTools(ctx)
 
 	// 开始对话循环
-	return a.conversationLoop(ctx, conv, tools, req.Model)
+	resp, err = a.runLoop(ctx, conv, tools, system, checks, req)
+	if err == nil {
+		a.learnFromTurn(ctx, conv.ID, message, resp)
+	}
+	return resp, err
+}
+
+// checkPrompt 校验以模板 name 处理请求时模板、变量与消息的组合，不渲染模板：
+// 模板定义了用户消息时不能再指定 req.Message；人设模板只提供系统提示，必须指定 req.Message
+func (a *Agent) checkPrompt(name string, req *ChatRequest) error {
+	if name == "" {
+		return nil
+	}
+	t, err := a.prompts.Check(name, req.Variables)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !t.Persona() && req.Message != "":
+		return fmt.Errorf("%w: message and template %s cannot both be set", prompt.ErrInvalid, name)
+	case t.Persona() && req.Message == "":
+		return fmt.Errorf("%w: persona %s requires a message", prompt.ErrInvalid, name)
+	}
+	return nil
+}
+
+// requestPrompt 返回请求的系统提示和用户消息：未指定模板时为 req.Message；
+// 否则按变量渲染模板，模板中的 rag 函数以当前用户的身份检索知识库。
+// 分配到实验变体时以变体的模板和系统提示替代请求中的
+func (a *Agent) requestPrompt(ctx context.Context, req *ChatRequest, assign experiment.Assignment) (system, message string, err error) {
+	name := req.Template
+	if assign.Template != "" {
+		name = assign.Template
+	}
+	if name != "" {
+		if err := a.checkPrompt(name, req); err != nil {
+			return "", "", err
+		}
+		r, err := a.prompts.Render(ctx, workflowRunner{a}, name, req.Variables)
+		if err != nil {
+			return "", "", err
+		}
+		system, message = r.System, r.Prompt
+	}
+	if message == "" {
+		message = req.Message
+	}
+	if assign.System != "" {
+		system = assign.System
+	}
+	return system, message, nil
+}
+
+// ListPrompts 返回已加载的提示模板和模板库的加载状态（未配置时为 nil）
+func (a *Agent) ListPrompts() ([]*prompt.Template, *prompt.Status) {
+	return a.prompts.List(), a.prompts.Status()
+}
+
+// GetPrompt 按名称返回提示模板
+func (a *Agent) GetPrompt(name string) (*prompt.Template, error) {
+	return a.prompts.Get(name)
+}
+
+// RenderPrompt 按变量渲染提示模板，不调用模型，用于调试模板
+func (a *Agent) RenderPrompt(ctx context.Context, name string, vars map[string]string) (*prompt.Rendered, error) {
+	return a.prompts.Render(ctx, workflowRunner{a}, name, vars)
 }
 
-// conversationLoop 对话循环（处理工具调用）
-func (a *Agent) conversationLoop(ctx context.Context, conv *Conversation, tools []api.Tool, model string) (*ChatResponse, error) {
+// conversationLoop 对话循环（处理工具调用），checks 不为 nil 时最终回答需满足其中的约束
+func (a *Agent) conversationLoop(ctx context.Context, conv *Conversation, tools []api.Tool, model, system string, checks *answerChecks, onEvent EventHandler) (*ChatResponse, error) {
+	var personaName string
+	persona := personaFromContext(ctx)
+	if persona != nil {
+		personaName = persona.Name
+		model = cmp.Or(model, persona.Model)
+	}
+	route := routeFromContext(ctx)
+	// 请求、人设和路由都没有指定模型时，启用升级策略则先由小模型回答
+	var path *EscalationPath
+	if model == "" && route.Model == "" && a.escalation != nil {
+		model = a.escalation.SmallModel()
+		path = &EscalationPath{Path: escalation.PathSmall}
+	}
 	if model == "" {
-		model = a.cfg.Ollama.Model
+		model = cmp.Or(route.Model, a.DefaultModel())
 	}
 
 	maxIterations := 100 // 防止无限循环
 	var toolCalls []ToolCallInfo
+	var reasoning []string
+	var metrics ResponseMetrics
+	user := UserFromContext(ctx)
+	// 基础系统提示、人设和模板的系统提示、工具说明、用户资料和回答语言要求作为系统消息放在最前面，不写入对话历史
+	lang := cmp.Or(languageFromContext(ctx), a.cfg.Ollama.Language)
+	if persona != nil && persona.SystemPrompt != "" {
+		system = strings.TrimSpace(persona.SystemPrompt + "\n\n" + system)
+	}
+	system = strings.TrimSpace(a.baseSystemPrompt(lang) + "\n\n" + system)
+	if guide := a.toolGuide(tools); guide != "" {
+		system = strings.TrimSpace(system + "\n\n" + guide)
+	}
+	if p := conv.Profile(); p != "" {
+		system = strings.TrimSpace(system + "\n\n" + p)
+	}
+	system += "\n\n" + locale.Instruction(lang)
+	// 没有可用工具时每轮都直接约束输出格式；有工具时约束会妨碍工具调用，只在修复最终回答时使用
+	if checks != nil && checks.schema != nil && len(tools) == 0 {
+		ctx = ollama.WithFormat(ctx, checks.schema.raw)
+	}
 
-	for range maxIterations {
+	for i := range maxIterations {
 		// 获取对话消息
 		messages := conv.GetMessages()
+		messages = append([]api.Message{{Role: "system", Content: system}}, messages...)
 
 		// 仅在第一轮时注入系统提示和工具列表
 		// var requestTools []api.Tool
@@ -180,33 +762,105 @@ func (a *Agent) conversationLoop(ctx context.Context, conv *Conversation, tools
 		// 	// klog.V(2).InfoS("First turn: injecting system prompt and tools", "tools", tools)
 		// }
 
+		// token 配额用尽时不再调用模型
+		if err := a.quota.Check(ctx, user, quota.MetricTokens); err != nil {
+			return nil, err
+		}
+
 		// 调用 Ollama
-		resp, err := a.ollama.Chat(ctx, messages, tools)
+		resp, err := a.chat(ctx, model, messages, tools)
 		if err != nil {
 			return nil, fmt.Errorf("ollama chat failed: %w", err)
 		}
+		a.quota.Add(ctx, user, quota.Usage{Tokens: int64(resp.PromptEvalCount + resp.EvalCount)})
+		metrics.add(resp.Metrics, resp.DoneReason)
+
+		// 过滤模型输出，被拦截时以提示替换
+		content, err := a.filters.Apply(filter.StageOutput, resp.Message.Content)
+		if err != nil {
+			content = err.Error()
+		}
+		resp.Message.Content = content
+
+		// 思考过程与回答分开返回，默认不写入对话历史以节省上下文
+		if thinking := resp.Message.Thinking; thinking != "" {
+			if !a.cfg.Reasoning.Hide {
+				if thinking, err = a.filters.Apply(filter.StageOutput, thinking); err != nil {
+					thinking = err.Error()
+				}
+				reasoning = append(reasoning, thinking)
+				onEvent.emit(Event{Type: EventReasoning, Content: thinking})
+			}
+			if !a.cfg.Reasoning.KeepInHistory {
+				resp.Message.Thinking = ""
+			}
+		}
+
+		// 小模型的最终回答未通过检查时丢弃，由大模型基于相同的上下文重新回答
+		if path != nil && path.Path == escalation.PathSmall && len(resp.Message.ToolCalls) == 0 {
+			answer := escalation.Answer{Content: content, Truncated: resp.DoneReason == modelstats.DoneReasonLength}
+			if i := lastUserMessage(messages); i >= 0 {
+				answer.Question = messages[i].Content
+			}
+			if escalate, reason := a.escalation.ShouldEscalate(ctx, answer); escalate {
+				klog.InfoS("Escalating to large model", "conversationID", conv.ID, "from", model, "to", a.escalation.LargeModel(), "reason", reason)
+				onEvent.emit(Event{Type: EventEscalation, Content: reason})
+				model = a.escalation.LargeModel()
+				path = &EscalationPath{Path: escalation.PathLarge, Reason: reason}
+				continue
+			}
+		}
+
+		// 校验最终回答，历史中只保存重新回答后的内容
+		var data json.RawMessage
+		var problems []string
+		if checks != nil && len(resp.Message.ToolCalls) == 0 {
+			if data, resp.Message.Content, problems, err = a.finalAnswer(ctx, model, messages, content, checks); err != nil {
+				return nil, err
+			}
+		}
 
 		// 添加助手消息到历史
 		conv.AddMessage(resp.Message)
 
 		// 如果没有工具调用，返回结果
 		if len(resp.Message.ToolCalls) == 0 {
+			onEvent.emit(Event{Type: EventMessage, Content: resp.Message.Content})
 			return &ChatResponse{
-				Response:       resp.Message.Content,
-				ToolCalls:      toolCalls,
-				ConversationID: conv.ID,
+				Response:         resp.Message.Content,
+				Reasoning:        strings.Join(reasoning, "\n\n"),
+				Data:             data,
+				ValidationErrors: problems,
+				ToolCalls:        toolCalls,
+				ConversationID:   conv.ID,
+				Iterations:       i + 1,
+				Persona:          personaName,
+				Language:         lang,
+				Route:            route.Category,
+				Metrics:          &metrics,
+				Model:            model,
+				Escalation:       path,
 			}, nil
 		}
 
 		// 处理工具调用
 		klog.V(2).InfoS("Processing tool calls", "count", len(resp.Message.ToolCalls))
 		for _, tc := range resp.Message.ToolCalls {
-			result, err := a.executeToolCall(ctx, tc)
+			onEvent.emit(Event{Type: EventToolCall, Tool: tc.Function.Name, Arguments: tc.Function.Arguments})
+
+			toolCtx, toolImages := withToolImages(ctx)
+			result, err := a.executeToolCall(toolCtx, conv.ID, tc)
 			if err != nil {
 				klog.ErrorS(err, "Tool call failed", "tool", tc.Function.Name)
 				result = fmt.Sprintf("Error: %v", err)
 			}
 
+			ev := Event{Type: EventToolResult, Tool: tc.Function.Name, Result: result}
+			if err != nil {
+				ev.Error = err.Error()
+			}
+			onEvent.emit(ev)
+
 			// 记录工具调用
 			toolCalls = append(toolCalls, ToolCallInfo{
 				Tool:      tc.Function.Name,
@@ -214,11 +868,23 @@ func (a *Agent) conversationLoop(ctx context.Context, conv *Conversation, tools
 				Result:    result,
 			})
 
-			// 添加工具结果到历史
+			// 过大的结果转存到文件，历史中只保留预览（read_more 的结果本身不超过阈值）
+			if tc.Function.Name != readMoreTool {
+				result = a.spill.Spill(conv.ID, tc.Function.Name, result)
+			}
+
+			// 添加工具结果到历史，经过注入防护包装后才进入模型上下文
+			// 工具返回的图片（如截图）随工具结果一起提供给模型
 			conv.AddMessage(api.Message{
 				Role:    "tool",
-				Content: result,
+				Content: a.guard.Wrap(ctx, tc.Function.Name, result),
+				Images:  toolImages.list(),
 			})
+
+			// 客户端断开或请求被停止时不再执行剩余的工具调用，也不再调用模型
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -226,46 +892,411 @@ func (a *Agent) conversationLoop(ctx context.Context, conv *Conversation, tools
 }
 
 // executeToolCall 执行工具调用
-func (a *Agent) executeToolCall(ctx context.Context, tc api.ToolCall) (string, error) {
-	toolName := tc.Function.Name
+func (a *Agent) executeToolCall(ctx context.Context, conversationID string, tc api.ToolCall) (string, error) {
+	return a.callTool(ctx, conversationID, tc.Function.Name, tc.Function.Arguments)
+}
+
+// CallTool 直接调用指定工具（不经过模型），用于调试工具集成
+func (a *Agent) CallTool(ctx context.Context, toolName string, args map[string]any) (string, error) {
+	return a.callTool(ctx, "", toolName, args)
+}
 
-	// 检查工具是否存在
+// callTool 经过权限策略检查后执行工具，并记录审计日志
+func (a *Agent) callTool(ctx context.Context, conversationID, toolName string, args map[string]any) (result string, err error) {
+	// 检查工具是否存在，租户不可用的工具视为不存在
 	tool := a.toolRegistry.Get(toolName)
+	defer func() {
+		rec := audit.Record{Type: audit.TypeToolCall, ConversationID: conversationID, Tool: toolName}
+		if tool != nil {
+			rec.Source = tool.Source
+		}
+		rec.SetArgs(args)
+		rec.SetResult(result)
+		a.auditLog(ctx, rec, err)
+	}()
+
 	if tool == nil {
 		return "", fmt.Errorf("tool not found: %s", toolName)
 	}
+	tenant, err := a.TenantFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !toolAllowedFor(ctx, tenant, tool) {
+		return "", fmt.Errorf("tool not found: %s", toolName)
+	}
+
+	// 检查权限策略
+	err = a.policy.Check(policy.Request{
+		Tool:           toolName,
+		Source:         tool.Source,
+		User:           UserFromContext(ctx),
+		ConversationID: conversationID,
+		Args:           args,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 命中工具结果缓存时不再执行工具，也不计入工具调用配额；只有只读工具使用缓存
+	readOnly := tool.Capabilities.ReadOnly
+	if cached, ok := a.toolCache.Get(ctx, conversationID, toolName, readOnly, args); ok {
+		klog.V(2).InfoS("Tool result cache hit", "tool", toolName, "conversationID", conversationID)
+		return a.filters.Apply(filter.StageToolResult, cached)
+	}
 
-	// 执行工具
-	return tool.Executor.Execute(ctx, tc.Function.Arguments)
+	// 检查并计入工具调用配额
+	if err := a.quota.Charge(ctx, UserFromContext(ctx), quota.Usage{ToolCalls: 1}, quota.MetricToolCalls); err != nil {
+		return "", err
+	}
+
+	// 执行工具并过滤结果
+	start := time.Now()
+	result, err = tool.Executor.Execute(withConversationID(ctx, conversationID), args)
+	a.toolStats.Record(toolName, time.Since(start), err != nil)
+	if !readOnly {
+		// 非只读工具可能修改了缓存结果依赖的状态（失败时也可能已部分修改），清除对话中的缓存
+		a.toolCache.Invalidate(conversationID)
+	}
+	if err != nil {
+		return "", err
+	}
+	a.toolCache.Put(ctx, conversationID, toolName, readOnly, args, result)
+	return a.filters.Apply(filter.StageToolResult, result)
+}
+
+// auditRAGWrite 记录知识库写入，content 只记录摘要
+func (a *Agent) auditRAGWrite(ctx context.Context, collection string, args map[string]any, content string, err error) {
+	if a.audit == nil {
+		return
+	}
+	rec := audit.Record{Type: audit.TypeRAGWrite, Target: collection}
+	rec.SetArgs(args)
+	rec.SetResult(content)
+	a.auditLog(ctx, rec, err)
 }
 
-// getAllOllamaTools 获取所有工具的 Ollama Tool 定义
-func (a *Agent) getAllOllamaTools() []api.Tool {
+// auditLog 补全用户、租户和结果后写入审计日志
+func (a *Agent) auditLog(ctx context.Context, rec audit.Record, err error) {
+	if a.audit == nil {
+		return
+	}
+
+	rec.User = UserFromContext(ctx)
+	if tenant, _ := a.TenantFor(ctx); tenant != nil {
+		rec.Tenant = tenant.Name
+	}
+	switch {
+	case err == nil:
+		rec.Outcome = audit.OutcomeOK
+	case errors.Is(err, policy.ErrDenied), errors.Is(err, ErrTenantForbidden), errors.Is(err, ErrNoTenant),
+		errors.Is(err, quota.ErrExceeded), errors.Is(err, filter.ErrBlocked), errors.Is(err, egress.ErrDenied):
+		rec.Outcome = audit.OutcomeDenied
+		rec.Error = err.Error()
+	default:
+		rec.Outcome = audit.OutcomeError
+		rec.Error = err.Error()
+	}
+	a.audit.Log(rec)
+}
+
+// chat 调用模型，使用 context 中人设的模型参数和输出格式；启用缓存时相同的模型、参数、格式、消息和工具直接返回缓存的响应
+func (a *Agent) chat(ctx context.Context, model string, messages []api.Message, tools []api.Tool) (*api.ChatResponse, error) {
+	options := withStopOption(modelOptions(personaFromContext(ctx)), stopFromContext(ctx))
+	options, messages, tools, err := a.fitModel(ctx, model, options, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	ctx = ollama.WithModelOptions(ctx, options)
+	if a.cache == nil {
+		return a.modelChat(ctx, model, messages, tools)
+	}
+
+	key, err := cache.Key(model, options, ollama.FormatFromContext(ctx), messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	if resp, ok := a.cache.Get(ctx, key); ok {
+		klog.V(2).InfoS("Response cache hit", "model", model, "messages", len(messages))
+		return resp, nil
+	}
+
+	resp, err := a.modelChat(ctx, model, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	a.cache.Put(ctx, key, resp)
+	return resp, nil
+}
+
+// modelChat 调用 Ollama 并记录模型调用统计
+func (a *Agent) modelChat(ctx context.Context, model string, messages []api.Message, tools []api.Tool) (*api.ChatResponse, error) {
+	resp, err := a.ollama.Chat(ctx, model, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	a.recordModelCall(model, resp.Metrics, resp.DoneReason)
+	return resp, nil
+}
+
+// OllamaHosts 返回各 Ollama 主机的健康状态和进行中的请求数
+func (a *Agent) OllamaHosts() []ollama.HostStatus {
+	return a.ollama.Hosts()
+}
+
+// CacheStats 返回响应缓存的命中统计，未启用缓存时返回 false
+func (a *Agent) CacheStats() (cache.Stats, bool) {
+	return a.cache.Stats(), a.cache != nil
+}
+
+// ToolCacheStats 返回工具结果缓存的命中统计，未配置缓存的工具时返回 false
+func (a *Agent) ToolCacheStats() (cache.Stats, bool) {
+	return a.toolCache.Stats(), a.toolCache != nil
+}
+
+// UploadImage 保存 context 中用户上传的图片，之后聊天请求可以通过 image_ids 引用
+func (a *Agent) UploadImage(ctx context.Context, data []byte) (*images.Upload, error) {
+	return a.images.Put(ctx, UserFromContext(ctx), data)
+}
+
+// MaxImageBytes 单张图片的最大字节数
+func (a *Agent) MaxImageBytes() int {
+	return a.images.MaxBytes()
+}
+
+// ToolStats 返回 context 中用户可用工具的调用统计（按名称排序）和开始统计的时间，
+// 只统计实际执行的调用，被拒绝或命中工具结果缓存的调用不计入
+func (a *Agent) ToolStats(ctx context.Context) ([]toolstats.Stats, time.Time) {
+	tools := a.tenantTools(ctx)
+	stats := make([]toolstats.Stats, 0, len(tools))
+	for _, tool := range tools {
+		stats = append(stats, a.toolStats.Get(tool.Name, tool.Source))
+	}
+	slices.SortFunc(stats, func(x, y toolstats.Stats) int {
+		return strings.Compare(x.Tool, y.Tool)
+	})
+	return stats, a.toolStats.Since()
+}
+
+// WorkerStats 返回聊天 worker 池的状态，未限制并发时第二个返回值为 false
+func (a *Agent) WorkerStats() (workerpool.Stats, bool) {
+	return a.workers.Stats(), a.workers != nil
+}
+
+// runLoop 在 worker 池中执行对话循环，worker 全忙且队列已满时返回 *workerpool.SaturatedError
+func (a *Agent) runLoop(ctx context.Context, conv *Conversation, tools []api.Tool, system string, checks *answerChecks, req *ChatRequest) (resp *ChatResponse, err error) {
+	err = a.workers.Do(ctx, func(ctx context.Context) error {
+		resp, err = a.conversationLoop(ctx, conv, tools, req.Model, system, checks, req.OnEvent)
+		return err
+	})
+	return resp, err
+}
+
+// chargeRequest 检查 context 中用户的请求和 token 配额，未超出时计入一次请求
+func (a *Agent) chargeRequest(ctx context.Context) error {
+	return a.quota.Charge(ctx, UserFromContext(ctx), quota.Usage{Requests: 1}, quota.MetricRequests, quota.MetricTokens)
+}
+
+// refundRequest 退还 chargeRequest 计入的一次请求，用于计入之后无法开始处理的请求（如对话忙）
+func (a *Agent) refundRequest(ctx context.Context) {
+	a.quota.Refund(ctx, UserFromContext(ctx), quota.Usage{Requests: 1})
+}
+
+// Usage 返回 context 中用户当前周期的用量和限额，未启用配额时返回 quota.ErrDisabled
+func (a *Agent) Usage(ctx context.Context) (*quota.Report, error) {
+	return a.quota.Report(ctx, UserFromContext(ctx))
+}
+
+// routeRequest 请求和人设都没有指定模型时，按任务类型选择模型并写入 context
+func (a *Agent) routeRequest(ctx context.Context, model string, persona *config.PersonaConfig, message string) context.Context {
+	if a.router == nil || model != "" || (persona != nil && persona.Model != "") {
+		return ctx
+	}
+	category, routed := a.router.Route(ctx, message)
+	klog.V(2).InfoS("Request routed", "category", category, "model", routed)
+	return withRoute(ctx, route{Category: category, Model: routed})
+}
+
+// routeChat 模型路由分类使用的单次补全
+func (a *Agent) routeChat(ctx context.Context, prompt string) (string, error) {
+	return a.complete(ctx, a.cfg.Routing.Model, prompt)
+}
+
+// escalationChat 检查小模型回答时使用的单次补全，未配置检查模型时使用小模型
+func (a *Agent) escalationChat(ctx context.Context, prompt string) (string, error) {
+	return a.complete(ctx, cmp.Or(a.cfg.Escalation.Model, a.cfg.Escalation.SmallModel), prompt)
+}
+
+// classifyChat 提示注入检测使用的单次补全
+func (a *Agent) classifyChat(ctx context.Context, prompt string) (string, error) {
+	return a.complete(ctx, a.cfg.Guard.Model, prompt)
+}
+
+// getAllOllamaTools 获取 context 中用户可用工具的 Ollama Tool 定义
+func (a *Agent) getAllOllamaTools(ctx context.Context) []api.Tool {
 	var tools []api.Tool
 
-	for _, tool := range a.toolRegistry.List() {
-		ollamaTool := MCPToolToOllamaTool(tool.MCPTool)
-		tools = append(tools, ollamaTool)
+	for _, tool := range a.tenantTools(ctx) {
+		tools = append(tools, a.describeTool(tool))
 	}
 	klog.InfoS("All tools", "tools", tools)
 
 	return tools
 }
 
-// getOrCreateConversation 获取或创建对话
-func (a *Agent) getOrCreateConversation(id string) *Conversation {
+// getOrCreateConversation 获取或创建对话，新对话归属于 context 中的用户
+func (a *Agent) getOrCreateConversation(ctx context.Context, id string) (*Conversation, error) {
 	if id == "" {
 		id = generateConversationID()
 	}
+	user := UserFromContext(ctx)
 
 	val, ok := a.conversations.Load(id)
 	if ok {
-		return val.(*Conversation)
+		conv := val.(*Conversation)
+		return conv, checkOwner(conv.User, user)
 	}
 
+	// 尝试从持久化存储恢复
 	conv := NewConversation(id)
-	a.conversations.Store(id, conv)
-	return conv
+	conv.User = user
+	if a.store != nil {
+		rec, err := a.store.Load(id)
+		switch {
+		case err == nil:
+			conv = conversationFromRecord(rec)
+		case !errors.Is(err, store.ErrNotFound):
+			klog.ErrorS(err, "Failed to load conversation", "conversationID", id)
+		}
+	}
+
+	val, _ = a.conversations.LoadOrStore(id, conv)
+	conv = val.(*Conversation)
+	return conv, checkOwner(conv.User, user)
+}
+
+// beginTurn 获取对话并开始一轮处理，同一对话的请求依次执行，避免多轮循环的消息交错。
+// 对话忙时按 conversation.concurrency 排队等待或直接返回 ErrConversationBusy，noWait 为 true 时总是直接返回；
+// 返回的 context 可以通过 StopConversation 取消，本轮处理必须使用它。
+// 返回的 end 传入处理结果的错误，保存对话并结束本轮，调用方必须在处理完成后调用：
+// 本轮被停止或取消时向对话追加 CancelledMarker，被停止时返回 ErrTurnStopped
+func (a *Agent) beginTurn(ctx context.Context, id string, noWait bool) (context.Context, *Conversation, func(error) error, error) {
+	conv, err := a.getOrCreateConversation(ctx, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var wait time.Duration
+	if a.cfg.Conversation.Concurrency != "reject" && !noWait {
+		wait = a.cfg.Conversation.QueueTimeout
+	}
+	if err := conv.acquire(ctx, wait); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 共享存储下其他副本可能已追加消息，以存储中的最新记录为准
+	if store.IsShared(a.store) {
+		if rec, err := a.store.Load(conv.ID); err == nil {
+			conv.refresh(rec)
+		}
+	}
+
+	turnCtx, stop := context.WithCancelCause(ctx)
+	conv.setStop(stop)
+
+	// 先保存再释放，排队的下一轮从存储刷新时能看到本轮的消息
+	return turnCtx, conv, func(err error) error {
+		conv.setStop(nil)
+		if err != nil && errors.Is(turnCtx.Err(), context.Canceled) {
+			conv.AddMessage(api.Message{Role: "assistant", Content: CancelledMarker})
+			if cause := context.Cause(turnCtx); errors.Is(cause, ErrTurnStopped) {
+				klog.InfoS("Conversation turn stopped", "conversationID", conv.ID)
+				err = fmt.Errorf("%w: %s", cause, conv.ID)
+			}
+		}
+		stop(nil)
+		a.saveConversation(conv)
+		conv.release()
+		return err
+	}, nil
+}
+
+// StopConversation 停止对话进行中的请求：取消正在进行的模型调用和工具调用，并在对话中追加 CancelledMarker。
+// 对话不存在时返回 store.ErrNotFound，属于其他用户时返回 ErrConversationForbidden，
+// 没有进行中的请求时返回 ErrConversationIdle。只能停止在本副本上执行的请求
+func (a *Agent) StopConversation(ctx context.Context, id string) error {
+	val, ok := a.conversations.Load(id)
+	if !ok {
+		if _, err := a.GetConversation(ctx, id); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s", ErrConversationIdle, id)
+	}
+	conv := val.(*Conversation)
+	if err := checkOwner(conv.User, UserFromContext(ctx)); err != nil {
+		return err
+	}
+	return conv.Stop()
+}
+
+// saveConversation 持久化对话（memory 模式下忽略）
+func (a *Agent) saveConversation(conv *Conversation) {
+	if a.store == nil {
+		return
+	}
+	if err := a.store.Save(conv.Record()); err != nil {
+		klog.ErrorS(err, "Failed to save conversation", "conversationID", conv.ID)
+	}
+}
+
+// ListConversations 列出对话摘要，context 中有用户时只返回该用户的对话
+func (a *Agent) ListConversations(ctx context.Context) ([]store.Summary, error) {
+	var summaries []store.Summary
+	if a.store != nil {
+		var err error
+		if summaries, err = a.store.List(); err != nil {
+			return nil, err
+		}
+	} else {
+		a.conversations.Range(func(_, val any) bool {
+			summaries = append(summaries, store.Summarize(val.(*Conversation).Record()))
+			return true
+		})
+		sort.Slice(summaries, func(i, j int) bool {
+			return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt)
+		})
+	}
+
+	if user := UserFromContext(ctx); user != "" {
+		summaries = slices.DeleteFunc(summaries, func(s store.Summary) bool { return s.User != user })
+	}
+	return summaries, nil
+}
+
+// GetConversation 获取对话记录，不存在时返回 store.ErrNotFound，属于其他用户时返回 ErrConversationForbidden
+func (a *Agent) GetConversation(ctx context.Context, id string) (*store.Record, error) {
+	rec, err := a.loadConversationRecord(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOwner(rec.User, UserFromContext(ctx)); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// loadConversationRecord 读取对话记录，共享存储下以存储为准
+func (a *Agent) loadConversationRecord(id string) (*store.Record, error) {
+	if store.IsShared(a.store) {
+		return a.store.Load(id)
+	}
+	if val, ok := a.conversations.Load(id); ok {
+		return val.(*Conversation).Record(), nil
+	}
+	if a.store != nil {
+		return a.store.Load(id)
+	}
+	return nil, store.ErrNotFound
 }
 
 func generateConversationID() string {
@@ -274,16 +1305,71 @@ func generateConversationID() string {
 
 // ChatRequest 聊天请求
 type ChatRequest struct {
-	Message        string `json:"message"`
-	ConversationID string `json:"conversation_id,omitempty"`
-	Model          string `json:"model,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Template 提示模板名，以 Variables 渲染：模板定义了用户消息时作为本次提问，不能再指定 Message；
+	// 模板定义的系统提示（人设）作为本次请求的系统消息
+	Template       string            `json:"template,omitempty"`
+	Variables      map[string]string `json:"variables,omitempty"`
+	ConversationID string            `json:"conversation_id,omitempty"`
+	Model          string            `json:"model,omitempty"`
+	// Collection RAG 聊天时检索的集合（为空时检索所有集合）
+	Collection string `json:"collection,omitempty"`
+	// References RAG 聊天时要求模型用 [编号] 标注引用，并在回答末尾附加编号的参考资料列表（rag.references 对所有请求开启）
+	References bool `json:"references,omitempty"`
+	// NoWait 对话正在处理其他请求时立即返回 ErrConversationBusy，不排队等待
+	NoWait bool `json:"no_wait,omitempty"`
+	// Persona 切换对话的人设（personas 配置），之后同一对话的请求沿用，为空时使用对话当前的人设
+	Persona string `json:"persona,omitempty"`
+	// Language 回答语言（zh、en 等，可写作 zh-CN），同时决定内置系统提示的语言；
+	// 之后同一对话的请求沿用，为空时使用对话当前的语言或 ollama.language
+	Language string `json:"language,omitempty"`
+	// Schema 最终回答需符合的 JSON Schema，解析后的 JSON 在 ChatResponse.Data 中返回
+	Schema json.RawMessage `json:"schema,omitempty"`
+	// Validators 本次请求额外使用的回答校验规则（output_validation.validators 中的名称）
+	Validators []string `json:"validators,omitempty"`
+	// DenyCapabilities 本次请求不提供给模型的工具能力（read_only、destructive、network、long_running）
+	DenyCapabilities []string `json:"deny_capabilities,omitempty"`
+	// Images 随用户消息发送给视觉模型的图片，base64 编码，也可以是 data URL
+	Images []string `json:"images,omitempty"`
+	// ImageIDs 随用户消息发送的已上传图片（POST /api/images 返回的 id），排在 Images 之后
+	ImageIDs []string `json:"image_ids,omitempty"`
+	// Stop 停止序列，模型输出其中任一序列时停止生成（回答不包含该序列），只对本次请求生效
+	Stop []string `json:"stop,omitempty"`
+
+	// OnEvent 可选的事件回调，用于实时获取工具调用进度（不参与序列化）
+	OnEvent EventHandler `json:"-"`
 }
 
 // ChatResponse 聊天响应
 type ChatResponse struct {
-	Response       string         `json:"response"`
-	ToolCalls      []ToolCallInfo `json:"tool_calls,omitempty"`
-	ConversationID string         `json:"conversation_id"`
+	Response  string          `json:"response"`
+	Reasoning string          `json:"reasoning,omitempty"` // 模型的思考过程（多轮工具调用时依次拼接）
+	Data      json.RawMessage `json:"data,omitempty"`      // 请求指定 Schema 时解析后的回答
+	// ValidationErrors 重试后仍未通过的校验规则，仅在 output_validation.on_failure 为 accept 时出现
+	ValidationErrors []string       `json:"validation_errors,omitempty"`
+	ToolCalls        []ToolCallInfo `json:"tool_calls,omitempty"`
+	ConversationID   string         `json:"conversation_id"`
+	Iterations       int            `json:"iterations,omitempty"` // 模型调用轮数
+	Persona          string         `json:"persona,omitempty"`    // 本轮使用的人设
+	Language         string         `json:"language,omitempty"`   // 本轮的回答语言
+	Route            string         `json:"route,omitempty"`      // 模型路由判断的任务类型，未启用路由或请求、人设指定了模型时为空
+	// Metrics Ollama 返回的 token 数、耗时和结束原因，Metrics.Truncated 表示回答因长度限制被截断
+	Metrics *ResponseMetrics `json:"metrics,omitempty"`
+	// Model 给出最终回答的模型
+	Model string `json:"model,omitempty"`
+	// Escalation 小模型优先时回答的来源，未使用升级策略时为空
+	Escalation *EscalationPath `json:"escalation,omitempty"`
+	// Experiment、Variant 请求参与的提示实验及分配到的变体
+	Experiment string `json:"experiment,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+	// Citations RAG 聊天时提供给模型的参考资料，按上下文中的编号排列
+	Citations []Citation `json:"citations,omitempty"`
+}
+
+// EscalationPath 小模型优先时回答的来源
+type EscalationPath struct {
+	Path   string `json:"path"`             // small（小模型直接回答）或 large（升级到大模型）
+	Reason string `json:"reason,omitempty"` // 升级的原因
 }
 
 // ToolCallInfo 工具调用信息
@@ -294,44 +1380,137 @@ type ToolCallInfo struct {
 }
 
 // AddRAGDocument 添加 RAG 文档
-func (a *Agent) AddRAGDocument(ctx context.Context, id, content string, metadata map[string]string) error {
-	return a.rag.AddDocument(ctx, id, content, metadata)
+func (a *Agent) AddRAGDocument(ctx context.Context, collection, id, content string, metadata map[string]string) (err error) {
+	defer func() { a.auditRAGWrite(ctx, collection, map[string]any{"id": id}, content, err) }()
+
+	collection, err = a.tenantCollection(ctx, collection, false)
+	if err != nil {
+		return err
+	}
+	if err := a.rag.AddDocument(ctx, collection, id, content, metadata); err != nil {
+		return err
+	}
+	a.extractDocument(collection, id, content)
+	return a.saveRAG()
 }
 
 // AddRAGDocumentChunks 添加已分块的 RAG 文档
-func (a *Agent) AddRAGDocumentChunks(ctx context.Context, id string, chunks []string, metadata map[string]string) error {
-	return a.rag.AddDocumentWithChunks(ctx, id, chunks, metadata)
+func (a *Agent) AddRAGDocumentChunks(ctx context.Context, collection, id string, chunks []string, metadata map[string]string) (err error) {
+	defer func() {
+		a.auditRAGWrite(ctx, collection, map[string]any{"id": id, "chunks": len(chunks)}, strings.Join(chunks, "\n"), err)
+	}()
+
+	collection, err = a.tenantCollection(ctx, collection, false)
+	if err != nil {
+		return err
+	}
+	if err := a.rag.AddDocumentWithChunks(ctx, collection, id, chunks, metadata); err != nil {
+		return err
+	}
+	a.extractDocument(collection, id, strings.Join(chunks, "\n"))
+	return a.saveRAG()
 }
 
 // ChatWithRAG 带 RAG 增强的聊天
-func (a *Agent) ChatWithRAG(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
-	// 获取 RAG 上下文（使用配置中的 TopK）
-	ragContext, err := a.rag.GetContext(ctx, req.Message, a.cfg.RAG.TopK)
+func (a *Agent) ChatWithRAG(ctx context.Context, req *ChatRequest) (resp *ChatResponse, err error) {
+	collection, err := a.tenantCollection(ctx, req.Collection, true)
+	if err != nil {
+		return nil, err
+	}
+
+	selected, err := a.persona(req.Persona)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := a.answerChecks(req)
+	if err != nil {
+		return nil, err
+	}
+	lang, err := locale.Normalize(req.Language)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCapabilities(req.DenyCapabilities); err != nil {
+		return nil, err
+	}
+	if err := checkStop(req.Stop); err != nil {
+		return nil, err
+	}
+	attached, err := a.images.Resolve(ctx, UserFromContext(ctx), req.Images, req.ImageIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 分配实验变体，渲染提示模板并过滤用户消息
+	id, assign := a.assignExperiment(req)
+	system, message, err := a.requestPrompt(ctx, req, assign)
+	if err != nil {
+		return nil, err
+	}
+	if message, err = a.filters.Apply(filter.StageInput, message); err != nil {
+		return nil, err
+	}
+
+	// 检查并计入请求配额，无效或被拦截的请求不计入
+	if err := a.chargeRequest(ctx); err != nil {
+		return nil, err
+	}
+
+	// 获取 RAG 上下文，启用知识图谱时补充问题中实体的关系
+	ragContext, results, err := a.ragContext(ctx, collection, message)
 	if err != nil {
 		klog.ErrorS(err, "Failed to get RAG context")
 		// 即使 RAG 失败，也继续处理（降级到普通聊天）
 	}
 
-	// 获取或创建对话
-	conv := a.getOrCreateConversation(req.ConversationID)
-
-	// 如果有 RAG 上下文，添加到消息中
-	enhancedMessage := req.Message
+	// 获取或创建对话，对话忙时退还请求配额
+	turnCtx, conv, endTurn, err := a.beginTurn(ctx, id, req.NoWait)
+	if err != nil {
+		a.refundRequest(ctx)
+		return nil, err
+	}
+	ctx = turnCtx
+	defer func() { err = endTurn(err) }()
+	defer func() { a.recordExperiment(conv, assign, resp, err) }()
+	persona := a.conversationPersona(conv, selected)
+	ctx = withPersona(ctx, persona)
+	checks = a.personaChecks(checks, persona)
+	ctx = withLanguage(ctx, a.conversationLanguage(conv, lang))
+	ctx = withDeniedCapabilities(ctx, req.DenyCapabilities)
+	ctx = withStop(ctx, req.Stop)
+	ctx = a.routeRequest(ctx, req.Model, persona, message)
+	a.snapshotProfile(ctx, conv)
+
+	// 如果有 RAG 上下文，添加到消息中；结构化输出的回答不附加参考资料列表
+	references := (req.References || a.cfg.RAG.References) && len(req.Schema) == 0 && len(results) > 0
+	enhancedMessage := message
 	if ragContext != "" {
-		enhancedMessage = ragContext + "\n用户问题：" + req.Message
+		if references {
+			ragContext += citationInstruction
+		}
+		enhancedMessage = ragContext + "\n用户问题：" + message
 	}
 
 	// 添加增强后的用户消息
 	conv.AddMessage(api.Message{
 		Role:    "user",
 		Content: enhancedMessage,
+		Images:  attached,
 	})
 
 	// 获取所有可用工具
-	tools := a.getAllOllamaTools()
+	tools := a.getAllOllamaTools(ctx)
 
 	// 开始对话循环
-	return a.conversationLoop(ctx, conv, tools, req.Model)
+	resp, err = a.runLoop(ctx, conv, tools, system, checks, req)
+	if err == nil {
+		a.learnFromTurn(ctx, conv.ID, message, resp)
+		resp.Citations = citations(results)
+		if references {
+			resp.Response += formatReferences(resp.Citations, cmp.Or(resp.Language, a.cfg.Ollama.Language))
+		}
+	}
+	return resp, err
 }
 
 // RAGDocumentCount 返回 RAG 文档数量
@@ -339,13 +1518,41 @@ func (a *Agent) RAGDocumentCount() int {
 	return a.rag.DocumentCount()
 }
 
-// SearchRAG 搜索 RAG 文档
-func (a *Agent) SearchRAG(ctx context.Context, query string) ([]rag.SearchResult, error) {
-	return a.rag.Search(ctx, query, a.cfg.RAG.TopK)
+// RAGCollections 返回各集合的分块数量
+func (a *Agent) RAGCollections() map[string]int {
+	return a.rag.Collections()
+}
+
+// SearchRAG 搜索 RAG 文档（topK <= 0 时使用配置中的 TopK）
+func (a *Agent) SearchRAG(ctx context.Context, collection, query string, topK int) ([]rag.SearchResult, error) {
+	if topK <= 0 {
+		topK = a.cfg.RAG.TopK
+	}
+	collection, err := a.tenantCollection(ctx, collection, true)
+	if err != nil {
+		return nil, err
+	}
+	return a.rag.Search(ctx, collection, query, topK)
+}
+
+// IngestRAG 将文件、目录或 URL 导入指定集合，返回导入的文档数；租户用户只能导入 URL
+func (a *Agent) IngestRAG(ctx context.Context, collection, target string) (int, error) {
+	return a.ingestRAG(ctx, collection, target, nil)
 }
 
 // LoadRAGDocumentsFromDir 从目录加载所有 md 文件作为 RAG 文档
-func (a *Agent) LoadRAGDocumentsFromDir(ctx context.Context, dir string) error {
+func (a *Agent) LoadRAGDocumentsFromDir(ctx context.Context, dir string) (err error) {
+	defer func() { a.auditRAGWrite(ctx, rag.DefaultCollection, map[string]any{"source": dir}, "", err) }()
+
+	tenant, err := a.TenantFor(ctx)
+	if err != nil {
+		return err
+	}
+	if tenant != nil {
+		return ErrTenantForbidden
+	}
+	ctx = priority.WithClass(ctx, priority.Background)
+
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return fmt.Errorf("failed to read directory %s: %w", dir, err)
@@ -372,7 +1579,7 @@ func (a *Agent) LoadRAGDocumentsFromDir(ctx context.Context, dir string) error {
 		// 使用文件名（不含扩展名）作为文档 ID
 		docID := entry.Name()[:len(entry.Name())-3]
 
-		err = a.rag.AddDocument(ctx, docID, string(content), map[string]string{
+		err = a.rag.AddDocument(ctx, rag.DefaultCollection, docID, string(content), map[string]string{
 			"source": filePath,
 			"file":   entry.Name(),
 		})
@@ -380,9 +1587,18 @@ func (a *Agent) LoadRAGDocumentsFromDir(ctx context.Context, dir string) error {
 			klog.ErrorS(err, "Failed to add document", "file", filePath)
 			continue
 		}
+		a.extractDocument(rag.DefaultCollection, docID, string(content))
 		loadedCount++
 	}
 
 	klog.InfoS("RAG documents loaded", "dir", dir, "files", loadedCount, "totalChunks", a.rag.DocumentCount())
-	return nil
+	return a.saveRAG()
+}
+
+// saveRAG 持久化 RAG 文档（未配置 store_path 或使用共享后端时忽略）
+func (a *Agent) saveRAG() error {
+	if a.cfg.RAG.StorePath == "" || a.cfg.RAG.Backend != "memory" {
+		return nil
+	}
+	return a.rag.Save(a.cfg.RAG.StorePath)
 }
@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// chatHandler 模拟 Ollama 的 /api/chat，reply 根据请求生成回答
+type chatHandler func(req *api.ChatRequest) string
+
+// newTestAgent 创建连接到模拟 Ollama 服务器的 Agent，extra 为追加到配置文件的 YAML
+func newTestAgent(t *testing.T, reply chatHandler, extra string) *Agent {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/chat":
+			var req api.ChatRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			json.NewEncoder(w).Encode(api.ChatResponse{
+				Model:      req.Model,
+				Message:    api.Message{Role: "assistant", Content: reply(&req)},
+				Done:       true,
+				DoneReason: "stop",
+			})
+		case "/api/show":
+			w.Write([]byte(`{"capabilities": ["completion", "tools"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "ollama:\n  host: " + srv.URL + "\n  model: test-model\n" + extra
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Stop(t.Context()) })
+	return a
+}
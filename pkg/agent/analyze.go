@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/champly/ai-agent/pkg/analyzer"
+)
+
+// analysisTools 仓库分析依赖的文件系统工具（内置文件系统 MCP Server 提供）
+var analysisTools = []string{"file_inventory", "read_file"}
+
+// ErrAnalysisUnavailable 仓库分析依赖的工具未启用或当前用户不可用
+var ErrAnalysisUnavailable = errors.New("repository analysis is unavailable")
+
+// SubmitAnalysis 提交仓库分析任务：列出 req.Path 下的文件清单，按重要程度在 token 预算内
+// 读取并摘要文件，最后生成结构化报告。任务的排队、重试、取消和查询与聊天任务相同
+func (a *Agent) SubmitAnalysis(ctx context.Context, req *analyzer.Request, opts TaskOptions) (*Task, error) {
+	tenant, err := a.TenantFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range analysisTools {
+		tool := a.toolRegistry.Get(name)
+		if tool == nil || !toolAllowedFor(ctx, tenant, tool) {
+			return nil, fmt.Errorf("%w: tool %s is not available", ErrAnalysisUnavailable, name)
+		}
+	}
+
+	analysis := *req
+	analysis.TokenBudget = a.analyzer.Budget(req)
+	return a.submitTask(ctx, analyzeJobKind, &taskPayload{Analysis: &analysis}, nil, opts.RunAt)
+}
+
+// analyze 执行仓库分析，计入一次请求配额并占用一个 worker
+func (a *Agent) analyze(ctx context.Context, req *analyzer.Request, onEvent EventHandler) (*analyzer.Report, error) {
+	if err := a.chargeRequest(ctx); err != nil {
+		return nil, err
+	}
+
+	var report *analyzer.Report
+	err := a.workers.Do(ctx, func(ctx context.Context) error {
+		var err error
+		report, err = a.analyzer.Run(ctx, analysisRunner{workflowRunner{a}, onEvent}, req)
+		return err
+	})
+	return report, err
+}
+
+// analysisRunner 为仓库分析提供模型和工具调用，工具调用作为任务事件推送进度
+type analysisRunner struct {
+	workflowRunner
+	onEvent EventHandler
+}
+
+// CallTool 调用工具，文件内容较大，结果事件中不包含工具输出
+func (r analysisRunner) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	r.onEvent.emit(Event{Type: EventToolCall, Tool: name, Arguments: args})
+	result, err := r.workflowRunner.CallTool(ctx, name, args)
+	ev := Event{Type: EventToolResult, Tool: name}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	r.onEvent.emit(ev)
+	return result, err
+}
@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/filter"
+	"github.com/champly/ai-agent/pkg/ollama"
+	"github.com/champly/ai-agent/pkg/quota"
+	"github.com/champly/ai-agent/pkg/validator"
+)
+
+// ErrOutputValidation 重试后模型的回答仍未通过校验规则（output_validation.on_failure 为 error 时）
+var ErrOutputValidation = errors.New("answer failed output validation")
+
+// answerChecks 最终回答需满足的约束：请求的 JSON Schema 和回答校验规则，nil 表示没有约束
+type answerChecks struct {
+	schema     *outputSchema
+	validators []*validator.Validator
+}
+
+// answerChecks 解析请求的 JSON Schema，选出对所有请求生效的和请求指定的校验规则
+func (a *Agent) answerChecks(req *ChatRequest) (*answerChecks, error) {
+	schema, err := parseOutputSchema(req.Schema)
+	if err != nil {
+		return nil, err
+	}
+	validators, err := a.validators.Select(req.Validators)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil && len(validators) == 0 {
+		return nil, nil
+	}
+	return &answerChecks{schema: schema, validators: validators}, nil
+}
+
+// personaChecks 加上人设指定的校验规则
+func (a *Agent) personaChecks(c *answerChecks, persona *config.PersonaConfig) *answerChecks {
+	if persona == nil || len(persona.Validators) == 0 {
+		return c
+	}
+	// 配置加载时已确认人设引用的规则存在
+	extra, err := a.validators.Select(persona.Validators)
+	if err != nil {
+		klog.ErrorS(err, "Failed to select persona output validators", "persona", persona.Name)
+		return c
+	}
+	merged := &answerChecks{}
+	if c != nil {
+		merged.schema = c.schema
+		merged.validators = slices.Clone(c.validators)
+	}
+	for _, v := range extra {
+		if !slices.Contains(merged.validators, v) {
+			merged.validators = append(merged.validators, v)
+		}
+	}
+	return merged
+}
+
+// checkResult 回答的校验结果
+type checkResult struct {
+	data      json.RawMessage // 解析后的 JSON
+	answer    string          // 作为回答保存的内容
+	schemaErr error           // 不符合 JSON Schema 的原因
+	problems  []string        // 所有不满足的要求
+}
+
+// check 校验回答
+func (c *answerChecks) check(content string) checkResult {
+	if c.schema != nil {
+		data, err := c.schema.parse(content)
+		if err != nil {
+			return checkResult{answer: content, schemaErr: err, problems: []string{fmt.Sprintf("不符合要求的 JSON Schema：%v", err)}}
+		}
+		content = string(data)
+		return checkResult{data: data, answer: content, problems: validator.Check(c.validators, content)}
+	}
+	return checkResult{answer: content, problems: validator.Check(c.validators, content)}
+}
+
+// retryPrompt 要求模型重新回答的提示
+func (c *answerChecks) retryPrompt(problems []string) string {
+	var b strings.Builder
+	b.WriteString("上面的回答不符合以下要求：\n")
+	for _, p := range problems {
+		b.WriteString("- " + p + "\n")
+	}
+	if c.schema != nil {
+		fmt.Fprintf(&b, "请只输出符合以下 JSON Schema 的 JSON，不要包含任何其他内容：\n%s", c.schema.raw)
+	} else {
+		b.WriteString("请修改后重新回答，只输出新的回答。")
+	}
+	return b.String()
+}
+
+// finalAnswer 校验最终回答，不满足约束时把原因告诉模型并要求重新回答：要求结构化输出时以 Ollama 的
+// format 参数约束输出，最多重试 structured_output.max_retries 次；有校验规则时最多重试
+// output_validation.max_retries 次，两者都有时取较大值。messages 为得到该回答时发送给模型的消息，
+// 重试过程不写入对话历史。返回解析后的 JSON、作为回答保存的内容和 on_failure 为 accept 时仍未通过的要求
+func (a *Agent) finalAnswer(ctx context.Context, model string, messages []api.Message, content string, checks *answerChecks) (json.RawMessage, string, []string, error) {
+	result := checks.check(content)
+	if len(result.problems) == 0 {
+		return result.data, result.answer, nil, nil
+	}
+
+	var retries int
+	if checks.schema != nil {
+		retries = a.cfg.StructuredOutput.MaxRetries
+		ctx = ollama.WithFormat(ctx, checks.schema.raw)
+	}
+	if len(checks.validators) > 0 {
+		retries = max(retries, a.cfg.OutputValidation.MaxRetries)
+	}
+
+	user := UserFromContext(ctx)
+	for attempt := 1; attempt <= retries; attempt++ {
+		klog.V(2).InfoS("Answer failed validation, asking model to retry", "attempt", attempt, "problems", result.problems)
+		if err := a.quota.Check(ctx, user, quota.MetricTokens); err != nil {
+			return nil, "", nil, err
+		}
+
+		retry := append(messages[:len(messages):len(messages)],
+			api.Message{Role: "assistant", Content: content},
+			api.Message{Role: "user", Content: checks.retryPrompt(result.problems)},
+		)
+		resp, err := a.chat(ctx, model, retry, nil)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("ollama chat failed: %w", err)
+		}
+		a.quota.Add(ctx, user, quota.Usage{Tokens: int64(resp.PromptEvalCount + resp.EvalCount)})
+
+		if content, err = a.filters.Apply(filter.StageOutput, resp.Message.Content); err != nil {
+			content = err.Error()
+		}
+		if result = checks.check(content); len(result.problems) == 0 {
+			return result.data, result.answer, nil, nil
+		}
+	}
+
+	if result.schemaErr != nil {
+		return nil, "", nil, fmt.Errorf("%w: %w", ErrStructuredOutput, result.schemaErr)
+	}
+	if a.cfg.OutputValidation.OnFailure == "accept" {
+		klog.InfoS("Answer still fails validation after retries, returning it as is", "problems", result.problems)
+		return result.data, result.answer, result.problems, nil
+	}
+	return nil, "", nil, fmt.Errorf("%w: %s", ErrOutputValidation, strings.Join(result.problems, "; "))
+}
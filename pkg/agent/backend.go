@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/champly/ai-agent/pkg/ollama"
+	"github.com/champly/ai-agent/pkg/workerpool"
+)
+
+// BackendStatus Ollama 主机的运行状态和请求排队情况
+type BackendStatus struct {
+	Hosts []ollama.BackendStatus `json:"hosts"`
+	// InFlight 各主机进行中的请求总数，VRAMBytes 已加载模型占用的显存总量
+	InFlight  int64 `json:"in_flight"`
+	VRAMBytes int64 `json:"vram_bytes"`
+	// Queue 等待 Ollama 名额（ollama.max_concurrent）的请求
+	Queue ollama.QueueStatus `json:"queue"`
+	// EstimatedWaitMs 新请求等待 Ollama 名额的估计时间：排队数 / 名额数 × 平均每次模型调用耗时，未限制并发时为 0
+	EstimatedWaitMs float64 `json:"estimated_wait_ms"`
+	// Workers 聊天 worker 池，未限制并发时为空
+	Workers *workerpool.Stats `json:"workers,omitempty"`
+}
+
+// BackendStatus 查询各 Ollama 主机已加载的模型和显存占用（/api/ps），以及请求的排队情况
+func (a *Agent) BackendStatus(ctx context.Context) *BackendStatus {
+	s := &BackendStatus{Hosts: a.ollama.Status(ctx), Queue: a.ollama.Queue()}
+	for _, h := range s.Hosts {
+		s.InFlight += h.InFlight
+		s.VRAMBytes += h.VRAMBytes
+	}
+	if s.Queue.Limit > 0 {
+		s.EstimatedWaitMs = float64(s.Queue.Queued) / float64(s.Queue.Limit) * a.modelStats.AvgTotalMs()
+	}
+	if stats, ok := a.WorkerStats(); ok {
+		s.Workers = &stats
+	}
+	return s
+}
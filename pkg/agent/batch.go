@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/champly/ai-agent/pkg/priority"
+)
+
+// ErrBatchTooLarge 批量请求超过 batch.max_items
+var ErrBatchTooLarge = errors.New("batch too large")
+
+// BatchResult 批量聊天中单条请求的结果，Err 不为空时 Response 为 nil
+type BatchResult struct {
+	Response *ChatResponse
+	Err      error
+}
+
+// ChatBatch 并发处理多条互不相关的聊天请求，同时处理的条数不超过 concurrency（最大为 batch.concurrency），
+// 结果与 reqs 一一对应；每条请求单独计入配额并经过 worker 池，ctx 取消后尚未开始的请求直接返回 ctx 的错误
+func (a *Agent) ChatBatch(ctx context.Context, reqs []*ChatRequest, concurrency int) ([]BatchResult, error) {
+	if len(reqs) > a.cfg.Batch.MaxItems {
+		return nil, fmt.Errorf("%w: %d items, at most %d allowed", ErrBatchTooLarge, len(reqs), a.cfg.Batch.MaxItems)
+	}
+	if concurrency <= 0 || concurrency > a.cfg.Batch.Concurrency {
+		concurrency = a.cfg.Batch.Concurrency
+	}
+
+	// 批量请求排在交互式聊天之后
+	ctx = priority.WithClass(ctx, priority.Batch)
+	results := make([]BatchResult, len(reqs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i].Response, results[i].Err = a.Chat(ctx, req)
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/champly/ai-agent/pkg/rag"
+)
+
+// citationInstruction 要求附加参考资料列表时，加在 RAG 上下文之后，要求模型标注引用的编号
+const citationInstruction = "回答中引用参考资料的内容时，在相应的句子后用方括号标注参考资料的编号，如 [1]。\n\n"
+
+// Citation RAG 聊天时提供给模型的一条参考资料，Index 与上下文中【参考资料 N】的编号一致
+type Citation struct {
+	Index      int     `json:"index"`
+	DocumentID string  `json:"document_id"`
+	ChunkID    string  `json:"chunk_id"`
+	Collection string  `json:"collection"`
+	Source     string  `json:"source,omitempty"` // 文件路径或 URL（文档元数据中的 source）
+	Repo       string  `json:"repo,omitempty"`   // git 来源的仓库地址
+	Commit     string  `json:"commit,omitempty"` // git 来源导入文档时的提交 SHA
+	Score      float32 `json:"score"`
+}
+
+// citations 按上下文中的顺序记录提供给模型的分块
+func citations(results []rag.SearchResult) []Citation {
+	var out []Citation
+	for i, r := range results {
+		doc := r.Document
+		out = append(out, Citation{
+			Index:      i + 1,
+			DocumentID: rag.SourceID(doc.ID),
+			ChunkID:    doc.ID,
+			Collection: doc.Collection,
+			Source:     doc.Metadata["source"],
+			Repo:       doc.Metadata["repo"],
+			Commit:     doc.Metadata["commit"],
+			Score:      r.Score,
+		})
+	}
+	return out
+}
+
+// formatReferences 把参考资料整理为附加在回答末尾的编号列表，同一文档的多个分块合并为一行
+func formatReferences(cites []Citation, lang string) string {
+	if len(cites) == 0 {
+		return ""
+	}
+	type reference struct {
+		indexes string
+		label   string
+	}
+	var refs []*reference
+	byDocument := make(map[string]*reference)
+	for _, c := range cites {
+		key := c.Collection + "\x00" + c.DocumentID
+		ref, ok := byDocument[key]
+		if !ok {
+			ref = &reference{label: c.Source}
+			if ref.label == "" {
+				ref.label = c.DocumentID
+			}
+			if c.Commit != "" {
+				ref.label += " @ " + c.Commit[:min(len(c.Commit), 12)]
+			}
+			byDocument[key] = ref
+			refs = append(refs, ref)
+		}
+		ref.indexes += fmt.Sprintf("[%d]", c.Index)
+	}
+
+	var b strings.Builder
+	if lang == "zh" {
+		b.WriteString("\n\n参考资料：")
+	} else {
+		b.WriteString("\n\nReferences:")
+	}
+	for _, ref := range refs {
+		fmt.Fprintf(&b, "\n%s %s", ref.indexes, ref.label)
+	}
+	return b.String()
+}
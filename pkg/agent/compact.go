@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"strings"
+	"unique"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/champly/ai-agent/pkg/guard"
+)
+
+// internMinLen 达到该长度的消息内容按值去重，较短的内容直接保存
+const internMinLen = 256
+
+// compactMessage 对话中保存的消息：较长的内容在所有对话间按值共享一份，
+// 常见的是相同的系统提示、重复的提问和多次调用得到的相同工具结果
+type compactMessage struct {
+	msg api.Message // Content 为空，其余字段原样保存
+
+	// 内容拆分为每条消息独有的首尾（工具结果包装中的随机边界）和可共享的正文
+	head, tail string
+	body       unique.Handle[string]
+	inline     string // 未去重的短内容
+}
+
+// compact 转换为紧凑形式
+func compact(msg api.Message) compactMessage {
+	content := msg.Content
+	msg.Content = ""
+	m := compactMessage{msg: msg}
+
+	if len(content) < internMinLen {
+		m.inline = content
+		return m
+	}
+	if head, body, tail, ok := guard.Split(content); ok && len(body) >= internMinLen {
+		// 首尾是原字符串的子串，复制一份以免引用整段内容
+		m.head, m.tail = strings.Clone(head), strings.Clone(tail)
+		content = body
+	}
+	m.body = unique.Make(content)
+	return m
+}
+
+// compactAll 批量转换
+func compactAll(messages []api.Message) []compactMessage {
+	result := make([]compactMessage, 0, len(messages))
+	for _, msg := range messages {
+		result = append(result, compact(msg))
+	}
+	return result
+}
+
+// message 还原为完整消息
+func (m compactMessage) message() api.Message {
+	msg := m.msg
+	switch {
+	case m.body == (unique.Handle[string]{}):
+		msg.Content = m.inline
+	case m.head == "" && m.tail == "":
+		msg.Content = m.body.Value()
+	default:
+		msg.Content = m.head + m.body.Value() + m.tail
+	}
+	return msg
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/champly/ai-agent/pkg/filter"
+	"github.com/champly/ai-agent/pkg/quota"
+)
+
+// CompleteRequest 单次补全请求：不创建对话，不使用工具、人设和内置系统提示
+type CompleteRequest struct {
+	Prompt string `json:"prompt"`
+	System string `json:"system,omitempty"`
+	Model  string `json:"model,omitempty"`
+	// Raw 不套用模型的提示模板，Prompt 需按模型的格式书写
+	Raw bool `json:"raw,omitempty"`
+	// Stop 停止序列，模型输出其中任一序列时停止生成
+	Stop []string `json:"stop,omitempty"`
+}
+
+// CompleteResponse 补全结果
+type CompleteResponse struct {
+	Response string `json:"response"`
+	Thinking string `json:"thinking,omitempty"`
+	Model    string `json:"model"`
+	// Metrics Ollama 返回的 token 数、耗时和结束原因
+	Metrics *ResponseMetrics `json:"metrics"`
+}
+
+// Complete 处理单次补全请求，与聊天一样计入配额并经过输入、输出过滤
+func (a *Agent) Complete(ctx context.Context, req *CompleteRequest) (*CompleteResponse, error) {
+	if err := checkStop(req.Stop); err != nil {
+		return nil, err
+	}
+	if err := a.chargeRequest(ctx); err != nil {
+		return nil, err
+	}
+	prompt, err := a.filters.Apply(filter.StageInput, req.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	model := cmp.Or(req.Model, a.DefaultModel())
+
+	var resp *api.GenerateResponse
+	err = a.workers.Do(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = a.ollama.Generate(ctx, model, &api.GenerateRequest{
+			Prompt:  prompt,
+			System:  req.System,
+			Raw:     req.Raw,
+			Options: withStopOption(nil, req.Stop),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ollama generate failed: %w", err)
+	}
+	a.recordModelCall(model, resp.Metrics, resp.DoneReason)
+	a.quota.Add(ctx, UserFromContext(ctx), quota.Usage{Tokens: int64(resp.PromptEvalCount + resp.EvalCount)})
+	var metrics ResponseMetrics
+	metrics.add(resp.Metrics, resp.DoneReason)
+
+	content, err := a.filters.Apply(filter.StageOutput, resp.Response)
+	if err != nil {
+		content = err.Error()
+	}
+	return &CompleteResponse{
+		Response: content,
+		Thinking: resp.Thinking,
+		Model:    model,
+		Metrics:  &metrics,
+	}, nil
+}
+
+// complete 内部使用的单次补全（注入检测、模型路由等），不计入用户配额，也不写入任何对话；model 为空时使用默认模型
+func (a *Agent) complete(ctx context.Context, model, prompt string) (string, error) {
+	model = cmp.Or(model, a.DefaultModel())
+	resp, err := a.ollama.Generate(ctx, model, &api.GenerateRequest{Prompt: prompt})
+	if err != nil {
+		return "", err
+	}
+	a.recordModelCall(model, resp.Metrics, resp.DoneReason)
+	return resp.Response, nil
+}
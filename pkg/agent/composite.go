@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"gopkg.in/yaml.v3"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/workflow"
+)
+
+// compositeSource 组合工具的来源，工具范围的 mcp_servers 中写 composite 表示这些工具
+const compositeSource = "composite"
+
+// loadCompositeTools 按工作流格式解析组合工具；步骤不能调用组合工具，避免相互调用形成递归
+func loadCompositeTools(cfgs []config.CompositeToolConfig, maxSteps int) (*workflow.Engine, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+	defs := make([]*workflow.Definition, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		data, err := yaml.Marshal(map[string]any{
+			"name":        cfg.Name,
+			"description": cfg.Description,
+			"inputs":      cfg.Inputs,
+			"steps":       cfg.Steps,
+			"output":      cfg.Output,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("composite tool %s: %w", cfg.Name, err)
+		}
+		def, err := workflow.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("composite tool %s: %w", cfg.Name, err)
+		}
+		defs = append(defs, def)
+	}
+	for _, def := range defs {
+		for _, step := range def.Steps {
+			isComposite := func(d *workflow.Definition) bool { return d.Name == step.Tool }
+			if step.Type == workflow.StepTool && slices.ContainsFunc(defs, isComposite) {
+				return nil, fmt.Errorf("composite tool %s: step %s cannot call composite tool %s", def.Name, step.ID, step.Tool)
+			}
+		}
+	}
+	return workflow.NewEngine(defs, maxSteps)
+}
+
+// registerCompositeTools 注册组合工具，与已有工具重名时跳过
+func (a *Agent) registerCompositeTools() {
+	for _, def := range a.composites.List() {
+		properties := make(map[string]any, len(def.Inputs))
+		var required []string
+		for _, in := range def.Inputs {
+			schema := map[string]any{"type": "string"}
+			if in.Description != "" {
+				schema["description"] = in.Description
+			}
+			if in.Default != "" {
+				schema["default"] = in.Default
+			}
+			properties[in.Name] = schema
+			if in.Required {
+				required = append(required, in.Name)
+			}
+		}
+		inputSchema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			inputSchema["required"] = required
+		}
+
+		info := &ToolInfo{
+			Name:   def.Name,
+			Source: compositeSource,
+			MCPTool: &mcp.Tool{
+				Name:        def.Name,
+				Description: def.Description,
+				InputSchema: inputSchema,
+			},
+			Executor: compositeExecutor{a: a, name: def.Name},
+		}
+		if !a.toolRegistry.Add(info) {
+			klog.InfoS("Composite tool conflicts with an existing tool, skipping", "tool", def.Name)
+		}
+	}
+}
+
+// compositeExecutor 组合工具执行器，每个步骤中的工具调用与模型直接调用工具一样经过权限策略、配额和审计
+type compositeExecutor struct {
+	a    *Agent
+	name string
+}
+
+// Execute 执行组合工具的步骤，返回最终输出
+func (e compositeExecutor) Execute(ctx context.Context, args map[string]any) (string, error) {
+	inputs := make(map[string]string, len(args))
+	for key, value := range args {
+		switch v := value.(type) {
+		case string:
+			inputs[key] = v
+		case float64:
+			inputs[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			inputs[key] = fmt.Sprint(v)
+		}
+	}
+	res, err := e.a.composites.Run(ctx, workflowRunner{e.a}, e.name, inputs, nil)
+	if err != nil {
+		return "", err
+	}
+	return res.Output, nil
+}
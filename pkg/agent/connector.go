@@ -0,0 +1,535 @@
+package agent
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/cache"
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/connector"
+	"github.com/champly/ai-agent/pkg/docconv"
+)
+
+const (
+	// connectorReplyTimeout 连接器单次回答的超时
+	connectorReplyTimeout = 10 * time.Minute
+	// connectorTypingInterval 回答过程中重新显示“正在输入”的间隔
+	connectorTypingInterval = 4 * time.Second
+	// connectorMaxBackoff 连接断开后重连的最长等待时间
+	connectorMaxBackoff = time.Minute
+	// connectorSettingsTTL 频道设置的保留时间
+	connectorSettingsTTL = 365 * 24 * time.Hour
+)
+
+// ErrConnectorExists 注册的连接器与已有连接器重名
+var ErrConnectorExists = errors.New("connector already registered")
+
+// connectorName 连接器名称允许的字符，与对话 ID 一致
+var connectorName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// connectorManager 已注册的连接器和频道设置（人设）
+type connectorManager struct {
+	settings cache.Store
+
+	mu         sync.Mutex
+	names      map[string]bool
+	connectors []connector.Connector // 在所有副本上运行的连接器，独占的连接器作为主节点任务运行
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// newConnectorManager 创建连接器管理器，频道设置按 connectors.backend 保存
+func newConnectorManager(cfg config.ConnectorsConfig, redisCfg config.RedisConfig) (*connectorManager, error) {
+	m := &connectorManager{names: make(map[string]bool)}
+	switch cfg.Backend {
+	case "", "memory":
+		m.settings = cache.NewMemoryStore(1000)
+	case "redis":
+		rs, err := cache.NewRedisStore(redisCfg)
+		if err != nil {
+			return nil, err
+		}
+		m.settings = rs
+	default:
+		return nil, fmt.Errorf("unknown connectors backend: %s", cfg.Backend)
+	}
+	return m, nil
+}
+
+// RegisterConnector 注册聊天平台连接器（需在 StartBackground 之前调用），重名时返回 ErrConnectorExists。
+// 连接器在 StartBackground 后开始接收消息，实现 connector.Exclusive 的只在主节点运行，Stop 时断开
+func (a *Agent) RegisterConnector(c connector.Connector) error {
+	name := c.Name()
+	if !connectorName.MatchString(name) {
+		return fmt.Errorf("invalid connector name %q", name)
+	}
+	m := a.connectors
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.names[name] {
+		return fmt.Errorf("%w: %s", ErrConnectorExists, name)
+	}
+	m.names[name] = true
+
+	if e, ok := c.(connector.Exclusive); ok && e.Exclusive() {
+		a.leader.Register("connector-"+name, func(ctx context.Context) { a.runConnector(ctx, c) })
+		return nil
+	}
+	m.connectors = append(m.connectors, c)
+	return nil
+}
+
+// startConnectors 在后台运行非独占的连接器
+func (a *Agent) startConnectors(ctx context.Context) {
+	m := a.connectors
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ctx, m.cancel = context.WithCancel(ctx)
+	for _, c := range m.connectors {
+		m.wg.Go(func() { a.runConnector(ctx, c) })
+	}
+}
+
+// stopConnectors 断开非独占的连接器，进行中的回答随之取消
+func (a *Agent) stopConnectors() {
+	m := a.connectors
+	m.mu.Lock()
+	cancel := m.cancel
+	m.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	m.wg.Wait()
+}
+
+// runConnector 接收消息直到 ctx 结束，连接断开时按指数退避（或平台建议的时间）重连
+func (a *Agent) runConnector(ctx context.Context, c connector.Connector) {
+	name := c.Name()
+	klog.InfoS("Connector started", "connector", name)
+	handler := func(ctx context.Context, msg *connector.Message) {
+		go a.handleConnectorMessage(ctx, c, msg)
+	}
+
+	backoff := time.Second
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := c.Receive(ctx, handler)
+		if ctx.Err() != nil {
+			break
+		}
+		// 正常的断开立即重连，连接后很快又断开时（如应用被停用）仍按退避等待
+		if err == nil && time.Since(started) > 10*time.Second {
+			backoff = time.Second
+			continue
+		}
+		// 连接保持了一段时间后才断开时重置退避
+		if time.Since(started) > connectorMaxBackoff {
+			backoff = time.Second
+		}
+		wait := backoff
+		var retry connector.RetryAfter
+		if errors.As(err, &retry) && retry.RetryAfter() > 0 {
+			wait = retry.RetryAfter()
+		}
+		klog.ErrorS(err, "Connector disconnected, reconnecting", "connector", name, "backoff", wait)
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, connectorMaxBackoff)
+	}
+	klog.InfoS("Connector stopped", "connector", name)
+}
+
+// handleConnectorMessage 处理连接器收到的一条消息：命令、文件和提问
+func (a *Agent) handleConnectorMessage(ctx context.Context, c connector.Connector, msg *connector.Message) {
+	opts := c.Options()
+	ctx, cancel := context.WithTimeout(ctx, connectorReplyTimeout)
+	defer cancel()
+	ctx = WithToolProfile(WithUser(ctx, opts.User), &opts.ToolProfile)
+	target := connector.Target{Channel: msg.Channel, Thread: msg.Thread, ReplyTo: msg.ID, User: msg.User}
+
+	if msg.Command != "" {
+		target.Private = true
+		a.sendConnectorText(ctx, c, target, a.connectorCommand(ctx, c, msg))
+		return
+	}
+
+	text := strings.TrimSpace(msg.Text)
+	var images, notes []string
+	for _, f := range msg.Files {
+		if f.Image {
+			data, err := downloadConnectorFile(ctx, f, opts.MaxFileBytes)
+			if err != nil {
+				klog.ErrorS(err, "Failed to download image", "connector", c.Name(), "channel", msg.Channel)
+				a.sendConnectorText(ctx, c, target, "图片下载失败："+err.Error())
+				return
+			}
+			images = append(images, base64.StdEncoding.EncodeToString(data))
+			continue
+		}
+		note, err := a.connectorUpload(ctx, c.Name(), opts, msg.Channel, f)
+		if err != nil {
+			klog.ErrorS(err, "Failed to handle upload", "connector", c.Name(), "channel", msg.Channel, "file", f.Name)
+			a.sendConnectorText(ctx, c, target, "文件处理失败："+err.Error())
+			return
+		}
+		notes = append(notes, note)
+	}
+	if len(notes) > 0 {
+		if text == "" {
+			a.sendConnectorText(ctx, c, target, strings.Join(notes, "\n"))
+			return
+		}
+		text += "\n\n（" + strings.Join(notes, "；") + "）"
+	}
+	if text == "" && len(images) > 0 {
+		text = "请描述这张图片"
+	}
+	if text == "" {
+		return
+	}
+
+	a.connectorAnswer(ctx, c, target, &ChatRequest{
+		Message:        text,
+		ConversationID: connectorConversationID(c.Name(), msg.Channel, msg.Thread),
+		Model:          opts.Model,
+		Collection:     opts.Collection,
+		Persona:        a.connectorPersona(ctx, c.Name(), msg.Channel),
+		Images:         images,
+	})
+}
+
+// connectorAnswer 发送占位消息，回答过程中按 update_interval 更新工具调用进度并定期显示“正在输入”，完成后替换为最终回答
+func (a *Agent) connectorAnswer(ctx context.Context, c connector.Connector, target connector.Target, req *ChatRequest) {
+	opts := c.Options()
+	r := &connector.Reply{}
+	id, err := c.Send(ctx, target, r)
+	if err != nil {
+		klog.ErrorS(err, "Failed to send message", "connector", c.Name(), "channel", target.Channel)
+		return
+	}
+
+	// 事件回调在对话循环中同步触发，只记录状态并通知更新协程
+	var mu sync.Mutex
+	changed := make(chan struct{}, 1)
+	req.OnEvent = func(ev Event) {
+		mu.Lock()
+		switch ev.Type {
+		case EventToolCall:
+			r.Tools = append(r.Tools, connector.ToolCall{Tool: ev.Tool, Arguments: ev.Arguments})
+		case EventToolResult:
+			for i := len(r.Tools) - 1; i >= 0; i-- {
+				if tc := &r.Tools[i]; tc.Tool == ev.Tool && !tc.Done {
+					tc.Result, tc.Error, tc.Done = ev.Result, ev.Error, true
+					break
+				}
+			}
+		default:
+			mu.Unlock()
+			return
+		}
+		mu.Unlock()
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	edit := func(ctx context.Context) error {
+		mu.Lock()
+		snapshot := *r
+		snapshot.Tools = slices.Clone(r.Tools)
+		mu.Unlock()
+		return c.Edit(ctx, target, id, &snapshot)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-changed:
+				if err := edit(ctx); err != nil {
+					klog.V(2).InfoS("Failed to update message", "connector", c.Name(), "channel", target.Channel, "err", err)
+				}
+				select {
+				case <-done:
+					return
+				case <-time.After(opts.UpdateInterval):
+				}
+			}
+		}
+	})
+	wg.Go(func() {
+		for {
+			if err := c.Typing(ctx, target); err != nil && ctx.Err() == nil {
+				klog.V(2).InfoS("Failed to send typing indicator", "connector", c.Name(), "channel", target.Channel, "err", err)
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(connectorTypingInterval):
+			}
+		}
+	})
+
+	var resp *ChatResponse
+	if opts.RAG {
+		resp, err = a.ChatWithRAG(ctx, req)
+	} else {
+		resp, err = a.Chat(ctx, req)
+	}
+	close(done)
+	wg.Wait()
+
+	mu.Lock()
+	r.Done = true
+	if err != nil {
+		klog.ErrorS(err, "Failed to answer message", "connector", c.Name(), "conversationID", req.ConversationID)
+		r.Err = err.Error()
+	} else {
+		r.Answer = resp.Response
+		r.Footer = connectorFooter(resp)
+	}
+	mu.Unlock()
+	// 回答超时后仍需更新消息
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+	if err := edit(ctx); err != nil {
+		klog.ErrorS(err, "Failed to update message", "connector", c.Name(), "channel", target.Channel)
+	}
+}
+
+// sendConnectorText 发送一条完整的文本回复，如命令结果和错误说明
+func (a *Agent) sendConnectorText(ctx context.Context, c connector.Connector, target connector.Target, text string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+	if _, err := c.Send(ctx, target, &connector.Reply{Answer: text, Done: true}); err != nil {
+		klog.ErrorS(err, "Failed to send message", "connector", c.Name(), "channel", target.Channel)
+	}
+}
+
+// connectorFooter 回答下方的说明：模型、人设和工具调用次数
+func connectorFooter(resp *ChatResponse) string {
+	parts := []string{resp.Model}
+	if resp.Persona != "" {
+		parts = append(parts, "人设 "+resp.Persona)
+	}
+	if n := len(resp.ToolCalls); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d 次工具调用", n))
+	}
+	return strings.Join(slices.DeleteFunc(parts, func(s string) bool { return s == "" }), " · ")
+}
+
+// connectorCommand 处理命令，返回回复的文本：persona 查看或切换频道的人设，help 显示用法
+func (a *Agent) connectorCommand(ctx context.Context, c connector.Connector, msg *connector.Message) string {
+	prefix := msg.CommandPrefix
+	switch msg.Command {
+	case "help":
+		return fmt.Sprintf("直接发送问题即可，同一讨论串（或聊天）中的消息属于同一个对话，附带的图片一并发送给模型。\n\n"+
+			"- `%[1]spersona` 查看可用人设和当前频道的人设\n"+
+			"- `%[1]spersona <name>` 切换当前频道的人设，频道中之后的消息使用该人设\n"+
+			"- `%[1]spersona default` 恢复默认人设\n"+
+			"- `%[1]shelp` 显示本说明", prefix)
+	case "persona":
+	default:
+		return fmt.Sprintf("未知命令 `%s`，发送 `%shelp` 查看用法", msg.Command, prefix)
+	}
+
+	personas := a.ListPersonas()
+	name := strings.TrimSpace(msg.Args)
+	if name == "" {
+		current := a.connectorPersona(ctx, c.Name(), msg.Channel)
+		if current == "" {
+			current = "默认"
+		}
+		var lines []string
+		for _, p := range personas {
+			line := "- `" + p.Name + "`"
+			if p.Description != "" {
+				line += " " + p.Description
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			return fmt.Sprintf("当前频道的人设：%s\n没有配置人设", current)
+		}
+		return fmt.Sprintf("当前频道的人设：%s\n可用人设：\n%s", current, strings.Join(lines, "\n"))
+	}
+
+	if name == "default" {
+		name = ""
+	} else if !slices.ContainsFunc(personas, func(p config.PersonaConfig) bool { return p.Name == name }) {
+		return fmt.Sprintf("人设 `%s` 不存在，发送 `%spersona` 查看可用人设", name, prefix)
+	}
+	if err := a.connectors.settings.Set(ctx, connectorPersonaKey(c.Name(), msg.Channel), []byte(name), connectorSettingsTTL); err != nil {
+		klog.ErrorS(err, "Failed to save channel persona", "connector", c.Name(), "channel", msg.Channel)
+		return "保存失败：" + err.Error()
+	}
+	klog.InfoS("Channel persona changed", "connector", c.Name(), "channel", msg.Channel, "user", msg.User, "persona", name)
+	if name == "" {
+		return "已恢复默认人设"
+	}
+	return fmt.Sprintf("已切换为人设 `%s`", name)
+}
+
+// connectorPersona 频道设置的人设，未设置时为空
+func (a *Agent) connectorPersona(ctx context.Context, name, channel string) string {
+	value, ok, err := a.connectors.settings.Get(ctx, connectorPersonaKey(name, channel))
+	if err != nil && !errors.Is(err, context.Canceled) {
+		klog.ErrorS(err, "Failed to load channel persona", "connector", name, "channel", channel)
+	}
+	if !ok {
+		return ""
+	}
+	return string(value)
+}
+
+// connectorPersonaKey 频道人设的存储键
+func connectorPersonaKey(name, channel string) string {
+	return "connector:persona:" + name + ":" + channel
+}
+
+// connectorConversationID 讨论串（未使用讨论串时为整个频道）对应的对话 ID，不允许的字符替换为 _
+func connectorConversationID(name, channel, thread string) string {
+	id := name + "-" + channel
+	if thread != "" {
+		id += "-" + thread
+	}
+	return safeConnectorID(id)
+}
+
+// safeConnectorID 把对话 ID 和目录名中不允许的字符替换为 _
+func safeConnectorID(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && (r == '-' || r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// connectorUpload 按 uploads 配置处理收到的文件，返回告知用户和模型的说明
+func (a *Agent) connectorUpload(ctx context.Context, name string, opts config.ConnectorConfig, channel string, f connector.File) (string, error) {
+	fileName := sanitizeFileName(f.Name)
+	if opts.Uploads == "ignore" {
+		return fmt.Sprintf("文件 %s 未处理，未启用文件上传", fileName), nil
+	}
+	data, err := downloadConnectorFile(ctx, f, opts.MaxFileBytes)
+	if err != nil {
+		return "", err
+	}
+	dir := safeConnectorID(channel)
+
+	if opts.Uploads == "rag" {
+		content, err := extractUploadText(fileName, data)
+		if err != nil {
+			return "", err
+		}
+		id := name + "-" + dir + "-" + strings.TrimSuffix(fileName, filepath.Ext(fileName))
+		err = a.AddRAGDocument(ctx, opts.Collection, id, content, map[string]string{
+			"source":  name,
+			"file":    fileName,
+			"channel": channel,
+		})
+		if err != nil {
+			return "", err
+		}
+		klog.InfoS("Upload ingested", "connector", name, "channel", channel, "file", fileName, "collection", opts.Collection)
+		return fmt.Sprintf("文件 %s 已导入知识库", fileName), nil
+	}
+
+	saved, err := saveUpload(filepath.Join(opts.WorkspaceDir, dir), fileName, data)
+	if err != nil {
+		return "", err
+	}
+	rel := filepath.ToSlash(filepath.Join(dir, saved))
+	klog.InfoS("Upload saved", "connector", name, "channel", channel, "path", rel, "size", len(data))
+	return fmt.Sprintf("文件已保存到工作目录的 %s", rel), nil
+}
+
+// downloadConnectorFile 下载消息附带的文件，已知大小超过 maxBytes 时不下载
+func downloadConnectorFile(ctx context.Context, f connector.File, maxBytes int) ([]byte, error) {
+	if f.Size > maxBytes {
+		return nil, fmt.Errorf("file size %d exceeds limit of %d bytes", f.Size, maxBytes)
+	}
+	return f.Download(ctx, maxBytes)
+}
+
+// extractUploadText 把上传的文档转为文本：Markdown 和纯文本原样保留，HTML、PDF 提取正文
+func extractUploadText(name string, data []byte) (string, error) {
+	format := docconv.DetectFormat(name, data)
+	switch format {
+	case docconv.FormatMarkdown, docconv.FormatText:
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("%s is not a UTF-8 text file", name)
+		}
+		return string(data), nil
+	}
+	text, err := docconv.Convert(data, format)
+	if err != nil {
+		return "", fmt.Errorf("extract text from %s: %w", name, err)
+	}
+	return text, nil
+}
+
+// saveUpload 在 dir 中保存文件，同名文件已存在时在文件名后加序号，返回实际的文件名
+func saveUpload(dir, name string, data []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 0; i < 100; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		f, err := os.OpenFile(filepath.Join(dir, candidate), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+		return candidate, nil
+	}
+	return "", fmt.Errorf("too many files named %s", name)
+}
+
+// sanitizeFileName 去掉文件名中的目录和控制字符，为空时使用默认名称
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "upload"
+	}
+	return name
+}
@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// userKey 用户身份在 context 中的键
+type userKey struct{}
+
+// WithUser 将发起请求的用户身份写入 context，供工具策略等按用户区分
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext 返回 context 中的用户身份，未设置时为空
+func UserFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// toolProfileKey 工具范围在 context 中的键
+type toolProfileKey struct{}
+
+// WithToolProfile 限制本次请求可用的工具，与租户限制同时生效，用于入站 webhook 等自动触发的任务
+func WithToolProfile(ctx context.Context, profile *config.ToolProfile) context.Context {
+	return context.WithValue(ctx, toolProfileKey{}, profile)
+}
+
+// toolProfileFromContext 返回 context 中的工具范围，未设置时为 nil
+func toolProfileFromContext(ctx context.Context) *config.ToolProfile {
+	profile, _ := ctx.Value(toolProfileKey{}).(*config.ToolProfile)
+	return profile
+}
+
+// routeKey 模型路由结果在 context 中的键
+type routeKey struct{}
+
+// route 模型路由的结果：请求的任务类型和选择的模型，Model 为空时使用默认模型
+type route struct {
+	Category string
+	Model    string
+}
+
+// withRoute 将模型路由结果写入 context
+func withRoute(ctx context.Context, r route) context.Context {
+	return context.WithValue(ctx, routeKey{}, r)
+}
+
+// routeFromContext 返回 context 中的模型路由结果，未路由时为零值
+func routeFromContext(ctx context.Context) route {
+	r, _ := ctx.Value(routeKey{}).(route)
+	return r
+}
+
+// toolImagesKey 收集工具结果中图片的 context 键
+type toolImagesKey struct{}
+
+// toolImages 一次工具调用返回的图片
+type toolImages struct {
+	mu     sync.Mutex
+	images []api.ImageData
+}
+
+// withToolImages 返回收集本次工具调用所返回图片的 context
+func withToolImages(ctx context.Context) (context.Context, *toolImages) {
+	imgs := &toolImages{}
+	return context.WithValue(ctx, toolImagesKey{}, imgs), imgs
+}
+
+// addToolImages 记录工具返回的图片，context 中没有收集器时丢弃
+func addToolImages(ctx context.Context, images ...api.ImageData) {
+	imgs, ok := ctx.Value(toolImagesKey{}).(*toolImages)
+	if !ok {
+		return
+	}
+	imgs.mu.Lock()
+	defer imgs.mu.Unlock()
+	imgs.images = append(imgs.images, images...)
+}
+
+// list 返回收集到的图片
+func (t *toolImages) list() []api.ImageData {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.images
+}
+
+// deniedCapabilitiesKey 本次请求不可用的工具能力在 context 中的键
+type deniedCapabilitiesKey struct{}
+
+// withDeniedCapabilities 将请求指定的不可用工具能力写入 context，为空时原样返回
+func withDeniedCapabilities(ctx context.Context, names []string) context.Context {
+	if len(names) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, deniedCapabilitiesKey{}, names)
+}
+
+// deniedCapabilitiesFromContext 返回 context 中不可用的工具能力
+func deniedCapabilitiesFromContext(ctx context.Context) []string {
+	names, _ := ctx.Value(deniedCapabilitiesKey{}).([]string)
+	return names
+}
+
+// stopKey 本次请求的停止序列在 context 中的键
+type stopKey struct{}
+
+// withStop 将请求指定的停止序列写入 context，为空时原样返回
+func withStop(ctx context.Context, stop []string) context.Context {
+	if len(stop) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, stopKey{}, stop)
+}
+
+// stopFromContext 返回 context 中的停止序列
+func stopFromContext(ctx context.Context) []string {
+	stop, _ := ctx.Value(stopKey{}).([]string)
+	return stop
+}
+
+// personaKey 当前对话的人设在 context 中的键
+type personaKey struct{}
+
+// withPersona 将本轮使用的人设写入 context，persona 为 nil 时原样返回
+func withPersona(ctx context.Context, persona *config.PersonaConfig) context.Context {
+	if persona == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, personaKey{}, persona)
+}
+
+// personaFromContext 返回 context 中的人设，未设置时为 nil
+func personaFromContext(ctx context.Context) *config.PersonaConfig {
+	persona, _ := ctx.Value(personaKey{}).(*config.PersonaConfig)
+	return persona
+}
+
+// conversationKey 当前对话 ID 在 context 中的键
+type conversationKey struct{}
+
+// withConversationID 将发起工具调用的对话 ID 写入 context，供 read_more 等内置工具使用
+func withConversationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, conversationKey{}, id)
+}
+
+// conversationIDFromContext 返回 context 中的对话 ID，直接调用工具时为空
+func conversationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(conversationKey{}).(string)
+	return id
+}
+
+// languageKey 本轮回答语言在 context 中的键
+type languageKey struct{}
+
+// withLanguage 将本轮的回答语言写入 context
+func withLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// languageFromContext 返回 context 中的回答语言，未设置时为空
+func languageFromContext(ctx context.Context) string {
+	lang, _ := ctx.Value(languageKey{}).(string)
+	return lang
+}
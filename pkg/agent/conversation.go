@@ -1,31 +1,227 @@
 package agent
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/ollama/ollama/api"
+
+	"github.com/champly/ai-agent/pkg/experiment"
+	"github.com/champly/ai-agent/pkg/store"
 )
 
+// ErrConversationForbidden 对话属于其他用户
+var ErrConversationForbidden = errors.New("conversation belongs to another user")
+
+// ErrConversationBusy 对话正在处理其他请求
+var ErrConversationBusy = errors.New("conversation is busy")
+
+// ErrConversationIdle 对话当前没有进行中的请求
+var ErrConversationIdle = errors.New("conversation is not running")
+
+// ErrTurnStopped 进行中的请求被用户停止
+var ErrTurnStopped = errors.New("conversation turn stopped")
+
+// CancelledMarker 请求被停止或取消时追加到对话的助手消息，标记该轮回答不完整
+const CancelledMarker = "[cancelled]"
+
 // Conversation 对话
 type Conversation struct {
-	ID       string
-	messages []api.Message
-	mu       sync.RWMutex
+	ID        string
+	User      string // 创建对话的用户
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	messages  []compactMessage
+	mu        sync.RWMutex
+	// turn 同一时间只允许一个请求执行对话循环，容量为 1 的通道可以配合超时和 context 等待
+	turn chan struct{}
+	// stop 取消进行中的一轮处理，没有进行中的请求时为 nil，由 mu 保护
+	stop context.CancelCauseFunc
+	// persona、language 对话选择的人设和回答语言，由 mu 保护
+	persona  string
+	language string
+	// profile 对话开始时的用户资料，之后的每一轮都注入系统提示，由 mu 保护
+	profile string
+	// assignment 最近一轮分配到的实验变体，rating 为用户对该变体的反馈（1、-1 或 0），由 mu 保护
+	assignment experiment.Assignment
+	rating     int
 }
 
 // NewConversation 创建对话
 func NewConversation(id string) *Conversation {
+	now := time.Now()
+	return &Conversation{
+		ID:        id,
+		CreatedAt: now,
+		UpdatedAt: now,
+		messages:  make([]compactMessage, 0),
+		turn:      make(chan struct{}, 1),
+	}
+}
+
+// conversationFromRecord 从持久化记录恢复对话
+func conversationFromRecord(rec *store.Record) *Conversation {
 	return &Conversation{
-		ID:       id,
-		messages: make([]api.Message, 0),
+		ID:        rec.ID,
+		User:      rec.User,
+		CreatedAt: rec.CreatedAt,
+		UpdatedAt: rec.UpdatedAt,
+		persona:   rec.Persona,
+		language:  rec.Language,
+		profile:   rec.Profile,
+		messages:  compactAll(rec.Messages),
+		turn:      make(chan struct{}, 1),
+	}
+}
+
+// acquire 开始一轮对话：wait <= 0 时对话忙则立即返回 ErrConversationBusy，
+// 否则最多等待 wait，超时返回 ErrConversationBusy，context 取消时返回 context 的错误
+func (c *Conversation) acquire(ctx context.Context, wait time.Duration) error {
+	select {
+	case c.turn <- struct{}{}:
+		return nil
+	default:
+	}
+	if wait <= 0 {
+		return fmt.Errorf("%w: %s", ErrConversationBusy, c.ID)
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case c.turn <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("%w: %s (waited %s)", ErrConversationBusy, c.ID, wait)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release 结束一轮对话
+func (c *Conversation) release() {
+	<-c.turn
+}
+
+// setStop 设置进行中的一轮处理的取消函数，nil 表示本轮已结束
+func (c *Conversation) setStop(stop context.CancelCauseFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stop = stop
+}
+
+// Persona 返回对话选择的人设，未选择时为空
+func (c *Conversation) Persona() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.persona
+}
+
+// setPersona 记录对话选择的人设
+func (c *Conversation) setPersona(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.persona = name
+}
+
+// Language 返回对话选择的回答语言，未选择时为空
+func (c *Conversation) Language() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.language
+}
+
+// setLanguage 记录对话选择的回答语言
+func (c *Conversation) setLanguage(lang string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.language = lang
+}
+
+// Profile 返回对话开始时的用户资料，没有资料时为空
+func (c *Conversation) Profile() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.profile
+}
+
+// setProfile 记录对话开始时的用户资料
+func (c *Conversation) setProfile(profile string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.profile = profile
+}
+
+// started 对话是否已有消息
+func (c *Conversation) started() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.messages) > 0
+}
+
+// setAssignment 记录本轮分配到的实验变体，变体变化时之前的反馈不再对应当前变体
+func (c *Conversation) setAssignment(a experiment.Assignment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.assignment != a {
+		c.assignment, c.rating = a, 0
+	}
+}
+
+// rate 记录对最近一轮的反馈，返回对应的实验变体和之前的反馈；没有参与实验时返回 experiment.ErrNotAssigned
+func (c *Conversation) rate(rating int) (experiment.Assignment, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.assignment.Experiment == "" {
+		return experiment.Assignment{}, 0, fmt.Errorf("%w: %s", experiment.ErrNotAssigned, c.ID)
 	}
+	previous := c.rating
+	c.rating = rating
+	return c.assignment, previous, nil
+}
+
+// Stop 停止进行中的一轮处理：取消模型调用和工具调用，没有进行中的请求时返回 ErrConversationIdle
+func (c *Conversation) Stop() error {
+	c.mu.RLock()
+	stop := c.stop
+	c.mu.RUnlock()
+	if stop == nil {
+		return fmt.Errorf("%w: %s", ErrConversationIdle, c.ID)
+	}
+	stop(ErrTurnStopped)
+	return nil
+}
+
+// refresh 用存储中更新的记录替换本地内容（其他副本修改过该对话时）
+func (c *Conversation) refresh(rec *store.Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rec.UpdatedAt.After(c.UpdatedAt) {
+		c.messages = compactAll(rec.Messages)
+		c.UpdatedAt = rec.UpdatedAt
+		c.persona = rec.Persona
+		c.language = rec.Language
+		c.profile = rec.Profile
+	}
+}
+
+// checkOwner 校验用户能否访问对话；user 为空表示进程内调用（CLI、事件诊断等），不做限制
+func checkOwner(owner, user string) error {
+	if user != "" && owner != "" && owner != user {
+		return ErrConversationForbidden
+	}
+	return nil
 }
 
 // AddMessage 添加消息
 func (c *Conversation) AddMessage(msg api.Message) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.messages = append(c.messages, msg)
+	c.messages = append(c.messages, compact(msg))
+	c.UpdatedAt = time.Now()
 }
 
 // GetMessages 获取所有消息
@@ -34,7 +230,30 @@ func (c *Conversation) GetMessages() []api.Message {
 	defer c.mu.RUnlock()
 
 	// 返回副本
-	result := make([]api.Message, len(c.messages))
-	copy(result, c.messages)
+	result := make([]api.Message, 0, len(c.messages))
+	for _, m := range c.messages {
+		result = append(result, m.message())
+	}
 	return result
 }
+
+// Record 导出为持久化记录
+func (c *Conversation) Record() *store.Record {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	messages := make([]api.Message, 0, len(c.messages))
+	for _, m := range c.messages {
+		messages = append(messages, m.message())
+	}
+	return &store.Record{
+		ID:        c.ID,
+		User:      c.User,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Persona:   c.persona,
+		Language:  c.language,
+		Profile:   c.profile,
+		Messages:  messages,
+	}
+}
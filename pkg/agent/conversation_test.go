@@ -0,0 +1,201 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// echo 回答最后一条用户消息
+func echo(req *api.ChatRequest) string {
+	return "echo " + req.Messages[len(req.Messages)-1].Content
+}
+
+// serialReply 返回记录并发数的回答函数：每个请求持续 hold，overlap 记录同时处理的最大请求数
+func serialReply(hold time.Duration, overlap *atomic.Int32) chatHandler {
+	var active atomic.Int32
+	return func(req *api.ChatRequest) string {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			cur := overlap.Load()
+			if n <= cur || overlap.CompareAndSwap(cur, n) {
+				break
+			}
+		}
+		time.Sleep(hold)
+		return echo(req)
+	}
+}
+
+// blockingReply 返回在 release 关闭前不回答的回答函数，请求到达时向 started 发送通知
+func blockingReply(started chan<- struct{}, release <-chan struct{}) chatHandler {
+	return func(req *api.ChatRequest) string {
+		started <- struct{}{}
+		<-release
+		return echo(req)
+	}
+}
+
+// checkTurns 检查对话中的用户消息和回答成对出现、没有交错，返回用户消息数
+func checkTurns(t *testing.T, a *Agent, id string) int {
+	t.Helper()
+	rec, err := a.GetConversation(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var turns []api.Message
+	for _, m := range rec.Messages {
+		if m.Role == "user" || m.Role == "assistant" {
+			turns = append(turns, m)
+		}
+	}
+	if len(turns)%2 != 0 {
+		t.Fatalf("unpaired messages: %+v", turns)
+	}
+	for i := 0; i < len(turns); i += 2 {
+		user, answer := turns[i], turns[i+1]
+		if user.Role != "user" || answer.Role != "assistant" || answer.Content != "echo "+user.Content {
+			t.Fatalf("interleaved turn %d: %q -> %q", i/2, user.Content, answer.Content)
+		}
+	}
+	return len(turns) / 2
+}
+
+func TestConcurrentChatQueued(t *testing.T) {
+	var overlap atomic.Int32
+	a := newTestAgent(t, serialReply(50*time.Millisecond, &overlap), "conversation:\n  concurrency: queue\n  queue_timeout: 5s\n")
+	first, err := a.Chat(t.Context(), &ChatRequest{Message: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := first.ConversationID
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Go(func() {
+			_, errs[i] = a.Chat(t.Context(), &ChatRequest{Message: fmt.Sprintf("message %d", i), ConversationID: id})
+		})
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if n := overlap.Load(); n != 1 {
+		t.Fatalf("requests of the same conversation overlapped: %d at once", n)
+	}
+	if n := checkTurns(t, a, id); n != 3 {
+		t.Fatalf("got %d turns, want 3", n)
+	}
+}
+
+func TestConcurrentChatNoWait(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	a := newTestAgent(t, blockingReply(started, release), "conversation:\n  queue_timeout: 5s\n")
+	id := "no-wait"
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := a.Chat(t.Context(), &ChatRequest{Message: "first", ConversationID: id})
+		done <- err
+	}()
+	<-started
+
+	_, err := a.Chat(t.Context(), &ChatRequest{Message: "second", ConversationID: id, NoWait: true})
+	if !errors.Is(err, ErrConversationBusy) {
+		t.Fatalf("got %v, want ErrConversationBusy", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := checkTurns(t, a, id); n != 1 {
+		t.Fatalf("got %d turns, want 1", n)
+	}
+}
+
+func TestConcurrentChatQueueTimeout(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	a := newTestAgent(t, blockingReply(started, release), "conversation:\n  concurrency: queue\n  queue_timeout: 100ms\n")
+	id := "timeout"
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := a.Chat(t.Context(), &ChatRequest{Message: "first", ConversationID: id})
+		done <- err
+	}()
+	<-started
+
+	begin := time.Now()
+	_, err := a.Chat(t.Context(), &ChatRequest{Message: "second", ConversationID: id})
+	if !errors.Is(err, ErrConversationBusy) {
+		t.Fatalf("got %v, want ErrConversationBusy", err)
+	}
+	if waited := time.Since(begin); waited < 100*time.Millisecond {
+		t.Fatalf("returned after %s, want to wait for queue_timeout", waited)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := checkTurns(t, a, id); n != 1 {
+		t.Fatalf("got %d turns, want 1", n)
+	}
+}
+
+func TestConcurrentChatNewConversation(t *testing.T) {
+	var overlap atomic.Int32
+	a := newTestAgent(t, serialReply(50*time.Millisecond, &overlap), "conversation:\n  concurrency: queue\n  queue_timeout: 5s\n")
+	id := "new-conversation"
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Go(func() {
+			_, errs[i] = a.Chat(t.Context(), &ChatRequest{Message: fmt.Sprintf("message %d", i), ConversationID: id})
+		})
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if n := overlap.Load(); n != 1 {
+		t.Fatalf("requests of the same conversation overlapped: %d at once", n)
+	}
+	if n := checkTurns(t, a, id); n != 2 {
+		t.Fatalf("got %d turns, want 2 (one of the requests used a different conversation)", n)
+	}
+}
+
+func TestGetOrCreateConversationConcurrent(t *testing.T) {
+	a := newTestAgent(t, echo, "")
+	convs := make([]*Conversation, 16)
+	var wg sync.WaitGroup
+	for i := range convs {
+		wg.Go(func() {
+			conv, err := a.getOrCreateConversation(t.Context(), "shared")
+			if err != nil {
+				t.Error(err)
+			}
+			convs[i] = conv
+		})
+	}
+	wg.Wait()
+	for i, conv := range convs {
+		if conv != convs[0] {
+			t.Fatalf("caller %d got a different conversation", i)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package agent
+
+// EventType 对话事件类型
+type EventType string
+
+const (
+	// EventToolCall 模型发起工具调用
+	EventToolCall EventType = "tool_call"
+	// EventToolResult 工具执行完成
+	EventToolResult EventType = "tool_result"
+	// EventReasoning 模型输出思考过程（reasoning.hide 为 true 时不触发）
+	EventReasoning EventType = "reasoning"
+	// EventEscalation 小模型的回答未通过检查，改由大模型重新回答，Content 为原因
+	EventEscalation EventType = "escalation"
+	// EventMessage 模型输出最终回答
+	EventMessage EventType = "message"
+)
+
+// Event 对话循环中产生的事件，用于实时展示工具调用进度
+type Event struct {
+	Type      EventType      `json:"type"`
+	Tool      string         `json:"tool,omitempty"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	Result    string         `json:"result,omitempty"`
+	Content   string         `json:"content,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// EventHandler 事件回调
+type EventHandler func(Event)
+
+// emit 触发事件回调（handler 为空时忽略）
+func (h EventHandler) emit(ev Event) {
+	if h != nil {
+		h(ev)
+	}
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/champly/ai-agent/pkg/experiment"
+)
+
+// assignExperiment 返回请求使用的对话 ID 和分配到的实验变体。新对话预先分配 ID，
+// 变体按对话 ID 分配，同一对话的后续请求始终使用同一变体
+func (a *Agent) assignExperiment(req *ChatRequest) (string, experiment.Assignment) {
+	id := req.ConversationID
+	if id == "" {
+		id = generateConversationID()
+	}
+	return id, a.experiments.Assign(req.Template, id)
+}
+
+// recordExperiment 记录本轮在实验中的结果并在响应中标注变体，被停止或取消的请求不计入统计
+func (a *Agent) recordExperiment(conv *Conversation, assign experiment.Assignment, resp *ChatResponse, err error) {
+	conv.setAssignment(assign)
+	if assign.Experiment == "" || errors.Is(err, context.Canceled) {
+		return
+	}
+	var iterations, toolCalls int
+	if resp != nil {
+		resp.Experiment, resp.Variant = assign.Experiment, assign.Variant
+		iterations, toolCalls = resp.Iterations, len(resp.ToolCalls)
+	}
+	a.experiments.Record(assign, iterations, toolCalls, err)
+}
+
+// RateConversation 记录用户对对话最近一轮回答的反馈（1 为正面，-1 为负面），计入该轮分配到的实验变体，
+// 同一对话重复提交时以最后一次为准。对话不存在时返回 store.ErrNotFound，属于其他用户时返回 ErrConversationForbidden，
+// 最近一轮没有参与实验时返回 experiment.ErrNotAssigned
+func (a *Agent) RateConversation(ctx context.Context, id string, rating int) error {
+	if rating != 1 && rating != -1 {
+		return fmt.Errorf("invalid rating %d, must be 1 or -1", rating)
+	}
+	val, ok := a.conversations.Load(id)
+	if !ok {
+		if _, err := a.GetConversation(ctx, id); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s", experiment.ErrNotAssigned, id)
+	}
+	conv := val.(*Conversation)
+	if err := checkOwner(conv.User, UserFromContext(ctx)); err != nil {
+		return err
+	}
+	assign, previous, err := conv.rate(rating)
+	if err != nil {
+		return err
+	}
+	a.experiments.Feedback(assign, rating, previous)
+	return nil
+}
+
+// Experiments 返回所有提示实验的统计，未配置时为空
+func (a *Agent) Experiments() []experiment.Report {
+	return a.experiments.Report()
+}
@@ -0,0 +1,474 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/github"
+	"github.com/champly/ai-agent/pkg/jobqueue"
+	"github.com/champly/ai-agent/pkg/webhook"
+)
+
+const (
+	// githubSource GitHub 工具的来源，工具范围的 mcp_servers 中写 github 表示这些工具
+	githubSource = "github"
+	// githubDedupWindow 同一提交的重复事件（重投、reopened）在该时间内只审查一次
+	githubDedupWindow = 24 * time.Hour
+)
+
+// GitHub 工具名
+const (
+	githubPullRequestTool = "github_pull_request"
+	githubDiffTool        = "github_pull_request_diff"
+	githubFileTool        = "github_file"
+)
+
+// reviewSchema 审查结果的 JSON Schema
+var reviewSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "summary": {"type": "string", "description": "审查总结：整体评价和主要问题"},
+    "comments": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "path": {"type": "string", "description": "文件路径"},
+          "line": {"type": "integer", "description": "新文件中的行号，必须是 diff 中新增或未改动的行"},
+          "body": {"type": "string", "description": "评论内容"}
+        },
+        "required": ["path", "line", "body"]
+      }
+    }
+  },
+  "required": ["summary", "comments"]
+}`)
+
+// githubReview 审查任务在队列中保存的内容
+type githubReview struct {
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+}
+
+// reviewResult 模型返回的审查结果
+type reviewResult struct {
+	Summary  string `json:"summary"`
+	Comments []struct {
+		Path string `json:"path"`
+		Line int    `json:"line"`
+		Body string `json:"body"`
+	} `json:"comments"`
+}
+
+// githubReviewer GitHub 客户端和已提交审查的提交
+type githubReviewer struct {
+	cfg    config.GitHubConfig
+	client *github.Client
+
+	mu   sync.Mutex
+	seen map[string]time.Time // 仓库#编号@提交 -> 提交审查的时间
+}
+
+// claim 记录提交的审查，githubDedupWindow 内已提交过时返回 false
+func (r *githubReviewer) claim(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for k, at := range r.seen {
+		if now.Sub(at) >= githubDedupWindow {
+			delete(r.seen, k)
+		}
+	}
+	if _, ok := r.seen[key]; ok {
+		return false
+	}
+	r.seen[key] = now
+	return true
+}
+
+// forget 删除提交的审查记录
+func (r *githubReviewer) forget(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.seen, key)
+}
+
+// checkRepo 仓库是否允许访问
+func (r *githubReviewer) checkRepo(repo string) error {
+	if !github.RepoAllowed(r.cfg.Repositories, repo) {
+		return fmt.Errorf("repository %s is not allowed", repo)
+	}
+	return nil
+}
+
+// files 拉取请求中在审查范围内的文件，以及范围外被跳过的文件数
+func (r *githubReviewer) files(ctx context.Context, repo string, number int) ([]github.File, int, error) {
+	all, err := r.client.Files(ctx, repo, number, r.cfg.MaxFiles)
+	if err != nil {
+		return nil, 0, err
+	}
+	files := slices.DeleteFunc(all, func(f github.File) bool { return !github.PathAllowed(r.cfg.Paths, f.Filename) })
+	return files, len(all) - len(files), nil
+}
+
+// HandleGitHubWebhook 校验 GitHub webhook 请求，允许的仓库中符合 actions 的拉取请求事件提交为一个审查任务。
+// 不需要处理的事件返回 nil；未启用时返回 webhook.ErrNotFound
+func (a *Agent) HandleGitHubWebhook(ctx context.Context, header http.Header, body []byte) (*Task, error) {
+	r := a.github
+	if r == nil {
+		return nil, fmt.Errorf("%w: github", webhook.ErrNotFound)
+	}
+	if !github.VerifySignature(r.cfg.WebhookSecret, header.Get("X-Hub-Signature-256"), body) {
+		return nil, webhook.ErrUnauthorized
+	}
+	if header.Get("X-GitHub-Event") != "pull_request" {
+		return nil, nil
+	}
+
+	var event struct {
+		Action      string `json:"action"`
+		PullRequest struct {
+			Number int    `json:"number"`
+			Draft  bool   `json:"draft"`
+			State  string `json:"state"`
+			Head   struct {
+				SHA string `json:"sha"`
+			} `json:"head"`
+		} `json:"pull_request"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("%w: %v", webhook.ErrInvalidPayload, err)
+	}
+	pr, repo := event.PullRequest, event.Repository.FullName
+	switch {
+	case !slices.Contains(r.cfg.Actions, event.Action), pr.State != "open", pr.Draft && !r.cfg.Drafts:
+		klog.V(2).InfoS("GitHub event ignored", "repo", repo, "number", pr.Number, "action", event.Action)
+		return nil, nil
+	case r.checkRepo(repo) != nil:
+		klog.InfoS("GitHub event from unlisted repository ignored", "repo", repo, "number", pr.Number)
+		return nil, nil
+	}
+	key := fmt.Sprintf("%s#%d@%s", repo, pr.Number, pr.Head.SHA)
+	if !r.claim(key) {
+		klog.V(2).InfoS("GitHub pull request already reviewed", "repo", repo, "number", pr.Number, "sha", pr.Head.SHA)
+		return nil, nil
+	}
+
+	ctx = WithToolProfile(WithUser(ctx, r.cfg.User), &r.cfg.ToolProfile)
+	task, err := a.submitTask(ctx, reviewJobKind, &taskPayload{
+		Request: ChatRequest{ConversationID: reviewConversationID(repo, pr.Number)},
+		Review:  &githubReview{Repo: repo, Number: pr.Number},
+	}, nil, time.Time{})
+	if err != nil {
+		// GitHub 重新投递时再次提交
+		r.forget(key)
+		return nil, err
+	}
+	klog.InfoS("GitHub pull request review queued", "repo", repo, "number", pr.Number, "taskID", task.ID)
+	return task, nil
+}
+
+// reviewConversationID 拉取请求的审查对话，同一拉取请求的多次审查（如 synchronize）继续同一对话
+func reviewConversationID(repo string, number int) string {
+	return safeConnectorID(fmt.Sprintf("github-%s-%d", strings.ReplaceAll(repo, "/", "-"), number))
+}
+
+// reviewPullRequest 以配置的人设审查拉取请求：模型通过 GitHub 工具读取改动，按 reviewSchema 返回审查结果，
+// 能定位到 diff 中的评论作为行内评论，其余并入审查总结
+func (a *Agent) reviewPullRequest(ctx context.Context, review *githubReview, conversationID string, onEvent EventHandler) (*ChatResponse, error) {
+	r := a.github
+	if r == nil {
+		return nil, jobqueue.Permanent(errors.New("github is not enabled"))
+	}
+	if err := r.checkRepo(review.Repo); err != nil {
+		return nil, jobqueue.Permanent(err)
+	}
+	pr, err := r.client.PullRequest(ctx, review.Repo, review.Number)
+	if err != nil {
+		return nil, err
+	}
+	if pr.State != "open" {
+		return nil, jobqueue.Permanent(fmt.Errorf("pull request %s#%d is %s", review.Repo, review.Number, pr.State))
+	}
+	files, skipped, err := r.files(ctx, review.Repo, review.Number)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		klog.InfoS("No files in review scope", "repo", review.Repo, "number", review.Number, "skipped", skipped)
+		return &ChatResponse{Response: "没有需要审查的文件"}, nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "请审查 GitHub 拉取请求 %s#%d：%s\n", review.Repo, review.Number, pr.Title)
+	fmt.Fprintf(&sb, "作者 %s，%s → %s\n", pr.User.Login, pr.Head.Ref, pr.Base.Ref)
+	if body := strings.TrimSpace(pr.Body); body != "" {
+		fmt.Fprintf(&sb, "描述：\n%s\n", truncateRunes(body, 2000))
+	}
+	fmt.Fprintf(&sb, "\n需要审查的文件（%d 个）：\n", len(files))
+	for _, f := range files {
+		fmt.Fprintf(&sb, "- %s（%s，+%d -%d）\n", f.Filename, f.Status, f.Additions, f.Deletions)
+	}
+	fmt.Fprintf(&sb, "\n先调用 %s 读取改动，需要上下文时调用 %s 读取文件（ref 为 %s）。"+
+		"只指出改动中真实存在的问题（缺陷、安全、并发、错误处理、可维护性），不要复述改动内容。"+
+		"行内评论的 line 为新文件中的行号，只能是 diff 中新增或未改动的行；没有问题时 comments 为空数组。",
+		githubDiffTool, githubFileTool, pr.Head.SHA)
+
+	resp, err := a.Chat(ctx, &ChatRequest{
+		Message:        sb.String(),
+		ConversationID: conversationID,
+		Model:          r.cfg.Model,
+		Persona:        r.cfg.Persona,
+		Schema:         reviewSchema,
+		OnEvent:        onEvent,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result reviewResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("parse review result: %w", err)
+	}
+
+	// 只有 diff 中的行能发表行内评论，否则 GitHub 拒绝整个审查
+	commentable := make(map[string]map[int]bool, len(files))
+	for _, f := range files {
+		commentable[f.Filename] = github.CommentableLines(f.Patch)
+	}
+	out := &github.Review{CommitID: pr.Head.SHA, Event: "COMMENT"}
+	var others []string
+	for _, c := range result.Comments {
+		if strings.TrimSpace(c.Body) == "" {
+			continue
+		}
+		if commentable[c.Path][c.Line] && len(out.Comments) < r.cfg.MaxComments {
+			out.Comments = append(out.Comments, github.ReviewComment{Path: c.Path, Line: c.Line, Side: "RIGHT", Body: c.Body})
+			continue
+		}
+		others = append(others, fmt.Sprintf("- `%s:%d` %s", c.Path, c.Line, c.Body))
+	}
+	body := strings.TrimSpace(result.Summary)
+	if len(others) > 0 {
+		body += "\n\n**其他意见**\n" + strings.Join(others, "\n")
+	}
+	footer := "模型 " + resp.Model
+	if resp.Persona != "" {
+		footer += " · 人设 " + resp.Persona
+	}
+	if skipped > 0 {
+		footer += fmt.Sprintf(" · %d 个文件不在审查范围内", skipped)
+	}
+	out.Body = body + "\n\n<sub>自动审查 · " + footer + "</sub>"
+
+	if err := r.client.CreateReview(ctx, review.Repo, review.Number, out); err != nil {
+		return nil, fmt.Errorf("post review: %w", err)
+	}
+	klog.InfoS("GitHub pull request reviewed", "repo", review.Repo, "number", review.Number, "comments", len(out.Comments), "other", len(others))
+	return resp, nil
+}
+
+// truncateRunes 截断到 n 个字符
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
+
+// newGitHubTools 创建读取拉取请求、diff 和文件内容的工具，只能访问 repositories 中的仓库和 paths 中的文件
+func newGitHubTools(r *githubReviewer) []*ToolInfo {
+	repoProp := map[string]any{"type": "string", "description": "仓库，格式为 owner/repo"}
+	numberProp := map[string]any{"type": "integer", "description": "拉取请求编号"}
+	openWorld := true
+	annotations := &mcp.ToolAnnotations{ReadOnlyHint: true, OpenWorldHint: &openWorld}
+	return []*ToolInfo{
+		{
+			Name:   githubPullRequestTool,
+			Source: githubSource,
+			MCPTool: &mcp.Tool{
+				Name:        githubPullRequestTool,
+				Description: "读取 GitHub 拉取请求的标题、描述、分支和改动文件列表",
+				InputSchema: map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"repo": repoProp, "number": numberProp},
+					"required":   []any{"repo", "number"},
+				},
+				Annotations: annotations,
+			},
+			Executor: &githubPullRequestExecutor{r: r},
+		},
+		{
+			Name:   githubDiffTool,
+			Source: githubSource,
+			MCPTool: &mcp.Tool{
+				Name:        githubDiffTool,
+				Description: "读取 GitHub 拉取请求的改动（统一 diff 格式），内容较多时分段返回，按提示传入 offset 继续读取；指定 path 时只返回该文件的改动",
+				InputSchema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"repo":   repoProp,
+						"number": numberProp,
+						"path":   map[string]any{"type": "string", "description": "只读取该文件的改动"},
+						"offset": map[string]any{"type": "integer", "description": "从第几个文件开始读取（从 0 开始）"},
+					},
+					"required": []any{"repo", "number"},
+				},
+				Annotations: annotations,
+			},
+			Executor: &githubDiffExecutor{r: r},
+		},
+		{
+			Name:   githubFileTool,
+			Source: githubSource,
+			MCPTool: &mcp.Tool{
+				Name:        githubFileTool,
+				Description: "读取 GitHub 仓库中的文件内容，用于了解改动的上下文",
+				InputSchema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"repo": repoProp,
+						"path": map[string]any{"type": "string", "description": "文件路径"},
+						"ref":  map[string]any{"type": "string", "description": "分支、标签或提交，默认为默认分支"},
+					},
+					"required": []any{"repo", "path"},
+				},
+				Annotations: annotations,
+			},
+			Executor: &githubFileExecutor{r: r},
+		},
+	}
+}
+
+// githubPullRequestExecutor github_pull_request 工具执行器
+type githubPullRequestExecutor struct {
+	r *githubReviewer
+}
+
+// Execute 执行工具
+func (e *githubPullRequestExecutor) Execute(ctx context.Context, args map[string]any) (string, error) {
+	repo, _ := args["repo"].(string)
+	number := int(intArg(args, "number"))
+	if err := e.r.checkRepo(repo); err != nil {
+		return "", err
+	}
+	pr, err := e.r.client.PullRequest(ctx, repo, number)
+	if err != nil {
+		return "", err
+	}
+	files, skipped, err := e.r.files(ctx, repo, number)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s#%d %s\n状态：%s", repo, number, pr.Title, pr.State)
+	if pr.Draft {
+		sb.WriteString("（草稿）")
+	}
+	fmt.Fprintf(&sb, "\n作者：%s\n分支：%s → %s\n提交：%s\n链接：%s\n", pr.User.Login, pr.Head.Ref, pr.Base.Ref, pr.Head.SHA, pr.HTMLURL)
+	if body := strings.TrimSpace(pr.Body); body != "" {
+		fmt.Fprintf(&sb, "\n%s\n", truncateRunes(body, 4000))
+	}
+	fmt.Fprintf(&sb, "\n改动文件（%d 个）：\n", len(files))
+	for _, f := range files {
+		fmt.Fprintf(&sb, "- %s（%s，+%d -%d）\n", f.Filename, f.Status, f.Additions, f.Deletions)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(&sb, "另有 %d 个文件不在审查范围内\n", skipped)
+	}
+	return sb.String(), nil
+}
+
+// githubDiffExecutor github_pull_request_diff 工具执行器
+type githubDiffExecutor struct {
+	r *githubReviewer
+}
+
+// Execute 执行工具
+func (e *githubDiffExecutor) Execute(ctx context.Context, args map[string]any) (string, error) {
+	repo, _ := args["repo"].(string)
+	number := int(intArg(args, "number"))
+	path, _ := args["path"].(string)
+	offset := max(int(intArg(args, "offset")), 0)
+	if err := e.r.checkRepo(repo); err != nil {
+		return "", err
+	}
+	files, _, err := e.r.files(ctx, repo, number)
+	if err != nil {
+		return "", err
+	}
+	if path != "" {
+		files = slices.DeleteFunc(files, func(f github.File) bool { return f.Filename != path })
+		if len(files) == 0 {
+			return "", fmt.Errorf("%s is not changed in this pull request or is out of review scope", path)
+		}
+	}
+	if offset >= len(files) {
+		return fmt.Sprintf("没有更多改动（共 %d 个文件）", len(files)), nil
+	}
+
+	limit := e.r.cfg.MaxDiffBytes
+	var sb strings.Builder
+	next := offset
+	for ; next < len(files); next++ {
+		f := files[next]
+		patch := f.Patch
+		if patch == "" {
+			patch = "（二进制文件或改动过大，无法显示 diff）"
+		}
+		section := fmt.Sprintf("### %s（%s）\n%s\n\n", f.Filename, f.Status, patch)
+		if sb.Len()+len(section) > limit {
+			if sb.Len() > 0 {
+				break
+			}
+			// 单个文件超过上限时截断
+			section = strings.ToValidUTF8(section[:limit], "") + "\n（改动过长，已截断）\n\n"
+		}
+		sb.WriteString(section)
+	}
+	if next < len(files) {
+		fmt.Fprintf(&sb, "还有 %d 个文件的改动，传入 offset=%d 继续读取", len(files)-next, next)
+	}
+	return sb.String(), nil
+}
+
+// githubFileExecutor github_file 工具执行器
+type githubFileExecutor struct {
+	r *githubReviewer
+}
+
+// Execute 执行工具
+func (e *githubFileExecutor) Execute(ctx context.Context, args map[string]any) (string, error) {
+	repo, _ := args["repo"].(string)
+	path, _ := args["path"].(string)
+	ref, _ := args["ref"].(string)
+	if err := e.r.checkRepo(repo); err != nil {
+		return "", err
+	}
+	if path == "" || !github.PathAllowed(e.r.cfg.Paths, path) {
+		return "", fmt.Errorf("path %q is not allowed", path)
+	}
+	data, err := e.r.client.Content(ctx, repo, path, ref)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > e.r.cfg.MaxDiffBytes {
+		return strings.ToValidUTF8(string(data[:e.r.cfg.MaxDiffBytes]), "") + "\n（文件过长，已截断）", nil
+	}
+	return string(data), nil
+}
@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/gitsync"
+	"github.com/champly/ai-agent/pkg/priority"
+)
+
+// gitSource 同步到知识库的 git 仓库
+type gitSource struct {
+	cfg  config.GitSourceConfig
+	repo *gitsync.Repo
+	// synced 最近一次全部文件都导入成功的提交，远端没有新提交时跳过同步
+	synced string
+}
+
+// registerGitSources 为每个 git 来源注册仅在主节点执行的同步任务
+func (a *Agent) registerGitSources() {
+	for _, cfg := range a.cfg.RAG.GitSources {
+		src := &gitSource{cfg: cfg, repo: gitsync.New(cfg, filepath.Join(a.cfg.RAG.GitDir, cfg.Name))}
+		a.leader.Register("git-sync-"+cfg.Name, func(ctx context.Context) { a.runGitSource(ctx, src) })
+	}
+}
+
+// runGitSource 立即同步一次，之后按 interval 定期同步，直到 ctx 结束
+func (a *Agent) runGitSource(ctx context.Context, src *gitSource) {
+	klog.InfoS("Git source sync started", "source", src.cfg.Name, "repo", src.repo.URL(), "interval", src.cfg.Interval)
+	ticker := time.NewTicker(src.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := a.syncGitSource(ctx, src); err != nil && ctx.Err() == nil {
+			klog.ErrorS(err, "Failed to sync git source", "source", src.cfg.Name)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncGitSource 拉取最新提交，与集合中已导入的文件比较内容的对象 SHA：新增和修改的文件重新导入，
+// 仓库中已删除的文件从集合中删除。导入失败的文件在下一次同步时重试
+func (a *Agent) syncGitSource(ctx context.Context, src *gitSource) (err error) {
+	// 同步的嵌入请求让位于聊天
+	ctx = priority.WithClass(ctx, priority.Background)
+	commit, err := src.repo.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+	if commit == src.synced {
+		klog.V(2).InfoS("Git source is up to date", "source", src.cfg.Name, "commit", commit)
+		return nil
+	}
+	files, err := src.repo.Files(ctx, commit)
+	if err != nil {
+		return err
+	}
+
+	// 文档 ID 为 <name>/<path>，只比较本来源导入的文档
+	collection := src.cfg.Collection
+	prefix := src.cfg.Name + "/"
+	indexed := make(map[string]string)
+	for id, metadata := range a.rag.Documents(collection) {
+		if strings.HasPrefix(id, prefix) {
+			indexed[id] = metadata["blob"]
+		}
+	}
+	var changed []gitsync.File
+	var stale []string
+	for _, f := range files {
+		id := prefix + f.Path
+		blob, ok := indexed[id]
+		delete(indexed, id)
+		if ok && blob == f.Blob {
+			continue
+		}
+		changed = append(changed, f)
+		if ok {
+			stale = append(stale, id)
+		}
+	}
+	deleted := slices.Sorted(maps.Keys(indexed))
+	stale = append(stale, deleted...)
+
+	loaded, failed := 0, 0
+	defer func() {
+		a.auditRAGWrite(ctx, collection, map[string]any{
+			"source": "git:" + src.cfg.Name, "commit": commit, "documents": loaded, "deleted": len(deleted),
+		}, "", err)
+	}()
+
+	if len(stale) > 0 {
+		if _, err := a.rag.RemoveDocuments(ctx, collection, stale); err != nil {
+			return err
+		}
+		for _, id := range deleted {
+			a.forgetDocument(collection, id)
+		}
+	}
+	for _, f := range changed {
+		if ctx.Err() != nil {
+			break
+		}
+		id := prefix + f.Path
+		metadata := map[string]string{
+			"source": f.Path,
+			"file":   path.Base(f.Path),
+			"repo":   src.repo.URL(),
+			"commit": commit,
+			"blob":   f.Blob,
+		}
+		if src.cfg.Branch != "" {
+			metadata["branch"] = src.cfg.Branch
+		}
+		content, err := src.repo.ReadFile(ctx, f)
+		if err == nil {
+			err = a.rag.AddDocument(ctx, collection, id, content, metadata)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			klog.ErrorS(err, "Failed to ingest file from git source", "source", src.cfg.Name, "path", f.Path)
+			failed++
+			continue
+		}
+		a.extractDocument(collection, id, content)
+		loaded++
+	}
+
+	if len(stale) > 0 || loaded > 0 {
+		if saveErr := a.saveRAG(); saveErr != nil {
+			klog.ErrorS(saveErr, "Failed to save RAG store")
+		}
+	}
+	klog.InfoS("Git source synced", "source", src.cfg.Name, "collection", collection, "commit", commit,
+		"files", len(files), "ingested", loaded, "deleted", len(deleted), "failed", failed)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to ingest %d of %d changed files at %s", failed, len(changed), commit)
+	}
+	src.synced = commit
+	return nil
+}
@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/champly/ai-agent/pkg/tools"
+)
+
+// goToolSource 以 RegisterTool 注册的 Go 函数工具的来源，工具范围的 mcp_servers 中写 go 表示这些工具
+const goToolSource = "go"
+
+// ErrToolExists 注册的工具与已有工具重名
+var ErrToolExists = errors.New("tool already registered")
+
+// RegisterTool 注册由 Go 函数实现的工具（见 pkg/tools），与已有工具重名时返回 ErrToolExists。
+// 与 MCP 工具一样经过租户和人设的工具范围、权限策略、配额、内容过滤和审计
+func (a *Agent) RegisterTool(tool *tools.Tool) error {
+	info := &ToolInfo{
+		Name:   tool.Name(),
+		Source: goToolSource,
+		MCPTool: &mcp.Tool{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			InputSchema: tool.InputSchema(),
+		},
+		Executor: goToolExecutor{tool: tool},
+	}
+	if !a.toolRegistry.Add(info) {
+		return fmt.Errorf("%w: %s", ErrToolExists, tool.Name())
+	}
+	return nil
+}
+
+// goToolExecutor Go 函数工具执行器
+type goToolExecutor struct {
+	tool *tools.Tool
+}
+
+// Execute 执行工具
+func (e goToolExecutor) Execute(ctx context.Context, args map[string]any) (string, error) {
+	return e.tool.Call(ctx, args)
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/toolprovider"
+)
+
+// grpcSourcePrefix 远程 gRPC 工具的来源前缀，后接服务名称
+const grpcSourcePrefix = "grpc:"
+
+// registerGRPCTools 注册远程 gRPC 服务提供的工具，与已有工具重名时跳过
+func (a *Agent) registerGRPCTools() {
+	for _, p := range a.toolProviders.Providers() {
+		for _, spec := range p.Tools() {
+			var schema map[string]any
+			if err := json.Unmarshal([]byte(spec.GetInputSchema()), &schema); err != nil {
+				klog.ErrorS(err, "Invalid gRPC tool schema, skipping", "provider", p.Name(), "tool", spec.GetName())
+				continue
+			}
+			info := &ToolInfo{
+				Name:   spec.GetName(),
+				Source: grpcSourcePrefix + p.Name(),
+				MCPTool: &mcp.Tool{
+					Name:        spec.GetName(),
+					Description: spec.GetDescription(),
+					InputSchema: schema,
+				},
+				Executor: &grpcToolExecutor{provider: p, tool: spec.GetName()},
+			}
+			if !a.toolRegistry.Add(info) {
+				klog.InfoS("gRPC tool conflicts with an existing tool, skipping", "provider", p.Name(), "tool", spec.GetName())
+			}
+		}
+	}
+}
+
+// grpcToolExecutor 远程 gRPC 工具执行器
+type grpcToolExecutor struct {
+	provider *toolprovider.Provider
+	tool     string
+}
+
+// Execute 执行工具
+func (e *grpcToolExecutor) Execute(ctx context.Context, args map[string]any) (string, error) {
+	return e.provider.Call(ctx, e.tool, args)
+}
@@ -0,0 +1,328 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/priority"
+	"github.com/champly/ai-agent/pkg/rag"
+)
+
+// maxIngestErrors 导入进度中保留的失败记录数
+const maxIngestErrors = 20
+
+var (
+	// ErrIngestJobNotFound 导入任务不存在、已过期或属于其他用户
+	ErrIngestJobNotFound = errors.New("ingest job not found")
+	// ErrTooManyIngestJobs 进行中的导入任务数达到 rag.ingest_jobs
+	ErrTooManyIngestJobs = errors.New("too many active ingest jobs")
+)
+
+// IngestProgress 导入进度
+type IngestProgress struct {
+	Files       int      `json:"files"`            // 待导入的文件数，URL 为 1
+	FilesDone   int      `json:"files_done"`       // 已处理的文件数（含失败）
+	FilesFailed int      `json:"files_failed"`     // 导入失败的文件数
+	Chunks      int      `json:"chunks"`           // 已嵌入的分块数
+	Errors      []string `json:"errors,omitempty"` // 失败的文件及原因，最多保留 20 条
+}
+
+// IngestJob 后台导入任务的状态快照
+type IngestJob struct {
+	ID         string         `json:"id"`
+	Source     string         `json:"source"`
+	Collection string         `json:"collection"`
+	Status     TaskStatus     `json:"status"`
+	CreatedAt  time.Time      `json:"created_at"`
+	FinishedAt time.Time      `json:"finished_at,omitzero"`
+	Progress   IngestProgress `json:"progress"`
+	Documents  int            `json:"documents"` // 成功导入的文档数
+	Error      string         `json:"error,omitempty"`
+
+	// Err 失败原因，供调用方区分错误类型（不参与序列化）
+	Err error `json:"-"`
+}
+
+// ingestJob 导入任务的内部状态
+type ingestJob struct {
+	user   string
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	info     IngestJob
+	canceled bool
+}
+
+// update 更新进度，job 为 nil（同步导入）时忽略
+func (j *ingestJob) update(fn func(p *IngestProgress)) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.info.Progress)
+}
+
+// snapshot 返回状态快照
+func (j *ingestJob) snapshot() *IngestJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := j.info
+	info.Progress.Errors = slices.Clone(info.Progress.Errors)
+	return &info
+}
+
+// ingestManager 后台导入任务管理
+type ingestManager struct {
+	limit  int
+	ttl    time.Duration
+	ctx    context.Context // 服务停止时取消所有导入
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	jobs   map[string]*ingestJob
+	active int
+}
+
+// newIngestManager 创建导入任务管理器，结束的任务与后台任务保留相同的时间
+func newIngestManager(limit int, ttl time.Duration) *ingestManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ingestManager{
+		limit:  limit,
+		ttl:    ttl,
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(map[string]*ingestJob),
+	}
+}
+
+// sweepLocked 删除结束超过 ttl 的任务
+func (m *ingestManager) sweepLocked() {
+	for id, j := range m.jobs {
+		info := j.snapshot()
+		if info.Status != TaskRunning && time.Since(info.FinishedAt) > m.ttl {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// lookup 查找 context 中用户的导入任务
+func (m *ingestManager) lookup(ctx context.Context, id string) (*ingestJob, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok || j.user != UserFromContext(ctx) {
+		return nil, fmt.Errorf("%w: %s", ErrIngestJobNotFound, id)
+	}
+	return j, nil
+}
+
+// close 取消所有进行中的导入
+func (m *ingestManager) close() {
+	m.cancel()
+}
+
+// StartIngest 在后台将文件、目录或 URL 导入指定集合，立即返回任务；权限在提交时检查。
+// 取消或服务停止时已导入的文档保留
+func (a *Agent) StartIngest(ctx context.Context, collection, target string) (*IngestJob, error) {
+	if _, err := a.ingestCollection(ctx, collection, target); err != nil {
+		return nil, err
+	}
+
+	m := a.ingests
+	m.mu.Lock()
+	m.sweepLocked()
+	if m.active >= m.limit {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("%w: at most %d allowed", ErrTooManyIngestJobs, m.limit)
+	}
+	m.active++
+
+	// 保留用户身份，但不随提交请求的连接取消
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(m.ctx, cancel)
+
+	j := &ingestJob{
+		user:   UserFromContext(ctx),
+		cancel: cancel,
+		info: IngestJob{
+			ID:         uuid.New().String(),
+			Source:     target,
+			Collection: collection,
+			Status:     TaskRunning,
+			CreatedAt:  time.Now(),
+		},
+	}
+	m.jobs[j.info.ID] = j
+	m.mu.Unlock()
+
+	klog.InfoS("Ingest job started", "jobID", j.info.ID, "source", target, "collection", collection)
+
+	go func() {
+		defer func() {
+			stop()
+			cancel()
+			m.mu.Lock()
+			m.active--
+			m.mu.Unlock()
+		}()
+
+		loaded, err := a.ingestRAG(jobCtx, collection, target, j)
+
+		j.mu.Lock()
+		j.info.FinishedAt = time.Now()
+		j.info.Documents = loaded
+		switch {
+		case err == nil:
+			j.info.Status = TaskSucceeded
+		case j.canceled || m.ctx.Err() != nil:
+			j.info.Status = TaskCanceled
+		default:
+			j.info.Status = TaskFailed
+		}
+		if err != nil {
+			j.info.Error = err.Error()
+			j.info.Err = err
+		}
+		info := j.info
+		j.mu.Unlock()
+
+		klog.InfoS("Ingest job finished", "jobID", info.ID, "status", info.Status, "documents", loaded,
+			"chunks", info.Progress.Chunks, "failed", info.Progress.FilesFailed, "duration", info.FinishedAt.Sub(info.CreatedAt))
+	}()
+
+	return j.snapshot(), nil
+}
+
+// GetIngest 返回 context 中用户的导入任务状态
+func (a *Agent) GetIngest(ctx context.Context, id string) (*IngestJob, error) {
+	j, err := a.ingests.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return j.snapshot(), nil
+}
+
+// ListIngests 列出 context 中用户的导入任务，按提交时间从新到旧排列
+func (a *Agent) ListIngests(ctx context.Context) []*IngestJob {
+	user := UserFromContext(ctx)
+	m := a.ingests
+	m.mu.Lock()
+	m.sweepLocked()
+	result := make([]*IngestJob, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if j.user == user {
+			result = append(result, j.snapshot())
+		}
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(result, func(x, y *IngestJob) int {
+		return y.CreatedAt.Compare(x.CreatedAt)
+	})
+	return result
+}
+
+// CancelIngest 取消进行中的导入任务，已导入的文档保留
+func (a *Agent) CancelIngest(ctx context.Context, id string) (*IngestJob, error) {
+	j, err := a.ingests.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	if j.info.Status == TaskRunning {
+		j.canceled = true
+	}
+	j.mu.Unlock()
+	j.cancel()
+	return j.snapshot(), nil
+}
+
+// ingestCollection 检查租户权限并返回实际写入的集合；租户用户只能导入 URL
+func (a *Agent) ingestCollection(ctx context.Context, collection, target string) (string, error) {
+	tenant, err := a.TenantFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	if tenant != nil && !rag.IsURL(target) {
+		return "", fmt.Errorf("%w: only urls can be ingested", ErrTenantForbidden)
+	}
+	return a.tenantCollection(ctx, collection, false)
+}
+
+// ingestRAG 导入文件、目录或 URL，job 不为 nil 时报告进度；ctx 取消时停止导入后续文件并保存已导入的部分
+func (a *Agent) ingestRAG(ctx context.Context, collection, target string, job *ingestJob) (loaded int, err error) {
+	defer func() {
+		a.auditRAGWrite(ctx, collection, map[string]any{"source": target, "documents": loaded}, "", err)
+	}()
+
+	if collection, err = a.ingestCollection(ctx, collection, target); err != nil {
+		return 0, err
+	}
+	// 导入的嵌入请求让位于聊天
+	ctx = priority.WithClass(ctx, priority.Background)
+
+	var sources []rag.Source
+	if rag.IsURL(target) {
+		job.update(func(p *IngestProgress) { p.Files = 1 })
+		src, err := rag.LoadURL(ctx, a.egress.Client(a.cfg.Ollama.Timeout), target)
+		if err != nil {
+			return 0, err
+		}
+		sources = append(sources, src)
+	} else {
+		if sources, err = rag.LoadPath(target); err != nil {
+			return 0, err
+		}
+		job.update(func(p *IngestProgress) { p.Files = len(sources) })
+	}
+
+	onChunk := func() { job.update(func(p *IngestProgress) { p.Chunks++ }) }
+	for _, src := range sources {
+		if ctx.Err() != nil {
+			break
+		}
+		err := a.rag.AddDocumentWithProgress(ctx, collection, src.ID, src.Content, src.Metadata, onChunk)
+		if err != nil && ctx.Err() != nil {
+			// 被取消的文件不计为失败
+			break
+		}
+		job.update(func(p *IngestProgress) {
+			p.FilesDone++
+			if err != nil {
+				p.FilesFailed++
+				if len(p.Errors) < maxIngestErrors {
+					p.Errors = append(p.Errors, fmt.Sprintf("%s: %v", src.Metadata["source"], err))
+				}
+			}
+		})
+		if err != nil {
+			klog.ErrorS(err, "Failed to add document", "source", src.Metadata["source"])
+			continue
+		}
+		a.extractDocument(collection, src.ID, src.Content)
+		loaded++
+	}
+
+	klog.InfoS("RAG documents ingested", "target", target, "collection", collection,
+		"documents", loaded, "totalChunks", a.rag.DocumentCount())
+
+	if err := ctx.Err(); err != nil {
+		// 已导入的文档仍然保存
+		if saveErr := a.saveRAG(); saveErr != nil {
+			klog.ErrorS(saveErr, "Failed to save RAG store")
+		}
+		return loaded, err
+	}
+	if loaded == 0 && len(sources) > 0 {
+		return 0, fmt.Errorf("failed to ingest any document from %s", target)
+	}
+	return loaded, a.saveRAG()
+}
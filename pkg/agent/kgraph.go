@@ -0,0 +1,337 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/kgraph"
+	"github.com/champly/ai-agent/pkg/ollama"
+	"github.com/champly/ai-agent/pkg/rag"
+)
+
+// 知识图谱查询的内置工具名
+const queryKnowledgeGraphTool = "query_knowledge_graph"
+
+// 查询工具允许的最大跳数
+const maxGraphHops = 4
+
+// graphExtractionSchema 抽取结果的 JSON Schema，作为模型的输出格式
+var graphExtractionSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"entities": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {"name": {"type": "string"}, "type": {"type": "string"}},
+				"required": ["name", "type"]
+			}
+		},
+		"relations": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {"source": {"type": "string"}, "relation": {"type": "string"}, "target": {"type": "string"}},
+				"required": ["source", "relation", "target"]
+			}
+		}
+	},
+	"required": ["entities", "relations"]
+}`)
+
+const graphExtractionPrompt = `从下面的文本中抽取重要的实体（人员、团队、项目、服务、组件、主机、技术、概念等）以及实体之间的关系。
+- 实体名称使用文本中的原始写法，同一实体使用同一名称；type 为实体类别
+- 关系用简短的动词短语表示（如"负责"、"依赖"、"部署在"），source 和 target 必须是实体名称
+- 只抽取文本中明确陈述的事实，闲聊、问候和没有事实的内容返回空数组
+
+文本：
+`
+
+// graphJob 一次抽取任务
+type graphJob struct {
+	scope  string
+	origin string
+	text   string
+	// replace 先删除 origin 之前抽取的事实（重新导入的文档）
+	replace bool
+}
+
+// graphExtractor 在后台用模型从对话和文档中抽取实体和关系
+type graphExtractor struct {
+	a     *Agent
+	graph *kgraph.Graph
+	cfg   config.KnowledgeGraphConfig
+	queue *learnQueue
+}
+
+// newGraphExtractor 创建抽取队列并启动 worker
+func newGraphExtractor(a *Agent, graph *kgraph.Graph, cfg config.KnowledgeGraphConfig) *graphExtractor {
+	return &graphExtractor{
+		a:     a,
+		graph: graph,
+		cfg:   cfg,
+		queue: newLearnQueue("knowledge_graph", cfg.Workers, cfg.QueueSize),
+	}
+}
+
+// enqueue 加入抽取队列，未启用或队列已满时忽略
+func (e *graphExtractor) enqueue(job graphJob) {
+	if e == nil || strings.TrimSpace(job.text) == "" {
+		return
+	}
+	e.queue.enqueue(func(ctx context.Context) error {
+		if err := e.extract(ctx, job); err != nil {
+			return fmt.Errorf("extract %s from %s: %w", job.origin, job.scope, err)
+		}
+		return nil
+	})
+}
+
+// close 停止 worker，队列中未处理的任务被丢弃
+func (e *graphExtractor) close() {
+	if e == nil {
+		return
+	}
+	e.queue.close()
+	if err := e.graph.Close(); err != nil {
+		klog.ErrorS(err, "Failed to close knowledge graph")
+	}
+}
+
+// extract 分段抽取文本并保存，单段的模型输出无法解析时跳过该段
+func (e *graphExtractor) extract(ctx context.Context, job graphJob) error {
+	if job.replace {
+		if err := e.graph.Remove(ctx, job.scope, job.origin); err != nil {
+			return err
+		}
+	}
+	runes := []rune(job.text)
+	for start := 0; start < len(runes); start += e.cfg.MaxInput {
+		segment := string(runes[start:min(start+e.cfg.MaxInput, len(runes))])
+		out, err := e.a.complete(ollama.WithFormat(ctx, graphExtractionSchema), e.cfg.Model, graphExtractionPrompt+segment)
+		if err != nil {
+			return err
+		}
+		var ex kgraph.Extraction
+		if err := json.Unmarshal([]byte(out), &ex); err != nil {
+			klog.ErrorS(err, "Failed to parse knowledge graph extraction", "scope", job.scope, "origin", job.origin)
+			continue
+		}
+		if err := e.graph.Add(ctx, job.scope, job.origin, ex); err != nil {
+			return err
+		}
+		klog.V(2).InfoS("Knowledge graph updated", "scope", job.scope, "origin", job.origin,
+			"entities", len(ex.Entities), "relations", len(ex.Relations))
+	}
+	return nil
+}
+
+// extractDocument 从加入集合的文档中抽取，替换该文档之前抽取的事实
+func (a *Agent) extractDocument(collection, id, content string) {
+	if a.graphs == nil || a.cfg.KnowledgeGraph.SkipDocuments {
+		return
+	}
+	collection = cmp.Or(collection, rag.DefaultCollection)
+	a.graphs.enqueue(graphJob{scope: kgraph.DocumentScope(collection), origin: id, text: content, replace: true})
+}
+
+// forgetDocument 删除从已移除的文档中抽取的事实
+func (a *Agent) forgetDocument(collection, id string) {
+	if a.graphs == nil {
+		return
+	}
+	scope := kgraph.DocumentScope(cmp.Or(collection, rag.DefaultCollection))
+	a.graphs.queue.enqueue(func(ctx context.Context) error {
+		return a.graphs.graph.Remove(ctx, scope, id)
+	})
+}
+
+// extractTurn 从一轮对话的用户消息和回答中抽取，事实归属 context 中的用户
+func (a *Agent) extractTurn(ctx context.Context, conversationID, message string, resp *ChatResponse) {
+	if a.graphs == nil || a.cfg.KnowledgeGraph.SkipConversations || resp == nil {
+		return
+	}
+	a.graphs.enqueue(graphJob{
+		scope:  kgraph.UserScope(UserFromContext(ctx)),
+		origin: conversationID,
+		text:   "用户：" + message + "\n助手：" + resp.Response,
+	})
+}
+
+// ragContext 检索 RAG 上下文（使用配置中的 TopK），同时返回按编号排列的参考资料分块。
+// 启用知识图谱时从问题中出现的实体出发补充多跳关系，
+// 并以关联实体的名称再检索一次，补充提及这些实体、但与问题本身相似度不高的文档
+func (a *Agent) ragContext(ctx context.Context, collection, query string) (string, []rag.SearchResult, error) {
+	results, err := a.rag.Search(ctx, collection, query, a.cfg.RAG.TopK)
+	if err != nil || a.graphs == nil {
+		return rag.FormatContext(results), results, err
+	}
+
+	filter := kgraph.Filter{Collection: collection, User: UserFromContext(ctx)}
+	facts, err := a.graphFacts(ctx, filter, query, a.cfg.KnowledgeGraph.Hops)
+	if err != nil {
+		klog.ErrorS(err, "Failed to query knowledge graph")
+		return rag.FormatContext(results), results, nil
+	}
+	if len(facts) == 0 {
+		return rag.FormatContext(results), results, nil
+	}
+	linked, err := a.linkedChunks(ctx, collection, query, facts, results)
+	if err != nil {
+		klog.ErrorS(err, "Failed to search documents linked by knowledge graph")
+	}
+	results = append(results, linked...)
+	return formatFacts(facts) + rag.FormatContext(results), results, nil
+}
+
+// graphFacts 返回 text 中出现的实体在 hops 跳以内的关系
+func (a *Agent) graphFacts(ctx context.Context, filter kgraph.Filter, text string, hops int) ([]kgraph.Fact, error) {
+	names, err := a.graphs.graph.Match(ctx, filter, text)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+	return a.graphs.graph.Neighborhood(ctx, filter, names, hops, a.cfg.KnowledgeGraph.MaxFacts)
+}
+
+// linkedChunks 以问题和关联实体的名称检索，返回关系来源文档中不在 results 里的分块
+func (a *Agent) linkedChunks(ctx context.Context, collection, query string, facts []kgraph.Fact, results []rag.SearchResult) ([]rag.SearchResult, error) {
+	lower := strings.ToLower(query)
+	origins := make(map[string]bool)
+	var names []string
+	for _, f := range facts {
+		if f.Collection != "" {
+			origins[f.Collection+"\x00"+f.Origin] = true
+		}
+		for _, name := range []string{f.Source, f.Target} {
+			if !strings.Contains(lower, strings.ToLower(name)) {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(origins) == 0 || len(names) == 0 {
+		return nil, nil
+	}
+
+	found, err := a.rag.Search(ctx, collection, query+" "+strings.Join(names, " "), a.cfg.RAG.TopK)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[*rag.Document]bool, len(results))
+	for _, r := range results {
+		seen[r.Document] = true
+	}
+	var linked []rag.SearchResult
+	for _, r := range found {
+		if !seen[r.Document] && origins[r.Document.Collection+"\x00"+rag.SourceID(r.Document.ID)] {
+			linked = append(linked, r)
+		}
+	}
+	return linked, nil
+}
+
+// formatFacts 把关系整理为模型的参考资料
+func formatFacts(facts []kgraph.Fact) string {
+	var b strings.Builder
+	b.WriteString("以下是知识图谱中与问题相关的实体关系：\n\n")
+	for _, f := range facts {
+		fmt.Fprintf(&b, "- %s —%s→ %s\n", f.Source, f.Relation, f.Target)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// newKnowledgeGraphTool 创建 query_knowledge_graph 工具
+func newKnowledgeGraphTool(a *Agent) *ToolInfo {
+	return &ToolInfo{
+		Name:   queryKnowledgeGraphTool,
+		Source: builtinSource,
+		MCPTool: &mcp.Tool{
+			Name: queryKnowledgeGraphTool,
+			Description: "查询从对话和知识库文档中抽取的知识图谱，返回实体之间的关系（如谁负责某个服务、某个组件依赖什么）。" +
+				"适合需要沿关系多步推理的问题；指定 entity 时从该实体出发，否则从 question 中出现的实体出发",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"entity":     map[string]any{"type": "string", "description": "实体名称，可以只写名称的一部分"},
+					"question":   map[string]any{"type": "string", "description": "问题，未指定 entity 时使用其中出现的实体"},
+					"hops":       map[string]any{"type": "integer", "description": fmt.Sprintf("从实体出发的最大跳数（1-%d），默认为配置值", maxGraphHops)},
+					"collection": map[string]any{"type": "string", "description": "只查询该知识库集合中的文档，默认为所有集合"},
+				},
+			},
+		},
+		Executor: &knowledgeGraphExecutor{a: a},
+	}
+}
+
+// knowledgeGraphExecutor query_knowledge_graph 工具执行器
+type knowledgeGraphExecutor struct {
+	a *Agent
+}
+
+// Execute 执行工具
+func (e *knowledgeGraphExecutor) Execute(ctx context.Context, args map[string]any) (string, error) {
+	a := e.a
+	entity, _ := args["entity"].(string)
+	question, _ := args["question"].(string)
+	if strings.TrimSpace(entity) == "" && strings.TrimSpace(question) == "" {
+		return "", fmt.Errorf("entity or question is required")
+	}
+	hops := int(intArg(args, "hops"))
+	if hops <= 0 {
+		hops = a.cfg.KnowledgeGraph.Hops
+	}
+	hops = min(hops, maxGraphHops)
+	collection, _ := args["collection"].(string)
+	collection, err := a.tenantCollection(ctx, collection, true)
+	if err != nil {
+		return "", err
+	}
+	filter := kgraph.Filter{Collection: collection, User: UserFromContext(ctx)}
+
+	var names []string
+	if entity != "" {
+		entities, err := a.graphs.graph.Search(ctx, filter, entity)
+		if err != nil {
+			return "", err
+		}
+		for _, e := range entities {
+			names = append(names, e.Name)
+		}
+	} else if names, err = a.graphs.graph.Match(ctx, filter, question); err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		entities, relations, err := a.graphs.graph.Stats(ctx, filter)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("知识图谱中没有找到相关实体（可见范围内共 %d 个实体、%d 条关系）", entities, relations), nil
+	}
+
+	facts, err := a.graphs.graph.Neighborhood(ctx, filter, names, hops, a.cfg.KnowledgeGraph.MaxFacts)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "实体：%s\n", strings.Join(names, "、"))
+	if len(facts) == 0 {
+		b.WriteString("这些实体没有已知的关系")
+		return b.String(), nil
+	}
+	fmt.Fprintf(&b, "%d 跳以内的关系：\n", hops)
+	for _, f := range facts {
+		source := "对话 " + f.Origin
+		if f.Collection != "" {
+			source = "文档 " + f.Collection + "/" + f.Origin
+		}
+		fmt.Fprintf(&b, "- %s —%s→ %s（来源：%s）\n", f.Source, f.Relation, f.Target, source)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
@@ -0,0 +1,23 @@
+package agent
+
+import (
+	"cmp"
+	"strings"
+
+	"github.com/champly/ai-agent/pkg/locale"
+)
+
+// conversationLanguage 返回本轮的回答语言：请求指定了语言（已规范化）时切换并记录到对话中，
+// 否则沿用对话之前选择的语言，都没有时使用 ollama.language
+func (a *Agent) conversationLanguage(conv *Conversation, requested string) string {
+	if requested != "" {
+		conv.setLanguage(requested)
+		return requested
+	}
+	return cmp.Or(conv.Language(), a.cfg.Ollama.Language)
+}
+
+// baseSystemPrompt 所有请求共用的系统提示：配置的 ollama.system_prompt，未配置时为该语言的内置提示
+func (a *Agent) baseSystemPrompt(lang string) string {
+	return strings.TrimSpace(cmp.Or(a.cfg.Ollama.SystemPrompt, locale.SystemPrompt(lang)))
+}
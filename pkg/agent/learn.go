@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/priority"
+)
+
+// learnQueue 在后台执行从对话和文档中学习的模型调用（知识图谱抽取、用户资料学习），
+// 以后台优先级执行，队列已满时丢弃新的任务，停止时丢弃未执行的任务
+type learnQueue struct {
+	name   string
+	jobs   chan func(ctx context.Context) error
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newLearnQueue 创建队列并启动 workers 个 worker
+func newLearnQueue(name string, workers, size int) *learnQueue {
+	ctx, cancel := context.WithCancel(priority.WithClass(context.Background(), priority.Background))
+	q := &learnQueue{
+		name:   name,
+		jobs:   make(chan func(ctx context.Context) error, size),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for range max(workers, 1) {
+		q.wg.Add(1)
+		go q.run()
+	}
+	return q
+}
+
+// enqueue 加入队列，队列已满或已停止时丢弃
+func (q *learnQueue) enqueue(job func(ctx context.Context) error) {
+	select {
+	case q.jobs <- job:
+	case <-q.ctx.Done():
+	default:
+		klog.InfoS("Learning queue is full, skipping", "queue", q.name)
+	}
+}
+
+// close 停止 worker 并等待进行中的任务结束
+func (q *learnQueue) close() {
+	q.cancel()
+	q.wg.Wait()
+}
+
+func (q *learnQueue) run() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case job := <-q.jobs:
+			if err := job(q.ctx); err != nil && q.ctx.Err() == nil {
+				klog.ErrorS(err, "Learning failed", "queue", q.name)
+			}
+		}
+	}
+}
+
+// learnFromTurn 一轮对话成功后，在后台从用户消息和回答中抽取知识图谱、学习用户资料
+func (a *Agent) learnFromTurn(ctx context.Context, conversationID, message string, resp *ChatResponse) {
+	a.extractTurn(ctx, conversationID, message, resp)
+	a.learnProfile(ctx, message, resp)
+}
@@ -1,11 +1,14 @@
 package agent
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -14,20 +17,84 @@ import (
 	"github.com/champly/ai-agent/pkg/config"
 )
 
+// mcpSourcePrefix 外部 MCP 工具的来源前缀
+const mcpSourcePrefix = "mcp:"
+
 // MCPClient MCP 客户端管理器（连接到外部 MCP 服务器）
 type MCPClient struct {
 	configs []config.MCPServerConfig
 	clients map[string]*MCPClientInfo
 	mu      sync.RWMutex
+
+	// onToolsChanged 运行中的服务器工具列表发生变化后调用
+	onToolsChanged func()
 }
 
+// mcpRefreshTimeout 重新获取单个服务器工具列表的超时时间
+const mcpRefreshTimeout = 30 * time.Second
+
+// ErrMCPServerBusy MCP 服务器进行中和等待中的调用都已达到上限
+var ErrMCPServerBusy = errors.New("mcp server is busy")
+
 // MCPClientInfo MCP 客户端信息
 type MCPClientInfo struct {
 	Name    string
+	Config  config.MCPServerConfig
 	Client  *mcp.Client
 	Session *mcp.ClientSession
-	Cmd     *exec.Cmd
 	Tools   []*mcp.Tool
+	process *mcpProcess
+	limiter *callLimiter
+	done    chan struct{} // 客户端停止时关闭，结束定期刷新
+}
+
+// callLimiter 限制单个 MCP 服务器同时进行的工具调用数，超出的调用排队等待
+type callLimiter struct {
+	slots    chan struct{}
+	waiting  atomic.Int64
+	maxQueue int64
+	timeout  time.Duration
+}
+
+// newCallLimiter 按配置创建限制器，未设置的项使用默认值
+func newCallLimiter(cfg config.MCPServerConfig) *callLimiter {
+	return &callLimiter{
+		slots:    make(chan struct{}, cmp.Or(max(cfg.MaxInFlight, 0), 1)),
+		maxQueue: int64(cmp.Or(max(cfg.MaxQueue, 0), 32)),
+		timeout:  cmp.Or(cfg.QueueTimeout, 30*time.Second),
+	}
+}
+
+// acquire 获取调用名额：等待中的调用已满时立即返回 ErrMCPServerBusy，等待超时同样返回该错误
+func (l *callLimiter) acquire(ctx context.Context, server string) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	waiting := l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+	if waiting > l.maxQueue {
+		return fmt.Errorf("%w: %s has %d calls in flight and %d queued", ErrMCPServerBusy, server, cap(l.slots), l.maxQueue)
+	}
+	klog.V(2).InfoS("MCP tool call queued", "server", server, "waiting", waiting)
+
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("%w: %s (waited %s)", ErrMCPServerBusy, server, l.timeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release 归还调用名额
+func (l *callLimiter) release() {
+	<-l.slots
 }
 
 // NewMCPClient 创建 MCP 客户端管理器
@@ -38,6 +105,11 @@ func NewMCPClient(configs []config.MCPServerConfig) *MCPClient {
 	}
 }
 
+// OnToolsChanged 设置工具列表变化（收到 listChanged 通知或定期刷新时发现变化）后的回调，需在 Start 之前调用
+func (m *MCPClient) OnToolsChanged(fn func()) {
+	m.onToolsChanged = fn
+}
+
 // Start 启动所有 MCP 客户端
 func (m *MCPClient) Start(ctx context.Context) error {
 	for _, cfg := range m.configs {
@@ -60,65 +132,204 @@ func (m *MCPClient) Start(ctx context.Context) error {
 func (m *MCPClient) startClient(ctx context.Context, cfg config.MCPServerConfig) error {
 	klog.InfoS("Starting MCP client", "name", cfg.Name, "command", cfg.Command, "args", cfg.Args)
 
-	cmd := exec.Command(cfg.Command, cfg.Args...)
-	if len(cfg.Env) > 0 {
-		for k, v := range cfg.Env {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
-		}
-	}
-
-	transport := &mcp.CommandTransport{
-		Command: cmd,
+	process, transport, err := startProcess(cfg)
+	if err != nil {
+		return err
 	}
 
 	client := mcp.NewClient(&mcp.Implementation{
 		Name:    "ai-agent",
 		Version: "v1.0.0",
-	}, nil)
+	}, &mcp.ClientOptions{
+		// 通知在会话的消息处理中同步调用，在其中发起请求会阻塞，因此异步刷新
+		ToolListChangedHandler: func(context.Context, *mcp.ToolListChangedRequest) {
+			klog.V(2).InfoS("MCP tool list changed", "name", cfg.Name)
+			go m.refreshTools(cfg.Name)
+		},
+	})
 
 	session, err := client.Connect(ctx, transport, nil)
 	if err != nil {
+		process.stop()
 		return fmt.Errorf("connect failed: %w", err)
 	}
 
 	toolsResult, err := session.ListTools(ctx, &mcp.ListToolsParams{})
 	if err != nil {
 		session.Close()
+		process.stop()
 		return fmt.Errorf("list tools failed: %w", err)
 	}
 
 	klog.InfoS("MCP client connected", "name", cfg.Name, "tools", len(toolsResult.Tools))
 
-	m.mu.Lock()
-	m.clients[cfg.Name] = &MCPClientInfo{
+	info := &MCPClientInfo{
 		Name:    cfg.Name,
+		Config:  cfg,
 		Client:  client,
 		Session: session,
-		Cmd:     cmd,
+		process: process,
 		Tools:   toolsResult.Tools,
+		limiter: newCallLimiter(cfg),
+		done:    make(chan struct{}),
 	}
+	m.mu.Lock()
+	m.clients[cfg.Name] = info
 	m.mu.Unlock()
 
+	if cfg.RefreshInterval > 0 {
+		go m.refreshLoop(info)
+	}
 	return nil
 }
 
+// refreshLoop 按 refresh_interval 定期重新获取工具列表，用于不发送 listChanged 通知的服务器
+func (m *MCPClient) refreshLoop(client *MCPClientInfo) {
+	ticker := time.NewTicker(client.Config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.refreshTools(client.Name)
+		case <-client.done:
+			return
+		}
+	}
+}
+
+// refreshTools 重新获取服务器的工具列表，有变化时更新并调用 onToolsChanged
+func (m *MCPClient) refreshTools(name string) {
+	m.mu.RLock()
+	client, ok := m.clients[name]
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mcpRefreshTimeout)
+	defer cancel()
+	result, err := client.Session.ListTools(ctx, &mcp.ListToolsParams{})
+	if err != nil {
+		klog.ErrorS(err, "Failed to refresh MCP tools", "name", name)
+		return
+	}
+
+	m.mu.Lock()
+	// 刷新期间客户端可能已被停止或替换
+	if m.clients[name] != client || reflect.DeepEqual(client.Tools, result.Tools) {
+		m.mu.Unlock()
+		return
+	}
+	added, removed := diffToolNames(client.Tools, result.Tools)
+	client.Tools = result.Tools
+	m.mu.Unlock()
+
+	klog.InfoS("MCP tools changed", "name", name, "tools", len(result.Tools), "added", added, "removed", removed)
+	if m.onToolsChanged != nil {
+		m.onToolsChanged()
+	}
+}
+
+// diffToolNames 返回新列表中新增和移除的工具名称
+func diffToolNames(old, updated []*mcp.Tool) (added, removed []string) {
+	names := make(map[string]bool, len(old))
+	for _, tool := range old {
+		names[tool.Name] = true
+	}
+	for _, tool := range updated {
+		if !names[tool.Name] {
+			added = append(added, tool.Name)
+		}
+		delete(names, tool.Name)
+	}
+	for name := range names {
+		removed = append(removed, name)
+	}
+	return added, removed
+}
+
 // Stop 停止所有 MCP 客户端
 func (m *MCPClient) Stop(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	clients := make([]*MCPClientInfo, 0, len(m.clients))
+	for _, client := range m.clients {
+		clients = append(clients, client)
+	}
+	m.clients = make(map[string]*MCPClientInfo)
+	stopClients(clients)
+
+	klog.InfoS("MCP Manager stopped")
+	return nil
+}
+
+// stopClients 并行停止多个客户端，等待全部退出
+func stopClients(clients []*MCPClientInfo) {
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.stop()
+		}()
+	}
+	wg.Wait()
+}
+
+// stop 关闭会话并优雅停止 MCP 服务器进程
+func (c *MCPClientInfo) stop() {
+	klog.V(2).InfoS("Stopping MCP client", "name", c.Name)
+	close(c.done)
+	if c.Session != nil {
+		c.Session.Close()
+	}
+	if c.process != nil {
+		c.process.stop()
+	}
+}
+
+// Reconcile 将运行中的客户端调整为给定配置，返回是否有客户端发生变化
+func (m *MCPClient) Reconcile(ctx context.Context, configs []config.MCPServerConfig) (bool, error) {
+	desired := make(map[string]config.MCPServerConfig, len(configs))
+	for _, cfg := range configs {
+		if cfg.Enabled {
+			desired[cfg.Name] = cfg
+		}
+	}
+
+	// 停止已移除或配置变化的客户端
+	var stopped []*MCPClientInfo
+	m.mu.Lock()
+	m.configs = configs
 	for name, client := range m.clients {
-		klog.V(2).InfoS("Stopping MCP client", "name", name)
-		if client.Session != nil {
-			client.Session.Close()
+		if cfg, ok := desired[name]; !ok || !reflect.DeepEqual(cfg, client.Config) {
+			stopped = append(stopped, client)
+			delete(m.clients, name)
 		}
-		if client.Cmd != nil && client.Cmd.Process != nil {
-			client.Cmd.Process.Kill()
+	}
+	var pending []config.MCPServerConfig
+	for name, cfg := range desired {
+		if _, ok := m.clients[name]; !ok {
+			pending = append(pending, cfg)
 		}
 	}
+	m.mu.Unlock()
 
-	klog.InfoS("MCP Manager stopped")
-	return nil
+	stopClients(stopped)
+
+	var errs []error
+	for _, cfg := range pending {
+		if err := m.startClient(ctx, cfg); err != nil {
+			errs = append(errs, fmt.Errorf("start %s: %w", cfg.Name, err))
+		}
+	}
+
+	changed := len(stopped) > 0 || len(pending) > 0
+	if changed {
+		klog.InfoS("MCP servers reconciled", "stopped", len(stopped), "started", len(pending)-len(errs))
+	}
+	return changed, errors.Join(errs...)
 }
 
 // GetAllTools 获取所有外部 MCP 工具
@@ -131,7 +342,7 @@ func (m *MCPClient) GetAllTools() []*ToolInfo {
 		for _, tool := range client.Tools {
 			tools = append(tools, &ToolInfo{
 				Name:    tool.Name,
-				Source:  fmt.Sprintf("mcp:%s", client.Name),
+				Source:  mcpSourcePrefix + client.Name,
 				MCPTool: tool,
 				Executor: &MCPToolExecutor{
 					manager:    m,
@@ -155,6 +366,11 @@ func (m *MCPClient) CallTool(ctx context.Context, serverName, toolName string, a
 		return nil, fmt.Errorf("MCP server not found: %s", serverName)
 	}
 
+	if err := client.limiter.acquire(ctx, serverName); err != nil {
+		return nil, err
+	}
+	defer client.limiter.release()
+
 	klog.InfoS("MCP client calling tool", "server", serverName, "tool", toolName, "args", formatArgs(args))
 
 	// 记录调用耗时
@@ -189,12 +405,26 @@ func (e *MCPToolExecutor) Execute(ctx context.Context, args map[string]any) (str
 		return "", err
 	}
 
-	if len(result.Content) > 0 {
-		if textContent, ok := result.Content[0].(*mcp.TextContent); ok {
-			return textContent.Text, nil
+	// 图片交给对话循环附加到工具结果消息中，模型为视觉模型时可以直接查看
+	var text string
+	var images int
+	for _, content := range result.Content {
+		switch c := content.(type) {
+		case *mcp.TextContent:
+			if text == "" {
+				text = c.Text
+			}
+		case *mcp.ImageContent:
+			addToolImages(ctx, c.Data)
+			images++
 		}
 	}
-
+	switch {
+	case text != "":
+		return text, nil
+	case images > 0:
+		return fmt.Sprintf("[%d image(s) attached]", images), nil
+	}
 	return "", fmt.Errorf("no content in result")
 }
 
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/escalation"
+	"github.com/champly/ai-agent/pkg/modelstats"
+)
+
+// ResponseMetrics Ollama 返回的 token 数和耗时，多轮工具调用时为各轮之和；命中响应缓存的轮次不计入
+type ResponseMetrics struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	// 各阶段耗时（毫秒）：处理提示词、生成回答、加载模型和 Ollama 统计的总耗时
+	PromptEvalMs float64 `json:"prompt_eval_ms"`
+	EvalMs       float64 `json:"eval_ms"`
+	LoadMs       float64 `json:"load_ms"`
+	TotalMs      float64 `json:"total_ms"`
+	// TokensPerSecond 生成回答的速度
+	TokensPerSecond float64 `json:"tokens_per_second"`
+	// DoneReason 最后一次模型调用的结束原因：stop（正常结束）、length（达到长度限制）等
+	DoneReason string `json:"done_reason,omitempty"`
+	// Truncated 回答因达到 num_predict 或上下文长度限制而被截断，可能不完整
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// add 累加一次模型调用的指标，结束原因以最后一次为准
+func (m *ResponseMetrics) add(metrics api.Metrics, doneReason string) {
+	m.PromptTokens += metrics.PromptEvalCount
+	m.CompletionTokens += metrics.EvalCount
+	m.PromptEvalMs += milliseconds(metrics.PromptEvalDuration)
+	m.EvalMs += milliseconds(metrics.EvalDuration)
+	m.LoadMs += milliseconds(metrics.LoadDuration)
+	m.TotalMs += milliseconds(metrics.TotalDuration)
+	m.TokensPerSecond = modelstats.TokensPerSecond(m.CompletionTokens, time.Duration(m.EvalMs*float64(time.Millisecond)))
+	m.DoneReason = doneReason
+	m.Truncated = doneReason == modelstats.DoneReasonLength
+}
+
+// milliseconds 耗时的毫秒数
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// recordModelCall 记录一次实际发送到 Ollama 的模型调用，回答被截断时记录日志
+func (a *Agent) recordModelCall(model string, metrics api.Metrics, doneReason string) {
+	a.modelStats.Record(model, metrics, doneReason)
+	if doneReason == modelstats.DoneReasonLength {
+		klog.InfoS("Model output truncated by length limit", "model", model, "completionTokens", metrics.EvalCount)
+	}
+}
+
+// ModelStats 返回各模型的 token 数、生成速度和截断次数统计，以及开始统计的时间
+func (a *Agent) ModelStats() ([]modelstats.Stats, time.Time) {
+	return a.modelStats.List(), a.modelStats.Since()
+}
+
+// EscalationStats 返回小模型直接回答和升级到大模型的次数，未启用升级策略时返回 false
+func (a *Agent) EscalationStats() (escalation.Stats, bool) {
+	return a.escalation.Stats(), a.escalation != nil
+}
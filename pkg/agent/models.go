@@ -0,0 +1,237 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ollama/ollama/api"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/embedding"
+	"github.com/champly/ai-agent/pkg/ollama"
+)
+
+// ErrModelCapability 模型不支持请求所需的能力（如图片输入）
+var ErrModelCapability = errors.New("model does not support the request")
+
+// modelRegistry 通过 /api/show 获取的模型信息：启动时查询配置中用到的模型，
+// 请求使用其他模型时在首次使用时查询；查询失败不缓存，下次使用时重试
+type modelRegistry struct {
+	pool *ollama.Pool
+
+	mu     sync.RWMutex
+	models map[string]*ollama.ModelInfo
+}
+
+// newModelRegistry 创建模型信息注册表
+func newModelRegistry(pool *ollama.Pool) *modelRegistry {
+	return &modelRegistry{pool: pool, models: make(map[string]*ollama.ModelInfo)}
+}
+
+// discover 并发查询模型信息，失败时只记录日志
+func (r *modelRegistry) discover(ctx context.Context, names []string) {
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Go(func() {
+			if info := r.get(ctx, name); info != nil {
+				klog.InfoS("Model discovered", "model", name, "family", info.Family,
+					"contextLength", info.ContextLength, "capabilities", info.Capabilities)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// get 返回模型信息，尚未查询时向 Ollama 查询；查询失败时返回 nil
+func (r *modelRegistry) get(ctx context.Context, name string) *ollama.ModelInfo {
+	r.mu.RLock()
+	info, ok := r.models[name]
+	r.mu.RUnlock()
+	if ok {
+		return info
+	}
+
+	info, err := r.pool.Show(ctx, name)
+	if err != nil {
+		klog.ErrorS(err, "Failed to query model info", "model", name)
+		return nil
+	}
+	r.mu.Lock()
+	r.models[name] = info
+	r.mu.Unlock()
+	return info
+}
+
+// list 返回已查询到的模型信息，按名称排序
+func (r *modelRegistry) list() []*ollama.ModelInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.SortedFunc(maps.Values(r.models), func(x, y *ollama.ModelInfo) int {
+		return strings.Compare(x.Name, y.Name)
+	})
+}
+
+// configuredModels 配置中用到的模型：默认模型、预热、路由、升级策略、人设、注入检测和（与聊天共用主机时的）嵌入模型
+func (a *Agent) configuredModels() []string {
+	names := []string{a.DefaultModel(), a.cfg.Guard.Model, a.cfg.Routing.Model,
+		a.cfg.Escalation.SmallModel, a.cfg.Escalation.LargeModel, a.cfg.Escalation.Model}
+	// 嵌入模型不在聊天主机上时无法查询
+	if !embedding.Separate(a.cfg.RAG.Embedding) {
+		names = append(names, a.cfg.RAG.EmbedModel)
+	}
+	names = append(names, a.cfg.Ollama.Warmup...)
+	for _, model := range a.cfg.Routing.Routes {
+		names = append(names, model)
+	}
+	for _, persona := range a.cfg.Personas {
+		names = append(names, persona.Model)
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+	return slices.DeleteFunc(names, func(name string) bool { return name == "" })
+}
+
+// Models 返回已查询到的模型信息（上下文长度、能力和所属系列）
+func (a *Agent) Models() []*ollama.ModelInfo {
+	return a.models.list()
+}
+
+// numCtx 请求使用的 num_ctx：配置了 ollama.max_context 时取模型上下文长度与其中较小者，否则为 0（不设置）
+func (a *Agent) numCtx(info *ollama.ModelInfo) int {
+	limit := a.cfg.Ollama.MaxContext
+	if limit <= 0 {
+		return 0
+	}
+	if info != nil && info.ContextLength > 0 {
+		return min(info.ContextLength, limit)
+	}
+	return limit
+}
+
+// fitModel 按模型信息调整请求：设置 num_ctx，模型不支持工具时不发送工具，
+// 消息超出上下文窗口时裁剪较早的历史；模型不支持图片而消息中有图片时返回错误
+func (a *Agent) fitModel(ctx context.Context, model string, options map[string]any, messages []api.Message, tools []api.Tool) (map[string]any, []api.Message, []api.Tool, error) {
+	info := a.models.get(ctx, model)
+	if info == nil {
+		return options, messages, tools, nil
+	}
+
+	if !info.Supports(ollama.CapabilityVision) {
+		// 本轮提问附带图片时报错；历史中之前附带的图片（可能是切换模型前发送的）不再发送
+		if i := lastUserMessage(messages); i >= 0 && len(messages[i].Images) > 0 {
+			return nil, nil, nil, fmt.Errorf("%w: %s does not support images", ErrModelCapability, model)
+		}
+		messages = withoutImages(messages)
+	}
+	if len(tools) > 0 && !info.Supports(ollama.CapabilityTools) {
+		klog.InfoS("Model does not support tools, sending request without tools", "model", model, "tools", len(tools))
+		tools = nil
+	}
+
+	window := info.ContextLength
+	if n := a.numCtx(info); n > 0 {
+		// 人设等已指定 num_ctx 时不覆盖
+		if _, ok := options["num_ctx"]; !ok {
+			options = maps.Clone(options)
+			if options == nil {
+				options = make(map[string]any)
+			}
+			options["num_ctx"] = n
+		}
+		window = n
+	}
+	if n, ok := options["num_ctx"].(int); ok {
+		window = n
+	}
+	return options, trimHistory(messages, window), tools, nil
+}
+
+// lastUserMessage 最后一条用户消息的下标，没有时为 -1
+func lastUserMessage(messages []api.Message) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return i
+		}
+	}
+	return -1
+}
+
+// withoutImages 去掉消息中的图片，不修改原切片
+func withoutImages(messages []api.Message) []api.Message {
+	if !slices.ContainsFunc(messages, func(m api.Message) bool { return len(m.Images) > 0 }) {
+		return messages
+	}
+	result := slices.Clone(messages)
+	for i := range result {
+		result[i].Images = nil
+	}
+	return result
+}
+
+// 上下文窗口的使用比例，其余留给工具定义和模型输出
+const historyBudgetRatio = 0.75
+
+// trimHistory 估算的 token 数超出上下文窗口时，从最早的非系统消息开始丢弃，
+// 保留的历史从一条用户消息开始，不拆开工具调用与其结果；window 为 0 时不裁剪。只影响本次请求，不修改对话历史
+func trimHistory(messages []api.Message, window int) []api.Message {
+	if window <= 0 {
+		return messages
+	}
+	budget := int(float64(window) * historyBudgetRatio)
+	total := 0
+	for _, m := range messages {
+		total += estimateTokens(m)
+	}
+	if total <= budget {
+		return messages
+	}
+
+	var system, rest []api.Message
+	for i, m := range messages {
+		if m.Role != "system" {
+			system, rest = messages[:i], messages[i:]
+			break
+		}
+	}
+	// 至少保留最后一条用户消息及其后的内容
+	last := max(lastUserMessage(rest), 0)
+	start := 0
+	for start < last && total > budget {
+		total -= estimateTokens(rest[start])
+		start++
+		for start < last && rest[start].Role != "user" {
+			total -= estimateTokens(rest[start])
+			start++
+		}
+	}
+	if start == 0 {
+		return messages
+	}
+	klog.V(2).InfoS("Trimmed conversation history to fit context window", "dropped", start, "window", window)
+	return append(slices.Clone(system), rest[start:]...)
+}
+
+// estimateTokens 粗略估算消息的 token 数：英文约 4 个字符一个 token，中文约 1 个字一个 token
+func estimateTokens(m api.Message) int {
+	ascii, other := 0, 0
+	for _, text := range []string{m.Content, m.Thinking} {
+		for _, r := range text {
+			if r < utf8.RuneSelf {
+				ascii++
+			} else {
+				other++
+			}
+		}
+	}
+	for _, tc := range m.ToolCalls {
+		ascii += len(tc.Function.Name) + len(tc.Function.Arguments.String())
+	}
+	// 每张图片按 768 个 token 估算
+	return ascii/4 + other + 768*len(m.Images) + 4
+}
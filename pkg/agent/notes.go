@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/champly/ai-agent/pkg/notes"
+)
+
+// 对话笔记的内置工具名
+const (
+	saveNoteTool  = "save_note"
+	readNotesTool = "read_notes"
+)
+
+// newNoteTools 创建 save_note 和 read_notes 工具，笔记按用户和对话保存
+func newNoteTools(store *notes.Store) []*ToolInfo {
+	return []*ToolInfo{
+		{
+			Name:   saveNoteTool,
+			Source: builtinSource,
+			MCPTool: &mcp.Tool{
+				Name: saveNoteTool,
+				Description: "把中间结果（文件清单、阶段性分析、待办事项等）保存为笔记，避免占用上下文；" +
+					"之后用 read_notes 按标题读取。标题相同时覆盖原笔记，content 为空时删除该笔记",
+				InputSchema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"title":   map[string]any{"type": "string", "description": "笔记标题，同一对话中唯一"},
+						"content": map[string]any{"type": "string", "description": "笔记内容"},
+					},
+					"required": []any{"title", "content"},
+				},
+			},
+			Executor: &saveNoteExecutor{store: store},
+		},
+		{
+			Name:   readNotesTool,
+			Source: builtinSource,
+			MCPTool: &mcp.Tool{
+				Name: readNotesTool,
+				Description: "读取 save_note 保存的笔记：指定 title 时返回笔记内容，否则列出当前对话的笔记标题和之前对话中的笔记；" +
+					"读取之前对话的笔记时传入列表中的 conversation_id",
+				InputSchema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"title":           map[string]any{"type": "string", "description": "笔记标题，不填时列出笔记"},
+						"conversation_id": map[string]any{"type": "string", "description": "之前对话的 ID，默认为当前对话"},
+					},
+				},
+			},
+			Executor: &readNotesExecutor{store: store},
+		},
+	}
+}
+
+// saveNoteExecutor save_note 工具执行器
+type saveNoteExecutor struct {
+	store *notes.Store
+}
+
+// Execute 执行工具
+func (e *saveNoteExecutor) Execute(ctx context.Context, args map[string]any) (string, error) {
+	conversationID := conversationIDFromContext(ctx)
+	if conversationID == "" {
+		return "", fmt.Errorf("notes are only available in a conversation")
+	}
+	title, _ := args["title"].(string)
+	content, _ := args["content"].(string)
+	if err := e.store.Save(ctx, UserFromContext(ctx), conversationID, title, content); err != nil {
+		return "", err
+	}
+	if content == "" {
+		return fmt.Sprintf("已删除笔记 %q", strings.TrimSpace(title)), nil
+	}
+	return fmt.Sprintf("已保存笔记 %q（%d 字节），之后可调用 read_notes 读取", strings.TrimSpace(title), len(content)), nil
+}
+
+// readNotesExecutor read_notes 工具执行器
+type readNotesExecutor struct {
+	store *notes.Store
+}
+
+// Execute 执行工具
+func (e *readNotesExecutor) Execute(ctx context.Context, args map[string]any) (string, error) {
+	owner, current := UserFromContext(ctx), conversationIDFromContext(ctx)
+	conversationID, _ := args["conversation_id"].(string)
+	if conversationID == "" {
+		conversationID = current
+	}
+	if conversationID == "" {
+		return "", fmt.Errorf("notes are only available in a conversation")
+	}
+
+	if title, _ := args["title"].(string); title != "" {
+		note, err := e.store.Get(ctx, owner, conversationID, title)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("# %s\n（更新于 %s）\n\n%s", note.Title, note.UpdatedAt.Format(time.DateTime), note.Content), nil
+	}
+
+	list, err := e.store.List(ctx, owner, conversationID)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if len(list) == 0 {
+		b.WriteString("该对话没有笔记\n")
+	} else {
+		fmt.Fprintf(&b, "对话 %s 的笔记：\n", conversationID)
+		for _, n := range list {
+			fmt.Fprintf(&b, "- %s（%d 字节，更新于 %s）\n", n.Title, len(n.Content), n.UpdatedAt.Format(time.DateTime))
+		}
+	}
+
+	conversations, err := e.store.Conversations(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	others := false
+	for _, c := range conversations {
+		if c.ID == conversationID {
+			continue
+		}
+		if !others {
+			b.WriteString("\n之前对话中的笔记（读取时传入 conversation_id）：\n")
+			others = true
+		}
+		fmt.Fprintf(&b, "- conversation_id=%s（更新于 %s）：%s\n", c.ID, c.UpdatedAt.Format(time.DateTime), strings.Join(c.Titles, "、"))
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/openapi"
+)
+
+// openAPISourcePrefix 由 OpenAPI 规范生成的工具的来源前缀，后接 API 名称
+const openAPISourcePrefix = "openapi:"
+
+// registerOpenAPITools 读取配置的 OpenAPI 规范并注册生成的工具；规范无法读取时记录日志并跳过该 API，
+// 与已有工具重名的工具不注册
+func (a *Agent) registerOpenAPITools(ctx context.Context) {
+	for _, cfg := range a.cfg.OpenAPI {
+		api, err := openapi.Load(ctx, a.egress.Client(cfg.Timeout), cfg)
+		if err != nil {
+			klog.ErrorS(err, "Failed to load OpenAPI spec", "api", cfg.Name, "spec", cfg.Spec)
+			continue
+		}
+		registered := 0
+		for _, op := range api.Operations() {
+			info := &ToolInfo{
+				Name:   op.Name(),
+				Source: openAPISourcePrefix + api.Name(),
+				MCPTool: &mcp.Tool{
+					Name:        op.Name(),
+					Description: op.Description(),
+					InputSchema: op.InputSchema(),
+				},
+				Executor: openAPIExecutor{op: op},
+			}
+			if !a.toolRegistry.Add(info) {
+				klog.InfoS("OpenAPI tool conflicts with an existing tool, skipping", "api", api.Name(), "tool", op.Name())
+				continue
+			}
+			registered++
+		}
+		klog.InfoS("OpenAPI tools loaded", "api", api.Name(), "tools", registered)
+	}
+}
+
+// openAPIExecutor OpenAPI 操作执行器
+type openAPIExecutor struct {
+	op *openapi.Operation
+}
+
+// Execute 执行工具
+func (e openAPIExecutor) Execute(ctx context.Context, args map[string]any) (string, error) {
+	return e.op.Call(ctx, args)
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// ErrPersonaNotFound 请求指定的人设未配置
+var ErrPersonaNotFound = errors.New("persona not found")
+
+// persona 按名称查找配置的人设，name 为空时返回 nil
+func (a *Agent) persona(name string) (*config.PersonaConfig, error) {
+	if name == "" {
+		return nil, nil
+	}
+	for i := range a.cfg.Personas {
+		if a.cfg.Personas[i].Name == name {
+			return &a.cfg.Personas[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrPersonaNotFound, name)
+}
+
+// conversationPersona 返回本轮使用的人设：请求指定了人设时切换并记录到对话中，
+// 否则沿用对话之前选择的人设（已从配置中删除时不再使用）
+func (a *Agent) conversationPersona(conv *Conversation, selected *config.PersonaConfig) *config.PersonaConfig {
+	if selected != nil {
+		conv.setPersona(selected.Name)
+		return selected
+	}
+	name := conv.Persona()
+	persona, err := a.persona(name)
+	if err != nil {
+		klog.InfoS("Conversation persona is no longer configured, ignoring it", "conversationID", conv.ID, "persona", name)
+	}
+	return persona
+}
+
+// modelOptions 人设的模型参数，没有需要覆盖的参数时为 nil
+func modelOptions(persona *config.PersonaConfig) map[string]any {
+	if persona == nil || persona.Temperature == nil {
+		return nil
+	}
+	return map[string]any{"temperature": *persona.Temperature}
+}
+
+// ListPersonas 返回配置的人设
+func (a *Agent) ListPersonas() []config.PersonaConfig {
+	return a.cfg.Personas
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/plugin"
+)
+
+// pluginSourcePrefix 插件工具的来源前缀，后接插件名
+const pluginSourcePrefix = "plugin:"
+
+// registerPluginTools 注册插件提供的工具，与已有工具（内置、Go 函数、MCP）重名时跳过
+func (a *Agent) registerPluginTools() {
+	for _, p := range a.plugins.Plugins() {
+		for _, spec := range p.Tools() {
+			var schema map[string]any
+			if err := json.Unmarshal(spec.InputSchema, &schema); err != nil {
+				klog.ErrorS(err, "Invalid plugin tool schema, skipping", "plugin", p.Name(), "tool", spec.Name)
+				continue
+			}
+			info := &ToolInfo{
+				Name:   spec.Name,
+				Source: pluginSourcePrefix + p.Name(),
+				MCPTool: &mcp.Tool{
+					Name:        spec.Name,
+					Description: spec.Description,
+					InputSchema: schema,
+				},
+				Executor: &pluginExecutor{plugin: p, tool: spec.Name},
+			}
+			if !a.toolRegistry.Add(info) {
+				klog.InfoS("Plugin tool conflicts with an existing tool, skipping", "plugin", p.Name(), "tool", spec.Name)
+			}
+		}
+	}
+}
+
+// pluginExecutor 插件工具执行器
+type pluginExecutor struct {
+	plugin *plugin.Plugin
+	tool   string
+}
+
+// Execute 执行工具
+func (e *pluginExecutor) Execute(ctx context.Context, args map[string]any) (string, error) {
+	return e.plugin.Call(ctx, e.tool, args)
+}
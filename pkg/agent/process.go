@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// defaultShutdownGrace 未配置时每个关闭阶段的等待时间
+const defaultShutdownGrace = 5 * time.Second
+
+// mcpProcess 外部 MCP 服务器子进程
+// 子进程运行在独立的进程组中，停止时整个进程组（包括 bash -c、npx 等启动的孙进程）一起退出。
+type mcpProcess struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	grace time.Duration
+	done  chan struct{} // 进程退出后关闭
+}
+
+// startProcess 启动 MCP 服务器子进程，返回与其 stdin/stdout 通信的传输层
+func startProcess(cfg config.MCPServerConfig) (*mcpProcess, mcp.Transport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	if len(cfg.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range cfg.Env {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+	setProcessGroup(cmd)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("start %s: %w", cfg.Command, err)
+	}
+
+	p := &mcpProcess{
+		name:  cfg.Name,
+		cmd:   cmd,
+		stdin: stdin,
+		grace: cfg.ShutdownGrace,
+		done:  make(chan struct{}),
+	}
+	if p.grace <= 0 {
+		p.grace = defaultShutdownGrace
+	}
+	go func() {
+		err := cmd.Wait()
+		klog.V(2).InfoS("MCP server process exited", "name", cfg.Name, "err", err)
+		close(p.done)
+	}()
+
+	// 关闭会话时只关闭 stdin，stdout 由 Wait 在进程退出后关闭
+	return p, &mcp.IOTransport{Reader: io.NopCloser(stdout), Writer: stdin}, nil
+}
+
+// stop 按 MCP 规范逐步停止进程：关闭 stdin 等待退出，超时后向进程组发送 SIGTERM，仍未退出时 SIGKILL
+func (p *mcpProcess) stop() {
+	p.stdin.Close()
+	if p.wait() {
+		// 进程组中可能还有脱离的孙进程
+		terminateProcessGroup(p.cmd)
+		return
+	}
+
+	klog.InfoS("MCP server did not exit after stdin closed, terminating", "name", p.name, "pid", p.cmd.Process.Pid)
+	if err := terminateProcessGroup(p.cmd); err != nil {
+		klog.V(2).InfoS("Failed to terminate MCP server", "name", p.name, "err", err)
+	} else if p.wait() {
+		return
+	}
+
+	klog.InfoS("MCP server did not exit after terminate, killing", "name", p.name, "pid", p.cmd.Process.Pid)
+	if err := killProcessGroup(p.cmd); err != nil {
+		klog.ErrorS(err, "Failed to kill MCP server", "name", p.name)
+	}
+	if !p.wait() {
+		klog.ErrorS(nil, "MCP server process is unresponsive", "name", p.name, "pid", p.cmd.Process.Pid)
+	}
+}
+
+// wait 等待进程退出，超过 grace 返回 false
+func (p *mcpProcess) wait() bool {
+	select {
+	case <-p.done:
+		return true
+	case <-time.After(p.grace):
+		return false
+	}
+}
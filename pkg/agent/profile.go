@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/ollama"
+	"github.com/champly/ai-agent/pkg/profile"
+)
+
+// 学习用户资料时对话内容的最大字符数，超出部分被截断
+const maxProfileInput = 4000
+
+// profileUpdateSchema 学习结果的 JSON Schema，作为模型的输出格式
+var profileUpdateSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"add": {
+			"type": "object",
+			"properties": {
+				"preferences": {"type": "array", "items": {"type": "string"}},
+				"projects": {"type": "array", "items": {"type": "string"}},
+				"environment": {"type": "array", "items": {"type": "string"}}
+			},
+			"required": ["preferences", "projects", "environment"]
+		},
+		"remove": {"type": "array", "items": {"type": "string"}}
+	},
+	"required": ["add", "remove"]
+}`)
+
+const profileLearningPrompt = `根据下面的一轮对话更新用户资料。只记录关于用户本人、在以后的对话中仍然有用的信息：
+- preferences：回答的语言、风格、格式，常用的工具等偏好
+- projects：用户负责或经常提到的项目、仓库和服务
+- environment：用户使用的操作系统、集群、编程语言和版本等环境
+每条用一句简短的陈述。一次性的问题、对话内容本身和任何凭证都不要记录；已有资料中已经包含的信息不要重复添加。
+与对话矛盾或用户说明已经过时的已有条目放入 remove（使用已有条目的原文）。没有需要更新的内容时返回空数组。
+
+已有资料：
+%s
+
+对话：
+%s`
+
+// GetProfile 返回 context 中用户的资料，未启用时返回 profile.ErrDisabled
+func (a *Agent) GetProfile(ctx context.Context) (*profile.Profile, error) {
+	return a.profiles.Get(ctx, UserFromContext(ctx))
+}
+
+// UpdateProfile 替换 context 中用户的资料，返回整理后的资料；之后新建的对话使用新的资料
+func (a *Agent) UpdateProfile(ctx context.Context, p *profile.Profile) (*profile.Profile, error) {
+	return a.profiles.Put(ctx, UserFromContext(ctx), p)
+}
+
+// DeleteProfile 清空 context 中用户的资料
+func (a *Agent) DeleteProfile(ctx context.Context) error {
+	return a.profiles.Delete(ctx, UserFromContext(ctx))
+}
+
+// snapshotProfile 新对话的第一轮记录用户当前的资料，之后的每一轮都注入系统提示；
+// 已有消息的对话保持开始时的资料，资料更新不影响进行中的对话
+func (a *Agent) snapshotProfile(ctx context.Context, conv *Conversation) {
+	if a.profiles == nil || conv.started() {
+		return
+	}
+	p, err := a.profiles.Get(ctx, UserFromContext(ctx))
+	if err != nil {
+		klog.ErrorS(err, "Failed to load user profile", "conversationID", conv.ID)
+		return
+	}
+	conv.setProfile(profile.Render(p))
+}
+
+// learnProfile 在后台从一轮对话中学习用户资料
+func (a *Agent) learnProfile(ctx context.Context, message string, resp *ChatResponse) {
+	if a.profileQueue == nil || resp == nil {
+		return
+	}
+	user := UserFromContext(ctx)
+	turn := []rune("用户：" + message + "\n助手：" + resp.Response)
+	if len(turn) > maxProfileInput {
+		turn = turn[:maxProfileInput]
+	}
+	a.profileQueue.enqueue(func(ctx context.Context) error {
+		current, err := a.profiles.Get(ctx, user)
+		if err != nil {
+			return err
+		}
+		current.UpdatedAt = time.Time{}
+		known, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		prompt := fmt.Sprintf(profileLearningPrompt, known, string(turn))
+		out, err := a.complete(ollama.WithFormat(ctx, profileUpdateSchema), a.cfg.Profiles.Model, prompt)
+		if err != nil {
+			return fmt.Errorf("learn profile of %q: %w", user, err)
+		}
+		var update profile.Update
+		if err := json.Unmarshal([]byte(out), &update); err != nil {
+			klog.ErrorS(err, "Failed to parse user profile update", "user", user)
+			return nil
+		}
+		changed, err := a.profiles.Apply(ctx, user, update)
+		if changed {
+			klog.V(2).InfoS("User profile updated", "user", user)
+		}
+		return err
+	})
+}
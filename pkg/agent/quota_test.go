@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/champly/ai-agent/pkg/filter"
+)
+
+// quotaConfig 每日限 1 次请求，拦截包含 forbidden 的用户消息
+const quotaConfig = `quota:
+  enabled: true
+  daily:
+    requests: 1
+filters:
+  rules:
+    - name: forbidden
+      pattern: forbidden
+      action: block
+      stages: [input]
+`
+
+// requestsUsed 返回用户当天已计入的请求数
+func requestsUsed(t *testing.T, a *Agent, user string) int64 {
+	t.Helper()
+	report, err := a.quota.Report(t.Context(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report.Periods[0].Used.Requests
+}
+
+func TestChatQuotaNotChargedWhenFiltered(t *testing.T) {
+	a := newTestAgent(t, echo, quotaConfig)
+	ctx := WithUser(t.Context(), "alice")
+
+	if _, err := a.Chat(ctx, &ChatRequest{Message: "forbidden"}); !errors.Is(err, filter.ErrBlocked) {
+		t.Fatalf("got %v, want ErrBlocked", err)
+	}
+	if _, err := a.ChatWithRAG(ctx, &ChatRequest{Message: "forbidden"}); !errors.Is(err, filter.ErrBlocked) {
+		t.Fatalf("got %v, want ErrBlocked", err)
+	}
+	if n := requestsUsed(t, a, "alice"); n != 0 {
+		t.Fatalf("blocked requests charged %d requests, want 0", n)
+	}
+	if _, err := a.Chat(ctx, &ChatRequest{Message: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChatQuotaNotChargedWhenBusy(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	// 限额为 2，第二次请求能通过配额检查并在开始处理时因对话忙而失败
+	a := newTestAgent(t, blockingReply(started, release), strings.Replace(quotaConfig, "requests: 1", "requests: 2", 1)+"conversation:\n  queue_timeout: 5s\n")
+	ctx := WithUser(t.Context(), "bob")
+	id := "busy"
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := a.Chat(ctx, &ChatRequest{Message: "first", ConversationID: id})
+		done <- err
+	}()
+	<-started
+
+	if _, err := a.Chat(ctx, &ChatRequest{Message: "second", ConversationID: id, NoWait: true}); !errors.Is(err, ErrConversationBusy) {
+		t.Fatalf("got %v, want ErrConversationBusy", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := requestsUsed(t, a, "bob"); n != 1 {
+		t.Fatalf("charged %d requests, want 1 (the busy request should be refunded)", n)
+	}
+}
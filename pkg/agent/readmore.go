@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/champly/ai-agent/pkg/spill"
+)
+
+// builtinSource Agent 内置工具的来源
+const builtinSource = "builtin"
+
+// readMoreTool 读取转存的大工具结果的内置工具名
+const readMoreTool = "read_more"
+
+// newReadMoreTool 创建 read_more 工具，只能读取当前对话中转存的结果
+func newReadMoreTool(store *spill.Store) *ToolInfo {
+	return &ToolInfo{
+		Name:   readMoreTool,
+		Source: builtinSource,
+		MCPTool: &mcp.Tool{
+			Name:        readMoreTool,
+			Description: "分段读取被截断为预览的大工具结果，使用预览末尾给出的 handle 和 offset",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"handle": map[string]any{"type": "string", "description": "结果句柄"},
+					"offset": map[string]any{"type": "integer", "description": "开始读取的字节位置，默认 0"},
+					"limit":  map[string]any{"type": "integer", "description": "最多读取的字节数"},
+				},
+				"required": []any{"handle"},
+			},
+		},
+		Executor: &readMoreExecutor{store: store},
+	}
+}
+
+// readMoreExecutor read_more 工具执行器
+type readMoreExecutor struct {
+	store *spill.Store
+}
+
+// Execute 执行工具
+func (e *readMoreExecutor) Execute(ctx context.Context, args map[string]any) (string, error) {
+	handle, _ := args["handle"].(string)
+	if handle == "" {
+		return "", fmt.Errorf("handle is required")
+	}
+
+	content, next, total, err := e.store.Read(conversationIDFromContext(ctx), handle, intArg(args, "offset"), intArg(args, "limit"))
+	if err != nil {
+		return "", err
+	}
+	if next < total {
+		content += fmt.Sprintf("\n\n[已读取到 %d / %d 字节，继续读取请调用 read_more，参数 handle=%q、offset=%d]", next, total, handle, next)
+	} else {
+		content += fmt.Sprintf("\n\n[已读取到结尾，共 %d 字节]", total)
+	}
+	return content, nil
+}
+
+// intArg 读取整数参数，模型可能以数字或字符串形式传入
+func intArg(args map[string]any, name string) int64 {
+	switch v := args[name].(type) {
+	case float64:
+		return int64(v)
+	case string:
+		var n int64
+		fmt.Sscan(v, &n)
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// ErrInvalidStop 请求中的停止序列为空或数量超出限制
+var ErrInvalidStop = errors.New("invalid stop sequences")
+
+// maxStopSequences 单个请求最多指定的停止序列数
+const maxStopSequences = 16
+
+// checkStop 校验请求中的停止序列
+func checkStop(stop []string) error {
+	if len(stop) > maxStopSequences {
+		return fmt.Errorf("%w: %d sequences exceeds limit of %d", ErrInvalidStop, len(stop), maxStopSequences)
+	}
+	for i, s := range stop {
+		if s == "" {
+			return fmt.Errorf("%w: stop[%d] is empty", ErrInvalidStop, i)
+		}
+	}
+	return nil
+}
+
+// withStopOption 在模型参数中加入停止序列，不修改原参数；stop 为空时原样返回
+func withStopOption(options map[string]any, stop []string) map[string]any {
+	if len(stop) == 0 {
+		return options
+	}
+	options = maps.Clone(options)
+	if options == nil {
+		options = make(map[string]any)
+	}
+	options["stop"] = stop
+	return options
+}
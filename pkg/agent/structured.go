@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+var (
+	// ErrInvalidSchema 请求中的 JSON Schema 无法解析
+	ErrInvalidSchema = errors.New("invalid output schema")
+	// ErrStructuredOutput 修复重试后模型的回答仍不符合 JSON Schema
+	ErrStructuredOutput = errors.New("answer does not match output schema")
+)
+
+// outputSchema 请求要求的最终回答格式
+type outputSchema struct {
+	raw      json.RawMessage
+	resolved *jsonschema.Resolved
+}
+
+// parseOutputSchema 解析请求中的 JSON Schema（按 draft 2020-12 校验），未指定时返回 nil
+func parseOutputSchema(raw json.RawMessage) (*outputSchema, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var schema jsonschema.Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+	// 常见的 draft-07 写法与 2020-12 基本兼容，统一按 2020-12 处理
+	schema.Schema = ""
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+	return &outputSchema{raw: raw, resolved: resolved}, nil
+}
+
+// parse 从回答中取出 JSON（允许包在 Markdown 代码块中）并按 Schema 校验，返回紧凑的 JSON
+func (s *outputSchema) parse(content string) (json.RawMessage, error) {
+	text := strings.TrimSpace(content)
+	if rest, ok := strings.CutPrefix(text, "```"); ok {
+		// 去掉语言标记所在的行和结尾的 ```
+		if _, body, found := strings.Cut(rest, "\n"); found {
+			text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(body), "```"))
+		}
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %w", err)
+	}
+	if err := s.resolved.Validate(value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
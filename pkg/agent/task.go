@@ -0,0 +1,646 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/analyzer"
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/filter"
+	"github.com/champly/ai-agent/pkg/jobqueue"
+	"github.com/champly/ai-agent/pkg/locale"
+	"github.com/champly/ai-agent/pkg/priority"
+	"github.com/champly/ai-agent/pkg/prompt"
+	"github.com/champly/ai-agent/pkg/quota"
+	"github.com/champly/ai-agent/pkg/validator"
+)
+
+// TaskStatus 后台任务状态
+type TaskStatus string
+
+const (
+	// TaskQueued 等待执行（包括定时执行和失败后等待重试）
+	TaskQueued TaskStatus = "queued"
+	// TaskRunning 正在执行（包括等待 worker 和对话中的上一轮）
+	TaskRunning TaskStatus = "running"
+	// TaskSucceeded 已完成，Result 为回答（仓库分析任务为 Report）
+	TaskSucceeded TaskStatus = "succeeded"
+	// TaskFailed 执行出错或超时，重试次数用尽时转入死信
+	TaskFailed TaskStatus = "failed"
+	// TaskCanceled 被取消或服务停止
+	TaskCanceled TaskStatus = "canceled"
+)
+
+// 任务在队列中的类型
+const (
+	chatJobKind    = "chat"
+	analyzeJobKind = "analyze"
+	reviewJobKind  = "review"
+)
+
+var (
+	// ErrTaskNotFound 任务不存在、已过期或属于其他用户
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrTooManyTasks 进行中的任务数达到 tasks.max_active
+	ErrTooManyTasks = errors.New("too many active tasks")
+)
+
+// TaskOptions 提交后台任务的选项
+type TaskOptions struct {
+	RAG   bool      // 按 ChatWithRAG 处理
+	RunAt time.Time // 定时执行，为零时立即执行
+}
+
+// Task 后台任务的状态快照
+type Task struct {
+	ID             string           `json:"id"`
+	Kind           string           `json:"kind"` // chat、analyze 或 review
+	Status         TaskStatus       `json:"status"`
+	ConversationID string           `json:"conversation_id,omitempty"`
+	RAG            bool             `json:"rag,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	RunAt          time.Time        `json:"run_at,omitzero"` // 等待执行时的计划执行时间
+	FinishedAt     time.Time        `json:"finished_at,omitzero"`
+	Attempts       int              `json:"attempts"` // 已开始执行的次数
+	Events         int              `json:"events"`   // 已产生的事件数
+	Result         *ChatResponse    `json:"result,omitempty"`
+	Report         *analyzer.Report `json:"report,omitempty"` // 仓库分析任务的报告
+	Error          string           `json:"error,omitempty"`  // 失败原因，等待重试时为上次执行的错误
+
+	// Err 失败原因，供调用方区分错误类型（不参与序列化）
+	Err error `json:"-"`
+}
+
+// Done 任务是否已结束
+func (t *Task) Done() bool {
+	return t.Status != TaskQueued && t.Status != TaskRunning
+}
+
+// taskPayload 任务在队列中保存的内容，重启后据此重新执行
+type taskPayload struct {
+	Request     ChatRequest         `json:"request"`
+	RAG         bool                `json:"rag,omitempty"`
+	Analysis    *analyzer.Request   `json:"analysis,omitempty"` // 仓库分析任务的请求
+	Review      *githubReview       `json:"review,omitempty"`   // 拉取请求审查任务的请求
+	User        string              `json:"user,omitempty"`
+	ToolProfile *config.ToolProfile `json:"tool_profile,omitempty"`
+}
+
+// taskResult 任务的执行结果
+type taskResult struct {
+	Response *ChatResponse
+	Report   *analyzer.Report
+}
+
+// task 任务的内部状态
+type task struct {
+	user    string
+	onEvent EventHandler // 提交方的事件回调，重启后恢复的任务没有
+
+	mu       sync.Mutex
+	info     Task
+	events   []Event
+	canceled bool
+	cancel   context.CancelFunc // 执行中时取消本次执行
+	changed  chan struct{}      // 每次更新时关闭并替换，用于通知等待者
+}
+
+// snapshot 返回状态快照
+func (t *task) snapshot() *Task {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshotLocked()
+}
+
+// snapshotLocked 返回状态快照，调用方需持有 t.mu
+func (t *task) snapshotLocked() *Task {
+	info := t.info
+	info.Events = len(t.events)
+	return &info
+}
+
+// addEvent 记录对话循环产生的事件
+func (t *task) addEvent(ev Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, ev)
+	t.notifyLocked()
+}
+
+// start 开始第 attempts 次执行，任务已取消或已结束时返回 false
+func (t *task) start(attempts int, cancel context.CancelFunc) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.canceled || t.info.Done() {
+		return false
+	}
+	t.info.Status = TaskRunning
+	t.info.Attempts = attempts
+	t.info.RunAt = time.Time{}
+	t.cancel = cancel
+	t.notifyLocked()
+	return true
+}
+
+// retry 本次执行失败，等待 at 时重试
+func (t *task) retry(err error, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.info.Status = TaskQueued
+	t.info.RunAt = at
+	t.info.Error = err.Error()
+	t.info.Err = err
+	t.cancel = nil
+	t.notifyLocked()
+}
+
+// finish 记录执行结果，任务已结束时返回 false
+func (t *task) finish(res taskResult, err error) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.info.Done() {
+		return false
+	}
+	t.info.FinishedAt = time.Now()
+	t.info.RunAt = time.Time{}
+	t.cancel = nil
+	switch {
+	case err == nil:
+		t.info.Status = TaskSucceeded
+		t.info.Result = res.Response
+		t.info.Report = res.Report
+		t.info.Error = ""
+		t.info.Err = nil
+	case t.canceled:
+		t.info.Status = TaskCanceled
+		t.info.Error = err.Error()
+		t.info.Err = err
+	default:
+		t.info.Status = TaskFailed
+		t.info.Error = err.Error()
+		t.info.Err = err
+	}
+	t.notifyLocked()
+	return true
+}
+
+// notifyLocked 唤醒所有等待者
+func (t *task) notifyLocked() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// taskManager 后台任务管理，任务经由持久化队列执行
+type taskManager struct {
+	cfg    config.TaskConfig
+	queue  *jobqueue.Queue
+	ctx    context.Context // 服务停止时取消所有任务
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	tasks  map[string]*task
+	active int // 未结束的任务数
+}
+
+// newTaskManager 创建任务管理器
+func newTaskManager(cfg config.TaskConfig, redisCfg config.RedisConfig) (*taskManager, error) {
+	queue, err := jobqueue.New(cfg.Queue, redisCfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &taskManager{
+		cfg:    cfg,
+		queue:  queue,
+		ctx:    ctx,
+		cancel: cancel,
+		tasks:  make(map[string]*task),
+	}, nil
+}
+
+// start 开始执行队列中的任务，包括上次停止时未完成的任务
+func (m *taskManager) start() {
+	m.queue.Start(m.ctx, m.cfg.MaxActive)
+}
+
+// sweepLocked 删除结束超过 TTL 的任务
+func (m *taskManager) sweepLocked() {
+	for id, t := range m.tasks {
+		info := t.snapshot()
+		if info.Done() && time.Since(info.FinishedAt) > m.cfg.TTL {
+			delete(m.tasks, id)
+		}
+	}
+}
+
+// lookup 查找 context 中用户的任务
+func (m *taskManager) lookup(ctx context.Context, id string) (*task, error) {
+	m.mu.Lock()
+	t, ok := m.tasks[id]
+	m.mu.Unlock()
+	if !ok || t.user != UserFromContext(ctx) {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+	return t, nil
+}
+
+// track 为队列中的任务建立本地状态，已有未结束的同 ID 任务时直接返回；
+// 用于重启后恢复的任务、其他副本提交的任务和从死信重新执行的任务
+func (m *taskManager) track(job *jobqueue.Job, p *taskPayload) *task {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.tasks[job.ID]; ok && !t.snapshot().Done() {
+		return t
+	}
+	t := &task{
+		user: p.User,
+		info: Task{
+			ID:             job.ID,
+			Kind:           job.Kind,
+			Status:         TaskQueued,
+			ConversationID: p.Request.ConversationID,
+			RAG:            p.RAG,
+			CreatedAt:      job.CreatedAt,
+			RunAt:          job.RunAt,
+		},
+		changed: make(chan struct{}),
+	}
+	m.tasks[job.ID] = t
+	m.active++
+	return t
+}
+
+// finish 记录任务结果并释放名额
+func (m *taskManager) finish(t *task, res taskResult, err error) {
+	if !t.finish(res, err) {
+		return
+	}
+	m.mu.Lock()
+	m.active--
+	m.mu.Unlock()
+
+	info := t.snapshot()
+	klog.V(2).InfoS("Task finished", "taskID", info.ID, "status", info.Status, "attempts", info.Attempts,
+		"duration", info.FinishedAt.Sub(info.CreatedAt), "error", info.Error)
+}
+
+// close 停止执行任务，执行中的任务放回队列，下次启动后继续
+func (m *taskManager) close() {
+	m.cancel()
+	if err := m.queue.Close(); err != nil {
+		klog.ErrorS(err, "Failed to close task queue")
+	}
+}
+
+// SubmitTask 将聊天请求放入任务队列并立即返回任务，opts.RunAt 不为零时定时执行。
+// 未指定对话 ID 时预先分配，执行期间即可通过对话接口查看进度；
+// 任务只受 tasks.timeout 和 CancelTask 限制，与提交请求的连接无关，失败时按 tasks.queue 的配置重试
+func (a *Agent) SubmitTask(ctx context.Context, req *ChatRequest, opts TaskOptions) (*Task, error) {
+	// 提交时先校验模板和变量，执行时再渲染（读取文件、检索知识库）
+	if err := a.checkPrompt(req.Template, req); err != nil {
+		return nil, err
+	}
+	if _, err := a.persona(req.Persona); err != nil {
+		return nil, err
+	}
+	if _, err := a.answerChecks(req); err != nil {
+		return nil, err
+	}
+	if _, err := locale.Normalize(req.Language); err != nil {
+		return nil, err
+	}
+	if req.ConversationID == "" {
+		req.ConversationID = generateConversationID()
+	}
+	return a.submitTask(ctx, chatJobKind, &taskPayload{Request: *req, RAG: opts.RAG}, req.OnEvent, opts.RunAt)
+}
+
+// submitTask 将任务放入队列，p 中的用户和工具范围取自 ctx
+func (a *Agent) submitTask(ctx context.Context, kind string, p *taskPayload, onEvent EventHandler, runAt time.Time) (*Task, error) {
+	m := a.tasks
+	m.mu.Lock()
+	m.sweepLocked()
+	if m.active >= m.cfg.MaxActive {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("%w: at most %d allowed", ErrTooManyTasks, m.cfg.MaxActive)
+	}
+	m.active++
+
+	user := UserFromContext(ctx)
+	t := &task{
+		user:    user,
+		onEvent: onEvent,
+		info: Task{
+			ID:             uuid.New().String(),
+			Kind:           kind,
+			Status:         TaskQueued,
+			ConversationID: p.Request.ConversationID,
+			RAG:            p.RAG,
+			CreatedAt:      time.Now(),
+			RunAt:          runAt,
+		},
+		changed: make(chan struct{}),
+	}
+	m.tasks[t.info.ID] = t
+	m.mu.Unlock()
+
+	// 保留用户身份和工具范围，重启后按相同的身份执行
+	p.User = user
+	p.ToolProfile = toolProfileFromContext(ctx)
+	payload, err := json.Marshal(p)
+	if err == nil {
+		err = m.queue.Enqueue(ctx, &jobqueue.Job{
+			ID:        t.info.ID,
+			Kind:      kind,
+			User:      user,
+			Payload:   payload,
+			RunAt:     runAt,
+			CreatedAt: t.info.CreatedAt,
+		})
+	}
+	if err != nil {
+		m.mu.Lock()
+		delete(m.tasks, t.info.ID)
+		m.active--
+		m.mu.Unlock()
+		return nil, err
+	}
+
+	klog.V(2).InfoS("Task submitted", "taskID", t.info.ID, "kind", kind, "conversationID", p.Request.ConversationID,
+		"rag", p.RAG, "runAt", runAt)
+	return t.snapshot(), nil
+}
+
+// runTask 执行队列中的聊天、仓库分析或拉取请求审查任务。需要重试时返回错误，由队列按退避重新执行
+func (a *Agent) runTask(ctx context.Context, job *jobqueue.Job) error {
+	var p taskPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return jobqueue.Permanent(fmt.Errorf("parse task payload: %w", err))
+	}
+
+	m := a.tasks
+	t := m.track(job, &p)
+	attemptCtx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+	defer cancel()
+	// 在开始前已被取消
+	if !t.start(job.Attempts, cancel) {
+		return nil
+	}
+	if job.Attempts > 1 {
+		klog.V(2).InfoS("Task retrying", "taskID", job.ID, "attempts", job.Attempts, "lastError", job.LastError)
+	}
+
+	onEvent := func(ev Event) {
+		t.addEvent(ev)
+		t.onEvent.emit(ev)
+	}
+	runCtx := priority.WithClass(WithUser(attemptCtx, p.User), priority.Batch)
+	if p.ToolProfile != nil {
+		runCtx = WithToolProfile(runCtx, p.ToolProfile)
+	}
+
+	var res taskResult
+	var err error
+	switch {
+	case job.Kind == analyzeJobKind && p.Analysis != nil:
+		res.Report, err = a.analyze(runCtx, p.Analysis, onEvent)
+	case job.Kind == analyzeJobKind:
+		err = jobqueue.Permanent(errors.New("analysis request is missing"))
+	case job.Kind == reviewJobKind && p.Review != nil:
+		res.Response, err = a.reviewPullRequest(runCtx, p.Review, p.Request.ConversationID, onEvent)
+	case job.Kind == reviewJobKind:
+		err = jobqueue.Permanent(errors.New("review request is missing"))
+	case p.RAG:
+		req := p.Request
+		req.OnEvent = onEvent
+		res.Response, err = a.ChatWithRAG(runCtx, &req)
+	default:
+		req := p.Request
+		req.OnEvent = onEvent
+		res.Response, err = a.Chat(runCtx, &req)
+	}
+
+	t.mu.Lock()
+	// 通过 StopConversation 停止任务所在的对话时视同取消
+	if errors.Is(err, ErrTurnStopped) {
+		t.canceled = true
+	}
+	canceled := t.canceled
+	t.mu.Unlock()
+	switch {
+	case err == nil:
+		m.finish(t, res, nil)
+		return nil
+	case canceled:
+		m.finish(t, taskResult{}, err)
+		return nil
+	case ctx.Err() != nil:
+		// 服务停止，任务由队列保留到下次启动
+		t.mu.Lock()
+		t.canceled = true
+		t.mu.Unlock()
+		m.finish(t, taskResult{}, err)
+		return err
+	}
+
+	// 超时、配额、过滤和权限类错误重试也不会成功
+	if attemptCtx.Err() != nil || !retryable(err) {
+		err = jobqueue.Permanent(err)
+	}
+	if at, ok := m.queue.RetryAt(job, err); ok {
+		t.retry(err, at)
+		return err
+	}
+	m.finish(t, taskResult{}, err)
+	return err
+}
+
+// retryable 任务失败后是否值得重试
+func retryable(err error) bool {
+	var exceeded *quota.ExceededError
+	return !errors.As(err, &exceeded) &&
+		!errors.Is(err, analyzer.ErrEmptyRepository) &&
+		!errors.Is(err, filter.ErrBlocked) &&
+		!errors.Is(err, prompt.ErrNotFound) &&
+		!errors.Is(err, prompt.ErrInvalid) &&
+		!errors.Is(err, ErrPersonaNotFound) &&
+		!errors.Is(err, ErrInvalidSchema) &&
+		!errors.Is(err, validator.ErrNotFound) &&
+		!errors.Is(err, locale.ErrUnsupported) &&
+		!errors.Is(err, ErrConversationForbidden) &&
+		!errors.Is(err, ErrNoTenant) &&
+		!errors.Is(err, ErrTenantForbidden)
+}
+
+// GetTask 返回 context 中用户的任务状态
+func (a *Agent) GetTask(ctx context.Context, id string) (*Task, error) {
+	t, err := a.tasks.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return t.snapshot(), nil
+}
+
+// ListTasks 列出 context 中用户的任务，按提交时间从新到旧排列
+func (a *Agent) ListTasks(ctx context.Context) []*Task {
+	user := UserFromContext(ctx)
+	m := a.tasks
+	m.mu.Lock()
+	m.sweepLocked()
+	result := make([]*Task, 0, len(m.tasks))
+	for _, t := range m.tasks {
+		if t.user == user {
+			result = append(result, t.snapshot())
+		}
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(result, func(x, y *Task) int {
+		return y.CreatedAt.Compare(x.CreatedAt)
+	})
+	return result
+}
+
+// CancelTask 取消等待或进行中的任务，已结束的任务不受影响
+func (a *Agent) CancelTask(ctx context.Context, id string) (*Task, error) {
+	m := a.tasks
+	t, err := m.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	status, cancel := t.info.Status, t.cancel
+	if !t.info.Done() {
+		t.canceled = true
+	}
+	t.mu.Unlock()
+
+	switch status {
+	case TaskQueued:
+		if err := m.queue.Cancel(ctx, id); err != nil {
+			return nil, err
+		}
+		m.finish(t, taskResult{}, context.Canceled)
+	case TaskRunning:
+		if cancel != nil {
+			cancel()
+		}
+	}
+	return t.snapshot(), nil
+}
+
+// DeadTask 重试次数用尽或不可重试而转入死信的任务
+type DeadTask struct {
+	ID             string            `json:"id"`
+	Kind           string            `json:"kind"`
+	ConversationID string            `json:"conversation_id,omitempty"`
+	Message        string            `json:"message,omitempty"`
+	Template       string            `json:"template,omitempty"`
+	RAG            bool              `json:"rag,omitempty"`
+	Analysis       *analyzer.Request `json:"analysis,omitempty"`
+	Attempts       int               `json:"attempts"`
+	CreatedAt      time.Time         `json:"created_at"`
+	FailedAt       time.Time         `json:"failed_at"`
+	Error          string            `json:"error"`
+}
+
+// ListDeadTasks 列出 context 中用户转入死信的任务，按失败时间从新到旧排列
+func (a *Agent) ListDeadTasks(ctx context.Context) ([]*DeadTask, error) {
+	jobs, err := a.tasks.queue.DeadLetters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user := UserFromContext(ctx)
+	result := make([]*DeadTask, 0, len(jobs))
+	for _, job := range jobs {
+		if job.User != user || !slices.Contains([]string{chatJobKind, analyzeJobKind, reviewJobKind}, job.Kind) {
+			continue
+		}
+		var p taskPayload
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			klog.ErrorS(err, "Failed to parse dead task payload", "taskID", job.ID)
+		}
+		result = append(result, &DeadTask{
+			ID:             job.ID,
+			Kind:           job.Kind,
+			ConversationID: p.Request.ConversationID,
+			Message:        p.Request.Message,
+			Template:       p.Request.Template,
+			RAG:            p.RAG,
+			Analysis:       p.Analysis,
+			Attempts:       job.Attempts,
+			CreatedAt:      job.CreatedAt,
+			FailedAt:       job.FailedAt,
+			Error:          job.LastError,
+		})
+	}
+	return result, nil
+}
+
+// deadJob 查找 context 中用户的死信任务
+func (a *Agent) deadJob(ctx context.Context, id string) error {
+	tasks, err := a.ListDeadTasks(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(tasks, func(t *DeadTask) bool { return t.ID == id }) {
+		return fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+	return nil
+}
+
+// RetryDeadTask 将死信中的任务重新放回队列，重试次数清零
+func (a *Agent) RetryDeadTask(ctx context.Context, id string) (*Task, error) {
+	if err := a.deadJob(ctx, id); err != nil {
+		return nil, err
+	}
+	job, err := a.tasks.queue.Requeue(ctx, id)
+	if errors.Is(err, jobqueue.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var p taskPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+	klog.V(2).InfoS("Dead task requeued", "taskID", id)
+	return a.tasks.track(job, &p).snapshot(), nil
+}
+
+// DiscardDeadTask 删除死信中的任务
+func (a *Agent) DiscardDeadTask(ctx context.Context, id string) error {
+	if err := a.deadJob(ctx, id); err != nil {
+		return err
+	}
+	err := a.tasks.queue.Discard(ctx, id)
+	if errors.Is(err, jobqueue.ErrNotFound) {
+		return fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+	return err
+}
+
+// TaskEvents 返回任务从第 after 个开始的事件和当前状态；任务未结束时 changed 在下次更新时关闭，
+// 用于流式推送进度
+func (a *Agent) TaskEvents(ctx context.Context, id string, after int) (events []Event, info *Task, changed <-chan struct{}, err error) {
+	t, err := a.tasks.lookup(ctx, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if after < len(t.events) {
+		events = slices.Clone(t.events[max(after, 0):])
+	}
+	return events, t.snapshotLocked(), t.changed, nil
+}
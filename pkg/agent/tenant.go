@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/rag"
+)
+
+var (
+	// ErrUnknownCapability 请求中的工具能力名称未定义
+	ErrUnknownCapability = errors.New("unknown tool capability")
+	// ErrNoTenant 配置了租户但用户不属于任何租户
+	ErrNoTenant = errors.New("user does not belong to any tenant")
+	// ErrTenantForbidden 租户用户不允许执行该操作
+	ErrTenantForbidden = errors.New("operation not allowed for tenant users")
+)
+
+// TenantFor 返回 context 中用户所属的租户；进程内调用或未配置租户时返回 nil
+func (a *Agent) TenantFor(ctx context.Context) (*config.TenantConfig, error) {
+	user := UserFromContext(ctx)
+	if user == "" || len(a.cfg.Tenants) == 0 {
+		return nil, nil
+	}
+	for i := range a.cfg.Tenants {
+		for _, pattern := range a.cfg.Tenants[i].Users {
+			if ok, _ := path.Match(pattern, user); ok {
+				return &a.cfg.Tenants[i], nil
+			}
+		}
+	}
+	return nil, ErrNoTenant
+}
+
+// toolAllowed 工具范围是否包含该工具，profile 为 nil 表示不限制
+func toolAllowed(profile *config.ToolProfile, tool *ToolInfo) bool {
+	// 内置工具只访问当前对话自己的数据
+	if profile == nil || tool.Source == builtinSource {
+		return true
+	}
+	if deniesCapability(profile.DenyCapabilities, tool) {
+		return false
+	}
+	if len(profile.MCPServers) > 0 {
+		server, ok := toolServer(tool)
+		if !ok || !slices.Contains(profile.MCPServers, server) {
+			return false
+		}
+	}
+	if len(profile.Tools) == 0 {
+		return true
+	}
+	for _, pattern := range profile.Tools {
+		if ok, _ := path.Match(pattern, tool.Name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// toolServer 工具范围的 mcp_servers 中对应该工具的名称：MCP 服务器名、插件名、OpenAPI 名称、gRPC 工具服务名称，
+// Go 函数工具为 go，组合工具为 composite，GitHub 工具为 github
+func toolServer(tool *ToolInfo) (string, bool) {
+	if tool.Source == goToolSource || tool.Source == compositeSource || tool.Source == githubSource {
+		return tool.Source, true
+	}
+	if name, ok := strings.CutPrefix(tool.Source, pluginSourcePrefix); ok {
+		return name, true
+	}
+	if name, ok := strings.CutPrefix(tool.Source, openAPISourcePrefix); ok {
+		return name, true
+	}
+	if name, ok := strings.CutPrefix(tool.Source, grpcSourcePrefix); ok {
+		return name, true
+	}
+	return strings.CutPrefix(tool.Source, mcpSourcePrefix)
+}
+
+// toolAllowedFor 租户、context 中的工具范围和人设是否都允许该工具
+func toolAllowedFor(ctx context.Context, tenant *config.TenantConfig, tool *ToolInfo) bool {
+	if tenant != nil && !toolAllowed(&tenant.ToolProfile, tool) {
+		return false
+	}
+	if persona := personaFromContext(ctx); persona != nil && !toolAllowed(&persona.ToolProfile, tool) {
+		return false
+	}
+	if tool.Source != builtinSource && deniesCapability(deniedCapabilitiesFromContext(ctx), tool) {
+		return false
+	}
+	return toolAllowed(toolProfileFromContext(ctx), tool)
+}
+
+// deniesCapability 工具是否具有 denied 中的任一能力
+func deniesCapability(denied []string, tool *ToolInfo) bool {
+	return slices.ContainsFunc(denied, tool.Capabilities.Has)
+}
+
+// checkCapabilities 校验请求中的工具能力名称
+func checkCapabilities(names []string) error {
+	for _, name := range names {
+		if !config.ValidCapability(name) {
+			return fmt.Errorf("%w: %s", ErrUnknownCapability, name)
+		}
+	}
+	return nil
+}
+
+// tenantTools 返回 context 中用户可用的工具
+func (a *Agent) tenantTools(ctx context.Context) []*ToolInfo {
+	tenant, err := a.TenantFor(ctx)
+	if err != nil {
+		return nil
+	}
+	return slices.DeleteFunc(a.toolRegistry.List(), func(tool *ToolInfo) bool {
+		return !toolAllowedFor(ctx, tenant, tool)
+	})
+}
+
+// tenantCollection 将集合名称映射到租户的命名空间；collection 为空时，
+// 检索（search 为 true）映射为租户的全部集合，写入映射为租户的默认集合
+func (a *Agent) tenantCollection(ctx context.Context, collection string, search bool) (string, error) {
+	tenant, err := a.TenantFor(ctx)
+	if err != nil || tenant == nil {
+		return collection, err
+	}
+	if collection == "" {
+		if search {
+			return tenant.Name + "/", nil
+		}
+		collection = rag.DefaultCollection
+	}
+	return tenant.Name + "/" + collection, nil
+}
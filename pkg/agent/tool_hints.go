@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/ollama/ollama/api"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// MCP 工具在 _meta 中提供使用说明和调用示例的键
+const (
+	// metaUsage 使用说明（字符串）
+	metaUsage = "ai-agent/usage"
+	// metaExamples 调用示例，格式同 tool_hints 配置：[{"request": "...", "arguments": {...}}]
+	metaExamples = "ai-agent/examples"
+)
+
+// toolHints 工具的使用说明和调用示例，配置中的优先于 MCP 工具 _meta 中提供的
+type toolHints struct {
+	system bool // 汇总为系统消息而不是追加到工具描述
+	tools  map[string]config.ToolHintConfig
+}
+
+// newToolHints 创建工具说明
+func newToolHints(cfg config.ToolHintsConfig) *toolHints {
+	h := &toolHints{
+		system: cfg.Placement == "system",
+		tools:  make(map[string]config.ToolHintConfig, len(cfg.Tools)),
+	}
+	for _, hint := range cfg.Tools {
+		h.tools[hint.Tool] = hint
+	}
+	return h
+}
+
+// hint 返回工具的说明文本，没有说明时返回空
+func (h *toolHints) hint(tool *mcp.Tool) string {
+	usage, examples := hintFromMeta(tool.Meta)
+	if c, ok := h.tools[tool.Name]; ok {
+		if c.Usage != "" {
+			usage = c.Usage
+		}
+		if len(c.Examples) > 0 {
+			examples = c.Examples
+		}
+	}
+
+	var lines []string
+	if usage = strings.TrimSpace(usage); usage != "" {
+		lines = append(lines, "用法："+usage)
+	}
+	if note := annotationNote(tool.Annotations); note != "" {
+		lines = append(lines, note)
+	}
+	if len(examples) > 0 {
+		lines = append(lines, "示例：")
+		for _, ex := range examples {
+			args, err := json.Marshal(ex.Arguments)
+			if err != nil || ex.Arguments == nil {
+				args = []byte("{}")
+			}
+			lines = append(lines, fmt.Sprintf("- %q → %s(%s)", ex.Request, tool.Name, args))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hintFromMeta 读取 MCP 工具 _meta 中的使用说明和调用示例，格式不正确的部分忽略
+func hintFromMeta(meta mcp.Meta) (string, []config.ToolExampleConfig) {
+	usage, _ := meta[metaUsage].(string)
+	raw, ok := meta[metaExamples]
+	if !ok {
+		return usage, nil
+	}
+	// 客户端收到的 _meta 是通用的 JSON 值，重新编码后按示例格式解析
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return usage, nil
+	}
+	var examples []config.ToolExampleConfig
+	if err := json.Unmarshal(data, &examples); err != nil {
+		return usage, nil
+	}
+	valid := examples[:0]
+	for _, ex := range examples {
+		if ex.Request != "" {
+			valid = append(valid, ex)
+		}
+	}
+	return usage, valid
+}
+
+// annotationNote 根据 MCP 工具注解生成的提示：只读工具可以放心调用，破坏性工具仅在用户明确要求时调用
+func annotationNote(ann *mcp.ToolAnnotations) string {
+	switch {
+	case ann == nil:
+		return ""
+	case ann.ReadOnlyHint:
+		return "只读，不会修改任何内容。"
+	case ann.DestructiveHint != nil && *ann.DestructiveHint:
+		return "会修改或删除数据，仅在用户明确要求时调用。"
+	}
+	return ""
+}
+
+// describeTool 返回 Ollama Tool 定义，placement 为 description 时说明追加到工具描述之后
+func (a *Agent) describeTool(tool *ToolInfo) api.Tool {
+	ollamaTool := MCPToolToOllamaTool(tool.MCPTool)
+	if a.hints.system {
+		return ollamaTool
+	}
+	if hint := a.hints.hint(tool.MCPTool); hint != "" {
+		ollamaTool.Function.Description = strings.TrimSpace(ollamaTool.Function.Description + "\n\n" + hint)
+	}
+	return ollamaTool
+}
+
+// toolGuide placement 为 system 时汇总本次请求可用工具的说明，作为系统消息放在对话之前（不写入对话历史）；
+// 没有说明时返回空
+func (a *Agent) toolGuide(tools []api.Tool) string {
+	if !a.hints.system {
+		return ""
+	}
+	var b strings.Builder
+	for _, t := range tools {
+		info := a.toolRegistry.Get(t.Function.Name)
+		if info == nil {
+			continue
+		}
+		if hint := a.hints.hint(info.MCPTool); hint != "" {
+			fmt.Fprintf(&b, "\n\n## %s\n%s", t.Function.Name, hint)
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "工具使用说明：" + b.String()
+}
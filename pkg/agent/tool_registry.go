@@ -2,9 +2,13 @@ package agent
 
 import (
 	"context"
+	"path"
+	"strings"
 	"sync"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/champly/ai-agent/pkg/config"
 )
 
 // ToolExecutor 工具执行器接口
@@ -14,30 +18,142 @@ type ToolExecutor interface {
 
 // ToolInfo 工具信息
 type ToolInfo struct {
-	Name     string
-	Source   string // "local_mcp", "external_mcp", etc.
-	MCPTool  *mcp.Tool
-	Executor ToolExecutor
+	Name         string
+	Source       string // "local_mcp", "external_mcp", etc.
+	MCPTool      *mcp.Tool
+	Executor     ToolExecutor
+	Capabilities ToolCapabilities // 注册时按 tool_capabilities 配置或 MCP 工具注解设置
+}
+
+// ToolCapabilities 工具的能力标记，用于按能力限制可用的工具
+type ToolCapabilities struct {
+	ReadOnly    bool `json:"read_only,omitempty"`
+	Destructive bool `json:"destructive,omitempty"`
+	Network     bool `json:"network,omitempty"`
+	LongRunning bool `json:"long_running,omitempty"`
+}
+
+// Has 是否具有名为 name 的能力（config.Capability*）
+func (c ToolCapabilities) Has(name string) bool {
+	switch name {
+	case config.CapabilityReadOnly:
+		return c.ReadOnly
+	case config.CapabilityDestructive:
+		return c.Destructive
+	case config.CapabilityNetwork:
+		return c.Network
+	case config.CapabilityLongRunning:
+		return c.LongRunning
+	}
+	return false
+}
+
+// Names 返回具有的能力名称
+func (c ToolCapabilities) Names() []string {
+	var names []string
+	for _, name := range []string{config.CapabilityReadOnly, config.CapabilityDestructive, config.CapabilityNetwork, config.CapabilityLongRunning} {
+		if c.Has(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// capabilitiesOf 按第一条匹配的规则设置能力；没有匹配的规则时取 MCP 工具注解中明确给出的提示
+func capabilitiesOf(tool *ToolInfo, rules []config.ToolCapabilityRule) ToolCapabilities {
+	var c ToolCapabilities
+	for _, rule := range rules {
+		if ok, _ := path.Match(rule.Tool, tool.Name); !ok {
+			continue
+		}
+		for _, name := range rule.Capabilities {
+			switch name {
+			case config.CapabilityReadOnly:
+				c.ReadOnly = true
+			case config.CapabilityDestructive:
+				c.Destructive = true
+			case config.CapabilityNetwork:
+				c.Network = true
+			case config.CapabilityLongRunning:
+				c.LongRunning = true
+			}
+		}
+		return c
+	}
+
+	if tool.MCPTool == nil || tool.MCPTool.Annotations == nil {
+		return c
+	}
+	// MCP 规范中 destructiveHint、openWorldHint 未设置时默认为 true，这里只采用明确给出的值，
+	// 避免把没有注解的工具都标记为 destructive
+	ann := tool.MCPTool.Annotations
+	c.ReadOnly = ann.ReadOnlyHint
+	c.Destructive = !ann.ReadOnlyHint && ann.DestructiveHint != nil && *ann.DestructiveHint
+	c.Network = ann.OpenWorldHint != nil && *ann.OpenWorldHint
+	return c
 }
 
 // ToolRegistry 工具注册表
 type ToolRegistry struct {
 	tools map[string]*ToolInfo
+	rules []config.ToolCapabilityRule
 	mu    sync.RWMutex
 }
 
-// NewToolRegistry 创建工具注册表
-func NewToolRegistry() *ToolRegistry {
+// NewToolRegistry 创建工具注册表，rules 为工具能力标记的配置
+func NewToolRegistry(rules []config.ToolCapabilityRule) *ToolRegistry {
 	return &ToolRegistry{
 		tools: make(map[string]*ToolInfo),
+		rules: rules,
 	}
 }
 
 // Register 注册工具
 func (r *ToolRegistry) Register(tool *ToolInfo) {
+	tool.Capabilities = capabilitiesOf(tool, r.rules)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Name] = tool
+}
+
+// Add 注册工具，已有同名工具时不注册并返回 false
+func (r *ToolRegistry) Add(tool *ToolInfo) bool {
+	tool.Capabilities = capabilitiesOf(tool, r.rules)
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if _, ok := r.tools[tool.Name]; ok {
+		return false
+	}
 	r.tools[tool.Name] = tool
+	return true
+}
+
+// UnregisterSource 移除来源以 prefix 开头的所有工具
+func (r *ToolRegistry) UnregisterSource(prefix string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, tool := range r.tools {
+		if strings.HasPrefix(tool.Source, prefix) {
+			delete(r.tools, name)
+		}
+	}
+}
+
+// ReplaceSource 以 tools 替换来源以 prefix 开头的所有工具，替换过程中其他请求不会看到工具缺失
+func (r *ToolRegistry) ReplaceSource(prefix string, tools []*ToolInfo) {
+	for _, tool := range tools {
+		tool.Capabilities = capabilitiesOf(tool, r.rules)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, tool := range r.tools {
+		if strings.HasPrefix(tool.Source, prefix) {
+			delete(r.tools, name)
+		}
+	}
+	for _, tool := range tools {
+		r.tools[tool.Name] = tool
+	}
 }
 
 // Get 获取工具
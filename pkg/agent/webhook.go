@@ -0,0 +1,32 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+
+	"k8s.io/klog/v2"
+)
+
+// HandleWebhook 校验入站 webhook 请求，每个需要处理的事件提交为一个后台任务。
+// 任务以 webhook 配置的用户身份执行，工具限制为 webhook 的工具范围；
+// 任务数达到上限时返回已提交的任务和 ErrTooManyTasks
+func (a *Agent) HandleWebhook(ctx context.Context, name string, header http.Header, body []byte) ([]*Task, error) {
+	hook, prompts, err := a.webhooks.Receive(name, header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &hook.Config
+	ctx = WithToolProfile(WithUser(ctx, cfg.User), &cfg.ToolProfile)
+	tasks := make([]*Task, 0, len(prompts))
+	for _, prompt := range prompts {
+		task, err := a.SubmitTask(ctx, &ChatRequest{Message: prompt, Model: cfg.Model}, TaskOptions{RAG: cfg.RAG})
+		if err != nil {
+			return tasks, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	klog.V(2).InfoS("Webhook received", "webhook", name, "tasks", len(tasks))
+	return tasks, nil
+}
@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/champly/ai-agent/pkg/filter"
+	"github.com/champly/ai-agent/pkg/quota"
+	"github.com/champly/ai-agent/pkg/workflow"
+)
+
+// ListWorkflows 返回已加载的工作流定义
+func (a *Agent) ListWorkflows() []*workflow.Definition {
+	return a.workflows.List()
+}
+
+// RunWorkflow 执行工作流。一次执行计入一次请求配额并占用一个 worker；输入经过用户消息过滤，
+// 模型步骤计入 token 配额，工具步骤与直接调用工具一样受权限策略和租户限制
+func (a *Agent) RunWorkflow(ctx context.Context, name string, inputs map[string]string, onStep func(workflow.StepResult)) (*workflow.Result, error) {
+	// 先确认工作流存在，避免为无效请求计入配额
+	if _, err := a.workflows.Get(name); err != nil {
+		return nil, err
+	}
+	if err := a.chargeRequest(ctx); err != nil {
+		return nil, err
+	}
+
+	filtered := make(map[string]string, len(inputs))
+	for key, value := range inputs {
+		value, err := a.filters.Apply(filter.StageInput, value)
+		if err != nil {
+			return nil, err
+		}
+		filtered[key] = value
+	}
+
+	var res *workflow.Result
+	err := a.workers.Do(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = a.workflows.Run(ctx, workflowRunner{a}, name, filtered, onStep)
+		return err
+	})
+	return res, err
+}
+
+// workflowRunner 为工作流提供模型、工具和 RAG 调用
+type workflowRunner struct {
+	a *Agent
+}
+
+// Prompt 单轮模型调用
+func (r workflowRunner) Prompt(ctx context.Context, model, system, prompt string) (string, error) {
+	a := r.a
+	if model == "" {
+		model = a.DefaultModel()
+	}
+	user := UserFromContext(ctx)
+	if err := a.quota.Check(ctx, user, quota.MetricTokens); err != nil {
+		return "", err
+	}
+
+	var messages []api.Message
+	if system != "" {
+		messages = append(messages, api.Message{Role: "system", Content: system})
+	}
+	messages = append(messages, api.Message{Role: "user", Content: prompt})
+
+	resp, err := a.chat(ctx, model, messages, nil)
+	if err != nil {
+		return "", err
+	}
+	a.quota.Add(ctx, user, quota.Usage{Tokens: int64(resp.PromptEvalCount + resp.EvalCount)})
+
+	// 过滤模型输出，被拦截时以提示替换
+	content, err := a.filters.Apply(filter.StageOutput, resp.Message.Content)
+	if err != nil {
+		content = err.Error()
+	}
+	return content, nil
+}
+
+// CallTool 调用工具；组合工具在对话中执行时，步骤中的工具调用记在同一对话下
+func (r workflowRunner) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	return r.a.callTool(ctx, conversationIDFromContext(ctx), name, args)
+}
+
+// SearchRAG 检索知识库
+func (r workflowRunner) SearchRAG(ctx context.Context, collection, query string, topK int) (string, error) {
+	a := r.a
+	if topK <= 0 {
+		topK = a.cfg.RAG.TopK
+	}
+	collection, err := a.tenantCollection(ctx, collection, true)
+	if err != nil {
+		return "", err
+	}
+	return a.rag.GetContext(ctx, collection, query, topK)
+}
@@ -0,0 +1,212 @@
+// Package analyzer 仓库分析：通过文件系统工具列出项目的文件清单，按重要程度在 token 预算内
+// 挑选文件逐个读取并摘要，最后根据清单统计和文件摘要生成结构化报告。
+// 相比让模型自行递归读取每个文件，上下文大小可控，适合小模型
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+const (
+	// inventoryTool 列出文件清单的工具（内置文件系统 MCP Server 提供）
+	inventoryTool = "file_inventory"
+	// readTool 读取文件的工具
+	readTool = "read_file"
+	// minReadTokens 剩余预算低于该值时不再读取文件
+	minReadTokens = 200
+)
+
+// ErrEmptyRepository 目录下没有可分析的文件
+var ErrEmptyRepository = errors.New("no files to analyze")
+
+// Runner 分析所需的模型和工具调用，由 Agent 提供，权限策略、配额和租户限制与普通请求一致
+type Runner interface {
+	// Prompt 单轮模型调用，返回回答
+	Prompt(ctx context.Context, model, system, prompt string) (string, error)
+	// CallTool 调用工具，返回结果文本
+	CallTool(ctx context.Context, name string, args map[string]any) (string, error)
+}
+
+// Request 分析请求
+type Request struct {
+	Path        string `json:"path"`                   // 项目目录，相对文件系统工具的根目录（allow-root），默认为根目录
+	Focus       string `json:"focus,omitempty"`        // 分析重点，如"认证流程"，路径包含其中关键词的文件优先读取
+	Model       string `json:"model,omitempty"`        // 为空时使用 analysis.model
+	TokenBudget int    `json:"token_budget,omitempty"` // 读取文件内容的 token 预算，上限为 analysis.token_budget
+}
+
+// LanguageStat 按语言统计的文件数和大小
+type LanguageStat struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// DirStat 顶层目录的文件数和大小
+type DirStat struct {
+	Path  string `json:"path"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// FileSummary 已读取文件的摘要
+type FileSummary struct {
+	Path      string `json:"path"`
+	Language  string `json:"language,omitempty"`
+	Size      int64  `json:"size"`
+	Summary   string `json:"summary"`
+	Truncated bool   `json:"truncated,omitempty"` // 超过 max_file_tokens，只读取了开头部分
+}
+
+// Report 分析报告
+type Report struct {
+	Path       string         `json:"path"`
+	Files      int            `json:"files"`
+	TotalBytes int64          `json:"total_bytes"`
+	Languages  []LanguageStat `json:"languages"`
+	Dirs       []DirStat      `json:"dirs"`
+	// InventoryTruncated 文件数超过 analysis.max_files，统计不完整
+	InventoryTruncated bool          `json:"inventory_truncated,omitempty"`
+	Summaries          []FileSummary `json:"summaries"`
+	Unread             int           `json:"unread"`      // 因预算不足或排序靠后未读取的文件数
+	TokensRead         int           `json:"tokens_read"` // 读取的文件内容（估算 token 数）
+	Overview           string        `json:"overview"`    // 模型根据清单和摘要生成的报告（Markdown）
+	Duration           time.Duration `json:"duration"`
+}
+
+// Analyzer 仓库分析
+type Analyzer struct {
+	cfg config.AnalysisConfig
+}
+
+// New 创建仓库分析
+func New(cfg config.AnalysisConfig) *Analyzer {
+	return &Analyzer{cfg: cfg}
+}
+
+// Budget 请求实际使用的 token 预算
+func (an *Analyzer) Budget(req *Request) int {
+	if req.TokenBudget <= 0 || req.TokenBudget > an.cfg.TokenBudget {
+		return an.cfg.TokenBudget
+	}
+	return req.TokenBudget
+}
+
+// inventoryFile file_inventory 返回的文件
+type inventoryFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Run 分析 req.Path 下的项目
+func (an *Analyzer) Run(ctx context.Context, r Runner, req *Request) (*Report, error) {
+	start := time.Now()
+	root := path.Clean("/" + req.Path)[1:]
+	if root == "" {
+		root = "."
+	}
+	model := req.Model
+	if model == "" {
+		model = an.cfg.Model
+	}
+
+	// 文件清单
+	out, err := r.CallTool(ctx, inventoryTool, map[string]any{"path": root, "max_files": an.cfg.MaxFiles})
+	if err != nil {
+		return nil, fmt.Errorf("list files: %w", err)
+	}
+	var inventory struct {
+		Files     []inventoryFile `json:"files"`
+		Truncated bool            `json:"truncated"`
+	}
+	// 工具执行出错（如目录不存在）时结果是错误信息而不是 JSON
+	if err := json.Unmarshal([]byte(out), &inventory); err != nil {
+		return nil, fmt.Errorf("list files: %.200s", out)
+	}
+	if len(inventory.Files) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrEmptyRepository, root)
+	}
+
+	report := &Report{Path: root, Files: len(inventory.Files), InventoryTruncated: inventory.Truncated}
+	report.Languages, report.Dirs, report.TotalBytes = stats(root, inventory.Files)
+
+	// 按重要程度在预算内逐个读取并摘要
+	budget := an.Budget(req)
+	candidates := rank(root, inventory.Files, req.Focus)
+	for i, f := range candidates {
+		remaining := budget - report.TokensRead
+		if remaining < minReadTokens {
+			report.Unread = len(candidates) - i
+			break
+		}
+		summary, tokens, err := an.summarize(ctx, r, model, f, min(remaining, an.cfg.MaxFileTokens))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("summarize %s: %w", f.Path, err)
+		}
+		report.TokensRead += tokens
+		if summary != nil {
+			report.Summaries = append(report.Summaries, *summary)
+		}
+	}
+	report.Unread += len(inventory.Files) - len(candidates)
+
+	overview, err := r.Prompt(ctx, model, systemPrompt, overviewPrompt(report, req.Focus))
+	if err != nil {
+		return nil, fmt.Errorf("write report: %w", err)
+	}
+	report.Overview = overview
+	report.Duration = time.Since(start)
+
+	klog.InfoS("Repository analyzed", "path", root, "files", report.Files, "read", len(report.Summaries),
+		"tokensRead", report.TokensRead, "duration", report.Duration)
+	return report, nil
+}
+
+// summarize 读取文件的前 maxTokens 个 token 并生成摘要，返回读取的 token 数；
+// 无法读取或内容为二进制时跳过（摘要为 nil）
+func (an *Analyzer) summarize(ctx context.Context, r Runner, model string, f inventoryFile, maxTokens int) (*FileSummary, int, error) {
+	out, err := r.CallTool(ctx, readTool, map[string]any{"path": f.Path})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		klog.V(2).InfoS("Skipping unreadable file", "path", f.Path, "err", err)
+		return nil, 0, nil
+	}
+	var file struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(out), &file); err != nil {
+		klog.V(2).InfoS("Skipping unreadable file", "path", f.Path, "result", out)
+		return nil, 0, nil
+	}
+	if strings.ContainsRune(file.Content, 0) {
+		return nil, 0, nil
+	}
+
+	content, truncated := truncateTokens(file.Content, maxTokens)
+	summary, err := r.Prompt(ctx, model, systemPrompt, filePrompt(f.Path, content, truncated))
+	if err != nil {
+		return nil, 0, err
+	}
+	return &FileSummary{
+		Path:      f.Path,
+		Language:  language(f.Path),
+		Size:      f.Size,
+		Summary:   strings.TrimSpace(summary),
+		Truncated: truncated,
+	}, estimateTokens(content), nil
+}
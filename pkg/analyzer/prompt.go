@@ -0,0 +1,54 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// systemPrompt 摘要和报告共用的系统提示
+const systemPrompt = `你是一名资深软件工程师，正在阅读一个陌生的代码仓库。只根据给出的内容作答，不要臆测未给出的代码，回答简洁准确。`
+
+// filePrompt 单个文件的摘要提示
+func filePrompt(path, content string, truncated bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "文件：%s\n", path)
+	if truncated {
+		b.WriteString("（文件较长，以下只是开头部分）\n")
+	}
+	fmt.Fprintf(&b, "```\n%s\n```\n\n", content)
+	b.WriteString("用 2 到 4 句话概括这个文件：它的职责、关键的类型或函数，以及依赖或被依赖的模块。直接输出摘要，不要复述代码。")
+	return b.String()
+}
+
+// overviewPrompt 根据清单统计和文件摘要生成报告的提示
+func overviewPrompt(report *Report, focus string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "项目目录：%s，共 %d 个文件（%d 字节）", report.Path, report.Files, report.TotalBytes)
+	if report.InventoryTruncated {
+		b.WriteString("，文件过多，清单不完整")
+	}
+	b.WriteString("\n\n按语言统计：\n")
+	for _, ls := range report.Languages {
+		fmt.Fprintf(&b, "- %s：%d 个文件，%d 字节\n", ls.Language, ls.Files, ls.Bytes)
+	}
+	b.WriteString("\n顶层目录：\n")
+	for _, ds := range report.Dirs {
+		fmt.Fprintf(&b, "- %s：%d 个文件\n", ds.Path, ds.Files)
+	}
+	fmt.Fprintf(&b, "\n已阅读的 %d 个文件的摘要（另有 %d 个文件未阅读）：\n", len(report.Summaries), report.Unread)
+	for _, s := range report.Summaries {
+		fmt.Fprintf(&b, "- %s：%s\n", s.Path, s.Summary)
+	}
+	if focus != "" {
+		fmt.Fprintf(&b, "\n本次分析重点：%s\n", focus)
+	}
+	b.WriteString(`
+根据以上信息写一份项目分析报告（Markdown），包含以下小节：
+## 概述
+## 技术栈
+## 目录与模块
+## 关键流程
+## 风险与改进建议
+信息不足的小节如实说明，不要编造。`)
+	return b.String()
+}
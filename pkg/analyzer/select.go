@@ -0,0 +1,225 @@
+package analyzer
+
+import (
+	"cmp"
+	"path"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// languages 扩展名到语言的映射
+var languages = map[string]string{
+	".go": "Go", ".py": "Python", ".js": "JavaScript", ".jsx": "JavaScript", ".mjs": "JavaScript",
+	".ts": "TypeScript", ".tsx": "TypeScript", ".java": "Java", ".kt": "Kotlin", ".scala": "Scala",
+	".rs": "Rust", ".c": "C", ".h": "C", ".cc": "C++", ".cpp": "C++", ".hpp": "C++", ".cs": "C#",
+	".rb": "Ruby", ".php": "PHP", ".swift": "Swift", ".m": "Objective-C", ".lua": "Lua",
+	".sh": "Shell", ".bash": "Shell", ".ps1": "PowerShell", ".sql": "SQL", ".proto": "Protobuf",
+	".html": "HTML", ".css": "CSS", ".scss": "CSS", ".vue": "Vue", ".svelte": "Svelte",
+	".md": "Markdown", ".rst": "reStructuredText", ".txt": "Text",
+	".yaml": "YAML", ".yml": "YAML", ".json": "JSON", ".toml": "TOML", ".xml": "XML", ".ini": "INI",
+	".tf": "Terraform", ".mod": "Go Module",
+}
+
+// manifests 依赖清单和构建文件，最能说明项目的技术栈
+var manifests = map[string]bool{
+	"go.mod": true, "package.json": true, "cargo.toml": true, "pyproject.toml": true, "setup.py": true,
+	"requirements.txt": true, "pom.xml": true, "build.gradle": true, "build.gradle.kts": true, "gemfile": true,
+	"composer.json": true, "makefile": true, "dockerfile": true, "cmakelists.txt": true, "chart.yaml": true,
+}
+
+// entrypoints 常见的程序入口文件名（不含扩展名）
+var entrypoints = map[string]bool{
+	"main": true, "app": true, "index": true, "server": true, "cli": true, "__main__": true, "lib": true, "mod": true,
+}
+
+// ignored 分析时忽略的文件：锁文件和生成的代码
+func ignored(p string) bool {
+	name := strings.ToLower(path.Base(p))
+	switch {
+	case strings.HasSuffix(name, ".lock"), strings.HasSuffix(name, "-lock.json"), name == "go.sum",
+		strings.HasSuffix(name, ".min.js"), strings.HasSuffix(name, ".min.css"), strings.HasSuffix(name, ".map"),
+		strings.HasSuffix(name, ".pb.go"), strings.Contains(name, "generated"), strings.HasPrefix(name, "zz_"):
+		return true
+	}
+	return false
+}
+
+// language 按扩展名识别语言，无法识别时返回空
+func language(p string) string {
+	if manifests[strings.ToLower(path.Base(p))] && path.Ext(p) == "" {
+		return "Build"
+	}
+	return languages[strings.ToLower(path.Ext(p))]
+}
+
+// isTest 是否为测试文件
+func isTest(p string) bool {
+	name := strings.ToLower(path.Base(p))
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	return strings.HasSuffix(stem, "_test") || strings.HasPrefix(stem, "test_") ||
+		strings.HasSuffix(stem, ".test") || strings.HasSuffix(stem, ".spec") ||
+		slices.ContainsFunc(strings.Split(path.Dir(p), "/"), func(dir string) bool {
+			return dir == "test" || dir == "tests" || dir == "testdata" || dir == "__tests__"
+		})
+}
+
+// relative 返回相对项目目录的路径
+func relative(root, p string) string {
+	if root == "." {
+		return p
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+}
+
+// score 文件的重要程度：说明文档和依赖清单最先读取，其次是入口和浅层的源码，测试最后
+func score(root string, f inventoryFile, keywords []string) int {
+	rel := relative(root, f.Path)
+	name := strings.ToLower(path.Base(rel))
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	depth := strings.Count(rel, "/")
+	lang := language(rel)
+
+	var s int
+	switch {
+	case strings.HasPrefix(name, "readme") && depth == 0:
+		s = 100
+	case manifests[name] && depth <= 1:
+		s = 90
+	case (entrypoints[stem] && lang != "") || strings.HasPrefix(rel, "cmd/"):
+		s = 70
+	case lang == "Markdown" || lang == "reStructuredText":
+		s = 30 - 5*depth
+	case lang == "YAML" || lang == "JSON" || lang == "TOML" || lang == "XML" || lang == "INI":
+		s = 35 - 10*depth
+	case lang == "" || lang == "Text":
+		s = 0
+	default:
+		s = 60 - 5*depth
+	}
+	if isTest(rel) {
+		s = min(s, 10)
+	}
+	lower := strings.ToLower(rel)
+	for _, kw := range keywords {
+		if strings.Contains(lower, kw) {
+			s += 50
+			break
+		}
+	}
+	return s
+}
+
+// rank 返回按重要程度排序的候选文件，同等重要时先读小文件（同样的预算覆盖更多文件）
+func rank(root string, files []inventoryFile, focus string) []inventoryFile {
+	var keywords []string
+	for _, kw := range strings.Fields(strings.ToLower(focus)) {
+		if utf8.RuneCountInString(kw) >= 2 {
+			keywords = append(keywords, kw)
+		}
+	}
+
+	type scored struct {
+		file  inventoryFile
+		score int
+	}
+	candidates := make([]scored, 0, len(files))
+	for _, f := range files {
+		if f.Size == 0 || ignored(f.Path) {
+			continue
+		}
+		candidates = append(candidates, scored{file: f, score: score(root, f, keywords)})
+	}
+	slices.SortStableFunc(candidates, func(a, b scored) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.file.Size, b.file.Size)
+	})
+
+	ranked := make([]inventoryFile, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = c.file
+	}
+	return ranked
+}
+
+// stats 按语言和顶层目录统计文件数和大小，均按大小从大到小排列
+func stats(root string, files []inventoryFile) ([]LanguageStat, []DirStat, int64) {
+	langs := make(map[string]*LanguageStat)
+	dirs := make(map[string]*DirStat)
+	var total int64
+	for _, f := range files {
+		total += f.Size
+
+		lang := language(f.Path)
+		if lang == "" {
+			lang = "Other"
+		}
+		ls, ok := langs[lang]
+		if !ok {
+			ls = &LanguageStat{Language: lang}
+			langs[lang] = ls
+		}
+		ls.Files++
+		ls.Bytes += f.Size
+
+		dir, _, found := strings.Cut(relative(root, f.Path), "/")
+		if !found {
+			dir = "."
+		}
+		ds, ok := dirs[dir]
+		if !ok {
+			ds = &DirStat{Path: dir}
+			dirs[dir] = ds
+		}
+		ds.Files++
+		ds.Bytes += f.Size
+	}
+
+	langStats := make([]LanguageStat, 0, len(langs))
+	for _, ls := range langs {
+		langStats = append(langStats, *ls)
+	}
+	slices.SortFunc(langStats, func(a, b LanguageStat) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), cmp.Compare(a.Language, b.Language))
+	})
+	dirStats := make([]DirStat, 0, len(dirs))
+	for _, ds := range dirs {
+		dirStats = append(dirStats, *ds)
+	}
+	slices.SortFunc(dirStats, func(a, b DirStat) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), cmp.Compare(a.Path, b.Path))
+	})
+	return langStats, dirStats, total
+}
+
+// estimateTokens 估算文本的 token 数：英文和代码约 4 字节一个 token，中文约一字一个 token
+func estimateTokens(s string) int {
+	runes := utf8.RuneCountInString(s)
+	wide := (len(s) - runes) / 2 // 多字节字符（按 3 字节计）的数量
+	return (runes-wide)/4 + wide + 1
+}
+
+// truncateTokens 截取文本开头约 maxTokens 个 token，按行截断
+func truncateTokens(s string, maxTokens int) (string, bool) {
+	if estimateTokens(s) <= maxTokens {
+		return s, false
+	}
+	lines := strings.SplitAfter(s, "\n")
+	var b strings.Builder
+	used := 0
+	for _, line := range lines {
+		n := estimateTokens(line)
+		if used+n > maxTokens {
+			break
+		}
+		b.WriteString(line)
+		used += n
+	}
+	if b.Len() == 0 {
+		// 单行超长（如压缩过的文件），按字符截断
+		return strings.ToValidUTF8(s[:min(len(s), maxTokens*4)], ""), true
+	}
+	return b.String(), true
+}
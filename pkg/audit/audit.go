@@ -0,0 +1,279 @@
+// Package audit 记录 Agent 读取、写入和执行的操作（工具调用、知识库写入等）。
+// 每条记录包含上一条记录的哈希形成哈希链，可选使用 Ed25519 私钥签名，
+// 事后删除、插入或修改中间的记录都能通过 Verify 发现；截断末尾的记录无法仅凭文件发现，
+// 需要将 Verify 返回的最后一条记录的序号和哈希保存到日志之外，下次校验时对比
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+const (
+	// TypeToolCall 工具调用
+	TypeToolCall = "tool_call"
+	// TypeRAGWrite 知识库写入
+	TypeRAGWrite = "rag_write"
+
+	// OutcomeOK 执行成功
+	OutcomeOK = "ok"
+	// OutcomeDenied 被租户、策略、配额或过滤规则拒绝
+	OutcomeDenied = "denied"
+	// OutcomeError 执行失败
+	OutcomeError = "error"
+)
+
+// Record 审计记录，Hash 覆盖除 Hash 和 Signature 之外的所有字段
+type Record struct {
+	Seq            uint64          `json:"seq"`
+	Time           time.Time       `json:"time"`
+	Type           string          `json:"type"`
+	User           string          `json:"user,omitempty"`
+	Tenant         string          `json:"tenant,omitempty"`
+	ConversationID string          `json:"conversation_id,omitempty"`
+	Tool           string          `json:"tool,omitempty"`
+	Source         string          `json:"source,omitempty"`
+	Args           json.RawMessage `json:"args,omitempty"`
+	Target         string          `json:"target,omitempty"` // 操作对象，如知识库集合和文档
+	Outcome        string          `json:"outcome"`
+	Error          string          `json:"error,omitempty"`
+	ResultBytes    int             `json:"result_bytes,omitempty"`
+	ResultSHA256   string          `json:"result_sha256,omitempty"` // 结果内容的摘要，审计日志不保存结果原文
+	PrevHash       string          `json:"prev_hash"`
+	Hash           string          `json:"hash"`
+	Signature      string          `json:"signature,omitempty"` // 对 Hash 的 Ed25519 签名（base64）
+}
+
+// digest 计算记录哈希：sha256(除 Hash、Signature 外的 JSON)，其中包含 PrevHash
+func (r Record) digest() (string, error) {
+	r.Hash, r.Signature = "", ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SetArgs 设置工具参数
+func (r *Record) SetArgs(args map[string]any) {
+	if len(args) == 0 {
+		return
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		klog.ErrorS(err, "Failed to marshal audit args", "tool", r.Tool)
+		return
+	}
+	r.Args = data
+}
+
+// SetResult 记录结果的大小和摘要
+func (r *Record) SetResult(result string) {
+	if result == "" {
+		return
+	}
+	sum := sha256.Sum256([]byte(result))
+	r.ResultBytes = len(result)
+	r.ResultSHA256 = hex.EncodeToString(sum[:])
+}
+
+// Logger 审计日志，追加写入 JSON Lines 文件；nil 表示不记录
+type Logger struct {
+	mu     sync.Mutex
+	file   *os.File
+	key    ed25519.PrivateKey
+	seq    uint64
+	prev   string
+	size   int64 // 最后一条完整记录之后的偏移
+	broken error // 写入失败且无法截断残缺的行时的错误，之后不再写入
+}
+
+// New 打开审计日志，从已有文件的最后一条记录继续哈希链；未配置路径时返回 nil
+func New(cfg config.AuditConfig) (*Logger, error) {
+	if cfg.Path == "" {
+		return nil, nil
+	}
+
+	l := &Logger{}
+	if cfg.SigningKey != "" {
+		key, err := LoadPrivateKey(cfg.SigningKey)
+		if err != nil {
+			return nil, err
+		}
+		l.key = key
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("create audit dir: %w", err)
+	}
+	f, err := os.OpenFile(cfg.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	last, err := lastRecord(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read audit log %s: %w", cfg.Path, err)
+	}
+	if last != nil {
+		l.seq, l.prev = last.Seq, last.Hash
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("stat audit log %s: %w", cfg.Path, err)
+	}
+	l.file, l.size = f, info.Size()
+
+	klog.InfoS("Audit log opened", "path", cfg.Path, "seq", l.seq, "signed", l.key != nil)
+	return l, nil
+}
+
+// Log 补全序号、时间和哈希后追加记录，写入失败时只记录日志。
+// 写入失败时截断已写入的部分内容，避免残缺的行导致之后的校验都失败；无法截断时不再写入
+func (l *Logger) Log(r Record) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.broken != nil {
+		klog.ErrorS(l.broken, "Audit log is disabled after a failed write", "type", r.Type)
+		return
+	}
+
+	r.Seq = l.seq + 1
+	r.Time = time.Now().UTC()
+	r.PrevHash = l.prev
+	hash, err := r.digest()
+	if err != nil {
+		klog.ErrorS(err, "Failed to hash audit record", "type", r.Type)
+		return
+	}
+	r.Hash = hash
+	if l.key != nil {
+		r.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(l.key, []byte(hash)))
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		klog.ErrorS(err, "Failed to marshal audit record", "type", r.Type)
+		return
+	}
+	data = append(data, '\n')
+	if _, err := l.file.Write(data); err != nil {
+		klog.ErrorS(err, "Failed to write audit record", "type", r.Type, "seq", r.Seq)
+		if err := l.file.Truncate(l.size); err != nil {
+			l.broken = fmt.Errorf("truncate partial audit record at offset %d: %w", l.size, err)
+			klog.ErrorS(l.broken, "Refusing further audit writes")
+		}
+		return
+	}
+	l.size += int64(len(data))
+	l.seq, l.prev = r.Seq, hash
+}
+
+// Close 关闭审计日志
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// lastRecord 读取文件中的最后一条记录，空文件返回 nil
+func lastRecord(r io.Reader) (*Record, error) {
+	var last []byte
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			last = line
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if last == nil {
+		return nil, nil
+	}
+
+	var rec Record
+	if err := json.Unmarshal(last, &rec); err != nil {
+		return nil, fmt.Errorf("parse last record: %w", err)
+	}
+	return &rec, nil
+}
+
+// LoadPrivateKey 读取 PKCS#8 PEM 格式的 Ed25519 私钥（openssl genpkey -algorithm ed25519）
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse audit signing key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("audit signing key is %T, want ed25519", key)
+	}
+	return edKey, nil
+}
+
+// LoadPublicKey 读取 PKIX PEM 格式的 Ed25519 公钥（openssl pkey -pubout）
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse audit public key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("audit public key is %T, want ed25519", key)
+	}
+	return edKey, nil
+}
+
+// readPEM 读取 PEM 文件中的第一个块
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem block in %s", path)
+	}
+	return block, nil
+}
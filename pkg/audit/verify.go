@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrTampered 审计日志校验失败
+var ErrTampered = errors.New("audit log verification failed")
+
+// Summary 校验结果。FirstSeq 不为 1 说明开头的记录已被归档或删除；
+// 将 LastSeq 和 LastHash 保存到日志之外，可在之后的校验中发现对末尾记录的截断
+type Summary struct {
+	Records  int    `json:"records"`
+	FirstSeq uint64 `json:"first_seq"`
+	LastSeq  uint64 `json:"last_seq"`
+	LastHash string `json:"last_hash"`
+}
+
+// Verify 校验审计日志的序号连续性、哈希链和签名，失败时返回包装了 ErrTampered 的错误和已校验部分的结果。
+// pub 不为空时要求每条记录都有有效签名
+func Verify(r io.Reader, pub ed25519.PublicKey) (*Summary, error) {
+	summary := &Summary{}
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			if err := verifyRecord(data, pub, summary); err != nil {
+				return summary, fmt.Errorf("%w: line %d: %v", ErrTampered, line, err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return summary, nil
+		}
+		if err != nil {
+			return summary, err
+		}
+	}
+}
+
+// verifyRecord 校验单条记录并推进序号和哈希链
+func verifyRecord(data []byte, pub ed25519.PublicKey, summary *Summary) error {
+	var rec Record
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rec); err != nil {
+		return fmt.Errorf("parse record: %v", err)
+	}
+
+	// 第一条记录之前的内容可能已按保留策略归档，从其序号和 prev_hash 开始校验
+	if summary.Records == 0 {
+		summary.FirstSeq = rec.Seq
+	} else {
+		if rec.Seq != summary.LastSeq+1 {
+			return fmt.Errorf("seq %d follows %d, records are missing or reordered", rec.Seq, summary.LastSeq)
+		}
+		if rec.PrevHash != summary.LastHash {
+			return fmt.Errorf("seq %d: prev_hash does not match the previous record", rec.Seq)
+		}
+	}
+
+	hash, err := rec.digest()
+	if err != nil {
+		return err
+	}
+	if hash != rec.Hash {
+		return fmt.Errorf("seq %d: content does not match hash", rec.Seq)
+	}
+
+	if pub != nil {
+		sig, err := base64.StdEncoding.DecodeString(rec.Signature)
+		if err != nil || !ed25519.Verify(pub, []byte(rec.Hash), sig) {
+			return fmt.Errorf("seq %d: invalid or missing signature", rec.Seq)
+		}
+	}
+
+	summary.Records++
+	summary.LastSeq, summary.LastHash = rec.Seq, rec.Hash
+	return nil
+}
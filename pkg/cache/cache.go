@@ -0,0 +1,168 @@
+// Package cache 缓存模型响应：相同的模型、消息和工具在有效期内直接返回之前的回答，
+// 适用于重复的巡检类问题和对本地模型的评测；也缓存同一对话中只读工具的结果
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// Store 缓存存储
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Stats 缓存命中统计（进程内）
+type Stats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// Cache 模型响应缓存，nil 表示不缓存
+type Cache struct {
+	store  Store
+	ttl    time.Duration
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// New 创建响应缓存；未启用时返回 nil
+func New(cfg config.CacheConfig, redisCfg config.RedisConfig) (*Cache, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	c := &Cache{ttl: cfg.TTL}
+	switch cfg.Backend {
+	case "", "memory":
+		c.store = NewMemoryStore(cfg.MaxEntries)
+	case "redis":
+		s, err := NewRedisStore(redisCfg)
+		if err != nil {
+			return nil, err
+		}
+		c.store = s
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %s", cfg.Backend)
+	}
+
+	klog.InfoS("Response cache enabled", "backend", cfg.Backend, "ttl", cfg.TTL, "maxEntries", cfg.MaxEntries)
+	return c, nil
+}
+
+// Get 查找缓存的响应，命中时返回的响应不含 token 统计（本次没有消耗模型 token）
+func (c *Cache) Get(ctx context.Context, key string) (*api.ChatResponse, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	data, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		klog.ErrorS(err, "Failed to read response cache")
+	}
+	if !ok || err != nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	var resp api.ChatResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		klog.ErrorS(err, "Failed to parse cached response")
+		c.misses.Add(1)
+		return nil, false
+	}
+	resp.Metrics = api.Metrics{}
+	c.hits.Add(1)
+	return &resp, true
+}
+
+// Put 缓存响应，写入失败时只记录日志
+func (c *Cache) Put(ctx context.Context, key string, resp *api.ChatResponse) {
+	if c == nil {
+		return
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		klog.ErrorS(err, "Failed to marshal response for cache")
+		return
+	}
+	if err := c.store.Set(ctx, key, data, c.ttl); err != nil {
+		klog.ErrorS(err, "Failed to write response cache")
+	}
+}
+
+// Stats 返回命中统计
+func (c *Cache) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	s := Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+	if total := s.Hits + s.Misses; total > 0 {
+		s.HitRate = float64(s.Hits) / float64(total)
+	}
+	return s
+}
+
+// boundaryPattern 工具结果包装中每次随机生成的边界，不参与缓存键计算
+var boundaryPattern = regexp.MustCompile(` boundary="[0-9a-f]+"`)
+
+// normalizedMessage 参与缓存键计算的消息内容
+type normalizedMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []api.ImageData  `json:"images,omitempty"`
+	ToolCalls []normalizedCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+// normalizedCall 参与缓存键计算的工具调用，不包含每次不同的调用 ID
+type normalizedCall struct {
+	Name      string `json:"name"`
+	Arguments any    `json:"arguments"`
+}
+
+// Key 根据模型、模型参数、输出格式、规范化后的消息和工具定义计算缓存键。
+// 规范化会去掉首尾空白、思考过程、工具调用 ID 和工具结果的随机边界
+func Key(model string, options map[string]any, format json.RawMessage, messages []api.Message, tools []api.Tool) (string, error) {
+	normalized := make([]normalizedMessage, 0, len(messages))
+	for _, m := range messages {
+		n := normalizedMessage{
+			Role:     m.Role,
+			Content:  strings.TrimSpace(boundaryPattern.ReplaceAllString(m.Content, "")),
+			Images:   m.Images,
+			ToolName: m.ToolName,
+		}
+		for _, tc := range m.ToolCalls {
+			n.ToolCalls = append(n.ToolCalls, normalizedCall{Name: tc.Function.Name, Arguments: tc.Function.Arguments})
+		}
+		normalized = append(normalized, n)
+	}
+
+	data, err := json.Marshal(struct {
+		Model    string              `json:"model"`
+		Options  map[string]any      `json:"options,omitempty"`
+		Format   json.RawMessage     `json:"format,omitempty"`
+		Messages []normalizedMessage `json:"messages"`
+		Tools    []api.Tool          `json:"tools,omitempty"`
+	}{model, options, format, normalized, tools})
+	if err != nil {
+		return "", fmt.Errorf("marshal cache key: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
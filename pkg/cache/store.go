@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/redisclient"
+)
+
+// MemoryStore 进程内 LRU 存储
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List // 最近使用的在前
+	entries    map[string]*list.Element
+}
+
+// memoryEntry LRU 中的缓存项
+type memoryEntry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+// NewMemoryStore 创建内存存储，超过 maxEntries 时淘汰最久未使用的项（<= 0 表示不限制）
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get 实现 Store
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*memoryEntry)
+	if time.Now().After(entry.expireAt) {
+		s.order.Remove(el)
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+// Set 实现 Store
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := &memoryEntry{key: key, value: value, expireAt: time.Now().Add(ttl)}
+	if el, ok := s.entries[key]; ok {
+		el.Value = entry
+		s.order.MoveToFront(el)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(entry)
+
+	for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+// DeletePrefix 删除键以 prefix 开头的项
+func (s *MemoryStore) DeletePrefix(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, el := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			s.order.Remove(el)
+			delete(s.entries, key)
+		}
+	}
+}
+
+// redisTimeout 单次 Redis 操作超时
+const redisTimeout = 5 * time.Second
+
+// RedisStore 基于 Redis 的存储，多个副本共享缓存，键为 <prefix>:cache:<缓存键>
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore 创建 Redis 存储
+func NewRedisStore(cfg config.RedisConfig) (*RedisStore, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStore{client: client, prefix: cfg.Prefix}, nil
+}
+
+// Get 实现 Store
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, redisclient.Key(s.prefix, "cache", key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("read cache: %w", err)
+	}
+	return data, true, nil
+}
+
+// Set 实现 Store
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), redisTimeout)
+	defer cancel()
+
+	if err := s.client.Set(ctx, redisclient.Key(s.prefix, "cache", key), value, ttl).Err(); err != nil {
+		return fmt.Errorf("write cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// ToolCache 只读工具的结果缓存（进程内 LRU），按对话隔离，nil 表示不缓存
+type ToolCache struct {
+	store  *MemoryStore
+	rules  []config.ToolCacheRule
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewToolCache 创建工具结果缓存；没有配置缓存的工具时返回 nil
+func NewToolCache(cfg config.ToolCacheConfig) *ToolCache {
+	if len(cfg.Tools) == 0 {
+		return nil
+	}
+	klog.InfoS("Tool result cache enabled", "rules", len(cfg.Tools), "maxEntries", cfg.MaxEntries)
+	return &ToolCache{store: NewMemoryStore(cfg.MaxEntries), rules: cfg.Tools}
+}
+
+// ttl 工具结果的缓存有效期，不缓存的工具返回 0
+func (c *ToolCache) ttl(tool string) time.Duration {
+	for _, rule := range c.rules {
+		if ok, _ := path.Match(rule.Tool, tool); ok {
+			return rule.TTL
+		}
+	}
+	return 0
+}
+
+// toolKey 由对话 ID、工具名和参数计算缓存键，键以 <对话 ID>: 开头，便于按对话清除；
+// 参数按 JSON 编码，对象的键有序，参数顺序不影响命中
+func toolKey(conversationID, tool string, args map[string]any) (string, bool) {
+	data, err := json.Marshal(struct {
+		Conversation string         `json:"conversation"`
+		Tool         string         `json:"tool"`
+		Args         map[string]any `json:"args"`
+	}{conversationID, tool, args})
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return conversationID + ":" + hex.EncodeToString(sum[:]), true
+}
+
+// Get 返回同一对话中相同工具和参数的缓存结果；只缓存只读工具（readOnly），
+// 不在对话中的调用（直接调用工具、工作流）不使用缓存
+func (c *ToolCache) Get(ctx context.Context, conversationID, tool string, readOnly bool, args map[string]any) (string, bool) {
+	if c == nil || conversationID == "" || !readOnly || c.ttl(tool) == 0 {
+		return "", false
+	}
+	key, ok := toolKey(conversationID, tool, args)
+	if !ok {
+		return "", false
+	}
+	data, ok, _ := c.store.Get(ctx, key)
+	if !ok {
+		c.misses.Add(1)
+		return "", false
+	}
+	c.hits.Add(1)
+	return string(data), true
+}
+
+// Put 缓存工具结果，工具不缓存或不是只读工具时忽略
+func (c *ToolCache) Put(ctx context.Context, conversationID, tool string, readOnly bool, args map[string]any, result string) {
+	if c == nil || conversationID == "" || !readOnly {
+		return
+	}
+	ttl := c.ttl(tool)
+	if ttl == 0 {
+		return
+	}
+	if key, ok := toolKey(conversationID, tool, args); ok {
+		c.store.Set(ctx, key, []byte(result), ttl)
+	}
+}
+
+// Invalidate 清除对话中缓存的所有结果，对话中执行了可能修改状态的工具后调用
+func (c *ToolCache) Invalidate(conversationID string) {
+	if c == nil || conversationID == "" {
+		return
+	}
+	c.store.DeletePrefix(conversationID + ":")
+}
+
+// Stats 返回命中统计
+func (c *ToolCache) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	s := Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+	if total := s.Hits + s.Misses; total > 0 {
+		s.HitRate = float64(s.Hits) / float64(total)
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/champly/ai-agent/pkg/agent"
+	"github.com/champly/ai-agent/pkg/store"
+)
+
+// Chat 聊天（POST /api/chat），req.OnEvent 不会被调用，需要实时事件时使用 ChatStream
+func (c *Client) Chat(ctx context.Context, req *agent.ChatRequest) (*agent.ChatResponse, error) {
+	var resp agent.ChatResponse
+	if err := c.do(ctx, http.MethodPost, "/api/chat", req, &resp, false); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ChatRAG 检索知识库后回答（POST /api/chat/rag）
+func (c *Client) ChatRAG(ctx context.Context, req *agent.ChatRequest) (*agent.ChatResponse, error) {
+	var resp agent.ChatResponse
+	if err := c.do(ctx, http.MethodPost, "/api/chat/rag", req, &resp, false); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// StreamOptions 流式聊天的选项
+type StreamOptions struct {
+	// RAG 检索知识库后回答
+	RAG bool
+}
+
+// ChatStream 流式聊天（POST /api/chat/stream）：工具调用等事件到达时依次调用 req.OnEvent（在调用 ChatStream 的 goroutine 中），
+// 返回最终回答。推送开始后失败时返回 APIError，状态码与同步调用 Chat 一致
+func (c *Client) ChatStream(ctx context.Context, req *agent.ChatRequest, opts StreamOptions) (*agent.ChatResponse, error) {
+	body, err := json.Marshal(struct {
+		*agent.ChatRequest
+		RAG bool `json:"rag,omitempty"`
+	}{req, opts.RAG})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	var resp *http.Response
+	// 推送开始前被拒绝（超出配额、繁忙）时重试，开始后不再重试
+	err = c.retry(ctx, false, func() error {
+		var err error
+		resp, err = c.send(ctx, http.MethodPost, "/api/chat/stream", body, http.Header{"Accept": {"text/event-stream"}})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	events := newSSEReader(resp.Body)
+	for {
+		ev, err := events.next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("chat stream: %w", io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chat stream: %w", err)
+		}
+
+		switch ev.Event {
+		case "done":
+			var out agent.ChatResponse
+			if err := json.Unmarshal([]byte(ev.Data), &out); err != nil {
+				return nil, fmt.Errorf("decode chat response: %w", err)
+			}
+			return &out, nil
+		case "error":
+			var e struct {
+				Error  string `json:"error"`
+				Status int    `json:"status"`
+			}
+			if err := json.Unmarshal([]byte(ev.Data), &e); err != nil {
+				return nil, fmt.Errorf("decode chat error: %w", err)
+			}
+			return nil, &APIError{StatusCode: e.Status, Message: e.Error}
+		default:
+			if req.OnEvent == nil {
+				continue
+			}
+			var out agent.Event
+			if err := json.Unmarshal([]byte(ev.Data), &out); err != nil {
+				return nil, fmt.Errorf("decode chat event: %w", err)
+			}
+			req.OnEvent(out)
+		}
+	}
+}
+
+// ListConversations 列出当前用户的对话
+func (c *Client) ListConversations(ctx context.Context) ([]store.Summary, error) {
+	var resp struct {
+		Conversations []store.Summary `json:"conversations"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/conversations", nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Conversations, nil
+}
+
+// GetConversation 获取对话的完整消息，不存在时返回 404 的 APIError
+func (c *Client) GetConversation(ctx context.Context, id string) (*store.Record, error) {
+	var rec store.Record
+	if err := c.do(ctx, http.MethodGet, "/api/conversations/"+url.PathEscape(id), nil, &rec, true); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// StopConversation 停止对话进行中的请求，对话空闲时返回 409 的 APIError
+func (c *Client) StopConversation(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/api/conversations/"+url.PathEscape(id)+"/stop", nil, nil, false)
+}
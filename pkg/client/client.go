@@ -0,0 +1,226 @@
+// Package client Agent HTTP API 的 Go 客户端，供其他 Go 服务调用 Agent：聊天（含流式）、对话、工具、知识库和后台任务。
+// 请求和响应复用 agent、store 包中的类型；服务端返回 429、503 等暂时性错误时按 Retry-After 自动重试
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 500 * time.Millisecond
+	defaultMaxRetryWait = 30 * time.Second
+)
+
+// Config 客户端配置
+type Config struct {
+	// BaseURL Agent 的地址，如 http://localhost:8080
+	BaseURL string
+	// Token 启用认证时的 OIDC token，以 Authorization: Bearer 发送
+	Token string
+	// HTTPClient 发送请求的 HTTP 客户端（可配置 TLS 和 mTLS），为空时使用 http.DefaultClient。
+	// 聊天和任务事件是长连接，不要设置过短的 Timeout，请求超时通过 context 控制
+	HTTPClient *http.Client
+	// MaxRetries 暂时性错误的最大重试次数，0 时使用默认值 3，负数不重试
+	MaxRetries int
+	// RetryBackoff 首次重试的等待时间，之后每次翻倍；服务端返回 Retry-After 时以其为准。0 时使用默认值 500ms
+	RetryBackoff time.Duration
+	// MaxRetryWait 单次重试的最长等待时间，Retry-After 超过该值时不再重试。0 时使用默认值 30s
+	MaxRetryWait time.Duration
+}
+
+// Client Agent HTTP API 客户端，可并发使用
+type Client struct {
+	baseURL      string
+	token        string
+	http         *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+	maxRetryWait time.Duration
+}
+
+// New 创建客户端
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url %q: must be an absolute http(s) url", cfg.BaseURL)
+	}
+
+	c := &Client{
+		baseURL:      strings.TrimRight(cfg.BaseURL, "/"),
+		token:        cfg.Token,
+		http:         cfg.HTTPClient,
+		maxRetries:   cfg.MaxRetries,
+		retryBackoff: cfg.RetryBackoff,
+		maxRetryWait: cfg.MaxRetryWait,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	switch {
+	case c.maxRetries == 0:
+		c.maxRetries = defaultMaxRetries
+	case c.maxRetries < 0:
+		c.maxRetries = 0
+	}
+	if c.retryBackoff <= 0 {
+		c.retryBackoff = defaultRetryBackoff
+	}
+	if c.maxRetryWait <= 0 {
+		c.maxRetryWait = defaultMaxRetryWait
+	}
+	return c, nil
+}
+
+// APIError 服务端返回的错误
+type APIError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter 服务端建议的重试等待时间（Retry-After），未返回时为 0
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("agent api: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsStatus err 是否为状态码为 code 的 APIError
+func IsStatus(err error, code int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+// retryable 请求失败后是否可以重试：幂等请求在网络错误和网关错误时重试；
+// 非幂等请求（聊天、提交任务等）只在服务端处理前拒绝时重试（429 超出配额、503 繁忙）
+func retryable(err error, idempotent bool) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return idempotent
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	default:
+		return false
+	}
+}
+
+// retry 执行 fn，可重试的错误按退避时间重试，返回最后一次的错误
+func (c *Client) retry(ctx context.Context, idempotent bool, fn func() error) error {
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || ctx.Err() != nil || attempt >= c.maxRetries || !retryable(err, idempotent) {
+			return err
+		}
+
+		wait := backoff
+		backoff *= 2
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+		if wait > c.maxRetryWait {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send 发送一次请求，状态码不是 2xx 时返回 APIError，成功时由调用方关闭响应体
+func (c *Client) send(ctx context.Context, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+	return resp, nil
+}
+
+// newAPIError 从错误响应构造 APIError：JSON 响应（如超出配额）取其 error 字段，其余为纯文本
+func newAPIError(resp *http.Response) *APIError {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		apiErr.Message = body.Error
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+// do 发送 JSON 请求并把响应解码到 out（为空时忽略响应体），按 idempotent 决定重试范围
+func (c *Client) do(ctx context.Context, method, path string, in, out any, idempotent bool) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+	}
+
+	// 响应无法解码时重试也无济于事，不参与重试
+	var decodeErr error
+	err := c.retry(ctx, idempotent, func() error {
+		resp, err := c.send(ctx, method, path, body, nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				decodeErr = fmt.Errorf("decode response of %s %s: %w", method, path, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return decodeErr
+}
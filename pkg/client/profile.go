@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/champly/ai-agent/pkg/profile"
+)
+
+// GetProfile 返回当前用户的资料，服务端未启用用户资料时返回 404 的 APIError
+func (c *Client) GetProfile(ctx context.Context) (*profile.Profile, error) {
+	var p profile.Profile
+	if err := c.do(ctx, http.MethodGet, "/api/profile", nil, &p, true); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpdateProfile 替换当前用户的资料，返回服务端整理后的资料；条目不合格时返回 400 的 APIError
+func (c *Client) UpdateProfile(ctx context.Context, p *profile.Profile) (*profile.Profile, error) {
+	var out profile.Profile
+	if err := c.do(ctx, http.MethodPut, "/api/profile", p, &out, true); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteProfile 清空当前用户的资料
+func (c *Client) DeleteProfile(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/api/profile", nil, nil, true)
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/champly/ai-agent/pkg/agent"
+)
+
+// Document 添加到知识库的文档
+type Document struct {
+	ID         string            `json:"id"`
+	Collection string            `json:"collection,omitempty"`
+	Content    string            `json:"content,omitempty"`
+	Chunks     []string          `json:"chunks,omitempty"` // 预分块的内容，指定时忽略 Content
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// SearchRequest 知识库检索请求
+type SearchRequest struct {
+	Query      string `json:"query"`
+	Collection string `json:"collection,omitempty"` // 为空时检索所有集合
+	TopK       int    `json:"top_k,omitempty"`      // 为空时使用服务端配置
+}
+
+// SearchResult 知识库检索结果
+type SearchResult struct {
+	ID         string            `json:"id"`
+	Collection string            `json:"collection"`
+	Content    string            `json:"content"`
+	Score      float32           `json:"score"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// AddDocument 添加文档到知识库，同 ID 的文档被替换
+func (c *Client) AddDocument(ctx context.Context, doc Document) error {
+	return c.do(ctx, http.MethodPost, "/api/rag/add", doc, nil, true)
+}
+
+// Search 检索知识库
+func (c *Client) Search(ctx context.Context, req SearchRequest) ([]SearchResult, error) {
+	var resp struct {
+		Results []SearchResult `json:"results"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/rag/search", req, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// Ingest 从服务端可访问的文件、目录或 URL 导入文档到 collection，等待导入完成并返回导入的文档数
+func (c *Client) Ingest(ctx context.Context, collection, source string) (int, error) {
+	in := map[string]any{"source": source, "collection": collection}
+	var resp struct {
+		Documents int `json:"documents"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/rag/ingest", in, &resp, false); err != nil {
+		return 0, err
+	}
+	return resp.Documents, nil
+}
+
+// StartIngest 在后台导入文档，立即返回导入任务，通过 GetIngest 查询进度
+func (c *Client) StartIngest(ctx context.Context, collection, source string) (*agent.IngestJob, error) {
+	in := map[string]any{"source": source, "collection": collection, "async": true}
+	var job agent.IngestJob
+	if err := c.do(ctx, http.MethodPost, "/api/rag/ingest", in, &job, false); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetIngest 查询后台导入任务
+func (c *Client) GetIngest(ctx context.Context, id string) (*agent.IngestJob, error) {
+	var job agent.IngestJob
+	if err := c.do(ctx, http.MethodGet, "/api/rag/jobs/"+url.PathEscape(id), nil, &job, true); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
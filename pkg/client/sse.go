@@ -0,0 +1,63 @@
+package client
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// maxEventSize 单条 SSE 事件的最大长度（工具结果可能较长）
+const maxEventSize = 16 << 20
+
+// sseEvent 一条 Server-Sent Events 事件
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// sseReader 按行解析 Server-Sent Events 流
+type sseReader struct {
+	scanner *bufio.Scanner
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+	return &sseReader{scanner: scanner}
+}
+
+// next 读取下一条事件，流结束时返回 io.EOF（不完整的最后一条事件被丢弃）
+func (r *sseReader) next() (*sseEvent, error) {
+	var ev sseEvent
+	var data []string
+	hasData := false
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if line == "" {
+			if !hasData {
+				continue
+			}
+			ev.Data = strings.Join(data, "\n")
+			return &ev, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			ev.ID = value
+		case "event":
+			ev.Event = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		}
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/champly/ai-agent/pkg/agent"
+)
+
+// errMalformedEvent 事件内容无法解码，重连也无济于事
+var errMalformedEvent = errors.New("malformed task event")
+
+// Task 后台任务
+type Task struct {
+	agent.Task
+	// ErrorStatus 失败时与同步调用 Chat 对应的 HTTP 状态码
+	ErrorStatus int `json:"error_status,omitempty"`
+}
+
+// TaskOptions 提交任务的选项
+type TaskOptions struct {
+	// RAG 检索知识库后回答
+	RAG bool
+	// RunAt 定时执行，为零值时立即执行
+	RunAt time.Time
+}
+
+// SubmitTask 提交后台聊天任务，立即返回排队中的任务
+func (c *Client) SubmitTask(ctx context.Context, req *agent.ChatRequest, opts TaskOptions) (*Task, error) {
+	in := struct {
+		*agent.ChatRequest
+		RAG   bool      `json:"rag,omitempty"`
+		RunAt time.Time `json:"run_at,omitzero"`
+	}{req, opts.RAG, opts.RunAt}
+	var task Task
+	if err := c.do(ctx, http.MethodPost, "/api/tasks", in, &task, false); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetTask 查询任务，不存在时返回 404 的 APIError
+func (c *Client) GetTask(ctx context.Context, id string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodGet, "/api/tasks/"+url.PathEscape(id), nil, &task, true); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ListTasks 列出当前用户的任务
+func (c *Client) ListTasks(ctx context.Context) ([]*Task, error) {
+	var resp struct {
+		Tasks []*Task `json:"tasks"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/tasks", nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Tasks, nil
+}
+
+// CancelTask 取消任务，返回取消后的任务
+func (c *Client) CancelTask(ctx context.Context, id string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodDelete, "/api/tasks/"+url.PathEscape(id), nil, &task, true); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// WatchTask 跟踪任务直到结束，依次对第 after 个之后的事件调用 handler（可为空），返回结束时的任务。
+// 连接中断时从最后收到的事件之后重连（Last-Event-ID），连续失败超过 MaxRetries 次时返回错误
+func (c *Client) WatchTask(ctx context.Context, id string, after int, handler agent.EventHandler) (*Task, error) {
+	path := "/api/tasks/" + url.PathEscape(id) + "/events"
+	failures := 0
+	backoff := c.retryBackoff
+	for {
+		task, n, err := c.watchTask(ctx, path, after, handler)
+		if err == nil {
+			return task, nil
+		}
+		if n > 0 {
+			// 收到了新事件，重新计算连续失败次数
+			failures, backoff = 0, c.retryBackoff
+		}
+		after += n
+
+		var apiErr *APIError
+		if ctx.Err() != nil || failures >= c.maxRetries || errors.Is(err, errMalformedEvent) ||
+			errors.As(err, &apiErr) && !retryable(err, true) {
+			return nil, err
+		}
+		failures++
+
+		wait := backoff
+		backoff = min(backoff*2, c.maxRetryWait)
+		if apiErr != nil && apiErr.RetryAfter > 0 {
+			wait = min(apiErr.RetryAfter, c.maxRetryWait)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// watchTask 建立一次事件连接，返回结束时的任务和本次连接收到的事件数
+func (c *Client) watchTask(ctx context.Context, path string, after int, handler agent.EventHandler) (*Task, int, error) {
+	header := http.Header{"Accept": {"text/event-stream"}}
+	if after > 0 {
+		header.Set("Last-Event-ID", strconv.Itoa(after))
+	}
+	resp, err := c.send(ctx, http.MethodGet, path, nil, header)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	received := 0
+	events := newSSEReader(resp.Body)
+	for {
+		ev, err := events.next()
+		if errors.Is(err, io.EOF) {
+			return nil, received, fmt.Errorf("task events: %w", io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return nil, received, fmt.Errorf("task events: %w", err)
+		}
+
+		if ev.Event == "done" {
+			var task Task
+			if err := json.Unmarshal([]byte(ev.Data), &task); err != nil {
+				return nil, received, fmt.Errorf("%w: decode task: %v", errMalformedEvent, err)
+			}
+			return &task, received, nil
+		}
+		received++
+		if handler == nil {
+			continue
+		}
+		var out agent.Event
+		if err := json.Unmarshal([]byte(ev.Data), &out); err != nil {
+			return nil, received, fmt.Errorf("%w: %v", errMalformedEvent, err)
+		}
+		handler(out)
+	}
+}
+
+// WaitTask 等待任务结束并返回结束时的任务，不关心中间事件时使用
+func (c *Client) WaitTask(ctx context.Context, id string) (*Task, error) {
+	return c.WatchTask(ctx, id, 0, nil)
+}
@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// ListTools 列出当前用户可用的工具，每个工具包含 name、description 等字段
+func (c *Client) ListTools(ctx context.Context) ([]map[string]string, error) {
+	var resp struct {
+		Tools []map[string]string `json:"tools"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/tools", nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Tools, nil
+}
+
+// CallTool 直接调用工具并返回结果，受工具策略限制，被拒绝时返回 403 的 APIError
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	in := map[string]any{"name": name, "arguments": args}
+	var resp struct {
+		Result string `json:"result"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/tools/call", in, &resp, false); err != nil {
+		return "", err
+	}
+	return resp.Result, nil
+}
@@ -2,18 +2,82 @@ package config
 
 import (
 	"fmt"
+	"net/mail"
+	"net/url"
 	"os"
+	"path"
+	"slices"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/champly/ai-agent/pkg/locale"
 )
 
 // Config 应用配置
 type Config struct {
-	Server     ServerConfig      `yaml:"server"`
-	Ollama     OllamaConfig      `yaml:"ollama"`
-	MCPServers []MCPServerConfig `yaml:"mcp_servers"`
-	RAG        RAGConfig         `yaml:"rag"`
+	Server       ServerConfig       `yaml:"server"`
+	Ollama       OllamaConfig       `yaml:"ollama"`
+	MCPServers   []MCPServerConfig  `yaml:"mcp_servers"`
+	RAG          RAGConfig          `yaml:"rag"`
+	Conversation ConversationConfig `yaml:"conversation"`
+	Leader       LeaderConfig       `yaml:"leader"`
+	Watcher      WatcherConfig      `yaml:"watcher"`
+	Slack        SlackConfig        `yaml:"slack"`
+	Telegram     TelegramConfig     `yaml:"telegram"`
+	Connectors   ConnectorsConfig   `yaml:"connectors"`
+	GitHub       GitHubConfig       `yaml:"github"`
+	Email        EmailConfig        `yaml:"email"`
+	A2A          A2AConfig          `yaml:"a2a"`
+	MCPEndpoint  MCPEndpointConfig  `yaml:"mcp_endpoint"`
+	Operator     OperatorConfig     `yaml:"operator"`
+	Secrets      SecretsConfig      `yaml:"secrets"`
+	Redis        RedisConfig        `yaml:"redis"`
+	Policy       PolicyConfig       `yaml:"policy"`
+	Tenants      []TenantConfig     `yaml:"tenants"`
+	Filters      FilterConfig       `yaml:"filters"`
+	Guard        GuardConfig        `yaml:"guard"`
+	Quota        QuotaConfig        `yaml:"quota"`
+	Egress       EgressConfig       `yaml:"egress"`
+	Audit        AuditConfig        `yaml:"audit"`
+	Cache        CacheConfig        `yaml:"cache"`
+	ToolCache    ToolCacheConfig    `yaml:"tool_cache"`
+	Images       ImageConfig        `yaml:"images"`
+	Routing      RoutingConfig      `yaml:"routing"`
+	Escalation   EscalationConfig   `yaml:"escalation"`
+	Workers      WorkerPoolConfig   `yaml:"workers"`
+	Batch        BatchConfig        `yaml:"batch"`
+	Tasks        TaskConfig         `yaml:"tasks"`
+	Workflows    WorkflowConfig     `yaml:"workflows"`
+	Prompts      PromptConfig       `yaml:"prompts"`
+	Analysis     AnalysisConfig     `yaml:"analysis"`
+	Webhooks     []WebhookConfig    `yaml:"webhooks"`
+	ToolResults  ToolResultConfig   `yaml:"tool_results"`
+	Notes        NotesConfig        `yaml:"notes"`
+	// KnowledgeGraph 从对话和导入文档中抽取的实体关系图
+	KnowledgeGraph KnowledgeGraphConfig `yaml:"knowledge_graph"`
+	// Profiles 从对话中学习、可通过 API 编辑的用户资料
+	Profiles    ProfileConfig      `yaml:"profiles"`
+	ToolHints   ToolHintsConfig    `yaml:"tool_hints"`
+	Experiments []ExperimentConfig `yaml:"experiments"`
+	Personas    []PersonaConfig    `yaml:"personas"`
+	// StructuredOutput 聊天请求指定 schema 时的结构化输出
+	StructuredOutput StructuredOutputConfig `yaml:"structured_output"`
+	// OutputValidation 最终回答的校验规则和重试策略
+	OutputValidation OutputValidationConfig `yaml:"output_validation"`
+	// Reasoning 推理模型思考过程的处理
+	Reasoning ReasoningConfig `yaml:"reasoning"`
+	// Plugins 外部工具插件
+	Plugins PluginConfig `yaml:"plugins"`
+	// OpenAPI 由 OpenAPI 3 规范生成工具的 REST API
+	OpenAPI []OpenAPIConfig `yaml:"openapi"`
+	// GRPCTools 通过 gRPC ToolProvider 服务提供工具的远程服务
+	GRPCTools []GRPCToolConfig `yaml:"grpc_tools"`
+	// CompositeTools 依次调用多个工具或模型的组合工具
+	CompositeTools []CompositeToolConfig `yaml:"composite_tools"`
+	// ToolCapabilities 按工具名设置的能力标记，替换 MCP 工具注解中的标记
+	ToolCapabilities []ToolCapabilityRule `yaml:"tool_capabilities"`
 }
 
 // ServerConfig 服务器配置
@@ -21,17 +85,62 @@ type ServerConfig struct {
 	Name    string `yaml:"name"`
 	Version string `yaml:"version"`
 	Listen  string `yaml:"listen"`
-	Debug   bool   `yaml:"debug"`
+	// GRPCListen gRPC API 的监听地址，为空时不启动；与 HTTP API 共用 tls 和 auth 配置
+	GRPCListen string `yaml:"grpc_listen"`
+	// DisableUI 不在 / 提供内置的聊天界面
+	DisableUI bool       `yaml:"disable_ui"`
+	Debug     bool       `yaml:"debug"`
+	TLS       TLSConfig  `yaml:"tls"`
+	Auth      AuthConfig `yaml:"auth"`
+}
+
+// AuthConfig HTTP API 的 OIDC/JWT 认证配置，Issuer 为空时不启用认证
+type AuthConfig struct {
+	Issuer    string `yaml:"issuer"`     // OIDC 提供方地址，需与 token 中的 iss 一致
+	Audience  string `yaml:"audience"`   // 期望的 aud（通常为 client ID），为空时不校验
+	JWKSURL   string `yaml:"jwks_url"`   // 签名公钥地址，为空时通过 issuer 的 discovery 文档获取
+	UserClaim string `yaml:"user_claim"` // 作为用户身份的 claim，默认 sub，常用 email、preferred_username
+}
+
+// TLSConfig HTTP API 的 TLS 配置，证书文件更新后自动重新加载
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file"`      // 服务端证书，为空时使用明文 HTTP
+	KeyFile      string `yaml:"key_file"`       // 服务端私钥
+	ClientCAFile string `yaml:"client_ca_file"` // 校验客户端证书的 CA（mTLS）
+	ClientAuth   string `yaml:"client_auth"`    // none、request（提供时校验）或 require；配置了 CA 时默认 require
 }
 
 // OllamaConfig Ollama 配置
 type OllamaConfig struct {
-	Host       string        `yaml:"host"`
-	Model      string        `yaml:"model"`
-	Timeout    time.Duration `yaml:"timeout"`
-	MaxRetries int           `yaml:"max_retries"`
-	// 系统提示，用于优化模型行为和减少 token 消耗
+	Host string `yaml:"host"`
+	// Hosts 多个 Ollama 主机，设置后替代 Host，请求按 Routing 分发到已拉取所需模型的健康主机
+	Hosts          []string      `yaml:"hosts"`
+	Routing        string        `yaml:"routing"`         // least_loaded（默认）或 round_robin
+	HealthInterval time.Duration `yaml:"health_interval"` // 健康检查和模型列表刷新间隔
+	Model          string        `yaml:"model"`
+	// Timeout 等待模型开始输出（含模型加载）的超时，开始输出后不再限制总时长，
+	// 改由 StreamIdleTimeout 限制两次输出之间的间隔
+	Timeout           time.Duration `yaml:"timeout"`
+	StreamIdleTimeout time.Duration `yaml:"stream_idle_timeout"`
+	ConnectTimeout    time.Duration `yaml:"connect_timeout"`   // 建立连接的超时
+	MaxIdleConns      int           `yaml:"max_idle_conns"`    // 每个主机保持的空闲连接数
+	IdleConnTimeout   time.Duration `yaml:"idle_conn_timeout"` // 空闲连接的保持时间
+	MaxRetries        int           `yaml:"max_retries"`
+	// MaxConcurrent 每台主机同时处理的请求数（与 OLLAMA_NUM_PARALLEL 一致），0 表示不限制；
+	// 超出时请求排队，交互式聊天先于批量任务和知识库导入的嵌入请求
+	MaxConcurrent int `yaml:"max_concurrent"`
+	// KeepAlive 随每次请求发送的 keep_alive，模型在最后一次请求后保持加载的时间；
+	// 0 表示使用 Ollama 的默认值（5 分钟），负数表示一直保持加载
+	KeepAlive time.Duration `yaml:"keep_alive"`
+	// Warmup 启动后预先加载的模型，避免第一个请求等待模型加载
+	Warmup []string `yaml:"warmup"`
+	// MaxContext 请求的 num_ctx 上限：设置后 num_ctx 取模型支持的上下文长度（/api/show）与该值中较小者，
+	// 历史超出时裁剪较早的消息；为 0 时不设置 num_ctx，使用 Ollama 的默认值
+	MaxContext int `yaml:"max_context"`
+	// 系统提示，用于优化模型行为和减少 token 消耗；为空时按回答语言使用内置的中文或英文提示
 	SystemPrompt string `yaml:"system_prompt"`
+	// Language 默认回答语言（zh、en、ja 等），请求可以指定其他语言，同一对话沿用
+	Language string `yaml:"language"`
 }
 
 // MCPServerConfig 外部 MCP 服务器配置
@@ -42,6 +151,16 @@ type MCPServerConfig struct {
 	Env       map[string]string `yaml:"env"`
 	Transport string            `yaml:"transport"` // stdio
 	Enabled   bool              `yaml:"enabled"`
+
+	ShutdownGrace time.Duration `yaml:"shutdown_grace"` // 停止时关闭 stdin 后等待退出的时间，超时后依次发送 SIGTERM、SIGKILL
+
+	// 工具调用并发限制，stdio 服务器通常串行处理请求，并发调用只会在管道中排队
+	MaxInFlight  int           `yaml:"max_in_flight"` // 同时进行的调用数，默认 1
+	MaxQueue     int           `yaml:"max_queue"`     // 等待中的调用数上限，超出时立即失败，默认 32
+	QueueTimeout time.Duration `yaml:"queue_timeout"` // 最长等待时间，默认 30s
+
+	// RefreshInterval 定期重新获取工具列表的间隔，为 0 时只在服务器发送 listChanged 通知时刷新
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
 }
 
 // RAGConfig RAG 配置
@@ -51,6 +170,703 @@ type RAGConfig struct {
 	ChunkOverlap int    `yaml:"chunk_overlap"` // 分块重叠
 	TopK         int    `yaml:"top_k"`         // 检索返回的最大结果数
 	DocumentsDir string `yaml:"documents_dir"` // RAG 文档目录
+	StorePath    string `yaml:"store_path"`    // 向量持久化文件，为空时仅保存在内存中
+	Backend      string `yaml:"backend"`       // 向量存储后端：memory（默认）或 redis（多副本共享）
+	IngestJobs   int    `yaml:"ingest_jobs"`   // 同时进行的后台导入任务数上限
+	// References RAG 聊天的回答末尾附加编号的参考资料列表，为 false 时请求可以用 references 单独开启
+	References bool `yaml:"references"`
+	// Embedding 生成嵌入向量的服务，可以与聊天模型使用不同的主机
+	Embedding EmbeddingConfig `yaml:"embedding"`
+	// GitDir git 来源的本地仓库目录，每个来源一个子目录
+	GitDir string `yaml:"git_dir"`
+	// GitSources 定期同步到知识库的 git 仓库，仅主节点同步
+	GitSources []GitSourceConfig `yaml:"git_sources"`
+}
+
+// GitSourceConfig 定期拉取的 git 仓库：变化的文件重新导入集合，仓库中删除的文件从集合中删除，
+// 分块的元数据记录导入时的提交 SHA
+type GitSourceConfig struct {
+	Name       string        `yaml:"name"`       // 来源名称，也是本地仓库目录名和文档 ID 的前缀
+	URL        string        `yaml:"url"`        // 仓库地址：https、ssh 或本地路径
+	Branch     string        `yaml:"branch"`     // 同步的分支，为空时使用远端的默认分支
+	Collection string        `yaml:"collection"` // 导入的集合，默认与 name 相同
+	Paths      []string      `yaml:"paths"`      // 只导入这些目录或文件（相对仓库根目录），为空时导入整个仓库
+	Extensions []string      `yaml:"extensions"` // 导入的文件扩展名，默认 .md 和 .txt
+	Interval   time.Duration `yaml:"interval"`   // 同步间隔，默认 1h
+	// Username、Token https 仓库的认证信息，以请求头传给 git，不写入本地仓库配置；Token 支持 env:、vault: 引用
+	Username string `yaml:"username"`
+	Token    string `yaml:"token"`
+}
+
+// EmbeddingConfig 嵌入向量的提供方：嵌入模型通常在 CPU 上单独部署，不必与 GPU 上的聊天模型共用主机
+type EmbeddingConfig struct {
+	Provider string `yaml:"provider"` // ollama（默认）或 openai（OpenAI 兼容的 embeddings 接口）
+	// Host ollama 为单独的 Ollama 主机，为空时使用聊天的 Ollama 主机；openai 为接口地址，如 http://localhost:8081/v1
+	Host       string        `yaml:"host"`
+	APIKey     string        `yaml:"api_key"`    // openai 的 API Key，支持 env:、vault: 引用
+	Dimensions int           `yaml:"dimensions"` // openai 返回的向量维度，0 表示使用模型默认值
+	Timeout    time.Duration `yaml:"timeout"`    // 单次请求超时，默认 30s
+}
+
+// ConversationConfig 对话存储配置
+type ConversationConfig struct {
+	Store string `yaml:"store"` // 存储类型：memory（默认）、file 或 redis（多副本共享）
+	Dir   string `yaml:"dir"`   // file 存储的目录
+	// Concurrency 同一对话的并发请求处理方式：queue（默认，排队等待上一轮结束）或 reject（立即返回对话忙）
+	Concurrency  string        `yaml:"concurrency"`
+	QueueTimeout time.Duration `yaml:"queue_timeout"` // queue 模式下的最长等待时间，超时返回对话忙
+}
+
+// StructuredOutputConfig 结构化输出配置
+type StructuredOutputConfig struct {
+	MaxRetries int `yaml:"max_retries"` // 最终回答不符合 JSON Schema 时要求模型修复的最大次数
+}
+
+// PluginConfig 外部工具插件：启动时运行 dir 中的插件程序，插件提供的工具与 MCP 工具一起使用
+type PluginConfig struct {
+	Dir           string        `yaml:"dir"`            // 插件目录，为空时不加载插件
+	StartTimeout  time.Duration `yaml:"start_timeout"`  // 等待插件完成握手的时间
+	CallTimeout   time.Duration `yaml:"call_timeout"`   // 单次工具调用的超时
+	ShutdownGrace time.Duration `yaml:"shutdown_grace"` // 关闭标准输入后等待插件退出的时间，超时后强制结束
+}
+
+// OpenAPIConfig 由 OpenAPI 3 规范生成工具的 REST API，每个选中的操作生成一个工具
+type OpenAPIConfig struct {
+	Name        string            `yaml:"name"`        // API 名称，工具来源为 openapi:<name>，工具范围的 mcp_servers 中写该名称
+	Spec        string            `yaml:"spec"`        // 规范文件路径或 http(s) 地址，JSON 或 YAML
+	BaseURL     string            `yaml:"base_url"`    // 请求地址，为空时使用规范中的第一个 servers
+	Operations  []string          `yaml:"operations"`  // 生成工具的 operationId，支持 * 通配；为空时只生成 GET 操作
+	ToolPrefix  string            `yaml:"tool_prefix"` // 工具名前缀，避免与其他工具重名
+	Headers     map[string]string `yaml:"headers"`     // 每个请求附加的请求头
+	Credentials map[string]string `yaml:"credentials"` // 按 securitySchemes 名称提供的凭证：apiKey 为密钥，http bearer 和 oauth2 为 token，http basic 为 user:password
+	Timeout     time.Duration     `yaml:"timeout"`     // 单次请求超时，默认 30s
+}
+
+// GRPCToolConfig 实现 ToolProvider 服务（pkg/toolprovider/pb）的远程工具服务，默认使用 TLS
+type GRPCToolConfig struct {
+	Name        string        `yaml:"name"`         // 服务名称，工具来源为 grpc:<name>，工具范围的 mcp_servers 中写该名称
+	Address     string        `yaml:"address"`      // 服务地址 host:port
+	CAFile      string        `yaml:"ca_file"`      // 校验服务端证书的 CA，为空时使用系统 CA
+	CertFile    string        `yaml:"cert_file"`    // 客户端证书（mTLS）
+	KeyFile     string        `yaml:"key_file"`     // 客户端私钥
+	ServerName  string        `yaml:"server_name"`  // 校验服务端证书时使用的名称，为空时取地址中的主机名
+	Insecure    bool          `yaml:"insecure"`     // 使用明文连接，仅用于本机调试
+	DialTimeout time.Duration `yaml:"dial_timeout"` // 启动时连接并获取工具列表的超时，默认 10s
+	CallTimeout time.Duration `yaml:"call_timeout"` // 单次工具调用的超时，默认 60s
+}
+
+// 工具能力标记
+const (
+	CapabilityReadOnly    = "read_only"    // 只读取数据，不修改任何状态
+	CapabilityDestructive = "destructive"  // 可能删除或覆盖数据
+	CapabilityNetwork     = "network"      // 访问 Agent 所在主机以外的系统
+	CapabilityLongRunning = "long_running" // 执行时间较长
+)
+
+// ValidCapability 是否为已知的工具能力标记
+func ValidCapability(name string) bool {
+	switch name {
+	case CapabilityReadOnly, CapabilityDestructive, CapabilityNetwork, CapabilityLongRunning:
+		return true
+	}
+	return false
+}
+
+// ToolCapabilityRule 工具的能力标记，按顺序匹配第一条规则；没有匹配的规则时使用 MCP 工具注解中的标记
+type ToolCapabilityRule struct {
+	Tool         string   `yaml:"tool"`         // 工具名模式，支持 * 通配
+	Capabilities []string `yaml:"capabilities"` // 能力标记，为空表示没有任何标记
+}
+
+// ReasoningConfig 推理模型（qwen3、deepseek-r1 等）输出的思考过程，始终从回答中分离
+type ReasoningConfig struct {
+	Hide          bool `yaml:"hide"`            // 不在响应的 reasoning 字段中返回思考过程
+	KeepInHistory bool `yaml:"keep_in_history"` // 思考过程保留在对话历史中，默认丢弃以节省上下文
+}
+
+// OutputValidationConfig 最终回答的校验：不通过时把原因告诉模型并要求重新回答
+type OutputValidationConfig struct {
+	MaxRetries int `yaml:"max_retries"` // 不通过时要求模型重新回答的最大次数
+	// OnFailure 重试后仍不通过时的处理：error（默认，请求失败）或 accept（返回最后的回答并附上未通过的规则）
+	OnFailure  string                  `yaml:"on_failure"`
+	Validators []OutputValidatorConfig `yaml:"validators"`
+}
+
+// OutputValidatorConfig 回答校验规则。default 为 true 的规则对所有请求生效，
+// 其他规则在人设或聊天请求的 validators 中指定时生效
+type OutputValidatorConfig struct {
+	Name      string `yaml:"name" json:"name"`
+	Type      string `yaml:"type" json:"type"`                       // regex、json、max_length 或 language
+	Pattern   string `yaml:"pattern" json:"pattern,omitempty"`       // regex：回答需匹配的正则表达式
+	Forbid    bool   `yaml:"forbid" json:"forbid,omitempty"`         // regex：为 true 时回答不能匹配
+	MaxLength int    `yaml:"max_length" json:"max_length,omitempty"` // max_length：最大字符数
+	Language  string `yaml:"language" json:"language,omitempty"`     // language：zh 或 en
+	Message   string `yaml:"message" json:"message,omitempty"`       // 不通过时告诉模型的修改要求，为空时按规则生成
+	Default   bool   `yaml:"default" json:"default,omitempty"`       // 对所有请求生效
+}
+
+// LeaderConfig 主节点选举配置（多副本部署时仅主节点执行后台任务）
+type LeaderConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Backend       string        `yaml:"backend"`        // lease（Kubernetes Lease）或 file（锁文件）
+	Identity      string        `yaml:"identity"`       // 实例标识，默认为 主机名-进程号
+	LeaseName     string        `yaml:"lease_name"`     // Lease 名称
+	Namespace     string        `yaml:"namespace"`      // Lease 所在命名空间，默认为当前 Pod 命名空间
+	LockPath      string        `yaml:"lock_path"`      // 锁文件路径（需位于各副本共享的存储上）
+	LeaseDuration time.Duration `yaml:"lease_duration"` // 租约时长
+	RenewDeadline time.Duration `yaml:"renew_deadline"` // 续约截止时间（仅 lease）
+	RetryPeriod   time.Duration `yaml:"retry_period"`   // 重试间隔
+}
+
+// WatcherConfig Kubernetes 事件监听配置，匹配的告警事件会自动触发诊断对话
+type WatcherConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Kubeconfig      string        `yaml:"kubeconfig"`        // kubeconfig 路径，为空时优先使用集群内凭证
+	Namespaces      []string      `yaml:"namespaces"`        // 监听的命名空间，为空时使用当前命名空间
+	LabelSelector   string        `yaml:"label_selector"`    // 事件关联 Pod 需匹配的标签选择器
+	Reasons         []string      `yaml:"reasons"`           // 关注的 Warning 事件原因
+	Cooldown        time.Duration `yaml:"cooldown"`          // 同一对象同一原因的重复触发间隔
+	Workers         int           `yaml:"workers"`           // 并发诊断数
+	QueueSize       int           `yaml:"queue_size"`        // 待诊断队列长度，超出时丢弃
+	Prompt          string        `yaml:"prompt"`            // 诊断提示模板（Go text/template）
+	WebhookURL      string        `yaml:"webhook_url"`       // 诊断结果推送的通用 Webhook
+	SlackWebhookURL string        `yaml:"slack_webhook_url"` // 诊断结果推送的 Slack Incoming Webhook
+}
+
+// ConnectorsConfig 聊天平台连接器（Slack、Telegram 等）的共享设置
+type ConnectorsConfig struct {
+	// Backend 频道人设等设置的存储后端：memory（默认）或 redis（多副本共享）
+	Backend string `yaml:"backend"`
+}
+
+// ConnectorConfig 连接器的公共配置：对话使用的模型和身份、回答的更新频率和收到文件时的处理方式
+type ConnectorConfig struct {
+	Model      string `yaml:"model"`      // 为空时使用默认模型
+	RAG        bool   `yaml:"rag"`        // 按 /api/chat/rag 处理
+	Collection string `yaml:"collection"` // RAG 聊天检索和 rag 方式导入文件的集合，为空时使用默认集合
+	User       string `yaml:"user"`       // 执行身份，用于配额、工具策略、租户和审计，默认为连接器名称
+	// UpdateInterval 回答过程中更新消息（工具调用进度）的最短间隔，避免触发平台的频率限制
+	UpdateInterval time.Duration `yaml:"update_interval"`
+	// Uploads 收到文件时的处理方式：ignore（默认）、rag（导入 Collection 集合）或 workspace（保存到 WorkspaceDir）
+	Uploads      string `yaml:"uploads"`
+	WorkspaceDir string `yaml:"workspace_dir"`  // workspace 方式的保存目录，每个频道一个子目录，通常为内置 MCP Server 的根目录
+	MaxFileBytes int    `yaml:"max_file_bytes"` // 接收文件的最大字节数
+	// ToolProfile 可用的工具范围，与租户限制同时生效
+	ToolProfile `yaml:",inline"`
+}
+
+// setDefaults 设置连接器公共配置的默认值，name 为连接器名称
+func (c *ConnectorConfig) setDefaults(name string) {
+	if c.User == "" {
+		c.User = name
+	}
+	if c.UpdateInterval == 0 {
+		c.UpdateInterval = time.Second
+	}
+	if c.Uploads == "" {
+		c.Uploads = "ignore"
+	}
+	if c.MaxFileBytes == 0 {
+		c.MaxFileBytes = 20 << 20
+	}
+}
+
+// validate 校验连接器公共配置，name 为连接器名称
+func (c *ConnectorConfig) validate(name string) error {
+	switch c.Uploads {
+	case "ignore", "rag":
+	case "workspace":
+		if c.WorkspaceDir == "" {
+			return fmt.Errorf("%s.workspace_dir is required for workspace uploads", name)
+		}
+	default:
+		return fmt.Errorf("unknown %s uploads mode: %s", name, c.Uploads)
+	}
+	return nil
+}
+
+// SlackConfig Slack 连接（Socket Mode）：频道中 @ 机器人或私信时回答，每个讨论串对应一个对话
+type SlackConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	AppToken string `yaml:"app_token"` // 应用级 token（xapp-），需要 connections:write 权限
+	BotToken string `yaml:"bot_token"` // 机器人 token（xoxb-），需要 app_mentions:read、chat:write、commands 权限，私信还需要 im:history
+	// Channels 只响应这些频道（频道 ID），为空表示机器人所在的全部频道
+	Channels []string `yaml:"channels"`
+	// DirectMessages 是否响应私信
+	DirectMessages bool   `yaml:"direct_messages"`
+	Command        string `yaml:"command"` // 斜杠命令，默认 /agent
+	// ConnectorConfig 模型、执行身份和工具范围等公共配置
+	ConnectorConfig `yaml:",inline"`
+}
+
+// TelegramConfig Telegram 机器人：长轮询接收消息，每个聊天对应一个对话
+type TelegramConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Token   string `yaml:"token"` // BotFather 发放的机器人 token
+	// AllowedChats 允许使用机器人的聊天 ID（私聊为用户 ID，群组为负数），其他聊天只收到包含其 ID 的拒绝消息
+	AllowedChats []int64 `yaml:"allowed_chats"`
+	// ConnectorConfig 模型、执行身份、文件处理和工具范围等公共配置
+	ConnectorConfig `yaml:",inline"`
+}
+
+// EmailConfig 邮件助手：轮询 IMAP 邮箱的未读邮件，每个邮件会话（按 References 归并）对应一个对话，通过 SMTP 回复
+type EmailConfig struct {
+	Enabled  bool              `yaml:"enabled"`
+	IMAP     EmailServerConfig `yaml:"imap"`
+	SMTP     EmailServerConfig `yaml:"smtp"`
+	Username string            `yaml:"username"` // IMAP 和 SMTP 的登录用户名
+	Password string            `yaml:"password"` // 登录密码或应用专用密码，支持 env:、vault: 引用
+	Address  string            `yaml:"address"`  // 回复的发件地址，默认为 username
+	Mailbox  string            `yaml:"mailbox"`  // 轮询的邮箱文件夹，默认 INBOX
+	// PollInterval 轮询未读邮件的间隔
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// AllowedSenders 允许的发件人：完整地址或 @域名，为空时不处理任何邮件；其他发件人的邮件标为已读后忽略
+	AllowedSenders []string `yaml:"allowed_senders"`
+	// ConnectorConfig 模型、执行身份、附件处理和工具范围等公共配置
+	ConnectorConfig `yaml:",inline"`
+}
+
+// EmailServerConfig 邮件服务器地址和加密方式
+type EmailServerConfig struct {
+	Addr string `yaml:"addr"` // host:port，如 imap.example.com:993、smtp.example.com:587
+	// TLS 加密方式：tls（默认，连接即加密，端口 993/465）、starttls（端口 143/587）或 none（仅用于本地测试）
+	TLS string `yaml:"tls"`
+}
+
+// GitHubConfig GitHub 拉取请求审查：打开拉取请求时由 webhook 触发，模型通过 GitHub 工具读取改动，以配置的人设审查后发表评论
+type GitHubConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	APIURL  string `yaml:"api_url"` // 默认 https://api.github.com，GitHub Enterprise 为 https://<host>/api/v3
+	// Token 个人或 fine-grained token，需要 contents 读和 pull requests 读写权限；与 app_id 二选一
+	Token string `yaml:"token"`
+	// AppID、PrivateKey GitHub App 的 ID 和私钥（PEM），以 App 在仓库上的安装身份访问，评论显示为 App
+	AppID         int64  `yaml:"app_id"`
+	PrivateKey    string `yaml:"private_key"`
+	WebhookSecret string `yaml:"webhook_secret"` // 校验 X-Hub-Signature-256 签名
+	// Repositories 允许审查和工具访问的仓库 owner/repo，支持 owner/* 通配；为空时不处理任何仓库
+	Repositories []string `yaml:"repositories"`
+	// Paths 审查的文件路径：dir/** 表示目录下的所有文件，不含 / 的模式（如 *.go）匹配文件名；为空表示全部
+	Paths []string `yaml:"paths"`
+	// Actions 触发审查的 pull_request 事件 action，默认 opened、reopened、ready_for_review
+	Actions      []string `yaml:"actions"`
+	Drafts       bool     `yaml:"drafts"`         // 是否审查草稿
+	Persona      string   `yaml:"persona"`        // 审查使用的人设，为空时使用默认人设
+	Model        string   `yaml:"model"`          // 为空时使用人设或默认模型
+	MaxFiles     int      `yaml:"max_files"`      // 审查的最大文件数，超出的文件不审查
+	MaxDiffBytes int      `yaml:"max_diff_bytes"` // GitHub 工具单次返回的 diff 或文件内容的最大字节数
+	MaxComments  int      `yaml:"max_comments"`   // 单次审查最多的行内评论数，其余并入审查总结
+	User         string   `yaml:"user"`           // 执行身份，用于配额、工具策略、租户和审计，默认 github
+	// ToolProfile 审查时可用的工具范围，GitHub 工具在 mcp_servers 中写 github
+	ToolProfile `yaml:",inline"`
+}
+
+// A2AConfig Agent-to-Agent（A2A）协议端点：其他 Agent 框架通过 agent card 发现本 Agent，以 JSON-RPC 委派任务
+type A2AConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// URL 对外的 JSON-RPC 地址，写入 agent card；为空时按请求的 Host 生成 <scheme>://<host>/a2a
+	URL         string `yaml:"url"`
+	Description string `yaml:"description"` // agent card 中的描述
+	Persona     string `yaml:"persona"`     // 处理委派任务使用的人设，为空时使用默认人设
+	// Skills agent card 中声明的技能，供调用方选择 Agent；为空时声明一个通用的运维助手技能
+	Skills []A2ASkillConfig `yaml:"skills"`
+}
+
+// A2ASkillConfig agent card 中的一个技能
+type A2ASkillConfig struct {
+	ID          string   `yaml:"id"`
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Tags        []string `yaml:"tags"`
+	Examples    []string `yaml:"examples"` // 示例提问
+}
+
+// MCPEndpointConfig 把 Agent 本身作为 MCP 服务器（ask_agent 工具）提供给 IDE 等 MCP 客户端
+type MCPEndpointConfig struct {
+	// Enabled 在 HTTP API 的 /mcp 提供 Streamable HTTP 传输；stdio 传输由 agent mcp 子命令提供，不受此开关影响
+	Enabled bool   `yaml:"enabled"`
+	Persona string `yaml:"persona"` // 新对话默认使用的人设，调用时可指定
+}
+
+// OperatorConfig 声明式配置（CRD）模式，由集群中的 Agent/ToolProfile/KnowledgeBase 资源驱动运行配置
+type OperatorConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Kubeconfig string `yaml:"kubeconfig"` // kubeconfig 路径，为空时优先使用集群内凭证
+	Namespace  string `yaml:"namespace"`  // 资源所在命名空间，为空时使用当前命名空间
+	AgentName  string `yaml:"agent_name"` // 当前进程对应的 Agent 资源名称
+}
+
+// SecretsConfig 外部密钥配置
+// 配置中形如 vault:<path>#<key> 或 env:<NAME> 的字符串会在启动时解析为实际值，并按 RefreshInterval 轮换。
+type SecretsConfig struct {
+	Provider        string        `yaml:"provider"`         // 为空时仅支持 env: 引用，vault 启用 HashiCorp Vault
+	RefreshInterval time.Duration `yaml:"refresh_interval"` // 重新解析密钥的间隔
+	Vault           VaultConfig   `yaml:"vault"`
+}
+
+// VaultConfig HashiCorp Vault 配置
+type VaultConfig struct {
+	Address         string `yaml:"address"`          // 为空时使用 VAULT_ADDR
+	Token           string `yaml:"token"`            // 为空时使用 VAULT_TOKEN
+	TokenFile       string `yaml:"token_file"`       // 从文件读取 token（每次请求重新读取，适配 Vault Agent 轮换）
+	Namespace       string `yaml:"namespace"`        // Vault 企业版命名空间
+	KubernetesRole  string `yaml:"kubernetes_role"`  // 设置后使用 Kubernetes ServiceAccount 登录
+	KubernetesMount string `yaml:"kubernetes_mount"` // Kubernetes 认证挂载路径
+}
+
+// RedisConfig 共享后端 Redis 配置（conversation.store、rag.backend 或 quota.backend 为 redis 时使用）
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`     // 地址，如 localhost:6379
+	Username string `yaml:"username"` // ACL 用户名
+	Password string `yaml:"password"` // 密码，建议使用密钥引用
+	DB       int    `yaml:"db"`       // 数据库编号
+	Prefix   string `yaml:"prefix"`   // 键前缀，多个部署共用同一 Redis 时区分
+	TLS      bool   `yaml:"tls"`      // 是否使用 TLS 连接
+}
+
+// PolicyConfig 工具调用权限策略，每次执行工具前按顺序匹配规则，第一条匹配的规则生效
+type PolicyConfig struct {
+	Default string       `yaml:"default"` // 没有规则匹配时的决定：allow（默认）或 deny
+	Rules   []PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule 策略规则，所有非空条件都满足时匹配；条件均支持 * 和 ** 通配，以 ! 开头表示取反
+type PolicyRule struct {
+	Name          string            `yaml:"name"`
+	Effect        string            `yaml:"effect"`        // allow 或 deny
+	Tools         []string          `yaml:"tools"`         // 工具名称
+	Sources       []string          `yaml:"sources"`       // 工具来源，如 mcp:builtin-filesystem
+	Users         []string          `yaml:"users"`         // 用户身份
+	Conversations []string          `yaml:"conversations"` // 对话 ID
+	Args          map[string]string `yaml:"args"`          // 参数名 -> 参数值模式，如 path: "!/workspace/**"
+}
+
+// FilterConfig 内容过滤配置，规则按顺序执行
+type FilterConfig struct {
+	Rules []FilterRule `yaml:"rules"`
+}
+
+// FilterRule 内容过滤规则，detector 与 pattern 二选一
+type FilterRule struct {
+	Name        string   `yaml:"name"`
+	Detector    string   `yaml:"detector"`    // 内置检测器：email、api_key、private_key、jwt、credit_card、cn_mobile、cn_id_card
+	Pattern     string   `yaml:"pattern"`     // 正则表达式
+	Action      string   `yaml:"action"`      // redact（默认，替换匹配内容）或 block（拦截整条内容）
+	Stages      []string `yaml:"stages"`      // input（用户消息）、tool_result、output（模型输出），为空表示全部
+	Replacement string   `yaml:"replacement"` // 替换文本，默认 [REDACTED:<规则名>]
+}
+
+// GuardConfig 工具结果的提示注入防护配置
+type GuardConfig struct {
+	Enabled    bool     `yaml:"enabled"`    // 以带随机边界和来源标记的块包装工具结果
+	Classifier string   `yaml:"classifier"` // 注入检测：为空不检测、heuristic（关键词规则）或 model（模型判断）
+	Model      string   `yaml:"model"`      // model 检测使用的模型，为空时使用默认模型
+	Action     string   `yaml:"action"`     // 检测到注入时：annotate（默认，添加警告）或 downgrade（移除可疑内容）
+	Tools      []string `yaml:"tools"`      // 需要检测的工具名称模式，支持 * 通配，为空表示全部
+}
+
+// RoutingConfig 按任务类型自动选择模型：对请求分类（简短问答、编程、总结），
+// 分发到对应的模型；请求、人设指定了模型时不分类
+type RoutingConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Classifier string `yaml:"classifier"` // heuristic（默认，关键词和长度规则）或 model（模型判断）
+	Model      string `yaml:"model"`      // model 分类使用的模型，为空时使用默认模型，建议使用小模型
+	// Routes 任务类型（qa、coding、summarization）到模型的映射，未配置的类型和无法分类的请求使用默认模型
+	Routes map[string]string `yaml:"routes"`
+}
+
+// EscalationConfig 小模型优先、按需升级：请求、人设和模型路由都没有指定模型时先由小模型回答，
+// 回答未通过检查时改由大模型重新回答
+type EscalationConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	SmallModel string `yaml:"small_model"` // 先回答的小模型
+	LargeModel string `yaml:"large_model"` // 小模型的回答未通过检查时使用的大模型
+	Checker    string `yaml:"checker"`     // heuristic（默认，空回答、截断和含糊表述）或 model（模型评分）
+	Model      string `yaml:"model"`       // model 检查使用的模型，为空时使用小模型
+	MinScore   int    `yaml:"min_score"`   // model 检查的最低分数（1-10），低于该分数时升级，默认 7
+}
+
+// TenantConfig 租户配置，按认证后的用户身份匹配。租户的用户只能使用配置的工具子集，
+// RAG 集合以 "<租户名>/" 为前缀与其他租户隔离
+type TenantConfig struct {
+	Name        string   `yaml:"name"`
+	Users       []string `yaml:"users"` // 用户身份模式，支持 * 通配，如 *@team-a.example.com
+	ToolProfile `yaml:",inline"`
+}
+
+// ToolProfile 可用工具范围，用于租户、入站 webhook 和人设
+type ToolProfile struct {
+	MCPServers []string `yaml:"mcp_servers" json:"mcp_servers,omitempty"` // 可用的 MCP 服务器名称（也可以是插件名、OpenAPI 名称、gRPC 工具服务名称，go 表示 Go 函数工具，composite 表示组合工具），为空表示全部
+	Tools      []string `yaml:"tools" json:"tools,omitempty"`             // 可用的工具名称模式，支持 * 通配，为空表示全部
+	// 不可用的工具能力（read_only、destructive、network、long_running），具有其中任一能力的工具不可用
+	DenyCapabilities []string `yaml:"deny_capabilities" json:"deny_capabilities,omitempty"`
+}
+
+// QuotaConfig 按认证后的用户身份统计用量，并执行每日、每月预算（按 UTC 自然日、自然月计算）
+type QuotaConfig struct {
+	Enabled   bool            `yaml:"enabled"`
+	Backend   string          `yaml:"backend"`   // 用量存储：memory（默认）或 redis（多副本共享）
+	Daily     QuotaLimits     `yaml:"daily"`     // 每日限额
+	Monthly   QuotaLimits     `yaml:"monthly"`   // 每月限额
+	Overrides []QuotaOverride `yaml:"overrides"` // 按用户覆盖默认限额，第一条匹配的生效
+}
+
+// QuotaLimits 限额，0 表示不限制
+type QuotaLimits struct {
+	Requests  int64 `yaml:"requests"`   // 聊天请求数
+	Tokens    int64 `yaml:"tokens"`     // 模型 token 数（输入与输出之和）
+	ToolCalls int64 `yaml:"tool_calls"` // 工具调用次数
+}
+
+// QuotaOverride 特定用户的限额，整体替换默认的 daily 和 monthly
+type QuotaOverride struct {
+	Users   []string    `yaml:"users"` // 用户身份模式，支持 * 通配
+	Daily   QuotaLimits `yaml:"daily"`
+	Monthly QuotaLimits `yaml:"monthly"`
+}
+
+// EgressConfig 出站 HTTP 请求策略，作用于网页导入等按用户输入访问外部地址的工具和加载器，以及 OpenAPI 工具
+// allowed_hosts 与 allowed_cidrs 都为空时允许任意公网地址，拒绝回环、私有、链路本地等内部地址
+type EgressConfig struct {
+	AllowedHosts     []string `yaml:"allowed_hosts"`      // 允许的主机名，支持 * 通配，如 *.example.com；匹配时不再校验地址
+	AllowedCIDRs     []string `yaml:"allowed_cidrs"`      // 允许连接的地址段或单个 IP，配置后其他地址均被拒绝
+	Schemes          []string `yaml:"schemes"`            // 允许的协议，默认 http、https
+	MaxResponseBytes int64    `yaml:"max_response_bytes"` // 响应体最大字节数，0 表示不限制
+}
+
+// AuditConfig 审计日志配置，记录工具调用和知识库写入，以哈希链防篡改
+type AuditConfig struct {
+	Path       string `yaml:"path"`        // 审计日志文件（JSON Lines），为空时不记录；多副本部署时每个副本使用各自的文件
+	SigningKey string `yaml:"signing_key"` // Ed25519 私钥文件（PKCS#8 PEM），设置后每条记录附带签名
+}
+
+// CacheConfig 模型响应缓存，相同的模型、消息和工具在 TTL 内直接返回缓存的回答
+type CacheConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Backend    string        `yaml:"backend"`     // memory（默认）或 redis（多副本共享）
+	TTL        time.Duration `yaml:"ttl"`         // 缓存有效期
+	MaxEntries int           `yaml:"max_entries"` // memory 缓存的最大条数，超出时淘汰最久未使用的
+}
+
+// ToolCacheConfig 工具结果缓存，同一对话中相同工具和参数的调用在 TTL 内直接返回之前的结果，
+// 只缓存具有 read_only 能力的工具，对话中执行其他工具后清除该对话的缓存
+type ToolCacheConfig struct {
+	MaxEntries int             `yaml:"max_entries"` // 最多缓存的结果数，超出时淘汰最久未使用的
+	Tools      []ToolCacheRule `yaml:"tools"`       // 缓存的工具，按顺序匹配第一条规则，未匹配的工具不缓存
+}
+
+// ToolCacheRule 工具结果的缓存有效期
+type ToolCacheRule struct {
+	Tool string        `yaml:"tool"` // 工具名模式，支持 * 通配
+	TTL  time.Duration `yaml:"ttl"`
+}
+
+// ImageConfig 聊天请求中附带的图片（视觉模型）
+type ImageConfig struct {
+	Backend    string        `yaml:"backend"`     // 上传图片的存储后端：memory（默认）或 redis（多副本共享）
+	TTL        time.Duration `yaml:"ttl"`         // 上传图片的保留时间，过期后不能再引用
+	MaxEntries int           `yaml:"max_entries"` // memory 后端最多保存的图片数，超出时淘汰最久未使用的
+	MaxBytes   int           `yaml:"max_bytes"`   // 单张图片的最大字节数
+	MaxImages  int           `yaml:"max_images"`  // 单条消息最多附带的图片数
+}
+
+// WorkerPoolConfig 聊天请求的并发限制，worker 全忙且队列已满时返回 503
+type WorkerPoolConfig struct {
+	Concurrency int           `yaml:"concurrency"` // 同时处理的请求数，0 表示不限制
+	QueueSize   int           `yaml:"queue_size"`  // 排队的最大请求数，默认为 concurrency 的 4 倍
+	RetryAfter  time.Duration `yaml:"retry_after"` // 503 响应中建议的重试等待时间
+	// Reserved 为交互式聊天保留的 worker 数，批量聊天和后台任务最多同时占用 concurrency - reserved 个 worker
+	Reserved int `yaml:"reserved"`
+}
+
+// BatchConfig 批量聊天接口配置
+type BatchConfig struct {
+	MaxItems    int `yaml:"max_items"`   // 单次批量请求的最大条数
+	Concurrency int `yaml:"concurrency"` // 同一批次内同时处理的条数上限，请求中可以指定更小的值
+}
+
+// TaskConfig 后台任务接口配置
+type TaskConfig struct {
+	MaxActive int             `yaml:"max_active"` // 同时进行的任务数上限
+	Timeout   time.Duration   `yaml:"timeout"`    // 单个任务的最长执行时间
+	TTL       time.Duration   `yaml:"ttl"`        // 任务结束后保留结果的时间
+	Queue     TaskQueueConfig `yaml:"queue"`
+}
+
+// TaskQueueConfig 后台任务队列配置：待执行的任务持久化保存，失败时按退避重试，重启后继续执行
+type TaskQueueConfig struct {
+	Backend     string        `yaml:"backend"`      // memory（默认，不持久化）、file 或 redis（多副本共享）
+	Dir         string        `yaml:"dir"`          // file 后端的目录
+	MaxAttempts int           `yaml:"max_attempts"` // 每个任务最多执行的次数（含首次），仍失败时转入死信
+	Backoff     time.Duration `yaml:"backoff"`      // 第一次重试前的等待时间，之后每次翻倍
+	MaxBackoff  time.Duration `yaml:"max_backoff"`  // 重试等待时间上限
+	Lease       time.Duration `yaml:"lease"`        // 执行中任务的租约，执行期间自动续期，副本崩溃后超过租约的任务重新执行
+}
+
+// WorkflowConfig 工作流配置
+type WorkflowConfig struct {
+	Dir      string `yaml:"dir"`       // 工作流定义目录（.yaml/.yml），为空时不加载
+	MaxSteps int    `yaml:"max_steps"` // 单次执行最多运行的步骤数，防止分支形成死循环
+}
+
+// CompositeToolConfig 组合工具，注册为一个普通工具，调用时依次执行 Steps。
+// 步骤的格式与工作流（pkg/workflow）相同，字符串字段可引用 .Inputs.<参数名> 和 .Steps.<步骤 ID>
+type CompositeToolConfig struct {
+	Name        string           `yaml:"name"`
+	Description string           `yaml:"description"`
+	Inputs      []CompositeInput `yaml:"inputs"` // 工具参数，均为字符串
+	Steps       []map[string]any `yaml:"steps"`
+	Output      string           `yaml:"output"` // 工具结果模板，为空时使用最后执行的步骤的输出
+}
+
+// CompositeInput 组合工具的参数
+type CompositeInput struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Required    bool   `yaml:"required"`
+	Default     string `yaml:"default"`
+}
+
+// PromptConfig 提示模板配置
+type PromptConfig struct {
+	Dir            string        `yaml:"dir"`             // 模板定义目录（.yaml/.yml），为空时不加载
+	ReloadInterval time.Duration `yaml:"reload_interval"` // 检查目录变化的最小间隔，变化后自动重新加载
+	FileRoot       string        `yaml:"file_root"`       // 模板中 file 函数可读取的根目录，为空时不能使用 file
+	MaxFileBytes   int           `yaml:"max_file_bytes"`  // file 函数读取单个文件的最大字节数，超出部分截断
+	Env            []string      `yaml:"env"`             // 模板中 env 函数可读取的环境变量，支持通配符，如 "CI_*"
+}
+
+// AnalysisConfig 仓库分析任务配置
+type AnalysisConfig struct {
+	Model         string `yaml:"model"`           // 摘要和报告使用的模型，为空时使用 ollama.model
+	TokenBudget   int    `yaml:"token_budget"`    // 读取文件内容的 token 预算（估算），请求中可以指定更小的值
+	MaxFileTokens int    `yaml:"max_file_tokens"` // 单个文件最多读取的 token 数，超出部分截断
+	MaxFiles      int    `yaml:"max_files"`       // 文件清单最多包含的文件数
+}
+
+// WebhookConfig 入站 webhook 配置：外部事件按模板生成提示，以 webhook 的身份提交为后台任务
+type WebhookConfig struct {
+	Name     string        `yaml:"name"`     // 接收地址为 /api/webhooks/<name>
+	Format   string        `yaml:"format"`   // 负载格式：alertmanager、github 或 json（默认）
+	Secret   string        `yaml:"secret"`   // github 用于校验 X-Hub-Signature-256 签名，其他格式要求 Authorization: Bearer <secret>
+	Events   []string      `yaml:"events"`   // 只处理这些事件：github 为事件类型或 事件.action，alertmanager 为告警状态（默认 firing）；为空表示全部
+	Prompt   string        `yaml:"prompt"`   // 提示模板，alertmanager 格式有默认模板
+	Model    string        `yaml:"model"`    // 为空时使用默认模型
+	RAG      bool          `yaml:"rag"`      // 按 /api/chat/rag 处理
+	User     string        `yaml:"user"`     // 执行身份，用于配额、工具策略、租户和审计，默认 webhook:<name>
+	Cooldown time.Duration `yaml:"cooldown"` // 相同提示在该时间内只处理一次，用于忽略重复通知，0 表示不去重
+	// ToolProfile 任务可用的工具范围，与租户限制同时生效
+	ToolProfile `yaml:",inline"`
+}
+
+// ToolResultConfig 大工具结果转存配置：超过阈值的结果写入临时文件，对话中只保留预览，模型通过 read_more 工具分段读取
+type ToolResultConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	Threshold    int           `yaml:"threshold"`     // 超过该字节数的结果转存，同时也是 read_more 单次读取的上限
+	PreviewBytes int           `yaml:"preview_bytes"` // 对话中保留的开头字节数
+	Dir          string        `yaml:"dir"`           // 转存目录，默认为系统临时目录
+	TTL          time.Duration `yaml:"ttl"`           // 转存结果的有效期
+}
+
+// NotesConfig 对话笔记：模型通过 save_note 把中间结果（文件清单、阶段性分析）保存在上下文之外，
+// 之后在同一对话或同一用户的后续对话中通过 read_notes 读取
+type NotesConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Backend    string        `yaml:"backend"`     // 存储后端：memory（默认）或 redis（多副本共享、重启后保留）
+	TTL        time.Duration `yaml:"ttl"`         // 笔记的保留时间，每次保存时刷新
+	MaxEntries int           `yaml:"max_entries"` // memory 后端最多保存笔记的对话数，超出时淘汰最久未使用的
+	MaxNotes   int           `yaml:"max_notes"`   // 单个对话最多保存的笔记数
+	MaxBytes   int           `yaml:"max_bytes"`   // 单条笔记的最大字节数
+}
+
+// KnowledgeGraphConfig 知识图谱：模型从对话和导入的文档中抽取实体和关系保存到 SQLite，
+// 通过 query_knowledge_graph 工具查询，RAG 聊天时用问题中实体的多跳关系补充向量检索
+type KnowledgeGraphConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Path      string `yaml:"path"`       // SQLite 数据库文件
+	Model     string `yaml:"model"`      // 抽取使用的模型，为空时使用默认模型
+	Workers   int    `yaml:"workers"`    // 并发执行抽取的数量
+	QueueSize int    `yaml:"queue_size"` // 等待抽取的对话轮次和文档数，队列已满时丢弃
+	MaxInput  int    `yaml:"max_input"`  // 单次抽取的最大字符数，更长的文档分段抽取
+	Hops      int    `yaml:"hops"`       // 检索时从问题中的实体出发的最大跳数
+	MaxFacts  int    `yaml:"max_facts"`  // 检索和查询返回的最多关系数
+	// SkipConversations 不从对话中抽取，SkipDocuments 不从导入的文档中抽取
+	SkipConversations bool `yaml:"skip_conversations"`
+	SkipDocuments     bool `yaml:"skip_documents"`
+}
+
+// ProfileConfig 用户资料：模型从对话中学习用户的偏好、常用项目和环境信息，新对话开始时注入系统提示
+type ProfileConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Backend    string        `yaml:"backend"`     // 存储后端：memory（默认）或 redis（多副本共享、重启后保留）
+	TTL        time.Duration `yaml:"ttl"`         // 资料的保留时间，每次更新时刷新
+	MaxEntries int           `yaml:"max_entries"` // memory 后端最多保存资料的用户数
+	MaxItems   int           `yaml:"max_items"`   // 每类（偏好、项目、环境）最多保存的条目数
+	Model      string        `yaml:"model"`       // 学习使用的模型，为空时使用默认模型
+	QueueSize  int           `yaml:"queue_size"`  // 等待学习的对话轮次，队列已满时丢弃
+	// SkipLearning 不从对话中学习，资料只能通过 API 编辑
+	SkipLearning bool `yaml:"skip_learning"`
+}
+
+// ToolHintsConfig 工具的使用说明和调用示例，帮助小模型选择工具、构造参数
+type ToolHintsConfig struct {
+	Placement string           `yaml:"placement"` // description（默认，追加到工具描述）或 system（汇总为系统消息）
+	Tools     []ToolHintConfig `yaml:"tools"`     // 覆盖 MCP 工具 _meta 中提供的说明和示例
+}
+
+// ToolHintConfig 单个工具的使用说明和调用示例
+type ToolHintConfig struct {
+	Tool     string              `yaml:"tool"`
+	Usage    string              `yaml:"usage"`    // 何时使用、参数如何填写等说明
+	Examples []ToolExampleConfig `yaml:"examples"` // 调用示例（few-shot）
+}
+
+// ToolExampleConfig 工具调用示例
+type ToolExampleConfig struct {
+	Request   string         `yaml:"request" json:"request"`     // 用户的提问
+	Arguments map[string]any `yaml:"arguments" json:"arguments"` // 对应的调用参数
+}
+
+// ExperimentConfig 提示 A/B 实验：按比例把使用某个模板（或未指定模板）的请求分配到替代的系统提示或模板，
+// 未分配到任何变体的请求为对照组 control
+type ExperimentConfig struct {
+	Name     string          `yaml:"name"`
+	Template string          `yaml:"template"` // 参与实验的请求指定的模板，为空时为未指定模板的请求
+	Variants []VariantConfig `yaml:"variants"`
+}
+
+// VariantConfig 实验变体，System 和 Template 至少指定一个
+type VariantConfig struct {
+	Name     string  `yaml:"name"`
+	Fraction float64 `yaml:"fraction"` // 分配到该变体的请求比例（0-1），所有变体之和不超过 1
+	System   string  `yaml:"system"`   // 替代的系统提示，覆盖模板定义的 system
+	Template string  `yaml:"template"` // 替代的模板，以原请求的变量渲染
+}
+
+// PersonaConfig 命名人设：系统提示、模型、温度和可用工具的组合。聊天请求以 persona 选择，
+// 选择记录在对话中，同一对话的后续请求沿用
+type PersonaConfig struct {
+	Name         string   `yaml:"name" json:"name"`
+	Description  string   `yaml:"description" json:"description,omitempty"`
+	SystemPrompt string   `yaml:"system_prompt" json:"system_prompt,omitempty"`
+	Model        string   `yaml:"model" json:"model,omitempty"`             // 为空时使用 ollama.model，请求中指定的模型优先
+	Temperature  *float64 `yaml:"temperature" json:"temperature,omitempty"` // 为空时使用模型的默认值
+	Validators   []string `yaml:"validators" json:"validators,omitempty"`   // 使用该人设时生效的回答校验规则
+	ToolProfile  `yaml:",inline"`
+}
+
+// validate 校验工具名模式和能力标记
+func (p *ToolProfile) validate() error {
+	for _, pattern := range p.Tools {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid tool pattern %q", pattern)
+		}
+	}
+	for _, name := range p.DenyCapabilities {
+		if !ValidCapability(name) {
+			return fmt.Errorf("unknown tool capability %q", name)
+		}
+	}
+	return nil
 }
 
 // Load 从文件加载配置
@@ -87,21 +903,48 @@ func (c *Config) setDefaults() {
 	if c.Server.Listen == "" {
 		c.Server.Listen = "localhost:8080"
 	}
+	if c.Server.Auth.UserClaim == "" {
+		c.Server.Auth.UserClaim = "sub"
+	}
 
+	if c.Ollama.Host == "" && len(c.Ollama.Hosts) > 0 {
+		c.Ollama.Host = c.Ollama.Hosts[0]
+	}
 	if c.Ollama.Host == "" {
 		c.Ollama.Host = "http://localhost:11434"
 	}
+	if len(c.Ollama.Hosts) == 0 {
+		c.Ollama.Hosts = []string{c.Ollama.Host}
+	}
+	if c.Ollama.Routing == "" {
+		c.Ollama.Routing = "least_loaded"
+	}
+	if c.Ollama.HealthInterval == 0 {
+		c.Ollama.HealthInterval = 30 * time.Second
+	}
 	if c.Ollama.Model == "" {
 		c.Ollama.Model = "qwen3-coder:480b-cloud"
 	}
 	if c.Ollama.Timeout == 0 {
 		c.Ollama.Timeout = 120 * time.Second
 	}
+	if c.Ollama.StreamIdleTimeout == 0 {
+		c.Ollama.StreamIdleTimeout = 60 * time.Second
+	}
+	if c.Ollama.ConnectTimeout == 0 {
+		c.Ollama.ConnectTimeout = 10 * time.Second
+	}
+	if c.Ollama.MaxIdleConns == 0 {
+		c.Ollama.MaxIdleConns = 16
+	}
+	if c.Ollama.IdleConnTimeout == 0 {
+		c.Ollama.IdleConnTimeout = 90 * time.Second
+	}
 	if c.Ollama.MaxRetries == 0 {
 		c.Ollama.MaxRetries = 3
 	}
-	if c.Ollama.SystemPrompt == "" {
-		c.Ollama.SystemPrompt = defaultSystemPrompt
+	if c.Ollama.Language == "" {
+		c.Ollama.Language = "zh"
 	}
 
 	// RAG 默认值
@@ -117,9 +960,408 @@ func (c *Config) setDefaults() {
 	if c.RAG.TopK == 0 {
 		c.RAG.TopK = 3
 	}
+	if c.RAG.Backend == "" {
+		c.RAG.Backend = "memory"
+	}
 	if c.RAG.DocumentsDir == "" {
 		c.RAG.DocumentsDir = "docs/rag"
 	}
+	if c.RAG.IngestJobs == 0 {
+		c.RAG.IngestJobs = 2
+	}
+	if c.RAG.Embedding.Provider == "" {
+		c.RAG.Embedding.Provider = "ollama"
+	}
+	if c.RAG.Embedding.Timeout == 0 {
+		c.RAG.Embedding.Timeout = 30 * time.Second
+	}
+	if c.RAG.GitDir == "" {
+		c.RAG.GitDir = "data/git"
+	}
+	for i := range c.RAG.GitSources {
+		src := &c.RAG.GitSources[i]
+		if src.Collection == "" {
+			src.Collection = src.Name
+		}
+		if len(src.Extensions) == 0 {
+			src.Extensions = []string{".md", ".txt"}
+		}
+		if src.Interval == 0 {
+			src.Interval = time.Hour
+		}
+		if src.Username == "" {
+			src.Username = "git"
+		}
+	}
+
+	// MCP 服务器默认值
+	for i := range c.MCPServers {
+		if c.MCPServers[i].ShutdownGrace == 0 {
+			c.MCPServers[i].ShutdownGrace = 5 * time.Second
+		}
+	}
+
+	// 对话存储默认值
+	if c.Conversation.Store == "" {
+		c.Conversation.Store = "memory"
+	}
+	if c.Conversation.Dir == "" {
+		c.Conversation.Dir = "data/conversations"
+	}
+	if c.Conversation.Concurrency == "" {
+		c.Conversation.Concurrency = "queue"
+	}
+	if c.Conversation.QueueTimeout == 0 {
+		c.Conversation.QueueTimeout = 2 * time.Minute
+	}
+	if c.StructuredOutput.MaxRetries == 0 {
+		c.StructuredOutput.MaxRetries = 2
+	}
+	if c.Plugins.StartTimeout == 0 {
+		c.Plugins.StartTimeout = 10 * time.Second
+	}
+	if c.Plugins.CallTimeout == 0 {
+		c.Plugins.CallTimeout = time.Minute
+	}
+	if c.Plugins.ShutdownGrace == 0 {
+		c.Plugins.ShutdownGrace = 5 * time.Second
+	}
+	for i := range c.OpenAPI {
+		if c.OpenAPI[i].Timeout == 0 {
+			c.OpenAPI[i].Timeout = 30 * time.Second
+		}
+	}
+	for i := range c.GRPCTools {
+		if c.GRPCTools[i].DialTimeout == 0 {
+			c.GRPCTools[i].DialTimeout = 10 * time.Second
+		}
+		if c.GRPCTools[i].CallTimeout == 0 {
+			c.GRPCTools[i].CallTimeout = 60 * time.Second
+		}
+	}
+	if c.OutputValidation.MaxRetries == 0 {
+		c.OutputValidation.MaxRetries = 2
+	}
+	if c.OutputValidation.OnFailure == "" {
+		c.OutputValidation.OnFailure = "error"
+	}
+
+	// 主节点选举默认值
+	if c.Leader.Backend == "" {
+		c.Leader.Backend = "file"
+	}
+	if c.Leader.LeaseName == "" {
+		c.Leader.LeaseName = "ai-agent-leader"
+	}
+	if c.Leader.LockPath == "" {
+		c.Leader.LockPath = "data/leader.lock"
+	}
+	if c.Leader.LeaseDuration == 0 {
+		c.Leader.LeaseDuration = 15 * time.Second
+	}
+	if c.Leader.RenewDeadline == 0 {
+		c.Leader.RenewDeadline = 10 * time.Second
+	}
+	if c.Leader.RetryPeriod == 0 {
+		c.Leader.RetryPeriod = 2 * time.Second
+	}
+
+	// 事件监听默认值
+	if len(c.Watcher.Reasons) == 0 {
+		c.Watcher.Reasons = []string{"BackOff", "Failed", "OOMKilling", "Unhealthy", "FailedScheduling", "FailedMount", "Evicted"}
+	}
+	if c.Watcher.Cooldown == 0 {
+		c.Watcher.Cooldown = 30 * time.Minute
+	}
+	if c.Watcher.Workers == 0 {
+		c.Watcher.Workers = 1
+	}
+	if c.Watcher.QueueSize == 0 {
+		c.Watcher.QueueSize = 100
+	}
+	if c.Watcher.Prompt == "" {
+		c.Watcher.Prompt = defaultWatcherPrompt
+	}
+
+	// Redis 默认值
+	if c.Redis.Addr == "" {
+		c.Redis.Addr = "localhost:6379"
+	}
+	if c.Redis.Prefix == "" {
+		c.Redis.Prefix = "ai-agent"
+	}
+
+	// 用量配额默认值
+	if c.Quota.Backend == "" {
+		c.Quota.Backend = "memory"
+	}
+
+	// 响应缓存默认值
+	if c.Cache.Backend == "" {
+		c.Cache.Backend = "memory"
+	}
+	if c.Cache.TTL == 0 {
+		c.Cache.TTL = 10 * time.Minute
+	}
+	if c.Cache.MaxEntries == 0 {
+		c.Cache.MaxEntries = 1000
+	}
+	if c.ToolCache.MaxEntries == 0 {
+		c.ToolCache.MaxEntries = 1000
+	}
+	if c.Escalation.MinScore == 0 {
+		c.Escalation.MinScore = 7
+	}
+	if c.Images.Backend == "" {
+		c.Images.Backend = "memory"
+	}
+	if c.Images.TTL == 0 {
+		c.Images.TTL = time.Hour
+	}
+	if c.Images.MaxEntries == 0 {
+		c.Images.MaxEntries = 100
+	}
+	if c.Images.MaxBytes == 0 {
+		c.Images.MaxBytes = 10 << 20
+	}
+	if c.Images.MaxImages == 0 {
+		c.Images.MaxImages = 4
+	}
+
+	// 并发限制默认值
+	if c.Workers.QueueSize == 0 {
+		c.Workers.QueueSize = 4 * c.Workers.Concurrency
+	}
+	if c.Workers.RetryAfter == 0 {
+		c.Workers.RetryAfter = 5 * time.Second
+	}
+
+	// 批量聊天默认值
+	if c.Batch.MaxItems == 0 {
+		c.Batch.MaxItems = 100
+	}
+	if c.Batch.Concurrency == 0 {
+		c.Batch.Concurrency = 4
+	}
+
+	// 后台任务默认值
+	if c.Tasks.MaxActive == 0 {
+		c.Tasks.MaxActive = 16
+	}
+	if c.Tasks.Timeout == 0 {
+		c.Tasks.Timeout = 30 * time.Minute
+	}
+	if c.Tasks.TTL == 0 {
+		c.Tasks.TTL = time.Hour
+	}
+	if c.Tasks.Queue.Backend == "" {
+		c.Tasks.Queue.Backend = "memory"
+	}
+	if c.Tasks.Queue.Dir == "" {
+		c.Tasks.Queue.Dir = "data/tasks"
+	}
+	if c.Tasks.Queue.MaxAttempts == 0 {
+		c.Tasks.Queue.MaxAttempts = 3
+	}
+	if c.Tasks.Queue.Backoff == 0 {
+		c.Tasks.Queue.Backoff = 10 * time.Second
+	}
+	if c.Tasks.Queue.MaxBackoff == 0 {
+		c.Tasks.Queue.MaxBackoff = 10 * time.Minute
+	}
+	if c.Tasks.Queue.Lease == 0 {
+		c.Tasks.Queue.Lease = time.Minute
+	}
+
+	// 工作流默认值
+	if c.Workflows.MaxSteps == 0 {
+		c.Workflows.MaxSteps = 100
+	}
+
+	// 提示模板默认值
+	if c.Prompts.ReloadInterval == 0 {
+		c.Prompts.ReloadInterval = 5 * time.Second
+	}
+	if c.Prompts.MaxFileBytes == 0 {
+		c.Prompts.MaxFileBytes = 64 * 1024
+	}
+
+	// 仓库分析默认值
+	if c.Analysis.TokenBudget == 0 {
+		c.Analysis.TokenBudget = 24000
+	}
+	if c.Analysis.MaxFileTokens == 0 {
+		c.Analysis.MaxFileTokens = 2000
+	}
+	if c.Analysis.MaxFiles == 0 {
+		c.Analysis.MaxFiles = 5000
+	}
+
+	// 入站 webhook 默认值
+	for i := range c.Webhooks {
+		hook := &c.Webhooks[i]
+		if hook.Format == "" {
+			hook.Format = "json"
+		}
+		if hook.User == "" {
+			hook.User = "webhook:" + hook.Name
+		}
+		if hook.Format == "alertmanager" {
+			if hook.Prompt == "" {
+				hook.Prompt = defaultAlertmanagerPrompt
+			}
+			if len(hook.Events) == 0 {
+				hook.Events = []string{"firing"}
+			}
+		}
+	}
+
+	// 大工具结果转存默认值
+	if c.ToolResults.Threshold == 0 {
+		c.ToolResults.Threshold = 32 * 1024
+	}
+	if c.ToolResults.PreviewBytes == 0 {
+		c.ToolResults.PreviewBytes = 4 * 1024
+	}
+	if c.ToolResults.TTL == 0 {
+		c.ToolResults.TTL = time.Hour
+	}
+	if c.Connectors.Backend == "" {
+		c.Connectors.Backend = "memory"
+	}
+	if c.Slack.Command == "" {
+		c.Slack.Command = "/agent"
+	}
+	c.Slack.setDefaults("slack")
+	c.Telegram.setDefaults("telegram")
+	if c.Email.IMAP.TLS == "" {
+		c.Email.IMAP.TLS = "tls"
+	}
+	if c.Email.SMTP.TLS == "" {
+		c.Email.SMTP.TLS = "tls"
+	}
+	if c.Email.Address == "" {
+		c.Email.Address = c.Email.Username
+	}
+	if c.Email.Mailbox == "" {
+		c.Email.Mailbox = "INBOX"
+	}
+	if c.Email.PollInterval == 0 {
+		c.Email.PollInterval = 30 * time.Second
+	}
+	if c.Email.Uploads == "" {
+		c.Email.Uploads = "rag"
+	}
+	c.Email.setDefaults("email")
+	if c.A2A.Description == "" {
+		c.A2A.Description = "Operations assistant that answers questions and runs tools such as Kubernetes, Prometheus and file operations."
+	}
+	if len(c.A2A.Skills) == 0 {
+		c.A2A.Skills = []A2ASkillConfig{{
+			ID:          "ops-assistant",
+			Name:        "Operations assistant",
+			Description: "Diagnose and answer operations questions using the configured tools and knowledge base.",
+			Tags:        []string{"operations", "kubernetes", "troubleshooting"},
+		}}
+	}
+	if c.GitHub.APIURL == "" {
+		c.GitHub.APIURL = "https://api.github.com"
+	}
+	if len(c.GitHub.Actions) == 0 {
+		c.GitHub.Actions = []string{"opened", "reopened", "ready_for_review"}
+	}
+	if c.GitHub.MaxFiles == 0 {
+		c.GitHub.MaxFiles = 100
+	}
+	if c.GitHub.MaxDiffBytes == 0 {
+		c.GitHub.MaxDiffBytes = 64 << 10
+	}
+	if c.GitHub.MaxComments == 0 {
+		c.GitHub.MaxComments = 20
+	}
+	if c.GitHub.User == "" {
+		c.GitHub.User = "github"
+	}
+	if c.Notes.Backend == "" {
+		c.Notes.Backend = "memory"
+	}
+	if c.Notes.TTL == 0 {
+		c.Notes.TTL = 7 * 24 * time.Hour
+	}
+	if c.Notes.MaxEntries == 0 {
+		c.Notes.MaxEntries = 1000
+	}
+	if c.Notes.MaxNotes == 0 {
+		c.Notes.MaxNotes = 50
+	}
+	if c.Notes.MaxBytes == 0 {
+		c.Notes.MaxBytes = 16 * 1024
+	}
+
+	// 知识图谱默认值
+	if c.KnowledgeGraph.Path == "" {
+		c.KnowledgeGraph.Path = "data/knowledge.db"
+	}
+	if c.KnowledgeGraph.Workers == 0 {
+		c.KnowledgeGraph.Workers = 1
+	}
+	if c.KnowledgeGraph.QueueSize == 0 {
+		c.KnowledgeGraph.QueueSize = 100
+	}
+	if c.KnowledgeGraph.MaxInput == 0 {
+		c.KnowledgeGraph.MaxInput = 4000
+	}
+	if c.KnowledgeGraph.Hops == 0 {
+		c.KnowledgeGraph.Hops = 2
+	}
+	if c.KnowledgeGraph.MaxFacts == 0 {
+		c.KnowledgeGraph.MaxFacts = 30
+	}
+
+	// 用户资料默认值
+	if c.Profiles.Backend == "" {
+		c.Profiles.Backend = "memory"
+	}
+	if c.Profiles.TTL == 0 {
+		c.Profiles.TTL = 90 * 24 * time.Hour
+	}
+	if c.Profiles.MaxEntries == 0 {
+		c.Profiles.MaxEntries = 1000
+	}
+	if c.Profiles.MaxItems == 0 {
+		c.Profiles.MaxItems = 10
+	}
+	if c.Profiles.QueueSize == 0 {
+		c.Profiles.QueueSize = 100
+	}
+
+	// 工具说明默认值
+	if c.ToolHints.Placement == "" {
+		c.ToolHints.Placement = "description"
+	}
+
+	// 出站策略默认值
+	if c.Egress.MaxResponseBytes == 0 {
+		c.Egress.MaxResponseBytes = 10 << 20
+	}
+
+	// 工具策略默认值
+	if c.Policy.Default == "" {
+		c.Policy.Default = "allow"
+	}
+
+	// 密钥默认值
+	if c.Secrets.RefreshInterval == 0 {
+		c.Secrets.RefreshInterval = 5 * time.Minute
+	}
+	if c.Secrets.Vault.KubernetesMount == "" {
+		c.Secrets.Vault.KubernetesMount = "kubernetes"
+	}
+
+	// 声明式配置默认值
+	if c.Operator.AgentName == "" {
+		c.Operator.AgentName = "ai-agent"
+	}
 }
 
 // validate 验证配置
@@ -131,15 +1373,471 @@ func (c *Config) validate() error {
 	if c.Ollama.Model == "" {
 		return fmt.Errorf("ollama model is required")
 	}
+	switch c.Ollama.Routing {
+	case "least_loaded", "round_robin":
+	default:
+		return fmt.Errorf("unknown ollama routing: %s", c.Ollama.Routing)
+	}
+	lang, err := locale.Normalize(c.Ollama.Language)
+	if err != nil {
+		return fmt.Errorf("ollama.language: %w", err)
+	}
+	c.Ollama.Language = lang
+	if c.Ollama.MaxConcurrent < 0 {
+		return fmt.Errorf("ollama max_concurrent must not be negative")
+	}
+
+	// 验证存储后端
+	switch c.RAG.Backend {
+	case "memory", "redis":
+	default:
+		return fmt.Errorf("unknown rag backend: %s", c.RAG.Backend)
+	}
+	switch c.RAG.Embedding.Provider {
+	case "ollama":
+	case "openai":
+		if c.RAG.Embedding.Host == "" {
+			return fmt.Errorf("rag.embedding.host is required for openai provider")
+		}
+	default:
+		return fmt.Errorf("unknown rag embedding provider: %s", c.RAG.Embedding.Provider)
+	}
+	gitSources := make(map[string]bool, len(c.RAG.GitSources))
+	for _, src := range c.RAG.GitSources {
+		if src.Name == "" || strings.ContainsAny(src.Name, `/\`) || strings.HasPrefix(src.Name, ".") {
+			return fmt.Errorf("invalid rag git source name %q", src.Name)
+		}
+		if gitSources[src.Name] {
+			return fmt.Errorf("duplicate rag git source %s", src.Name)
+		}
+		gitSources[src.Name] = true
+		if src.URL == "" {
+			return fmt.Errorf("rag git source %s: url is required", src.Name)
+		}
+		if src.Interval < 0 {
+			return fmt.Errorf("rag git source %s: interval must not be negative", src.Name)
+		}
+		for _, p := range src.Paths {
+			if p == "" || path.IsAbs(p) || strings.HasPrefix(path.Clean(p), "..") {
+				return fmt.Errorf("rag git source %s: invalid path %q", src.Name, p)
+			}
+		}
+	}
+
+	switch c.Quota.Backend {
+	case "memory", "redis":
+	default:
+		return fmt.Errorf("unknown quota backend: %s", c.Quota.Backend)
+	}
+
+	switch c.Images.Backend {
+	case "memory", "redis":
+	default:
+		return fmt.Errorf("unknown images backend: %s", c.Images.Backend)
+	}
+
+	switch c.Cache.Backend {
+	case "memory", "redis":
+	default:
+		return fmt.Errorf("unknown cache backend: %s", c.Cache.Backend)
+	}
+	for _, rule := range c.ToolCache.Tools {
+		if _, err := path.Match(rule.Tool, ""); err != nil || rule.Tool == "" {
+			return fmt.Errorf("tool_cache: invalid tool pattern %q", rule.Tool)
+		}
+		if rule.TTL <= 0 {
+			return fmt.Errorf("tool_cache: ttl for %s must be positive", rule.Tool)
+		}
+	}
+
+	// 验证并发限制
+	if c.Workers.Reserved < 0 || (c.Workers.Concurrency > 0 && c.Workers.Reserved >= c.Workers.Concurrency) {
+		return fmt.Errorf("workers reserved must be between 0 and concurrency - 1")
+	}
+
+	switch c.Tasks.Queue.Backend {
+	case "memory", "file", "redis":
+	default:
+		return fmt.Errorf("unknown task queue backend: %s", c.Tasks.Queue.Backend)
+	}
+
+	if c.ToolResults.Enabled && c.ToolResults.PreviewBytes >= c.ToolResults.Threshold {
+		return fmt.Errorf("tool_results.preview_bytes must be smaller than tool_results.threshold")
+	}
+	switch c.Connectors.Backend {
+	case "memory", "redis":
+	default:
+		return fmt.Errorf("unknown connectors backend: %s", c.Connectors.Backend)
+	}
+	if c.Slack.Enabled {
+		if c.Slack.AppToken == "" || c.Slack.BotToken == "" {
+			return fmt.Errorf("slack.app_token and slack.bot_token are required")
+		}
+		if !strings.HasPrefix(c.Slack.Command, "/") {
+			return fmt.Errorf("slack.command must start with /")
+		}
+	}
+	if err := c.Slack.validate("slack"); err != nil {
+		return err
+	}
+	if c.Telegram.Enabled && c.Telegram.Token == "" {
+		return fmt.Errorf("telegram.token is required")
+	}
+	if err := c.Telegram.validate("telegram"); err != nil {
+		return err
+	}
+	if c.GitHub.Enabled {
+		if c.GitHub.WebhookSecret == "" {
+			return fmt.Errorf("github.webhook_secret is required")
+		}
+		if (c.GitHub.Token == "") == (c.GitHub.AppID == 0) {
+			return fmt.Errorf("exactly one of github.token and github.app_id is required")
+		}
+		if c.GitHub.AppID != 0 && c.GitHub.PrivateKey == "" {
+			return fmt.Errorf("github.private_key is required for github.app_id")
+		}
+	}
+	for _, repo := range c.GitHub.Repositories {
+		owner, name, ok := strings.Cut(repo, "/")
+		if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("invalid github repository %q, expected owner/repo", repo)
+		}
+	}
+	for _, pattern := range c.GitHub.Paths {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid github path pattern %q: %w", pattern, err)
+		}
+	}
+	if err := c.GitHub.ToolProfile.validate(); err != nil {
+		return fmt.Errorf("github: %w", err)
+	}
+	if c.Email.Enabled {
+		if c.Email.IMAP.Addr == "" || c.Email.SMTP.Addr == "" {
+			return fmt.Errorf("email.imap.addr and email.smtp.addr are required")
+		}
+		if c.Email.Username == "" || c.Email.Password == "" {
+			return fmt.Errorf("email.username and email.password are required")
+		}
+		if _, err := mail.ParseAddress(c.Email.Address); err != nil {
+			return fmt.Errorf("invalid email.address %q: %w", c.Email.Address, err)
+		}
+	}
+	for _, server := range []struct{ name, tls string }{{"imap", c.Email.IMAP.TLS}, {"smtp", c.Email.SMTP.TLS}} {
+		switch server.tls {
+		case "tls", "starttls", "none":
+		default:
+			return fmt.Errorf("unknown email.%s.tls mode: %s", server.name, server.tls)
+		}
+	}
+	for _, sender := range c.Email.AllowedSenders {
+		if !strings.Contains(sender, "@") {
+			return fmt.Errorf("invalid email allowed sender %q, expected an address or @domain", sender)
+		}
+	}
+	if err := c.Email.validate("email"); err != nil {
+		return err
+	}
+	if c.A2A.URL != "" {
+		if u, err := url.Parse(c.A2A.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid a2a.url %q, expected an absolute http(s) URL", c.A2A.URL)
+		}
+	}
+	skills := make(map[string]bool, len(c.A2A.Skills))
+	for _, skill := range c.A2A.Skills {
+		if skill.ID == "" || skill.Name == "" {
+			return fmt.Errorf("a2a skill id and name are required")
+		}
+		if skills[skill.ID] {
+			return fmt.Errorf("duplicate a2a skill %s", skill.ID)
+		}
+		skills[skill.ID] = true
+	}
+	if kg := c.KnowledgeGraph; kg.Enabled && (kg.Workers < 0 || kg.QueueSize < 0 || kg.MaxInput < 0 || kg.Hops < 0 || kg.MaxFacts < 0) {
+		return fmt.Errorf("knowledge_graph workers, queue_size, max_input, hops and max_facts must not be negative")
+	}
+	switch c.Notes.Backend {
+	case "memory", "redis":
+	default:
+		return fmt.Errorf("unknown notes backend: %s", c.Notes.Backend)
+	}
+
+	switch c.Profiles.Backend {
+	case "memory", "redis":
+	default:
+		return fmt.Errorf("unknown profile backend: %s", c.Profiles.Backend)
+	}
+
+	switch c.Conversation.Concurrency {
+	case "queue", "reject":
+	default:
+		return fmt.Errorf("unknown conversation concurrency mode: %s", c.Conversation.Concurrency)
+	}
+
+	// 验证租户配置
+	tenants := make(map[string]bool, len(c.Tenants))
+	for _, t := range c.Tenants {
+		if t.Name == "" || strings.ContainsAny(t.Name, "/*") {
+			return fmt.Errorf("invalid tenant name %q", t.Name)
+		}
+		if tenants[t.Name] {
+			return fmt.Errorf("duplicate tenant %s", t.Name)
+		}
+		tenants[t.Name] = true
+		if err := t.ToolProfile.validate(); err != nil {
+			return fmt.Errorf("tenant %s: %w", t.Name, err)
+		}
+	}
+
+	// 验证入站 webhook 配置
+	webhooks := make(map[string]bool, len(c.Webhooks))
+	for _, hook := range c.Webhooks {
+		if hook.Name == "" || strings.Contains(hook.Name, "/") {
+			return fmt.Errorf("invalid webhook name %q", hook.Name)
+		}
+		if webhooks[hook.Name] {
+			return fmt.Errorf("duplicate webhook %s", hook.Name)
+		}
+		webhooks[hook.Name] = true
+		switch hook.Format {
+		case "alertmanager", "github", "json":
+		default:
+			return fmt.Errorf("webhook %s: unknown format: %s", hook.Name, hook.Format)
+		}
+		if hook.Secret == "" {
+			return fmt.Errorf("webhook %s: secret is required", hook.Name)
+		}
+		if hook.Prompt == "" {
+			return fmt.Errorf("webhook %s: prompt is required", hook.Name)
+		}
+		if err := hook.ToolProfile.validate(); err != nil {
+			return fmt.Errorf("webhook %s: %w", hook.Name, err)
+		}
+		if len(c.Tenants) > 0 && !slices.ContainsFunc(c.Tenants, func(t TenantConfig) bool {
+			return slices.ContainsFunc(t.Users, func(pattern string) bool {
+				ok, _ := path.Match(pattern, hook.User)
+				return ok
+			})
+		}) {
+			return fmt.Errorf("webhook %s: user %s does not belong to any tenant", hook.Name, hook.User)
+		}
+	}
+
+	// 验证提示模板配置
+	if c.Prompts.MaxFileBytes < 0 {
+		return fmt.Errorf("prompts max_file_bytes must not be negative")
+	}
+	for _, pattern := range c.Prompts.Env {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("prompts env: invalid pattern %q", pattern)
+		}
+	}
+
+	// 验证工具说明配置
+	switch c.ToolHints.Placement {
+	case "description", "system":
+	default:
+		return fmt.Errorf("unknown tool_hints placement: %s", c.ToolHints.Placement)
+	}
+	hinted := make(map[string]bool, len(c.ToolHints.Tools))
+	for _, hint := range c.ToolHints.Tools {
+		if hint.Tool == "" {
+			return fmt.Errorf("tool_hints: tool name is required")
+		}
+		if hinted[hint.Tool] {
+			return fmt.Errorf("tool_hints: duplicate tool %s", hint.Tool)
+		}
+		hinted[hint.Tool] = true
+		for i, ex := range hint.Examples {
+			if ex.Request == "" {
+				return fmt.Errorf("tool_hints: %s: example %d: request is required", hint.Tool, i)
+			}
+		}
+	}
+
+	// 验证提示实验配置
+	experiments := make(map[string]bool, len(c.Experiments))
+	scopes := make(map[string]bool, len(c.Experiments))
+	for _, exp := range c.Experiments {
+		if exp.Name == "" {
+			return fmt.Errorf("experiment name is required")
+		}
+		if experiments[exp.Name] {
+			return fmt.Errorf("duplicate experiment name: %s", exp.Name)
+		}
+		experiments[exp.Name] = true
+		if scopes[exp.Template] {
+			return fmt.Errorf("experiment %s: template %q is already in another experiment", exp.Name, exp.Template)
+		}
+		scopes[exp.Template] = true
+		if len(exp.Variants) == 0 {
+			return fmt.Errorf("experiment %s: at least one variant is required", exp.Name)
+		}
+		variants := map[string]bool{"control": true}
+		var total float64
+		for _, v := range exp.Variants {
+			if v.Name == "" || variants[v.Name] {
+				return fmt.Errorf("experiment %s: invalid or duplicate variant name %q", exp.Name, v.Name)
+			}
+			variants[v.Name] = true
+			if v.Fraction <= 0 || v.Fraction > 1 {
+				return fmt.Errorf("experiment %s: variant %s: fraction must be in (0, 1]", exp.Name, v.Name)
+			}
+			if v.System == "" && v.Template == "" {
+				return fmt.Errorf("experiment %s: variant %s: system or template is required", exp.Name, v.Name)
+			}
+			total += v.Fraction
+		}
+		if total > 1+1e-9 {
+			return fmt.Errorf("experiment %s: variant fractions sum to more than 1", exp.Name)
+		}
+	}
+
+	// 验证回答校验配置
+	if c.OutputValidation.MaxRetries < 0 {
+		return fmt.Errorf("output_validation.max_retries must not be negative")
+	}
+	switch c.OutputValidation.OnFailure {
+	case "error", "accept":
+	default:
+		return fmt.Errorf("unknown output_validation.on_failure: %s", c.OutputValidation.OnFailure)
+	}
+	validators := make(map[string]bool, len(c.OutputValidation.Validators))
+	for _, v := range c.OutputValidation.Validators {
+		if v.Name == "" {
+			return fmt.Errorf("output validator name is required")
+		}
+		if validators[v.Name] {
+			return fmt.Errorf("duplicate output validator name: %s", v.Name)
+		}
+		validators[v.Name] = true
+	}
+
+	// 验证人设配置
+	personas := make(map[string]bool, len(c.Personas))
+	for _, p := range c.Personas {
+		if p.Name == "" {
+			return fmt.Errorf("persona name is required")
+		}
+		if personas[p.Name] {
+			return fmt.Errorf("duplicate persona name: %s", p.Name)
+		}
+		personas[p.Name] = true
+		if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+			return fmt.Errorf("persona %s: temperature must be between 0 and 2", p.Name)
+		}
+		if err := p.ToolProfile.validate(); err != nil {
+			return fmt.Errorf("persona %s: %w", p.Name, err)
+		}
+		for _, name := range p.Validators {
+			if !validators[name] {
+				return fmt.Errorf("persona %s: unknown output validator %s", p.Name, name)
+			}
+		}
+	}
+
+	if c.GitHub.Persona != "" && !personas[c.GitHub.Persona] {
+		return fmt.Errorf("github: unknown persona %s", c.GitHub.Persona)
+	}
+	if c.A2A.Persona != "" && !personas[c.A2A.Persona] {
+		return fmt.Errorf("a2a: unknown persona %s", c.A2A.Persona)
+	}
+	if c.MCPEndpoint.Persona != "" && !personas[c.MCPEndpoint.Persona] {
+		return fmt.Errorf("mcp_endpoint: unknown persona %s", c.MCPEndpoint.Persona)
+	}
+
+	// 验证 OpenAPI 配置
+	apis := make(map[string]bool, len(c.OpenAPI))
+	for _, api := range c.OpenAPI {
+		if api.Name == "" {
+			return fmt.Errorf("openapi name is required")
+		}
+		if apis[api.Name] {
+			return fmt.Errorf("duplicate openapi name: %s", api.Name)
+		}
+		apis[api.Name] = true
+		if api.Spec == "" {
+			return fmt.Errorf("openapi %s: spec is required", api.Name)
+		}
+		for _, pattern := range api.Operations {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("openapi %s: invalid operation pattern %q", api.Name, pattern)
+			}
+		}
+	}
+
+	// 验证 gRPC 工具服务配置
+	providers := make(map[string]bool, len(c.GRPCTools))
+	for _, p := range c.GRPCTools {
+		if p.Name == "" {
+			return fmt.Errorf("grpc_tools name is required")
+		}
+		if providers[p.Name] {
+			return fmt.Errorf("duplicate grpc_tools name: %s", p.Name)
+		}
+		providers[p.Name] = true
+		if p.Address == "" {
+			return fmt.Errorf("grpc_tools %s: address is required", p.Name)
+		}
+		if (p.CertFile == "") != (p.KeyFile == "") {
+			return fmt.Errorf("grpc_tools %s: cert_file and key_file must be set together", p.Name)
+		}
+		if p.Insecure && (p.CAFile != "" || p.CertFile != "") {
+			return fmt.Errorf("grpc_tools %s: insecure cannot be combined with TLS files", p.Name)
+		}
+	}
+
+	// 验证工具能力标记
+	for _, rule := range c.ToolCapabilities {
+		if _, err := path.Match(rule.Tool, ""); err != nil || rule.Tool == "" {
+			return fmt.Errorf("tool_capabilities: invalid tool pattern %q", rule.Tool)
+		}
+		for _, name := range rule.Capabilities {
+			if !ValidCapability(name) {
+				return fmt.Errorf("tool_capabilities %s: unknown capability %q", rule.Tool, name)
+			}
+		}
+	}
+
+	// 验证组合工具配置，步骤在 Agent 启动时按工作流格式校验
+	composites := make(map[string]bool, len(c.CompositeTools))
+	for _, t := range c.CompositeTools {
+		if t.Name == "" {
+			return fmt.Errorf("composite_tools name is required")
+		}
+		if composites[t.Name] {
+			return fmt.Errorf("duplicate composite_tools name: %s", t.Name)
+		}
+		composites[t.Name] = true
+		if len(t.Steps) == 0 {
+			return fmt.Errorf("composite_tools %s: at least one step is required", t.Name)
+		}
+	}
+
+	// 验证密钥配置
+	switch c.Secrets.Provider {
+	case "", "vault":
+	default:
+		return fmt.Errorf("unknown secrets provider: %s", c.Secrets.Provider)
+	}
 
 	return nil
 }
 
-// defaultSystemPrompt 默认系统提示，用于优化模型行为和减少 token 消耗
-const defaultSystemPrompt = `你是一个高效的AI助手，具备以下特性：
-- 深度理解用户需求，避免不必要的重复工具调用
-- 优先查看对话历史，利用已有信息回答问题
-- 只在确实需要时才调用工具，避免盲目探索
-- 支持批量工具调用，提高执行效率
-- 提供清晰、准确的最终回答，简要说明工具使用情况
-- 分析项目的时候需要读取项目中的每一个文件(递归遍历，特别是项目代码文件)`
+// defaultWatcherPrompt 默认的事件诊断提示模板
+const defaultWatcherPrompt = `Kubernetes 集群中出现告警事件，请诊断原因：
+- 命名空间：{{.Namespace}}
+- 对象：{{.Kind}}/{{.Name}}
+- 原因：{{.Reason}}（发生 {{.Count}} 次）
+- 信息：{{.Message}}
+
+请使用 Kubernetes 工具查看该对象的 describe 信息、相关事件和容器日志（包括上一次崩溃的日志），
+给出根因分析和具体的修复建议。`
+
+// defaultAlertmanagerPrompt 默认的 Alertmanager 告警诊断提示模板
+const defaultAlertmanagerPrompt = `收到 Alertmanager 告警，请诊断原因：
+- 告警：{{index .Alert.Labels "alertname"}}（{{.Alert.Status}}，开始于 {{.Alert.StartsAt}}）
+- 标签：{{range $k, $v := .Alert.Labels}}{{$k}}={{$v}} {{end}}
+- 摘要：{{index .Alert.Annotations "summary"}}
+- 描述：{{index .Alert.Annotations "description"}}
+
+请使用可用的工具查看相关对象的状态、事件和日志，给出根因分析和具体的修复建议。`
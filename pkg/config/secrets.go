@@ -0,0 +1,111 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SecretResolver 解析密钥引用（如 vault:secret/data/ai-agent#token）
+type SecretResolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// secretSchemes 支持的密钥引用前缀
+var secretSchemes = []string{"vault:", "env:"}
+
+// IsSecretRef 判断字符串是否为密钥引用
+func IsSecretRef(s string) bool {
+	for _, scheme := range secretSchemes {
+		if strings.HasPrefix(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveSecrets 返回解析了所有密钥引用的配置副本
+// 原配置保持不变，便于轮换时重新解析。secrets 段自身不参与解析。
+func (c *Config) ResolveSecrets(ctx context.Context, r SecretResolver) (*Config, error) {
+	secrets := c.Secrets
+
+	resolved, err := resolveValue(ctx, r, reflect.ValueOf(*c), "")
+	if err != nil {
+		return nil, err
+	}
+	out := resolved.Interface().(Config)
+	out.Secrets = secrets
+	return &out, nil
+}
+
+// resolveValue 深拷贝 v 并解析其中的密钥引用，path 用于错误提示
+func resolveValue(ctx context.Context, r SecretResolver, v reflect.Value, path string) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.String:
+		if !IsSecretRef(v.String()) {
+			return v, nil
+		}
+		secret, err := r.Resolve(ctx, v.String())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("resolve secret %s: %w", path, err)
+		}
+		return reflect.ValueOf(secret).Convert(v.Type()), nil
+
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			if !field.IsExported() || field.Type == reflect.TypeFor[SecretsConfig]() {
+				continue
+			}
+			fv, err := resolveValue(ctx, r, v.Field(i), joinPath(path, field.Name))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Field(i).Set(fv)
+		}
+		return out, nil
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v, nil
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			ev, err := resolveValue(ctx, r, v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Index(i).Set(ev)
+		}
+		return out, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v, nil
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			ev, err := resolveValue(ctx, r, iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.SetMapIndex(iter.Key(), ev)
+		}
+		return out, nil
+
+	default:
+		return v, nil
+	}
+}
+
+// joinPath 拼接字段路径
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
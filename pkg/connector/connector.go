@@ -0,0 +1,100 @@
+// Package connector 定义聊天平台连接器的接口。连接器只负责平台协议：接收消息、发送和编辑回答、显示“正在输入”；
+// 对话映射、人设命令、文件处理、回答过程中的进度更新和断线重连由 Agent 统一处理（见 agent.RegisterConnector），
+// 接入新的平台（如 Mattermost）只需实现 Connector。
+//
+//	c := mattermost.New(cfg)
+//	if err := ag.RegisterConnector(c); err != nil {
+//		...
+//	}
+//	ag.StartBackground(ctx)
+package connector
+
+import (
+	"context"
+	"time"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// Connector 聊天平台连接器
+type Connector interface {
+	// Name 连接器名称，用于对话 ID、日志和设置的存储键，只能包含字母、数字、-、_ 和 .
+	Name() string
+	// Options 模型、执行身份、文件处理和工具范围等公共配置
+	Options() config.ConnectorConfig
+	// Receive 连接平台并把收到的消息交给 handler，直到 ctx 结束或连接断开。handler 不阻塞，可以在读取循环中直接调用。
+	// 连接断开时返回错误，Agent 按指数退避重新调用；平台要求重连等正常断开时返回 nil
+	Receive(ctx context.Context, handler Handler) error
+	// Send 发送回答，返回之后 Edit 使用的消息 ID
+	Send(ctx context.Context, target Target, reply *Reply) (string, error)
+	// Edit 把已发送的消息替换为回答的当前状态
+	Edit(ctx context.Context, target Target, id string, reply *Reply) error
+	// Typing 显示“正在输入”，回答过程中定期调用；平台不支持时返回 nil
+	Typing(ctx context.Context, target Target) error
+}
+
+// Exclusive 可选接口：Exclusive 返回 true 的连接器同一时间只能有一个连接（如 Telegram 长轮询），多副本时只在主节点运行
+type Exclusive interface {
+	Exclusive() bool
+}
+
+// Handler 处理收到的消息
+type Handler func(ctx context.Context, msg *Message)
+
+// Message 收到的消息
+type Message struct {
+	Channel string // 频道或聊天 ID
+	Thread  string // 讨论串 ID，为空表示整个频道为一个对话
+	ID      string // 平台的消息 ID，回复时作为 Target.ReplyTo 原样传回
+	User    string // 发送者
+	Text    string
+	Files   []File // 附带的文件和图片
+
+	// Command 命令名（不含前缀），如 persona、help，为空表示普通消息；Args 为命令参数
+	Command string
+	Args    string
+	// CommandPrefix 在平台上输入命令的前缀，用于帮助信息，如 Slack 的 "/agent "、Telegram 的 "/"
+	CommandPrefix string
+}
+
+// File 消息附带的文件，处理时才下载
+type File struct {
+	Name  string
+	Size  int  // 字节数，未知时为 0
+	Image bool // 图片随提问发送给视觉模型，其他文件按 uploads 配置处理
+	// Download 下载文件内容，超过 maxBytes 时返回错误
+	Download func(ctx context.Context, maxBytes int) ([]byte, error)
+}
+
+// Target 回答发送的位置
+type Target struct {
+	Channel string
+	Thread  string
+	ReplyTo string // 回复的消息 ID
+	User    string // 收到消息的发送者
+	// Private 只有 User 可见（如命令的回复），平台不支持时在频道中发送
+	Private bool
+}
+
+// Reply 回答的当前状态，由连接器按平台的格式渲染
+type Reply struct {
+	Tools  []ToolCall
+	Answer string // Markdown
+	Err    string
+	Done   bool
+	Footer string // 回答完成后显示的说明：模型、人设和工具调用次数
+}
+
+// ToolCall 回答过程中的一次工具调用
+type ToolCall struct {
+	Tool      string
+	Arguments map[string]any
+	Result    string
+	Error     string
+	Done      bool
+}
+
+// RetryAfter 可选接口：Receive 返回的错误实现该接口时，Agent 按平台建议的时间等待后再重连（如频率限制）
+type RetryAfter interface {
+	RetryAfter() time.Duration
+}
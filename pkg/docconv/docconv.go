@@ -0,0 +1,81 @@
+// Package docconv 把 HTML、Markdown 和简单的 PDF 转换为纯文本：保留标题（以 Markdown 的 # 标记）、
+// 段落和列表结构，去掉标签、样式和链接地址等对模型没有用处的内容，减少送入对话的 token 数
+package docconv
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// 文档格式
+const (
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+	FormatPDF      = "pdf"
+	FormatText     = "text"
+)
+
+// ErrUnsupported 格式不支持或无法从文档中提取文本
+var ErrUnsupported = errors.New("unsupported document")
+
+// extFormats 扩展名对应的格式
+var extFormats = map[string]string{
+	".html":     FormatHTML,
+	".htm":      FormatHTML,
+	".xhtml":    FormatHTML,
+	".md":       FormatMarkdown,
+	".markdown": FormatMarkdown,
+	".pdf":      FormatPDF,
+	".txt":      FormatText,
+}
+
+// DetectFormat 按扩展名判断格式，无法判断时按内容判断
+func DetectFormat(name string, data []byte) string {
+	if format, ok := extFormats[strings.ToLower(filepath.Ext(name))]; ok {
+		return format
+	}
+	head := strings.ToLower(strings.TrimSpace(string(data[:min(len(data), 512)])))
+	switch {
+	case strings.HasPrefix(head, "%pdf-"):
+		return FormatPDF
+	case strings.HasPrefix(head, "<!doctype html") || strings.HasPrefix(head, "<html"):
+		return FormatHTML
+	}
+	return FormatText
+}
+
+// Convert 按格式把文档转换为纯文本
+func Convert(data []byte, format string) (string, error) {
+	var text string
+	switch format {
+	case FormatHTML:
+		text = HTML(string(data))
+	case FormatMarkdown:
+		text = Markdown(string(data))
+	case FormatPDF:
+		var err error
+		if text, err = PDF(data); err != nil {
+			return "", err
+		}
+	case FormatText:
+		text = string(data)
+	default:
+		return "", fmt.Errorf("%w: format %q", ErrUnsupported, format)
+	}
+	return tidy(text), nil
+}
+
+// blankLines 连续的空行
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// tidy 去掉行尾空白，合并连续的空行
+func tidy(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t ")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
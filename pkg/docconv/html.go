@@ -0,0 +1,213 @@
+package docconv
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skippedElements 不输出内容的元素
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Iframe: true, atom.Object: true, atom.Canvas: true, atom.Head: true,
+}
+
+// blockElements 前后换行的块级元素
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Header: true, atom.Footer: true, atom.Nav: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Table: true,
+	atom.Figure: true, atom.Figcaption: true, atom.Form: true, atom.Hr: true,
+	atom.Address: true, atom.Details: true, atom.Summary: true,
+}
+
+// headingLevels 标题元素的级别
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// HTML 把 HTML 转换为纯文本：标题转换为 # 标记，列表项以 - 开头，表格每行一行、单元格以 | 分隔，
+// pre 保留原有空白，其他文本合并连续空白；不输出脚本、样式和 head 中除标题外的内容
+func HTML(s string) string {
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		// html.Parse 只在读取失败时返回错误，字符串不会失败
+		return s
+	}
+	w := &htmlWriter{}
+	if title := findTitle(doc); title != "" {
+		w.block()
+		w.buf.WriteString("# " + title)
+		w.block()
+	}
+	w.walk(doc)
+	return w.buf.String()
+}
+
+// htmlWriter 遍历节点输出文本
+type htmlWriter struct {
+	buf strings.Builder
+	pre int
+}
+
+// walk 输出节点及其子节点
+func (w *htmlWriter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+		if skippedElements[n.DataAtom] {
+			return
+		}
+	case html.CommentNode, html.DoctypeNode:
+		return
+	}
+
+	if level, ok := headingLevels[n.DataAtom]; ok {
+		w.block()
+		w.buf.WriteString(strings.Repeat("#", level) + " " + collapse(textContent(n)))
+		w.block()
+		return
+	}
+
+	switch {
+	case n.DataAtom == atom.Br:
+		w.buf.WriteString("\n")
+	case n.DataAtom == atom.Li:
+		w.line()
+		w.buf.WriteString("- ")
+	case n.DataAtom == atom.Tr:
+		w.line()
+	case n.DataAtom == atom.Td || n.DataAtom == atom.Th:
+		if n.PrevSibling != nil {
+			w.buf.WriteString(" | ")
+		}
+	case n.DataAtom == atom.Pre:
+		w.block()
+		w.pre++
+	case n.DataAtom == atom.Img:
+		if alt := attr(n, "alt"); alt != "" {
+			w.text("[" + alt + "]")
+		}
+	case blockElements[n.DataAtom]:
+		w.block()
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+
+	switch {
+	case n.DataAtom == atom.Pre:
+		w.pre--
+		w.block()
+	case n.DataAtom == atom.Li || n.DataAtom == atom.Tr:
+		w.line()
+	case blockElements[n.DataAtom]:
+		w.block()
+	}
+}
+
+// text 输出文本：pre 中原样输出，否则合并空白并在需要时与前文之间加一个空格
+func (w *htmlWriter) text(s string) {
+	if w.pre > 0 {
+		w.buf.WriteString(s)
+		return
+	}
+	leading := s != "" && isSpace(s[0])
+	trailing := s != "" && isSpace(s[len(s)-1])
+	s = collapse(s)
+	if s == "" {
+		if leading {
+			w.space()
+		}
+		return
+	}
+	if leading {
+		w.space()
+	}
+	w.buf.WriteString(s)
+	if trailing {
+		w.space()
+	}
+}
+
+// space 前文不以空白结尾时输出一个空格
+func (w *htmlWriter) space() {
+	if out := w.buf.String(); out != "" && !isSpace(out[len(out)-1]) {
+		w.buf.WriteByte(' ')
+	}
+}
+
+// line 前文不以换行结尾时换行
+func (w *htmlWriter) line() {
+	if out := w.buf.String(); out != "" && !strings.HasSuffix(out, "\n") {
+		w.buf.WriteByte('\n')
+	}
+}
+
+// block 块级元素之间空一行
+func (w *htmlWriter) block() {
+	out := w.buf.String()
+	switch {
+	case out == "" || strings.HasSuffix(out, "\n\n"):
+	case strings.HasSuffix(out, "\n"):
+		w.buf.WriteByte('\n')
+	default:
+		w.buf.WriteString("\n\n")
+	}
+}
+
+// findTitle 返回 head 中的 title
+func findTitle(n *html.Node) string {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Title {
+		return collapse(textContent(n))
+	}
+	if n.Type == html.ElementNode && n.DataAtom == atom.Body {
+		return ""
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if title := findTitle(c); title != "" {
+			return title
+		}
+	}
+	return ""
+}
+
+// textContent 节点中的所有文本，跳过脚本和样式
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	if n.Type == html.ElementNode && skippedElements[n.DataAtom] {
+		return ""
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// attr 返回元素的属性值
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// collapse 合并连续空白并去掉首尾空白
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// isSpace 是否为 ASCII 空白
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
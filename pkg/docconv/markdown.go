@@ -0,0 +1,111 @@
+package docconv
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// mdImage ![alt](url) 保留 alt
+	mdImage = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	// mdLink [text](url) 和 [text][ref] 保留文本
+	mdLink = regexp.MustCompile(`\[([^\]]+)\](?:\([^)]*\)|\[[^\]]*\])`)
+	// mdAutolink <https://...> 保留地址
+	mdAutolink = regexp.MustCompile(`<((?:https?|mailto):[^>\s]+)>`)
+	// mdRefDef [ref]: url 链接定义，整行去掉
+	mdRefDef = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s+\S+`)
+	// mdStrong **粗体**、__粗体__、~~删除线~~ 保留文本
+	mdStrong = regexp.MustCompile(`(\*\*|__|~~)(\S(?:.*?\S)?)(\*\*|__|~~)`)
+	// mdEmphasis *斜体*、_斜体_ 保留文本，不处理 snake_case 这类单词中的下划线
+	mdEmphasis = regexp.MustCompile(`(^|[^\w*])[*_](\S(?:.*?\S)?)[*_]([^\w*]|$)`)
+	// mdCode `行内代码` 保留代码
+	mdCode = regexp.MustCompile("`+([^`]+)`+")
+	// mdTag HTML 标签
+	mdTag = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	// mdHeading ATX 标题，去掉结尾的 #
+	mdHeading = regexp.MustCompile(`^\s{0,3}(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	// mdSetext Setext 标题的下划线
+	mdSetext = regexp.MustCompile(`^\s{0,3}(=+|-+)\s*$`)
+	// mdRule 分隔线
+	mdRule = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+	// mdList 列表项，统一为 -
+	mdList = regexp.MustCompile(`^(\s*)(?:[-*+]|\d+[.)])\s+(?:\[[ xX]\]\s+)?`)
+	// mdTableRule 表格的分隔行
+	mdTableRule = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	// mdFence 代码块的围栏
+	mdFence = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+)
+
+// Markdown 去掉 Markdown 的格式标记：标题统一为 # 标记，链接和图片只保留文本，
+// 代码块保留代码去掉围栏，列表统一以 - 开头，表格去掉分隔行和首尾的 |
+func Markdown(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	inFence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := mdFence.FindStringSubmatch(line); m != nil {
+			switch {
+			case inFence == "":
+				inFence = m[1]
+				continue
+			case m[1] == inFence:
+				inFence = ""
+				continue
+			}
+		}
+		if inFence != "" {
+			out = append(out, line)
+			continue
+		}
+
+		// Setext 标题：下一行为 === 或 ---
+		if strings.TrimSpace(line) != "" && i+1 < len(lines) && !mdList.MatchString(line) {
+			if m := mdSetext.FindStringSubmatch(lines[i+1]); m != nil {
+				level := "#"
+				if m[1][0] == '-' {
+					level = "##"
+				}
+				out = append(out, level+" "+inline(strings.TrimSpace(line)))
+				i++
+				continue
+			}
+		}
+
+		switch {
+		case mdTableRule.MatchString(line) && strings.Contains(line, "|"):
+			// 表格分隔行直接去掉，表头与数据行相连
+		case mdRefDef.MatchString(line), mdRule.MatchString(line):
+			out = append(out, "")
+		case mdHeading.MatchString(line):
+			m := mdHeading.FindStringSubmatch(line)
+			out = append(out, m[1]+" "+inline(m[2]))
+		default:
+			line = strings.TrimLeft(line, " >")
+			if loc := mdList.FindStringSubmatchIndex(line); loc != nil {
+				line = line[loc[2]:loc[3]] + "- " + line[loc[1]:]
+			}
+			if strings.HasPrefix(strings.TrimSpace(line), "|") {
+				cells := strings.Split(strings.Trim(strings.TrimSpace(line), "|"), "|")
+				for j, cell := range cells {
+					cells[j] = strings.TrimSpace(cell)
+				}
+				line = strings.Join(cells, " | ")
+			}
+			out = append(out, inline(line))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// inline 去掉行内的格式标记
+func inline(s string) string {
+	s = mdImage.ReplaceAllString(s, "$1")
+	s = mdLink.ReplaceAllString(s, "$1")
+	s = mdAutolink.ReplaceAllString(s, "$1")
+	s = mdCode.ReplaceAllString(s, "$1")
+	s = mdTag.ReplaceAllString(s, "")
+	s = mdStrong.ReplaceAllString(s, "$2")
+	s = mdEmphasis.ReplaceAllString(s, "$1$2$3")
+	return s
+}
@@ -0,0 +1,341 @@
+package docconv
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPDFStream 单个内容流解压后的最大字节数
+const maxPDFStream = 32 << 20
+
+var (
+	// pdfStream 流对象：字典和流数据
+	pdfStream = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	// pdfNonContent 不是页面内容的流（图片、字体、XObject 表单以外的元数据等）
+	pdfNonContent = regexp.MustCompile(`/Subtype\s*/(Image|XML|Type1C|CIDFontType0C|OpenType)|/Type\s*/(XRef|ObjStm|Metadata|EmbeddedFile)|/Length[123]\b`)
+)
+
+// PDF 从简单的 PDF 中提取文本：解析页面内容流中的文本操作符（Tj、TJ、'、"），
+// 按 Td、T*、Tm 等操作符换行。只支持未加密、使用标准编码字体的 PDF，
+// 扫描件和使用 CID 字体（常见于中文 PDF）的文档无法提取，返回 ErrUnsupported
+func PDF(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data[:min(len(data), 1024)]), []byte("%PDF-")) {
+		return "", fmt.Errorf("%w: not a PDF file", ErrUnsupported)
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", fmt.Errorf("%w: encrypted PDF", ErrUnsupported)
+	}
+
+	var out strings.Builder
+	for _, loc := range pdfStream.FindAllSubmatchIndex(data, -1) {
+		dict := data[loc[2]:loc[3]]
+		if pdfNonContent.Match(dict) {
+			continue
+		}
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+		stream := data[start : start+end]
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			decoded, err := inflate(stream)
+			if err != nil {
+				continue
+			}
+			stream = decoded
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			// 其他压缩方式（DCT 图片、LZW 等）不处理
+			continue
+		}
+		if text := pdfText(stream); strings.TrimSpace(text) != "" {
+			out.WriteString(text)
+			out.WriteString("\n\n")
+		}
+	}
+	if strings.TrimSpace(out.String()) == "" {
+		return "", fmt.Errorf("%w: no extractable text (scanned or uses embedded CID fonts)", ErrUnsupported)
+	}
+	return out.String(), nil
+}
+
+// inflate 解压 FlateDecode 流，流数据末尾可能有多余的换行
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxPDFStream))
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+// pdfText 解析内容流中的文本操作符；不在 BT/ET 之间的内容（图形操作）忽略
+func pdfText(content []byte) string {
+	var out strings.Builder
+	var operands []pdfToken
+	inText := false
+	lex := &pdfLexer{data: content}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+		switch tok.value {
+		case "BT":
+			inText = true
+		case "ET":
+			inText = false
+			out.WriteString("\n")
+		case "Tj":
+			if inText {
+				out.WriteString(lastString(operands))
+			}
+		case "TJ":
+			if !inText {
+				break
+			}
+			for _, op := range operands {
+				if op.kind == pdfString {
+					out.WriteString(op.value)
+				} else if v, err := strconv.ParseFloat(op.value, 64); err == nil && v < -200 {
+					// 数组中的负数表示向右的字距，超过约五分之一个字宽时视为空格
+					out.WriteString(" ")
+				}
+			}
+		case "'", `"`:
+			if inText {
+				out.WriteString("\n" + lastString(operands))
+			}
+		case "T*", "Tm":
+			out.WriteString("\n")
+		case "Td", "TD":
+			// 纵向移动时换行，只有横向移动时视为同一行的词间距
+			if len(operands) > 0 {
+				if ty, err := strconv.ParseFloat(operands[len(operands)-1].value, 64); err == nil && ty != 0 {
+					out.WriteString("\n")
+					break
+				}
+			}
+			out.WriteString(" ")
+		}
+		operands = operands[:0]
+	}
+	return out.String()
+}
+
+// lastString 操作数中最后一个字符串，没有时为空
+func lastString(operands []pdfToken) string {
+	for i := len(operands) - 1; i >= 0; i-- {
+		if operands[i].kind == pdfString {
+			return operands[i].value
+		}
+	}
+	return ""
+}
+
+// PDF 内容流的词法单元类型
+const (
+	pdfOperand = iota
+	pdfString
+	pdfOperator
+)
+
+// pdfToken 词法单元：字符串为解码后的文本，数字、名称等其他操作数为原文
+type pdfToken struct {
+	kind  int
+	value string
+}
+
+// pdfLexer 内容流的词法分析器
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+// next 返回下一个词法单元，数组的括号忽略，其中的元素逐个作为操作数返回
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isSpace(c) || c == 0:
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return pdfToken{pdfString, l.literal()}, true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+			l.skipDict()
+		case c == '<':
+			return pdfToken{pdfString, l.hex()}, true
+		case c == '[' || c == ']':
+			l.pos++
+		case c == '/':
+			l.pos++
+			l.word()
+			return pdfToken{pdfOperand, ""}, true
+		case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+			return pdfToken{pdfOperand, l.word()}, true
+		default:
+			op := l.word()
+			if op == "" {
+				l.pos++
+				continue
+			}
+			if op == "BI" {
+				// 内嵌图片的数据可能包含任意字节，跳到 EI
+				if i := bytes.Index(l.data[l.pos:], []byte("EI")); i >= 0 {
+					l.pos += i + 2
+				} else {
+					l.pos = len(l.data)
+				}
+				continue
+			}
+			return pdfToken{pdfOperator, op}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+// word 读取到空白或分隔符为止
+func (l *pdfLexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isSpace(c) || c == 0 || strings.IndexByte("()<>[]{}/%", c) >= 0 {
+			break
+		}
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// literal 解析 (...) 字符串，处理转义和嵌套括号
+func (l *pdfLexer) literal() string {
+	var b []byte
+	depth := 0
+	l.pos++
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '\\':
+			if l.pos >= len(l.data) {
+				return decodePDFString(b)
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				b = append(b, '\n')
+			case 'r':
+				b = append(b, '\r')
+			case 't':
+				b = append(b, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// 续行
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					b = append(b, byte(v))
+				} else {
+					b = append(b, e)
+				}
+			}
+		case '(':
+			depth++
+			b = append(b, c)
+		case ')':
+			if depth == 0 {
+				return decodePDFString(b)
+			}
+			depth--
+			b = append(b, c)
+		default:
+			b = append(b, c)
+		}
+	}
+	return decodePDFString(b)
+}
+
+// hex 解析 <...> 十六进制字符串
+func (l *pdfLexer) hex() string {
+	l.pos++
+	end := bytes.IndexByte(l.data[l.pos:], '>')
+	if end < 0 {
+		l.pos = len(l.data)
+		return ""
+	}
+	digits := strings.Join(strings.Fields(string(l.data[l.pos:l.pos+end])), "")
+	l.pos += end + 1
+	if len(digits)%2 == 1 {
+		digits += "0"
+	}
+	b := make([]byte, 0, len(digits)/2)
+	for i := 0; i+1 < len(digits); i += 2 {
+		v, err := strconv.ParseUint(digits[i:i+2], 16, 8)
+		if err != nil {
+			return ""
+		}
+		b = append(b, byte(v))
+	}
+	return decodePDFString(b)
+}
+
+// skipDict 跳过 << ... >> 字典（如标记内容的属性）
+func (l *pdfLexer) skipDict() {
+	depth := 0
+	for l.pos+1 < len(l.data) {
+		switch {
+		case l.data[l.pos] == '<' && l.data[l.pos+1] == '<':
+			depth++
+			l.pos += 2
+		case l.data[l.pos] == '>' && l.data[l.pos+1] == '>':
+			depth--
+			l.pos += 2
+			if depth == 0 {
+				return
+			}
+		default:
+			l.pos++
+		}
+	}
+	l.pos = len(l.data)
+}
+
+// decodePDFString 解码字符串：带 BOM 的按 UTF-16BE，其他按 Latin-1（近似 WinAnsi/PDFDoc 编码）；
+// 不可打印的字节（通常是 CID 字体的字形编号）丢弃
+func decodePDFString(b []byte) string {
+	var out strings.Builder
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		for i := 2; i+1 < len(b); i += 2 {
+			out.WriteRune(rune(b[i])<<8 | rune(b[i+1]))
+		}
+		return out.String()
+	}
+	for _, c := range b {
+		if c >= 0x20 || c == '\n' || c == '\t' {
+			out.WriteRune(rune(c))
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,217 @@
+// Package egress 实现出站 HTTP 请求策略：限制可访问的协议、主机和地址段以及响应大小，
+// 防止访问外部地址的工具和加载器被用来探测或访问 Agent 所在网络的内部服务（SSRF）
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+var (
+	// ErrDenied 目标地址不在出站策略允许范围内
+	ErrDenied = errors.New("denied by egress policy")
+	// ErrResponseTooLarge 响应超过出站策略的大小限制
+	ErrResponseTooLarge = errors.New("response exceeds egress size limit")
+)
+
+// Policy 出站请求策略
+// 主机名匹配 allowed_hosts 时直接放行；否则实际连接的地址必须位于 allowed_cidrs 中，
+// 两者都未配置时允许任意公网地址，拒绝回环、私有、链路本地等内部地址。
+type Policy struct {
+	hosts   []string
+	cidrs   []*net.IPNet
+	schemes []string
+	maxSize int64
+}
+
+// New 创建出站策略
+func New(cfg config.EgressConfig) (*Policy, error) {
+	p := &Policy{
+		schemes: cfg.Schemes,
+		maxSize: cfg.MaxResponseBytes,
+	}
+	for _, h := range cfg.AllowedHosts {
+		p.hosts = append(p.hosts, strings.ToLower(h))
+	}
+	for _, c := range cfg.AllowedCIDRs {
+		// 允许直接写单个 IP
+		cidr := c
+		if ip := net.ParseIP(c); ip != nil {
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid egress cidr %q: %w", c, err)
+		}
+		p.cidrs = append(p.cidrs, ipNet)
+	}
+	if len(p.schemes) == 0 {
+		p.schemes = []string{"http", "https"}
+	}
+
+	klog.V(2).InfoS("Egress policy loaded", "hosts", p.hosts, "cidrs", cfg.AllowedCIDRs, "schemes", p.schemes, "maxResponseBytes", p.maxSize)
+	return p, nil
+}
+
+// CheckURL 检查协议和主机名，地址在建立连接时检查
+func (p *Policy) CheckURL(u *url.URL) error {
+	if !slices.Contains(p.schemes, strings.ToLower(u.Scheme)) {
+		return fmt.Errorf("%w: scheme %q is not allowed", ErrDenied, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: missing host in %s", ErrDenied, u.Redacted())
+	}
+	return nil
+}
+
+// Client 返回执行该策略的 HTTP 客户端：每次请求（包括重定向）检查协议，
+// 连接时检查解析后的实际地址（防止 DNS 重绑定），并限制响应体大小。不使用环境变量中的代理
+func (p *Policy) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if p.matchHost(host) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		// 在连接前校验解析得到的每个地址
+		d := *dialer
+		d.Control = func(_, address string, _ syscall.RawConn) error {
+			ipStr, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return p.checkIP(host, net.ParseIP(ipStr))
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &roundTripper{policy: p, next: transport},
+	}
+}
+
+// matchHost 主机名是否在 allowed_hosts 中
+func (p *Policy) matchHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range p.hosts {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIP 检查连接地址
+func (p *Policy) checkIP(host string, ip net.IP) error {
+	if ip == nil {
+		return fmt.Errorf("%w: invalid address for %s", ErrDenied, host)
+	}
+	if len(p.hosts) == 0 && len(p.cidrs) == 0 {
+		if isInternal(ip) {
+			return fmt.Errorf("%w: %s resolves to internal address %s", ErrDenied, host, ip)
+		}
+		return nil
+	}
+	for _, c := range p.cidrs {
+		if c.Contains(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s (%s) is not in the allowlist", ErrDenied, host, ip)
+}
+
+// isInternal 是否为内部地址（回环、私有、链路本地、运营商级 NAT 等）
+func isInternal(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	// 100.64.0.0/10（运营商级 NAT，也常用于云厂商内部网络）
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return true
+	}
+	return false
+}
+
+// roundTripper 检查每个请求的协议和主机，并限制响应体大小
+type roundTripper struct {
+	policy *Policy
+	next   http.RoundTripper
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.policy.CheckURL(req.URL); err != nil {
+		klog.InfoS("Outbound request denied", "url", req.URL.Redacted(), "err", err)
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		if errors.Is(err, ErrDenied) {
+			klog.InfoS("Outbound request denied", "url", req.URL.Redacted(), "err", err)
+		}
+		return nil, err
+	}
+
+	if limit := t.policy.maxSize; limit > 0 {
+		if resp.ContentLength > limit {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%w: %s declares %d bytes, limit is %d", ErrResponseTooLarge, req.URL.Redacted(), resp.ContentLength, limit)
+		}
+		resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: limit}
+	}
+	return resp, nil
+}
+
+// limitedBody 超过大小限制时返回 ErrResponseTooLarge，而不是静默截断
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+// Read 实现 io.Reader
+func (b *limitedBody) Read(buf []byte) (int, error) {
+	if b.remaining <= 0 {
+		// 恰好读完时不算超出
+		var probe [1]byte
+		if n, _ := b.ReadCloser.Read(probe[:]); n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(buf)) > b.remaining {
+		buf = buf[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(buf)
+	b.remaining -= int64(n)
+	return n, err
+}
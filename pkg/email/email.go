@@ -0,0 +1,287 @@
+// Package email 通过 IMAP 轮询和 SMTP 回复把 Agent 接入邮箱的连接器：每个邮件会话（按 References 归并）对应一个对话，
+// 附件交给 Agent 按 uploads 配置处理（默认导入知识库），回答完成后作为对原邮件的回复发送。
+// 未授权的发件人和自动发送的邮件（退信、自动回复、邮件列表）标为已读后忽略，不回复
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/connector"
+)
+
+const (
+	// maxMessagesPerPoll 每次轮询处理的最大邮件数，其余在下一次轮询处理
+	maxMessagesPerPoll = 20
+	// pendingTTL 等待回答的邮件的保留时间，超过后不再回复
+	pendingTTL = time.Hour
+)
+
+// Bot 邮件连接器
+type Bot struct {
+	cfg  config.EmailConfig
+	from *mail.Address
+
+	mu      sync.Mutex
+	pending map[string]*pending // 等待回答的邮件，键为 Message-ID
+}
+
+// pending 等待回答的邮件
+type pending struct {
+	msg      *inbound
+	received time.Time
+}
+
+var (
+	_ connector.Connector = (*Bot)(nil)
+	_ connector.Exclusive = (*Bot)(nil)
+)
+
+// New 创建邮件连接器
+func New(cfg config.EmailConfig) *Bot {
+	from, err := mail.ParseAddress(cfg.Address)
+	if err != nil {
+		from = &mail.Address{Address: cfg.Address}
+	}
+	return &Bot{cfg: cfg, from: from, pending: make(map[string]*pending)}
+}
+
+// Name 实现 connector.Connector
+func (b *Bot) Name() string {
+	return "email"
+}
+
+// Options 实现 connector.Connector
+func (b *Bot) Options() config.ConnectorConfig {
+	return b.cfg.ConnectorConfig
+}
+
+// Exclusive 实现 connector.Exclusive：多副本同时轮询同一邮箱会重复回复，只在主节点运行
+func (b *Bot) Exclusive() bool {
+	return true
+}
+
+// Receive 按 poll_interval 轮询未读邮件，直到 ctx 结束或轮询失败
+func (b *Bot) Receive(ctx context.Context, handler connector.Handler) error {
+	for {
+		if err := b.poll(ctx, handler); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(b.cfg.PollInterval):
+		}
+	}
+}
+
+// poll 登录邮箱处理一批未读邮件：先标为已读再交给 handler，轮询失败时不会重复回复
+func (b *Bot) poll(ctx context.Context, handler connector.Handler) error {
+	b.prune()
+	c, err := dialIMAP(ctx, b.cfg.IMAP)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.Login(b.cfg.Username, b.cfg.Password); err != nil {
+		return err
+	}
+	if err := c.Select(b.cfg.Mailbox); err != nil {
+		return err
+	}
+	uids, err := c.Unseen()
+	if err != nil {
+		return err
+	}
+	if len(uids) > maxMessagesPerPoll {
+		uids = uids[:maxMessagesPerPoll]
+	}
+
+	// 邮件总大小上限：附件经 base64 编码后约为原大小的 4/3
+	limit := b.cfg.MaxFileBytes * 2
+	for _, uid := range uids {
+		size, err := c.Size(uid)
+		if err != nil {
+			return err
+		}
+		section := ""
+		if size > limit {
+			section = "HEADER"
+		}
+		data, err := c.Fetch(uid, section)
+		if err != nil {
+			return err
+		}
+		if err := c.MarkSeen(uid); err != nil {
+			return err
+		}
+
+		msg, err := parseMessage(data)
+		if err != nil {
+			klog.ErrorS(err, "Failed to parse email, skipped", "uid", uid)
+			continue
+		}
+		if !b.accept(msg) {
+			continue
+		}
+		if size > limit {
+			go b.reject(ctx, msg, fmt.Sprintf("邮件大小 %d 字节超过上限 %d 字节，未处理，请减小附件后重新发送", size, limit))
+			continue
+		}
+		b.dispatch(ctx, msg, handler)
+	}
+	return c.Logout()
+}
+
+// accept 是否处理该邮件：发件人在 allowed_senders 中，且不是自己发出或自动发送的邮件
+func (b *Bot) accept(msg *inbound) bool {
+	switch {
+	case strings.EqualFold(msg.From, b.from.Address):
+		return false
+	case msg.Auto:
+		klog.V(2).InfoS("Automatic email ignored", "from", msg.From, "subject", msg.Subject)
+		return false
+	case !senderAllowed(b.cfg.AllowedSenders, msg.From):
+		klog.InfoS("Email from unauthorized sender ignored", "from", msg.From, "subject", msg.Subject)
+		return false
+	}
+	return true
+}
+
+// senderAllowed 发件人是否在 allowed 中：完整地址或 @域名，忽略大小写
+func senderAllowed(allowed []string, from string) bool {
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if from == a || strings.HasPrefix(a, "@") && strings.HasSuffix(from, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch 把邮件转为连接器消息：邮件会话的第一封邮件为频道，新会话的主题作为提问的一部分
+func (b *Bot) dispatch(ctx context.Context, msg *inbound, handler connector.Handler) {
+	text := msg.Text
+	if msg.Thread == msg.ID && msg.Subject != "" {
+		text = strings.TrimSpace("主题：" + msg.Subject + "\n\n" + text)
+	}
+	m := &connector.Message{
+		Channel: msg.Thread,
+		ID:      msg.ID,
+		User:    msg.From,
+		Text:    text,
+	}
+	for _, f := range msg.Files {
+		data := f.Data
+		m.Files = append(m.Files, connector.File{
+			Name:  f.Name,
+			Size:  len(data),
+			Image: f.Image,
+			Download: func(ctx context.Context, maxBytes int) ([]byte, error) {
+				if len(data) > maxBytes {
+					return nil, fmt.Errorf("file size %d exceeds limit of %d bytes", len(data), maxBytes)
+				}
+				return data, nil
+			},
+		})
+	}
+	if m.Text == "" && len(m.Files) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	b.pending[msg.ID] = &pending{msg: msg, received: time.Now()}
+	b.mu.Unlock()
+	klog.InfoS("Email received", "from", msg.From, "subject", msg.Subject, "thread", msg.Thread, "files", len(msg.Files))
+	handler(ctx, m)
+}
+
+// prune 清理超过 pendingTTL 仍未回答的邮件
+func (b *Bot) prune() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, p := range b.pending {
+		if time.Since(p.received) > pendingTTL {
+			delete(b.pending, id)
+		}
+	}
+}
+
+// Send 实现 connector.Connector：邮件不能修改，回答完成前只记录占位，完成的回答直接作为回复发送
+func (b *Bot) Send(ctx context.Context, target connector.Target, reply *connector.Reply) (string, error) {
+	if !reply.Done {
+		return target.ReplyTo, nil
+	}
+	return target.ReplyTo, b.reply(ctx, target.ReplyTo, render(reply))
+}
+
+// Edit 实现 connector.Connector：回答完成时发送回复，回答过程中的进度忽略
+func (b *Bot) Edit(ctx context.Context, target connector.Target, id string, reply *connector.Reply) error {
+	if !reply.Done {
+		return nil
+	}
+	return b.reply(ctx, target.ReplyTo, render(reply))
+}
+
+// Typing 实现 connector.Connector，邮件不支持
+func (b *Bot) Typing(ctx context.Context, target connector.Target) error {
+	return nil
+}
+
+// reply 回复 Message-ID 为 id 的邮件，每封邮件只回复一次
+func (b *Bot) reply(ctx context.Context, id, body string) error {
+	b.mu.Lock()
+	p, ok := b.pending[id]
+	delete(b.pending, id)
+	b.mu.Unlock()
+	if !ok {
+		return errors.New("email " + id + " is not waiting for a reply")
+	}
+	return b.send(ctx, p.msg, body)
+}
+
+// reject 不处理该邮件时回复原因
+func (b *Bot) reject(ctx context.Context, msg *inbound, reason string) {
+	klog.InfoS("Email rejected", "from", msg.From, "subject", msg.Subject, "reason", reason)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+	defer cancel()
+	if err := b.send(ctx, msg, reason); err != nil {
+		klog.ErrorS(err, "Failed to send email", "to", msg.ReplyTo)
+	}
+}
+
+// send 发送对 msg 的回复
+func (b *Bot) send(ctx context.Context, msg *inbound, body string) error {
+	id, data := buildReply(b.from, msg, body, time.Now())
+	if err := sendMail(ctx, b.cfg.SMTP, b.cfg.Username, b.cfg.Password, b.from.Address, msg.ReplyTo, data); err != nil {
+		return err
+	}
+	klog.InfoS("Email reply sent", "to", msg.ReplyTo, "subject", msg.Subject, "messageID", id)
+	return nil
+}
+
+// render 把完成的回答渲染为纯文本（Markdown 原样保留）
+func render(r *connector.Reply) string {
+	if r.Err != "" {
+		return "处理失败：" + r.Err
+	}
+	text := strings.TrimSpace(r.Answer)
+	if text == "" {
+		text = "（空回答）"
+	}
+	if r.Footer != "" {
+		text += "\n\n— " + r.Footer
+	}
+	return text
+}
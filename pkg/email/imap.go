@@ -0,0 +1,238 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// commandTimeout 单个 IMAP 命令（包括读取邮件内容）的超时
+const commandTimeout = 2 * time.Minute
+
+var (
+	// literalSuffix 行尾的字面量长度 {n}（LITERAL+ 为 {n+}）
+	literalSuffix = regexp.MustCompile(`\{(\d+)\+?\}$`)
+	// sizeItem FETCH 响应中的 RFC822.SIZE
+	sizeItem = regexp.MustCompile(`RFC822\.SIZE (\d+)`)
+)
+
+// imapError 服务器对命令返回 NO 或 BAD
+type imapError struct {
+	Command string
+	Status  string
+	Text    string
+}
+
+// Error 实现 error
+func (e *imapError) Error() string {
+	return fmt.Sprintf("imap %s: %s %s", e.Command, e.Status, e.Text)
+}
+
+// response 一条未标记的响应，字面量的内容单独保存，行中只保留 {n}
+type response struct {
+	line     string
+	literals [][]byte
+}
+
+// imapClient 最小的 IMAP4rev1 客户端，只实现轮询未读邮件需要的命令
+type imapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+	stop func() bool
+}
+
+// dialIMAP 连接 IMAP 服务器并读取问候，ctx 结束时断开连接
+func dialIMAP(ctx context.Context, cfg config.EmailServerConfig) (*imapClient, error) {
+	conn, err := dial(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := &imapClient{conn: conn, r: bufio.NewReader(conn)}
+	c.stop = context.AfterFunc(ctx, func() { c.conn.Close() })
+	c.conn.SetDeadline(time.Now().Add(commandTimeout))
+	greeting, err := c.readResponse()
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("imap greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting.line, "* OK") && !strings.HasPrefix(greeting.line, "* PREAUTH") {
+		c.Close()
+		return nil, fmt.Errorf("imap greeting: %s", greeting.line)
+	}
+	if cfg.TLS == "starttls" {
+		if _, err := c.command("STARTTLS"); err != nil {
+			c.Close()
+			return nil, err
+		}
+		host, _, _ := net.SplitHostPort(cfg.Addr)
+		tlsConn := tls.Client(c.conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("imap starttls: %w", err)
+		}
+		c.conn, c.r = tlsConn, bufio.NewReader(tlsConn)
+	}
+	return c, nil
+}
+
+// dial 按 tls 配置建立连接：tls 为连接即加密，starttls 和 none 先建立明文连接
+func dial(ctx context.Context, cfg config.EmailServerConfig) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second}
+	if cfg.TLS != "tls" {
+		return d.DialContext(ctx, "tcp", cfg.Addr)
+	}
+	host, _, _ := net.SplitHostPort(cfg.Addr)
+	td := &tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: host}}
+	return td.DialContext(ctx, "tcp", cfg.Addr)
+}
+
+// Close 断开连接
+func (c *imapClient) Close() error {
+	c.stop()
+	return c.conn.Close()
+}
+
+// Login 登录
+func (c *imapClient) Login(username, password string) error {
+	_, err := c.command("LOGIN " + quote(username) + " " + quote(password))
+	return err
+}
+
+// Select 打开邮箱文件夹
+func (c *imapClient) Select(mailbox string) error {
+	_, err := c.command("SELECT " + quote(mailbox))
+	return err
+}
+
+// Unseen 未读邮件的 UID，按从旧到新排列
+func (c *imapClient) Unseen() ([]uint32, error) {
+	resps, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, r := range resps {
+		rest, ok := strings.CutPrefix(r.line, "* SEARCH")
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Fields(rest) {
+			uid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("imap search: invalid uid %q", field)
+			}
+			uids = append(uids, uint32(uid))
+		}
+	}
+	return uids, nil
+}
+
+// Size 邮件的字节数
+func (c *imapClient) Size(uid uint32) (int, error) {
+	resps, err := c.command(fmt.Sprintf("UID FETCH %d (RFC822.SIZE)", uid))
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range resps {
+		if m := sizeItem.FindStringSubmatch(r.line); m != nil {
+			return strconv.Atoi(m[1])
+		}
+	}
+	return 0, fmt.Errorf("imap fetch: no size for uid %d", uid)
+}
+
+// Fetch 读取邮件（section 为空）或邮件头（section 为 HEADER），不改变已读状态
+func (c *imapClient) Fetch(uid uint32, section string) ([]byte, error) {
+	resps, err := c.command(fmt.Sprintf("UID FETCH %d (BODY.PEEK[%s])", uid, section))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resps {
+		if strings.Contains(r.line, " FETCH ") && len(r.literals) > 0 {
+			return r.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("imap fetch: uid %d not found", uid)
+}
+
+// MarkSeen 把邮件标为已读
+func (c *imapClient) MarkSeen(uid uint32) error {
+	_, err := c.command(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid))
+	return err
+}
+
+// Logout 退出登录
+func (c *imapClient) Logout() error {
+	_, err := c.command("LOGOUT")
+	return err
+}
+
+// command 发送命令，返回完成前收到的未标记响应，服务器返回 NO 或 BAD 时返回 imapError
+func (c *imapClient) command(cmd string) ([]response, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	name, _, _ := strings.Cut(cmd, " ")
+	c.conn.SetDeadline(time.Now().Add(commandTimeout))
+	if _, err := io.WriteString(c.conn, tag+" "+cmd+"\r\n"); err != nil {
+		return nil, fmt.Errorf("imap %s: %w", name, err)
+	}
+
+	var resps []response
+	for {
+		r, err := c.readResponse()
+		if err != nil {
+			return nil, fmt.Errorf("imap %s: %w", name, err)
+		}
+		rest, ok := strings.CutPrefix(r.line, tag+" ")
+		if !ok {
+			resps = append(resps, r)
+			continue
+		}
+		status, text, _ := strings.Cut(rest, " ")
+		if !strings.EqualFold(status, "OK") {
+			return nil, &imapError{Command: name, Status: status, Text: text}
+		}
+		return resps, nil
+	}
+}
+
+// readResponse 读取一条响应，行尾为 {n} 时读取 n 字节的字面量后继续读取该响应的剩余部分
+func (c *imapClient) readResponse() (response, error) {
+	var r response
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return r, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		r.line += line
+		m := literalSuffix.FindStringSubmatch(line)
+		if m == nil {
+			return r, nil
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return r, fmt.Errorf("invalid literal size %q", m[1])
+		}
+		literal := make([]byte, n)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return r, err
+		}
+		r.literals = append(r.literals, literal)
+	}
+}
+
+// quote 把字符串转为 IMAP 的带引号字符串
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
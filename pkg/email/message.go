@@ -0,0 +1,311 @@
+package email
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/encoding/htmlindex"
+
+	"github.com/champly/ai-agent/pkg/docconv"
+)
+
+// maxPartDepth 解析嵌套 multipart 的最大层数
+const maxPartDepth = 10
+
+// decoder 解码 RFC 2047 编码的邮件头，支持 GBK 等非 UTF-8 字符集
+var decoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// inbound 收到的邮件
+type inbound struct {
+	ID         string   // Message-ID（不含尖括号）
+	Thread     string   // 邮件会话的第一封邮件的 Message-ID
+	References []string // 回复时需要带上的 References
+	From       string   // 发件地址（小写）
+	ReplyTo    string   // 回复的收件地址：Reply-To，没有时为发件地址
+	Subject    string
+	Date       time.Time
+	Text       string // 正文，已去掉引用的历史邮件
+	Auto       bool   // 自动发送的邮件（退信、自动回复、邮件列表），不回复
+	Files      []attachment
+}
+
+// attachment 邮件附件
+type attachment struct {
+	Name  string
+	Image bool
+	Data  []byte
+}
+
+// parseMessage 解析邮件：正文优先使用 text/plain，只有 HTML 时提取文本；附件和作为附件发送的图片保存在 Files，
+// 正文中引用的内嵌图片（如签名中的图标）忽略
+func parseMessage(data []byte) (*inbound, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	h := msg.Header
+	parser := &mail.AddressParser{WordDecoder: decoder}
+	from, err := parser.Parse(h.Get("From"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid From: %w", err)
+	}
+	in := &inbound{
+		ID:      messageID(h.Get("Message-Id")),
+		From:    strings.ToLower(from.Address),
+		ReplyTo: from.Address,
+		Auto:    isAutomatic(h, from.Address),
+	}
+	if replyTo, err := parser.Parse(h.Get("Reply-To")); err == nil {
+		in.ReplyTo = replyTo.Address
+	}
+	if in.ID == "" {
+		// 没有 Message-ID 的邮件按内容生成，同一封邮件得到相同的 ID
+		sum := sha256.Sum256(data)
+		in.ID = hex.EncodeToString(sum[:12]) + "@local"
+	}
+	if subject, err := decoder.DecodeHeader(h.Get("Subject")); err == nil {
+		in.Subject = strings.TrimSpace(subject)
+	} else {
+		in.Subject = h.Get("Subject")
+	}
+	in.Date, _ = h.Date()
+
+	for _, ref := range strings.Fields(h.Get("References")) {
+		if id := messageID(ref); id != "" {
+			in.References = append(in.References, id)
+		}
+	}
+	replyTo := messageID(h.Get("In-Reply-To"))
+	if len(in.References) == 0 && replyTo != "" {
+		in.References = []string{replyTo}
+	}
+	in.Thread = in.ID
+	if len(in.References) > 0 {
+		in.Thread = in.References[0]
+	}
+
+	var plain, html []string
+	err = walkPart(textproto.MIMEHeader(h), msg.Body, 0, func(mediaType string, header textproto.MIMEHeader, body []byte) error {
+		disposition, dparams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+		_, cparams, _ := mime.ParseMediaType(header.Get("Content-Type"))
+		name := dparams["filename"]
+		if name == "" {
+			name = cparams["name"]
+		}
+		if decoded, err := decoder.DecodeHeader(name); err == nil {
+			name = decoded
+		}
+		switch {
+		case disposition != "attachment" && name == "" && mediaType == "text/plain":
+			text, err := decodeCharset(cparams["charset"], body)
+			if err != nil {
+				return err
+			}
+			plain = append(plain, text)
+		case disposition != "attachment" && name == "" && mediaType == "text/html":
+			text, err := decodeCharset(cparams["charset"], body)
+			if err != nil {
+				return err
+			}
+			html = append(html, docconv.HTML(text))
+		case disposition != "attachment" && header.Get("Content-Id") != "":
+			// 正文引用的内嵌资源
+		default:
+			if name == "" {
+				name = "attachment"
+				if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+					name += exts[0]
+				}
+			}
+			in.Files = append(in.Files, attachment{Name: name, Image: strings.HasPrefix(mediaType, "image/"), Data: body})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	text := strings.Join(plain, "\n\n")
+	if strings.TrimSpace(text) == "" {
+		text = strings.Join(html, "\n\n")
+	}
+	in.Text = stripQuoted(text)
+	return in, nil
+}
+
+// walkPart 遍历 MIME 结构，对每个非 multipart 的部分调用 fn，body 已按 Content-Transfer-Encoding 解码
+func walkPart(header textproto.MIMEHeader, r io.Reader, depth int, fn func(mediaType string, header textproto.MIMEHeader, body []byte) error) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxPartDepth {
+			return fmt.Errorf("MIME parts nested too deep")
+		}
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := walkPart(part.Header, part, depth+1, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("decode %s part: %w", mediaType, err)
+	}
+	return fn(mediaType, header, body)
+}
+
+// isAutomatic 是否为自动发送的邮件（RFC 3834 的 Auto-Submitted、批量和邮件列表、退信），回复这类邮件可能造成循环
+func isAutomatic(h mail.Header, from string) bool {
+	if v := strings.ToLower(h.Get("Auto-Submitted")); v != "" && v != "no" {
+		return true
+	}
+	switch strings.ToLower(h.Get("Precedence")) {
+	case "bulk", "junk", "list":
+		return true
+	}
+	if h.Get("List-Id") != "" || h.Get("X-Autoreply") != "" || h.Get("X-Autorespond") != "" {
+		return true
+	}
+	local, _, _ := strings.Cut(strings.ToLower(from), "@")
+	return local == "mailer-daemon" || local == "postmaster" || strings.HasPrefix(local, "noreply") || strings.HasPrefix(local, "no-reply")
+}
+
+// messageID 去掉 Message-ID 两侧的空白和尖括号
+func messageID(s string) string {
+	return strings.Trim(strings.TrimSpace(s), "<>")
+}
+
+// charsetReader 按字符集解码，用于邮件头
+func charsetReader(charset string, r io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return enc.NewDecoder().Reader(r), nil
+}
+
+// decodeCharset 把正文按字符集转为 UTF-8，未声明字符集时按 UTF-8 处理
+func decodeCharset(charset string, body []byte) (string, error) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii":
+		return string(body), nil
+	}
+	r, err := charsetReader(charset, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	text, err := io.ReadAll(r)
+	return string(text), err
+}
+
+// stripQuoted 去掉回复中引用的历史邮件：以 > 开头的行及其前面的“写道”行，以及 Outlook 的原始邮件分隔线之后的内容。
+// 历史邮件已经在对话中，不需要重复发送给模型
+func stripQuoted(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "-----Original Message-----") || strings.HasPrefix(trimmed, "-----原始邮件-----") ||
+			strings.HasPrefix(trimmed, "------------------ 原始邮件 ------------------") {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			// 引用前的“On ... wrote:”或“在 ... 写道：”
+			n := len(lines)
+			for n > 0 && strings.TrimSpace(lines[n-1]) == "" {
+				n--
+			}
+			if n > 0 && isAttribution(lines[n-1]) {
+				lines = lines[:n-1]
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// isAttribution 是否为引用前的说明行
+func isAttribution(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasSuffix(line, "wrote:") || strings.HasSuffix(line, "写道：") || strings.HasSuffix(line, "写道:")
+}
+
+// buildReply 生成回复邮件，返回新邮件的 Message-ID 和内容：主题加 Re:，In-Reply-To 和 References 指向原邮件以便归入同一会话，
+// 正文后附上引用的原邮件；标记 Auto-Submitted 避免对方的自动回复形成循环
+func buildReply(from *mail.Address, in *inbound, body string, now time.Time) (string, []byte) {
+	_, domain, _ := strings.Cut(from.Address, "@")
+	id := uuid.NewString() + "@" + domain
+
+	subject := in.Subject
+	lower := strings.ToLower(subject)
+	if !strings.HasPrefix(lower, "re:") && !strings.HasPrefix(subject, "回复：") && !strings.HasPrefix(subject, "答复:") {
+		subject = "Re: " + subject
+	}
+	refs := make([]string, 0, len(in.References)+1)
+	for _, ref := range append(slices.Clone(in.References), in.ID) {
+		refs = append(refs, "<"+ref+">")
+	}
+
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", from.String())
+	header("To", (&mail.Address{Address: in.ReplyTo}).String())
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", "<"+id+">")
+	header("In-Reply-To", "<"+in.ID+">")
+	header("References", strings.Join(refs, " "))
+	header("Auto-Submitted", "auto-replied")
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+
+	text := strings.TrimSpace(body)
+	if in.Text != "" {
+		date := ""
+		if !in.Date.IsZero() {
+			date = in.Date.Format("2006-01-02 15:04") + "，"
+		}
+		text += fmt.Sprintf("\n\n在 %s%s 写道：\n", date, in.From)
+		for _, line := range strings.Split(in.Text, "\n") {
+			text += "> " + line + "\n"
+		}
+	}
+	w := quotedprintable.NewWriter(&buf)
+	w.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n")))
+	w.Close()
+	return id, buf.Bytes()
+}
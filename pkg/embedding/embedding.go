@@ -0,0 +1,58 @@
+// Package embedding 生成 RAG 使用的嵌入向量，提供方与聊天模型分开配置：
+// 可以使用聊天的 Ollama 主机、单独的 Ollama 主机（如只有 CPU 的机器），或 OpenAI 兼容的 embeddings 接口
+package embedding
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/ollama"
+)
+
+// 提供方
+const (
+	ProviderOllama = "ollama"
+	ProviderOpenAI = "openai"
+)
+
+// Func 生成文本的嵌入向量
+type Func func(ctx context.Context, text string) ([]float32, error)
+
+// New 按配置创建嵌入函数：ollama 未指定主机时使用聊天的主机池 pool，指定主机时以 opts 连接该主机
+func New(cfg config.EmbeddingConfig, model string, pool *ollama.Pool, opts ollama.Options) (Func, error) {
+	switch cfg.Provider {
+	case "", ProviderOllama:
+		if cfg.Host == "" {
+			return func(ctx context.Context, text string) ([]float32, error) {
+				return pool.Embed(ctx, model, text)
+			}, nil
+		}
+		opts.ResponseTimeout = cfg.Timeout
+		client, err := ollama.NewClient(cfg.Host, model, opts)
+		if err != nil {
+			return nil, fmt.Errorf("embedding host %s: %w", cfg.Host, err)
+		}
+		klog.InfoS("Using separate Ollama host for embeddings", "host", cfg.Host, "model", model)
+		return func(ctx context.Context, text string) ([]float32, error) {
+			return client.Embed(ctx, model, text)
+		}, nil
+	case ProviderOpenAI:
+		o, err := newOpenAI(cfg, model)
+		if err != nil {
+			return nil, err
+		}
+		klog.InfoS("Using OpenAI-compatible embeddings", "url", cfg.Host, "model", model)
+		return o.embed, nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider: %s", cfg.Provider)
+	}
+}
+
+// Separate 嵌入模型是否不在聊天的 Ollama 主机上（单独的主机或其他提供方），
+// 此时不能通过聊天主机查询、预热嵌入模型
+func Separate(cfg config.EmbeddingConfig) bool {
+	return cfg.Provider == ProviderOpenAI || cfg.Host != ""
+}
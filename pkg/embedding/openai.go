@@ -0,0 +1,97 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/egress"
+)
+
+// maxErrorBody 错误响应中保留的最大字节数
+const maxErrorBody = 1024
+
+// openAI OpenAI 兼容的 embeddings 接口（OpenAI、vLLM、LocalAI、llama.cpp server、TEI 等）
+type openAI struct {
+	url        string
+	apiKey     string
+	model      string
+	dimensions int
+	client     *http.Client
+}
+
+// newOpenAI 创建客户端，cfg.Host 为接口的基础地址（包含 /v1）。
+// 请求经过只允许该主机的出站策略：配置的地址可信（通常是本机或内网的推理服务），不受内部地址限制，
+// 重定向到其他主机时被拒绝
+func newOpenAI(cfg config.EmbeddingConfig, model string) (*openAI, error) {
+	u, err := url.Parse(cfg.Host)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid embedding host %q", cfg.Host)
+	}
+	policy, err := egress.New(config.EgressConfig{AllowedHosts: []string{u.Hostname()}})
+	if err != nil {
+		return nil, err
+	}
+	return &openAI{
+		url:        strings.TrimSuffix(cfg.Host, "/") + "/embeddings",
+		apiKey:     cfg.APIKey,
+		model:      model,
+		dimensions: cfg.Dimensions,
+		client:     policy.Client(cfg.Timeout),
+	}, nil
+}
+
+// embeddingRequest POST /embeddings 的请求体
+type embeddingRequest struct {
+	Model      string `json:"model"`
+	Input      string `json:"input"`
+	Dimensions int    `json:"dimensions,omitempty"`
+}
+
+// embeddingResponse POST /embeddings 的响应体
+type embeddingResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// embed 生成文本的嵌入向量
+func (o *openAI) embed(ctx context.Context, text string) ([]float32, error) {
+	body, err := json.Marshal(embeddingRequest{Model: o.model, Input: text, Dimensions: o.dimensions})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embeddings request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, fmt.Errorf("embeddings request: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode embeddings response: %w", err)
+	}
+	if len(result.Data) == 0 {
+		return nil, nil
+	}
+	return result.Data[0].Embedding, nil
+}
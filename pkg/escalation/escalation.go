@@ -0,0 +1,198 @@
+// Package escalation 小模型优先、按需升级：先由响应快的小模型回答，回答看起来不可靠时
+// （空回答、含糊其辞、被截断或模型评分过低）改由能力更强的大模型重新回答，
+// 适合同时部署一个强模型和一个快模型的本地环境
+package escalation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// 回答的来源
+const (
+	// PathSmall 小模型的回答通过检查，直接返回
+	PathSmall = "small"
+	// PathLarge 小模型的回答未通过检查，升级到大模型
+	PathLarge = "large"
+)
+
+// 检查器
+const (
+	CheckerHeuristic = "heuristic"
+	CheckerModel     = "model"
+)
+
+// Answer 待检查的小模型回答
+type Answer struct {
+	Question string
+	Content  string
+	// Truncated 回答因长度限制被截断
+	Truncated bool
+}
+
+// Checker 判断小模型的回答是否可靠，不可靠时返回原因
+type Checker interface {
+	Check(ctx context.Context, answer Answer) (ok bool, reason string, err error)
+}
+
+// ChatFunc 调用模型，传入提示词返回回答
+type ChatFunc func(ctx context.Context, prompt string) (string, error)
+
+// Stats 各路径返回的回答数
+type Stats struct {
+	Small     int64 `json:"small"`
+	Escalated int64 `json:"escalated"`
+}
+
+// Escalator 小模型优先的升级策略，nil 表示未启用
+type Escalator struct {
+	small, large string
+	checker      Checker
+
+	served, escalated atomic.Int64
+}
+
+// New 按配置创建升级策略，未启用时返回 nil；chat 用于 model 检查器
+func New(cfg config.EscalationConfig, chat ChatFunc) (*Escalator, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.SmallModel == "" || cfg.LargeModel == "" {
+		return nil, fmt.Errorf("escalation: small_model and large_model are required")
+	}
+
+	e := &Escalator{small: cfg.SmallModel, large: cfg.LargeModel}
+	switch cfg.Checker {
+	case "", CheckerHeuristic:
+		e.checker = heuristicChecker{}
+	case CheckerModel:
+		if cfg.MinScore < 1 || cfg.MinScore > 10 {
+			return nil, fmt.Errorf("escalation: min_score must be between 1 and 10")
+		}
+		e.checker = &modelChecker{chat: chat, minScore: cfg.MinScore}
+	default:
+		return nil, fmt.Errorf("unknown escalation checker: %s", cfg.Checker)
+	}
+
+	klog.InfoS("Model escalation enabled", "small", cfg.SmallModel, "large", cfg.LargeModel, "checker", cfg.Checker)
+	return e, nil
+}
+
+// SmallModel 先回答的小模型
+func (e *Escalator) SmallModel() string {
+	return e.small
+}
+
+// LargeModel 升级后使用的大模型
+func (e *Escalator) LargeModel() string {
+	return e.large
+}
+
+// ShouldEscalate 检查小模型的回答，需要升级到大模型时返回 true 和原因；检查失败时同样升级
+func (e *Escalator) ShouldEscalate(ctx context.Context, answer Answer) (bool, string) {
+	ok, reason, err := e.checker.Check(ctx, answer)
+	if err != nil {
+		klog.ErrorS(err, "Failed to check small model answer, escalating")
+		ok, reason = false, "check failed"
+	}
+	if ok {
+		e.served.Add(1)
+		return false, ""
+	}
+	e.escalated.Add(1)
+	return true, reason
+}
+
+// Stats 返回各路径返回的回答数，未启用时为零值
+func (e *Escalator) Stats() Stats {
+	if e == nil {
+		return Stats{}
+	}
+	return Stats{Small: e.served.Load(), Escalated: e.escalated.Load()}
+}
+
+// hedgePattern 表示不确定、无法回答的表述
+var hedgePattern = regexp.MustCompile(`(?i)\b(i'?m not sure|i am not sure|i don'?t know|i do not know|i(?: a|')m unable to|i cannot (?:answer|determine|help)|not enough information)\b|不确定|不知道|无法回答|无法确定|不清楚|没有足够的信息`)
+
+// heuristicChecker 基于规则的检查器，不增加模型调用
+type heuristicChecker struct{}
+
+// Check 实现 Checker
+func (heuristicChecker) Check(_ context.Context, answer Answer) (bool, string, error) {
+	if reason := incomplete(answer); reason != "" {
+		return false, reason, nil
+	}
+	if hedgePattern.MatchString(answer.Content) {
+		return false, "answer is uncertain", nil
+	}
+	return true, "", nil
+}
+
+// incomplete 回答为空或被截断时返回原因，这类回答不需要评估内容
+func incomplete(answer Answer) string {
+	switch {
+	case strings.TrimSpace(answer.Content) == "":
+		return "empty answer"
+	case answer.Truncated:
+		return "answer truncated"
+	}
+	return ""
+}
+
+// maxCheckLength 交给模型检查的问题和回答的最大字符数
+const maxCheckLength = 4000
+
+// checkPrompt 模型检查提示词
+const checkPrompt = `评估下面的回答是否准确、完整地回答了问题。给出 1 到 10 的分数（10 表示完全可靠），只输出分数。
+
+问题：
+%s
+
+回答：
+%s`
+
+// scorePattern 模型输出中的分数
+var scorePattern = regexp.MustCompile(`\d+`)
+
+// modelChecker 使用模型评分的检查器，比规则更准确但会增加一次模型调用
+type modelChecker struct {
+	chat     ChatFunc
+	minScore int
+}
+
+// Check 实现 Checker，空回答和被截断的回答不经模型评分直接升级
+func (c *modelChecker) Check(ctx context.Context, answer Answer) (bool, string, error) {
+	if reason := incomplete(answer); reason != "" {
+		return false, reason, nil
+	}
+
+	output, err := c.chat(ctx, fmt.Sprintf(checkPrompt, truncate(answer.Question), truncate(answer.Content)))
+	if err != nil {
+		return false, "", fmt.Errorf("check with model: %w", err)
+	}
+	match := scorePattern.FindString(output)
+	if match == "" {
+		return false, "", fmt.Errorf("no score in checker output: %q", output)
+	}
+	score, _ := strconv.Atoi(match)
+	if score < c.minScore {
+		return false, fmt.Sprintf("score %d below %d", score, c.minScore), nil
+	}
+	return true, "", nil
+}
+
+// truncate 截取前 maxCheckLength 个字符
+func truncate(s string) string {
+	if runes := []rune(s); len(runes) > maxCheckLength {
+		return string(runes[:maxCheckLength])
+	}
+	return s
+}
@@ -0,0 +1,282 @@
+// Package exectools 提供在运行中的容器内执行命令的 MCP 工具（docker exec / kubectl exec）
+package exectools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/k8stools"
+)
+
+const (
+	// RuntimeDocker 通过 docker exec 执行
+	RuntimeDocker = "docker"
+	// RuntimeKubernetes 通过 Kubernetes exec 子资源执行
+	RuntimeKubernetes = "kubernetes"
+
+	// defaultTimeout 默认命令超时
+	defaultTimeout = 30 * time.Second
+	// defaultMaxOutput 默认输出上限（字节）
+	defaultMaxOutput = 64 << 10
+)
+
+// Config 容器执行工具配置
+type Config struct {
+	Runtime       string        // docker 或 kubernetes
+	AllowedImages []string      // 允许执行的镜像，支持结尾 * 的前缀匹配，"*" 表示全部；为空时拒绝所有
+	Namespaces    []string      // kubernetes 运行时允许的命名空间，为空时仅允许当前命名空间，"*" 表示全部
+	Kubeconfig    string        // kubeconfig 路径
+	Context       string        // kubeconfig 上下文
+	Timeout       time.Duration // 单条命令最大执行时间
+	MaxOutput     int           // stdout/stderr 各自的最大返回字节数
+}
+
+// Toolset 容器执行工具集
+type Toolset struct {
+	cfg              Config
+	defaultNamespace string
+	restConfig       *rest.Config
+	clientset        kubernetes.Interface
+}
+
+// New 创建容器执行工具集
+func New(cfg Config) (*Toolset, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxOutput <= 0 {
+		cfg.MaxOutput = defaultMaxOutput
+	}
+
+	t := &Toolset{cfg: cfg}
+	switch cfg.Runtime {
+	case RuntimeDocker:
+		if _, err := exec.LookPath("docker"); err != nil {
+			return nil, fmt.Errorf("docker runtime: %w", err)
+		}
+	case RuntimeKubernetes:
+		restCfg, namespace, err := k8stools.LoadRESTConfig(cfg.Kubeconfig, cfg.Context)
+		if err != nil {
+			return nil, err
+		}
+		clientset, err := kubernetes.NewForConfig(restCfg)
+		if err != nil {
+			return nil, fmt.Errorf("create kubernetes client: %w", err)
+		}
+		t.restConfig = restCfg
+		t.clientset = clientset
+		t.defaultNamespace = namespace
+		if len(t.cfg.Namespaces) == 0 {
+			t.cfg.Namespaces = []string{namespace}
+		}
+	default:
+		return nil, fmt.Errorf("unknown exec runtime: %s", cfg.Runtime)
+	}
+
+	klog.InfoS("Container exec toolset created", "runtime", cfg.Runtime, "allowedImages", cfg.AllowedImages)
+	return t, nil
+}
+
+// ExecInput container_exec 的输入
+type ExecInput struct {
+	Target         string   `json:"target" jsonschema:"docker 运行时为容器名称或 ID；kubernetes 运行时为 Pod 名称"`
+	Container      string   `json:"container,omitempty" jsonschema:"Pod 内的容器名称（kubernetes 运行时，多容器 Pod 必填）"`
+	Namespace      string   `json:"namespace,omitempty" jsonschema:"命名空间（kubernetes 运行时，默认为当前命名空间）"`
+	Command        []string `json:"command" jsonschema:"要执行的命令及参数，如 [\"cat\", \"/etc/hosts\"]，不经过 shell"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty" jsonschema:"超时时间（秒），不超过服务端上限"`
+}
+
+// ExecOutput container_exec 的输出
+type ExecOutput struct {
+	Stdout    string `json:"stdout" jsonschema:"标准输出"`
+	Stderr    string `json:"stderr,omitempty" jsonschema:"标准错误"`
+	ExitCode  int    `json:"exit_code" jsonschema:"退出码"`
+	Truncated bool   `json:"truncated,omitempty" jsonschema:"输出是否被截断"`
+}
+
+// Register 将工具注册到 MCP Server
+func (t *Toolset) Register(server *mcp.Server) {
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "container_exec",
+		Description: fmt.Sprintf("在运行中的容器内执行命令（%s），用于检查进程、配置文件、网络连通性等", t.cfg.Runtime),
+	}, t.handleExec)
+}
+
+// handleExec 在容器内执行命令
+func (t *Toolset) handleExec(ctx context.Context, req *mcp.CallToolRequest, input ExecInput) (*mcp.CallToolResult, ExecOutput, error) {
+	klog.InfoS("MCP tool called: container_exec", "runtime", t.cfg.Runtime, "target", input.Target,
+		"namespace", input.Namespace, "container", input.Container, "command", input.Command)
+
+	if input.Target == "" {
+		return nil, ExecOutput{}, fmt.Errorf("target is required")
+	}
+	if len(input.Command) == 0 {
+		return nil, ExecOutput{}, fmt.Errorf("command is required")
+	}
+
+	timeout := t.cfg.Timeout
+	if d := time.Duration(input.TimeoutSeconds) * time.Second; d > 0 && d < timeout {
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{limit: t.cfg.MaxOutput}
+	stderr := &limitedBuffer{limit: t.cfg.MaxOutput}
+
+	var exitCode int
+	var err error
+	if t.cfg.Runtime == RuntimeDocker {
+		exitCode, err = t.dockerExec(ctx, input, stdout, stderr)
+	} else {
+		exitCode, err = t.kubeExec(ctx, input, stdout, stderr)
+	}
+	if err != nil {
+		return nil, ExecOutput{}, err
+	}
+
+	return nil, ExecOutput{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		ExitCode:  exitCode,
+		Truncated: stdout.truncated || stderr.truncated,
+	}, nil
+}
+
+// dockerExec 通过 docker CLI 执行命令
+func (t *Toolset) dockerExec(ctx context.Context, input ExecInput, stdout, stderr *limitedBuffer) (int, error) {
+	out, err := exec.CommandContext(ctx, "docker", "inspect", "--format", "{{.Config.Image}}", input.Target).Output()
+	if err != nil {
+		return 0, fmt.Errorf("inspect container %s: %w", input.Target, err)
+	}
+	if err := t.checkImage(strings.TrimSpace(string(out))); err != nil {
+		return 0, err
+	}
+
+	args := append([]string{"exec", input.Target}, input.Command...)
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && ctx.Err() == nil {
+			return exitErr.ExitCode(), nil
+		}
+		return 0, fmt.Errorf("docker exec in %s: %w", input.Target, err)
+	}
+	return 0, nil
+}
+
+// kubeExec 通过 Pod exec 子资源执行命令
+func (t *Toolset) kubeExec(ctx context.Context, input ExecInput, stdout, stderr *limitedBuffer) (int, error) {
+	namespace := input.Namespace
+	if namespace == "" {
+		namespace = t.defaultNamespace
+	}
+	if !slices.Contains(t.cfg.Namespaces, k8stools.AllNamespaces) && !slices.Contains(t.cfg.Namespaces, namespace) {
+		return 0, fmt.Errorf("access denied: namespace %q is not allowed (allowed: %s)", namespace, strings.Join(t.cfg.Namespaces, ","))
+	}
+
+	pod, err := t.clientset.CoreV1().Pods(namespace).Get(ctx, input.Target, metav1.GetOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("get pod %s: %w", input.Target, err)
+	}
+	container, err := findContainer(pod, input.Container)
+	if err != nil {
+		return 0, err
+	}
+	if err := t.checkImage(container.Image); err != nil {
+		return 0, err
+	}
+
+	execReq := t.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(input.Target).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container.Name,
+			Command:   input.Command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(t.restConfig, "POST", execReq.URL())
+	if err != nil {
+		return 0, fmt.Errorf("create executor: %w", err)
+	}
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: stdout, Stderr: stderr})
+	if err != nil {
+		if exitErr, ok := err.(interface{ ExitStatus() int }); ok {
+			return exitErr.ExitStatus(), nil
+		}
+		return 0, fmt.Errorf("exec in %s/%s: %w", input.Target, container.Name, err)
+	}
+	return 0, nil
+}
+
+// findContainer 查找 Pod 内的目标容器，单容器 Pod 可省略名称
+func findContainer(pod *corev1.Pod, name string) (*corev1.Container, error) {
+	if name == "" {
+		if len(pod.Spec.Containers) != 1 {
+			return nil, fmt.Errorf("pod %s has %d containers, container is required", pod.Name, len(pod.Spec.Containers))
+		}
+		return &pod.Spec.Containers[0], nil
+	}
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == name {
+			return &pod.Spec.Containers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("container %q not found in pod %s", name, pod.Name)
+}
+
+// checkImage 校验镜像是否在允许列表内
+func (t *Toolset) checkImage(image string) error {
+	for _, pattern := range t.cfg.AllowedImages {
+		if matchImage(pattern, image) {
+			return nil
+		}
+	}
+	return fmt.Errorf("access denied: image %q is not allowed", image)
+}
+
+// matchImage 匹配镜像：结尾 * 为前缀匹配，否则匹配完整镜像或不带 tag/digest 的仓库名
+func matchImage(pattern, image string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(image, prefix)
+	}
+	return image == pattern || strings.HasPrefix(image, pattern+":") || strings.HasPrefix(image, pattern+"@")
+}
+
+// limitedBuffer 超过上限后丢弃后续输出的缓冲区
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write 实现 io.Writer，始终返回完整长度以免中断命令
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := b.limit - b.Len(); remain < len(p) {
+		b.truncated = true
+		if remain > 0 {
+			b.Buffer.Write(p[:remain])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,202 @@
+// Package experiment 提示 A/B 实验：按配置的比例把请求分配到替代的系统提示或模板，
+// 同一对话始终分配到同一变体；按变体统计成功率、模型调用轮数、工具调用次数和用户反馈。
+// 统计保存在内存中，重启后清零
+package experiment
+
+import (
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// Control 对照组：未分配到任何变体的请求，按原样处理
+const Control = "control"
+
+// ErrNotAssigned 对话没有参与实验，不能提交反馈
+var ErrNotAssigned = errors.New("conversation is not part of an experiment")
+
+// Assignment 请求分配到的实验变体，Experiment 为空表示未参与实验
+type Assignment struct {
+	Experiment string `json:"experiment,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+	System     string `json:"-"` // 替代的系统提示
+	Template   string `json:"-"` // 替代的模板
+}
+
+// Stats 变体的统计
+type Stats struct {
+	Variant   string  `json:"variant"`
+	Fraction  float64 `json:"fraction"`
+	Requests  int64   `json:"requests"`
+	Failures  int64   `json:"failures"`
+	ToolCalls int64   `json:"tool_calls"`
+	// Iterations 成功请求的模型调用轮数之和，每轮可能包含多个工具调用
+	Iterations int64 `json:"iterations"`
+	Positive   int64 `json:"positive"`
+	Negative   int64 `json:"negative"`
+
+	SuccessRate   float64 `json:"success_rate"`
+	AvgIterations float64 `json:"avg_iterations"`
+	// PositiveRate 正面反馈占全部反馈的比例，没有反馈时为 0
+	PositiveRate float64 `json:"positive_rate"`
+}
+
+// Report 实验的配置和各变体的统计，第一项为对照组
+type Report struct {
+	Name     string    `json:"name"`
+	Template string    `json:"template,omitempty"`
+	Since    time.Time `json:"since"`
+	Variants []Stats   `json:"variants"`
+}
+
+// experiment 单个实验
+type experiment struct {
+	cfg   config.ExperimentConfig
+	since time.Time
+	stats map[string]*Stats
+}
+
+// Experiments 所有实验，nil 表示未配置
+type Experiments struct {
+	mu          sync.Mutex
+	experiments []*experiment
+	byTemplate  map[string]*experiment
+}
+
+// New 创建实验，未配置时返回 nil
+func New(cfgs []config.ExperimentConfig) *Experiments {
+	if len(cfgs) == 0 {
+		return nil
+	}
+	e := &Experiments{byTemplate: make(map[string]*experiment, len(cfgs))}
+	for _, cfg := range cfgs {
+		exp := &experiment{cfg: cfg, since: time.Now(), stats: make(map[string]*Stats, len(cfg.Variants)+1)}
+		control := 1.0
+		for _, v := range cfg.Variants {
+			exp.stats[v.Name] = &Stats{Variant: v.Name, Fraction: v.Fraction}
+			control -= v.Fraction
+		}
+		exp.stats[Control] = &Stats{Variant: Control, Fraction: max(control, 0)}
+		e.experiments = append(e.experiments, exp)
+		e.byTemplate[cfg.Template] = exp
+	}
+	return e
+}
+
+// Assign 为使用模板 template（空表示未指定模板）的请求分配变体，key 相同时分配结果相同（通常为对话 ID）
+func (e *Experiments) Assign(template, key string) Assignment {
+	if e == nil {
+		return Assignment{}
+	}
+	exp, ok := e.byTemplate[template]
+	if !ok {
+		return Assignment{}
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(exp.cfg.Name + "\x00" + key))
+	bucket := float64(h.Sum64()%10000) / 10000
+	var acc float64
+	for _, v := range exp.cfg.Variants {
+		acc += v.Fraction
+		if bucket < acc {
+			return Assignment{Experiment: exp.cfg.Name, Variant: v.Name, System: v.System, Template: v.Template}
+		}
+	}
+	return Assignment{Experiment: exp.cfg.Name, Variant: Control}
+}
+
+// stats 返回分配结果对应的统计，调用方需持有 mu
+func (e *Experiments) stats(a Assignment) *Stats {
+	if e == nil || a.Experiment == "" {
+		return nil
+	}
+	for _, exp := range e.experiments {
+		if exp.cfg.Name == a.Experiment {
+			return exp.stats[a.Variant]
+		}
+	}
+	return nil
+}
+
+// Record 记录一次请求的结果：iterations 为模型调用轮数，toolCalls 为工具调用次数，err 不为 nil 时计为失败
+func (e *Experiments) Record(a Assignment, iterations, toolCalls int, err error) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.stats(a)
+	if s == nil {
+		return
+	}
+	s.Requests++
+	if err != nil {
+		s.Failures++
+		return
+	}
+	s.Iterations += int64(iterations)
+	s.ToolCalls += int64(toolCalls)
+}
+
+// Feedback 记录用户反馈：rating 为 1（正面）或 -1（负面），previous 为该对话之前的反馈（没有时为 0），
+// 同一对话修改反馈时先撤销之前的
+func (e *Experiments) Feedback(a Assignment, rating, previous int) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.stats(a)
+	if s == nil {
+		return
+	}
+	switch previous {
+	case 1:
+		s.Positive--
+	case -1:
+		s.Negative--
+	}
+	switch rating {
+	case 1:
+		s.Positive++
+	case -1:
+		s.Negative++
+	}
+}
+
+// Report 返回所有实验的统计，按配置顺序排列
+func (e *Experiments) Report() []Report {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	reports := make([]Report, 0, len(e.experiments))
+	for _, exp := range e.experiments {
+		r := Report{Name: exp.cfg.Name, Template: exp.cfg.Template, Since: exp.since}
+		names := []string{Control}
+		for _, v := range exp.cfg.Variants {
+			names = append(names, v.Name)
+		}
+		for _, name := range names {
+			s := *exp.stats[name]
+			if s.Requests > 0 {
+				s.SuccessRate = float64(s.Requests-s.Failures) / float64(s.Requests)
+			}
+			if succeeded := s.Requests - s.Failures; succeeded > 0 {
+				s.AvgIterations = float64(s.Iterations) / float64(succeeded)
+			}
+			if total := s.Positive + s.Negative; total > 0 {
+				s.PositiveRate = float64(s.Positive) / float64(total)
+			}
+			r.Variants = append(r.Variants, s)
+		}
+		reports = append(reports, r)
+	}
+	return reports
+}
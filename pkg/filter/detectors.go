@@ -0,0 +1,101 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 内置检测器名称
+const (
+	DetectorEmail      = "email"
+	DetectorAPIKey     = "api_key"
+	DetectorPrivateKey = "private_key"
+	DetectorJWT        = "jwt"
+	DetectorCreditCard = "credit_card"
+	DetectorCNMobile   = "cn_mobile"
+	DetectorCNIDCard   = "cn_id_card"
+)
+
+var (
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	privateKeyPattern = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)
+	jwtPattern        = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]+`)
+	cnMobilePattern   = regexp.MustCompile(`\b1[3-9]\d{9}\b`)
+	cnIDCardPattern   = regexp.MustCompile(`\b\d{17}[\dXx]\b`)
+	cardPattern       = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+	// apiKeyPattern 常见云厂商和 SaaS 的密钥格式，以及 key=value 形式的凭证
+	apiKeyPattern = regexp.MustCompile(strings.Join([]string{
+		`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,    // AWS Access Key
+		`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,   // GitHub Token
+		`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`, // Slack Token
+		`\bsk-[A-Za-z0-9_-]{20,}\b`,        // OpenAI 等 sk- 前缀密钥
+		`\bAIza[0-9A-Za-z_-]{35}\b`,        // Google API Key
+		`\bglpat-[A-Za-z0-9_-]{20,}\b`,     // GitLab Token
+		`(?i:\b(?:api[_-]?key|secret|token|password|passwd)\b["']?\s*[:=]\s*["']?[^\s"',;]{8,})`, // key=value
+	}, "|"))
+)
+
+func init() {
+	RegisterDetector(DetectorEmail, regexDetector{re: emailPattern})
+	RegisterDetector(DetectorAPIKey, regexDetector{re: apiKeyPattern})
+	RegisterDetector(DetectorPrivateKey, regexDetector{re: privateKeyPattern})
+	RegisterDetector(DetectorJWT, regexDetector{re: jwtPattern})
+	RegisterDetector(DetectorCNMobile, regexDetector{re: cnMobilePattern})
+	RegisterDetector(DetectorCreditCard, DetectorFunc(findCreditCards))
+	RegisterDetector(DetectorCNIDCard, DetectorFunc(findCNIDCards))
+}
+
+// findCreditCards 查找通过 Luhn 校验的银行卡号，减少对普通长数字的误判
+func findCreditCards(text string) [][]int {
+	var result [][]int
+	for _, m := range cardPattern.FindAllStringIndex(text, -1) {
+		digits := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, text[m[0]:m[1]])
+		if len(digits) >= 13 && len(digits) <= 19 && luhnValid(digits) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// luhnValid Luhn 校验
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// cnIDWeights 身份证校验码加权因子
+var cnIDWeights = [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+// findCNIDCards 查找通过校验码验证的 18 位居民身份证号
+func findCNIDCards(text string) [][]int {
+	var result [][]int
+	for _, m := range cnIDCardPattern.FindAllStringIndex(text, -1) {
+		id := strings.ToUpper(text[m[0]:m[1]])
+		sum := 0
+		for i, w := range cnIDWeights {
+			sum += int(id[i]-'0') * w
+		}
+		if "10X98765432"[sum%11] == id[17] {
+			result = append(result, m)
+		}
+	}
+	return result
+}
@@ -0,0 +1,189 @@
+// Package filter 实现内容过滤：在用户消息、工具结果和模型输出进入日志、模型或返回客户端之前，
+// 按规则脱敏或拦截密钥、API Key 和个人信息
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// ErrBlocked 内容被过滤规则拦截
+var ErrBlocked = errors.New("blocked by content filter")
+
+const (
+	// StageInput 用户消息
+	StageInput = "input"
+	// StageToolResult 工具返回结果
+	StageToolResult = "tool_result"
+	// StageOutput 模型输出
+	StageOutput = "output"
+
+	// ActionRedact 替换匹配内容
+	ActionRedact = "redact"
+	// ActionBlock 拦截整条内容
+	ActionBlock = "block"
+)
+
+// Detector 敏感内容检测器，返回所有匹配区间 [start, end)
+type Detector interface {
+	Find(text string) [][]int
+}
+
+// DetectorFunc 函数形式的检测器
+type DetectorFunc func(text string) [][]int
+
+// Find 实现 Detector
+func (f DetectorFunc) Find(text string) [][]int {
+	return f(text)
+}
+
+// regexDetector 基于正则表达式的检测器
+type regexDetector struct {
+	re *regexp.Regexp
+}
+
+// Find 实现 Detector
+func (d regexDetector) Find(text string) [][]int {
+	return d.re.FindAllStringIndex(text, -1)
+}
+
+var (
+	detectorsMu sync.RWMutex
+	detectors   = make(map[string]Detector)
+)
+
+// RegisterDetector 注册自定义检测器，配置中通过 detector 字段引用；需在 New 之前调用
+func RegisterDetector(name string, d Detector) {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	detectors[name] = d
+}
+
+// LookupDetector 查找已注册的检测器
+func LookupDetector(name string) (Detector, bool) {
+	detectorsMu.RLock()
+	defer detectorsMu.RUnlock()
+	d, ok := detectors[name]
+	return d, ok
+}
+
+// rule 编译后的过滤规则
+type rule struct {
+	name        string
+	detector    Detector
+	block       bool
+	stages      []string
+	replacement string
+}
+
+// Pipeline 过滤流水线，nil 表示不过滤
+type Pipeline struct {
+	rules []*rule
+}
+
+// New 编译过滤规则
+func New(cfg config.FilterConfig) (*Pipeline, error) {
+	p := &Pipeline{}
+	for i, rc := range cfg.Rules {
+		name := rc.Name
+		if name == "" {
+			name = rc.Detector
+		}
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i)
+		}
+		r := &rule{name: name, stages: rc.Stages, replacement: rc.Replacement}
+
+		switch {
+		case rc.Detector != "" && rc.Pattern != "":
+			return nil, fmt.Errorf("filter rule %s: detector and pattern are mutually exclusive", name)
+		case rc.Detector != "":
+			d, ok := LookupDetector(rc.Detector)
+			if !ok {
+				return nil, fmt.Errorf("filter rule %s: unknown detector %q", name, rc.Detector)
+			}
+			r.detector = d
+		case rc.Pattern != "":
+			re, err := regexp.Compile(rc.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("filter rule %s: %w", name, err)
+			}
+			r.detector = regexDetector{re: re}
+		default:
+			return nil, fmt.Errorf("filter rule %s: detector or pattern is required", name)
+		}
+
+		switch rc.Action {
+		case "", ActionRedact:
+		case ActionBlock:
+			r.block = true
+		default:
+			return nil, fmt.Errorf("filter rule %s: invalid action %q", name, rc.Action)
+		}
+		for _, stage := range rc.Stages {
+			if stage != StageInput && stage != StageToolResult && stage != StageOutput {
+				return nil, fmt.Errorf("filter rule %s: invalid stage %q", name, stage)
+			}
+		}
+		if r.replacement == "" {
+			r.replacement = "[REDACTED:" + name + "]"
+		}
+		p.rules = append(p.rules, r)
+	}
+
+	if len(p.rules) > 0 {
+		klog.InfoS("Content filters loaded", "rules", len(p.rules))
+	}
+	return p, nil
+}
+
+// Apply 按顺序对指定阶段的内容执行规则：redact 规则替换匹配内容，block 规则匹配时返回包装了 ErrBlocked 的错误
+func (p *Pipeline) Apply(stage, text string) (string, error) {
+	if p == nil || text == "" {
+		return text, nil
+	}
+
+	for _, r := range p.rules {
+		if len(r.stages) > 0 && !slices.Contains(r.stages, stage) {
+			continue
+		}
+		matches := r.detector.Find(text)
+		if len(matches) == 0 {
+			continue
+		}
+
+		if r.block {
+			klog.InfoS("Content blocked by filter", "rule", r.name, "stage", stage)
+			return "", fmt.Errorf("%w: %s content matched rule %s", ErrBlocked, stage, r.name)
+		}
+		klog.V(2).InfoS("Content redacted by filter", "rule", r.name, "stage", stage, "matches", len(matches))
+		text = redact(text, matches, r.replacement)
+	}
+	return text, nil
+}
+
+// redact 将匹配区间替换为 replacement，与前一区间重叠的匹配被忽略
+func redact(text string, matches [][]int, replacement string) string {
+	slices.SortFunc(matches, func(a, b []int) int { return a[0] - b[0] })
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		if m[0] < last {
+			continue
+		}
+		b.WriteString(text[last:m[0]])
+		b.WriteString(replacement)
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
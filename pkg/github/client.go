@@ -0,0 +1,330 @@
+// Package github GitHub REST API 的最小客户端：读取拉取请求、改动和文件内容，发表审查。
+// 支持 token 和 GitHub App（按仓库取安装 token）两种认证方式，并提供 webhook 签名校验和审查范围（仓库、路径）的匹配
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+const (
+	// apiTimeout 单次请求超时
+	apiTimeout = 30 * time.Second
+	// maxResponseBytes 响应体的大小上限
+	maxResponseBytes = 16 << 20
+	// maxFilePages 读取改动文件列表的最大页数（每页 100 个，与 GitHub 的 3000 个文件上限一致）
+	maxFilePages = 30
+)
+
+// APIError GitHub 返回的错误
+type APIError struct {
+	Status  int
+	Message string
+}
+
+// Error 实现 error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("github: %d %s", e.Status, e.Message)
+}
+
+// Client GitHub REST API 客户端
+type Client struct {
+	cfg  config.GitHubConfig
+	http *http.Client
+	key  *rsa.PrivateKey // GitHub App 私钥，使用 token 时为 nil
+
+	mu            sync.Mutex
+	installations map[string]int64            // 仓库 -> App 安装 ID
+	tokens        map[int64]installationToken // 安装 ID -> 安装 token
+}
+
+// installationToken App 安装 token，有效期 1 小时
+type installationToken struct {
+	token   string
+	expires time.Time
+}
+
+// New 创建客户端，配置了 app_id 时解析 App 私钥
+func New(cfg config.GitHubConfig) (*Client, error) {
+	c := &Client{
+		cfg:           cfg,
+		http:          &http.Client{Timeout: apiTimeout},
+		installations: make(map[string]int64),
+		tokens:        make(map[int64]installationToken),
+	}
+	if cfg.AppID != 0 {
+		key, err := parsePrivateKey(cfg.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("parse github app private key: %w", err)
+		}
+		c.key = key
+	}
+	return c, nil
+}
+
+// parsePrivateKey 解析 PEM 格式的 RSA 私钥（GitHub 下载的为 PKCS#1，也接受 PKCS#8）
+func parsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+	return rsaKey, nil
+}
+
+// PullRequest 拉取请求
+type PullRequest struct {
+	Number       int    `json:"number"`
+	Title        string `json:"title"`
+	Body         string `json:"body"`
+	State        string `json:"state"`
+	Draft        bool   `json:"draft"`
+	HTMLURL      string `json:"html_url"`
+	Additions    int    `json:"additions"`
+	Deletions    int    `json:"deletions"`
+	ChangedFiles int    `json:"changed_files"`
+	User         struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Head struct {
+		SHA string `json:"sha"`
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+// File 拉取请求改动的文件
+type File struct {
+	Filename  string `json:"filename"`
+	Status    string `json:"status"` // added、modified、removed、renamed 等
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Patch     string `json:"patch"` // 统一 diff 格式的改动，二进制或过大的文件为空
+}
+
+// Review 审查，Event 为 COMMENT、APPROVE 或 REQUEST_CHANGES
+type Review struct {
+	CommitID string          `json:"commit_id,omitempty"`
+	Body     string          `json:"body"`
+	Event    string          `json:"event"`
+	Comments []ReviewComment `json:"comments,omitempty"`
+}
+
+// ReviewComment 审查中的行内评论，Line 为新文件中的行号
+type ReviewComment struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Side string `json:"side"`
+	Body string `json:"body"`
+}
+
+// PullRequest 读取拉取请求
+func (c *Client) PullRequest(ctx context.Context, repo string, number int) (*PullRequest, error) {
+	var pr PullRequest
+	if err := c.do(ctx, http.MethodGet, repo, fmt.Sprintf("/pulls/%d", number), nil, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// Files 读取拉取请求改动的文件，最多 limit 个
+func (c *Client) Files(ctx context.Context, repo string, number, limit int) ([]File, error) {
+	var files []File
+	for page := 1; page <= maxFilePages && len(files) < limit; page++ {
+		var batch []File
+		if err := c.do(ctx, http.MethodGet, repo, fmt.Sprintf("/pulls/%d/files?per_page=100&page=%d", number, page), nil, &batch); err != nil {
+			return nil, err
+		}
+		files = append(files, batch...)
+		if len(batch) < 100 {
+			break
+		}
+	}
+	if len(files) > limit {
+		files = files[:limit]
+	}
+	return files, nil
+}
+
+// Content 读取 ref（分支、标签或提交）上的文件内容
+func (c *Client) Content(ctx context.Context, repo, path, ref string) ([]byte, error) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	endpoint := "/contents/" + strings.Join(segments, "/")
+	if ref != "" {
+		endpoint += "?ref=" + url.QueryEscape(ref)
+	}
+	var raw rawBody
+	if err := c.do(ctx, http.MethodGet, repo, endpoint, nil, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// CreateReview 在拉取请求上发表审查
+func (c *Client) CreateReview(ctx context.Context, repo string, number int, review *Review) error {
+	return c.do(ctx, http.MethodPost, repo, fmt.Sprintf("/pulls/%d/reviews", number), review, nil)
+}
+
+// rawBody 以原始内容（而不是 JSON）读取的响应
+type rawBody []byte
+
+// do 调用仓库下的 API，endpoint 为 /repos/<repo> 之后的部分，响应解码到 out
+func (c *Client) do(ctx context.Context, method, repo, endpoint string, body, out any) error {
+	token, err := c.token(ctx, repo)
+	if err != nil {
+		return err
+	}
+	return c.request(ctx, method, "/repos/"+repo+endpoint, "token "+token, body, out)
+}
+
+// request 发送请求，auth 为 Authorization 头
+func (c *Client) request(ctx context.Context, method, endpoint, auth string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.cfg.APIURL, "/")+endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if _, ok := out.(*rawBody); ok {
+		req.Header.Set("Accept", "application/vnd.github.raw+json")
+	} else {
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("github %s %s: %w", method, endpoint, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return fmt.Errorf("github %s %s: %w", method, endpoint, err)
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) != nil || e.Message == "" {
+			e.Message = http.StatusText(resp.StatusCode)
+		}
+		return &APIError{Status: resp.StatusCode, Message: e.Message}
+	}
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *rawBody:
+		*out = data
+		return nil
+	default:
+		return json.Unmarshal(data, out)
+	}
+}
+
+// token 访问仓库使用的 token：配置了 token 时直接使用，否则取 App 在该仓库上的安装 token（缓存到过期前 5 分钟）
+func (c *Client) token(ctx context.Context, repo string) (string, error) {
+	if c.key == nil {
+		return c.cfg.Token, nil
+	}
+
+	c.mu.Lock()
+	id, ok := c.installations[repo]
+	cached := c.tokens[id]
+	c.mu.Unlock()
+	if ok && time.Until(cached.expires) > 5*time.Minute {
+		return cached.token, nil
+	}
+
+	jwt, err := c.appJWT()
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		var installation struct {
+			ID int64 `json:"id"`
+		}
+		if err := c.request(ctx, http.MethodGet, "/repos/"+repo+"/installation", "Bearer "+jwt, nil, &installation); err != nil {
+			return "", fmt.Errorf("find github app installation for %s: %w", repo, err)
+		}
+		id = installation.ID
+	}
+	var created struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	endpoint := "/app/installations/" + strconv.FormatInt(id, 10) + "/access_tokens"
+	if err := c.request(ctx, http.MethodPost, endpoint, "Bearer "+jwt, nil, &created); err != nil {
+		return "", fmt.Errorf("create github installation token: %w", err)
+	}
+
+	c.mu.Lock()
+	c.installations[repo] = id
+	c.tokens[id] = installationToken{token: created.Token, expires: created.ExpiresAt}
+	c.mu.Unlock()
+	return created.Token, nil
+}
+
+// appJWT 生成以 App 身份调用 API 的 JWT（RS256），有效期 9 分钟，签发时间提前 1 分钟以容忍时钟偏差
+func (c *Client) appJWT() (string, error) {
+	now := time.Now()
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(c.cfg.AppID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signing := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign github app jwt: %w", err)
+	}
+	return signing + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,79 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// VerifySignature 校验 webhook 请求体的 X-Hub-Signature-256 签名
+func VerifySignature(secret, signature string, body []byte) bool {
+	sig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// RepoAllowed 仓库是否在 patterns（owner/repo 或 owner/*）中，比较时忽略大小写
+func RepoAllowed(patterns []string, repo string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(repo))
+		return ok
+	})
+}
+
+// PathAllowed 文件是否在 patterns 中，patterns 为空表示全部：dir/** 匹配目录下的所有文件，
+// 不含 / 的模式匹配文件名，其他模式按 path.Match 匹配完整路径
+func PathAllowed(patterns []string, file string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if strings.HasPrefix(file, dir+"/") {
+				return true
+			}
+			continue
+		}
+		name := file
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(file)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CommentableLines 统一 diff 中可以发表行内评论的新文件行号（新增行和上下文行）
+func CommentableLines(patch string) map[int]bool {
+	lines := make(map[int]bool)
+	line := 0
+	for _, l := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(l, "@@"):
+			// @@ -a,b +c,d @@：新文件从第 c 行开始
+			_, rest, _ := strings.Cut(l, "+")
+			start, _, _ := strings.Cut(rest, " ")
+			start, _, _ = strings.Cut(start, ",")
+			line, _ = strconv.Atoi(start)
+		case line == 0 || l == "" || strings.HasPrefix(l, "-") || strings.HasPrefix(l, "\\"):
+		default:
+			lines[line] = true
+			line++
+		}
+	}
+	return lines
+}
@@ -0,0 +1,152 @@
+// Package gitsync 把远端 git 仓库拉取到本地，列出和读取其中的文件，供知识库定期导入。
+// 使用系统中的 git 命令；本地只保存最新提交的浅克隆裸仓库，不检出工作区
+package gitsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// MaxFileSize 导入的单个文件的最大字节数，更大的文件被跳过
+const MaxFileSize = 10 << 20
+
+// syncRef 保存最近一次拉取的提交的本地引用
+const syncRef = "refs/sync/head"
+
+// File 仓库中的文件
+type File struct {
+	Path string // 相对仓库根目录的路径
+	Blob string // 文件内容的对象 SHA，内容不变时不变
+	Size int64
+}
+
+// Repo 本地保存的远端仓库
+type Repo struct {
+	cfg config.GitSourceConfig
+	dir string
+}
+
+// New 创建仓库，dir 为本地裸仓库目录，第一次 Fetch 时初始化
+func New(cfg config.GitSourceConfig, dir string) *Repo {
+	return &Repo{cfg: cfg, dir: dir}
+}
+
+// URL 返回去除了用户名和密码的仓库地址，用于日志和文档元数据
+func (r *Repo) URL() string {
+	u, err := url.Parse(r.cfg.URL)
+	if err != nil || u.User == nil {
+		return r.cfg.URL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// Fetch 拉取分支的最新提交（深度为 1），返回提交 SHA
+func (r *Repo) Fetch(ctx context.Context) (string, error) {
+	if _, err := os.Stat(filepath.Join(r.dir, "HEAD")); err != nil {
+		if err := os.MkdirAll(r.dir, 0o755); err != nil {
+			return "", fmt.Errorf("create git directory: %w", err)
+		}
+		if _, err := r.git(ctx, "init", "--quiet", "--bare"); err != nil {
+			return "", err
+		}
+		klog.InfoS("Git repository initialized", "source", r.cfg.Name, "dir", r.dir)
+	}
+
+	ref := "HEAD"
+	if r.cfg.Branch != "" {
+		ref = "refs/heads/" + r.cfg.Branch
+	}
+	if _, err := r.git(ctx, "fetch", "--quiet", "--no-tags", "--depth", "1", r.cfg.URL, "+"+ref+":"+syncRef); err != nil {
+		return "", err
+	}
+	out, err := r.git(ctx, "rev-parse", syncRef)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Files 列出提交中位于 paths 下、扩展名匹配的文件，跳过符号链接、子模块和超过 MaxFileSize 的文件
+func (r *Repo) Files(ctx context.Context, commit string) ([]File, error) {
+	out, err := r.git(ctx, "ls-tree", "-r", "-l", "-z", commit)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	for entry := range strings.SplitSeq(strings.TrimSuffix(string(out), "\x00"), "\x00") {
+		// <mode> <type> <object> <size>\t<path>
+		info, p, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 4 || fields[1] != "blob" || fields[0] == "120000" || !r.match(p) {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+		if size > MaxFileSize {
+			klog.InfoS("Skipping large file in git source", "source", r.cfg.Name, "path", p, "size", size)
+			continue
+		}
+		files = append(files, File{Path: p, Blob: fields[2], Size: size})
+	}
+	return files, nil
+}
+
+// match 文件是否位于配置的路径下且扩展名匹配
+func (r *Repo) match(p string) bool {
+	if !slices.ContainsFunc(r.cfg.Extensions, func(ext string) bool { return strings.EqualFold(path.Ext(p), ext) }) {
+		return false
+	}
+	if len(r.cfg.Paths) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(r.cfg.Paths, func(prefix string) bool {
+		prefix = path.Clean(prefix)
+		return prefix == "." || p == prefix || strings.HasPrefix(p, prefix+"/")
+	})
+}
+
+// ReadFile 读取文件内容
+func (r *Repo) ReadFile(ctx context.Context, f File) (string, error) {
+	out, err := r.git(ctx, "cat-file", "blob", f.Blob)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", f.Path, err)
+	}
+	return string(out), nil
+}
+
+// git 在本地仓库中执行 git 命令。禁止交互式输入凭证，配置了 token 时通过环境变量传入认证请求头
+func (r *Repo) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if r.cfg.Token != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(r.cfg.Username + ":" + r.cfg.Token))
+		cmd.Env = append(cmd.Env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+credentials,
+		)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
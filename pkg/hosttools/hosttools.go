@@ -0,0 +1,225 @@
+// Package hosttools 提供主机状态 MCP 工具：system_info 返回操作系统、CPU、内存和磁盘使用情况，
+// list_processes 按 CPU 或内存列出进程，让运维类对话基于主机的实际状态作答。
+// 在容器中运行时看到的是容器自身的进程和 cgroup 之外的主机内存
+package hosttools
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// sampleInterval 计算进程 CPU 使用率的采样间隔
+	sampleInterval = 500 * time.Millisecond
+	// defaultProcessLimit 默认返回的进程数
+	defaultProcessLimit = 20
+	// maxProcessLimit 最多返回的进程数
+	maxProcessLimit = 200
+	// maxCommandLength 命令行的最大字符数，超出时截断
+	maxCommandLength = 200
+)
+
+// 进程排序字段
+const (
+	SortCPU = "cpu"
+	SortRSS = "rss"
+	SortPID = "pid"
+)
+
+// Config 主机工具配置
+type Config struct {
+	Disks []string // 报告使用情况的挂载点，为空时为 /
+}
+
+// Toolset 主机工具集
+type Toolset struct {
+	cfg Config
+}
+
+// New 创建主机工具集
+func New(cfg Config) (*Toolset, error) {
+	if len(cfg.Disks) == 0 {
+		cfg.Disks = []string{"/"}
+	}
+	for _, disk := range cfg.Disks {
+		if _, err := os.Stat(disk); err != nil {
+			return nil, fmt.Errorf("invalid disk path %q: %w", disk, err)
+		}
+	}
+
+	klog.InfoS("Host toolset created", "os", runtime.GOOS, "disks", cfg.Disks)
+	return &Toolset{cfg: cfg}, nil
+}
+
+// SystemInfoInput system_info 的输入
+type SystemInfoInput struct{}
+
+// SystemInfoOutput system_info 的输出
+type SystemInfoOutput struct {
+	Hostname string     `json:"hostname" jsonschema:"主机名"`
+	OS       string     `json:"os" jsonschema:"操作系统发行版"`
+	Kernel   string     `json:"kernel,omitempty" jsonschema:"内核版本"`
+	Arch     string     `json:"arch" jsonschema:"CPU 架构"`
+	Uptime   string     `json:"uptime,omitempty" jsonschema:"开机时长"`
+	CPU      CPUInfo    `json:"cpu" jsonschema:"CPU 信息"`
+	Memory   MemoryInfo `json:"memory" jsonschema:"内存使用情况"`
+	Disks    []DiskInfo `json:"disks,omitempty" jsonschema:"磁盘使用情况"`
+}
+
+// CPUInfo CPU 信息
+type CPUInfo struct {
+	Model  string  `json:"model,omitempty" jsonschema:"CPU 型号"`
+	Cores  int     `json:"cores" jsonschema:"逻辑核数"`
+	Load1  float64 `json:"load1" jsonschema:"1 分钟平均负载"`
+	Load5  float64 `json:"load5" jsonschema:"5 分钟平均负载"`
+	Load15 float64 `json:"load15" jsonschema:"15 分钟平均负载"`
+}
+
+// MemoryInfo 内存使用情况（MB）
+type MemoryInfo struct {
+	TotalMB     int64   `json:"total_mb" jsonschema:"内存总量"`
+	AvailableMB int64   `json:"available_mb" jsonschema:"可用内存（包括可回收的缓存）"`
+	UsedPercent float64 `json:"used_percent" jsonschema:"已用比例（%）"`
+	SwapTotalMB int64   `json:"swap_total_mb" jsonschema:"交换空间总量"`
+	SwapUsedMB  int64   `json:"swap_used_mb" jsonschema:"已用交换空间"`
+}
+
+// DiskInfo 挂载点的磁盘使用情况（MB）
+type DiskInfo struct {
+	Path        string  `json:"path" jsonschema:"挂载点"`
+	TotalMB     int64   `json:"total_mb" jsonschema:"总容量"`
+	FreeMB      int64   `json:"free_mb" jsonschema:"非 root 用户可用的空间"`
+	UsedPercent float64 `json:"used_percent" jsonschema:"已用比例（%）"`
+}
+
+// ListProcessesInput list_processes 的输入
+type ListProcessesInput struct {
+	Name   string  `json:"name,omitempty" jsonschema:"按进程名或命令行过滤（子串，不区分大小写）"`
+	User   string  `json:"user,omitempty" jsonschema:"只列出该用户的进程"`
+	MinCPU float64 `json:"min_cpu,omitempty" jsonschema:"CPU 使用率下限（%，100 表示一个核）"`
+	MinRSS int64   `json:"min_rss_mb,omitempty" jsonschema:"常驻内存下限（MB）"`
+	SortBy string  `json:"sort_by,omitempty" jsonschema:"排序字段：cpu（默认）、rss 或 pid"`
+	Limit  int     `json:"limit,omitempty" jsonschema:"返回的进程数，默认 20，最多 200"`
+}
+
+// ListProcessesOutput list_processes 的输出
+type ListProcessesOutput struct {
+	Processes []ProcessInfo `json:"processes" jsonschema:"进程列表"`
+	Total     int           `json:"total" jsonschema:"符合过滤条件的进程数"`
+	SampleMs  int64         `json:"sample_ms" jsonschema:"计算 CPU 使用率的采样间隔（毫秒）"`
+}
+
+// ProcessInfo 进程信息
+type ProcessInfo struct {
+	PID        int     `json:"pid" jsonschema:"进程 ID"`
+	PPID       int     `json:"ppid" jsonschema:"父进程 ID"`
+	Name       string  `json:"name" jsonschema:"进程名"`
+	User       string  `json:"user,omitempty" jsonschema:"所属用户"`
+	State      string  `json:"state" jsonschema:"状态：R 运行、S 睡眠、D 不可中断、Z 僵尸等"`
+	CPUPercent float64 `json:"cpu_percent" jsonschema:"采样间隔内的 CPU 使用率（%，100 表示一个核）"`
+	RSSMB      float64 `json:"rss_mb" jsonschema:"常驻内存（MB）"`
+	Threads    int     `json:"threads" jsonschema:"线程数"`
+	Command    string  `json:"command,omitempty" jsonschema:"命令行（过长时截断）"`
+}
+
+// Register 将工具注册到 MCP Server
+func (t *Toolset) Register(server *mcp.Server) {
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "system_info",
+		Description: "返回主机的操作系统、内核、CPU 型号与负载、内存和磁盘使用情况",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, t.handleSystemInfo)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_processes",
+		Description: "列出主机上的进程（PID、名称、用户、CPU 使用率、常驻内存、命令行），可按名称、用户、CPU 和内存过滤，默认按 CPU 使用率排序",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, t.handleListProcesses)
+}
+
+// handleSystemInfo 返回主机状态，无法读取的部分留空
+func (t *Toolset) handleSystemInfo(ctx context.Context, req *mcp.CallToolRequest, input SystemInfoInput) (*mcp.CallToolResult, SystemInfoOutput, error) {
+	klog.InfoS("MCP tool called: system_info")
+
+	out := SystemInfoOutput{OS: runtime.GOOS, Arch: runtime.GOARCH, CPU: CPUInfo{Cores: runtime.NumCPU()}}
+	out.Hostname, _ = os.Hostname()
+	if err := readSystem(&out); err != nil {
+		klog.ErrorS(err, "Failed to read system info")
+	}
+	for _, path := range t.cfg.Disks {
+		disk, err := readDisk(path)
+		if err != nil {
+			klog.ErrorS(err, "Failed to read disk usage", "path", path)
+			continue
+		}
+		out.Disks = append(out.Disks, disk)
+	}
+	return nil, out, nil
+}
+
+// handleListProcesses 采样两次进程的 CPU 时间计算使用率，过滤排序后返回
+func (t *Toolset) handleListProcesses(ctx context.Context, req *mcp.CallToolRequest, input ListProcessesInput) (*mcp.CallToolResult, ListProcessesOutput, error) {
+	klog.InfoS("MCP tool called: list_processes", "name", input.Name, "user", input.User, "sortBy", input.SortBy)
+
+	var compare func(a, b ProcessInfo) int
+	switch input.SortBy {
+	case "", SortCPU:
+		compare = func(a, b ProcessInfo) int { return cmp.Compare(b.CPUPercent, a.CPUPercent) }
+	case SortRSS:
+		compare = func(a, b ProcessInfo) int { return cmp.Compare(b.RSSMB, a.RSSMB) }
+	case SortPID:
+		compare = func(a, b ProcessInfo) int { return cmp.Compare(a.PID, b.PID) }
+	default:
+		return nil, ListProcessesOutput{}, fmt.Errorf("invalid sort_by %q: expected cpu, rss or pid", input.SortBy)
+	}
+	limit := input.Limit
+	if limit <= 0 {
+		limit = defaultProcessLimit
+	}
+	limit = min(limit, maxProcessLimit)
+
+	processes, err := readProcesses(ctx, sampleInterval)
+	if err != nil {
+		return nil, ListProcessesOutput{}, err
+	}
+
+	name := strings.ToLower(input.Name)
+	processes = slices.DeleteFunc(processes, func(p ProcessInfo) bool {
+		return (name != "" && !strings.Contains(strings.ToLower(p.Name), name) && !strings.Contains(strings.ToLower(p.Command), name)) ||
+			(input.User != "" && p.User != input.User) ||
+			p.CPUPercent < input.MinCPU ||
+			p.RSSMB < float64(input.MinRSS)
+	})
+	// 相同时按 PID 排序，使结果稳定
+	slices.SortFunc(processes, func(a, b ProcessInfo) int {
+		return cmp.Or(compare(a, b), cmp.Compare(a.PID, b.PID))
+	})
+
+	out := ListProcessesOutput{Total: len(processes), SampleMs: sampleInterval.Milliseconds()}
+	out.Processes = processes[:min(len(processes), limit)]
+	return nil, out, nil
+}
+
+// truncateCommand 截断过长的命令行
+func truncateCommand(command string) string {
+	if runes := []rune(command); len(runes) > maxCommandLength {
+		return string(runes[:maxCommandLength]) + "..."
+	}
+	return command
+}
+
+// percent 计算比例（%），保留一位小数
+func percent(part, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(int(part/total*1000+0.5)) / 10
+}
@@ -0,0 +1,225 @@
+//go:build linux
+
+package hosttools
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// clockTicks /proc 中 CPU 时间的单位（USER_HZ），Linux 上几乎总是 100
+const clockTicks = 100
+
+// readSystem 从 /proc 读取发行版、内核、CPU、负载、开机时长和内存信息
+func readSystem(out *SystemInfoOutput) error {
+	if data, err := os.ReadFile("/etc/os-release"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if value, ok := strings.CutPrefix(line, "PRETTY_NAME="); ok {
+				out.OS = strings.Trim(value, `"`)
+			}
+		}
+	}
+	if data, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		out.Kernel = strings.TrimSpace(string(data))
+	}
+	if data, err := os.ReadFile("/proc/uptime"); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) > 0 {
+			if seconds, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				out.Uptime = (time.Duration(seconds) * time.Second).String()
+			}
+		}
+	}
+	if data, err := os.ReadFile("/proc/cpuinfo"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "model name" {
+				out.CPU.Model = strings.TrimSpace(value)
+				break
+			}
+		}
+	}
+	if data, err := os.ReadFile("/proc/loadavg"); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) >= 3 {
+			out.CPU.Load1, _ = strconv.ParseFloat(fields[0], 64)
+			out.CPU.Load5, _ = strconv.ParseFloat(fields[1], 64)
+			out.CPU.Load15, _ = strconv.ParseFloat(fields[2], 64)
+		}
+	}
+
+	meminfo, err := readMeminfo()
+	if err != nil {
+		return err
+	}
+	total, available := meminfo["MemTotal"], meminfo["MemAvailable"]
+	out.Memory = MemoryInfo{
+		TotalMB:     total >> 10,
+		AvailableMB: available >> 10,
+		UsedPercent: percent(float64(total-available), float64(total)),
+		SwapTotalMB: meminfo["SwapTotal"] >> 10,
+		SwapUsedMB:  (meminfo["SwapTotal"] - meminfo["SwapFree"]) >> 10,
+	}
+	return nil
+}
+
+// readMeminfo 读取 /proc/meminfo，单位 KB
+func readMeminfo() (map[string]int64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, fmt.Errorf("read meminfo: %w", err)
+	}
+	defer f.Close()
+
+	values := make(map[string]int64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		if fields := strings.Fields(value); len(fields) > 0 {
+			values[key], _ = strconv.ParseInt(fields[0], 10, 64)
+		}
+	}
+	return values, scanner.Err()
+}
+
+// readDisk 读取挂载点的磁盘使用情况
+func readDisk(path string) (DiskInfo, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return DiskInfo{}, err
+	}
+	bsize := int64(st.Bsize)
+	total := int64(st.Blocks) * bsize
+	free := int64(st.Bavail) * bsize
+	used := total - int64(st.Bfree)*bsize
+	return DiskInfo{
+		Path:        path,
+		TotalMB:     total >> 20,
+		FreeMB:      free >> 20,
+		UsedPercent: percent(float64(used), float64(used+free)),
+	}, nil
+}
+
+// procStat /proc/<pid>/stat 中用到的字段
+type procStat struct {
+	info     ProcessInfo
+	cpuTicks uint64
+}
+
+// readProcesses 读取所有进程，间隔 interval 再读一次 CPU 时间计算使用率；期间退出的进程不返回
+func readProcesses(ctx context.Context, interval time.Duration) ([]ProcessInfo, error) {
+	first, err := scanProcesses()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(interval):
+	}
+	second, err := scanProcesses()
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start).Seconds()
+
+	users := make(map[uint32]string)
+	processes := make([]ProcessInfo, 0, len(second))
+	for pid, stat := range second {
+		p := stat.info
+		if prev, ok := first[pid]; ok && stat.cpuTicks >= prev.cpuTicks {
+			p.CPUPercent = percent(float64(stat.cpuTicks-prev.cpuTicks)/clockTicks, elapsed)
+		}
+		p.User = lookupUser(users, pid)
+		if data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline")); err == nil {
+			p.Command = truncateCommand(strings.Join(strings.Fields(strings.ReplaceAll(string(data), "\x00", " ")), " "))
+		}
+		processes = append(processes, p)
+	}
+	return processes, nil
+}
+
+// scanProcesses 读取所有进程的 /proc/<pid>/stat
+func scanProcesses() (map[int]procStat, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("read /proc: %w", err)
+	}
+	stats := make(map[int]procStat, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		// 读取期间退出的进程直接跳过
+		if stat, err := readStat(pid); err == nil {
+			stats[pid] = stat
+		}
+	}
+	return stats, nil
+}
+
+// readStat 解析 /proc/<pid>/stat；进程名在括号中且可能包含空格，从最后一个右括号之后按空格拆分
+func readStat(pid int) (procStat, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return procStat{}, err
+	}
+	text := string(data)
+	open, end := strings.IndexByte(text, '('), strings.LastIndexByte(text, ')')
+	if open < 0 || end < open {
+		return procStat{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	// fields[0] 为第 3 个字段（state）
+	fields := strings.Fields(text[end+1:])
+	if len(fields) < 22 {
+		return procStat{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	threads, _ := strconv.Atoi(fields[17])
+	rss, _ := strconv.ParseInt(fields[21], 10, 64)
+	return procStat{
+		info: ProcessInfo{
+			PID:     pid,
+			PPID:    ppid,
+			Name:    text[open+1 : end],
+			State:   fields[0],
+			RSSMB:   float64(rss*int64(os.Getpagesize())*10>>20) / 10,
+			Threads: threads,
+		},
+		cpuTicks: utime + stime,
+	}, nil
+}
+
+// lookupUser 返回进程所属用户名，找不到用户时返回 UID；users 缓存本次已查询的用户
+func lookupUser(users map[uint32]string, pid int) string {
+	info, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid)))
+	if err != nil {
+		return ""
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	if name, ok := users[st.Uid]; ok {
+		return name
+	}
+	uid := strconv.FormatUint(uint64(st.Uid), 10)
+	name := uid
+	if u, err := user.LookupId(uid); err == nil {
+		name = u.Username
+	}
+	users[st.Uid] = name
+	return name
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package hosttools
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// errUnsupported 当前平台无法读取进程和内存信息
+var errUnsupported = fmt.Errorf("not supported on %s", runtime.GOOS)
+
+// readSystem 非 Linux 平台只返回 runtime 提供的信息
+func readSystem(out *SystemInfoOutput) error {
+	return errUnsupported
+}
+
+// readDisk 非 Linux 平台不支持
+func readDisk(path string) (DiskInfo, error) {
+	return DiskInfo{}, errUnsupported
+}
+
+// readProcesses 非 Linux 平台不支持
+func readProcesses(ctx context.Context, interval time.Duration) ([]ProcessInfo, error) {
+	return nil, errUnsupported
+}
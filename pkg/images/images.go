@@ -0,0 +1,154 @@
+// Package images 处理聊天请求附带的图片：校验请求中的 base64 图片，保存通过 /api/images 上传的图片，
+// 并在聊天时把两者解析为发送给视觉模型（llava、qwen-vl 等）的图片数据
+package images
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/cache"
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+var (
+	// ErrInvalid 图片无法解码、格式不支持、过大或数量超出限制
+	ErrInvalid = errors.New("invalid image")
+	// ErrNotFound 上传的图片不存在、已过期或属于其他用户
+	ErrNotFound = errors.New("image not found")
+)
+
+// mediaTypes 支持的图片格式
+var mediaTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Upload 已上传的图片
+type Upload struct {
+	ID        string    `json:"id"`
+	MediaType string    `json:"media_type"`
+	Size      int       `json:"size"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Store 上传图片的存储
+type Store struct {
+	store     cache.Store
+	ttl       time.Duration
+	maxBytes  int
+	maxImages int
+}
+
+// New 按配置创建存储
+func New(cfg config.ImageConfig, redisCfg config.RedisConfig) (*Store, error) {
+	s := &Store{ttl: cfg.TTL, maxBytes: cfg.MaxBytes, maxImages: cfg.MaxImages}
+	switch cfg.Backend {
+	case "", "memory":
+		s.store = cache.NewMemoryStore(cfg.MaxEntries)
+	case "redis":
+		rs, err := cache.NewRedisStore(redisCfg)
+		if err != nil {
+			return nil, err
+		}
+		s.store = rs
+	default:
+		return nil, fmt.Errorf("unknown images backend: %s", cfg.Backend)
+	}
+	return s, nil
+}
+
+// MaxBytes 单张图片的最大字节数
+func (s *Store) MaxBytes() int {
+	return s.maxBytes
+}
+
+// Check 校验图片大小和格式，返回图片的媒体类型
+func (s *Store) Check(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", fmt.Errorf("%w: empty", ErrInvalid)
+	}
+	if len(data) > s.maxBytes {
+		return "", fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrInvalid, len(data), s.maxBytes)
+	}
+	mediaType := http.DetectContentType(data)
+	if !mediaTypes[mediaType] {
+		return "", fmt.Errorf("%w: unsupported type %s", ErrInvalid, mediaType)
+	}
+	return mediaType, nil
+}
+
+// Put 保存 owner 上传的图片，之后只有 owner 能引用
+func (s *Store) Put(ctx context.Context, owner string, data []byte) (*Upload, error) {
+	mediaType, err := s.Check(data)
+	if err != nil {
+		return nil, err
+	}
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(b[:])
+	if err := s.store.Set(ctx, key(owner, id), data, s.ttl); err != nil {
+		return nil, err
+	}
+	klog.V(2).InfoS("Image uploaded", "id", id, "type", mediaType, "size", len(data))
+	return &Upload{ID: id, MediaType: mediaType, Size: len(data), ExpiresAt: time.Now().Add(s.ttl)}, nil
+}
+
+// Resolve 把请求中的 base64 图片（可以是 data URL）和上传图片的 ID 解析为图片数据，base64 图片在前
+func (s *Store) Resolve(ctx context.Context, owner string, inline, ids []string) ([]api.ImageData, error) {
+	if n := len(inline) + len(ids); n > s.maxImages {
+		return nil, fmt.Errorf("%w: %d images exceeds limit of %d", ErrInvalid, n, s.maxImages)
+	}
+	var result []api.ImageData
+	for i, encoded := range inline {
+		data, err := decode(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: images[%d]: %v", ErrInvalid, i, err)
+		}
+		if _, err := s.Check(data); err != nil {
+			return nil, fmt.Errorf("images[%d]: %w", i, err)
+		}
+		result = append(result, data)
+	}
+	for _, id := range ids {
+		data, ok, err := s.store.Get(ctx, key(owner, id))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		result = append(result, data)
+	}
+	return result, nil
+}
+
+// decode 解码 base64 图片，允许 data:image/png;base64, 前缀
+func decode(encoded string) ([]byte, error) {
+	if rest, ok := strings.CutPrefix(encoded, "data:"); ok {
+		_, payload, found := strings.Cut(rest, ";base64,")
+		if !found {
+			return nil, errors.New("data URL must be base64 encoded")
+		}
+		encoded = payload
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+}
+
+// key 上传图片的存储键，包含上传者身份，其他用户无法引用
+func key(owner, id string) string {
+	return "image:" + owner + ":" + id
+}
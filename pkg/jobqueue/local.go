@@ -0,0 +1,243 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// validID 任务 ID 只允许安全字符，防止路径穿越
+var validID = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// localStore 进程内的队列，dir 不为空时每个任务保存为一个 JSON 文件（死信在 dead 子目录），
+// 重启后加载并继续执行；只能由单个进程使用
+type localStore struct {
+	dir string
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+	dead map[string]*Job
+}
+
+// newLocalStore 创建本地队列，dir 为空时只保存在内存中
+func newLocalStore(dir string) (*localStore, error) {
+	s := &localStore{dir: dir, jobs: make(map[string]*Job), dead: make(map[string]*Job)}
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "dead"), 0o755); err != nil {
+		return nil, fmt.Errorf("create task queue directory: %w", err)
+	}
+	if err := s.load(dir, s.jobs); err != nil {
+		return nil, err
+	}
+	if err := s.load(filepath.Join(dir, "dead"), s.dead); err != nil {
+		return nil, err
+	}
+	// 上次退出时执行中的任务由本进程重新执行
+	for _, job := range s.jobs {
+		job.LeaseUntil = time.Time{}
+	}
+	if len(s.jobs) > 0 || len(s.dead) > 0 {
+		klog.InfoS("Task queue loaded", "dir", dir, "pending", len(s.jobs), "dead", len(s.dead))
+	}
+	return s, nil
+}
+
+// load 读取目录下的任务文件
+func (s *localStore) load(dir string, into map[string]*Job) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read task queue directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read job %s: %w", path, err)
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			klog.ErrorS(err, "Skipping invalid job file", "path", path)
+			continue
+		}
+		into[job.ID] = &job
+	}
+	return nil
+}
+
+// path 返回任务文件路径，dead 为 true 时在死信目录
+func (s *localStore) path(id string, dead bool) (string, error) {
+	if !validID.MatchString(id) || strings.Contains(id, "..") {
+		return "", fmt.Errorf("invalid job id: %q", id)
+	}
+	if dead {
+		return filepath.Join(s.dir, "dead", id+".json"), nil
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// writeLocked 保存任务文件（写临时文件后重命名），调用方需持有 s.mu
+func (s *localStore) writeLocked(job *Job, dead bool) error {
+	if s.dir == "" {
+		return nil
+	}
+	path, err := s.path(job.ID, dead)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("marshal job: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write job: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// removeLocked 删除任务文件，调用方需持有 s.mu
+func (s *localStore) removeLocked(id string, dead bool) error {
+	if s.dir == "" {
+		return nil
+	}
+	path, err := s.path(id, dead)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove job: %w", err)
+	}
+	return nil
+}
+
+// Put 添加或替换等待执行的任务
+func (s *localStore) Put(_ context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *job
+	stored.LeaseUntil = time.Time{}
+	if err := s.writeLocked(&stored, false); err != nil {
+		return err
+	}
+	s.jobs[job.ID] = &stored
+	return nil
+}
+
+// Claim 取出最早到期的任务
+func (s *localStore) Claim(_ context.Context, now time.Time, lease time.Duration) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *Job
+	for _, job := range s.jobs {
+		if job.RunAt.After(now) || job.LeaseUntil.After(now) {
+			continue
+		}
+		if next == nil || job.RunAt.Before(next.RunAt) {
+			next = job
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+
+	next.Attempts++
+	next.LeaseUntil = now.Add(lease)
+	if err := s.writeLocked(next, false); err != nil {
+		next.Attempts--
+		next.LeaseUntil = time.Time{}
+		return nil, err
+	}
+	claimed := *next
+	return &claimed, nil
+}
+
+// Extend 延长租约，只在内存中记录（文件后端重启后所有任务都会重新执行）
+func (s *localStore) Extend(_ context.Context, id string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[id]; ok {
+		job.LeaseUntil = until
+	}
+	return nil
+}
+
+// Delete 删除任务
+func (s *localStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[id]; !ok {
+		return nil
+	}
+	if err := s.removeLocked(id, false); err != nil {
+		return err
+	}
+	delete(s.jobs, id)
+	return nil
+}
+
+// Bury 将任务转入死信
+func (s *localStore) Bury(_ context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *job
+	stored.LeaseUntil = time.Time{}
+	if err := s.writeLocked(&stored, true); err != nil {
+		return err
+	}
+	if err := s.removeLocked(job.ID, false); err != nil {
+		return err
+	}
+	delete(s.jobs, job.ID)
+	s.dead[job.ID] = &stored
+	return nil
+}
+
+// Dead 列出死信，按失败时间从新到旧排列
+func (s *localStore) Dead(_ context.Context) ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, 0, len(s.dead))
+	for _, job := range s.dead {
+		copied := *job
+		jobs = append(jobs, &copied)
+	}
+	slices.SortFunc(jobs, func(a, b *Job) int { return b.FailedAt.Compare(a.FailedAt) })
+	return jobs, nil
+}
+
+// Unbury 从死信中取出任务
+func (s *localStore) Unbury(_ context.Context, id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.dead[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err := s.removeLocked(id, true); err != nil {
+		return nil, err
+	}
+	delete(s.dead, id)
+	return job, nil
+}
+
+// Close 本地队列无需释放资源
+func (s *localStore) Close() error {
+	return nil
+}
@@ -0,0 +1,329 @@
+// Package jobqueue 持久化的后台任务队列：任务可延迟执行，失败时按指数退避重试，
+// 超过重试次数后转入死信；执行中的任务持有租约，进程重启或副本崩溃后由队列重新执行
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// pollInterval 没有到期任务时的轮询间隔
+const pollInterval = time.Second
+
+// ErrNotFound 任务不在队列或死信中
+var ErrNotFound = errors.New("job not found")
+
+// Job 队列中的任务
+type Job struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`           // 任务类型，决定由哪个 Handler 执行
+	User      string          `json:"user,omitempty"` // 提交任务的用户
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"` // 已开始执行的次数
+	RunAt     time.Time       `json:"run_at"`   // 最早执行时间
+	CreatedAt time.Time       `json:"created_at"`
+	LastError string          `json:"last_error,omitempty"`
+	FailedAt  time.Time       `json:"failed_at,omitzero"` // 转入死信的时间
+
+	// LeaseUntil 执行中任务的租约到期时间，为零表示等待执行
+	LeaseUntil time.Time `json:"lease_until,omitzero"`
+}
+
+// Store 队列的存储后端
+type Store interface {
+	// Put 添加或替换等待执行的任务（清除租约）
+	Put(ctx context.Context, job *Job) error
+	// Claim 取出一个到期的任务并设置租约，已开始次数加一；没有到期任务时返回 nil。
+	// 租约过期的任务视为等待执行
+	Claim(ctx context.Context, now time.Time, lease time.Duration) (*Job, error)
+	// Extend 延长执行中任务的租约
+	Extend(ctx context.Context, id string, until time.Time) error
+	// Delete 删除任务，不存在时不报错
+	Delete(ctx context.Context, id string) error
+	// Bury 将任务转入死信
+	Bury(ctx context.Context, job *Job) error
+	// Dead 列出死信中的任务
+	Dead(ctx context.Context) ([]*Job, error)
+	// Unbury 从死信中取出任务，不存在时返回 ErrNotFound
+	Unbury(ctx context.Context, id string) (*Job, error)
+	// Close 释放连接
+	Close() error
+}
+
+// newStore 按配置创建存储后端
+func newStore(cfg config.TaskQueueConfig, redisCfg config.RedisConfig) (Store, error) {
+	switch cfg.Backend {
+	case "", "memory":
+		return newLocalStore("")
+	case "file":
+		return newLocalStore(cfg.Dir)
+	case "redis":
+		return newRedisStore(redisCfg)
+	default:
+		return nil, fmt.Errorf("unknown task queue backend: %s", cfg.Backend)
+	}
+}
+
+// Handler 执行一种类型的任务，返回错误时按配置重试
+type Handler func(ctx context.Context, job *Job) error
+
+// permanentError 不需要重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记错误不需要重试，任务直接转入死信
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Queue 任务队列
+type Queue struct {
+	cfg   config.TaskQueueConfig
+	store Store
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	started atomic.Bool
+	wake    chan struct{} // 本进程提交任务时唤醒空闲的 worker
+	done    chan struct{} // 所有 worker 退出后关闭
+}
+
+// New 创建任务队列
+func New(cfg config.TaskQueueConfig, redisCfg config.RedisConfig) (*Queue, error) {
+	store, err := newStore(cfg, redisCfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{
+		cfg:      cfg,
+		store:    store,
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Register 注册任务类型的 Handler，需在 Start 之前调用
+func (q *Queue) Register(kind string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+}
+
+// Enqueue 提交任务，RunAt 为零时立即执行
+func (q *Queue) Enqueue(ctx context.Context, job *Job) error {
+	now := time.Now()
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+	if err := q.store.Put(ctx, job); err != nil {
+		return fmt.Errorf("enqueue job: %w", err)
+	}
+	q.notify()
+	return nil
+}
+
+// Cancel 删除尚未执行完的任务；执行中的任务由调用方自行停止
+func (q *Queue) Cancel(ctx context.Context, id string) error {
+	return q.store.Delete(ctx, id)
+}
+
+// RetryAt job 本次执行以 err 失败后是否会重试，以及下次执行的时间
+func (q *Queue) RetryAt(job *Job, err error) (time.Time, bool) {
+	var permanent *permanentError
+	if err == nil || errors.As(err, &permanent) || job.Attempts >= q.cfg.MaxAttempts {
+		return time.Time{}, false
+	}
+	return time.Now().Add(q.backoff(job.Attempts)), true
+}
+
+// DeadLetters 列出死信中的任务
+func (q *Queue) DeadLetters(ctx context.Context) ([]*Job, error) {
+	return q.store.Dead(ctx)
+}
+
+// Requeue 将死信中的任务重新放回队列，重试次数清零
+func (q *Queue) Requeue(ctx context.Context, id string) (*Job, error) {
+	job, err := q.store.Unbury(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	job.Attempts = 0
+	job.FailedAt = time.Time{}
+	job.RunAt = time.Time{}
+	if err := q.Enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Discard 删除死信中的任务
+func (q *Queue) Discard(ctx context.Context, id string) error {
+	_, err := q.store.Unbury(ctx, id)
+	return err
+}
+
+// Start 在后台启动 workers 个 worker 执行到期任务，ctx 取消后停止；
+// 因停止而中断的任务放回队列，下次启动后继续执行
+func (q *Queue) Start(ctx context.Context, workers int) {
+	if !q.started.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer close(q.done)
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Go(func() { q.work(ctx) })
+		}
+		wg.Wait()
+	}()
+}
+
+// Close 等待 worker 退出（需先取消 Start 的 ctx）后关闭存储
+func (q *Queue) Close() error {
+	if q.started.Load() {
+		<-q.done
+	}
+	return q.store.Close()
+}
+
+// notify 唤醒一个空闲的 worker
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work 循环取出并执行任务
+func (q *Queue) work(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-q.wake:
+		}
+
+		for ctx.Err() == nil {
+			job, err := q.store.Claim(ctx, time.Now(), q.cfg.Lease)
+			if err != nil {
+				if ctx.Err() == nil {
+					klog.ErrorS(err, "Failed to claim job")
+				}
+				break
+			}
+			if job == nil {
+				break
+			}
+			// 可能还有其他到期任务，唤醒其他 worker
+			q.notify()
+			q.process(ctx, job)
+		}
+		timer.Reset(pollInterval)
+	}
+}
+
+// process 执行任务并按结果删除、重试或转入死信
+func (q *Queue) process(ctx context.Context, job *Job) {
+	q.mu.RLock()
+	handler := q.handlers[job.Kind]
+	q.mu.RUnlock()
+
+	var err error
+	if handler == nil {
+		err = Permanent(fmt.Errorf("no handler for job kind %s", job.Kind))
+	} else {
+		err = q.runWithLease(ctx, job, handler)
+	}
+
+	// 存储操作不随 ctx 取消，保证停止时任务能放回队列
+	storeCtx := context.WithoutCancel(ctx)
+	switch {
+	case err == nil:
+		err = q.store.Delete(storeCtx, job.ID)
+
+	case ctx.Err() != nil:
+		// 服务停止导致的中断不计入重试次数
+		job.Attempts--
+		klog.InfoS("Job interrupted, requeued", "jobID", job.ID, "kind", job.Kind)
+		err = q.store.Put(storeCtx, job)
+
+	case q.retry(job, err):
+		job.LastError = err.Error()
+		klog.InfoS("Job failed, will retry", "jobID", job.ID, "kind", job.Kind,
+			"attempts", job.Attempts, "retryAt", job.RunAt, "err", err)
+		err = q.store.Put(storeCtx, job)
+
+	default:
+		job.LastError = err.Error()
+		job.FailedAt = time.Now()
+		klog.InfoS("Job moved to dead letter", "jobID", job.ID, "kind", job.Kind, "attempts", job.Attempts, "err", err)
+		err = q.store.Bury(storeCtx, job)
+	}
+	if err != nil {
+		klog.ErrorS(err, "Failed to update job", "jobID", job.ID)
+	}
+}
+
+// retry 需要重试时设置下次执行时间
+func (q *Queue) retry(job *Job, err error) bool {
+	at, ok := q.RetryAt(job, err)
+	if ok {
+		job.RunAt = at
+	}
+	return ok
+}
+
+// runWithLease 执行任务，期间定期延长租约
+func (q *Queue) runWithLease(ctx context.Context, job *Job, handler Handler) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(q.cfg.Lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := q.store.Extend(ctx, job.ID, time.Now().Add(q.cfg.Lease)); err != nil && ctx.Err() == nil {
+					klog.ErrorS(err, "Failed to extend job lease", "jobID", job.ID)
+				}
+			}
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff 第 attempts 次失败后的等待时间，从 Backoff 开始每次翻倍，不超过 MaxBackoff
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.cfg.Backoff
+	for i := 1; i < attempts && d < q.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, q.cfg.MaxBackoff)
+}
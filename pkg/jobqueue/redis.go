@@ -0,0 +1,223 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/redisclient"
+)
+
+// redisTimeout 单次 Redis 操作超时
+const redisTimeout = 5 * time.Second
+
+// claimScript 将租约过期的任务放回待执行集合，再取出最早到期的任务并设置租约。
+// KEYS: ready, leases, jobs, attempts；ARGV: 当前时间、租约到期时间（毫秒）
+var claimScript = redis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, id in ipairs(expired) do
+  redis.call('ZREM', KEYS[2], id)
+  redis.call('ZADD', KEYS[1], ARGV[1], id)
+end
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then
+  return false
+end
+local id = ids[1]
+redis.call('ZREM', KEYS[1], id)
+local data = redis.call('HGET', KEYS[3], id)
+if not data then
+  redis.call('HDEL', KEYS[4], id)
+  return false
+end
+redis.call('ZADD', KEYS[2], ARGV[2], id)
+local attempts = redis.call('HINCRBY', KEYS[4], id, 1)
+return {data, attempts}
+`)
+
+// redisStore 基于 Redis 的队列，多个副本共享，任一副本都可以取出任务执行。
+// 任务内容保存在 <prefix>:taskqueue:jobs 哈希，待执行和执行中的任务分别按执行时间、
+// 租约到期时间保存在 ready、leases 有序集合，死信保存在 dead 哈希
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// newRedisStore 创建 Redis 队列
+func newRedisStore(cfg config.RedisConfig) (*redisStore, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &redisStore{client: client, prefix: cfg.Prefix}, nil
+}
+
+// key 返回队列的键
+func (s *redisStore) key(name string) string {
+	return redisclient.Key(s.prefix, "taskqueue", name)
+}
+
+// Put 添加或替换等待执行的任务
+func (s *redisStore) Put(ctx context.Context, job *Job) error {
+	stored := *job
+	stored.LeaseUntil = time.Time{}
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return fmt.Errorf("marshal job: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.key("jobs"), job.ID, data)
+		pipe.HSet(ctx, s.key("attempts"), job.ID, job.Attempts)
+		pipe.ZRem(ctx, s.key("leases"), job.ID)
+		pipe.ZAdd(ctx, s.key("ready"), redis.Z{Score: float64(job.RunAt.UnixMilli()), Member: job.ID})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("write job: %w", err)
+	}
+	return nil
+}
+
+// Claim 取出最早到期的任务
+func (s *redisStore) Claim(ctx context.Context, now time.Time, lease time.Duration) (*Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	keys := []string{s.key("ready"), s.key("leases"), s.key("jobs"), s.key("attempts")}
+	res, err := claimScript.Run(ctx, s.client, keys, now.UnixMilli(), now.Add(lease).UnixMilli()).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claim job: %w", err)
+	}
+
+	data, _ := res[0].(string)
+	attempts, _ := res[1].(int64)
+	var job Job
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, fmt.Errorf("parse job: %w", err)
+	}
+	job.Attempts = int(attempts)
+	job.LeaseUntil = now.Add(lease)
+	return &job, nil
+}
+
+// Extend 延长租约，任务已被删除时不做任何事
+func (s *redisStore) Extend(ctx context.Context, id string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	err := s.client.ZAddXX(ctx, s.key("leases"), redis.Z{Score: float64(until.UnixMilli()), Member: id}).Err()
+	if err != nil {
+		return fmt.Errorf("extend job lease: %w", err)
+	}
+	return nil
+}
+
+// removeJob 在事务中删除任务的所有记录
+func (s *redisStore) removeJob(ctx context.Context, pipe redis.Pipeliner, id string) {
+	pipe.HDel(ctx, s.key("jobs"), id)
+	pipe.HDel(ctx, s.key("attempts"), id)
+	pipe.ZRem(ctx, s.key("ready"), id)
+	pipe.ZRem(ctx, s.key("leases"), id)
+}
+
+// Delete 删除任务
+func (s *redisStore) Delete(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.removeJob(ctx, pipe, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete job: %w", err)
+	}
+	return nil
+}
+
+// Bury 将任务转入死信
+func (s *redisStore) Bury(ctx context.Context, job *Job) error {
+	stored := *job
+	stored.LeaseUntil = time.Time{}
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return fmt.Errorf("marshal job: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.key("dead"), job.ID, data)
+		s.removeJob(ctx, pipe, job.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bury job: %w", err)
+	}
+	return nil
+}
+
+// Dead 列出死信，按失败时间从新到旧排列
+func (s *redisStore) Dead(ctx context.Context) ([]*Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	values, err := s.client.HGetAll(ctx, s.key("dead")).Result()
+	if err != nil {
+		return nil, fmt.Errorf("read dead letters: %w", err)
+	}
+	jobs := make([]*Job, 0, len(values))
+	for id, data := range values {
+		var job Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			klog.ErrorS(err, "Failed to parse dead letter", "jobID", id)
+			continue
+		}
+		jobs = append(jobs, &job)
+	}
+	slices.SortFunc(jobs, func(a, b *Job) int { return b.FailedAt.Compare(a.FailedAt) })
+	return jobs, nil
+}
+
+// Unbury 从死信中取出任务，并发取出同一任务时只有一个成功
+func (s *redisStore) Unbury(ctx context.Context, id string) (*Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	data, err := s.client.HGet(ctx, s.key("dead"), id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read dead letter: %w", err)
+	}
+	removed, err := s.client.HDel(ctx, s.key("dead"), id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("remove dead letter: %w", err)
+	}
+	if removed == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("parse job: %w", err)
+	}
+	return &job, nil
+}
+
+// Close 关闭连接
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
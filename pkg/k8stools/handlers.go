@@ -0,0 +1,350 @@
+package k8stools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// requestTimeout 单次 API 调用超时
+	requestTimeout = 30 * time.Second
+	// defaultListLimit 列表默认返回条数
+	defaultListLimit = 100
+	// defaultTailLines 日志默认返回的行数
+	defaultTailLines = 200
+	// maxLogBytes 日志最大返回字节数
+	maxLogBytes = 256 << 10
+)
+
+// ListInput k8s_list 的输入
+type ListInput struct {
+	Resource      string `json:"resource" jsonschema:"资源类型，如 pods、deployments、svc"`
+	Namespace     string `json:"namespace,omitempty" jsonschema:"命名空间（默认为当前命名空间）"`
+	LabelSelector string `json:"label_selector,omitempty" jsonschema:"标签选择器，如 app=nginx"`
+	FieldSelector string `json:"field_selector,omitempty" jsonschema:"字段选择器，如 status.phase=Running"`
+	Limit         int64  `json:"limit,omitempty" jsonschema:"最大返回条数（默认 100）"`
+}
+
+// GetInput k8s_get / k8s_describe 的输入
+type GetInput struct {
+	Resource  string `json:"resource" jsonschema:"资源类型，如 pods、deployments"`
+	Name      string `json:"name" jsonschema:"资源名称"`
+	Namespace string `json:"namespace,omitempty" jsonschema:"命名空间（默认为当前命名空间）"`
+}
+
+// LogsInput k8s_logs 的输入
+type LogsInput struct {
+	Name         string `json:"name" jsonschema:"Pod 名称"`
+	Namespace    string `json:"namespace,omitempty" jsonschema:"命名空间（默认为当前命名空间）"`
+	Container    string `json:"container,omitempty" jsonschema:"容器名称（多容器 Pod 必填）"`
+	TailLines    int64  `json:"tail_lines,omitempty" jsonschema:"返回最后多少行（默认 200）"`
+	SinceSeconds int64  `json:"since_seconds,omitempty" jsonschema:"仅返回最近多少秒的日志"`
+	Previous     bool   `json:"previous,omitempty" jsonschema:"读取上一次（已崩溃）容器的日志"`
+}
+
+// EventsInput k8s_events 的输入
+type EventsInput struct {
+	Namespace string `json:"namespace,omitempty" jsonschema:"命名空间（默认为当前命名空间）"`
+	Object    string `json:"object,omitempty" jsonschema:"只返回与该资源名称相关的事件"`
+	Type      string `json:"type,omitempty" jsonschema:"事件类型：Normal 或 Warning"`
+	Limit     int    `json:"limit,omitempty" jsonschema:"最大返回条数（默认 100）"`
+}
+
+// Output 工具输出
+type Output struct {
+	Output string `json:"output" jsonschema:"查询结果"`
+}
+
+// handleList 列出资源
+func (t *Toolset) handleList(ctx context.Context, req *mcp.CallToolRequest, input ListInput) (*mcp.CallToolResult, Output, error) {
+	klog.InfoS("MCP tool called: k8s_list", "resource", input.Resource, "namespace", input.Namespace)
+
+	client, ns, err := t.resourceClient(input.Resource, input.Namespace)
+	if err != nil {
+		return nil, Output{}, err
+	}
+
+	limit := input.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	list, err := client.List(ctx, metav1.ListOptions{
+		LabelSelector: input.LabelSelector,
+		FieldSelector: input.FieldSelector,
+		Limit:         limit,
+	})
+	if err != nil {
+		return nil, Output{}, fmt.Errorf("list %s: %w", input.Resource, err)
+	}
+
+	if len(list.Items) == 0 {
+		return nil, Output{Output: fmt.Sprintf("No %s found in namespace %q", input.Resource, ns)}, nil
+	}
+
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tAGE")
+	for _, item := range list.Items {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", item.GetName(), statusSummary(&item), age(item.GetCreationTimestamp()))
+	}
+	w.Flush()
+
+	if list.GetContinue() != "" {
+		fmt.Fprintf(&sb, "... more items available, narrow the selector or raise limit\n")
+	}
+	return nil, Output{Output: sb.String()}, nil
+}
+
+// handleGet 获取资源定义
+func (t *Toolset) handleGet(ctx context.Context, req *mcp.CallToolRequest, input GetInput) (*mcp.CallToolResult, Output, error) {
+	klog.InfoS("MCP tool called: k8s_get", "resource", input.Resource, "name", input.Name, "namespace", input.Namespace)
+
+	obj, err := t.get(ctx, input)
+	if err != nil {
+		return nil, Output{}, err
+	}
+
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return nil, Output{}, fmt.Errorf("marshal %s: %w", input.Name, err)
+	}
+	return nil, Output{Output: string(data)}, nil
+}
+
+// handleDescribe 描述资源（定义 + 相关事件）
+func (t *Toolset) handleDescribe(ctx context.Context, req *mcp.CallToolRequest, input GetInput) (*mcp.CallToolResult, Output, error) {
+	klog.InfoS("MCP tool called: k8s_describe", "resource", input.Resource, "name", input.Name, "namespace", input.Namespace)
+
+	obj, err := t.get(ctx, input)
+	if err != nil {
+		return nil, Output{}, err
+	}
+
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return nil, Output{}, fmt.Errorf("marshal %s: %w", input.Name, err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s/%s\n\n", obj.GetKind(), obj.GetName())
+	sb.Write(data)
+
+	sb.WriteString("\nEvents:\n")
+	if obj.GetNamespace() == "" {
+		sb.WriteString("  <cluster-scoped resource, events not listed>\n")
+		return nil, Output{Output: sb.String()}, nil
+	}
+
+	events, err := t.listEvents(ctx, obj.GetNamespace(), obj.GetName(), "", defaultListLimit)
+	if err != nil {
+		fmt.Fprintf(&sb, "  <failed to list events: %v>\n", err)
+	} else {
+		sb.WriteString(events)
+	}
+	return nil, Output{Output: sb.String()}, nil
+}
+
+// handleLogs 读取 Pod 日志
+func (t *Toolset) handleLogs(ctx context.Context, req *mcp.CallToolRequest, input LogsInput) (*mcp.CallToolResult, Output, error) {
+	klog.InfoS("MCP tool called: k8s_logs", "pod", input.Name, "namespace", input.Namespace, "container", input.Container)
+
+	ns, err := t.resolveNamespace(input.Namespace)
+	if err != nil {
+		return nil, Output{}, err
+	}
+
+	tail := input.TailLines
+	if tail <= 0 {
+		tail = defaultTailLines
+	}
+	limitBytes := int64(maxLogBytes)
+	opts := &corev1.PodLogOptions{
+		Container:  input.Container,
+		TailLines:  &tail,
+		Previous:   input.Previous,
+		LimitBytes: &limitBytes,
+	}
+	if input.SinceSeconds > 0 {
+		opts.SinceSeconds = &input.SinceSeconds
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	stream, err := t.clientset.CoreV1().Pods(ns).GetLogs(input.Name, opts).Stream(ctx)
+	if err != nil {
+		return nil, Output{}, fmt.Errorf("get logs of %s: %w", input.Name, err)
+	}
+	defer stream.Close()
+
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return nil, Output{}, fmt.Errorf("read logs of %s: %w", input.Name, err)
+	}
+	if len(data) == 0 {
+		return nil, Output{Output: "<no logs>"}, nil
+	}
+	return nil, Output{Output: string(data)}, nil
+}
+
+// handleEvents 列出事件
+func (t *Toolset) handleEvents(ctx context.Context, req *mcp.CallToolRequest, input EventsInput) (*mcp.CallToolResult, Output, error) {
+	klog.InfoS("MCP tool called: k8s_events", "namespace", input.Namespace, "object", input.Object, "type", input.Type)
+
+	ns, err := t.resolveNamespace(input.Namespace)
+	if err != nil {
+		return nil, Output{}, err
+	}
+
+	limit := input.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+
+	events, err := t.listEvents(ctx, ns, input.Object, input.Type, limit)
+	if err != nil {
+		return nil, Output{}, err
+	}
+	return nil, Output{Output: events}, nil
+}
+
+// get 获取单个资源（去除 managedFields 以减少输出）
+func (t *Toolset) get(ctx context.Context, input GetInput) (*unstructured.Unstructured, error) {
+	client, _, err := t.resourceClient(input.Resource, input.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	obj, err := client.Get(ctx, input.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get %s/%s: %w", input.Resource, input.Name, err)
+	}
+	obj.SetManagedFields(nil)
+	return obj, nil
+}
+
+// listEvents 列出事件，按最近发生时间倒序
+func (t *Toolset) listEvents(ctx context.Context, namespace, object, eventType string, limit int) (string, error) {
+	var selectors []string
+	if object != "" {
+		selectors = append(selectors, "involvedObject.name="+object)
+	}
+	if eventType != "" {
+		selectors = append(selectors, "type="+eventType)
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	list, err := t.clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: strings.Join(selectors, ","),
+	})
+	if err != nil {
+		return "", fmt.Errorf("list events: %w", err)
+	}
+
+	events := list.Items
+	sort.Slice(events, func(i, j int) bool {
+		return eventTime(&events[i]).After(eventTime(&events[j]))
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	if len(events) == 0 {
+		return "  <none>\n", nil
+	}
+
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "LAST SEEN\tTYPE\tREASON\tOBJECT\tCOUNT\tMESSAGE")
+	for i := range events {
+		ev := &events[i]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s/%s\t%d\t%s\n",
+			age(metav1.NewTime(eventTime(ev))), ev.Type, ev.Reason,
+			strings.ToLower(ev.InvolvedObject.Kind), ev.InvolvedObject.Name, ev.Count,
+			strings.TrimSpace(ev.Message))
+	}
+	w.Flush()
+	return sb.String(), nil
+}
+
+// eventTime 返回事件最近发生的时间
+func eventTime(ev *corev1.Event) time.Time {
+	switch {
+	case !ev.LastTimestamp.IsZero():
+		return ev.LastTimestamp.Time
+	case !ev.EventTime.IsZero():
+		return ev.EventTime.Time
+	default:
+		return ev.CreationTimestamp.Time
+	}
+}
+
+// age 返回人类可读的资源年龄
+func age(ts metav1.Time) string {
+	if ts.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(ts.Time))
+}
+
+// statusSummary 提取资源状态摘要（Pod 阶段、副本就绪数等）
+func statusSummary(obj *unstructured.Unstructured) string {
+	if phase, ok, _ := unstructured.NestedString(obj.Object, "status", "phase"); ok {
+		if obj.GetKind() == "Pod" {
+			return phase + podReadiness(obj)
+		}
+		return phase
+	}
+	if replicas, ok, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); ok {
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		return fmt.Sprintf("%d/%d ready", ready, replicas)
+	}
+	if typ, ok, _ := unstructured.NestedString(obj.Object, "spec", "type"); ok {
+		return typ
+	}
+	return "-"
+}
+
+// podReadiness 返回 Pod 容器就绪数与重启次数
+func podReadiness(obj *unstructured.Unstructured) string {
+	statuses, ok, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses")
+	if !ok {
+		return ""
+	}
+
+	var ready, restarts int64
+	for _, s := range statuses {
+		status, ok := s.(map[string]any)
+		if !ok {
+			continue
+		}
+		if r, _ := status["ready"].(bool); r {
+			ready++
+		}
+		if n, ok := status["restartCount"].(int64); ok {
+			restarts += n
+		}
+	}
+	return fmt.Sprintf(" (%d/%d ready, %d restarts)", ready, len(statuses), restarts)
+}
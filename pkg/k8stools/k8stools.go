@@ -0,0 +1,197 @@
+// Package k8stools 提供只读的 Kubernetes MCP 工具集（资源查询、describe、Pod 日志、事件）
+package k8stools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+// AllNamespaces 允许访问所有命名空间
+const AllNamespaces = "*"
+
+// serviceAccountNamespace 集群内 Pod 所在命名空间文件
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Config Kubernetes 工具集配置
+type Config struct {
+	Kubeconfig         string   // kubeconfig 路径，为空时依次尝试集群内凭证和默认 kubeconfig
+	Context            string   // kubeconfig 上下文
+	Namespaces         []string // 允许访问的命名空间，为空时仅允许当前上下文的命名空间，"*" 表示全部
+	AllowClusterScoped bool     // 是否允许查询集群级资源（如 nodes）
+}
+
+// Toolset Kubernetes 工具集
+type Toolset struct {
+	cfg              Config
+	defaultNamespace string
+	clientset        kubernetes.Interface
+	dynamic          dynamic.Interface
+	mapper           meta.RESTMapper
+}
+
+// New 创建 Kubernetes 工具集
+func New(cfg Config) (*Toolset, error) {
+	restCfg, namespace, err := LoadRESTConfig(cfg.Kubeconfig, cfg.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("create kubernetes client: %w", err)
+	}
+	dyn, err := dynamic.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("create dynamic client: %w", err)
+	}
+	disco, err := discovery.NewDiscoveryClientForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("create discovery client: %w", err)
+	}
+
+	cached := memory.NewMemCacheClient(disco)
+	mapper := restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cached), cached, nil)
+
+	if len(cfg.Namespaces) == 0 {
+		cfg.Namespaces = []string{namespace}
+	}
+
+	klog.InfoS("Kubernetes toolset created", "host", restCfg.Host, "namespaces", cfg.Namespaces)
+	return &Toolset{
+		cfg:              cfg,
+		defaultNamespace: namespace,
+		clientset:        clientset,
+		dynamic:          dyn,
+		mapper:           mapper,
+	}, nil
+}
+
+// LoadRESTConfig 加载集群连接配置，返回当前命名空间
+// 未指定 kubeconfig 时优先使用集群内凭证，其次使用默认 kubeconfig。
+func LoadRESTConfig(kubeconfig, kubeContext string) (*rest.Config, string, error) {
+	if kubeconfig == "" {
+		if restCfg, err := rest.InClusterConfig(); err == nil {
+			namespace := "default"
+			if data, err := os.ReadFile(serviceAccountNamespace); err == nil {
+				namespace = strings.TrimSpace(string(data))
+			}
+			return restCfg, namespace, nil
+		}
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	clientCfg := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+
+	restCfg, err := clientCfg.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("load kubeconfig: %w", err)
+	}
+	namespace, _, err := clientCfg.Namespace()
+	if err != nil || namespace == "" {
+		namespace = "default"
+	}
+	return restCfg, namespace, nil
+}
+
+// Register 将工具注册到 MCP Server
+func (t *Toolset) Register(server *mcp.Server) {
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "k8s_list",
+		Description: "列出 Kubernetes 资源（如 pods、deployments、services），支持标签/字段选择器",
+	}, t.handleList)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "k8s_get",
+		Description: "获取单个 Kubernetes 资源的完整定义（YAML）",
+	}, t.handleGet)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "k8s_describe",
+		Description: "描述 Kubernetes 资源：资源定义、状态及相关事件",
+	}, t.handleDescribe)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "k8s_logs",
+		Description: "读取 Pod 容器日志",
+	}, t.handleLogs)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "k8s_events",
+		Description: "列出命名空间内的事件，可按资源名称和事件类型过滤",
+	}, t.handleEvents)
+}
+
+// resolveNamespace 校验命名空间是否在允许范围内
+func (t *Toolset) resolveNamespace(namespace string) (string, error) {
+	if namespace == "" {
+		namespace = t.defaultNamespace
+	}
+	if slices.Contains(t.cfg.Namespaces, AllNamespaces) || slices.Contains(t.cfg.Namespaces, namespace) {
+		return namespace, nil
+	}
+	return "", fmt.Errorf("access denied: namespace %q is not allowed (allowed: %s)", namespace, strings.Join(t.cfg.Namespaces, ","))
+}
+
+// resolveResource 将资源名称（支持简称，如 deploy、po）解析为 GVR，并返回是否为命名空间级资源
+func (t *Toolset) resolveResource(resource string) (schema.GroupVersionResource, bool, error) {
+	gvr, err := t.mapper.ResourceFor(schema.ParseGroupResource(strings.ToLower(resource)).WithVersion(""))
+	if err != nil {
+		return schema.GroupVersionResource{}, false, fmt.Errorf("unknown resource %q: %w", resource, err)
+	}
+
+	gvk, err := t.mapper.KindFor(gvr)
+	if err != nil {
+		return schema.GroupVersionResource{}, false, fmt.Errorf("resolve kind of %q: %w", resource, err)
+	}
+	mapping, err := t.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return schema.GroupVersionResource{}, false, fmt.Errorf("resolve mapping of %q: %w", resource, err)
+	}
+
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	if !namespaced && !t.cfg.AllowClusterScoped {
+		return schema.GroupVersionResource{}, false, fmt.Errorf("access denied: cluster-scoped resource %q is not allowed", resource)
+	}
+	return mapping.Resource, namespaced, nil
+}
+
+// resourceClient 返回资源对应的 dynamic client
+func (t *Toolset) resourceClient(resource, namespace string) (dynamic.ResourceInterface, string, error) {
+	gvr, namespaced, err := t.resolveResource(resource)
+	if err != nil {
+		return nil, "", err
+	}
+	if !namespaced {
+		return t.dynamic.Resource(gvr), "", nil
+	}
+
+	ns, err := t.resolveNamespace(namespace)
+	if err != nil {
+		return nil, "", err
+	}
+	return t.dynamic.Resource(gvr).Namespace(ns), ns, nil
+}
+
+// withTimeout 为单次 API 调用设置超时
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, requestTimeout)
+}
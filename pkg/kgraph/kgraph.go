@@ -0,0 +1,406 @@
+// Package kgraph 保存从对话和导入文档中抽取的实体和关系（SQLite），支持从问题中的实体出发做多跳查询。
+// 文档的事实按 RAG 集合划分范围，对话的事实按用户划分范围，查询时只返回可见范围内的事实
+package kgraph
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// 范围前缀：文档的事实以 "doc:<集合>" 为范围，对话的事实以 "user:<用户>" 为范围
+const (
+	docScopePrefix  = "doc:"
+	userScopePrefix = "user:"
+)
+
+// 匹配问题中的实体时忽略的最短名称（字符数），避免单字实体匹配到大量问题
+const minMatchLength = 2
+
+// 单次匹配返回的最多实体数
+const maxMatches = 10
+
+const schema = `
+CREATE TABLE IF NOT EXISTS entities (
+	id         INTEGER PRIMARY KEY,
+	scope      TEXT NOT NULL,
+	key        TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	type       TEXT NOT NULL DEFAULT '',
+	updated_at TIMESTAMP NOT NULL,
+	UNIQUE (scope, key)
+);
+CREATE INDEX IF NOT EXISTS entities_key ON entities (key);
+CREATE TABLE IF NOT EXISTS relations (
+	id       INTEGER PRIMARY KEY,
+	scope    TEXT NOT NULL,
+	origin   TEXT NOT NULL,
+	source   INTEGER NOT NULL REFERENCES entities (id),
+	target   INTEGER NOT NULL REFERENCES entities (id),
+	relation TEXT NOT NULL,
+	UNIQUE (source, relation, target, origin)
+);
+CREATE INDEX IF NOT EXISTS relations_source ON relations (source);
+CREATE INDEX IF NOT EXISTS relations_target ON relations (target);
+CREATE INDEX IF NOT EXISTS relations_origin ON relations (scope, origin);
+CREATE TABLE IF NOT EXISTS mentions (
+	entity INTEGER NOT NULL REFERENCES entities (id),
+	origin TEXT NOT NULL,
+	PRIMARY KEY (entity, origin)
+);
+`
+
+// Entity 实体
+type Entity struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// Relation 两个实体之间的关系，Source 和 Target 为实体名称
+type Relation struct {
+	Source   string `json:"source"`
+	Relation string `json:"relation"`
+	Target   string `json:"target"`
+}
+
+// Extraction 从一段文本中抽取的实体和关系
+type Extraction struct {
+	Entities  []Entity   `json:"entities"`
+	Relations []Relation `json:"relations"`
+}
+
+// Fact 查询返回的一条关系
+type Fact struct {
+	Source   string `json:"source"`
+	Relation string `json:"relation"`
+	Target   string `json:"target"`
+	// Collection 来自文档时为文档所在的集合，Origin 为文档 ID；来自对话时 Collection 为空，Origin 为对话 ID
+	Collection string `json:"collection,omitempty"`
+	Origin     string `json:"origin"`
+}
+
+// Filter 查询的可见范围
+type Filter struct {
+	// Collection 可见的文档集合，规则与 RAG 检索相同：为空时为所有集合，以 / 结尾时为该前缀下的集合
+	Collection string
+	// User 可见其对话中抽取的事实的用户
+	User string
+}
+
+// where 返回 col 列的范围条件及参数
+func (f Filter) where(col string) (string, []any) {
+	cond := col + " = ?"
+	args := []any{UserScope(f.User)}
+	switch {
+	case f.Collection == "":
+		cond += " OR substr(" + col + ", 1, ?) = ?"
+		args = append(args, len(docScopePrefix), docScopePrefix)
+	case strings.HasSuffix(f.Collection, "/"):
+		prefix := DocumentScope(f.Collection)
+		cond += " OR substr(" + col + ", 1, ?) = ?"
+		args = append(args, len(prefix), prefix)
+	default:
+		cond += " OR " + col + " = ?"
+		args = append(args, DocumentScope(f.Collection))
+	}
+	return "(" + cond + ")", args
+}
+
+// DocumentScope 返回集合中文档事实的范围
+func DocumentScope(collection string) string {
+	return docScopePrefix + collection
+}
+
+// UserScope 返回用户对话事实的范围
+func UserScope(user string) string {
+	return userScopePrefix + user
+}
+
+// Graph 知识图谱，nil 表示未启用
+type Graph struct {
+	db *sql.DB
+}
+
+// New 按配置打开（必要时创建）数据库，未启用时返回 nil
+func New(cfg config.KnowledgeGraphConfig) (*Graph, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create knowledge graph directory: %w", err)
+	}
+	db, err := sql.Open("sqlite3", "file:"+cfg.Path+"?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+	// 写入串行执行，避免 SQLite 的锁冲突
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize knowledge graph: %w", err)
+	}
+	klog.InfoS("Knowledge graph enabled", "path", cfg.Path)
+	return &Graph{db: db}, nil
+}
+
+// Close 关闭数据库
+func (g *Graph) Close() error {
+	if g == nil {
+		return nil
+	}
+	return g.db.Close()
+}
+
+// normalize 整理实体名称，返回显示名称和用于匹配的 key
+func normalize(name string) (string, string) {
+	name = strings.Join(strings.Fields(name), " ")
+	return name, strings.ToLower(name)
+}
+
+// Add 保存从 origin（文档 ID 或对话 ID）中抽取的实体和关系，关系两端未在 Entities 中列出的实体自动补充
+func (g *Graph) Add(ctx context.Context, scope, origin string, ex Extraction) (err error) {
+	tx, err := g.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	ids := make(map[string]int64)
+	upsert := func(name, typ string) (int64, error) {
+		name, key := normalize(name)
+		if key == "" {
+			return 0, nil
+		}
+		if id, ok := ids[key]; ok && typ == "" {
+			return id, nil
+		}
+		var id int64
+		err := tx.QueryRowContext(ctx, `INSERT INTO entities (scope, key, name, type, updated_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (scope, key) DO UPDATE SET type = coalesce(nullif(excluded.type, ''), type), updated_at = excluded.updated_at
+			RETURNING id`, scope, key, name, strings.TrimSpace(typ), now).Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO mentions (entity, origin) VALUES (?, ?)`, id, origin); err != nil {
+			return 0, err
+		}
+		ids[key] = id
+		return id, nil
+	}
+
+	for _, e := range ex.Entities {
+		if _, err := upsert(e.Name, e.Type); err != nil {
+			return err
+		}
+	}
+	for _, r := range ex.Relations {
+		relation := strings.TrimSpace(r.Relation)
+		source, err := upsert(r.Source, "")
+		if err != nil {
+			return err
+		}
+		target, err := upsert(r.Target, "")
+		if err != nil {
+			return err
+		}
+		if relation == "" || source == 0 || target == 0 || source == target {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO relations (scope, origin, source, target, relation) VALUES (?, ?, ?, ?, ?)`,
+			scope, origin, source, target, relation); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Remove 删除从 origin 中抽取的关系，以及不再被任何来源提及的实体；重新导入文档前调用以替换旧的事实
+func (g *Graph) Remove(ctx context.Context, scope, origin string) (err error) {
+	tx, err := g.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	statements := []string{
+		`DELETE FROM relations WHERE scope = ? AND origin = ?`,
+		`DELETE FROM mentions WHERE entity IN (SELECT id FROM entities WHERE scope = ?) AND origin = ?`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt, scope, origin); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM entities WHERE scope = ?
+		AND NOT EXISTS (SELECT 1 FROM mentions WHERE mentions.entity = entities.id)
+		AND NOT EXISTS (SELECT 1 FROM relations WHERE relations.source = entities.id OR relations.target = entities.id)`, scope)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Match 返回 text 中出现的实体名称，较长的名称优先
+func (g *Graph) Match(ctx context.Context, f Filter, text string) ([]string, error) {
+	where, args := f.where("scope")
+	args = append([]any{strings.ToLower(text), minMatchLength}, args...)
+	args = append(args, maxMatches)
+	rows, err := g.db.QueryContext(ctx, `SELECT key, min(name) FROM entities
+		WHERE instr(?, key) > 0 AND length(key) >= ? AND `+where+`
+		GROUP BY key ORDER BY length(key) DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var key, name string
+		if err := rows.Scan(&key, &name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// Search 返回名称包含 query 的实体，用于按名称查询时容忍不完整的名称
+func (g *Graph) Search(ctx context.Context, f Filter, query string) ([]Entity, error) {
+	_, key := normalize(query)
+	if key == "" {
+		return nil, nil
+	}
+	where, args := f.where("scope")
+	args = append([]any{key}, args...)
+	args = append(args, maxMatches)
+	rows, err := g.db.QueryContext(ctx, `SELECT min(name), max(type) FROM entities
+		WHERE instr(key, ?) > 0 AND `+where+`
+		GROUP BY key ORDER BY length(key) LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entities []Entity
+	for rows.Next() {
+		var e Entity
+		if err := rows.Scan(&e.Name, &e.Type); err != nil {
+			return nil, err
+		}
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+// Neighborhood 从 names 中的实体出发，返回 hops 跳以内的关系（最多 limit 条），离起点近的关系在前。
+// 不同来源中名称相同（不区分大小写）的实体视为同一实体，多跳查询可以跨文档和对话
+func (g *Graph) Neighborhood(ctx context.Context, f Filter, names []string, hops, limit int) ([]Fact, error) {
+	visited := make(map[string]bool)
+	var frontier []string
+	for _, name := range names {
+		if _, key := normalize(name); key != "" && !visited[key] {
+			visited[key] = true
+			frontier = append(frontier, key)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var facts []Fact
+	for hop := 0; hop < hops && len(frontier) > 0 && len(facts) < limit; hop++ {
+		var next []string
+		found, err := g.edges(ctx, f, frontier, limit-len(facts))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range found {
+			id := e.sourceKey + "\x00" + e.Relation + "\x00" + e.targetKey
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			facts = append(facts, e.Fact)
+			for _, key := range []string{e.sourceKey, e.targetKey} {
+				if !visited[key] {
+					visited[key] = true
+					next = append(next, key)
+				}
+			}
+		}
+		frontier = next
+	}
+	return facts, nil
+}
+
+// edge 带两端实体 key 的关系
+type edge struct {
+	Fact
+	sourceKey, targetKey string
+}
+
+// edges 返回一端为 keys 中实体的关系
+func (g *Graph) edges(ctx context.Context, f Filter, keys []string, limit int) ([]edge, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+	where, scopeArgs := f.where("r.scope")
+	var args []any
+	for range 2 {
+		for _, key := range keys {
+			args = append(args, key)
+		}
+	}
+	args = append(args, scopeArgs...)
+	args = append(args, limit)
+
+	rows, err := g.db.QueryContext(ctx, `SELECT s.name, s.key, r.relation, t.name, t.key, r.scope, r.origin
+		FROM relations r JOIN entities s ON s.id = r.source JOIN entities t ON t.id = r.target
+		WHERE (s.key IN (`+placeholders+`) OR t.key IN (`+placeholders+`)) AND `+where+`
+		ORDER BY r.id DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edges []edge
+	for rows.Next() {
+		var e edge
+		var scope string
+		if err := rows.Scan(&e.Source, &e.sourceKey, &e.Relation, &e.Target, &e.targetKey, &scope, &e.Origin); err != nil {
+			return nil, err
+		}
+		e.Collection, _ = strings.CutPrefix(scope, docScopePrefix)
+		if strings.HasPrefix(scope, userScopePrefix) {
+			e.Collection = ""
+		}
+		edges = append(edges, e)
+	}
+	return edges, rows.Err()
+}
+
+// Stats 返回可见范围内的实体数和关系数
+func (g *Graph) Stats(ctx context.Context, f Filter) (entities, relations int, err error) {
+	where, args := f.where("scope")
+	if err := g.db.QueryRowContext(ctx, `SELECT count(DISTINCT key) FROM entities WHERE `+where, args...).Scan(&entities); err != nil {
+		return 0, 0, err
+	}
+	if err := g.db.QueryRowContext(ctx, `SELECT count(*) FROM relations WHERE `+where, args...).Scan(&relations); err != nil {
+		return 0, 0, err
+	}
+	return entities, relations, nil
+}
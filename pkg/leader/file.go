@@ -0,0 +1,110 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// fileElector 基于锁文件心跳的选举，适用于共享卷上的多副本部署
+// 主节点定期刷新锁文件的修改时间，超过 LeaseDuration 未刷新视为过期，可被其他实例接管。
+type fileElector struct {
+	cfg      config.LeaderConfig
+	identity string
+}
+
+// newFileElector 创建锁文件选举器
+func newFileElector(cfg config.LeaderConfig, identity string) *fileElector {
+	return &fileElector{cfg: cfg, identity: identity}
+}
+
+// run 周期性尝试获取或续约锁文件
+func (e *fileElector) run(ctx context.Context, onStarted func(ctx context.Context), onStopped func()) {
+	if err := os.MkdirAll(filepath.Dir(e.cfg.LockPath), 0o755); err != nil {
+		klog.ErrorS(err, "Failed to create leader lock directory", "path", e.cfg.LockPath)
+	}
+
+	ticker := time.NewTicker(e.cfg.RetryPeriod)
+	defer ticker.Stop()
+
+	leading := false
+	for {
+		held := e.tryAcquire()
+		switch {
+		case held && !leading:
+			leading = true
+			onStarted(ctx)
+		case !held && leading:
+			leading = false
+			onStopped()
+		}
+
+		select {
+		case <-ctx.Done():
+			if leading {
+				e.release()
+				onStopped()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tryAcquire 尝试获取或续约锁，返回当前是否持有锁
+func (e *fileElector) tryAcquire() bool {
+	data, err := os.ReadFile(e.cfg.LockPath)
+	switch {
+	case err == nil:
+		holder := strings.TrimSpace(string(data))
+		if holder == e.identity {
+			// 续约
+			now := time.Now()
+			if err := os.Chtimes(e.cfg.LockPath, now, now); err != nil {
+				klog.ErrorS(err, "Failed to renew leader lock", "path", e.cfg.LockPath)
+				return false
+			}
+			return true
+		}
+
+		info, err := os.Stat(e.cfg.LockPath)
+		if err != nil || time.Since(info.ModTime()) < e.cfg.LeaseDuration {
+			return false
+		}
+		// 锁已过期，删除后重新竞争
+		klog.InfoS("Leader lock expired, taking over", "path", e.cfg.LockPath, "previous", holder)
+		if err := os.Remove(e.cfg.LockPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return false
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		klog.ErrorS(err, "Failed to read leader lock", "path", e.cfg.LockPath)
+		return false
+	}
+
+	// O_EXCL 保证只有一个实例能创建成功
+	f, err := os.OpenFile(e.cfg.LockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if _, err := f.WriteString(e.identity); err != nil {
+		klog.ErrorS(err, "Failed to write leader lock", "path", e.cfg.LockPath)
+		return false
+	}
+	return true
+}
+
+// release 主动释放锁，便于其他实例尽快接管
+func (e *fileElector) release() {
+	data, err := os.ReadFile(e.cfg.LockPath)
+	if err == nil && strings.TrimSpace(string(data)) == e.identity {
+		os.Remove(e.cfg.LockPath)
+	}
+}
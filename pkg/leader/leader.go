@@ -0,0 +1,150 @@
+// Package leader 实现多副本部署下的主节点选举，保证后台任务只在主节点执行
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// elector 选举实现
+type elector interface {
+	// run 参与选举直到 ctx 结束；成为主节点时调用 onStarted，失去主节点身份时调用 onStopped
+	run(ctx context.Context, onStarted func(ctx context.Context), onStopped func())
+}
+
+// Job 仅在主节点上执行的后台任务，ctx 在失去主节点身份时取消
+type Job func(ctx context.Context)
+
+// Manager 主节点选举管理器
+type Manager struct {
+	identity string
+	elector  elector // 为空表示未启用选举，当前实例始终为主节点
+
+	mu     sync.Mutex
+	jobs   map[string]Job
+	cancel context.CancelFunc // 当前主节点任期的取消函数
+	stop   context.CancelFunc // 停止参与选举
+	leader atomic.Bool
+	wg     sync.WaitGroup
+}
+
+// New 根据配置创建选举管理器
+func New(cfg config.LeaderConfig) (*Manager, error) {
+	identity := cfg.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("get hostname: %w", err)
+		}
+		identity = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	m := &Manager{
+		identity: identity,
+		jobs:     make(map[string]Job),
+	}
+
+	if !cfg.Enabled {
+		return m, nil
+	}
+
+	switch cfg.Backend {
+	case "lease":
+		e, err := newLeaseElector(cfg, identity)
+		if err != nil {
+			return nil, err
+		}
+		m.elector = e
+	case "file":
+		m.elector = newFileElector(cfg, identity)
+	default:
+		return nil, fmt.Errorf("unknown leader election backend: %s", cfg.Backend)
+	}
+	return m, nil
+}
+
+// Register 注册仅在主节点执行的后台任务（需在 Start 之前调用）
+func (m *Manager) Register(name string, job Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[name] = job
+}
+
+// Identity 返回当前实例标识
+func (m *Manager) Identity() string {
+	return m.identity
+}
+
+// IsLeader 当前实例是否为主节点
+func (m *Manager) IsLeader() bool {
+	return m.leader.Load()
+}
+
+// Start 开始参与选举（非阻塞）
+func (m *Manager) Start(ctx context.Context) {
+	ctx, m.stop = context.WithCancel(ctx)
+	if m.elector == nil {
+		m.startLeading(ctx)
+		return
+	}
+
+	klog.InfoS("Leader election started", "identity", m.identity)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.elector.run(ctx, m.startLeading, m.stopLeading)
+	}()
+}
+
+// Stop 退出选举、停止所有后台任务并等待退出
+func (m *Manager) Stop() {
+	if m.stop != nil {
+		m.stop()
+	}
+	m.stopLeading()
+	m.wg.Wait()
+}
+
+// startLeading 成为主节点，启动所有后台任务
+func (m *Manager) startLeading(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cancel != nil {
+		return
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.leader.Store(true)
+	klog.InfoS("Became leader, starting background jobs", "identity", m.identity, "jobs", len(m.jobs))
+
+	for name, job := range m.jobs {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			klog.V(2).InfoS("Leader job started", "job", name)
+			job(ctx)
+			klog.V(2).InfoS("Leader job stopped", "job", name)
+		}()
+	}
+}
+
+// stopLeading 失去主节点身份，取消所有后台任务
+func (m *Manager) stopLeading() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	m.cancel = nil
+	m.leader.Store(false)
+	klog.InfoS("Stopped leading, background jobs cancelled", "identity", m.identity)
+}
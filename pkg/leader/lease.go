@@ -0,0 +1,72 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/k8stools"
+)
+
+// leaseElector 基于 Kubernetes Lease 的选举
+type leaseElector struct {
+	cfg  config.LeaderConfig
+	lock *resourcelock.LeaseLock
+}
+
+// newLeaseElector 创建 Lease 选举器
+func newLeaseElector(cfg config.LeaderConfig, identity string) (*leaseElector, error) {
+	restCfg, currentNamespace, err := k8stools.LoadRESTConfig("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("create kubernetes client: %w", err)
+	}
+
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = currentNamespace
+	}
+
+	return &leaseElector{
+		cfg: cfg,
+		lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Name:      cfg.LeaseName,
+				Namespace: namespace,
+			},
+			Client:     client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+	}, nil
+}
+
+// run 参与选举，失去主节点身份后重新参与，直到 ctx 结束
+func (e *leaseElector) run(ctx context.Context, onStarted func(ctx context.Context), onStopped func()) {
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            e.lock,
+			LeaseDuration:   e.cfg.LeaseDuration,
+			RenewDeadline:   e.cfg.RenewDeadline,
+			RetryPeriod:     e.cfg.RetryPeriod,
+			ReleaseOnCancel: true,
+			Name:            e.cfg.LeaseName,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: onStarted,
+				OnStoppedLeading: onStopped,
+				OnNewLeader: func(identity string) {
+					klog.InfoS("Leader changed", "lease", e.cfg.LeaseName, "leader", identity)
+				},
+			},
+		})
+	}
+}
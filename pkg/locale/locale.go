@@ -0,0 +1,70 @@
+// Package locale 回答语言：语言代码的规范化、内置的中英文默认系统提示和要求模型使用该语言回答的提示
+package locale
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupported 不支持的语言
+var ErrUnsupported = errors.New("unsupported language")
+
+// names 支持的语言（BCP 47 主语言子标签）及其英文名称
+var names = map[string]string{
+	"zh": "Chinese",
+	"en": "English",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"fr": "French",
+	"de": "German",
+	"es": "Spanish",
+	"ru": "Russian",
+}
+
+// Normalize 规范化语言代码：zh-CN、zh_Hans、EN 等取主语言子标签并转为小写，空字符串原样返回
+func Normalize(tag string) (string, error) {
+	if tag == "" {
+		return "", nil
+	}
+	lang, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	lang = strings.ToLower(lang)
+	if _, ok := names[lang]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupported, tag)
+	}
+	return lang, nil
+}
+
+// systemPrompts 内置的默认系统提示，用于优化模型行为和减少 token 消耗
+var systemPrompts = map[string]string{
+	"zh": `你是一个高效的AI助手，具备以下特性：
+- 深度理解用户需求，避免不必要的重复工具调用
+- 优先查看对话历史，利用已有信息回答问题
+- 只在确实需要时才调用工具，避免盲目探索
+- 支持批量工具调用，提高执行效率
+- 提供清晰、准确的最终回答，简要说明工具使用情况
+- 分析项目时先查看目录结构和 README、依赖清单等关键文件，再按需读取与问题相关的代码，不要逐个读取所有文件`,
+	"en": `You are an efficient AI assistant:
+- Understand what the user actually needs and avoid repeating tool calls
+- Check the conversation history first and reuse information you already have
+- Call tools only when they are really needed instead of exploring blindly
+- Batch tool calls when possible to save round trips
+- Give a clear, accurate final answer with a short note on which tools you used
+- When analyzing a project, look at the directory layout and key files such as the README and dependency manifests first, then read only the code relevant to the question instead of every file`,
+}
+
+// SystemPrompt 语言对应的内置默认系统提示，没有该语言的版本时使用英文
+func SystemPrompt(lang string) string {
+	if p, ok := systemPrompts[lang]; ok {
+		return p
+	}
+	return systemPrompts["en"]
+}
+
+// Instruction 要求模型使用该语言回答的提示
+func Instruction(lang string) string {
+	if lang == "zh" {
+		return "请始终使用中文回答。"
+	}
+	return fmt.Sprintf("Always answer in %s.", names[lang])
+}
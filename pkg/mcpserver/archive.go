@@ -0,0 +1,480 @@
+package mcpserver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+)
+
+// 压缩包大小的默认限制
+const (
+	DefaultArchiveMaxBytes   = 512 << 20
+	DefaultArchiveMaxEntries = 10000
+)
+
+// 压缩包格式
+const (
+	formatZip   = "zip"
+	formatTarGz = "tar.gz"
+	formatTar   = "tar"
+)
+
+// errArchiveLimit 压缩包的解压大小或条目数超出限制
+var errArchiveLimit = errors.New("archive exceeds limit")
+
+// ArchiveLimits 解压和打包的限制，防止压缩炸弹和意外打包整个目录
+type ArchiveLimits struct {
+	MaxBytes   int64 // 解压后或打包前的文件总大小上限
+	MaxEntries int   // 文件和目录的总数上限
+}
+
+// ExtractArchiveInput 解压的输入
+type ExtractArchiveInput struct {
+	Path      string `json:"path" jsonschema:"压缩包路径（相对允许访问的根目录），支持 .zip、.tar.gz、.tgz、.tar"`
+	Dest      string `json:"dest,omitempty" jsonschema:"解压到的目录，默认为压缩包所在目录下去掉扩展名的同名目录"`
+	Overwrite bool   `json:"overwrite,omitempty" jsonschema:"覆盖已存在的文件，默认遇到已存在的文件时报错"`
+}
+
+// ExtractArchiveOutput 解压的输出
+type ExtractArchiveOutput struct {
+	Dest    string   `json:"dest" jsonschema:"解压到的目录（相对允许访问的根目录）"`
+	Files   int      `json:"files" jsonschema:"解压的文件数"`
+	Bytes   int64    `json:"bytes" jsonschema:"解压的总字节数"`
+	Skipped []string `json:"skipped,omitempty" jsonschema:"跳过的条目（符号链接、设备文件等）"`
+}
+
+// CreateArchiveInput 打包的输入
+type CreateArchiveInput struct {
+	Paths     []string `json:"paths" jsonschema:"要打包的文件或目录（相对允许访问的根目录），目录递归打包"`
+	Output    string   `json:"output" jsonschema:"压缩包路径，按扩展名选择格式：.zip、.tar.gz 或 .tgz"`
+	Overwrite bool     `json:"overwrite,omitempty" jsonschema:"覆盖已存在的压缩包"`
+}
+
+// CreateArchiveOutput 打包的输出
+type CreateArchiveOutput struct {
+	Output string `json:"output" jsonschema:"压缩包路径"`
+	Files  int    `json:"files" jsonschema:"打包的文件数"`
+	Bytes  int64  `json:"bytes" jsonschema:"打包的文件总大小（压缩前）"`
+	Size   int64  `json:"size" jsonschema:"压缩包大小"`
+}
+
+// SetArchiveLimits 设置 extract_archive 和 create_archive 的限制，需在 Start 之前调用
+func (s *MCPServer) SetArchiveLimits(limits ArchiveLimits) {
+	s.archiveLimits = limits
+}
+
+// archiveFormat 按扩展名判断压缩包格式
+func archiveFormat(name string) (format, base string, err error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return formatZip, name[:len(name)-len(".zip")], nil
+	case strings.HasSuffix(lower, ".tar.gz"):
+		return formatTarGz, name[:len(name)-len(".tar.gz")], nil
+	case strings.HasSuffix(lower, ".tgz"):
+		return formatTarGz, name[:len(name)-len(".tgz")], nil
+	case strings.HasSuffix(lower, ".tar"):
+		return formatTar, name[:len(name)-len(".tar")], nil
+	}
+	return "", "", fmt.Errorf("unsupported archive format: %s", filepath.Base(name))
+}
+
+// extractor 一次解压：所有条目都必须落在 dest 之内
+type extractor struct {
+	dest      string
+	overwrite bool
+	limits    ArchiveLimits
+	out       ExtractArchiveOutput
+	entries   int
+}
+
+// target 返回条目在 dest 中的路径，拒绝绝对路径和 ..（zip slip）
+func (e *extractor) target(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	clean := path.Clean("/" + name)[1:]
+	if clean == "" || path.IsAbs(name) || strings.HasPrefix(name, "../") || strings.Contains(name, "/../") || name == ".." {
+		return "", fmt.Errorf("illegal entry path %q", name)
+	}
+	return filepath.Join(e.dest, filepath.FromSlash(clean)), nil
+}
+
+// count 计入一个条目，超出条目数限制时返回错误
+func (e *extractor) count() error {
+	e.entries++
+	if e.entries > e.limits.MaxEntries {
+		return fmt.Errorf("%w: more than %d entries", errArchiveLimit, e.limits.MaxEntries)
+	}
+	return nil
+}
+
+// dir 创建目录条目
+func (e *extractor) dir(name string) error {
+	if err := e.count(); err != nil {
+		return err
+	}
+	target, err := e.target(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(target, 0o755)
+}
+
+// file 写入文件条目，按实际写入的字节数检查大小限制（不信任压缩包头中的大小）
+func (e *extractor) file(name string, mode fs.FileMode, r io.Reader) error {
+	if err := e.count(); err != nil {
+		return err
+	}
+	target, err := e.target(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if e.overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	perm := fs.FileMode(0o644)
+	if mode&0o111 != 0 {
+		perm = 0o755
+	}
+	f, err := os.OpenFile(target, flags, perm)
+	if err != nil {
+		return err
+	}
+	remaining := e.limits.MaxBytes - e.out.Bytes
+	n, err := io.Copy(f, io.LimitReader(r, remaining+1))
+	e.out.Bytes += n
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if n > remaining {
+		return fmt.Errorf("%w: more than %d bytes", errArchiveLimit, e.limits.MaxBytes)
+	}
+	e.out.Files++
+	return nil
+}
+
+// skip 记录跳过的条目
+func (e *extractor) skip(name string) {
+	e.out.Skipped = append(e.out.Skipped, name)
+}
+
+// handleExtractArchive 解压 zip 或 tar(.gz) 压缩包，只解压普通文件和目录
+func (s *MCPServer) handleExtractArchive(ctx context.Context, req *mcp.CallToolRequest, input ExtractArchiveInput) (*mcp.CallToolResult, ExtractArchiveOutput, error) {
+	klog.InfoS("MCP tool called: extract_archive", "path", input.Path, "dest", input.Dest)
+
+	format, base, err := archiveFormat(input.Path)
+	if err != nil {
+		return nil, ExtractArchiveOutput{}, err
+	}
+	src, root, err := s.resolve(input.Path)
+	if err != nil {
+		return nil, ExtractArchiveOutput{}, err
+	}
+	dest := input.Dest
+	if dest == "" {
+		dest = base
+	}
+	destPath, _, err := s.resolve(dest)
+	if err != nil {
+		return nil, ExtractArchiveOutput{}, err
+	}
+
+	e := &extractor{dest: destPath, overwrite: input.Overwrite, limits: s.archiveLimits}
+	if format == formatZip {
+		err = e.extractZip(ctx, src)
+	} else {
+		err = e.extractTar(ctx, src, format == formatTarGz)
+	}
+	if err != nil {
+		// 已解压的文件保留，便于查看是哪个条目出错
+		return nil, ExtractArchiveOutput{}, fmt.Errorf("extract failed after %d files: %w", e.out.Files, err)
+	}
+
+	rel, _ := filepath.Rel(root, destPath)
+	e.out.Dest = filepath.ToSlash(rel)
+	klog.V(3).InfoS("Archive extracted", "path", src, "dest", destPath, "files", e.out.Files, "bytes", e.out.Bytes)
+	return nil, e.out, nil
+}
+
+// extractZip 解压 zip
+func (e *extractor) extractZip(ctx context.Context, src string) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = e.dir(f.Name)
+		case mode.IsRegular():
+			err = e.zipFile(f)
+		default:
+			e.skip(f.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zipFile 解压 zip 中的单个文件
+func (e *extractor) zipFile(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return e.file(f.Name, f.Mode(), rc)
+}
+
+// extractTar 解压 tar，gzipped 为 true 时先解压 gzip
+func (e *extractor) extractTar(ctx context.Context, src string, gzipped bool) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = e.dir(hdr.Name)
+		case tar.TypeReg:
+			err = e.file(hdr.Name, hdr.FileInfo().Mode(), tr)
+		case tar.TypeXGlobalHeader:
+		default:
+			// 符号链接和硬链接可能指向 dest 之外，设备文件等不需要
+			e.skip(hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// archiveWriter 按格式写入条目
+type archiveWriter interface {
+	add(name string, info fs.FileInfo, r io.Reader) error
+	Close() error
+}
+
+// handleCreateArchive 把文件和目录打包为 zip 或 tar.gz，只打包普通文件和目录
+func (s *MCPServer) handleCreateArchive(ctx context.Context, req *mcp.CallToolRequest, input CreateArchiveInput) (*mcp.CallToolResult, CreateArchiveOutput, error) {
+	klog.InfoS("MCP tool called: create_archive", "paths", input.Paths, "output", input.Output)
+
+	if len(input.Paths) == 0 {
+		return nil, CreateArchiveOutput{}, fmt.Errorf("paths is required")
+	}
+	format, _, err := archiveFormat(input.Output)
+	if err != nil {
+		return nil, CreateArchiveOutput{}, err
+	}
+	if format == formatTar {
+		return nil, CreateArchiveOutput{}, fmt.Errorf("unsupported output format: use .zip, .tar.gz or .tgz")
+	}
+	outPath, root, err := s.resolve(input.Output)
+	if err != nil {
+		return nil, CreateArchiveOutput{}, err
+	}
+	var sources []string
+	for _, p := range input.Paths {
+		src, _, err := s.resolve(p)
+		if err != nil {
+			return nil, CreateArchiveOutput{}, err
+		}
+		sources = append(sources, src)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		return nil, CreateArchiveOutput{}, fmt.Errorf("create directory failed: %w", err)
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if input.Overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(outPath, flags, 0o644)
+	if err != nil {
+		return nil, CreateArchiveOutput{}, err
+	}
+
+	var w archiveWriter
+	if format == formatZip {
+		w = &zipWriter{zip.NewWriter(f)}
+	} else {
+		w = newTarGzWriter(f)
+	}
+	out, err := s.writeArchive(ctx, w, root, outPath, sources)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// 不留下不完整的压缩包
+		os.Remove(outPath)
+		return nil, CreateArchiveOutput{}, fmt.Errorf("create archive failed: %w", err)
+	}
+
+	if info, err := os.Stat(outPath); err == nil {
+		out.Size = info.Size()
+	}
+	rel, _ := filepath.Rel(root, outPath)
+	out.Output = filepath.ToSlash(rel)
+	klog.V(3).InfoS("Archive created", "output", outPath, "files", out.Files, "bytes", out.Bytes, "size", out.Size)
+	return nil, out, nil
+}
+
+// writeArchive 把 sources 写入压缩包，条目名为相对 root 的路径；跳过压缩包自身和符号链接
+func (s *MCPServer) writeArchive(ctx context.Context, w archiveWriter, root, outPath string, sources []string) (CreateArchiveOutput, error) {
+	var out CreateArchiveOutput
+	entries := 0
+	for _, src := range sources {
+		err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if p == outPath || !(d.IsDir() || d.Type().IsRegular()) {
+				return nil
+			}
+			entries++
+			if entries > s.archiveLimits.MaxEntries {
+				return fmt.Errorf("%w: more than %d entries", errArchiveLimit, s.archiveLimits.MaxEntries)
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+			if d.IsDir() {
+				return w.add(name+"/", info, nil)
+			}
+			if out.Bytes += info.Size(); out.Bytes > s.archiveLimits.MaxBytes {
+				return fmt.Errorf("%w: more than %d bytes", errArchiveLimit, s.archiveLimits.MaxBytes)
+			}
+			file, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			out.Files++
+			return w.add(name, info, file)
+		})
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// zipWriter 写入 zip
+type zipWriter struct {
+	*zip.Writer
+}
+
+// add 实现 archiveWriter，r 为 nil 时写入目录条目
+func (z *zipWriter) add(name string, info fs.FileInfo, r io.Reader) error {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if r != nil {
+		hdr.Method = zip.Deflate
+	}
+	fw, err := z.CreateHeader(hdr)
+	if err != nil || r == nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
+
+// tarGzWriter 写入 tar.gz
+type tarGzWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+// newTarGzWriter 创建 tar.gz 写入器
+func newTarGzWriter(w io.Writer) *tarGzWriter {
+	gz := gzip.NewWriter(w)
+	return &tarGzWriter{gz: gz, tw: tar.NewWriter(gz)}
+}
+
+// add 实现 archiveWriter，r 为 nil 时写入目录条目
+func (t *tarGzWriter) add(name string, info fs.FileInfo, r io.Reader) error {
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	// 不记录本机的用户信息
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	if err := t.tw.WriteHeader(hdr); err != nil || r == nil {
+		return err
+	}
+	_, err = io.Copy(t.tw, r)
+	return err
+}
+
+// Close 依次关闭 tar 和 gzip
+func (t *tarGzWriter) Close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	return t.gz.Close()
+}
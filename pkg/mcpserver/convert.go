@@ -0,0 +1,92 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/docconv"
+)
+
+// convert_document 的限制
+const (
+	// maxConvertFileSize 转换的文件大小上限
+	maxConvertFileSize  = 20 << 20
+	defaultConvertChars = 50000
+	maxConvertChars     = 200000
+)
+
+// ConvertDocumentInput 转换文档的输入
+type ConvertDocumentInput struct {
+	Path     string `json:"path" jsonschema:"文档路径（相对允许访问的根目录），支持 .html、.htm、.md、.markdown、.pdf 和 .txt"`
+	Format   string `json:"format,omitempty" jsonschema:"文档格式：html、markdown、pdf 或 text，默认按扩展名和内容判断"`
+	Offset   int    `json:"offset,omitempty" jsonschema:"从转换结果的第几个字符开始返回，用于分段读取较长的文档"`
+	MaxChars int    `json:"max_chars,omitempty" jsonschema:"最多返回的字符数，默认 50000，最多 200000"`
+}
+
+// ConvertDocumentOutput 转换文档的输出
+type ConvertDocumentOutput struct {
+	Text          string `json:"text" jsonschema:"纯文本，标题以 # 标记"`
+	Format        string `json:"format" jsonschema:"文档格式"`
+	OriginalBytes int    `json:"original_bytes" jsonschema:"原文件字节数"`
+	TotalChars    int    `json:"total_chars" jsonschema:"转换结果的总字符数"`
+	NextOffset    int    `json:"next_offset,omitempty" jsonschema:"还有剩余内容时下一段的 offset"`
+}
+
+// handleConvertDocument 把 HTML、Markdown 或简单的 PDF 转换为保留标题结构的纯文本
+func (s *MCPServer) handleConvertDocument(ctx context.Context, req *mcp.CallToolRequest, input ConvertDocumentInput) (*mcp.CallToolResult, ConvertDocumentOutput, error) {
+	klog.InfoS("MCP tool called: convert_document", "path", input.Path, "format", input.Format, "offset", input.Offset)
+
+	abs, _, err := s.resolve(input.Path)
+	if err != nil {
+		return nil, ConvertDocumentOutput{}, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, ConvertDocumentOutput{}, err
+	}
+	if info.IsDir() {
+		return nil, ConvertDocumentOutput{}, fmt.Errorf("%s is a directory", input.Path)
+	}
+	if info.Size() > maxConvertFileSize {
+		return nil, ConvertDocumentOutput{}, fmt.Errorf("document too large: %d bytes exceeds limit of %d", info.Size(), maxConvertFileSize)
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, ConvertDocumentOutput{}, fmt.Errorf("read file failed: %w", err)
+	}
+
+	format := input.Format
+	if format == "" {
+		format = docconv.DetectFormat(filepath.Base(abs), data)
+	}
+	if format != docconv.FormatPDF && !utf8.Valid(data) {
+		return nil, ConvertDocumentOutput{}, fmt.Errorf("%s is not a UTF-8 text document", input.Path)
+	}
+	text, err := docconv.Convert(data, format)
+	if err != nil {
+		return nil, ConvertDocumentOutput{}, err
+	}
+
+	maxChars := input.MaxChars
+	if maxChars <= 0 {
+		maxChars = defaultConvertChars
+	}
+	maxChars = min(maxChars, maxConvertChars)
+
+	runes := []rune(text)
+	out := ConvertDocumentOutput{Format: format, OriginalBytes: len(data), TotalChars: len(runes)}
+	offset := min(max(input.Offset, 0), len(runes))
+	end := min(offset+maxChars, len(runes))
+	out.Text = string(runes[offset:end])
+	if end < len(runes) {
+		out.NextOffset = end
+	}
+	klog.V(3).InfoS("Document converted", "path", abs, "format", format, "bytes", len(data), "chars", len(runes))
+	return nil, out, nil
+}
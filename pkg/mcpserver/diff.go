@@ -0,0 +1,129 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aymanbagabas/go-udiff"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+)
+
+// diff_files 的限制
+const (
+	defaultDiffContext = udiff.DefaultContextLines
+	maxDiffContext     = 20
+	// maxDiffFileSize 参与比较的文件大小上限
+	maxDiffFileSize = 2 << 20
+	// maxDiffOutput 返回的 diff 长度上限（字节），超出时截断
+	maxDiffOutput = 64 << 10
+)
+
+// DiffFilesInput 比较文件的输入
+type DiffFilesInput struct {
+	Path    string `json:"path" jsonschema:"原文件路径（相对允许访问的根目录）"`
+	Other   string `json:"other,omitempty" jsonschema:"与之比较的文件路径；为空时与 content 比较"`
+	Content string `json:"content,omitempty" jsonschema:"与之比较的内容，如准备写入的新内容；other 为空时使用"`
+	Context int    `json:"context,omitempty" jsonschema:"每处改动前后的上下文行数，默认 3，最多 20"`
+}
+
+// DiffFilesOutput 比较文件的输出
+type DiffFilesOutput struct {
+	Diff      string `json:"diff" jsonschema:"unified diff 格式的差异，内容相同时为空"`
+	Identical bool   `json:"identical" jsonschema:"内容是否相同"`
+	Added     int    `json:"added" jsonschema:"新增的行数"`
+	Removed   int    `json:"removed" jsonschema:"删除的行数"`
+	Hunks     int    `json:"hunks" jsonschema:"改动块数"`
+	Truncated bool   `json:"truncated,omitempty" jsonschema:"diff 超过 64KB 被截断"`
+}
+
+// handleDiffFiles 生成 path 到 other（或 content）的 unified diff
+func (s *MCPServer) handleDiffFiles(ctx context.Context, req *mcp.CallToolRequest, input DiffFilesInput) (*mcp.CallToolResult, DiffFilesOutput, error) {
+	klog.InfoS("MCP tool called: diff_files", "path", input.Path, "other", input.Other)
+
+	before, fromName, err := s.readDiffFile(input.Path)
+	if err != nil {
+		return nil, DiffFilesOutput{}, err
+	}
+	after, toName := input.Content, "content"
+	if input.Other != "" {
+		if after, toName, err = s.readDiffFile(input.Other); err != nil {
+			return nil, DiffFilesOutput{}, err
+		}
+	}
+
+	contextLines := input.Context
+	if contextLines <= 0 {
+		contextLines = defaultDiffContext
+	}
+	contextLines = min(contextLines, maxDiffContext)
+
+	edits := udiff.Strings(before, after)
+	if len(edits) == 0 {
+		return nil, DiffFilesOutput{Identical: true}, nil
+	}
+	unified, err := udiff.ToUnifiedDiff("a/"+fromName, "b/"+toName, before, edits, contextLines)
+	if err != nil {
+		return nil, DiffFilesOutput{}, fmt.Errorf("generate diff failed: %w", err)
+	}
+
+	out := DiffFilesOutput{Diff: unified.String(), Hunks: len(unified.Hunks)}
+	for _, h := range unified.Hunks {
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case udiff.Insert:
+				out.Added++
+			case udiff.Delete:
+				out.Removed++
+			}
+		}
+	}
+	// 行数统计基于完整的 diff，只截断返回的文本
+	if len(out.Diff) > maxDiffOutput {
+		out.Diff, out.Truncated = truncateLines(out.Diff, maxDiffOutput), true
+	}
+	return nil, out, nil
+}
+
+// readDiffFile 读取参与比较的文本文件，返回内容和相对允许访问的根目录的路径
+func (s *MCPServer) readDiffFile(path string) (content, name string, err error) {
+	abs, root, err := s.resolve(path)
+	if err != nil {
+		return "", "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", "", err
+	}
+	if info.IsDir() {
+		return "", "", fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > maxDiffFileSize {
+		return "", "", fmt.Errorf("%s is too large to diff: %d bytes exceeds limit of %d", path, info.Size(), maxDiffFileSize)
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", "", fmt.Errorf("read file failed: %w", err)
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", "", fmt.Errorf("%s is a binary file", path)
+	}
+	rel, _ := filepath.Rel(root, abs)
+	return string(data), filepath.ToSlash(rel), nil
+}
+
+// truncateLines 截断到不超过 limit 字节的最后一个完整行
+func truncateLines(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	text = text[:limit]
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		text = text[:i+1]
+	}
+	return text
+}
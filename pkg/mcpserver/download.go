@@ -0,0 +1,184 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/egress"
+)
+
+// DownloadFileInput 下载文件的输入
+type DownloadFileInput struct {
+	URL       string `json:"url" jsonschema:"下载地址（http 或 https），主机必须在允许下载的列表中"`
+	Path      string `json:"path" jsonschema:"保存路径（相对允许访问的根目录）；为已存在的目录或以 / 结尾时使用地址中的文件名"`
+	Checksum  string `json:"checksum,omitempty" jsonschema:"期望的校验和，格式为 sha256:<hex> 或 sha512:<hex>，不带前缀时按 sha256；不一致时不保存"`
+	Overwrite bool   `json:"overwrite,omitempty" jsonschema:"覆盖已存在的文件"`
+}
+
+// DownloadFileOutput 下载文件的输出
+type DownloadFileOutput struct {
+	Path        string `json:"path" jsonschema:"保存路径（相对允许访问的根目录）"`
+	Size        int64  `json:"size" jsonschema:"文件大小"`
+	SHA256      string `json:"sha256" jsonschema:"文件的 SHA-256"`
+	ContentType string `json:"content_type,omitempty" jsonschema:"响应的 Content-Type"`
+	Verified    bool   `json:"verified,omitempty" jsonschema:"是否已按 checksum 校验"`
+}
+
+// downloader download_file 的 HTTP 客户端和限制
+type downloader struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// EnableDownload 注册 download_file 工具：请求经过出站策略 policy（应只允许下载的主机），
+// 文件不超过 maxBytes（0 表示不限制），单次下载不超过 timeout。需在 Start 之前调用
+func (s *MCPServer) EnableDownload(policy *egress.Policy, maxBytes int64, timeout time.Duration) {
+	s.downloader = &downloader{client: policy.Client(timeout), maxBytes: maxBytes}
+	destructive, openWorld := true, true
+	mcp.AddTool(s.server, &mcp.Tool{
+		Name:        "download_file",
+		Description: "从允许的主机下载文件到工作目录，可校验 SHA-256/SHA-512，用于获取后续文件操作需要的制品",
+		Annotations: &mcp.ToolAnnotations{DestructiveHint: &destructive, OpenWorldHint: &openWorld},
+	}, s.handleDownloadFile)
+}
+
+// handleDownloadFile 下载到同目录的临时文件，大小和校验和通过后再重命名，失败时不留下不完整的文件
+func (s *MCPServer) handleDownloadFile(ctx context.Context, req *mcp.CallToolRequest, input DownloadFileInput) (*mcp.CallToolResult, DownloadFileOutput, error) {
+	klog.InfoS("MCP tool called: download_file", "url", input.URL, "path", input.Path)
+
+	u, err := url.Parse(input.URL)
+	if err != nil || u.Host == "" {
+		return nil, DownloadFileOutput{}, fmt.Errorf("invalid url: %s", input.URL)
+	}
+	want, newHash, err := parseChecksum(input.Checksum)
+	if err != nil {
+		return nil, DownloadFileOutput{}, err
+	}
+
+	dest, root, err := s.resolve(input.Path)
+	if err != nil {
+		return nil, DownloadFileOutput{}, err
+	}
+	if info, err := os.Stat(dest); (err == nil && info.IsDir()) || strings.HasSuffix(input.Path, "/") {
+		// 地址中的文件名为 ..（包括编码后的 %2e%2e）或包含路径分隔符时会保存到目标目录之外
+		name := path.Base(u.Path)
+		if name == "." || name == ".." || name == "/" || strings.ContainsAny(name, `/\`) {
+			return nil, DownloadFileOutput{}, fmt.Errorf("cannot determine file name from url, specify it in path")
+		}
+		dest = filepath.Join(dest, name)
+	}
+	// 拼接文件名后重新检查保存路径仍在允许访问的根目录下
+	rel, err := filepath.Rel(root, dest)
+	if err != nil {
+		return nil, DownloadFileOutput{}, fmt.Errorf("access denied: path outside allowed root")
+	}
+	if dest, root, err = s.resolve(rel); err != nil {
+		return nil, DownloadFileOutput{}, err
+	}
+	if _, err := os.Stat(dest); err == nil && !input.Overwrite {
+		return nil, DownloadFileOutput{}, fmt.Errorf("%s already exists, set overwrite to replace it", filepath.ToSlash(rel))
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return nil, DownloadFileOutput{}, fmt.Errorf("create directory failed: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, DownloadFileOutput{}, err
+	}
+	resp, err := s.downloader.client.Do(httpReq)
+	if err != nil {
+		return nil, DownloadFileOutput{}, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, DownloadFileOutput{}, fmt.Errorf("download failed: %s", resp.Status)
+	}
+	if limit := s.downloader.maxBytes; limit > 0 && resp.ContentLength > limit {
+		return nil, DownloadFileOutput{}, fmt.Errorf("file too large: %d bytes exceeds limit of %d", resp.ContentLength, limit)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".download-*")
+	if err != nil {
+		return nil, DownloadFileOutput{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	sum := sha256.New()
+	writers := []io.Writer{tmp, sum}
+	var verify hash.Hash
+	if newHash != nil {
+		verify = newHash()
+		writers = append(writers, verify)
+	}
+	size, err := io.Copy(io.MultiWriter(writers...), resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if errors.Is(err, egress.ErrResponseTooLarge) {
+		return nil, DownloadFileOutput{}, fmt.Errorf("file too large: exceeds limit of %d bytes", s.downloader.maxBytes)
+	}
+	if err != nil {
+		return nil, DownloadFileOutput{}, fmt.Errorf("download failed: %w", err)
+	}
+
+	out := DownloadFileOutput{Size: size, SHA256: hex.EncodeToString(sum.Sum(nil)), ContentType: resp.Header.Get("Content-Type")}
+	if verify != nil {
+		if got := hex.EncodeToString(verify.Sum(nil)); got != want {
+			return nil, DownloadFileOutput{}, fmt.Errorf("checksum mismatch: expected %s, got %s", want, got)
+		}
+		out.Verified = true
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return nil, DownloadFileOutput{}, err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return nil, DownloadFileOutput{}, fmt.Errorf("save file failed: %w", err)
+	}
+
+	out.Path = filepath.ToSlash(rel)
+	klog.InfoS("File downloaded", "url", u.Redacted(), "path", dest, "size", size, "sha256", out.SHA256, "verified", out.Verified)
+	return nil, out, nil
+}
+
+// parseChecksum 解析 sha256:<hex> 或 sha512:<hex>，为空时不校验
+func parseChecksum(checksum string) (string, func() hash.Hash, error) {
+	if checksum == "" {
+		return "", nil, nil
+	}
+	algo, value, ok := strings.Cut(checksum, ":")
+	if !ok {
+		algo, value = "sha256", checksum
+	}
+	value = strings.ToLower(strings.TrimSpace(value))
+	var newHash func() hash.Hash
+	var size int
+	switch strings.ToLower(algo) {
+	case "sha256":
+		newHash, size = sha256.New, sha256.Size
+	case "sha512":
+		newHash, size = sha512.New, sha512.Size
+	default:
+		return "", nil, fmt.Errorf("unsupported checksum algorithm %q: use sha256 or sha512", algo)
+	}
+	if b, err := hex.DecodeString(value); err != nil || len(b) != size {
+		return "", nil, fmt.Errorf("invalid %s checksum: %s", algo, value)
+	}
+	return value, newHash, nil
+}
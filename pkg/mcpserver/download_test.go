@@ -0,0 +1,78 @@
+package mcpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/egress"
+)
+
+// newDownloadServer 创建允许访问 parent/root 的 MCP Server，download_file 允许下载本机测试服务器上的文件，
+// requests 记录测试服务器收到的请求数
+func newDownloadServer(t *testing.T) (s *MCPServer, srv *httptest.Server, parent string, requests *atomic.Int32) {
+	t.Helper()
+	requests = new(atomic.Int32)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("content"))
+	}))
+	t.Cleanup(srv.Close)
+
+	parent = t.TempDir()
+	root := filepath.Join(parent, "root")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewMCPServer(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := egress.New(config.EgressConfig{AllowedHosts: []string{"127.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.EnableDownload(policy, 0, 10*time.Second)
+	return s, srv, parent, requests
+}
+
+func TestDownloadFileName(t *testing.T) {
+	s, srv, _, _ := newDownloadServer(t)
+	_, out, err := s.handleDownloadFile(t.Context(), nil, DownloadFileInput{URL: srv.URL + "/dist/tool.tar.gz", Path: "downloads/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Path != "downloads/tool.tar.gz" || out.Size != int64(len("content")) {
+		t.Fatalf("got %+v", out)
+	}
+}
+
+func TestDownloadFileNameOutsideRoot(t *testing.T) {
+	for _, rawURL := range []string{"/..", "/%2e%2e", "/dist/..", "/dist/%2E%2E", "/dist%2f..%2f.."} {
+		t.Run(rawURL, func(t *testing.T) {
+			s, srv, parent, requests := newDownloadServer(t)
+			input := DownloadFileInput{URL: srv.URL + rawURL, Path: "", Overwrite: true}
+			if _, out, err := s.handleDownloadFile(t.Context(), nil, input); err == nil {
+				t.Fatalf("download to %q succeeded, want error", out.Path)
+			}
+			// 保存路径在下载之前就应被拒绝，否则临时文件会创建在根目录之外
+			if n := requests.Load(); n != 0 {
+				t.Fatalf("sent %d download requests, want the path to be rejected first", n)
+			}
+			entries, err := os.ReadDir(parent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				if e.Name() != "root" {
+					t.Fatalf("file %s written outside the allowed root", e.Name())
+				}
+			}
+		})
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/secretscan"
 )
 
 // ReadFileInput 读取文件的输入
@@ -30,6 +32,7 @@ type WriteFileInput struct {
 // WriteFileOutput 写入文件的输出
 type WriteFileOutput struct {
 	Message string `json:"message" jsonschema:"操作结果消息"`
+	Warning string `json:"warning,omitempty" jsonschema:"写入内容的安全警告，如疑似包含凭证"`
 }
 
 // ListDirectoryInput 列出目录的输入
@@ -51,7 +54,12 @@ type DirectoryEntry struct {
 // MCPServer MCP 服务器实现
 type MCPServer struct {
 	server    *mcp.Server
-	allowRoot string // 允许访问的根目录
+	allowRoot string              // 允许访问的根目录
+	scanner   *secretscan.Scanner // 写入前的凭证扫描，为空时不扫描
+	// archiveLimits 解压和打包的大小、条目数限制
+	archiveLimits ArchiveLimits
+	// downloader download_file 使用的客户端，未启用时为空
+	downloader *downloader
 }
 
 // NewMCPServer 创建 MCP 服务器
@@ -70,7 +78,8 @@ func NewMCPServer(allowRoot string) (*MCPServer, error) {
 	}
 
 	s := &MCPServer{
-		allowRoot: allowRoot,
+		allowRoot:     allowRoot,
+		archiveLimits: ArchiveLimits{MaxBytes: DefaultArchiveMaxBytes, MaxEntries: DefaultArchiveMaxEntries},
 	}
 
 	// 创建 MCP Server
@@ -90,23 +99,100 @@ func NewMCPServer(allowRoot string) (*MCPServer, error) {
 
 // registerTools 注册所有工具
 func (s *MCPServer) registerTools() {
+	// 工具注解，客户端据此提示模型哪些工具会修改文件
+	destructive := true
+
 	// 注册 read_file 工具
 	mcp.AddTool(s.server, &mcp.Tool{
 		Name:        "read_file",
 		Description: "读取文件内容",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
 	}, s.handleReadFile)
 
 	// 注册 write_file 工具
 	mcp.AddTool(s.server, &mcp.Tool{
 		Name:        "write_file",
 		Description: "写入文件内容",
+		Annotations: &mcp.ToolAnnotations{DestructiveHint: &destructive},
 	}, s.handleWriteFile)
 
 	// 注册 list_directory 工具
 	mcp.AddTool(s.server, &mcp.Tool{
 		Name:        "list_directory",
 		Description: "列出目录内容",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
 	}, s.handleListDirectory)
+
+	// 注册 file_inventory 工具
+	mcp.AddTool(s.server, &mcp.Tool{
+		Name:        "file_inventory",
+		Description: "递归列出目录下的源码和文档文件及大小，跳过依赖目录、构建产物和二进制文件",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, s.handleFileInventory)
+
+	// 注册 grep_content 工具
+	mcp.AddTool(s.server, &mcp.Tool{
+		Name:        "grep_content",
+		Description: "按正则表达式（RE2）搜索文件内容，返回匹配的文件、行号和上下文行；用于查找函数定义、调用位置和报错信息，按文件名查找请用 file_inventory",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, s.handleGrepContent)
+
+	// 注册 convert_document 工具
+	mcp.AddTool(s.server, &mcp.Tool{
+		Name:        "convert_document",
+		Description: "把 HTML、Markdown 或简单的 PDF 转换为纯文本（标题以 # 标记），去掉标签和格式标记，比直接读取原文件节省上下文",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, s.handleConvertDocument)
+
+	// 注册 outline_code 工具
+	mcp.AddTool(s.server, &mcp.Tool{
+		Name:        "outline_code",
+		Description: "解析 Go 文件或目录，返回包、类型（含字段和接口方法）、函数签名和文档注释，不含函数体；用于了解代码结构，需要实现细节时再用 read_file",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, s.handleOutlineCode)
+
+	// 注册 watch_path 工具
+	mcp.AddTool(s.server, &mcp.Tool{
+		Name:        "watch_path",
+		Description: "在限定时间内（默认 30s，最多 5m）监视文件或目录，返回新建、修改和删除的文件；默认发现变化后立即返回，用于等待构建产物或日志更新",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, s.handleWatchPath)
+
+	// 注册 read_image 工具
+	mcp.AddTool(s.server, &mcp.Tool{
+		Name:        "read_image",
+		Description: "读取图片（PNG、JPEG、GIF、WebP）并以图片内容返回，过大时等比缩小；模型为视觉模型时可以直接查看图片",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, s.handleReadImage)
+
+	// 注册 diff_files 工具
+	mcp.AddTool(s.server, &mcp.Tool{
+		Name:        "diff_files",
+		Description: "生成两个文件（或文件与给定内容）之间的 unified diff，用于确认修改结果或说明改动，无需完整读取两个文件",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, s.handleDiffFiles)
+
+	// 注册 extract_archive 和 create_archive 工具
+	mcp.AddTool(s.server, &mcp.Tool{
+		Name:        "extract_archive",
+		Description: "解压 zip、tar.gz 或 tar 压缩包，只解压普通文件和目录，解压大小和文件数有上限",
+		Annotations: &mcp.ToolAnnotations{DestructiveHint: &destructive},
+	}, s.handleExtractArchive)
+	mcp.AddTool(s.server, &mcp.Tool{
+		Name:        "create_archive",
+		Description: "把文件和目录打包为 zip 或 tar.gz 压缩包，格式由输出文件的扩展名决定",
+		Annotations: &mcp.ToolAnnotations{DestructiveHint: &destructive},
+	}, s.handleCreateArchive)
+}
+
+// SetSecretScanner 设置 write_file 写入前的凭证扫描，需在 Start 之前调用
+func (s *MCPServer) SetSecretScanner(scanner *secretscan.Scanner) {
+	s.scanner = scanner
+}
+
+// MCP 返回底层 MCP Server，用于注册额外的工具集
+func (s *MCPServer) MCP() *mcp.Server {
+	return s.server
 }
 
 // Start 启动 MCP 服务器
@@ -172,6 +258,12 @@ func (s *MCPServer) handleWriteFile(ctx context.Context, req *mcp.CallToolReques
 		return nil, WriteFileOutput{}, fmt.Errorf("access denied: path outside allowed root")
 	}
 
+	// 扫描内容中的凭证
+	warning, err := s.scanner.Check(input.Path, input.Content)
+	if err != nil {
+		return nil, WriteFileOutput{}, err
+	}
+
 	klog.V(3).InfoS("Writing file", "path", absPath, "size", len(input.Content))
 
 	// 确保目录存在
@@ -186,7 +278,7 @@ func (s *MCPServer) handleWriteFile(ctx context.Context, req *mcp.CallToolReques
 	}
 
 	msg := fmt.Sprintf("Successfully wrote %d bytes to %s", len(input.Content), input.Path)
-	return nil, WriteFileOutput{Message: msg}, nil
+	return nil, WriteFileOutput{Message: msg, Warning: warning}, nil
 }
 
 // handleListDirectory 处理目录列表请求
@@ -0,0 +1,247 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+)
+
+// grep_content 的默认值和上限
+const (
+	defaultGrepPerFile = 20
+	defaultGrepTotal   = 200
+	maxGrepContext     = 10
+	// maxGrepFileSize 超过该大小的文件不搜索（通常是生成的代码或数据文件）
+	maxGrepFileSize = 2 << 20
+	// maxGrepLineLength 返回的每行最多保留的字符数，避免压缩后的单行文件占满结果
+	maxGrepLineLength = 500
+)
+
+// GrepContentInput 内容搜索的输入
+type GrepContentInput struct {
+	Pattern    string   `json:"pattern" jsonschema:"RE2 正则表达式，如 func\\s+New\\w*\\("`
+	Path       string   `json:"path,omitempty" jsonschema:"搜索的目录或文件（相对允许访问的根目录），默认为根目录"`
+	IgnoreCase bool     `json:"ignore_case,omitempty" jsonschema:"忽略大小写"`
+	Include    []string `json:"include,omitempty" jsonschema:"只搜索文件名匹配这些模式的文件，如 [\"*.go\"]"`
+	Ignore     []string `json:"ignore,omitempty" jsonschema:"跳过匹配这些模式的文件和目录（匹配名称或相对路径），如 [\"*_test.go\", \"docs/*\"]"`
+	Before     int      `json:"before,omitempty" jsonschema:"每个匹配前附带的行数，最多 10"`
+	After      int      `json:"after,omitempty" jsonschema:"每个匹配后附带的行数，最多 10"`
+	MaxPerFile int      `json:"max_per_file,omitempty" jsonschema:"每个文件最多返回的匹配数，默认 20"`
+	MaxMatches int      `json:"max_matches,omitempty" jsonschema:"最多返回的匹配总数，默认 200"`
+}
+
+// GrepMatch 一处匹配
+type GrepMatch struct {
+	Path   string   `json:"path" jsonschema:"文件路径（相对允许访问的根目录，可直接传给 read_file）"`
+	Line   int      `json:"line" jsonschema:"行号，从 1 开始"`
+	Text   string   `json:"text" jsonschema:"匹配的行"`
+	Before []string `json:"before,omitempty" jsonschema:"匹配前的上下文行"`
+	After  []string `json:"after,omitempty" jsonschema:"匹配后的上下文行"`
+}
+
+// GrepContentOutput 内容搜索的输出
+type GrepContentOutput struct {
+	Matches   []GrepMatch `json:"matches" jsonschema:"匹配列表，按文件路径和行号排列"`
+	Files     int         `json:"files" jsonschema:"包含匹配的文件数"`
+	Truncated bool        `json:"truncated,omitempty" jsonschema:"匹配数达到上限，结果不完整，可缩小 path 或收紧 pattern"`
+}
+
+// resolve 把相对允许访问的根目录的路径解析为绝对路径，同时返回根目录的绝对路径；路径在根目录之外时返回错误
+func (s *MCPServer) resolve(path string) (abs, root string, err error) {
+	root, err = filepath.Abs(s.allowRoot)
+	if err != nil {
+		return "", "", fmt.Errorf("resolve allow root failed: %w", err)
+	}
+	abs, err = filepath.Abs(filepath.Join(s.allowRoot, path))
+	if err != nil {
+		return "", "", fmt.Errorf("resolve path failed: %w", err)
+	}
+	if rel, err := filepath.Rel(root, abs); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("access denied: path outside allowed root")
+	}
+	return abs, root, nil
+}
+
+// handleGrepContent 按正则搜索文件内容，跳过隐藏文件、依赖目录、构建产物和二进制文件
+func (s *MCPServer) handleGrepContent(ctx context.Context, req *mcp.CallToolRequest, input GrepContentInput) (*mcp.CallToolResult, GrepContentOutput, error) {
+	klog.InfoS("MCP tool called: grep_content", "pattern", input.Pattern, "path", input.Path)
+
+	pattern := input.Pattern
+	if input.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, GrepContentOutput{}, fmt.Errorf("invalid pattern: %w", err)
+	}
+	for _, p := range append(input.Include, input.Ignore...) {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, GrepContentOutput{}, fmt.Errorf("invalid file pattern %q: %w", p, err)
+		}
+	}
+	absPath, root, err := s.resolve(input.Path)
+	if err != nil {
+		return nil, GrepContentOutput{}, err
+	}
+
+	g := &grepper{
+		re:         re,
+		root:       root,
+		include:    input.Include,
+		ignore:     input.Ignore,
+		before:     min(max(input.Before, 0), maxGrepContext),
+		after:      min(max(input.After, 0), maxGrepContext),
+		maxPerFile: input.MaxPerFile,
+		maxTotal:   input.MaxMatches,
+	}
+	if g.maxPerFile <= 0 {
+		g.maxPerFile = defaultGrepPerFile
+	}
+	if g.maxTotal <= 0 {
+		g.maxTotal = defaultGrepTotal
+	}
+
+	out := GrepContentOutput{Matches: []GrepMatch{}}
+	err = filepath.WalkDir(absPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			klog.V(3).InfoS("Skipping unreadable path", "path", path, "err", err)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		name := d.Name()
+		if d.IsDir() {
+			if path != absPath && (skippedDirs[name] || strings.HasPrefix(name, ".") || g.ignored(name, rel)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// 直接指定的文件不按隐藏文件和扩展名过滤
+		if path != absPath && (strings.HasPrefix(name, ".") || binaryExts[strings.ToLower(filepath.Ext(name))] || !g.included(name) || g.ignored(name, rel)) {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		matches := g.searchFile(path, rel)
+		if len(matches) == 0 {
+			return nil
+		}
+		out.Files++
+		if room := g.maxTotal - len(out.Matches); len(matches) > room {
+			matches = matches[:room]
+			out.Truncated = true
+		}
+		out.Matches = append(out.Matches, matches...)
+		if out.Truncated || len(out.Matches) >= g.maxTotal {
+			out.Truncated = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, GrepContentOutput{}, fmt.Errorf("search failed: %w", err)
+	}
+
+	klog.V(3).InfoS("Content search done", "pattern", input.Pattern, "matches", len(out.Matches), "files", out.Files)
+	return nil, out, nil
+}
+
+// grepper 一次内容搜索的参数
+type grepper struct {
+	re                   *regexp.Regexp
+	root                 string
+	include, ignore      []string
+	before, after        int
+	maxPerFile, maxTotal int
+}
+
+// included 文件名是否匹配 include（为空时都匹配）
+func (g *grepper) included(name string) bool {
+	if len(g.include) == 0 {
+		return true
+	}
+	for _, p := range g.include {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ignored 名称或相对路径是否匹配 ignore 中的任一模式
+func (g *grepper) ignored(name, rel string) bool {
+	for _, p := range g.ignore {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// searchFile 搜索单个文件，跳过过大和看起来是二进制的文件；读取失败时视为没有匹配
+func (g *grepper) searchFile(path, rel string) []GrepMatch {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxGrepFileSize {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil
+	}
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	var matches []GrepMatch
+	for i, line := range lines {
+		if !g.re.MatchString(line) {
+			continue
+		}
+		matches = append(matches, GrepMatch{
+			Path:   rel,
+			Line:   i + 1,
+			Text:   clipLine(line),
+			Before: clipLines(lines[max(i-g.before, 0):i]),
+			After:  clipLines(lines[i+1 : min(i+1+g.after, len(lines))]),
+		})
+		if len(matches) >= g.maxPerFile {
+			break
+		}
+	}
+	return matches
+}
+
+// clipLine 截断过长的行
+func clipLine(line string) string {
+	if runes := []rune(line); len(runes) > maxGrepLineLength {
+		return string(runes[:maxGrepLineLength]) + "…"
+	}
+	return line
+}
+
+// clipLines 截断每一行，空切片返回 nil
+func clipLines(lines []string) []string {
+	if len(lines) == 0 {
+		return nil
+	}
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = clipLine(line)
+	}
+	return result
+}
@@ -0,0 +1,199 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+)
+
+// read_image 的限制
+const (
+	// defaultImageMaxDimension 默认的最长边像素数，与常见视觉模型的输入分辨率相当
+	defaultImageMaxDimension = 1568
+	maxImageMaxDimension     = 4096
+	// maxImageFileSize 读取的图片文件大小上限
+	maxImageFileSize = 20 << 20
+	// maxImageOutput 返回给模型的图片大小上限，超出时降低 JPEG 质量重新编码
+	maxImageOutput = 4 << 20
+)
+
+// ReadImageInput 读取图片的输入
+type ReadImageInput struct {
+	Path         string `json:"path" jsonschema:"图片路径（相对允许访问的根目录），支持 PNG、JPEG、GIF、WebP"`
+	MaxDimension int    `json:"max_dimension,omitempty" jsonschema:"最长边的像素数，超出时等比缩小，默认 1568，最多 4096"`
+}
+
+// ReadImageOutput 读取图片的输出，图片本身以 image 内容返回
+type ReadImageOutput struct {
+	Path           string `json:"path" jsonschema:"图片路径"`
+	MediaType      string `json:"media_type" jsonschema:"返回的图片格式"`
+	Width          int    `json:"width,omitempty" jsonschema:"返回的图片宽度（WebP 为 0）"`
+	Height         int    `json:"height,omitempty" jsonschema:"返回的图片高度（WebP 为 0）"`
+	OriginalWidth  int    `json:"original_width,omitempty" jsonschema:"原图宽度"`
+	OriginalHeight int    `json:"original_height,omitempty" jsonschema:"原图高度"`
+	Size           int    `json:"size" jsonschema:"返回的图片字节数"`
+	Resized        bool   `json:"resized,omitempty" jsonschema:"是否缩小或重新编码"`
+}
+
+// handleReadImage 读取图片，按 max_dimension 等比缩小后作为 image 内容返回，供视觉模型查看
+func (s *MCPServer) handleReadImage(ctx context.Context, req *mcp.CallToolRequest, input ReadImageInput) (*mcp.CallToolResult, ReadImageOutput, error) {
+	klog.InfoS("MCP tool called: read_image", "path", input.Path, "maxDimension", input.MaxDimension)
+
+	abs, root, err := s.resolve(input.Path)
+	if err != nil {
+		return nil, ReadImageOutput{}, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, ReadImageOutput{}, err
+	}
+	if info.IsDir() {
+		return nil, ReadImageOutput{}, fmt.Errorf("%s is a directory", input.Path)
+	}
+	if info.Size() > maxImageFileSize {
+		return nil, ReadImageOutput{}, fmt.Errorf("image too large: %d bytes exceeds limit of %d", info.Size(), maxImageFileSize)
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, ReadImageOutput{}, fmt.Errorf("read file failed: %w", err)
+	}
+
+	maxDimension := input.MaxDimension
+	if maxDimension <= 0 {
+		maxDimension = defaultImageMaxDimension
+	}
+	maxDimension = min(maxDimension, maxImageMaxDimension)
+
+	rel, _ := filepath.Rel(root, abs)
+	out := ReadImageOutput{Path: filepath.ToSlash(rel)}
+	data, err = fitImage(data, maxDimension, &out)
+	if err != nil {
+		return nil, ReadImageOutput{}, err
+	}
+	out.Size = len(data)
+
+	// 文本内容与结构化输出相同，图片附在其后
+	text, err := json.Marshal(out)
+	if err != nil {
+		return nil, ReadImageOutput{}, err
+	}
+	result := &mcp.CallToolResult{Content: []mcp.Content{
+		&mcp.TextContent{Text: string(text)},
+		&mcp.ImageContent{Data: data, MIMEType: out.MediaType},
+	}}
+	klog.V(3).InfoS("Image read", "path", abs, "type", out.MediaType, "size", out.Size, "resized", out.Resized)
+	return result, out, nil
+}
+
+// fitImage 把图片缩小到最长边不超过 maxDimension、大小不超过 maxImageOutput，并填写 out 中的格式和尺寸。
+// WebP 无法解码，只检查大小后原样返回
+func fitImage(data []byte, maxDimension int, out *ReadImageOutput) ([]byte, error) {
+	out.MediaType = http.DetectContentType(data)
+	switch out.MediaType {
+	case "image/png", "image/jpeg", "image/gif":
+	case "image/webp":
+		if len(data) > maxImageOutput {
+			return nil, fmt.Errorf("webp image too large: %d bytes exceeds limit of %d", len(data), maxImageOutput)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported image type: %s", out.MediaType)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image failed: %w", err)
+	}
+	b := img.Bounds()
+	out.OriginalWidth, out.OriginalHeight = b.Dx(), b.Dy()
+	out.Width, out.Height = out.OriginalWidth, out.OriginalHeight
+
+	// GIF 只返回第一帧，统一重新编码为 PNG
+	if longest := max(b.Dx(), b.Dy()); longest > maxDimension || out.MediaType == "image/gif" {
+		if longest > maxDimension {
+			out.Width = max(b.Dx()*maxDimension/longest, 1)
+			out.Height = max(b.Dy()*maxDimension/longest, 1)
+			img = scaleImage(img, out.Width, out.Height)
+		}
+		if out.MediaType == "image/jpeg" {
+			data, err = encodeJPEG(img, 90)
+		} else {
+			out.MediaType = "image/png"
+			data, err = encodePNG(img)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out.Resized = true
+	}
+	if len(data) > maxImageOutput {
+		// 截图等大尺寸 PNG 改用 JPEG，降低质量直到满足大小限制
+		for _, quality := range []int{80, 60, 40} {
+			if data, err = encodeJPEG(img, quality); err != nil {
+				return nil, err
+			}
+			if len(data) <= maxImageOutput {
+				break
+			}
+		}
+		if len(data) > maxImageOutput {
+			return nil, fmt.Errorf("image too large after re-encoding: %d bytes, try a smaller max_dimension", len(data))
+		}
+		out.MediaType, out.Resized = "image/jpeg", true
+	}
+	return data, nil
+}
+
+// scaleImage 按区域平均等比缩小图片
+func scaleImage(src image.Image, width, height int) *image.RGBA64 {
+	b := src.Bounds()
+	dst := image.NewRGBA64(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/height, y0+1)
+		for x := range width {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := max(b.Min.X+(x+1)*b.Dx()/width, x0+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// encodeJPEG 以指定质量编码为 JPEG
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("encode jpeg failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodePNG 编码为 PNG
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode png failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,115 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+)
+
+// defaultInventoryFiles file_inventory 默认最多返回的文件数
+const defaultInventoryFiles = 2000
+
+// skippedDirs 清单中跳过的目录：版本控制、依赖和构建产物
+var skippedDirs = map[string]bool{
+	".git": true, ".hg": true, ".svn": true, ".idea": true, ".vscode": true,
+	"vendor": true, "node_modules": true, "third_party": true, "__pycache__": true, ".venv": true, "venv": true,
+	"dist": true, "build": true, "target": true, "bin": true, "out": true, ".next": true, ".cache": true,
+}
+
+// binaryExts 清单中跳过的二进制文件扩展名
+var binaryExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".ico": true, ".svg": true, ".webp": true, ".pdf": true,
+	".zip": true, ".gz": true, ".tgz": true, ".tar": true, ".jar": true, ".war": true, ".7z": true,
+	".exe": true, ".dll": true, ".so": true, ".dylib": true, ".a": true, ".o": true, ".class": true, ".pyc": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".eot": true, ".mp3": true, ".mp4": true, ".mov": true,
+	".db": true, ".sqlite": true, ".bin": true, ".wasm": true,
+}
+
+// FileInventoryInput 文件清单的输入
+type FileInventoryInput struct {
+	Path     string `json:"path" jsonschema:"目录路径（相对允许访问的根目录）"`
+	MaxFiles int    `json:"max_files,omitempty" jsonschema:"最多返回的文件数，默认 2000"`
+}
+
+// InventoryFile 清单中的文件
+type InventoryFile struct {
+	Path string `json:"path" jsonschema:"文件路径（相对允许访问的根目录，可直接传给 read_file）"`
+	Size int64  `json:"size" jsonschema:"文件大小（字节）"`
+}
+
+// FileInventoryOutput 文件清单的输出
+type FileInventoryOutput struct {
+	Files     []InventoryFile `json:"files" jsonschema:"文件列表"`
+	Truncated bool            `json:"truncated,omitempty" jsonschema:"文件数超过 max_files，清单不完整"`
+}
+
+// handleFileInventory 递归列出目录下的文件及大小，跳过隐藏文件和目录、依赖目录、构建产物和二进制文件
+func (s *MCPServer) handleFileInventory(ctx context.Context, req *mcp.CallToolRequest, input FileInventoryInput) (*mcp.CallToolResult, FileInventoryOutput, error) {
+	klog.InfoS("MCP tool called: file_inventory", "path", input.Path)
+
+	allowedPath, err := filepath.Abs(s.allowRoot)
+	if err != nil {
+		return nil, FileInventoryOutput{}, fmt.Errorf("resolve allow root failed: %w", err)
+	}
+	absPath, err := filepath.Abs(filepath.Join(s.allowRoot, input.Path))
+	if err != nil {
+		return nil, FileInventoryOutput{}, fmt.Errorf("resolve path failed: %w", err)
+	}
+	if rel, err := filepath.Rel(allowedPath, absPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, FileInventoryOutput{}, fmt.Errorf("access denied: path outside allowed root")
+	}
+
+	maxFiles := input.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultInventoryFiles
+	}
+
+	var out FileInventoryOutput
+	err = filepath.WalkDir(absPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 无权限等错误跳过该项，不中断遍历
+			klog.V(3).InfoS("Skipping unreadable path", "path", path, "err", err)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != absPath && (skippedDirs[name] || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// 隐藏文件（如 .env）可能包含凭证，不列出
+		if !d.Type().IsRegular() || strings.HasPrefix(name, ".") || binaryExts[strings.ToLower(filepath.Ext(name))] {
+			return nil
+		}
+		if len(out.Files) >= maxFiles {
+			out.Truncated = true
+			return filepath.SkipAll
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(allowedPath, path)
+		if err != nil {
+			return nil
+		}
+		out.Files = append(out.Files, InventoryFile{Path: filepath.ToSlash(rel), Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, FileInventoryOutput{}, fmt.Errorf("walk directory failed: %w", err)
+	}
+
+	klog.V(3).InfoS("File inventory built", "path", absPath, "files", len(out.Files), "truncated", out.Truncated)
+	return nil, out, nil
+}
@@ -0,0 +1,339 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+)
+
+// outline_code 的限制
+const (
+	maxOutlineFiles   = 500
+	maxOutlineSymbols = 3000
+	// maxOutlineDoc 文档注释的最大字符数，超出时只保留第一段并截断
+	maxOutlineDoc = 300
+	// maxOutlineSignature 签名的最大字符数
+	maxOutlineSignature = 300
+)
+
+// 符号类型
+const (
+	SymbolFunc      = "func"
+	SymbolMethod    = "method"
+	SymbolStruct    = "struct"
+	SymbolInterface = "interface"
+	SymbolType      = "type"
+	SymbolConst     = "const"
+	SymbolVar       = "var"
+)
+
+// OutlineCodeInput 代码大纲的输入
+type OutlineCodeInput struct {
+	Path         string `json:"path" jsonschema:"Go 文件或目录（相对允许访问的根目录），目录递归解析"`
+	ExportedOnly bool   `json:"exported_only,omitempty" jsonschema:"只返回导出的符号"`
+	IncludeTests bool   `json:"include_tests,omitempty" jsonschema:"包含 _test.go 文件"`
+	NoDoc        bool   `json:"no_doc,omitempty" jsonschema:"不返回文档注释，进一步缩短结果"`
+}
+
+// OutlineCodeOutput 代码大纲的输出
+type OutlineCodeOutput struct {
+	Packages  []PackageOutline `json:"packages" jsonschema:"按目录和包名分组的大纲"`
+	Files     int              `json:"files" jsonschema:"解析的文件数"`
+	Errors    []string         `json:"errors,omitempty" jsonschema:"无法解析的文件"`
+	Truncated bool             `json:"truncated,omitempty" jsonschema:"文件或符号超出上限，结果不完整"`
+}
+
+// PackageOutline 包的大纲
+type PackageOutline struct {
+	Name    string          `json:"name" jsonschema:"包名"`
+	Dir     string          `json:"dir" jsonschema:"包所在目录（相对允许访问的根目录）"`
+	Doc     string          `json:"doc,omitempty" jsonschema:"包文档注释"`
+	Files   []string        `json:"files" jsonschema:"包中的文件名"`
+	Symbols []SymbolOutline `json:"symbols" jsonschema:"包中的符号，按文件和行号排列"`
+}
+
+// SymbolOutline 顶层声明
+type SymbolOutline struct {
+	Kind      string   `json:"kind" jsonschema:"类型：func、method、struct、interface、type、const 或 var"`
+	Name      string   `json:"name" jsonschema:"名称，方法为 接收者类型.方法名"`
+	Signature string   `json:"signature" jsonschema:"声明签名，不含函数体"`
+	Members   []string `json:"members,omitempty" jsonschema:"结构体字段或接口方法"`
+	Doc       string   `json:"doc,omitempty" jsonschema:"文档注释"`
+	File      string   `json:"file" jsonschema:"文件名"`
+	Line      int      `json:"line" jsonschema:"行号"`
+}
+
+// handleOutlineCode 用 go/parser 解析 Go 文件，返回包、类型、函数及其文档注释，不返回函数体
+func (s *MCPServer) handleOutlineCode(ctx context.Context, req *mcp.CallToolRequest, input OutlineCodeInput) (*mcp.CallToolResult, OutlineCodeOutput, error) {
+	klog.InfoS("MCP tool called: outline_code", "path", input.Path, "exportedOnly", input.ExportedOnly)
+
+	abs, root, err := s.resolve(input.Path)
+	if err != nil {
+		return nil, OutlineCodeOutput{}, err
+	}
+	files, truncated, err := goFiles(abs, input.IncludeTests)
+	if err != nil {
+		return nil, OutlineCodeOutput{}, err
+	}
+	if len(files) == 0 {
+		return nil, OutlineCodeOutput{}, fmt.Errorf("no Go files found under %s (only Go is supported)", input.Path)
+	}
+
+	o := &outliner{fset: token.NewFileSet(), exportedOnly: input.ExportedOnly, noDoc: input.NoDoc}
+	out := OutlineCodeOutput{Packages: []PackageOutline{}, Truncated: truncated}
+	packages := make(map[string]*PackageOutline)
+	var order []string
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, OutlineCodeOutput{}, err
+		}
+		rel, _ := filepath.Rel(root, path)
+		f, err := parser.ParseFile(o.fset, path, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			out.Errors = append(out.Errors, fmt.Sprintf("%s: %v", filepath.ToSlash(rel), err))
+			continue
+		}
+		out.Files++
+
+		dir := filepath.ToSlash(filepath.Dir(rel))
+		key := dir + "\x00" + f.Name.Name
+		pkg, ok := packages[key]
+		if !ok {
+			pkg = &PackageOutline{Name: f.Name.Name, Dir: dir, Symbols: []SymbolOutline{}}
+			packages[key] = pkg
+			order = append(order, key)
+		}
+		pkg.Files = append(pkg.Files, filepath.Base(path))
+		if f.Doc != nil && pkg.Doc == "" && !input.NoDoc {
+			pkg.Doc = docText(f.Doc)
+		}
+
+		symbols := o.file(f, filepath.Base(path))
+		if room := maxOutlineSymbols - o.count; len(symbols) > room {
+			symbols, out.Truncated = symbols[:room], true
+		}
+		o.count += len(symbols)
+		pkg.Symbols = append(pkg.Symbols, symbols...)
+		if out.Truncated && o.count >= maxOutlineSymbols {
+			break
+		}
+	}
+	for _, key := range order {
+		out.Packages = append(out.Packages, *packages[key])
+	}
+	klog.V(3).InfoS("Code outline done", "path", abs, "files", out.Files, "symbols", o.count)
+	return nil, out, nil
+}
+
+// goFiles 返回 path 下的 Go 文件，跳过隐藏目录、依赖目录和构建产物；超过上限时截断
+func goFiles(path string, includeTests bool) ([]string, bool, error) {
+	var files []string
+	truncated := false
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if p != path && (skippedDirs[name] || strings.HasPrefix(name, ".") || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || filepath.Ext(name) != ".go" || (!includeTests && strings.HasSuffix(name, "_test.go")) {
+			return nil
+		}
+		if len(files) >= maxOutlineFiles {
+			truncated = true
+			return filepath.SkipAll
+		}
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return files, truncated, nil
+}
+
+// outliner 提取顶层声明
+type outliner struct {
+	fset         *token.FileSet
+	exportedOnly bool
+	noDoc        bool
+	count        int
+}
+
+// file 提取文件中的顶层声明
+func (o *outliner) file(f *ast.File, name string) []SymbolOutline {
+	var symbols []SymbolOutline
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if o.exportedOnly && !d.Name.IsExported() {
+				continue
+			}
+			symbols = append(symbols, o.funcDecl(d, name))
+		case *ast.GenDecl:
+			symbols = append(symbols, o.genDecl(d, name)...)
+		}
+	}
+	return symbols
+}
+
+// funcDecl 函数或方法的签名，不含函数体
+func (o *outliner) funcDecl(d *ast.FuncDecl, file string) SymbolOutline {
+	sym := SymbolOutline{Kind: SymbolFunc, Name: d.Name.Name, File: file, Line: o.line(d.Pos()), Doc: o.doc(d.Doc)}
+	if d.Recv != nil && len(d.Recv.List) > 0 {
+		sym.Kind = SymbolMethod
+		sym.Name = receiverType(d.Recv.List[0].Type) + "." + d.Name.Name
+	}
+	sig := *d
+	sig.Body, sig.Doc = nil, nil
+	sym.Signature = o.node(&sig)
+	return sym
+}
+
+// genDecl 类型、常量和变量声明，分组声明中的每一项各为一个符号
+func (o *outliner) genDecl(d *ast.GenDecl, file string) []SymbolOutline {
+	var symbols []SymbolOutline
+	for _, spec := range d.Specs {
+		switch sp := spec.(type) {
+		case *ast.TypeSpec:
+			if o.exportedOnly && !sp.Name.IsExported() {
+				continue
+			}
+			sym := SymbolOutline{Kind: SymbolType, Name: sp.Name.Name, File: file, Line: o.line(sp.Pos()), Doc: o.doc(specDoc(sp.Doc, d))}
+			spec := *sp
+			spec.Doc, spec.Comment = nil, nil
+			switch t := sp.Type.(type) {
+			case *ast.StructType:
+				// 字段在 members 中列出，签名只保留类型名和类型参数
+				sym.Kind, sym.Members = SymbolStruct, o.fields(t.Fields)
+				spec.Type = &ast.StructType{Fields: &ast.FieldList{}}
+			case *ast.InterfaceType:
+				sym.Kind, sym.Members = SymbolInterface, o.fields(t.Methods)
+				spec.Type = &ast.InterfaceType{Methods: &ast.FieldList{}}
+			}
+			sym.Signature = strings.TrimSuffix("type "+o.node(&spec), " { }")
+			symbols = append(symbols, sym)
+		case *ast.ValueSpec:
+			kind := SymbolVar
+			if d.Tok == token.CONST {
+				kind = SymbolConst
+			}
+			for _, ident := range sp.Names {
+				if ident.Name == "_" || (o.exportedOnly && !ident.IsExported()) {
+					continue
+				}
+				spec := *sp
+				spec.Doc, spec.Comment = nil, nil
+				symbols = append(symbols, SymbolOutline{
+					Kind:      kind,
+					Name:      ident.Name,
+					Signature: clip(d.Tok.String()+" "+o.node(&spec), maxOutlineSignature),
+					Doc:       o.doc(specDoc(sp.Doc, d)),
+					File:      file,
+					Line:      o.line(ident.Pos()),
+				})
+			}
+		}
+	}
+	return symbols
+}
+
+// fields 结构体字段或接口方法，嵌入的类型只有类型名
+func (o *outliner) fields(list *ast.FieldList) []string {
+	var members []string
+	for _, field := range list.List {
+		typ := o.node(field.Type)
+		if len(field.Names) == 0 {
+			members = append(members, typ)
+			continue
+		}
+		for _, name := range field.Names {
+			if o.exportedOnly && !name.IsExported() {
+				continue
+			}
+			if fn, ok := field.Type.(*ast.FuncType); ok {
+				// 接口方法：去掉 func 关键字
+				members = append(members, name.Name+strings.TrimPrefix(o.node(fn), "func"))
+			} else {
+				members = append(members, name.Name+" "+typ)
+			}
+		}
+	}
+	return members
+}
+
+// node 把语法树节点格式化为源码
+func (o *outliner) node(node any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, o.fset, node); err != nil {
+		return ""
+	}
+	return clip(strings.Join(strings.Fields(buf.String()), " "), maxOutlineSignature)
+}
+
+// line 返回位置的行号
+func (o *outliner) line(pos token.Pos) int {
+	return o.fset.Position(pos).Line
+}
+
+// doc 返回文档注释，no_doc 时为空
+func (o *outliner) doc(group *ast.CommentGroup) string {
+	if o.noDoc || group == nil {
+		return ""
+	}
+	return docText(group)
+}
+
+// docText 文档注释的第一段，过长时截断
+func docText(group *ast.CommentGroup) string {
+	text := strings.TrimSpace(group.Text())
+	if i := strings.Index(text, "\n\n"); i >= 0 {
+		text = text[:i]
+	}
+	return clip(strings.Join(strings.Fields(text), " "), maxOutlineDoc)
+}
+
+// specDoc 声明项的文档注释，没有时使用非分组声明的注释
+func specDoc(doc *ast.CommentGroup, d *ast.GenDecl) *ast.CommentGroup {
+	if doc == nil && !d.Lparen.IsValid() {
+		return d.Doc
+	}
+	return doc
+}
+
+// receiverType 方法接收者的类型名，去掉指针和类型参数
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// clip 截断到 limit 个字符
+func clip(text string, limit int) string {
+	if runes := []rune(text); len(runes) > limit {
+		return string(runes[:limit]) + "..."
+	}
+	return text
+}
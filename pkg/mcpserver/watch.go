@@ -0,0 +1,253 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+)
+
+// watch_path 的限制
+const (
+	defaultWatchDuration = 30 * time.Second
+	maxWatchDuration     = 5 * time.Minute
+	defaultWatchInterval = time.Second
+	minWatchInterval     = 200 * time.Millisecond
+	// maxWatchFiles 监视的文件数上限，超出时需要缩小 path 或设置 include
+	maxWatchFiles = 20000
+	// maxWatchEvents 返回的事件数上限
+	maxWatchEvents = 500
+)
+
+// 文件变化类型
+const (
+	WatchCreated  = "created"
+	WatchModified = "modified"
+	WatchDeleted  = "deleted"
+)
+
+// WatchPathInput 监视路径的输入
+type WatchPathInput struct {
+	Path         string   `json:"path" jsonschema:"要监视的文件或目录（相对允许访问的根目录），目录递归监视"`
+	Include      []string `json:"include,omitempty" jsonschema:"只监视文件名匹配这些模式的文件，如 [\"*.log\"]"`
+	Duration     string   `json:"duration,omitempty" jsonschema:"最长监视时间，如 30s、2m，默认 30s，最多 5m"`
+	Interval     string   `json:"interval,omitempty" jsonschema:"检查间隔，默认 1s，最小 200ms"`
+	KeepWatching bool     `json:"keep_watching,omitempty" jsonschema:"为 true 时在整个监视时间内持续收集变化，默认发现变化后立即返回"`
+}
+
+// WatchEvent 文件变化
+type WatchEvent struct {
+	Path string    `json:"path" jsonschema:"文件路径（相对允许访问的根目录）"`
+	Type string    `json:"type" jsonschema:"变化类型：created、modified 或 deleted"`
+	Size int64     `json:"size,omitempty" jsonschema:"变化后的文件大小"`
+	Time time.Time `json:"time" jsonschema:"发现变化的时间"`
+}
+
+// WatchPathOutput 监视路径的输出
+type WatchPathOutput struct {
+	Events    []WatchEvent `json:"events" jsonschema:"文件变化，按发现顺序排列"`
+	Changed   bool         `json:"changed" jsonschema:"监视期间是否有变化"`
+	Files     int          `json:"files" jsonschema:"开始监视时的文件数"`
+	ElapsedMs int64        `json:"elapsed_ms" jsonschema:"实际监视时长（毫秒）"`
+	Truncated bool         `json:"truncated,omitempty" jsonschema:"变化超过 500 个，之后的变化未返回"`
+}
+
+// fileState 文件快照，大小或修改时间不同即视为修改
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// handleWatchPath 在限定时间内轮询文件的大小和修改时间，返回新建、修改和删除的文件；
+// 客户端在请求中提供 progress token 时，每次发现变化都发送进度通知
+func (s *MCPServer) handleWatchPath(ctx context.Context, req *mcp.CallToolRequest, input WatchPathInput) (*mcp.CallToolResult, WatchPathOutput, error) {
+	klog.InfoS("MCP tool called: watch_path", "path", input.Path, "duration", input.Duration, "keepWatching", input.KeepWatching)
+
+	duration, err := watchDuration(input.Duration, defaultWatchDuration)
+	if err != nil {
+		return nil, WatchPathOutput{}, fmt.Errorf("invalid duration: %w", err)
+	}
+	duration = min(duration, maxWatchDuration)
+	interval, err := watchDuration(input.Interval, defaultWatchInterval)
+	if err != nil {
+		return nil, WatchPathOutput{}, fmt.Errorf("invalid interval: %w", err)
+	}
+	interval = max(interval, minWatchInterval)
+
+	abs, root, err := s.resolve(input.Path)
+	if err != nil {
+		return nil, WatchPathOutput{}, err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return nil, WatchPathOutput{}, err
+	}
+	w := &pathWatcher{root: root, path: abs, include: input.Include}
+	snapshot, err := w.snapshot()
+	if err != nil {
+		return nil, WatchPathOutput{}, err
+	}
+
+	out := WatchPathOutput{Events: []WatchEvent{}, Files: len(snapshot)}
+	start := time.Now()
+	deadline := time.NewTimer(duration)
+	defer deadline.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, WatchPathOutput{}, ctx.Err()
+		case <-deadline.C:
+			out.ElapsedMs = time.Since(start).Milliseconds()
+			return nil, out, nil
+		case <-ticker.C:
+		}
+
+		current, err := w.snapshot()
+		if err != nil {
+			return nil, WatchPathOutput{}, err
+		}
+		events := w.diff(snapshot, current)
+		snapshot = current
+		if len(events) == 0 {
+			continue
+		}
+
+		out.Changed = true
+		if room := maxWatchEvents - len(out.Events); len(events) > room {
+			events, out.Truncated = events[:room], true
+		}
+		out.Events = append(out.Events, events...)
+		notifyWatchProgress(ctx, req, len(out.Events), events)
+		if !input.KeepWatching || out.Truncated {
+			out.ElapsedMs = time.Since(start).Milliseconds()
+			return nil, out, nil
+		}
+	}
+}
+
+// watchDuration 解析时长，为空时返回默认值
+func watchDuration(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return def, nil
+	}
+	return d, nil
+}
+
+// notifyWatchProgress 客户端请求了进度通知时，把本次发现的变化作为进度消息发送
+func notifyWatchProgress(ctx context.Context, req *mcp.CallToolRequest, total int, events []WatchEvent) {
+	if req == nil || req.Session == nil || req.Params == nil {
+		return
+	}
+	token := req.Params.GetProgressToken()
+	if token == nil {
+		return
+	}
+	parts := make([]string, 0, len(events))
+	for _, e := range events {
+		parts = append(parts, e.Type+" "+e.Path)
+	}
+	err := req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+		ProgressToken: token,
+		Progress:      float64(total),
+		Message:       strings.Join(parts, "\n"),
+	})
+	if err != nil {
+		klog.V(2).InfoS("Failed to send watch progress", "err", err)
+	}
+}
+
+// pathWatcher 一次监视的范围
+type pathWatcher struct {
+	root    string
+	path    string
+	include []string
+}
+
+// snapshot 记录监视范围内文件的大小和修改时间，与 file_inventory 一样跳过隐藏文件、依赖目录和构建产物目录
+// （path 本身除外，监视构建产物时直接把 path 指向该目录）
+func (w *pathWatcher) snapshot() (map[string]fileState, error) {
+	files := make(map[string]fileState)
+	err := filepath.WalkDir(w.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 轮询期间被删除的文件和目录直接跳过
+			if os.IsNotExist(err) && path != w.path {
+				return nil
+			}
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != w.path && (skippedDirs[name] || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path != w.path && (!d.Type().IsRegular() || strings.HasPrefix(name, ".") || !w.included(name)) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if len(files) >= maxWatchFiles {
+			return fmt.Errorf("too many files to watch (more than %d), narrow path or set include", maxWatchFiles)
+		}
+		rel, _ := filepath.Rel(w.root, path)
+		files[filepath.ToSlash(rel)] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	// 监视的路径本身被删除时视为其下所有文件被删除
+	if os.IsNotExist(err) {
+		return files, nil
+	}
+	return files, err
+}
+
+// included 文件名是否匹配 include（为空时都匹配）
+func (w *pathWatcher) included(name string) bool {
+	if len(w.include) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(w.include, func(pattern string) bool {
+		ok, _ := filepath.Match(pattern, name)
+		return ok
+	})
+}
+
+// diff 比较两次快照，按路径排序返回变化
+func (w *pathWatcher) diff(before, after map[string]fileState) []WatchEvent {
+	now := time.Now()
+	var events []WatchEvent
+	for path, state := range after {
+		prev, ok := before[path]
+		switch {
+		case !ok:
+			events = append(events, WatchEvent{Path: path, Type: WatchCreated, Size: state.size, Time: now})
+		case prev.size != state.size || !prev.modTime.Equal(state.modTime):
+			events = append(events, WatchEvent{Path: path, Type: WatchModified, Size: state.size, Time: now})
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			events = append(events, WatchEvent{Path: path, Type: WatchDeleted, Time: now})
+		}
+	}
+	slices.SortFunc(events, func(a, b WatchEvent) int { return strings.Compare(a.Path, b.Path) })
+	return events
+}
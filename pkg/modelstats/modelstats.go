@@ -0,0 +1,136 @@
+// Package modelstats 按模型统计 Ollama 返回的 token 数、各阶段耗时和结束原因，
+// 用于比较不同模型、主机的生成速度并发现经常因长度限制被截断的请求。统计保存在内存中，每个副本各自统计，重启后清零
+package modelstats
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// DoneReasonLength 达到 num_predict 或上下文长度限制而停止生成，回答可能不完整
+const DoneReasonLength = "length"
+
+// Stats 模型的调用统计，不包含命中响应缓存的调用
+type Stats struct {
+	Model            string `json:"model"`
+	Calls            int64  `json:"calls"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	// Truncated 因长度限制停止生成的调用数
+	Truncated      int64   `json:"truncated"`
+	TruncationRate float64 `json:"truncation_rate"`
+	// 生成和处理提示词的平均速度（token/秒），按总 token 数除以总耗时计算
+	TokensPerSecond       float64 `json:"tokens_per_second"`
+	PromptTokensPerSecond float64 `json:"prompt_tokens_per_second"`
+	// 平均每次调用的模型加载耗时和总耗时（毫秒）
+	AvgLoadMs  float64   `json:"avg_load_ms"`
+	AvgTotalMs float64   `json:"avg_total_ms"`
+	LastUsed   time.Time `json:"last_used"`
+}
+
+// entry 单个模型的累计数据
+type entry struct {
+	calls, truncated        int64
+	promptTokens, evalCount int64
+	promptEval, eval        time.Duration
+	load, total             time.Duration
+	lastUsed                time.Time
+}
+
+// Recorder 模型调用统计
+type Recorder struct {
+	mu     sync.Mutex
+	models map[string]*entry
+	since  time.Time
+}
+
+// New 创建统计
+func New() *Recorder {
+	return &Recorder{models: make(map[string]*entry), since: time.Now()}
+}
+
+// Since 开始统计的时间
+func (r *Recorder) Since() time.Time {
+	return r.since
+}
+
+// Record 记录一次模型调用的指标和结束原因
+func (r *Recorder) Record(model string, m api.Metrics, doneReason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.models[model]
+	if !ok {
+		e = &entry{}
+		r.models[model] = e
+	}
+	e.calls++
+	if doneReason == DoneReasonLength {
+		e.truncated++
+	}
+	e.promptTokens += int64(m.PromptEvalCount)
+	e.evalCount += int64(m.EvalCount)
+	e.promptEval += m.PromptEvalDuration
+	e.eval += m.EvalDuration
+	e.load += m.LoadDuration
+	e.total += m.TotalDuration
+	e.lastUsed = time.Now()
+}
+
+// List 返回所有调用过的模型的统计，按名称排序
+func (r *Recorder) List() []Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := slices.SortedFunc(maps.Keys(r.models), strings.Compare)
+	stats := make([]Stats, 0, len(names))
+	for _, name := range names {
+		e := r.models[name]
+		stats = append(stats, Stats{
+			Model:                 name,
+			Calls:                 e.calls,
+			PromptTokens:          e.promptTokens,
+			CompletionTokens:      e.evalCount,
+			Truncated:             e.truncated,
+			TruncationRate:        float64(e.truncated) / float64(e.calls),
+			TokensPerSecond:       TokensPerSecond(int(e.evalCount), e.eval),
+			PromptTokensPerSecond: TokensPerSecond(int(e.promptTokens), e.promptEval),
+			AvgLoadMs:             milliseconds(e.load) / float64(e.calls),
+			AvgTotalMs:            milliseconds(e.total) / float64(e.calls),
+			LastUsed:              e.lastUsed,
+		})
+	}
+	return stats
+}
+
+// AvgTotalMs 所有模型平均每次调用的总耗时（毫秒），尚无调用时为 0
+func (r *Recorder) AvgTotalMs() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var calls int64
+	var total time.Duration
+	for _, e := range r.models {
+		calls += e.calls
+		total += e.total
+	}
+	if calls == 0 {
+		return 0
+	}
+	return milliseconds(total) / float64(calls)
+}
+
+// TokensPerSecond 按 token 数和耗时计算速度，耗时为 0 时返回 0
+func TokensPerSecond(tokens int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(tokens) / d.Seconds()
+}
+
+// milliseconds 耗时的毫秒数
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,200 @@
+// Package notes 保存模型通过 save_note 工具记录的对话笔记：文件清单、阶段性分析等中间结果保存在上下文之外，
+// 对话历史被裁剪后仍可通过 read_notes 取回，同一用户的后续对话也可以读取之前对话的笔记
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/cache"
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+var (
+	// ErrInvalid 标题或内容为空、过大，或对话的笔记数超出限制
+	ErrInvalid = errors.New("invalid note")
+	// ErrNotFound 笔记不存在、已过期或属于其他用户
+	ErrNotFound = errors.New("note not found")
+)
+
+// 标题的最大字符数
+const maxTitleLength = 100
+
+// 用户索引中保留的最近对话数
+const maxIndexedConversations = 20
+
+// Note 一条笔记
+type Note struct {
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Conversation 用户有笔记的一个对话
+type Conversation struct {
+	ID        string    `json:"id"`
+	Titles    []string  `json:"titles"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store 笔记存储，nil 表示未启用
+type Store struct {
+	store    cache.Store
+	ttl      time.Duration
+	maxNotes int
+	maxBytes int
+
+	// mu 串行化本进程内的读-改-写；多副本共用 redis 时同一对话的请求通常由同一副本处理
+	mu sync.Mutex
+}
+
+// New 按配置创建存储，未启用时返回 nil
+func New(cfg config.NotesConfig, redisCfg config.RedisConfig) (*Store, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	s := &Store{ttl: cfg.TTL, maxNotes: cfg.MaxNotes, maxBytes: cfg.MaxBytes}
+	switch cfg.Backend {
+	case "", "memory":
+		// 每个对话的笔记和每个用户的索引各占一项
+		s.store = cache.NewMemoryStore(cfg.MaxEntries)
+	case "redis":
+		rs, err := cache.NewRedisStore(redisCfg)
+		if err != nil {
+			return nil, err
+		}
+		s.store = rs
+	default:
+		return nil, fmt.Errorf("unknown notes backend: %s", cfg.Backend)
+	}
+	klog.InfoS("Conversation notes enabled", "backend", cfg.Backend, "ttl", cfg.TTL)
+	return s, nil
+}
+
+// Save 保存 owner 在对话中的笔记，标题相同时覆盖；content 为空时删除该笔记
+func (s *Store) Save(ctx context.Context, owner, conversationID, title, content string) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalid)
+	}
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return fmt.Errorf("%w: title exceeds %d characters", ErrInvalid, maxTitleLength)
+	}
+	if len(content) > s.maxBytes {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d, split it into several notes", ErrInvalid, len(content), s.maxBytes)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notes, err := s.List(ctx, owner, conversationID)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(notes, func(n Note) bool { return n.Title == title })
+	switch {
+	case content == "" && i < 0:
+		return fmt.Errorf("%w: %s", ErrNotFound, title)
+	case content == "":
+		notes = slices.Delete(notes, i, i+1)
+	case i >= 0:
+		notes[i].Content, notes[i].UpdatedAt = content, time.Now()
+	case len(notes) >= s.maxNotes:
+		return fmt.Errorf("%w: conversation already has %d notes, overwrite or delete an existing one", ErrInvalid, len(notes))
+	default:
+		notes = append(notes, Note{Title: title, Content: content, UpdatedAt: time.Now()})
+	}
+
+	if err := s.put(ctx, notesKey(owner, conversationID), notes); err != nil {
+		return err
+	}
+	return s.index(ctx, owner, conversationID, notes)
+}
+
+// List 返回 owner 在对话中的笔记，按保存顺序排列
+func (s *Store) List(ctx context.Context, owner, conversationID string) ([]Note, error) {
+	var notes []Note
+	if err := s.get(ctx, notesKey(owner, conversationID), &notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// Get 返回 owner 在对话中指定标题的笔记
+func (s *Store) Get(ctx context.Context, owner, conversationID, title string) (*Note, error) {
+	notes, err := s.List(ctx, owner, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	title = strings.TrimSpace(title)
+	i := slices.IndexFunc(notes, func(n Note) bool { return n.Title == title })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, title)
+	}
+	return &notes[i], nil
+}
+
+// Conversations 返回 owner 最近有笔记的对话，最近更新的在前
+func (s *Store) Conversations(ctx context.Context, owner string) ([]Conversation, error) {
+	var conversations []Conversation
+	if err := s.get(ctx, indexKey(owner), &conversations); err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+// index 更新用户索引中的对话，笔记全部删除时移出索引
+func (s *Store) index(ctx context.Context, owner, conversationID string, notes []Note) error {
+	conversations, err := s.Conversations(ctx, owner)
+	if err != nil {
+		return err
+	}
+	conversations = slices.DeleteFunc(conversations, func(c Conversation) bool { return c.ID == conversationID })
+	if len(notes) > 0 {
+		titles := make([]string, len(notes))
+		for i, n := range notes {
+			titles[i] = n.Title
+		}
+		conversations = slices.Insert(conversations, 0, Conversation{ID: conversationID, Titles: titles, UpdatedAt: time.Now()})
+	}
+	if len(conversations) > maxIndexedConversations {
+		conversations = conversations[:maxIndexedConversations]
+	}
+	return s.put(ctx, indexKey(owner), conversations)
+}
+
+// get 读取 JSON 值，不存在时保持 v 不变
+func (s *Store) get(ctx context.Context, key string, v any) error {
+	data, ok, err := s.store.Get(ctx, key)
+	if err != nil || !ok {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// put 以 JSON 保存值并刷新有效期
+func (s *Store) put(ctx context.Context, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(ctx, key, data, s.ttl)
+}
+
+// notesKey 对话笔记的存储键，包含用户身份，其他用户无法读取
+func notesKey(owner, conversationID string) string {
+	return "notes:" + owner + ":" + conversationID
+}
+
+// indexKey 用户有笔记的对话索引的存储键
+func indexKey(owner string) string {
+	return "notes-index:" + owner
+}
@@ -1,49 +1,138 @@
 package ollama
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/ollama/ollama/api"
 	"k8s.io/klog/v2"
 )
 
+// Options 连接与超时设置，未设置的项使用默认值
+type Options struct {
+	ConnectTimeout    time.Duration // 建立 TCP/TLS 连接的超时
+	ResponseTimeout   time.Duration // 等待首个响应（模型加载、处理提示）的超时，也是非流式请求的整体超时
+	StreamIdleTimeout time.Duration // 流式生成时两次输出之间的最长间隔，只要仍在输出就不会超时
+	MaxIdleConns      int           // 保持的空闲连接数
+	IdleConnTimeout   time.Duration // 空闲连接的保持时间
+	// KeepAlive 随每次请求发送的 keep_alive：模型在最后一次请求后保持加载的时间，
+	// 0 表示使用 Ollama 的默认值（5 分钟），负数表示一直保持加载
+	KeepAlive time.Duration
+}
+
+// withDefaults 填充默认值
+func (o Options) withDefaults() Options {
+	o.ConnectTimeout = cmp.Or(o.ConnectTimeout, 10*time.Second)
+	o.ResponseTimeout = cmp.Or(o.ResponseTimeout, 120*time.Second)
+	o.StreamIdleTimeout = cmp.Or(o.StreamIdleTimeout, 60*time.Second)
+	o.MaxIdleConns = cmp.Or(o.MaxIdleConns, 16)
+	o.IdleConnTimeout = cmp.Or(o.IdleConnTimeout, 90*time.Second)
+	return o
+}
+
+var (
+	// errResponseTimeout 超过 ResponseTimeout 仍未收到响应
+	errResponseTimeout = errors.New("ollama response timeout")
+	// errStreamIdle 流式输出中断超过 StreamIdleTimeout
+	errStreamIdle = errors.New("ollama stream idle timeout")
+)
+
+// modelOptionsKey 模型参数在 context 中的键
+type modelOptionsKey struct{}
+
+// WithModelOptions 设置本次请求的模型参数（如 temperature），随 Chat 请求发送给 Ollama
+func WithModelOptions(ctx context.Context, options map[string]any) context.Context {
+	return context.WithValue(ctx, modelOptionsKey{}, options)
+}
+
+// ModelOptionsFromContext 返回 context 中的模型参数，未设置时为 nil
+func ModelOptionsFromContext(ctx context.Context) map[string]any {
+	options, _ := ctx.Value(modelOptionsKey{}).(map[string]any)
+	return options
+}
+
+// formatKey 输出格式在 context 中的键
+type formatKey struct{}
+
+// WithFormat 要求本次请求的输出符合 JSON Schema（或为 "json"），随 Chat 请求发送给 Ollama
+func WithFormat(ctx context.Context, format json.RawMessage) context.Context {
+	return context.WithValue(ctx, formatKey{}, format)
+}
+
+// FormatFromContext 返回 context 中的输出格式，未设置时为 nil
+func FormatFromContext(ctx context.Context) json.RawMessage {
+	format, _ := ctx.Value(formatKey{}).(json.RawMessage)
+	return format
+}
+
 // Client Ollama 客户端（基于官方 SDK）
 type Client struct {
 	client *api.Client
 	model  string
+	opts   Options
 }
 
-// NewClient 创建 Ollama 客户端
-func NewClient(baseURL, model string, timeout time.Duration) (*Client, error) {
+// NewClient 创建 Ollama 客户端。不使用 http.Client.Timeout（它会中断仍在输出的长回答），
+// 超时由 Options 中的各项分别控制，连接在请求之间复用
+func NewClient(baseURL, model string, opts Options) (*Client, error) {
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return nil, err
 	}
+	opts = opts.withDefaults()
 
+	dialer := &net.Dialer{
+		Timeout:   opts.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
 	httpClient := &http.Client{
-		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: opts.ConnectTimeout,
+			MaxIdleConns:        opts.MaxIdleConns,
+			MaxIdleConnsPerHost: opts.MaxIdleConns,
+			IdleConnTimeout:     opts.IdleConnTimeout,
+			ForceAttemptHTTP2:   true,
+		},
 	}
 
 	client := api.NewClient(u, httpClient)
 
-	klog.InfoS("Ollama client created", "baseURL", baseURL, "model", model)
+	klog.InfoS("Ollama client created", "baseURL", baseURL, "model", model,
+		"responseTimeout", opts.ResponseTimeout, "streamIdleTimeout", opts.StreamIdleTimeout)
 	return &Client{
 		client: client,
 		model:  model,
+		opts:   opts,
 	}, nil
 }
 
-// Chat 发送聊天请求
-func (c *Client) Chat(ctx context.Context, messages []api.Message, tools []api.Tool) (*api.ChatResponse, error) {
-	stream := false
+// Chat 发送聊天请求，model 为空时使用客户端默认模型。
+// 内部以流式方式接收并拼接完整回答：首个输出前受 ResponseTimeout 限制，之后每次输出都会重置 StreamIdleTimeout
+func (c *Client) Chat(ctx context.Context, model string, messages []api.Message, tools []api.Tool) (*api.ChatResponse, error) {
+	if model == "" {
+		model = c.model
+	}
+
+	stream := true
 	req := &api.ChatRequest{
-		Model:    c.model,
-		Messages: messages,
-		Stream:   &stream,
+		Model:     model,
+		Messages:  messages,
+		Stream:    &stream,
+		Options:   ModelOptionsFromContext(ctx),
+		Format:    FormatFromContext(ctx),
+		KeepAlive: c.keepAlive(),
 	}
 
 	if len(tools) > 0 {
@@ -54,16 +143,31 @@ func (c *Client) Chat(ctx context.Context, messages []api.Message, tools []api.T
 		klog.V(3).InfoS("Ollama chat request", "req", string(reqJSON))
 	}
 
+	ctx, touch, finish := c.watch(ctx)
 	var resp api.ChatResponse
+	var content, thinking strings.Builder
+	var toolCalls []api.ToolCall
 	err := c.client.Chat(ctx, req, func(r api.ChatResponse) error {
+		touch()
+		content.WriteString(r.Message.Content)
+		thinking.WriteString(r.Message.Thinking)
+		toolCalls = append(toolCalls, r.Message.ToolCalls...)
 		resp = r
 		return nil
 	})
-	if err != nil {
+	if err = finish(err, resp.Done); err != nil {
 		klog.ErrorS(err, "Ollama chat failed")
 		return nil, err
 	}
 
+	// 启用 think 参数时思考过程单独返回，否则从回答的 <think> 标记中分离
+	resp.Message.Content = content.String()
+	resp.Message.Thinking = thinking.String()
+	if resp.Message.Thinking == "" {
+		resp.Message.Thinking, resp.Message.Content = SplitThinking(resp.Message.Content)
+	}
+	resp.Message.ToolCalls = toolCalls
+
 	klog.V(3).InfoS("Ollama chat response",
 		"role", resp.Message.Role,
 		"content", resp.Message.Content,
@@ -72,22 +176,110 @@ func (c *Client) Chat(ctx context.Context, messages []api.Message, tools []api.T
 	return &resp, nil
 }
 
+// watch 流式请求的超时控制：首个输出前受 ResponseTimeout 限制，之后每次输出调用 touch 重置为 StreamIdleTimeout；
+// 请求结束后调用 finish，传入请求的错误和是否收到结束标记，返回附带超时原因的错误
+func (c *Client) watch(ctx context.Context) (context.Context, func(), func(error, bool) error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	var streaming atomic.Bool
+	watchdog := time.AfterFunc(c.opts.ResponseTimeout, func() {
+		if streaming.Load() {
+			cancel(errStreamIdle)
+		} else {
+			cancel(errResponseTimeout)
+		}
+	})
+	touch := func() {
+		streaming.Store(true)
+		watchdog.Reset(c.opts.StreamIdleTimeout)
+	}
+	finish := func(err error, done bool) error {
+		watchdog.Stop()
+		defer cancel(nil)
+		// SDK 读取流时忽略连接中断的错误，没有收到结束标记即视为输出被截断
+		if err == nil && !done {
+			err = io.ErrUnexpectedEOF
+		}
+		if cause := context.Cause(ctx); err != nil && !errors.Is(err, cause) && (errors.Is(cause, errResponseTimeout) || errors.Is(cause, errStreamIdle)) {
+			err = fmt.Errorf("%w: %w", cause, err)
+		}
+		return err
+	}
+	return ctx, touch, finish
+}
+
+// Generate 发送单次补全请求（/api/generate），不使用对话消息，model 为空时使用客户端默认模型；
+// 超时控制与 Chat 相同
+func (c *Client) Generate(ctx context.Context, model string, req *api.GenerateRequest) (*api.GenerateResponse, error) {
+	stream := true
+	r := *req
+	r.Model = cmp.Or(model, c.model)
+	r.Stream = &stream
+	if r.Options == nil {
+		r.Options = ModelOptionsFromContext(ctx)
+	}
+	if r.Format == nil {
+		r.Format = FormatFromContext(ctx)
+	}
+	r.KeepAlive = c.keepAlive()
+
+	ctx, touch, finish := c.watch(ctx)
+	var resp api.GenerateResponse
+	var content, thinking strings.Builder
+	err := c.client.Generate(ctx, &r, func(g api.GenerateResponse) error {
+		touch()
+		content.WriteString(g.Response)
+		thinking.WriteString(g.Thinking)
+		resp = g
+		return nil
+	})
+	if err = finish(err, resp.Done); err != nil {
+		klog.ErrorS(err, "Ollama generate failed")
+		return nil, err
+	}
+
+	resp.Response = content.String()
+	resp.Thinking = thinking.String()
+	if resp.Thinking == "" {
+		resp.Thinking, resp.Response = SplitThinking(resp.Response)
+	}
+	return &resp, nil
+}
+
 // Ping 检查 Ollama 服务是否可用
 func (c *Client) Ping(ctx context.Context) error {
 	// 使用 List 方法检查连接
-	_, err := c.client.List(ctx)
+	_, err := c.ListModels(ctx)
 	return err
 }
 
+// ListModels 列出 Ollama 本地可用的模型名称
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.ResponseTimeout)
+	defer cancel()
+	resp, err := c.client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]string, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		models = append(models, m.Name)
+	}
+	return models, nil
+}
+
 // Embed 生成文本的嵌入向量
 func (c *Client) Embed(ctx context.Context, model string, input string) ([]float32, error) {
 	klog.V(3).InfoS("Ollama embed request", "model", model, "inputLen", len(input))
 
 	req := &api.EmbedRequest{
-		Model: model,
-		Input: input,
+		Model:     model,
+		Input:     input,
+		KeepAlive: c.keepAlive(),
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, c.opts.ResponseTimeout)
+	defer cancel()
 	resp, err := c.client.Embed(ctx, req)
 	if err != nil {
 		klog.ErrorS(err, "Ollama embed failed")
@@ -110,3 +302,39 @@ func (c *Client) Embed(ctx context.Context, model string, input string) ([]float
 
 	return embedding, nil
 }
+
+// keepAlive 请求中的 keep_alive，未配置时为 nil（使用 Ollama 的默认值）
+func (c *Client) keepAlive() *api.Duration {
+	if c.opts.KeepAlive == 0 {
+		return nil
+	}
+	return &api.Duration{Duration: c.opts.KeepAlive}
+}
+
+// Load 加载模型并按 KeepAlive 保持，避免第一个请求等待模型加载
+func (c *Client) Load(ctx context.Context, model string) error {
+	return c.setKeepAlive(ctx, model, c.keepAlive())
+}
+
+// Unload 立即从内存中卸载模型
+func (c *Client) Unload(ctx context.Context, model string) error {
+	return c.setKeepAlive(ctx, model, &api.Duration{})
+}
+
+// setKeepAlive 发送不含提示的请求，Ollama 只加载模型（或 keepAlive 为 0 时卸载）而不生成内容；
+// 嵌入模型不支持 generate，改用空输入的 embed 请求
+func (c *Client) setKeepAlive(ctx context.Context, model string, keepAlive *api.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.ResponseTimeout)
+	defer cancel()
+
+	err := c.client.Generate(ctx, &api.GenerateRequest{Model: model, KeepAlive: keepAlive}, func(api.GenerateResponse) error {
+		return nil
+	})
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		if _, embedErr := c.client.Embed(ctx, &api.EmbedRequest{Model: model, KeepAlive: keepAlive}); embedErr == nil {
+			return nil
+		}
+	}
+	return err
+}
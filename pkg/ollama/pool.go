@@ -0,0 +1,439 @@
+package ollama
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"k8s.io/klog/v2"
+)
+
+// ErrNoHost 没有可以处理请求的 Ollama 主机（都没有拉取所需模型）
+var ErrNoHost = errors.New("no ollama host available")
+
+const (
+	// RoutingLeastLoaded 发往进行中请求最少的主机
+	RoutingLeastLoaded = "least_loaded"
+	// RoutingRoundRobin 依次轮询
+	RoutingRoundRobin = "round_robin"
+)
+
+// PoolConfig 多主机配置
+type PoolConfig struct {
+	Hosts          []string
+	Model          string        // 默认模型
+	Options        Options       // 各主机共用的连接与超时设置
+	Routing        string        // least_loaded（默认）或 round_robin
+	HealthInterval time.Duration // 健康检查间隔，同时刷新各主机已拉取的模型
+	MaxConcurrent  int           // 每台主机同时处理的请求数，0 表示不限制，超出时按优先级排队
+}
+
+// HostStatus 主机状态
+type HostStatus struct {
+	Host     string `json:"host"`
+	Healthy  bool   `json:"healthy"`
+	InFlight int64  `json:"in_flight"`
+	Models   int    `json:"models"`
+	Error    string `json:"error,omitempty"`
+}
+
+// backend 单个 Ollama 主机
+type backend struct {
+	host     string
+	client   *Client
+	inFlight atomic.Int64
+	slots    chan struct{} // 主机的并发名额，nil 表示不限制
+
+	mu      sync.RWMutex
+	healthy bool
+	models  map[string]bool // 已拉取的模型，nil 表示尚未获取
+	lastErr error
+}
+
+// Pool 在多个 Ollama 主机之间路由请求：只发往已拉取所需模型的主机，
+// 优先选择健康的主机，连接失败时标记为不健康并换一台重试。
+// 限制了并发时，请求先在总名额（每台主机的名额之和）上按优先级排队，再占用所选主机的名额
+type Pool struct {
+	backends []*backend
+	model    string
+	routing  string
+	interval time.Duration
+	next     atomic.Uint64
+	sched    *scheduler
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewPool 创建多主机客户端
+func NewPool(cfg PoolConfig) (*Pool, error) {
+	if len(cfg.Hosts) == 0 {
+		return nil, fmt.Errorf("at least one ollama host is required")
+	}
+	switch cfg.Routing {
+	case "":
+		cfg.Routing = RoutingLeastLoaded
+	case RoutingLeastLoaded, RoutingRoundRobin:
+	default:
+		return nil, fmt.Errorf("unknown ollama routing: %s", cfg.Routing)
+	}
+
+	p := &Pool{
+		model:    cfg.Model,
+		routing:  cfg.Routing,
+		interval: cfg.HealthInterval,
+		sched:    newScheduler(cfg.MaxConcurrent * len(cfg.Hosts)),
+		stop:     make(chan struct{}),
+	}
+	for _, host := range cfg.Hosts {
+		client, err := NewClient(host, cfg.Model, cfg.Options)
+		if err != nil {
+			return nil, fmt.Errorf("ollama host %s: %w", host, err)
+		}
+		// 首次健康检查前视为健康，模型未知时不做过滤
+		b := &backend{host: host, client: client, healthy: true}
+		if cfg.MaxConcurrent > 0 {
+			b.slots = make(chan struct{}, cfg.MaxConcurrent)
+		}
+		p.backends = append(p.backends, b)
+	}
+	return p, nil
+}
+
+// Ping 检查所有主机并刷新模型列表，至少一台可用时返回 nil
+func (p *Pool) Ping(ctx context.Context) error {
+	p.checkAll(ctx)
+
+	var errs []error
+	for _, b := range p.backends {
+		b.mu.RLock()
+		healthy, err := b.healthy, b.lastErr
+		b.mu.RUnlock()
+		if healthy {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", b.host, err))
+	}
+	return errors.Join(errs...)
+}
+
+// StartHealthCheck 启动后台健康检查，Close 时停止
+func (p *Pool) StartHealthCheck() {
+	if p.interval <= 0 {
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+				p.checkAll(ctx)
+				cancel()
+			}
+		}
+	}()
+}
+
+// Close 停止健康检查
+func (p *Pool) Close() {
+	p.stopOnce.Do(func() { close(p.stop) })
+	p.wg.Wait()
+}
+
+// checkAll 并发检查所有主机
+func (p *Pool) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, b := range p.backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.check(ctx, b)
+		}()
+	}
+	wg.Wait()
+}
+
+// check 通过模型列表接口检查主机，同时记录已拉取的模型
+func (p *Pool) check(ctx context.Context, b *backend) {
+	names, err := b.client.ListModels(ctx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		if b.healthy {
+			klog.ErrorS(err, "Ollama host unhealthy", "host", b.host)
+		}
+		b.healthy, b.lastErr = false, err
+		return
+	}
+	if !b.healthy {
+		klog.InfoS("Ollama host recovered", "host", b.host)
+	}
+	b.models = make(map[string]bool, len(names))
+	for _, name := range names {
+		b.models[normalizeModel(name)] = true
+	}
+	b.healthy, b.lastErr = true, nil
+}
+
+// markUnhealthy 请求连接失败时标记主机不健康，等待下次健康检查恢复
+func (b *backend) markUnhealthy(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.healthy {
+		klog.ErrorS(err, "Ollama host request failed, marking unhealthy", "host", b.host)
+	}
+	b.healthy, b.lastErr = false, err
+}
+
+// tryAcquire 主机有空闲名额时占用一个
+func (b *backend) tryAcquire() bool {
+	if b.slots == nil {
+		return true
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// acquire 等待主机的一个名额，ctx 取消时放弃等待
+func (b *backend) acquire(ctx context.Context) error {
+	if b.slots == nil {
+		return nil
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release 归还主机的名额
+func (b *backend) release() {
+	if b.slots != nil {
+		<-b.slots
+	}
+}
+
+// state 返回主机是否已拉取模型以及是否健康；尚未获取模型列表的健康主机（首次检查前）视为已拉取
+func (b *backend) state(model string) (hasModel, healthy bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.models == nil {
+		return b.healthy, b.healthy
+	}
+	return b.models[normalizeModel(model)], b.healthy
+}
+
+// candidates 按路由策略排序的候选主机：只包含已拉取模型的主机，健康的在前；
+// 全部不健康时仍然尝试，避免健康状态过期导致整体不可用
+func (p *Pool) candidates(model string) []*backend {
+	var healthy, unhealthy []*backend
+	for _, b := range p.backends {
+		hasModel, ok := b.state(model)
+		switch {
+		case !hasModel:
+		case ok:
+			healthy = append(healthy, b)
+		default:
+			unhealthy = append(unhealthy, b)
+		}
+	}
+	if len(healthy) > 1 {
+		// 在符合条件的主机之间轮转起点
+		start := int(p.next.Add(1)-1) % len(healthy)
+		healthy = slices.Concat(healthy[start:], healthy[:start])
+	}
+
+	if p.routing == RoutingLeastLoaded && len(healthy) > 1 {
+		// 稳定排序保留轮询顺序，负载相同的主机轮流使用
+		loads := make(map[*backend]int64, len(healthy))
+		for _, b := range healthy {
+			loads[b] = b.inFlight.Load()
+		}
+		slices.SortStableFunc(healthy, func(x, y *backend) int { return cmp.Compare(loads[x], loads[y]) })
+	}
+	return append(healthy, unhealthy...)
+}
+
+// reserve 按顺序选择第一台有空闲名额的候选主机并占用名额；都没有空闲名额时等待第一台
+func reserve(ctx context.Context, candidates []*backend) (*backend, error) {
+	for _, b := range candidates {
+		if b.tryAcquire() {
+			return b, nil
+		}
+	}
+	if err := candidates[0].acquire(ctx); err != nil {
+		return nil, err
+	}
+	return candidates[0], nil
+}
+
+// do 在候选主机上执行请求，连接类错误时换下一台主机重试；
+// 限制了并发时先按 ctx 中的优先级排队，再占用所选主机的名额
+func (p *Pool) do(ctx context.Context, model string, fn func(*Client) error) error {
+	if err := p.sched.acquire(ctx); err != nil {
+		return err
+	}
+	defer p.sched.release()
+
+	candidates := p.candidates(model)
+	if len(candidates) == 0 {
+		return fmt.Errorf("%w: model %s is not pulled on any host", ErrNoHost, model)
+	}
+
+	var err error
+	for len(candidates) > 0 {
+		b, acquireErr := reserve(ctx, candidates)
+		if acquireErr != nil {
+			return acquireErr
+		}
+		candidates = slices.DeleteFunc(candidates, func(c *backend) bool { return c == b })
+
+		b.inFlight.Add(1)
+		err = fn(b.client)
+		b.inFlight.Add(-1)
+		b.release()
+		if !retryable(ctx, err) {
+			return err
+		}
+		// 主机返回了 5xx 响应时仍可连接，不标记为不健康
+		var statusErr api.StatusError
+		if !errors.As(err, &statusErr) {
+			b.markUnhealthy(err)
+		}
+	}
+	return err
+}
+
+// retryable 是否应换一台主机重试：只重试网络错误（连接被拒绝、连接中断、超时）和 5xx 响应，
+// Ollama 返回的其他错误（包括流式输出中的错误）和调用方取消不重试
+func retryable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, errResponseTimeout) || errors.Is(err, errStreamIdle)
+}
+
+// Chat 发送聊天请求，model 为空时使用默认模型
+func (p *Pool) Chat(ctx context.Context, model string, messages []api.Message, tools []api.Tool) (*api.ChatResponse, error) {
+	if model == "" {
+		model = p.model
+	}
+	var resp *api.ChatResponse
+	err := p.do(ctx, model, func(c *Client) error {
+		var err error
+		resp, err = c.Chat(ctx, model, messages, tools)
+		return err
+	})
+	return resp, err
+}
+
+// Generate 发送单次补全请求，model 为空时使用默认模型
+func (p *Pool) Generate(ctx context.Context, model string, req *api.GenerateRequest) (*api.GenerateResponse, error) {
+	model = cmp.Or(model, p.model)
+	var resp *api.GenerateResponse
+	err := p.do(ctx, model, func(c *Client) error {
+		var err error
+		resp, err = c.Generate(ctx, model, req)
+		return err
+	})
+	return resp, err
+}
+
+// Embed 生成文本的嵌入向量
+func (p *Pool) Embed(ctx context.Context, model string, input string) ([]float32, error) {
+	var embedding []float32
+	err := p.do(ctx, model, func(c *Client) error {
+		var err error
+		embedding, err = c.Embed(ctx, model, input)
+		return err
+	})
+	return embedding, err
+}
+
+// Load 在所有已拉取该模型的健康主机上加载模型，model 为空时使用默认模型
+func (p *Pool) Load(ctx context.Context, model string) error {
+	return p.each(ctx, cmp.Or(model, p.model), (*Client).Load)
+}
+
+// Unload 在所有已拉取该模型的健康主机上卸载模型，model 为空时使用默认模型
+func (p *Pool) Unload(ctx context.Context, model string) error {
+	return p.each(ctx, cmp.Or(model, p.model), (*Client).Unload)
+}
+
+// each 在所有已拉取模型的健康主机上并发执行 fn，不经过排队，返回各主机的错误
+func (p *Pool) each(ctx context.Context, model string, fn func(*Client, context.Context, string) error) error {
+	var targets []*backend
+	for _, b := range p.backends {
+		if hasModel, healthy := b.state(model); hasModel && healthy {
+			targets = append(targets, b)
+		}
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("%w: model %s is not pulled on any healthy host", ErrNoHost, model)
+	}
+
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, b := range targets {
+		wg.Go(func() {
+			if err := fn(b.client, ctx, model); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", b.host, err)
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Hosts 返回各主机状态
+func (p *Pool) Hosts() []HostStatus {
+	statuses := make([]HostStatus, 0, len(p.backends))
+	for _, b := range p.backends {
+		b.mu.RLock()
+		s := HostStatus{Host: b.host, Healthy: b.healthy, InFlight: b.inFlight.Load(), Models: len(b.models)}
+		if b.lastErr != nil {
+			s.Error = b.lastErr.Error()
+		}
+		b.mu.RUnlock()
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// normalizeModel 补全省略的 :latest 标签，使 "llama3" 与 "llama3:latest" 匹配
+func normalizeModel(name string) string {
+	if !strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") {
+		return name + ":latest"
+	}
+	return name
+}
@@ -0,0 +1,102 @@
+package ollama
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/champly/ai-agent/pkg/priority"
+)
+
+// scheduler 限制同时发往 Ollama 的请求数，超出时排队；
+// 有空闲名额时先唤醒优先级最高的请求，同一优先级先到先得。nil 表示不限制
+type scheduler struct {
+	limit int
+
+	mu      sync.Mutex
+	active  int
+	waiting [priority.Classes][]chan struct{}
+}
+
+// newScheduler 创建调度器，limit <= 0 时返回 nil
+func newScheduler(limit int) *scheduler {
+	if limit <= 0 {
+		return nil
+	}
+	return &scheduler{limit: limit}
+}
+
+// acquire 等待一个名额，优先级取自 ctx；ctx 取消时放弃排队
+func (s *scheduler) acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	class := priority.FromContext(ctx)
+
+	s.mu.Lock()
+	// 同级或更高优先级的请求在排队时不插队
+	if s.active < s.limit && !s.waitingLocked(class) {
+		s.active++
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	s.waiting[class] = append(s.waiting[class], ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if i := slices.Index(s.waiting[class], ready); i >= 0 {
+			s.waiting[class] = slices.Delete(s.waiting[class], i, i+1)
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+		s.mu.Unlock()
+		// 取消的同时已经拿到名额，交给下一个请求
+		s.release()
+		return ctx.Err()
+	}
+}
+
+// release 归还名额，直接转交给优先级最高的排队请求
+func (s *scheduler) release() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.waiting {
+		if len(s.waiting[c]) > 0 {
+			close(s.waiting[c][0])
+			s.waiting[c] = s.waiting[c][1:]
+			return
+		}
+	}
+	s.active--
+}
+
+// waitingLocked 是否有优先级不低于 class 的请求在排队，调用方需持有 s.mu
+func (s *scheduler) waitingLocked(class priority.Class) bool {
+	for c := priority.Interactive; c <= class; c++ {
+		if len(s.waiting[c]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// stats 返回占用的名额数和各优先级排队中的请求数
+func (s *scheduler) stats() (active int, queued [priority.Classes]int) {
+	if s == nil {
+		return 0, queued
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.waiting {
+		queued[c] = len(s.waiting[c])
+	}
+	return s.active, queued
+}
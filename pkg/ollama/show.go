@@ -0,0 +1,88 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+)
+
+// 模型能力（/api/show 返回的 capabilities）
+const (
+	CapabilityCompletion = "completion"
+	CapabilityTools      = "tools"
+	CapabilityVision     = "vision"
+	CapabilityEmbedding  = "embedding"
+	CapabilityThinking   = "thinking"
+)
+
+// ModelInfo 通过 /api/show 获取的模型信息
+type ModelInfo struct {
+	Name          string   `json:"name"`
+	Family        string   `json:"family,omitempty"`
+	ParameterSize string   `json:"parameter_size,omitempty"`
+	Quantization  string   `json:"quantization,omitempty"`
+	ContextLength int      `json:"context_length,omitempty"` // 模型支持的最大上下文长度（token），未知时为 0
+	Capabilities  []string `json:"capabilities,omitempty"`
+}
+
+// Supports 模型是否具有该能力；Ollama 未返回能力列表（旧版本）时视为支持
+func (m *ModelInfo) Supports(capability string) bool {
+	return len(m.Capabilities) == 0 || slices.Contains(m.Capabilities, capability)
+}
+
+// Show 查询模型的上下文长度、能力和所属系列
+func (c *Client) Show(ctx context.Context, model string) (*ModelInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.ResponseTimeout)
+	defer cancel()
+	resp, err := c.client.Show(ctx, &api.ShowRequest{Model: model})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ModelInfo{
+		Name:          model,
+		Family:        resp.Details.Family,
+		ParameterSize: resp.Details.ParameterSize,
+		Quantization:  resp.Details.QuantizationLevel,
+	}
+	for _, capability := range resp.Capabilities {
+		info.Capabilities = append(info.Capabilities, string(capability))
+	}
+	// 上下文长度以 "<架构>.context_length" 为键，如 llama.context_length、qwen2.context_length
+	if arch, ok := resp.ModelInfo["general.architecture"].(string); ok {
+		if n, ok := resp.ModelInfo[arch+".context_length"].(float64); ok {
+			info.ContextLength = int(n)
+		}
+	}
+	if info.ContextLength == 0 {
+		for key, value := range resp.ModelInfo {
+			if n, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") {
+				info.ContextLength = int(n)
+				break
+			}
+		}
+	}
+	return info, nil
+}
+
+// Show 在已拉取该模型的主机上查询模型信息，连接失败时换下一台主机
+func (p *Pool) Show(ctx context.Context, model string) (*ModelInfo, error) {
+	candidates := p.candidates(model)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: model %s is not pulled on any host", ErrNoHost, model)
+	}
+	var err error
+	for _, b := range candidates {
+		var info *ModelInfo
+		if info, err = b.client.Show(ctx, model); err == nil {
+			return info, nil
+		}
+		if !retryable(ctx, err) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,102 @@
+package ollama
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/champly/ai-agent/pkg/priority"
+)
+
+// RunningModel 已加载到主机内存中的模型（来自 /api/ps）
+type RunningModel struct {
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"`      // 占用的内存总量（字节）
+	SizeVRAM      int64     `json:"size_vram"` // 其中位于显存的部分，小于 Size 时部分层在 CPU 上运行
+	ContextLength int       `json:"context_length,omitempty"`
+	ExpiresAt     time.Time `json:"expires_at"` // 空闲后卸载的时间
+}
+
+// BackendStatus 主机的运行状态
+type BackendStatus struct {
+	HostStatus
+	Loaded []RunningModel `json:"loaded"`
+	// VRAMBytes 已加载模型占用的显存总量
+	VRAMBytes int64 `json:"vram_bytes"`
+	// PSError 查询已加载模型失败时的错误
+	PSError string `json:"ps_error,omitempty"`
+}
+
+// QueueStatus 发往 Ollama 的请求的排队情况，未限制并发（max_concurrent 为 0）时 Limit 为 0
+type QueueStatus struct {
+	Limit  int `json:"limit"`
+	Active int `json:"active"`
+	Queued int `json:"queued"`
+	// QueuedByPriority 各优先级排队中的请求数
+	QueuedByPriority map[string]int `json:"queued_by_priority"`
+}
+
+// Running 列出主机上已加载的模型
+func (c *Client) Running(ctx context.Context) ([]RunningModel, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.ResponseTimeout)
+	defer cancel()
+	resp, err := c.client.ListRunning(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return runningModels(resp.Models), nil
+}
+
+// runningModels 转换 /api/ps 的结果
+func runningModels(models []api.ProcessModelResponse) []RunningModel {
+	result := make([]RunningModel, 0, len(models))
+	for _, m := range models {
+		result = append(result, RunningModel{
+			Name:          m.Name,
+			Size:          m.Size,
+			SizeVRAM:      m.SizeVRAM,
+			ContextLength: m.ContextLength,
+			ExpiresAt:     m.ExpiresAt,
+		})
+	}
+	return result
+}
+
+// Status 并发查询各主机已加载的模型和显存占用，查询失败的主机记录错误，不影响其他主机
+func (p *Pool) Status(ctx context.Context) []BackendStatus {
+	hosts := p.Hosts()
+	statuses := make([]BackendStatus, len(p.backends))
+	var wg sync.WaitGroup
+	for i, b := range p.backends {
+		statuses[i].HostStatus = hosts[i]
+		wg.Go(func() {
+			loaded, err := b.client.Running(ctx)
+			if err != nil {
+				statuses[i].PSError = err.Error()
+				return
+			}
+			statuses[i].Loaded = loaded
+			for _, m := range loaded {
+				statuses[i].VRAMBytes += m.SizeVRAM
+			}
+		})
+	}
+	wg.Wait()
+	return statuses
+}
+
+// Queue 返回发往 Ollama 的请求的排队情况
+func (p *Pool) Queue() QueueStatus {
+	active, queued := p.sched.stats()
+	s := QueueStatus{Active: active, QueuedByPriority: make(map[string]int, priority.Classes)}
+	if p.sched != nil {
+		s.Limit = p.sched.limit
+	}
+	for c, n := range queued {
+		s.QueuedByPriority[priority.Class(c).String()] = n
+		s.Queued += n
+	}
+	return s
+}
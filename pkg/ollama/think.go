@@ -0,0 +1,48 @@
+package ollama
+
+import "strings"
+
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+// SplitThinking 从回答中分离 <think>...</think> 思考过程（qwen3、deepseek-r1 等推理模型未使用 think 参数时的输出），
+// 返回思考过程和去掉思考过程后的回答。模型模板已在提示中写入 <think> 时回答中只有结束标记，
+// 结束标记之前的内容都是思考过程；输出被截断缺少结束标记时，开始标记之后的内容都是思考过程
+func SplitThinking(content string) (thinking, answer string) {
+	if !strings.Contains(content, thinkOpen) && !strings.Contains(content, thinkClose) {
+		return "", content
+	}
+
+	var thoughts []string
+	rest := content
+	if end := strings.Index(rest, thinkClose); end >= 0 && !strings.Contains(rest[:end], thinkOpen) {
+		thoughts = append(thoughts, rest[:end])
+		rest = rest[end+len(thinkClose):]
+	}
+
+	var b strings.Builder
+	for {
+		before, after, found := strings.Cut(rest, thinkOpen)
+		b.WriteString(before)
+		if !found {
+			break
+		}
+		thought, remaining, closed := strings.Cut(after, thinkClose)
+		thoughts = append(thoughts, thought)
+		if !closed {
+			break
+		}
+		rest = remaining
+	}
+
+	// qwen3 关闭思考时仍会输出空的 <think></think>
+	var nonEmpty []string
+	for _, t := range thoughts {
+		if t = strings.TrimSpace(t); t != "" {
+			nonEmpty = append(nonEmpty, t)
+		}
+	}
+	return strings.Join(nonEmpty, "\n\n"), strings.TrimSpace(b.String())
+}
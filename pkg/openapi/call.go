@@ -0,0 +1,209 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Call 按参数拼出请求并调用 API，返回响应体；HTTP 状态码大于等于 400 时返回包含响应内容的错误
+func (o *Operation) Call(ctx context.Context, args map[string]any) (string, error) {
+	known := make(map[string]bool, len(o.params))
+	for _, p := range o.params {
+		known[p.arg] = true
+	}
+	if o.body != nil {
+		if o.body.arg != "" {
+			known[o.body.arg] = true
+		}
+		for _, f := range o.body.fields {
+			known[f] = true
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(args)) {
+		if !known[k] {
+			return "", fmt.Errorf("unknown argument: %s", k)
+		}
+	}
+	required, _ := o.schema["required"].([]any)
+	for _, k := range required {
+		if v, ok := args[k.(string)]; !ok || v == nil {
+			return "", fmt.Errorf("missing required argument: %s", k)
+		}
+	}
+
+	p := o.path
+	query := url.Values{}
+	header := http.Header{}
+	var cookies []*http.Cookie
+	for _, pm := range o.params {
+		v, ok := args[pm.arg]
+		if !ok || v == nil {
+			continue
+		}
+		switch pm.in {
+		case "path":
+			// PathEscape 不转义 . 和 ..，这样的值会改变请求的路径层级
+			value := joinValue(v)
+			if value == "" || value == "." || value == ".." {
+				return "", fmt.Errorf("invalid path parameter %s: %q", pm.arg, value)
+			}
+			p = strings.ReplaceAll(p, "{"+pm.name+"}", url.PathEscape(value))
+		case "query":
+			if list, ok := v.([]any); ok {
+				for _, item := range list {
+					query.Add(pm.name, formatValue(item))
+				}
+			} else {
+				query.Set(pm.name, formatValue(v))
+			}
+		case "header":
+			header.Set(pm.name, joinValue(v))
+		case "cookie":
+			cookies = append(cookies, &http.Cookie{Name: pm.name, Value: joinValue(v)})
+		}
+	}
+
+	if i := strings.IndexByte(p, '{'); i >= 0 && strings.IndexByte(p[i:], '}') >= 0 {
+		return "", fmt.Errorf("missing path parameter in %s", p)
+	}
+
+	var body io.Reader
+	if o.body != nil {
+		if payload := o.payload(args); payload != nil {
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return "", fmt.Errorf("marshal request body: %w", err)
+			}
+			body = bytes.NewReader(data)
+			header.Set("Content-Type", "application/json")
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, o.method, o.api.baseURL+p, body)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range o.api.headers {
+		req.Header.Set(k, v)
+	}
+	maps.Copy(req.Header, header)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	o.authorize(req, query)
+	req.URL.RawQuery = query.Encode()
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	resp, err := o.api.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", o, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("%s: read response: %w", o, err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		if len(data) > maxErrorBytes {
+			data = data[:maxErrorBytes]
+		}
+		return "", fmt.Errorf("%s: HTTP %d: %s", o, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if len(data) == 0 {
+		return fmt.Sprintf("HTTP %d", resp.StatusCode), nil
+	}
+	if len(data) > maxResponseBytes {
+		return string(data[:maxResponseBytes]) + fmt.Sprintf("\n... response truncated at %d bytes", maxResponseBytes), nil
+	}
+	return string(data), nil
+}
+
+// payload 由参数组装请求体，没有请求体参数时返回 nil
+func (o *Operation) payload(args map[string]any) any {
+	if o.body.arg != "" {
+		return args[o.body.arg]
+	}
+
+	fields := make(map[string]any)
+	for _, f := range o.body.fields {
+		if v, ok := args[f]; ok {
+			fields[f] = v
+		}
+	}
+	if len(fields) == 0 && !o.body.required {
+		return nil
+	}
+	return fields
+}
+
+// authorize 按操作的 security 要求附加凭证：使用第一组所有认证方式都配置了凭证的要求；
+// 都不满足时不附加，由 API 返回认证错误
+func (o *Operation) authorize(req *http.Request, query url.Values) {
+	for _, requirement := range o.security {
+		satisfied := len(requirement) > 0
+		for name := range requirement {
+			if _, ok := o.api.credentials[name]; !ok {
+				satisfied = false
+				break
+			}
+		}
+		if !satisfied {
+			continue
+		}
+		for name := range requirement {
+			o.api.apply(req, query, name)
+		}
+		return
+	}
+}
+
+// apply 附加一种认证方式的凭证
+func (a *API) apply(req *http.Request, query url.Values, name string) {
+	credential := a.credentials[name]
+	scheme := a.schemes[name]
+	switch strings.ToLower(scheme.Type) {
+	case "apikey":
+		switch scheme.In {
+		case "query":
+			query.Set(scheme.Name, credential)
+		case "cookie":
+			req.AddCookie(&http.Cookie{Name: scheme.Name, Value: credential})
+		default:
+			req.Header.Set(scheme.Name, credential)
+		}
+	case "http":
+		if strings.EqualFold(scheme.Scheme, "basic") {
+			user, password, _ := strings.Cut(credential, ":")
+			req.SetBasicAuth(user, password)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+credential)
+		}
+	default:
+		// oauth2、openIdConnect 使用配置的 access token
+		req.Header.Set("Authorization", "Bearer "+credential)
+	}
+}
+
+// joinValue 路径、请求头和 cookie 中的数组以逗号连接
+func joinValue(v any) string {
+	list, ok := v.([]any)
+	if !ok {
+		return formatValue(v)
+	}
+	items := make([]string, len(list))
+	for i, item := range list {
+		items[i] = formatValue(item)
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,340 @@
+// Package openapi 根据 OpenAPI 3 规范为 REST API 的操作生成工具：参数和 JSON 请求体映射为工具参数，
+// 调用时按规范拼出请求，并按 securitySchemes 附加配置中的凭证，无需为每个 API 编写 MCP 封装
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+const (
+	// maxSpecBytes 规范文件的最大字节数
+	maxSpecBytes = 16 << 20
+	// maxResponseBytes 返回给模型的响应体最大字节数
+	maxResponseBytes = 1 << 20
+	// maxErrorBytes 请求失败时错误信息中保留的响应体字节数
+	maxErrorBytes = 2 << 10
+	// maxToolName 工具名的最大长度
+	maxToolName = 64
+	// bodyArg 请求体不是对象或字段与参数重名时，整个请求体作为该参数传入，与参数重名时为 request_body
+	bodyArg = "body"
+)
+
+// invalidNameChars 工具名中不允许的字符
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// API 由一份规范生成的工具集
+type API struct {
+	name        string
+	baseURL     string
+	client      *http.Client
+	headers     map[string]string
+	credentials map[string]string
+	schemes     map[string]securityScheme
+	operations  []*Operation
+}
+
+// Operation 规范中的一个操作，对应一个工具
+type Operation struct {
+	api         *API
+	name        string
+	description string
+	method      string
+	path        string
+	params      []param
+	body        *bodySpec
+	security    []map[string][]string
+	schema      map[string]any
+}
+
+// param 映射为工具参数的路径、查询、请求头或 cookie 参数
+type param struct {
+	arg  string // 工具参数名，与其他参数重名时为 <in>_<name>
+	name string
+	in   string
+}
+
+// bodySpec 请求体到工具参数的映射
+type bodySpec struct {
+	arg      string   // 整个请求体作为该参数传入
+	fields   []string // arg 为空时为对象的各个字段，每个字段是一个工具参数
+	required bool
+}
+
+// Load 读取规范并生成选中操作的工具，client 用于获取规范和调用 API（出站策略的客户端）；
+// 单个操作无法生成工具时记录日志并跳过
+func Load(ctx context.Context, client *http.Client, cfg config.OpenAPIConfig) (*API, error) {
+	doc, err := readSpec(ctx, client, cfg.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	base := cfg.BaseURL
+	if base == "" {
+		if base, err = doc.baseURL(cfg.Spec); err != nil {
+			return nil, err
+		}
+	}
+	api := &API{
+		name:        cfg.Name,
+		baseURL:     strings.TrimSuffix(base, "/"),
+		client:      client,
+		headers:     cfg.Headers,
+		credentials: cfg.Credentials,
+		schemes:     doc.Components.SecuritySchemes,
+	}
+
+	paths := slices.Sorted(maps.Keys(doc.Paths))
+	names := make(map[string]bool)
+	for _, p := range paths {
+		item := doc.Paths[p]
+		for _, m := range item.operations() {
+			name := toolName(cfg.ToolPrefix, m.op.OperationID, m.method, p)
+			if !selected(cfg.Operations, m.op.OperationID, name, m.method) {
+				continue
+			}
+			if names[name] {
+				klog.InfoS("Duplicate OpenAPI tool name, skipping operation", "api", cfg.Name, "tool", name, "method", m.method, "path", p)
+				continue
+			}
+			op, err := api.newOperation(doc, name, m.method, p, item.Parameters, m.op)
+			if err != nil {
+				klog.ErrorS(err, "Skipping OpenAPI operation", "api", cfg.Name, "method", m.method, "path", p)
+				continue
+			}
+			names[name] = true
+			api.operations = append(api.operations, op)
+		}
+	}
+	return api, nil
+}
+
+// Name API 名称
+func (a *API) Name() string {
+	return a.name
+}
+
+// Operations 生成了工具的操作
+func (a *API) Operations() []*Operation {
+	return a.operations
+}
+
+// toolName 生成工具名：operationId，没有时为 <方法>_<路径>，不允许的字符替换为下划线
+func toolName(prefix, operationID, method, p string) string {
+	name := operationID
+	if name == "" {
+		name = strings.ToLower(method) + "_" + p
+	}
+	name = prefix + strings.Trim(invalidNameChars.ReplaceAllString(name, "_"), "_")
+	if len(name) > maxToolName {
+		name = name[:maxToolName]
+	}
+	return name
+}
+
+// selected 操作是否在 operations 中；operations 为空时只选择 GET 操作
+func selected(patterns []string, operationID, name, method string) bool {
+	if len(patterns) == 0 {
+		return method == http.MethodGet
+	}
+	id := operationID
+	if id == "" {
+		id = name
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, id); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// newOperation 展开参数和请求体，生成工具参数的 schema
+func (a *API) newOperation(doc *document, name, method, p string, shared []parameter, op *operation) (*Operation, error) {
+	o := &Operation{
+		api:         a,
+		name:        name,
+		description: describe(method, p, op),
+		method:      method,
+		path:        p,
+		security:    doc.Security,
+	}
+	if op.Security != nil {
+		o.security = *op.Security
+	}
+
+	properties := make(map[string]any)
+	var required []any
+
+	// 操作级参数覆盖路径级同名同位置的参数
+	params := make(map[string]parameter)
+	var order []string
+	for _, raw := range slices.Concat(shared, op.Parameters) {
+		pm, err := doc.parameter(raw)
+		if err != nil {
+			return nil, err
+		}
+		key := pm.In + ":" + pm.Name
+		if _, ok := params[key]; !ok {
+			order = append(order, key)
+		}
+		params[key] = pm
+	}
+	for _, key := range order {
+		pm := params[key]
+		switch pm.In {
+		case "path", "query", "header", "cookie":
+		default:
+			return nil, fmt.Errorf("parameter %s: unsupported location %q", pm.Name, pm.In)
+		}
+		schema, err := doc.schema(pm.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", pm.Name, err)
+		}
+		if pm.Description != "" {
+			schema["description"] = pm.Description
+		}
+		arg := pm.Name
+		if _, ok := properties[arg]; ok {
+			arg = pm.In + "_" + pm.Name
+		}
+		properties[arg] = schema
+		// 路径参数总是必填
+		if pm.Required || pm.In == "path" {
+			required = append(required, arg)
+		}
+		o.params = append(o.params, param{arg: arg, name: pm.Name, in: pm.In})
+	}
+
+	body, err := doc.requestBody(op.RequestBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		schema, ok := jsonSchema(body)
+		switch {
+		case !ok && body.Required:
+			return nil, fmt.Errorf("request body: only JSON content is supported")
+		case ok:
+			if schema, err = doc.schema(schema); err != nil {
+				return nil, fmt.Errorf("request body: %w", err)
+			}
+			o.body = &bodySpec{required: body.Required}
+			if fields, ok := schema["properties"].(map[string]any); ok && !overlaps(properties, fields) {
+				bodyRequired, _ := schema["required"].([]any)
+				for _, f := range slices.Sorted(maps.Keys(fields)) {
+					properties[f] = fields[f]
+					o.body.fields = append(o.body.fields, f)
+					if body.Required && slices.Contains(bodyRequired, any(f)) {
+						required = append(required, f)
+					}
+				}
+			} else {
+				if body.Description != "" {
+					schema["description"] = body.Description
+				}
+				o.body.arg = bodyArg
+				if _, ok := properties[bodyArg]; ok {
+					o.body.arg = "request_" + bodyArg
+				}
+				properties[o.body.arg] = schema
+				if body.Required {
+					required = append(required, o.body.arg)
+				}
+			}
+		}
+	}
+
+	o.schema = map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		o.schema["required"] = required
+	}
+	return o, nil
+}
+
+// describe 工具说明：summary 和 description，最后附上方法和路径
+func describe(method, p string, op *operation) string {
+	var parts []string
+	for _, s := range []string{op.Summary, op.Description} {
+		if s = strings.TrimSpace(s); s != "" && !slices.Contains(parts, s) {
+			parts = append(parts, s)
+		}
+	}
+	if op.Deprecated {
+		parts = append(parts, "(deprecated)")
+	}
+	parts = append(parts, fmt.Sprintf("[%s %s]", method, p))
+	return strings.Join(parts, "\n")
+}
+
+// jsonSchema 返回 JSON 请求体的 schema
+func jsonSchema(body *requestBody) (map[string]any, bool) {
+	types := slices.Sorted(maps.Keys(body.Content))
+	for _, ct := range types {
+		mediaType, _, _ := strings.Cut(ct, ";")
+		if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+			return body.Content[ct].Schema, true
+		}
+	}
+	return nil, false
+}
+
+// overlaps 请求体字段是否与参数或 body 重名
+func overlaps(properties, fields map[string]any) bool {
+	if _, ok := fields[bodyArg]; ok {
+		return true
+	}
+	for f := range fields {
+		if _, ok := properties[f]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Name 工具名
+func (o *Operation) Name() string {
+	return o.name
+}
+
+// Description 工具说明
+func (o *Operation) Description() string {
+	return o.description
+}
+
+// InputSchema 工具参数的 JSON Schema
+func (o *Operation) InputSchema() map[string]any {
+	return o.schema
+}
+
+// String 方法和路径，用于日志
+func (o *Operation) String() string {
+	return o.method + " " + o.path
+}
+
+// formatValue 参数值转为字符串：数字不使用科学计数法，对象编码为 JSON
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool, int, int64:
+		return fmt.Sprint(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
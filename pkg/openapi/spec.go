@@ -0,0 +1,258 @@
+package openapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// document OpenAPI 3 文档中生成工具用到的部分
+type document struct {
+	OpenAPI    string                `yaml:"openapi"`
+	Servers    []server              `yaml:"servers"`
+	Paths      map[string]pathItem   `yaml:"paths"`
+	Components components            `yaml:"components"`
+	Security   []map[string][]string `yaml:"security"`
+}
+
+// server 服务地址，{变量} 使用默认值
+type server struct {
+	URL       string `yaml:"url"`
+	Variables map[string]struct {
+		Default string `yaml:"default"`
+	} `yaml:"variables"`
+}
+
+// pathItem 路径下的操作
+type pathItem struct {
+	Parameters []parameter `yaml:"parameters"`
+	Get        *operation  `yaml:"get"`
+	Put        *operation  `yaml:"put"`
+	Post       *operation  `yaml:"post"`
+	Delete     *operation  `yaml:"delete"`
+	Patch      *operation  `yaml:"patch"`
+	Head       *operation  `yaml:"head"`
+	Options    *operation  `yaml:"options"`
+}
+
+// operations 按固定顺序返回路径下的操作
+func (p pathItem) operations() []struct {
+	method string
+	op     *operation
+} {
+	all := []struct {
+		method string
+		op     *operation
+	}{
+		{http.MethodGet, p.Get}, {http.MethodPut, p.Put}, {http.MethodPost, p.Post}, {http.MethodDelete, p.Delete},
+		{http.MethodPatch, p.Patch}, {http.MethodHead, p.Head}, {http.MethodOptions, p.Options},
+	}
+	var out []struct {
+		method string
+		op     *operation
+	}
+	for _, item := range all {
+		if item.op != nil {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// operation 单个 API 操作
+type operation struct {
+	OperationID string                 `yaml:"operationId"`
+	Summary     string                 `yaml:"summary"`
+	Description string                 `yaml:"description"`
+	Deprecated  bool                   `yaml:"deprecated"`
+	Parameters  []parameter            `yaml:"parameters"`
+	RequestBody *requestBody           `yaml:"requestBody"`
+	Security    *[]map[string][]string `yaml:"security"` // 为空列表时不需要认证，未设置时使用文档级 security
+}
+
+// parameter 路径、查询、请求头或 cookie 参数
+type parameter struct {
+	Ref         string         `yaml:"$ref"`
+	Name        string         `yaml:"name"`
+	In          string         `yaml:"in"`
+	Description string         `yaml:"description"`
+	Required    bool           `yaml:"required"`
+	Schema      map[string]any `yaml:"schema"`
+}
+
+// requestBody 请求体，只支持 JSON
+type requestBody struct {
+	Ref         string `yaml:"$ref"`
+	Description string `yaml:"description"`
+	Required    bool   `yaml:"required"`
+	Content     map[string]struct {
+		Schema map[string]any `yaml:"schema"`
+	} `yaml:"content"`
+}
+
+// components 可被 $ref 引用的定义
+type components struct {
+	Schemas         map[string]map[string]any `yaml:"schemas"`
+	Parameters      map[string]parameter      `yaml:"parameters"`
+	RequestBodies   map[string]requestBody    `yaml:"requestBodies"`
+	SecuritySchemes map[string]securityScheme `yaml:"securitySchemes"`
+}
+
+// securityScheme 认证方式
+type securityScheme struct {
+	Type   string `yaml:"type"`   // apiKey、http、oauth2、openIdConnect
+	Scheme string `yaml:"scheme"` // http 类型的 bearer 或 basic
+	In     string `yaml:"in"`     // apiKey 的位置：header、query 或 cookie
+	Name   string `yaml:"name"`   // apiKey 的参数名
+}
+
+// readSpec 读取规范文件或 http(s) 地址，JSON 也按 YAML 解析
+func readSpec(ctx context.Context, client *http.Client, spec string) (*document, error) {
+	var data []byte
+	if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, spec, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetch spec: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetch spec: HTTP %d", resp.StatusCode)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, maxSpecBytes)); err != nil {
+			return nil, fmt.Errorf("fetch spec: %w", err)
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(spec); err != nil {
+			return nil, fmt.Errorf("read spec: %w", err)
+		}
+	}
+
+	var doc document
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported spec version %q, only OpenAPI 3 is supported", doc.OpenAPI)
+	}
+	return &doc, nil
+}
+
+// baseURL 第一个 servers 的地址，相对地址按规范地址解析
+func (d *document) baseURL(spec string) (string, error) {
+	if len(d.Servers) == 0 {
+		return "", fmt.Errorf("spec has no servers, set base_url")
+	}
+	s := d.Servers[0]
+	raw := s.URL
+	for name, v := range s.Variables {
+		raw = strings.ReplaceAll(raw, "{"+name+"}", v.Default)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid server url %q: %w", raw, err)
+	}
+	if !u.IsAbs() {
+		base, err := url.Parse(spec)
+		if err != nil || !base.IsAbs() {
+			return "", fmt.Errorf("relative server url %q requires base_url", raw)
+		}
+		u = base.ResolveReference(u)
+	}
+	return u.String(), nil
+}
+
+// parameter 展开参数的 $ref
+func (d *document) parameter(p parameter) (parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/")
+	if !ok {
+		return p, fmt.Errorf("unsupported $ref %q", p.Ref)
+	}
+	ref, ok := d.Components.Parameters[name]
+	if !ok {
+		return p, fmt.Errorf("parameter %q not found", p.Ref)
+	}
+	return d.parameter(ref)
+}
+
+// requestBody 展开请求体的 $ref
+func (d *document) requestBody(b *requestBody) (*requestBody, error) {
+	if b == nil || b.Ref == "" {
+		return b, nil
+	}
+	name, ok := strings.CutPrefix(b.Ref, "#/components/requestBodies/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q", b.Ref)
+	}
+	ref, ok := d.Components.RequestBodies[name]
+	if !ok {
+		return nil, fmt.Errorf("request body %q not found", b.Ref)
+	}
+	return d.requestBody(&ref)
+}
+
+// schema 返回展开了所有 $ref 的 schema 副本，递归引用（expanding 中正在展开的定义）以 object 代替
+func (d *document) schema(s map[string]any, expanding ...string) (map[string]any, error) {
+	if s == nil {
+		return map[string]any{}, nil
+	}
+	if ref, ok := s["$ref"].(string); ok {
+		name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+		if !ok {
+			return nil, fmt.Errorf("unsupported $ref %q", ref)
+		}
+		target, ok := d.Components.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("schema %q not found", ref)
+		}
+		if slices.Contains(expanding, name) {
+			return map[string]any{"type": "object"}, nil
+		}
+		return d.schema(target, append(expanding, name)...)
+	}
+
+	out := maps.Clone(s)
+	for k, v := range s {
+		resolved, err := d.resolve(v, expanding)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = resolved
+	}
+	return out, nil
+}
+
+// resolve 展开 schema 中任意值内嵌的 $ref
+func (d *document) resolve(v any, expanding []string) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		return d.schema(v, expanding...)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			resolved, err := d.resolve(item, expanding)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
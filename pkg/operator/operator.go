@@ -0,0 +1,281 @@
+// Package operator 实现声明式配置模式：监听集群中的 Agent、ToolProfile、KnowledgeBase 自定义资源，
+// 并将其调和到当前运行的 Agent 进程（默认模型、MCP 工具、RAG 知识库）。
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/k8stools"
+)
+
+const (
+	// resyncPeriod informer 全量同步周期
+	resyncPeriod = 10 * time.Minute
+	// retryDelay 调和失败后的重试间隔
+	retryDelay = 30 * time.Second
+	// reconcileTimeout 单次调和超时（包含知识库导入）
+	reconcileTimeout = 10 * time.Minute
+)
+
+// Controller 自定义资源控制器
+type Controller struct {
+	cfg       config.OperatorConfig
+	agent     *agent.Agent
+	dynamic   dynamic.Interface
+	namespace string
+
+	listers map[schema.GroupVersionResource]cache.GenericLister
+	trigger chan struct{}
+
+	// ingested 已导入的知识库：名称 -> generation
+	ingested map[string]int64
+}
+
+// New 创建控制器
+func New(cfg config.OperatorConfig, ag *agent.Agent) (*Controller, error) {
+	restCfg, namespace, err := k8stools.LoadRESTConfig(cfg.Kubeconfig, "")
+	if err != nil {
+		return nil, err
+	}
+	dyn, err := dynamic.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("create dynamic client: %w", err)
+	}
+	if cfg.Namespace != "" {
+		namespace = cfg.Namespace
+	}
+
+	return &Controller{
+		cfg:       cfg,
+		agent:     ag,
+		dynamic:   dyn,
+		namespace: namespace,
+		listers:   make(map[schema.GroupVersionResource]cache.GenericLister),
+		trigger:   make(chan struct{}, 1),
+		ingested:  make(map[string]int64),
+	}, nil
+}
+
+// Run 监听资源变化并调和，直到 ctx 结束
+// 所有副本都需要运行：每个进程都要加载相同的工具与知识库，状态仅由主节点写回。
+func (c *Controller) Run(ctx context.Context) {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.dynamic, resyncPeriod, c.namespace, nil)
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { c.enqueue() },
+		UpdateFunc: func(_, _ any) { c.enqueue() },
+		DeleteFunc: func(any) { c.enqueue() },
+	}
+
+	var synced []cache.InformerSynced
+	for _, gvr := range []schema.GroupVersionResource{agentGVR, toolProfileGVR, knowledgeBaseGVR} {
+		informer := factory.ForResource(gvr)
+		informer.Informer().AddEventHandler(handler)
+		c.listers[gvr] = informer.Lister()
+		synced = append(synced, informer.Informer().HasSynced)
+	}
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	klog.InfoS("Operator started", "namespace", c.namespace, "agent", c.cfg.AgentName)
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			klog.InfoS("Operator stopped")
+			return
+		case <-c.trigger:
+			if err := c.reconcile(ctx); err != nil {
+				klog.ErrorS(err, "Failed to reconcile agent", "agent", c.cfg.AgentName)
+				time.AfterFunc(retryDelay, c.enqueue)
+			}
+		}
+	}
+}
+
+// enqueue 触发一次调和（合并连续的变化）
+func (c *Controller) enqueue() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// reconcile 将 Agent 资源调和到当前进程
+func (c *Controller) reconcile(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	obj, err := c.get(agentGVR, c.cfg.AgentName)
+	if apierrors.IsNotFound(err) {
+		klog.InfoS("Agent resource not found, keeping current configuration", "agent", c.cfg.AgentName, "namespace", c.namespace)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var spec AgentSpec
+	if err := decodeSpec(obj, &spec); err != nil {
+		return err
+	}
+
+	c.agent.SetDefaultModel(spec.Model)
+
+	var errs []error
+	servers, err := c.mcpServers(spec.ToolProfiles)
+	if err != nil {
+		errs = append(errs, err)
+	} else if err := c.agent.ReconcileMCPServers(ctx, servers); err != nil {
+		errs = append(errs, fmt.Errorf("reconcile mcp servers: %w", err))
+	}
+
+	if err := c.ingestKnowledgeBases(ctx, spec.KnowledgeBases); err != nil {
+		errs = append(errs, err)
+	}
+
+	err = errors.Join(errs...)
+	c.updateStatus(ctx, obj, err)
+	if err == nil {
+		klog.InfoS("Agent reconciled", "agent", c.cfg.AgentName, "generation", obj.GetGeneration(),
+			"model", c.agent.DefaultModel(), "tools", len(c.agent.ListTools(ctx)))
+	}
+	return err
+}
+
+// mcpServers 汇总引用的 ToolProfile 中的 MCP 服务器
+func (c *Controller) mcpServers(profiles []string) ([]config.MCPServerConfig, error) {
+	var servers []config.MCPServerConfig
+	seen := make(map[string]string)
+	for _, name := range profiles {
+		obj, err := c.get(toolProfileGVR, name)
+		if err != nil {
+			return nil, fmt.Errorf("get tool profile %s: %w", name, err)
+		}
+		var spec ToolProfileSpec
+		if err := decodeSpec(obj, &spec); err != nil {
+			return nil, err
+		}
+		for _, s := range spec.MCPServers {
+			if other, ok := seen[s.Name]; ok {
+				return nil, fmt.Errorf("mcp server %q defined in both tool profiles %s and %s", s.Name, other, name)
+			}
+			seen[s.Name] = name
+			servers = append(servers, s.toConfig())
+		}
+	}
+	return servers, nil
+}
+
+// ingestKnowledgeBases 导入新增或有变化的知识库
+func (c *Controller) ingestKnowledgeBases(ctx context.Context, names []string) error {
+	var errs []error
+	for _, name := range names {
+		obj, err := c.get(knowledgeBaseGVR, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("get knowledge base %s: %w", name, err))
+			continue
+		}
+		if c.ingested[name] == obj.GetGeneration() {
+			continue
+		}
+
+		var spec KnowledgeBaseSpec
+		if err := decodeSpec(obj, &spec); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		collection := spec.Collection
+		if collection == "" {
+			collection = name
+		}
+
+		var failed bool
+		for _, source := range spec.Sources {
+			n, err := c.agent.IngestRAG(ctx, collection, source)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("ingest %s into %s: %w", source, collection, err))
+				failed = true
+				continue
+			}
+			klog.InfoS("Knowledge base source ingested", "knowledgeBase", name, "collection", collection, "source", source, "documents", n)
+		}
+		if !failed {
+			c.ingested[name] = obj.GetGeneration()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// updateStatus 由主节点写回 Agent 状态
+func (c *Controller) updateStatus(ctx context.Context, obj *unstructured.Unstructured, reconcileErr error) {
+	if !c.agent.IsLeader() {
+		return
+	}
+
+	status := AgentStatus{
+		ObservedGeneration: obj.GetGeneration(),
+		Phase:              "Ready",
+		Tools:              len(c.agent.ListTools(ctx)),
+		Identity:           c.agent.Identity(),
+	}
+	if reconcileErr != nil {
+		status.Phase = "Error"
+		status.Message = reconcileErr.Error()
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		klog.ErrorS(err, "Failed to convert agent status")
+		return
+	}
+
+	updated := obj.DeepCopy()
+	updated.Object["status"] = content
+	_, err = c.dynamic.Resource(agentGVR).Namespace(c.namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		klog.ErrorS(err, "Failed to update agent status", "agent", obj.GetName())
+	}
+}
+
+// get 从 informer 缓存中获取资源
+func (c *Controller) get(gvr schema.GroupVersionResource, name string) (*unstructured.Unstructured, error) {
+	obj, err := c.listers[gvr].ByNamespace(c.namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", obj)
+	}
+	return u, nil
+}
+
+// decodeSpec 将资源的 spec 字段解码为结构体
+func decodeSpec(obj *unstructured.Unstructured, out any) error {
+	spec, _, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil {
+		return fmt.Errorf("read spec of %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, out); err != nil {
+		return fmt.Errorf("decode spec of %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	return nil
+}
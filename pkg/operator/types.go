@@ -0,0 +1,69 @@
+package operator
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// Group 自定义资源的 API 组
+const Group = "agent.champly.io"
+
+// Version 自定义资源的 API 版本
+const Version = "v1alpha1"
+
+var (
+	// agentGVR Agent 资源
+	agentGVR = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "agents"}
+	// toolProfileGVR ToolProfile 资源
+	toolProfileGVR = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "toolprofiles"}
+	// knowledgeBaseGVR KnowledgeBase 资源
+	knowledgeBaseGVR = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "knowledgebases"}
+)
+
+// AgentSpec Agent 资源定义：一个运行中 Agent 的模型、工具和知识库
+type AgentSpec struct {
+	Model          string   `json:"model,omitempty"`          // 默认模型，为空时使用配置文件中的模型
+	ToolProfiles   []string `json:"toolProfiles,omitempty"`   // 引用的 ToolProfile 名称
+	KnowledgeBases []string `json:"knowledgeBases,omitempty"` // 引用的 KnowledgeBase 名称
+}
+
+// ToolProfileSpec ToolProfile 资源定义：一组 MCP 服务器
+type ToolProfileSpec struct {
+	MCPServers []MCPServer `json:"mcpServers,omitempty"`
+}
+
+// MCPServer ToolProfile 中的 MCP 服务器
+type MCPServer struct {
+	Name    string            `json:"name"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+}
+
+// toConfig 转换为 MCP 服务器配置
+func (s MCPServer) toConfig() config.MCPServerConfig {
+	return config.MCPServerConfig{
+		Name:      s.Name,
+		Command:   s.Command,
+		Args:      s.Args,
+		Env:       s.Env,
+		Transport: "stdio",
+		Enabled:   true,
+	}
+}
+
+// KnowledgeBaseSpec KnowledgeBase 资源定义：导入 RAG 集合的文档来源
+type KnowledgeBaseSpec struct {
+	Collection string   `json:"collection,omitempty"` // RAG 集合名称，为空时使用资源名称
+	Sources    []string `json:"sources"`              // 文件、目录或 URL
+}
+
+// AgentStatus Agent 资源状态
+type AgentStatus struct {
+	ObservedGeneration int64  `json:"observedGeneration"`
+	Phase              string `json:"phase"` // Ready 或 Error
+	Message            string `json:"message,omitempty"`
+	Tools              int    `json:"tools"`
+	Identity           string `json:"identity,omitempty"` // 写入状态的实例
+}
@@ -0,0 +1,244 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// Plugin 运行中的插件进程
+type Plugin struct {
+	name        string
+	cmd         *exec.Cmd
+	stdin       io.WriteCloser
+	client      *rpc.Client
+	tools       []ToolSpec
+	done        chan struct{} // 进程退出后关闭
+	callTimeout time.Duration
+	grace       time.Duration
+}
+
+// Name 插件名（程序文件名去掉扩展名）
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+// Tools 插件提供的工具
+func (p *Plugin) Tools() []ToolSpec {
+	return p.tools
+}
+
+// Call 调用插件的工具，超过 plugins.call_timeout 或 ctx 取消时返回错误（插件中的调用在截止时间后取消）
+func (p *Plugin) Call(ctx context.Context, name string, args map[string]any) (string, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("marshal arguments: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.callTimeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	var reply CallReply
+	call := p.client.Go(serviceName+".Call", CallArgs{Name: name, Args: data, Deadline: deadline}, &reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+	case <-ctx.Done():
+		return "", fmt.Errorf("plugin %s: %w", p.name, ctx.Err())
+	}
+	if call.Error != nil {
+		return "", fmt.Errorf("plugin %s: %w", p.name, call.Error)
+	}
+	if reply.Error != "" {
+		return "", errors.New(reply.Error)
+	}
+	return reply.Result, nil
+}
+
+// start 启动插件程序并完成握手
+func start(ctx context.Context, path string, cfg config.PluginConfig) (*Plugin, error) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	cmd := exec.Command(path)
+	cmd.Env = append(os.Environ(), cookieKey+"="+cookieValue)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", path, err)
+	}
+
+	p := &Plugin{
+		name:        name,
+		cmd:         cmd,
+		stdin:       stdin,
+		done:        make(chan struct{}),
+		callTimeout: cfg.CallTimeout,
+		grace:       cfg.ShutdownGrace,
+	}
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			klog.InfoS("Plugin output", "plugin", name, "line", scanner.Text())
+		}
+	}()
+	go func() {
+		err := cmd.Wait()
+		klog.V(2).InfoS("Plugin process exited", "plugin", name, "err", err)
+		close(p.done)
+	}()
+
+	if err := p.handshake(ctx, bufio.NewReader(stdout), cfg.StartTimeout); err != nil {
+		p.stop()
+		return nil, err
+	}
+	return p, nil
+}
+
+// handshake 读取插件的握手信息，连接插件的 RPC 服务并获取工具列表
+func (p *Plugin) handshake(ctx context.Context, stdout *bufio.Reader, timeout time.Duration) error {
+	lines := make(chan string, 1)
+	go func() {
+		line, _ := stdout.ReadString('\n')
+		lines <- line
+		// 之后的标准输出丢弃，避免插件写满管道后阻塞
+		io.Copy(io.Discard, stdout)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var line string
+	select {
+	case line = <-lines:
+	case <-p.done:
+		return fmt.Errorf("plugin %s exited before handshake", p.name)
+	case <-timer.C:
+		return fmt.Errorf("plugin %s: handshake timed out after %s", p.name, timeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	parts := strings.Split(strings.TrimSpace(line), "|")
+	if len(parts) != 3 {
+		return fmt.Errorf("plugin %s: invalid handshake %q", p.name, line)
+	}
+	if version, err := strconv.Atoi(parts[0]); err != nil || version != ProtocolVersion {
+		return fmt.Errorf("plugin %s: unsupported protocol version %q (want %d)", p.name, parts[0], ProtocolVersion)
+	}
+	client, err := rpc.Dial(parts[1], parts[2])
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", p.name, err)
+	}
+	p.client = client
+	if err := client.Call(serviceName+".Tools", Empty{}, &p.tools); err != nil {
+		return fmt.Errorf("plugin %s: list tools: %w", p.name, err)
+	}
+	return nil
+}
+
+// stop 关闭连接和插件的标准输入，等待 plugins.shutdown_grace 后仍未退出时强制结束
+func (p *Plugin) stop() {
+	if p.client != nil {
+		p.client.Close()
+	}
+	p.stdin.Close()
+	select {
+	case <-p.done:
+		return
+	case <-time.After(p.grace):
+	}
+	klog.InfoS("Plugin did not exit in time, killing it", "plugin", p.name)
+	p.cmd.Process.Kill()
+	<-p.done
+}
+
+// Manager 已加载的插件，nil 表示未配置插件目录
+type Manager struct {
+	plugins []*Plugin
+}
+
+// Start 启动插件目录中的所有插件程序，单个插件启动失败时记录日志并跳过；未配置插件目录时返回 nil
+func Start(ctx context.Context, cfg config.PluginConfig) (*Manager, error) {
+	if cfg.Dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(cfg.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		klog.InfoS("Plugin directory does not exist, no plugins loaded", "dir", cfg.Dir)
+		return &Manager{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read plugin directory: %w", err)
+	}
+
+	m := &Manager{}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !isExecutable(info) {
+			continue
+		}
+		p, err := start(ctx, filepath.Join(cfg.Dir, entry.Name()), cfg)
+		if err != nil {
+			klog.ErrorS(err, "Failed to start plugin", "path", entry.Name())
+			continue
+		}
+		klog.InfoS("Plugin loaded", "plugin", p.name, "tools", len(p.tools))
+		m.plugins = append(m.plugins, p)
+	}
+	return m, nil
+}
+
+// isExecutable 是否为可执行的普通文件（Windows 上按 .exe 扩展名判断）
+func isExecutable(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(info.Name()), ".exe")
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
+
+// Plugins 返回已加载的插件
+func (m *Manager) Plugins() []*Plugin {
+	if m == nil {
+		return nil
+	}
+	return m.plugins
+}
+
+// Close 同时停止所有插件
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, p := range m.plugins {
+		wg.Go(p.stop)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,53 @@
+// Package plugin 外部工具插件：第三方以独立的可执行程序提供工具，Agent 启动时从插件目录发现并启动这些程序，
+// 插件提供的工具与 MCP 工具一起注册到工具表中。
+//
+// 协议与 hashicorp/go-plugin 的 net/rpc 模式相同：Agent 在环境变量中带上 magic cookie 启动插件，
+// 插件在本地 unix socket 上提供 net/rpc 服务，并在标准输出的第一行写出握手信息
+// "<协议版本>|unix|<socket 路径>"。插件的标准错误输出写入 Agent 日志，标准输入关闭时插件退出。
+// 插件程序用 Serve 提供以 pkg/tools 定义的工具：
+//
+//	func main() {
+//		tool, err := tools.New("get_weather", "查询城市天气", getWeather)
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		plugin.Serve(tool)
+//	}
+package plugin
+
+import "time"
+
+const (
+	// ProtocolVersion 插件协议版本，握手时版本不一致的插件不会加载
+	ProtocolVersion = 1
+
+	// cookieKey、cookieValue 区分插件是由 Agent 启动还是被直接运行
+	cookieKey   = "AI_AGENT_PLUGIN_MAGIC_COOKIE"
+	cookieValue = "5f0c2a7e9b3d4c18a6e1f8b2d7c9e4a3"
+
+	// serviceName 插件的 net/rpc 服务名
+	serviceName = "Plugin"
+)
+
+// Empty 没有参数的 RPC 请求
+type Empty struct{}
+
+// ToolSpec 插件提供的工具定义
+type ToolSpec struct {
+	Name        string
+	Description string
+	InputSchema []byte // 参数的 JSON Schema
+}
+
+// CallArgs 工具调用请求
+type CallArgs struct {
+	Name     string
+	Args     []byte    // JSON 编码的参数
+	Deadline time.Time // 调用的截止时间，插件以此设置传给工具的 context
+}
+
+// CallReply 工具调用结果，工具返回错误时 Error 不为空
+type CallReply struct {
+	Result string
+	Error  string
+}
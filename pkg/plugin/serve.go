@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+
+	"github.com/champly/ai-agent/pkg/tools"
+)
+
+// Serve 以插件方式提供工具，直到 Agent 关闭插件的标准输入；不是由 Agent 启动时输出提示并以状态码 1 退出
+func Serve(ts ...*tools.Tool) {
+	if err := serve(ts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func serve(ts []*tools.Tool) error {
+	if os.Getenv(cookieKey) != cookieValue {
+		return errors.New("this program is an ai-agent plugin: put it in the plugins.dir directory instead of running it directly")
+	}
+
+	svc := &service{tools: make(map[string]*tools.Tool, len(ts))}
+	for _, t := range ts {
+		if _, ok := svc.tools[t.Name()]; ok {
+			return fmt.Errorf("duplicate tool name: %s", t.Name())
+		}
+		svc.tools[t.Name()] = t
+		schema, err := json.Marshal(t.InputSchema())
+		if err != nil {
+			return fmt.Errorf("tool %s: %w", t.Name(), err)
+		}
+		svc.specs = append(svc.specs, ToolSpec{Name: t.Name(), Description: t.Description(), InputSchema: schema})
+	}
+
+	dir, err := os.MkdirTemp("", "ai-agent-plugin-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	l, err := net.Listen("unix", filepath.Join(dir, "plugin.sock"))
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(serviceName, svc); err != nil {
+		return err
+	}
+	go func() {
+		// 不使用 srv.Accept：退出时关闭监听会让它打印错误日志
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.ServeConn(conn)
+		}
+	}()
+
+	// 握手信息，Agent 读取后连接 socket
+	fmt.Fprintf(os.Stdout, "%d|unix|%s\n", ProtocolVersion, l.Addr())
+
+	// 标准输入关闭（Agent 停止插件或自身退出）时退出
+	_, err = io.Copy(io.Discard, os.Stdin)
+	return err
+}
+
+// service 插件的 RPC 服务
+type service struct {
+	tools map[string]*tools.Tool
+	specs []ToolSpec
+}
+
+// Tools 返回插件提供的工具
+func (s *service) Tools(_ Empty, reply *[]ToolSpec) error {
+	*reply = s.specs
+	return nil
+}
+
+// Call 调用工具，工具返回的错误放在 reply.Error 中，RPC 本身的错误只表示请求无法处理
+func (s *service) Call(args CallArgs, reply *CallReply) error {
+	tool, ok := s.tools[args.Name]
+	if !ok {
+		return fmt.Errorf("tool not found: %s", args.Name)
+	}
+	var m map[string]any
+	if len(args.Args) > 0 {
+		if err := json.Unmarshal(args.Args, &m); err != nil {
+			return fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+
+	ctx := context.Background()
+	if !args.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, args.Deadline)
+		defer cancel()
+	}
+	result, err := tool.Call(ctx, m)
+	if err != nil {
+		reply.Error = err.Error()
+		return nil
+	}
+	reply.Result = result
+	return nil
+}
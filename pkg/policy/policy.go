@@ -0,0 +1,242 @@
+// Package policy 实现工具调用的权限策略：按工具名称、来源、参数、用户和对话匹配 allow/deny 规则
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// ErrDenied 工具调用被策略拒绝
+var ErrDenied = errors.New("denied by policy")
+
+const (
+	// EffectAllow 允许执行
+	EffectAllow = "allow"
+	// EffectDeny 拒绝执行
+	EffectDeny = "deny"
+)
+
+// Request 待评估的工具调用
+type Request struct {
+	Tool           string
+	Source         string
+	User           string
+	ConversationID string
+	Args           map[string]any
+}
+
+// Decision 评估结果
+type Decision struct {
+	Allowed bool
+	Rule    string // 匹配的规则名称，为空表示使用默认决定
+}
+
+// Engine 策略引擎
+type Engine struct {
+	defaultAllow bool
+	rules        []*rule
+}
+
+// rule 编译后的规则
+type rule struct {
+	name          string
+	allow         bool
+	tools         []*pattern
+	sources       []*pattern
+	users         []*pattern
+	conversations []*pattern
+	args          map[string]*pattern
+}
+
+// New 编译策略配置
+func New(cfg config.PolicyConfig) (*Engine, error) {
+	e := &Engine{}
+	switch cfg.Default {
+	case "", EffectAllow:
+		e.defaultAllow = true
+	case EffectDeny:
+	default:
+		return nil, fmt.Errorf("invalid policy default %q", cfg.Default)
+	}
+
+	for i, rc := range cfg.Rules {
+		name := rc.Name
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i)
+		}
+		r := &rule{name: name, args: make(map[string]*pattern)}
+		switch rc.Effect {
+		case EffectAllow:
+			r.allow = true
+		case EffectDeny:
+		default:
+			return nil, fmt.Errorf("policy rule %s: invalid effect %q", name, rc.Effect)
+		}
+
+		var err error
+		if r.tools, err = compileAll(rc.Tools); err != nil {
+			return nil, fmt.Errorf("policy rule %s: %w", name, err)
+		}
+		if r.sources, err = compileAll(rc.Sources); err != nil {
+			return nil, fmt.Errorf("policy rule %s: %w", name, err)
+		}
+		if r.users, err = compileAll(rc.Users); err != nil {
+			return nil, fmt.Errorf("policy rule %s: %w", name, err)
+		}
+		if r.conversations, err = compileAll(rc.Conversations); err != nil {
+			return nil, fmt.Errorf("policy rule %s: %w", name, err)
+		}
+		for arg, expr := range rc.Args {
+			p, err := compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("policy rule %s: arg %s: %w", name, arg, err)
+			}
+			r.args[arg] = p
+		}
+		e.rules = append(e.rules, r)
+	}
+
+	klog.InfoS("Tool policy loaded", "rules", len(e.rules), "default", cfg.Default)
+	return e, nil
+}
+
+// Evaluate 评估工具调用，第一条匹配的规则生效
+func (e *Engine) Evaluate(req Request) Decision {
+	for _, r := range e.rules {
+		if r.matches(req) {
+			return Decision{Allowed: r.allow, Rule: r.name}
+		}
+	}
+	return Decision{Allowed: e.defaultAllow}
+}
+
+// Check 评估并记录决定，被拒绝时返回包装了 ErrDenied 的错误
+func (e *Engine) Check(req Request) error {
+	d := e.Evaluate(req)
+	rule := d.Rule
+	if rule == "" {
+		rule = "<default>"
+	}
+
+	if !d.Allowed {
+		klog.InfoS("Tool call denied by policy", "tool", req.Tool, "source", req.Source,
+			"user", req.User, "conversationID", req.ConversationID, "rule", rule)
+		return fmt.Errorf("%w: tool %s is not allowed (rule %s)", ErrDenied, req.Tool, rule)
+	}
+	klog.V(2).InfoS("Tool call allowed by policy", "tool", req.Tool, "source", req.Source,
+		"user", req.User, "conversationID", req.ConversationID, "rule", rule)
+	return nil
+}
+
+// matches 规则的所有条件都满足时匹配
+func (r *rule) matches(req Request) bool {
+	if !matchAny(r.tools, req.Tool) || !matchAny(r.sources, req.Source) ||
+		!matchAny(r.users, req.User) || !matchAny(r.conversations, req.ConversationID) {
+		return false
+	}
+	for name, p := range r.args {
+		value, ok := req.Args[name]
+		if !ok {
+			// 缺少参数时只有取反模式视为满足
+			if !p.negate {
+				return false
+			}
+			continue
+		}
+		if !p.match(argString(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// argString 将参数值转为字符串，形如绝对路径的值先规范化，避免 /workspace/../etc 绕过
+func argString(v any) string {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	if strings.HasPrefix(s, "/") {
+		return path.Clean(s)
+	}
+	return s
+}
+
+// pattern 通配模式：* 匹配除 / 外任意字符，** 匹配任意字符，! 开头取反
+type pattern struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+// compile 编译通配模式
+func compile(expr string) (*pattern, error) {
+	p := &pattern{}
+	if rest, ok := strings.CutPrefix(expr, "!"); ok {
+		p.negate = true
+		expr = rest
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; c {
+		case '*':
+			if i+1 < len(expr) && expr[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", expr, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// compileAll 编译一组模式
+func compileAll(exprs []string) ([]*pattern, error) {
+	patterns := make([]*pattern, 0, len(exprs))
+	for _, expr := range exprs {
+		p, err := compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// match 匹配单个值
+func (p *pattern) match(s string) bool {
+	return p.re.MatchString(s) != p.negate
+}
+
+// matchAny 任一模式匹配即满足，没有模式时视为满足
+func matchAny(patterns []*pattern, s string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if p.match(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+// Package priority 请求的优先级：交互式聊天优先于批量和后台任务，后台任务优先于知识库导入。
+// 优先级随 context 传递，worker 池和 Ollama 调用排队时按优先级出队
+package priority
+
+import (
+	"context"
+	"fmt"
+)
+
+// Class 优先级，值越小越优先
+type Class int
+
+const (
+	// Interactive 用户在线等待的聊天和工作流请求
+	Interactive Class = iota
+	// Batch 批量聊天、后台任务和 webhook 触发的任务
+	Batch
+	// Background 知识库导入等后台批处理
+	Background
+)
+
+// Classes 优先级的数量
+const Classes = int(Background) + 1
+
+// names 优先级名称
+var names = [Classes]string{"interactive", "batch", "background"}
+
+// String 返回优先级名称
+func (c Class) String() string {
+	if c < 0 || int(c) >= Classes {
+		return fmt.Sprintf("priority(%d)", int(c))
+	}
+	return names[c]
+}
+
+// All 按从高到低的顺序返回所有优先级
+func All() []Class {
+	return []Class{Interactive, Batch, Background}
+}
+
+type contextKey struct{}
+
+// WithClass 设置 context 中请求的优先级；只会降低优先级，ctx 中已是更低的优先级时保持不变
+func WithClass(ctx context.Context, c Class) context.Context {
+	if FromContext(ctx) >= c {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext 返回 context 中请求的优先级，未设置时为 Interactive
+func FromContext(ctx context.Context) Class {
+	c, _ := ctx.Value(contextKey{}).(Class)
+	return c
+}
@@ -0,0 +1,247 @@
+// Package profile 保存每个用户的资料：偏好、常用项目和环境信息。资料由模型从对话中学习，也可以通过 API 编辑，
+// 新对话开始时以简短的上下文注入系统提示，避免用户在每个对话中重复说明
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/cache"
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/secretscan"
+)
+
+var (
+	// ErrDisabled 未启用用户资料
+	ErrDisabled = errors.New("user profiles are not enabled")
+	// ErrInvalid 条目过长、条目数超出限制或包含疑似凭证
+	ErrInvalid = errors.New("invalid profile")
+)
+
+// 单个条目的最大字符数
+const maxItemLength = 200
+
+// Profile 用户资料，每类为若干条简短的陈述
+type Profile struct {
+	// Preferences 回答风格、语言、常用工具等偏好
+	Preferences []string `json:"preferences"`
+	// Projects 经常提到的项目、仓库和服务
+	Projects []string `json:"projects"`
+	// Environment 操作系统、集群、版本等环境信息
+	Environment []string  `json:"environment"`
+	UpdatedAt   time.Time `json:"updated_at,omitzero"`
+}
+
+// sections 返回各类条目，用于统一处理
+func (p *Profile) sections() []*[]string {
+	return []*[]string{&p.Preferences, &p.Projects, &p.Environment}
+}
+
+// Empty 资料是否为空
+func (p *Profile) Empty() bool {
+	return len(p.Preferences) == 0 && len(p.Projects) == 0 && len(p.Environment) == 0
+}
+
+// Update 从对话中学到的变化：Add 中的条目追加到对应类别，Remove 中的条目（不区分大小写）从所有类别删除
+type Update struct {
+	Add    Profile  `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// Store 用户资料存储，nil 表示未启用
+type Store struct {
+	store    cache.Store
+	ttl      time.Duration
+	maxItems int
+	scanner  *secretscan.Scanner
+
+	// mu 串行化本进程内的读-改-写
+	mu sync.Mutex
+}
+
+// New 按配置创建存储，未启用时返回 nil
+func New(cfg config.ProfileConfig, redisCfg config.RedisConfig) (*Store, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	scanner, err := secretscan.New(secretscan.Config{})
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{ttl: cfg.TTL, maxItems: cfg.MaxItems, scanner: scanner}
+	switch cfg.Backend {
+	case "", "memory":
+		s.store = cache.NewMemoryStore(cfg.MaxEntries)
+	case "redis":
+		rs, err := cache.NewRedisStore(redisCfg)
+		if err != nil {
+			return nil, err
+		}
+		s.store = rs
+	default:
+		return nil, fmt.Errorf("unknown profile backend: %s", cfg.Backend)
+	}
+	klog.InfoS("User profiles enabled", "backend", cfg.Backend, "ttl", cfg.TTL)
+	return s, nil
+}
+
+// Get 返回用户的资料，没有资料时返回空资料
+func (s *Store) Get(ctx context.Context, user string) (*Profile, error) {
+	if s == nil {
+		return nil, ErrDisabled
+	}
+	p := &Profile{Preferences: []string{}, Projects: []string{}, Environment: []string{}}
+	data, ok, err := s.store.Get(ctx, profileKey(user))
+	if err != nil || !ok {
+		return p, err
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Put 替换用户的资料。条目去除首尾空白和重复项，条目过长、某类条目超出上限或包含疑似凭证时返回 ErrInvalid
+func (s *Store) Put(ctx context.Context, user string, p *Profile) (*Profile, error) {
+	if s == nil {
+		return nil, ErrDisabled
+	}
+	clean := &Profile{}
+	src, dst := p.sections(), clean.sections()
+	for i := range src {
+		items := normalize(*src[i])
+		if len(items) > s.maxItems {
+			return nil, fmt.Errorf("%w: at most %d items per section", ErrInvalid, s.maxItems)
+		}
+		for _, item := range items {
+			if err := s.check(item); err != nil {
+				return nil, err
+			}
+		}
+		*dst[i] = items
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return clean, s.put(ctx, user, clean)
+}
+
+// Delete 清空用户的资料
+func (s *Store) Delete(ctx context.Context, user string) error {
+	if s == nil {
+		return ErrDisabled
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(ctx, user, &Profile{Preferences: []string{}, Projects: []string{}, Environment: []string{}})
+}
+
+// Apply 合并从对话中学到的变化：先删除 Remove 中的条目，再追加新的条目；
+// 某类条目超出上限时丢弃最早的条目，不合格的条目被忽略。返回资料是否发生变化
+func (s *Store) Apply(ctx context.Context, user string, u Update) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.Get(ctx, user)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	remove := make(map[string]bool, len(u.Remove))
+	for _, item := range normalize(u.Remove) {
+		remove[strings.ToLower(item)] = true
+	}
+	add := u.Add.sections()
+	for i, section := range p.sections() {
+		before := len(*section)
+		*section = slices.DeleteFunc(*section, func(item string) bool { return remove[strings.ToLower(item)] })
+		changed = changed || len(*section) != before
+		for _, item := range normalize(*add[i]) {
+			if s.check(item) != nil || containsFold(*section, item) {
+				continue
+			}
+			*section = append(*section, item)
+			changed = true
+		}
+		if len(*section) > s.maxItems {
+			*section = slices.Delete(*section, 0, len(*section)-s.maxItems)
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, s.put(ctx, user, p)
+}
+
+// check 检查单个条目的长度和疑似凭证
+func (s *Store) check(item string) error {
+	if utf8.RuneCountInString(item) > maxItemLength {
+		return fmt.Errorf("%w: item exceeds %d characters", ErrInvalid, maxItemLength)
+	}
+	if findings := s.scanner.Scan(item); len(findings) > 0 {
+		return fmt.Errorf("%w: item contains a possible secret (%s)", ErrInvalid, findings[0].Detector)
+	}
+	return nil
+}
+
+// put 保存资料并刷新有效期
+func (s *Store) put(ctx context.Context, user string, p *Profile) error {
+	p.UpdatedAt = time.Now()
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(ctx, profileKey(user), data, s.ttl)
+}
+
+// Render 把资料整理为注入系统提示的简短上下文，资料为空时返回空字符串
+func Render(p *Profile) string {
+	if p == nil || p.Empty() {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("关于当前用户的已知信息（来自之前的对话，用户的最新说法优先）：")
+	for _, section := range []struct {
+		label string
+		items []string
+	}{
+		{"偏好", p.Preferences},
+		{"常用项目", p.Projects},
+		{"环境", p.Environment},
+	} {
+		if len(section.items) > 0 {
+			fmt.Fprintf(&b, "\n- %s：%s", section.label, strings.Join(section.items, "；"))
+		}
+	}
+	return b.String()
+}
+
+// normalize 去除条目首尾空白、空条目和重复项（不区分大小写），保持原有顺序
+func normalize(items []string) []string {
+	out := []string{}
+	for _, item := range items {
+		item = strings.Join(strings.Fields(item), " ")
+		if item != "" && !containsFold(out, item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func containsFold(items []string, item string) bool {
+	return slices.ContainsFunc(items, func(s string) bool { return strings.EqualFold(s, item) })
+}
+
+// profileKey 用户资料的存储键
+func profileKey(user string) string {
+	return "profile:" + user
+}
@@ -0,0 +1,117 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// Runtime 渲染时 rag 函数检索知识库的能力，由 Agent 提供，租户限制与普通请求一致
+type Runtime interface {
+	// SearchRAG 检索知识库，返回拼接好的参考资料；collection 为空时检索所有集合，topK <= 0 时使用默认值
+	SearchRAG(ctx context.Context, collection, query string, topK int) (string, error)
+}
+
+// truncatedMarker file 函数读取的内容超过上限时追加的标记
+const truncatedMarker = "\n[truncated]"
+
+// funcs 模板中可用的函数：
+//   - file "path"：读取 file_root 下的文件，超过 max_file_bytes 时截断
+//   - now / date ["layout"]：当前时间 / 按 Go 时间格式格式化的当前时间，默认为 2006-01-02
+//   - env "NAME"：prompts.env 允许的环境变量
+//   - rag "query" ["collection"]：检索知识库，返回参考资料
+//
+// file、env、rag 依赖配置和请求，这里的实现只用于编译模板，渲染时替换为 runtimeFuncs
+var funcs = template.FuncMap{
+	"contains":  strings.Contains,
+	"hasPrefix": strings.HasPrefix,
+	"hasSuffix": strings.HasSuffix,
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"trim":      strings.TrimSpace,
+	"truncate": func(n int, s string) string {
+		if len(s) <= n {
+			return s
+		}
+		return strings.ToValidUTF8(s[:n], "")
+	},
+	"now":  time.Now,
+	"date": date,
+	"file": func(string) (string, error) { return "", errors.New("file is not available") },
+	"env":  func(string) (string, error) { return "", errors.New("env is not available") },
+	"rag":  func(string, ...string) (string, error) { return "", errors.New("rag is not available") },
+}
+
+// date 按 layout 格式化当前时间，默认为 2006-01-02
+func date(layout ...string) string {
+	if len(layout) == 0 {
+		return time.Now().Format(time.DateOnly)
+	}
+	return time.Now().Format(layout[0])
+}
+
+// runtimeFuncs 渲染时使用的 file、env、rag 函数
+func (l *Library) runtimeFuncs(ctx context.Context, rt Runtime) template.FuncMap {
+	return template.FuncMap{
+		"file": l.readFile,
+		"env":  l.env,
+		"rag": func(query string, collection ...string) (string, error) {
+			if rt == nil {
+				return "", errors.New("rag is not available")
+			}
+			var c string
+			if len(collection) > 0 {
+				c = collection[0]
+			}
+			return rt.SearchRAG(ctx, c, query, 0)
+		},
+	}
+}
+
+// readFile 读取 file_root 下的文件，超过 max_file_bytes 时截断并追加标记；不能访问根目录以外的文件
+func (l *Library) readFile(name string) (string, error) {
+	if l.cfg.FileRoot == "" {
+		return "", fmt.Errorf("%w: file requires prompts.file_root", ErrInvalid)
+	}
+	f, err := os.OpenInRoot(l.cfg.FileRoot, name)
+	if err != nil {
+		return "", fmt.Errorf("%w: file %s: %w", ErrInvalid, name, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, int64(l.cfg.MaxFileBytes)+1))
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", name, err)
+	}
+	if len(data) > l.cfg.MaxFileBytes {
+		data = data[:l.cfg.MaxFileBytes]
+		// 去掉末尾被截断的多字节字符
+		for i := 0; i < utf8.UTFMax-1 && len(data) > 0; i++ {
+			if r, _ := utf8.DecodeLastRune(data); r != utf8.RuneError {
+				break
+			}
+			data = data[:len(data)-1]
+		}
+		return string(data) + truncatedMarker, nil
+	}
+	return string(data), nil
+}
+
+// env 读取 prompts.env 允许的环境变量，未设置时为空字符串
+func (l *Library) env(name string) (string, error) {
+	if !slices.ContainsFunc(l.cfg.Env, func(pattern string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}) {
+		return "", fmt.Errorf("%w: environment variable %s is not allowed", ErrInvalid, name)
+	}
+	return os.Getenv(name), nil
+}
@@ -0,0 +1,154 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// version 目录及其中模板文件的最新修改时间、文件数和总大小，任一变化都需要重新加载
+type version struct {
+	modTime time.Time
+	files   int
+	size    int64
+}
+
+// dirVersion 返回目录当前的版本，增删和重命名文件会更新目录自身的修改时间
+func dirVersion(dir string) version {
+	var v version
+	if info, err := os.Stat(dir); err == nil {
+		v.modTime = info.ModTime()
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return v
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		v.files++
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		v.size += info.Size()
+		if info.ModTime().After(v.modTime) {
+			v.modTime = info.ModTime()
+		}
+	}
+	return v
+}
+
+// Library 目录中的提示模板，nil 表示未配置。距上次检查超过 reload_interval 后，
+// 在下次访问时检查目录是否变化并重新加载；加载失败时继续使用之前的模板
+type Library struct {
+	cfg config.PromptConfig
+
+	mu       sync.Mutex
+	set      *Set
+	version  version   // 已加载（或加载失败）的目录版本，同一版本不重复加载
+	checked  time.Time // 上次检查时间
+	loadedAt time.Time
+	lastErr  error // 最近一次重新加载的错误，加载成功后清除
+}
+
+// newLibrary 创建模板库并立即加载一次，首次加载失败时返回错误
+func newLibrary(cfg config.PromptConfig) (*Library, error) {
+	l := &Library{cfg: cfg, version: dirVersion(cfg.Dir)}
+	set, err := loadDir(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	l.set, l.checked, l.loadedAt = set, time.Now(), time.Now()
+	klog.InfoS("Prompt templates loaded", "dir", cfg.Dir, "count", len(set.templates))
+	return l, nil
+}
+
+// current 返回当前的模板，目录有变化时重新加载
+func (l *Library) current() *Set {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if time.Since(l.checked) < l.cfg.ReloadInterval {
+		return l.set
+	}
+	l.checked = time.Now()
+	v := dirVersion(l.cfg.Dir)
+	if v == l.version {
+		return l.set
+	}
+	l.version = v
+
+	set, err := loadDir(l.cfg.Dir)
+	if err != nil {
+		l.lastErr = err
+		klog.ErrorS(err, "Failed to reload prompt templates, keeping the previous ones", "dir", l.cfg.Dir)
+		return l.set
+	}
+	l.set, l.loadedAt, l.lastErr = set, time.Now(), nil
+	klog.InfoS("Prompt templates reloaded", "dir", l.cfg.Dir, "count", len(set.templates))
+	return l.set
+}
+
+// Status 模板库的加载状态
+type Status struct {
+	Dir      string    `json:"dir"`
+	LoadedAt time.Time `json:"loaded_at"`
+	Error    string    `json:"error,omitempty"` // 最近一次重新加载失败的原因，此时仍使用之前的模板
+}
+
+// Status 返回模板库的加载状态，未配置时返回 nil
+func (l *Library) Status() *Status {
+	if l == nil {
+		return nil
+	}
+	l.current()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := &Status{Dir: l.cfg.Dir, LoadedAt: l.loadedAt}
+	if l.lastErr != nil {
+		s.Error = l.lastErr.Error()
+	}
+	return s
+}
+
+// List 返回所有模板定义，按名称排序
+func (l *Library) List() []*Template {
+	if l == nil {
+		return nil
+	}
+	return l.current().List()
+}
+
+// Get 按名称查找模板
+func (l *Library) Get(name string) (*Template, error) {
+	if l == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return l.current().Get(name)
+}
+
+// Check 校验模板存在且变量完整，不执行模板（不会读取文件或检索知识库）
+func (l *Library) Check(name string, vars map[string]string) (*Template, error) {
+	if l == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return l.current().Check(name, vars)
+}
+
+// Render 按变量渲染模板，见 Set.Render。rag 函数通过 rt 在 ctx 下检索知识库，rt 为 nil 时不可用
+func (l *Library) Render(ctx context.Context, rt Runtime, name string, vars map[string]string) (*Rendered, error) {
+	if l == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return l.current().Render(name, vars, l.runtimeFuncs(ctx, rt))
+}
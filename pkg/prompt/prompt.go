@@ -0,0 +1,339 @@
+// Package prompt 提示模板：按名称调用的 text/template 模板，声明变量及默认值，可以引用其他模板，
+// 从目录加载，文件变化后自动重新加载。模板可以定义用户消息、系统提示（人设）或两者，
+// 调用方只需传入模板名和变量即可得到结构化的提示，不必自行拼接消息
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+var (
+	// ErrNotFound 模板不存在
+	ErrNotFound = errors.New("prompt template not found")
+	// ErrInvalid 缺少必填变量、变量未定义或模板与请求的用法不符
+	ErrInvalid = errors.New("invalid prompt")
+)
+
+// systemSuffix 系统提示在模板集合中的名称后缀
+const systemSuffix = ":system"
+
+// namePattern 变量名需能在模板中以 .<name> 引用
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Variable 模板变量
+type Variable struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Required    bool   `yaml:"required,omitempty" json:"required,omitempty"`
+	Default     string `yaml:"default,omitempty" json:"default,omitempty"`
+}
+
+// Template 提示模板定义。Template 和 System 为 text/template 模板，以 .<name> 引用变量，
+// 以 {{template "<name>" .}} 引用其他模板的 Template，被引用模板声明的变量和默认值同样生效，
+// 可用的函数见 funcs。只定义 System 的模板为人设，调用时用户消息由请求提供
+type Template struct {
+	Name        string     `yaml:"name" json:"name"`
+	Description string     `yaml:"description,omitempty" json:"description,omitempty"`
+	Variables   []Variable `yaml:"variables,omitempty" json:"variables,omitempty"`
+	System      string     `yaml:"system,omitempty" json:"system,omitempty"`
+	Template    string     `yaml:"template,omitempty" json:"template,omitempty"`
+
+	includes []string // 直接或间接引用的模板，按引用顺序排列
+}
+
+// Persona 是否为人设（只定义系统提示）
+func (t *Template) Persona() bool {
+	return t.Template == ""
+}
+
+// Parse 解析并校验模板定义，模板在加入 Set 时编译
+func Parse(data []byte) (*Template, error) {
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parse prompt: %w", err)
+	}
+	if t.Name == "" || strings.Contains(t.Name, systemSuffix) {
+		return nil, fmt.Errorf("invalid prompt name %q", t.Name)
+	}
+	if strings.TrimSpace(t.Template) == "" && strings.TrimSpace(t.System) == "" {
+		return nil, fmt.Errorf("prompt %s: template or system is required", t.Name)
+	}
+	seen := make(map[string]bool, len(t.Variables))
+	for _, v := range t.Variables {
+		if !namePattern.MatchString(v.Name) {
+			return nil, fmt.Errorf("prompt %s: invalid variable name %q", t.Name, v.Name)
+		}
+		if seen[v.Name] {
+			return nil, fmt.Errorf("prompt %s: duplicate variable %q", t.Name, v.Name)
+		}
+		seen[v.Name] = true
+	}
+	return &t, nil
+}
+
+// Rendered 渲染结果
+type Rendered struct {
+	System string `json:"system,omitempty"` // 系统提示，模板未定义 system 时为空
+	Prompt string `json:"prompt,omitempty"` // 用户消息，人设模板为空
+}
+
+// Set 一组编译好的模板，创建后不再修改
+type Set struct {
+	templates map[string]*Template
+	tmpl      *template.Template // 所有模板编译在同一个集合中，可以互相引用
+}
+
+// loadDir 加载 dir 下所有 .yaml/.yml 模板定义，目录不存在时返回空集合
+func loadDir(dir string) (*Set, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return New()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read prompt dir: %w", err)
+	}
+
+	var templates []*Template
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read prompt %s: %w", path, err)
+		}
+		t, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		templates = append(templates, t)
+	}
+	return New(templates...)
+}
+
+// New 编译模板定义，校验名称不重复、引用的模板都存在
+func New(templates ...*Template) (*Set, error) {
+	s := &Set{
+		templates: make(map[string]*Template, len(templates)),
+		tmpl:      template.New("").Funcs(funcs).Option("missingkey=zero"),
+	}
+	for _, t := range templates {
+		if _, ok := s.templates[t.Name]; ok {
+			return nil, fmt.Errorf("duplicate prompt name %q", t.Name)
+		}
+		for _, part := range t.parts() {
+			if _, err := s.tmpl.New(part.name).Parse(part.text); err != nil {
+				return nil, fmt.Errorf("prompt %s: %w", t.Name, err)
+			}
+		}
+		s.templates[t.Name] = t
+	}
+
+	for _, t := range s.templates {
+		for _, part := range t.parts() {
+			// {{define}} 块与模板同名时会替换该模板
+			if tmpl := s.tmpl.Lookup(part.name); tmpl == nil || tmpl.Tree == nil || tmpl.Tree.ParseName != part.name {
+				return nil, fmt.Errorf("prompt %s: redefined by another template", t.Name)
+			}
+		}
+		includes, err := s.includes(t)
+		if err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", t.Name, err)
+		}
+		t.includes = includes
+	}
+	return s, nil
+}
+
+// part 模板中需要编译的部分
+type part struct {
+	name string
+	text string
+}
+
+// parts 返回模板定义的用户消息和系统提示，名称分别为模板名和模板名加 :system
+func (t *Template) parts() []part {
+	var parts []part
+	if t.Template != "" {
+		parts = append(parts, part{t.Name, t.Template})
+	}
+	if t.System != "" {
+		parts = append(parts, part{t.Name + systemSuffix, t.System})
+	}
+	return parts
+}
+
+// includes 返回模板直接或间接引用的模板名（不含自身），引用不存在的模板时返回错误
+func (s *Set) includes(t *Template) ([]string, error) {
+	var result []string
+	seen := map[string]bool{}
+	var queue []string
+	for _, part := range t.parts() {
+		seen[part.name] = true
+		queue = append(queue, part.name)
+	}
+	for len(queue) > 0 {
+		tmpl := s.tmpl.Lookup(queue[0])
+		queue = queue[1:]
+		for _, ref := range references(tmpl.Tree.Root) {
+			if seen[ref] {
+				continue
+			}
+			if t := s.tmpl.Lookup(ref); t == nil || t.Tree == nil {
+				return nil, fmt.Errorf("template %q is not defined", ref)
+			}
+			seen[ref] = true
+			result = append(result, ref)
+			queue = append(queue, ref)
+		}
+	}
+	return result, nil
+}
+
+// references 返回语法树中 {{template}} 引用的模板名
+func references(node parse.Node) []string {
+	var names []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			names = append(names, references(child)...)
+		}
+	case *parse.TemplateNode:
+		names = append(names, n.Name)
+	case *parse.IfNode:
+		names = append(names, references(n.List)...)
+		names = append(names, references(n.ElseList)...)
+	case *parse.RangeNode:
+		names = append(names, references(n.List)...)
+		names = append(names, references(n.ElseList)...)
+	case *parse.WithNode:
+		names = append(names, references(n.List)...)
+		names = append(names, references(n.ElseList)...)
+	}
+	return names
+}
+
+// List 返回所有模板定义，按名称排序
+func (s *Set) List() []*Template {
+	templates := make([]*Template, 0, len(s.templates))
+	for _, t := range s.templates {
+		templates = append(templates, t)
+	}
+	slices.SortFunc(templates, func(a, b *Template) int { return strings.Compare(a.Name, b.Name) })
+	return templates
+}
+
+// Get 按名称查找模板
+func (s *Set) Get(name string) (*Template, error) {
+	if t, ok := s.templates[name]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Check 校验模板存在且变量完整，不执行模板
+func (s *Set) Check(name string, vars map[string]string) (*Template, error) {
+	t, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.resolve(t, vars); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Render 按变量渲染模板，去掉首尾空白。未传入的变量使用默认值，缺少必填变量或传入未声明的变量时返回 ErrInvalid；
+// fm 替换同名的模板函数，为 nil 时使用默认实现
+func (s *Set) Render(name string, vars map[string]string, fm template.FuncMap) (*Rendered, error) {
+	t, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	values, err := s.resolve(t, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := s.tmpl
+	if fm != nil {
+		// Set 创建后不再修改，在副本上替换函数，并发渲染互不影响
+		if tmpl, err = s.tmpl.Clone(); err != nil {
+			return nil, fmt.Errorf("render prompt %s: %w", name, err)
+		}
+		tmpl.Funcs(fm)
+	}
+
+	var r Rendered
+	for _, part := range t.parts() {
+		var b strings.Builder
+		if err := tmpl.ExecuteTemplate(&b, part.name, values); err != nil {
+			return nil, fmt.Errorf("render prompt %s: %w", name, err)
+		}
+		if part.name == name {
+			r.Prompt = strings.TrimSpace(b.String())
+		} else {
+			r.System = strings.TrimSpace(b.String())
+		}
+	}
+	return &r, nil
+}
+
+// resolve 合并模板及其引用的模板声明的变量，同名变量以先声明的为准
+func (s *Set) resolve(t *Template, vars map[string]string) (map[string]string, error) {
+	values := make(map[string]string)
+	declared := make(map[string]bool)
+	for _, name := range append([]string{t.Name}, t.includes...) {
+		// 引用的可能是 {{define}} 块而不是模板定义
+		def, ok := s.templates[strings.TrimSuffix(name, systemSuffix)]
+		if !ok {
+			continue
+		}
+		for _, v := range def.Variables {
+			if declared[v.Name] {
+				continue
+			}
+			declared[v.Name] = true
+			value, ok := vars[v.Name]
+			switch {
+			case ok:
+				values[v.Name] = value
+			case v.Required:
+				return nil, fmt.Errorf("%w: %s: variable %s is required", ErrInvalid, t.Name, v.Name)
+			default:
+				values[v.Name] = v.Default
+			}
+		}
+	}
+	for name := range vars {
+		if !declared[name] {
+			return nil, fmt.Errorf("%w: %s: unknown variable %s", ErrInvalid, t.Name, name)
+		}
+	}
+	return values, nil
+}
+
+// Load 加载 cfg.Dir 下的模板并在文件变化后自动重新加载；未配置目录时返回 nil
+func Load(cfg config.PromptConfig) (*Library, error) {
+	if cfg.Dir == "" {
+		return nil, nil
+	}
+	return newLibrary(cfg)
+}
@@ -0,0 +1,148 @@
+package promtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// queryData 查询结果
+type queryData struct {
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// sample 单个采样点：[unix 秒, "值"]
+type sample [2]any
+
+// series 单条时间序列
+type series struct {
+	Metric map[string]string `json:"metric"`
+	Value  sample            `json:"value"`  // vector
+	Values []sample          `json:"values"` // matrix
+}
+
+// formatResult 将查询结果格式化为紧凑的表格
+func formatResult(data json.RawMessage) (string, error) {
+	var qd queryData
+	if err := json.Unmarshal(data, &qd); err != nil {
+		return "", fmt.Errorf("decode query data: %w", err)
+	}
+
+	switch qd.ResultType {
+	case "scalar", "string":
+		var s sample
+		if err := json.Unmarshal(qd.Result, &s); err != nil {
+			return "", fmt.Errorf("decode %s: %w", qd.ResultType, err)
+		}
+		return fmt.Sprintf("%s @ %s", sampleValue(s), sampleTime(s)), nil
+	case "vector", "matrix":
+	default:
+		return "", fmt.Errorf("unsupported result type %q", qd.ResultType)
+	}
+
+	var list []series
+	if err := json.Unmarshal(qd.Result, &list); err != nil {
+		return "", fmt.Errorf("decode %s: %w", qd.ResultType, err)
+	}
+	if len(list) == 0 {
+		return "<no data>", nil
+	}
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	if qd.ResultType == "vector" {
+		fmt.Fprintln(w, "SERIES\tVALUE")
+		for _, s := range head(list) {
+			fmt.Fprintf(w, "%s\t%s\n", metricName(s.Metric), sampleValue(s.Value))
+		}
+	} else {
+		fmt.Fprintln(w, "SERIES\tMIN\tAVG\tMAX\tLAST\tPOINTS")
+		for _, s := range head(list) {
+			minV, avgV, maxV, last := summarize(s.Values)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", metricName(s.Metric),
+				formatFloat(minV), formatFloat(avgV), formatFloat(maxV), formatFloat(last), len(s.Values))
+		}
+	}
+	w.Flush()
+
+	if len(list) > maxSeries {
+		fmt.Fprintf(&b, "... %d more series omitted, refine the query with label filters or aggregation\n", len(list)-maxSeries)
+	}
+	return b.String(), nil
+}
+
+// head 返回最多 maxSeries 条序列
+func head(list []series) []series {
+	if len(list) > maxSeries {
+		return list[:maxSeries]
+	}
+	return list
+}
+
+// metricName 以 PromQL 风格显示序列标签
+func metricName(metric map[string]string) string {
+	name := metric["__name__"]
+	keys := make([]string, 0, len(metric))
+	for k := range metric {
+		if k != "__name__" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		if name == "" {
+			return "{}"
+		}
+		return name
+	}
+
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%q", k, metric[k])
+	}
+	return name + "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// summarize 计算序列的最小、平均、最大和最新值（忽略 NaN）
+func summarize(values []sample) (minV, avgV, maxV, last float64) {
+	minV, maxV = math.Inf(1), math.Inf(-1)
+	var sum float64
+	var n int
+	for _, s := range values {
+		v, err := strconv.ParseFloat(sampleValue(s), 64)
+		if err != nil || math.IsNaN(v) {
+			continue
+		}
+		minV, maxV = min(minV, v), max(maxV, v)
+		sum += v
+		last = v
+		n++
+	}
+	if n == 0 {
+		return math.NaN(), math.NaN(), math.NaN(), math.NaN()
+	}
+	return minV, sum / float64(n), maxV, last
+}
+
+// sampleValue 返回采样值字符串
+func sampleValue(s sample) string {
+	v, _ := s[1].(string)
+	return v
+}
+
+// sampleTime 返回采样时间
+func sampleTime(s sample) string {
+	ts, _ := s[0].(float64)
+	return time.Unix(0, int64(ts*float64(time.Second))).UTC().Format(time.RFC3339)
+}
+
+// formatFloat 以紧凑格式输出数值
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
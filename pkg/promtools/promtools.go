@@ -0,0 +1,214 @@
+// Package promtools 提供 Prometheus 查询 MCP 工具（PromQL 即时查询与范围查询）
+package promtools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/egress"
+)
+
+const (
+	// requestTimeout 单次查询超时
+	requestTimeout = 30 * time.Second
+	// maxSeries 最多返回的序列数
+	maxSeries = 50
+	// maxResponseBytes 查询响应的最大字节数
+	maxResponseBytes = 16 << 20
+	// defaultRangePoints 未指定 step 时范围查询的目标采样点数
+	defaultRangePoints = 60
+)
+
+// Config Prometheus 工具配置
+type Config struct {
+	URL         string         // Prometheus 地址，如 http://prometheus:9090
+	BearerToken string         // 可选的 Bearer Token
+	Egress      *egress.Policy // 出站策略，为空时不限制
+}
+
+// Toolset Prometheus 工具集
+type Toolset struct {
+	cfg    Config
+	client *http.Client
+}
+
+// New 创建 Prometheus 工具集
+func New(cfg Config) (*Toolset, error) {
+	if _, err := url.ParseRequestURI(cfg.URL); err != nil {
+		return nil, fmt.Errorf("invalid prometheus url: %w", err)
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+
+	client := &http.Client{Timeout: requestTimeout}
+	if cfg.Egress != nil {
+		client = cfg.Egress.Client(requestTimeout)
+	}
+
+	klog.InfoS("Prometheus toolset created", "url", cfg.URL)
+	return &Toolset{
+		cfg:    cfg,
+		client: client,
+	}, nil
+}
+
+// QueryInput query_prometheus 的输入
+type QueryInput struct {
+	Query string `json:"query" jsonschema:"PromQL 表达式，如 histogram_quantile(0.99, sum(rate(http_request_duration_seconds_bucket[5m])) by (le))"`
+	Time  string `json:"time,omitempty" jsonschema:"即时查询的时间点（RFC3339、Unix 秒或相对时间如 -1h，默认当前）"`
+	Start string `json:"start,omitempty" jsonschema:"范围查询开始时间（RFC3339、Unix 秒或相对时间如 -1h）；设置后执行范围查询"`
+	End   string `json:"end,omitempty" jsonschema:"范围查询结束时间（默认当前）"`
+	Step  string `json:"step,omitempty" jsonschema:"范围查询步长，如 30s、5m（默认自动）"`
+}
+
+// Output 工具输出
+type Output struct {
+	Output string `json:"output" jsonschema:"查询结果表格"`
+}
+
+// Register 将工具注册到 MCP Server
+func (t *Toolset) Register(server *mcp.Server) {
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "query_prometheus",
+		Description: "执行 PromQL 查询（即时查询或设置 start 后的范围查询），返回指标的表格结果；范围查询按序列汇总 min/avg/max/last",
+	}, t.handleQuery)
+}
+
+// handleQuery 执行 PromQL 查询
+func (t *Toolset) handleQuery(ctx context.Context, req *mcp.CallToolRequest, input QueryInput) (*mcp.CallToolResult, Output, error) {
+	klog.InfoS("MCP tool called: query_prometheus", "query", input.Query, "start", input.Start, "end", input.End)
+
+	if strings.TrimSpace(input.Query) == "" {
+		return nil, Output{}, fmt.Errorf("query is required")
+	}
+
+	now := time.Now()
+	params := url.Values{"query": {input.Query}}
+	endpoint := "/api/v1/query"
+
+	if input.Start != "" {
+		start, err := parseTime(input.Start, now)
+		if err != nil {
+			return nil, Output{}, fmt.Errorf("invalid start: %w", err)
+		}
+		end := now
+		if input.End != "" {
+			if end, err = parseTime(input.End, now); err != nil {
+				return nil, Output{}, fmt.Errorf("invalid end: %w", err)
+			}
+		}
+		if !end.After(start) {
+			return nil, Output{}, fmt.Errorf("end must be after start")
+		}
+
+		step := end.Sub(start) / defaultRangePoints
+		if input.Step != "" {
+			if step, err = time.ParseDuration(input.Step); err != nil {
+				return nil, Output{}, fmt.Errorf("invalid step: %w", err)
+			}
+		}
+		step = max(step, time.Second)
+
+		endpoint = "/api/v1/query_range"
+		params.Set("start", formatTime(start))
+		params.Set("end", formatTime(end))
+		params.Set("step", fmt.Sprintf("%gs", step.Seconds()))
+	} else if input.Time != "" {
+		ts, err := parseTime(input.Time, now)
+		if err != nil {
+			return nil, Output{}, fmt.Errorf("invalid time: %w", err)
+		}
+		params.Set("time", formatTime(ts))
+	}
+
+	data, err := t.query(ctx, endpoint, params)
+	if err != nil {
+		return nil, Output{}, err
+	}
+
+	out, err := formatResult(data)
+	if err != nil {
+		return nil, Output{}, err
+	}
+	return nil, Output{Output: out}, nil
+}
+
+// apiResponse Prometheus HTTP API 响应
+type apiResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+	Warnings  []string        `json:"warnings"`
+}
+
+// query 调用 Prometheus HTTP API，返回 data 字段
+func (t *Toolset) query(ctx context.Context, endpoint string, params url.Values) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.URL+endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if t.cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+t.cfg.BearerToken)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	var result apiResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("decode response (status %s): %w", resp.Status, err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("prometheus %s: %s", result.ErrorType, result.Error)
+	}
+	for _, w := range result.Warnings {
+		klog.InfoS("Prometheus query warning", "warning", w)
+	}
+	return result.Data, nil
+}
+
+// parseTime 解析 RFC3339、Unix 秒或相对时间（如 -1h、now）
+func parseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "now" {
+		return now, nil
+	}
+	if strings.HasPrefix(s, "-") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	var sec float64
+	if _, err := fmt.Sscanf(s, "%g", &sec); err != nil {
+		return time.Time{}, fmt.Errorf("unsupported time format %q", s)
+	}
+	return time.Unix(0, int64(sec*float64(time.Second))), nil
+}
+
+// formatTime 格式化为 Prometheus API 接受的 Unix 秒
+func formatTime(t time.Time) string {
+	return fmt.Sprintf("%.3f", float64(t.UnixMilli())/1000)
+}
@@ -0,0 +1,301 @@
+// Package quota 按用户身份统计请求数、token 数和工具调用次数，并执行每日、每月预算
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+var (
+	// ErrExceeded 用量超出配额，具体信息见 ExceededError
+	ErrExceeded = errors.New("quota exceeded")
+	// ErrDisabled 未启用用量统计
+	ErrDisabled = errors.New("quota is not enabled")
+)
+
+const (
+	// PeriodDaily 自然日（UTC）
+	PeriodDaily = "daily"
+	// PeriodMonthly 自然月（UTC）
+	PeriodMonthly = "monthly"
+
+	// MetricRequests 聊天请求数
+	MetricRequests = "requests"
+	// MetricTokens 模型 token 数
+	MetricTokens = "tokens"
+	// MetricToolCalls 工具调用次数
+	MetricToolCalls = "tool_calls"
+)
+
+// Usage 用量，作为限额时 0 表示不限制
+type Usage struct {
+	Requests  int64 `json:"requests"`
+	Tokens    int64 `json:"tokens"`
+	ToolCalls int64 `json:"tool_calls"`
+}
+
+// get 返回指定指标的值
+func (u Usage) get(metric string) int64 {
+	switch metric {
+	case MetricRequests:
+		return u.Requests
+	case MetricTokens:
+		return u.Tokens
+	case MetricToolCalls:
+		return u.ToolCalls
+	}
+	return 0
+}
+
+// add 返回累加 delta 后的用量
+func (u Usage) add(delta Usage) Usage {
+	return Usage{Requests: u.Requests + delta.Requests, Tokens: u.Tokens + delta.Tokens, ToolCalls: u.ToolCalls + delta.ToolCalls}
+}
+
+// negate 返回相反的用量，用于撤销累加
+func (u Usage) negate() Usage {
+	return Usage{Requests: -u.Requests, Tokens: -u.Tokens, ToolCalls: -u.ToolCalls}
+}
+
+// exceeded 返回 metrics 中第一个达到限额的指标，都未达到时返回空
+func (u Usage) exceeded(limit Usage, metrics []string) string {
+	for _, metric := range metrics {
+		if l := limit.get(metric); l > 0 && u.get(metric) >= l {
+			return metric
+		}
+	}
+	return ""
+}
+
+// ExceededError 超出配额的详细信息，errors.Is(err, ErrExceeded) 成立
+type ExceededError struct {
+	User    string    `json:"user"`
+	Period  string    `json:"period"`
+	Metric  string    `json:"metric"`
+	Limit   int64     `json:"limit"`
+	Used    int64     `json:"used"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+// Error 实现 error
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s %s quota exceeded for user %s: used %d of %d, resets at %s",
+		e.Period, e.Metric, e.User, e.Used, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// Is 使 errors.Is(err, ErrExceeded) 成立
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrExceeded
+}
+
+// PeriodUsage 一个统计周期内的用量
+type PeriodUsage struct {
+	Period  string    `json:"period"`
+	Start   time.Time `json:"start"`
+	ResetAt time.Time `json:"reset_at"`
+	Used    Usage     `json:"used"`
+	Limit   Usage     `json:"limit"`
+}
+
+// Report 用户的用量报告
+type Report struct {
+	User    string        `json:"user"`
+	Periods []PeriodUsage `json:"periods"`
+}
+
+// Store 用量存储，计数在 expireAt 之后可以丢弃
+type Store interface {
+	Add(ctx context.Context, key string, delta Usage, expireAt time.Time) error
+	Get(ctx context.Context, key string) (Usage, error)
+	// Charge 原子地检查并累加：累加前 metrics 中有指标达到 limit 时不累加，返回 false；
+	// 返回的用量为累加前的值
+	Charge(ctx context.Context, key string, delta, limit Usage, metrics []string, expireAt time.Time) (Usage, bool, error)
+}
+
+// Manager 配额管理，nil 表示不统计也不限制。用户为空（进程内可信调用）时同样不统计
+type Manager struct {
+	cfg   config.QuotaConfig
+	store Store
+	now   func() time.Time
+}
+
+// New 创建配额管理；未启用时返回 nil
+func New(cfg config.QuotaConfig, redisCfg config.RedisConfig) (*Manager, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	m := &Manager{cfg: cfg, now: time.Now}
+	switch cfg.Backend {
+	case "", "memory":
+		m.store = NewMemoryStore()
+	case "redis":
+		s, err := NewRedisStore(redisCfg)
+		if err != nil {
+			return nil, err
+		}
+		m.store = s
+	default:
+		return nil, fmt.Errorf("unknown quota backend: %s", cfg.Backend)
+	}
+
+	klog.InfoS("Usage quota enabled", "backend", cfg.Backend, "overrides", len(cfg.Overrides))
+	return m, nil
+}
+
+// Check 检查用户指定指标在各周期是否仍有余量，用尽时返回 *ExceededError。
+// 读取用量失败时记录日志并放行，避免存储故障导致服务不可用
+func (m *Manager) Check(ctx context.Context, user string, metrics ...string) error {
+	if m == nil || user == "" {
+		return nil
+	}
+
+	for _, p := range m.periods(user) {
+		if p.Limit == (Usage{}) {
+			continue
+		}
+		used, err := m.store.Get(ctx, p.key)
+		if err != nil {
+			klog.ErrorS(err, "Failed to read usage", "user", user, "period", p.Period)
+			continue
+		}
+		for _, metric := range metrics {
+			limit := p.Limit.get(metric)
+			if limit > 0 && used.get(metric) >= limit {
+				return &ExceededError{
+					User:    user,
+					Period:  p.Period,
+					Metric:  metric,
+					Limit:   limit,
+					Used:    used.get(metric),
+					ResetAt: p.ResetAt,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Charge 在各周期中原子地检查 metrics 是否仍有余量并累加 delta，并发请求不会同时通过检查而超出限额。
+// 某个周期已用尽时不计入任何周期并返回 *ExceededError；读写用量失败时记录日志并放行
+func (m *Manager) Charge(ctx context.Context, user string, delta Usage, metrics ...string) error {
+	if m == nil || user == "" {
+		return nil
+	}
+
+	var charged []period
+	for _, p := range m.periods(user) {
+		used, ok, err := m.store.Charge(ctx, p.key, delta, p.Limit, metrics, p.ResetAt)
+		if err != nil {
+			klog.ErrorS(err, "Failed to charge usage", "user", user, "period", p.Period)
+			continue
+		}
+		if ok {
+			charged = append(charged, p)
+			continue
+		}
+
+		// 撤销已计入其他周期的用量
+		for _, c := range charged {
+			if err := m.store.Add(ctx, c.key, delta.negate(), c.ResetAt); err != nil {
+				klog.ErrorS(err, "Failed to revert usage", "user", user, "period", c.Period)
+			}
+		}
+		metric := used.exceeded(p.Limit, metrics)
+		return &ExceededError{
+			User:    user,
+			Period:  p.Period,
+			Metric:  metric,
+			Limit:   p.Limit.get(metric),
+			Used:    used.get(metric),
+			ResetAt: p.ResetAt,
+		}
+	}
+	return nil
+}
+
+// Refund 退还 Charge 计入的用量，用于计入之后才发现无法处理的请求
+func (m *Manager) Refund(ctx context.Context, user string, delta Usage) {
+	m.Add(ctx, user, delta.negate())
+}
+
+// Add 累加用户用量，写入失败时只记录日志
+func (m *Manager) Add(ctx context.Context, user string, delta Usage) {
+	if m == nil || user == "" || delta == (Usage{}) {
+		return
+	}
+
+	for _, p := range m.periods(user) {
+		if err := m.store.Add(ctx, p.key, delta, p.ResetAt); err != nil {
+			klog.ErrorS(err, "Failed to record usage", "user", user, "period", p.Period)
+		}
+	}
+}
+
+// Report 返回用户当前周期的用量和限额
+func (m *Manager) Report(ctx context.Context, user string) (*Report, error) {
+	if m == nil {
+		return nil, ErrDisabled
+	}
+
+	report := &Report{User: user}
+	for _, p := range m.periods(user) {
+		used, err := m.store.Get(ctx, p.key)
+		if err != nil {
+			return nil, fmt.Errorf("read %s usage: %w", p.Period, err)
+		}
+		p.Used = used
+		report.Periods = append(report.Periods, p.PeriodUsage)
+	}
+	return report, nil
+}
+
+// period 统计周期及其存储键
+type period struct {
+	PeriodUsage
+	key string
+}
+
+// periods 返回用户当前所处的每日、每月周期及对应限额
+func (m *Manager) periods(user string) []period {
+	daily, monthly := m.limits(user)
+	now := m.now().UTC()
+
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return []period{
+		{
+			PeriodUsage: PeriodUsage{Period: PeriodDaily, Start: dayStart, ResetAt: dayStart.AddDate(0, 0, 1), Limit: daily},
+			key:         user + ":" + PeriodDaily + ":" + dayStart.Format("20060102"),
+		},
+		{
+			PeriodUsage: PeriodUsage{Period: PeriodMonthly, Start: monthStart, ResetAt: monthStart.AddDate(0, 1, 0), Limit: monthly},
+			key:         user + ":" + PeriodMonthly + ":" + monthStart.Format("200601"),
+		},
+	}
+}
+
+// limits 返回用户的每日、每月限额，第一条匹配的覆盖规则生效
+func (m *Manager) limits(user string) (daily, monthly Usage) {
+	daily, monthly = toUsage(m.cfg.Daily), toUsage(m.cfg.Monthly)
+	for _, o := range m.cfg.Overrides {
+		for _, pattern := range o.Users {
+			if ok, _ := path.Match(pattern, user); ok {
+				return toUsage(o.Daily), toUsage(o.Monthly)
+			}
+		}
+	}
+	return daily, monthly
+}
+
+// toUsage 将配置中的限额转换为 Usage
+func toUsage(l config.QuotaLimits) Usage {
+	return Usage{Requests: l.Requests, Tokens: l.Tokens, ToolCalls: l.ToolCalls}
+}
@@ -0,0 +1,78 @@
+package quota
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+func TestChargeConcurrent(t *testing.T) {
+	m, err := New(config.QuotaConfig{Enabled: true, Daily: config.QuotaLimits{Requests: 10}}, config.RedisConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var allowed, exceeded atomic.Int32
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Go(func() {
+			err := m.Charge(t.Context(), "alice", Usage{Requests: 1}, MetricRequests)
+			switch {
+			case err == nil:
+				allowed.Add(1)
+			case errors.Is(err, ErrExceeded):
+				exceeded.Add(1)
+			default:
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if n := allowed.Load(); n != 10 {
+		t.Fatalf("allowed %d requests, want 10", n)
+	}
+	report, err := m.Report(t.Context(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range report.Periods {
+		if p.Used.Requests != 10 {
+			t.Fatalf("%s usage is %d, want 10", p.Period, p.Used.Requests)
+		}
+	}
+}
+
+func TestChargeRevertsOtherPeriods(t *testing.T) {
+	m, err := New(config.QuotaConfig{
+		Enabled: true,
+		Daily:   config.QuotaLimits{Requests: 5},
+		Monthly: config.QuotaLimits{Requests: 2},
+	}, config.RedisConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := m.Charge(t.Context(), "bob", Usage{Requests: 1}, MetricRequests); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = m.Charge(t.Context(), "bob", Usage{Requests: 1}, MetricRequests)
+	var exceededErr *ExceededError
+	if !errors.As(err, &exceededErr) || exceededErr.Period != PeriodMonthly || exceededErr.Used != 2 {
+		t.Fatalf("got %v, want monthly quota exceeded", err)
+	}
+	report, err := m.Report(t.Context(), "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range report.Periods {
+		if p.Used.Requests != 2 {
+			t.Fatalf("%s usage is %d, want 2", p.Period, p.Used.Requests)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/redisclient"
+)
+
+// MemoryStore 进程内用量存储，重启后清零，多副本之间不共享
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+// memoryEntry 内存中的计数
+type memoryEntry struct {
+	usage    Usage
+	expireAt time.Time
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*memoryEntry)}
+}
+
+// Add 实现 Store，同时清理已过期的计数
+func (s *MemoryStore) Add(_ context.Context, key string, delta Usage, expireAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entryLocked(key, expireAt)
+	e.usage = e.usage.add(delta)
+	return nil
+}
+
+// Charge 实现 Store，检查和累加在同一次加锁中完成
+func (s *MemoryStore) Charge(_ context.Context, key string, delta, limit Usage, metrics []string, expireAt time.Time) (Usage, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entryLocked(key, expireAt)
+	used := e.usage
+	if used.exceeded(limit, metrics) != "" {
+		return used, false, nil
+	}
+	e.usage = used.add(delta)
+	return used, true, nil
+}
+
+// entryLocked 返回 key 的计数，不存在时创建，同时清理已过期的计数；调用方需持有 s.mu
+func (s *MemoryStore) entryLocked(key string, expireAt time.Time) *memoryEntry {
+	now := time.Now()
+	for k, e := range s.entries {
+		if now.After(e.expireAt) {
+			delete(s.entries, k)
+		}
+	}
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &memoryEntry{expireAt: expireAt}
+		s.entries[key] = e
+	}
+	return e
+}
+
+// Get 实现 Store
+func (s *MemoryStore) Get(_ context.Context, key string) (Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok && time.Now().Before(e.expireAt) {
+		return e.usage, nil
+	}
+	return Usage{}, nil
+}
+
+// redisTimeout 单次 Redis 操作超时
+const redisTimeout = 5 * time.Second
+
+// RedisStore 基于 Redis 的用量存储，多个副本共享计数
+// 每个用户每个周期一个哈希 <prefix>:quota:<用户>:<周期>，在周期结束后过期。
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore 创建 Redis 存储
+func NewRedisStore(cfg config.RedisConfig) (*RedisStore, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStore{client: client, prefix: cfg.Prefix}, nil
+}
+
+// Add 实现 Store
+func (s *RedisStore) Add(ctx context.Context, key string, delta Usage, expireAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), redisTimeout)
+	defer cancel()
+
+	key = redisclient.Key(s.prefix, "quota", key)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr(ctx, pipe, key, delta, expireAt)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("write usage: %w", err)
+	}
+	return nil
+}
+
+// Charge 实现 Store：先在事务中累加并读取累加后的用量，再比较累加前的值，
+// 已达到限额时减回 delta。减回之前并发的请求可能看到偏大的用量而被拒绝，但不会超出限额
+func (s *RedisStore) Charge(ctx context.Context, key string, delta, limit Usage, metrics []string, expireAt time.Time) (Usage, bool, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), redisTimeout)
+	defer cancel()
+
+	redisKey := redisclient.Key(s.prefix, "quota", key)
+	var values *redis.MapStringStringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr(ctx, pipe, redisKey, delta, expireAt)
+		values = pipe.HGetAll(ctx, redisKey)
+		return nil
+	})
+	if err != nil {
+		return Usage{}, false, fmt.Errorf("charge usage: %w", err)
+	}
+	charged, err := parseUsage(values.Val())
+	if err != nil {
+		return Usage{}, false, err
+	}
+
+	used := charged.add(delta.negate())
+	if used.exceeded(limit, metrics) == "" {
+		return used, true, nil
+	}
+	if err := s.Add(ctx, key, delta.negate(), expireAt); err != nil {
+		return used, false, fmt.Errorf("revert usage: %w", err)
+	}
+	return used, false, nil
+}
+
+// incr 在事务中累加 delta 并设置过期时间
+func incr(ctx context.Context, pipe redis.Pipeliner, key string, delta Usage, expireAt time.Time) {
+	if delta.Requests != 0 {
+		pipe.HIncrBy(ctx, key, MetricRequests, delta.Requests)
+	}
+	if delta.Tokens != 0 {
+		pipe.HIncrBy(ctx, key, MetricTokens, delta.Tokens)
+	}
+	if delta.ToolCalls != 0 {
+		pipe.HIncrBy(ctx, key, MetricToolCalls, delta.ToolCalls)
+	}
+	pipe.ExpireAt(ctx, key, expireAt)
+}
+
+// Get 实现 Store
+func (s *RedisStore) Get(ctx context.Context, key string) (Usage, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	values, err := s.client.HGetAll(ctx, redisclient.Key(s.prefix, "quota", key)).Result()
+	if err != nil {
+		return Usage{}, fmt.Errorf("read usage: %w", err)
+	}
+	return parseUsage(values)
+}
+
+// parseUsage 解析用量哈希的字段
+func parseUsage(values map[string]string) (Usage, error) {
+	var u Usage
+	var err error
+	for field, target := range map[string]*int64{
+		MetricRequests:  &u.Requests,
+		MetricTokens:    &u.Tokens,
+		MetricToolCalls: &u.ToolCalls,
+	} {
+		if v, ok := values[field]; ok {
+			if *target, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return Usage{}, fmt.Errorf("parse usage %s: %w", field, err)
+			}
+		}
+	}
+	return u, nil
+}
@@ -0,0 +1,242 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/redisclient"
+)
+
+// backendTimeout 后端操作超时
+const backendTimeout = 30 * time.Second
+
+// maxRemoveAttempts 删除分块时与其他副本的写入冲突的最大重试次数
+const maxRemoveAttempts = 5
+
+// Backend 共享向量存储后端，多个副本通过它同步文档
+// 每次写入递增版本号，各副本检索前比较版本，有变化时重新加载到内存。
+type Backend interface {
+	// Append 追加文档分块
+	Append(ctx context.Context, docs []*Document) error
+	// Remove 删除 match 返回 true 的分块，返回删除的分块数
+	Remove(ctx context.Context, match func(*Document) bool) (int, error)
+	// Load 加载全部分块及对应版本
+	Load(ctx context.Context) ([]*Document, int64, error)
+	// Version 返回当前版本
+	Version(ctx context.Context) (int64, error)
+}
+
+// SetBackend 设置共享后端并加载已有文档
+func (r *RAG) SetBackend(ctx context.Context, backend Backend) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.backend = backend
+	r.version = -1
+	return r.reloadLocked(ctx)
+}
+
+// sync 后端版本变化时重新加载文档
+func (r *RAG) sync(ctx context.Context) {
+	if r.backend == nil {
+		return
+	}
+
+	version, err := r.backend.Version(ctx)
+	if err != nil {
+		klog.ErrorS(err, "Failed to check rag backend version")
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if version == r.version {
+		return
+	}
+	if err := r.reloadLocked(ctx); err != nil {
+		klog.ErrorS(err, "Failed to reload rag documents from backend")
+	}
+}
+
+// syncBackground 用独立的超时上下文同步，供无 ctx 的查询方法使用
+func (r *RAG) syncBackground() {
+	if r.backend == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+	r.sync(ctx)
+}
+
+// reloadLocked 从后端加载所有文档（需持有写锁）
+func (r *RAG) reloadLocked(ctx context.Context) error {
+	docs, version, err := r.backend.Load(ctx)
+	if err != nil {
+		return err
+	}
+	r.setDocumentsLocked(docs)
+	r.version = version
+	klog.V(2).InfoS("RAG documents reloaded from backend", "chunks", len(docs), "version", version)
+	return nil
+}
+
+// RedisBackend 基于 Redis 的共享向量存储
+// 分块以 JSON 形式追加在 <prefix>:rag:chunks 列表中，<prefix>:rag:version 为版本号。
+type RedisBackend struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisBackend 创建 Redis 后端，已有数据的嵌入模型与 embedModel 不一致时返回错误
+func NewRedisBackend(cfg config.RedisConfig, embedModel string) (*RedisBackend, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	b := &RedisBackend{client: client, prefix: cfg.Prefix}
+
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+
+	// 嵌入模型变化后旧向量不可比较，拒绝混用
+	ok, err := client.SetNX(ctx, b.key("model"), embedModel, 0).Result()
+	if err != nil {
+		return nil, fmt.Errorf("check rag embed model: %w", err)
+	}
+	if !ok {
+		stored, err := client.Get(ctx, b.key("model")).Result()
+		if err != nil {
+			return nil, fmt.Errorf("check rag embed model: %w", err)
+		}
+		if stored != embedModel {
+			return nil, fmt.Errorf("rag backend uses embed model %q, config uses %q; delete %s* to re-ingest", stored, embedModel, b.key(""))
+		}
+	}
+	return b, nil
+}
+
+// key 返回带前缀的键
+func (b *RedisBackend) key(name string) string {
+	return redisclient.Key(b.prefix, "rag", name)
+}
+
+// Append 追加文档分块并递增版本
+func (b *RedisBackend) Append(ctx context.Context, docs []*Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	values := make([]any, 0, len(docs))
+	for _, doc := range docs {
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("marshal rag chunk: %w", err)
+		}
+		values = append(values, data)
+	}
+
+	_, err := b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, b.key("chunks"), values...)
+		pipe.Incr(ctx, b.key("version"))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("append rag chunks: %w", err)
+	}
+	return nil
+}
+
+// Remove 重写分块列表，删除匹配的分块并递增版本。列表在读取后被其他副本修改时重试
+func (b *RedisBackend) Remove(ctx context.Context, match func(*Document) bool) (int, error) {
+	chunks := b.key("chunks")
+	for range maxRemoveAttempts {
+		removed := 0
+		err := b.client.Watch(ctx, func(tx *redis.Tx) error {
+			values, err := tx.LRange(ctx, chunks, 0, -1).Result()
+			if err != nil {
+				return err
+			}
+			kept := make([]any, 0, len(values))
+			for _, data := range values {
+				var doc Document
+				if err := json.Unmarshal([]byte(data), &doc); err == nil {
+					if doc.Collection == "" {
+						doc.Collection = DefaultCollection
+					}
+					if match(&doc) {
+						removed++
+						continue
+					}
+				}
+				kept = append(kept, data)
+			}
+			if removed == 0 {
+				return nil
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Del(ctx, chunks)
+				if len(kept) > 0 {
+					pipe.RPush(ctx, chunks, kept...)
+				}
+				pipe.Incr(ctx, b.key("version"))
+				return nil
+			})
+			return err
+		}, chunks)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("remove rag chunks: %w", err)
+		}
+		return removed, nil
+	}
+	return 0, errors.New("remove rag chunks: too many concurrent writes")
+}
+
+// Load 加载全部分块及对应版本（同一事务内读取，保证一致）
+func (b *RedisBackend) Load(ctx context.Context) ([]*Document, int64, error) {
+	var chunks *redis.StringSliceCmd
+	var version *redis.StringCmd
+	_, err := b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		chunks = pipe.LRange(ctx, b.key("chunks"), 0, -1)
+		version = pipe.Get(ctx, b.key("version"))
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, 0, fmt.Errorf("load rag chunks: %w", err)
+	}
+
+	docs := make([]*Document, 0, len(chunks.Val()))
+	for _, data := range chunks.Val() {
+		var doc Document
+		if err := json.Unmarshal([]byte(data), &doc); err != nil {
+			klog.ErrorS(err, "Failed to parse rag chunk from backend")
+			continue
+		}
+		if doc.Collection == "" {
+			doc.Collection = DefaultCollection
+		}
+		docs = append(docs, &doc)
+	}
+
+	v, _ := strconv.ParseInt(version.Val(), 10, 64)
+	return docs, v, nil
+}
+
+// Version 返回当前版本
+func (b *RedisBackend) Version(ctx context.Context) (int64, error) {
+	v, err := b.client.Get(ctx, b.key("version")).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return v, err
+}
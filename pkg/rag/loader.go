@@ -0,0 +1,149 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxSourceSize 单个来源允许加载的最大字节数
+const maxSourceSize = 10 << 20
+
+// supportedExts 目录导入时支持的文件扩展名
+var supportedExts = map[string]bool{
+	".md":  true,
+	".txt": true,
+}
+
+// Source 待导入的原始文档
+type Source struct {
+	ID       string
+	Content  string
+	Metadata map[string]string
+}
+
+// IsURL 判断目标是否为 http(s) 地址
+func IsURL(target string) bool {
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// LoadPath 从文件或目录（递归）加载文档
+func LoadPath(path string) ([]Source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", path, err)
+	}
+
+	if !info.IsDir() {
+		src, err := loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return []Source{src}, nil
+	}
+
+	var sources []Source
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !supportedExts[filepath.Ext(p)] {
+			return nil
+		}
+		src, err := loadFile(p)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, src)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %s: %w", path, err)
+	}
+	return sources, nil
+}
+
+// loadFile 加载单个文件，使用文件名（不含扩展名）作为文档 ID
+func loadFile(path string) (Source, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Source{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	if len(content) > maxSourceSize {
+		return Source{}, fmt.Errorf("file %s exceeds %d bytes", path, maxSourceSize)
+	}
+
+	name := filepath.Base(path)
+	return Source{
+		ID:      strings.TrimSuffix(name, filepath.Ext(name)),
+		Content: string(content),
+		Metadata: map[string]string{
+			"source": path,
+			"file":   name,
+		},
+	}, nil
+}
+
+// LoadURL 下载网页或文本内容作为文档
+func LoadURL(ctx context.Context, client *http.Client, url string) (Source, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Source{}, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Source{}, fmt.Errorf("fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Source{}, fmt.Errorf("fetch %s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceSize+1))
+	if err != nil {
+		return Source{}, fmt.Errorf("read %s: %w", url, err)
+	}
+	if len(data) > maxSourceSize {
+		return Source{}, fmt.Errorf("content of %s exceeds %d bytes", url, maxSourceSize)
+	}
+
+	content := string(data)
+	if strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		content = htmlToText(content)
+	}
+
+	return Source{
+		ID:      url,
+		Content: content,
+		Metadata: map[string]string{
+			"source": url,
+			"url":    url,
+		},
+	}, nil
+}
+
+var (
+	scriptRe = regexp.MustCompile(`(?is)<(script|style|noscript)[^>]*>.*?</(script|style|noscript)>`)
+	blockRe  = regexp.MustCompile(`(?i)</?(p|div|br|li|tr|h[1-6]|section|article)[^>]*>`)
+	tagRe    = regexp.MustCompile(`(?s)<[^>]+>`)
+	blankRe  = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// htmlToText 粗略地将 HTML 转换为纯文本
+func htmlToText(s string) string {
+	s = scriptRe.ReplaceAllString(s, "")
+	s = blockRe.ReplaceAllString(s, "\n")
+	s = tagRe.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = blankRe.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
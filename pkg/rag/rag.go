@@ -3,7 +3,7 @@ package rag
 import (
 	"context"
 	"fmt"
-	"math"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -11,12 +11,16 @@ import (
 	"k8s.io/klog/v2"
 )
 
+// DefaultCollection 默认集合名称
+const DefaultCollection = "default"
+
 // Document 文档结构
 type Document struct {
-	ID        string    // 文档ID
-	Content   string    // 文档内容
-	Embedding []float32 // 嵌入向量
-	Metadata  map[string]string
+	ID         string    // 文档ID
+	Collection string    // 所属集合
+	Content    string    // 文档内容
+	Embedding  []float32 // 嵌入向量
+	Metadata   map[string]string
 }
 
 // SearchResult 搜索结果
@@ -33,10 +37,15 @@ type EmbeddingFunc func(ctx context.Context, text string) ([]float32, error)
 type RAG struct {
 	mu           sync.RWMutex
 	documents    []*Document
+	index        *vectorIndex // documents 的归一化向量索引
 	embedFunc    EmbeddingFunc
 	embedModel   string
 	chunkSize    int // 分块大小
 	chunkOverlap int // 分块重叠
+
+	// 共享后端（为空时文档仅保存在内存中）及已加载的版本
+	backend Backend
+	version int64
 }
 
 // Config RAG 配置
@@ -62,6 +71,7 @@ func New(cfg *Config, embedFunc EmbeddingFunc) *RAG {
 	}
 	return &RAG{
 		documents:    make([]*Document, 0),
+		index:        &vectorIndex{},
 		embedFunc:    embedFunc,
 		embedModel:   cfg.EmbedModel,
 		chunkSize:    cfg.ChunkSize,
@@ -69,14 +79,30 @@ func New(cfg *Config, embedFunc EmbeddingFunc) *RAG {
 	}
 }
 
-// AddDocument 添加文档
-func (r *RAG) AddDocument(ctx context.Context, id, content string, metadata map[string]string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// AddDocument 添加文档到指定集合（collection 为空时使用默认集合）
+func (r *RAG) AddDocument(ctx context.Context, collection, id, content string, metadata map[string]string) error {
+	return r.AddDocumentWithProgress(ctx, collection, id, content, metadata, nil)
+}
 
+// AddDocumentWithProgress 添加文档，每嵌入一个分块调用一次 onChunk（可为 nil），用于报告导入进度
+func (r *RAG) AddDocumentWithProgress(ctx context.Context, collection, id, content string, metadata map[string]string, onChunk func()) error {
 	// 分块处理
 	chunks := r.splitText(content)
+	if err := r.addChunks(ctx, collection, id, chunks, metadata, onChunk); err != nil {
+		return err
+	}
+
+	klog.InfoS("Document added", "collection", collection, "id", id, "chunks", len(chunks))
+	return nil
+}
+
+// addChunks 嵌入分块并追加到集合。嵌入在锁外进行，长时间的导入不阻塞检索
+func (r *RAG) addChunks(ctx context.Context, collection, id string, chunks []string, metadata map[string]string, onChunk func()) error {
+	if collection == "" {
+		collection = DefaultCollection
+	}
 
+	docs := make([]*Document, 0, len(chunks))
 	for i, chunk := range chunks {
 		// 生成嵌入向量
 		embedding, err := r.embedFunc(ctx, chunk)
@@ -84,47 +110,65 @@ func (r *RAG) AddDocument(ctx context.Context, id, content string, metadata map[
 			return fmt.Errorf("failed to embed chunk %d: %w", i, err)
 		}
 
-		doc := &Document{
-			ID:        fmt.Sprintf("%s_chunk_%d", id, i),
-			Content:   chunk,
-			Embedding: embedding,
-			Metadata:  metadata,
+		docs = append(docs, &Document{
+			ID:         fmt.Sprintf("%s_chunk_%d", id, i),
+			Collection: collection,
+			Content:    chunk,
+			Embedding:  embedding,
+			Metadata:   metadata,
+		})
+		if onChunk != nil {
+			onChunk()
 		}
-		r.documents = append(r.documents, doc)
 	}
 
-	klog.InfoS("Document added", "id", id, "chunks", len(chunks))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.appendLocked(ctx, docs)
+}
+
+// appendLocked 归一化向量后追加分块到内存和共享后端（需持有写锁）
+func (r *RAG) appendLocked(ctx context.Context, docs []*Document) error {
+	for _, doc := range docs {
+		normalize(doc.Embedding)
+	}
+	if r.backend != nil {
+		if err := r.backend.Append(ctx, docs); err != nil {
+			return err
+		}
+	}
+	r.documents = append(r.documents, docs...)
+	if skipped := r.index.add(docs); skipped > 0 {
+		klog.InfoS("Chunks with mismatched embedding dimension are not searchable", "chunks", skipped, "dimension", r.index.dim)
+	}
 	return nil
 }
 
-// AddDocumentWithChunks 直接添加已分块的文档
-func (r *RAG) AddDocumentWithChunks(ctx context.Context, id string, chunks []string, metadata map[string]string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// setDocumentsLocked 替换全部分块并重建向量索引（需持有写锁）
+func (r *RAG) setDocumentsLocked(docs []*Document) {
+	r.documents = docs
+	r.index = &vectorIndex{}
+	if skipped := r.index.add(docs); skipped > 0 {
+		klog.InfoS("Chunks with mismatched embedding dimension are not searchable", "chunks", skipped, "dimension", r.index.dim)
+	}
+}
 
+// AddDocumentWithChunks 直接添加已分块的文档
+func (r *RAG) AddDocumentWithChunks(ctx context.Context, collection, id string, chunks []string, metadata map[string]string) error {
 	klog.InfoS("Adding document with pre-split chunks", "id", id, "chunks", len(chunks))
 
-	for i, chunk := range chunks {
-		embedding, err := r.embedFunc(ctx, chunk)
-		if err != nil {
-			return fmt.Errorf("failed to embed chunk %d: %w", i, err)
-		}
-
-		doc := &Document{
-			ID:        fmt.Sprintf("%s_chunk_%d", id, i),
-			Content:   chunk,
-			Embedding: embedding,
-			Metadata:  metadata,
-		}
-		r.documents = append(r.documents, doc)
+	if err := r.addChunks(ctx, collection, id, chunks, metadata, nil); err != nil {
+		return err
 	}
 
 	klog.InfoS("Document chunks added successfully", "id", id, "totalChunks", len(chunks))
 	return nil
 }
 
-// Search 搜索相关文档
-func (r *RAG) Search(ctx context.Context, query string, topK int) ([]SearchResult, error) {
+// Search 搜索相关文档（collection 为空时搜索所有集合，以 / 结尾时搜索该前缀下的所有集合）
+func (r *RAG) Search(ctx context.Context, collection, query string, topK int) ([]SearchResult, error) {
+	r.sync(ctx)
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -138,13 +182,16 @@ func (r *RAG) Search(ctx context.Context, query string, topK int) ([]SearchResul
 		return nil, fmt.Errorf("failed to embed query: %w", err)
 	}
 
-	// 计算相似度
-	results := make([]SearchResult, 0, len(r.documents))
-	for _, doc := range r.documents {
-		score := cosineSimilarity(queryEmbedding, doc.Embedding)
+	// 存储的向量已归一化，归一化查询向量后点积即余弦相似度
+	normalize(queryEmbedding)
+	hits := r.index.search(queryEmbedding, func(doc *Document) bool {
+		return matchCollection(collection, doc.Collection)
+	})
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
 		results = append(results, SearchResult{
-			Document: doc,
-			Score:    score,
+			Document: r.index.docs[hit.row],
+			Score:    hit.score,
 		})
 	}
 
@@ -153,6 +200,10 @@ func (r *RAG) Search(ctx context.Context, query string, topK int) ([]SearchResul
 		return results[i].Score > results[j].Score
 	})
 
+	if len(results) == 0 {
+		return nil, nil
+	}
+
 	// 返回 top-K 结果
 	if topK > len(results) {
 		topK = len(results)
@@ -160,6 +211,7 @@ func (r *RAG) Search(ctx context.Context, query string, topK int) ([]SearchResul
 
 	klog.V(2).InfoS("Search completed",
 		"query", query,
+		"collection", collection,
 		"totalDocs", len(r.documents),
 		"topK", topK,
 		"topScore", results[0].Score)
@@ -167,15 +219,30 @@ func (r *RAG) Search(ctx context.Context, query string, topK int) ([]SearchResul
 	return results[:topK], nil
 }
 
+// matchCollection 判断文档集合是否匹配检索条件
+func matchCollection(filter, collection string) bool {
+	if filter == "" {
+		return true
+	}
+	if strings.HasSuffix(filter, "/") {
+		return strings.HasPrefix(collection, filter)
+	}
+	return collection == filter
+}
+
 // GetContext 获取增强上下文
-func (r *RAG) GetContext(ctx context.Context, query string, topK int) (string, error) {
-	results, err := r.Search(ctx, query, topK)
+func (r *RAG) GetContext(ctx context.Context, collection, query string, topK int) (string, error) {
+	results, err := r.Search(ctx, collection, query, topK)
 	if err != nil {
 		return "", err
 	}
+	return FormatContext(results), nil
+}
 
+// FormatContext 把检索结果整理为模型的参考资料，没有结果时返回空字符串
+func FormatContext(results []SearchResult) string {
 	if len(results) == 0 {
-		return "", nil
+		return ""
 	}
 
 	// 构建上下文
@@ -190,7 +257,15 @@ func (r *RAG) GetContext(ctx context.Context, query string, topK int) (string, e
 
 	sb.WriteString("请基于以上参考资料回答用户问题。如果参考资料中没有相关信息，请明确说明。\n\n")
 
-	return sb.String(), nil
+	return sb.String()
+}
+
+// SourceID 返回分块所属文档的 ID
+func SourceID(chunkID string) string {
+	if i := strings.LastIndex(chunkID, "_chunk_"); i >= 0 {
+		return chunkID[:i]
+	}
+	return chunkID
 }
 
 // splitText 文本分块
@@ -265,34 +340,89 @@ func (r *RAG) splitText(text string) []string {
 
 // DocumentCount 返回文档数量
 func (r *RAG) DocumentCount() int {
+	r.syncBackground()
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	return len(r.documents)
 }
 
-// Clear 清空所有文档
-func (r *RAG) Clear() {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.documents = make([]*Document, 0)
+// Collections 返回各集合的分块数量
+func (r *RAG) Collections() map[string]int {
+	r.syncBackground()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(map[string]int)
+	for _, doc := range r.documents {
+		result[doc.Collection]++
+	}
+	return result
 }
 
-// cosineSimilarity 计算余弦相似度
-func cosineSimilarity(a, b []float32) float32 {
-	if len(a) != len(b) {
-		return 0
+// Documents 返回集合中的文档 ID 及其第一个分块的元数据
+func (r *RAG) Documents(collection string) map[string]map[string]string {
+	if collection == "" {
+		collection = DefaultCollection
 	}
+	r.syncBackground()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	var dotProduct, normA, normB float64
-	for i := range a {
-		dotProduct += float64(a[i]) * float64(b[i])
-		normA += float64(a[i]) * float64(a[i])
-		normB += float64(b[i]) * float64(b[i])
+	result := make(map[string]map[string]string)
+	for _, doc := range r.documents {
+		id := SourceID(doc.ID)
+		if _, ok := result[id]; !ok && doc.Collection == collection {
+			result[id] = doc.Metadata
+		}
+	}
+	return result
+}
+
+// RemoveDocuments 删除集合中指定文档的所有分块，返回删除的分块数
+func (r *RAG) RemoveDocuments(ctx context.Context, collection string, ids []string) (int, error) {
+	if collection == "" {
+		collection = DefaultCollection
 	}
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	match := func(doc *Document) bool {
+		return doc.Collection == collection && remove[SourceID(doc.ID)]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	if normA == 0 || normB == 0 {
-		return 0
+	var removed int
+	if r.backend != nil {
+		var err error
+		if removed, err = r.backend.Remove(ctx, match); err != nil {
+			return 0, err
+		}
+		if removed > 0 {
+			if err := r.reloadLocked(ctx); err != nil {
+				return removed, err
+			}
+		}
+	} else {
+		kept := slices.DeleteFunc(slices.Clone(r.documents), match)
+		removed = len(r.documents) - len(kept)
+		if removed > 0 {
+			r.setDocumentsLocked(kept)
+		}
 	}
 
-	return float32(dotProduct / (math.Sqrt(normA) * math.Sqrt(normB)))
+	klog.InfoS("Documents removed", "collection", collection, "documents", len(ids), "chunks", removed)
+	return removed, nil
+}
+
+// Clear 清空所有文档
+func (r *RAG) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.setDocumentsLocked(make([]*Document, 0))
 }
@@ -0,0 +1,83 @@
+package rag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/klog/v2"
+)
+
+// snapshot 持久化文件格式
+type snapshot struct {
+	EmbedModel string      `json:"embed_model"`
+	Documents  []*Document `json:"documents"`
+}
+
+// Save 将所有文档（含嵌入向量）保存到文件
+func (r *RAG) Save(path string) error {
+	r.mu.RLock()
+	data, err := json.Marshal(snapshot{
+		EmbedModel: r.embedModel,
+		Documents:  r.documents,
+	})
+	count := len(r.documents)
+	r.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("marshal rag snapshot: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create rag store directory: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免写入中断导致文件损坏
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write rag snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename rag snapshot: %w", err)
+	}
+
+	klog.V(2).InfoS("RAG snapshot saved", "path", path, "chunks", count)
+	return nil
+}
+
+// Load 从文件加载文档，文件不存在时忽略
+func (r *RAG) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read rag snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("parse rag snapshot: %w", err)
+	}
+
+	// 嵌入模型变化后旧向量不可比较，直接丢弃
+	if snap.EmbedModel != "" && snap.EmbedModel != r.embedModel {
+		klog.InfoS("RAG snapshot embed model mismatch, ignoring",
+			"path", path, "snapshotModel", snap.EmbedModel, "embedModel", r.embedModel)
+		return nil
+	}
+
+	for _, doc := range snap.Documents {
+		if doc.Collection == "" {
+			doc.Collection = DefaultCollection
+		}
+	}
+
+	r.mu.Lock()
+	r.setDocumentsLocked(snap.Documents)
+	r.mu.Unlock()
+
+	klog.InfoS("RAG snapshot loaded", "path", path, "chunks", len(snap.Documents))
+	return nil
+}
@@ -0,0 +1,137 @@
+package rag
+
+import (
+	"math"
+	"runtime"
+	"sync"
+)
+
+// parallelThreshold 参与评分的向量元素总数超过该值时按 CPU 核数并行评分
+const parallelThreshold = 1 << 18
+
+// vectorIndex 连续存储的归一化向量，检索时的相似度即点积。
+// 各分块的 Embedding 指向 data 中对应的片段，不额外占用内存
+type vectorIndex struct {
+	dim  int
+	data []float32   // 按行连续存放，第 i 行为 docs[i] 的向量
+	docs []*Document // 已建立索引的分块，与 data 的行一一对应
+}
+
+// add 归一化并追加分块的向量；维度与已有向量不一致或为空的分块不参与检索
+func (x *vectorIndex) add(docs []*Document) (skipped int) {
+	oldCap, first := cap(x.data), len(x.docs)
+	for _, doc := range docs {
+		if len(doc.Embedding) == 0 || (x.dim != 0 && len(doc.Embedding) != x.dim) {
+			skipped++
+			continue
+		}
+		if x.dim == 0 {
+			x.dim = len(doc.Embedding)
+		}
+		x.data = append(x.data, doc.Embedding...)
+		normalize(x.data[len(x.data)-x.dim:])
+		x.docs = append(x.docs, doc)
+	}
+
+	// append 重新分配了 data 时所有分块都需要指向新的存储，否则只处理新增的分块
+	if cap(x.data) != oldCap {
+		first = 0
+	}
+	for i := first; i < len(x.docs); i++ {
+		x.docs[i].Embedding = x.data[i*x.dim : (i+1)*x.dim : (i+1)*x.dim]
+	}
+	return skipped
+}
+
+// scored 某一行的得分
+type scored struct {
+	row   int
+	score float32
+}
+
+// search 计算 query 与 filter 选中的各行的点积，query 需已归一化
+func (x *vectorIndex) search(query []float32, filter func(*Document) bool) []scored {
+	rows := len(x.docs)
+	if rows == 0 || len(query) != x.dim {
+		return nil
+	}
+
+	workers := 1
+	if rows*x.dim >= parallelThreshold {
+		workers = min(runtime.GOMAXPROCS(0), rows)
+	}
+	if workers == 1 {
+		return x.scoreRange(query, filter, 0, rows)
+	}
+
+	parts := make([][]scored, workers)
+	per := (rows + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := range workers {
+		start, end := w*per, min((w+1)*per, rows)
+		if start >= end {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			parts[w] = x.scoreRange(query, filter, start, end)
+		}()
+	}
+	wg.Wait()
+
+	results := make([]scored, 0, rows)
+	for _, part := range parts {
+		results = append(results, part...)
+	}
+	return results
+}
+
+// scoreRange 计算 [start, end) 行的得分
+func (x *vectorIndex) scoreRange(query []float32, filter func(*Document) bool, start, end int) []scored {
+	results := make([]scored, 0, end-start)
+	for i := start; i < end; i++ {
+		if !filter(x.docs[i]) {
+			continue
+		}
+		results = append(results, scored{row: i, score: dot(query, x.data[i*x.dim:(i+1)*x.dim])})
+	}
+	return results
+}
+
+// normalize 原地归一化为单位向量，零向量保持不变
+func normalize(v []float32) {
+	var sum float64
+	for _, f := range v {
+		sum += float64(f) * float64(f)
+	}
+	if sum == 0 {
+		return
+	}
+	inv := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= inv
+	}
+}
+
+// dot 计算点积：4 路展开并使用独立的累加器，消除循环内的边界检查和依赖链，
+// 便于 CPU 流水线并行执行
+func dot(a, b []float32) float32 {
+	n := min(len(a), len(b))
+	a, b = a[:n], b[:n]
+
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= n; i += 4 {
+		aa := a[i : i+4 : i+4]
+		bb := b[i : i+4 : i+4]
+		s0 += aa[0] * bb[0]
+		s1 += aa[1] * bb[1]
+		s2 += aa[2] * bb[2]
+		s3 += aa[3] * bb[3]
+	}
+	for ; i < n; i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
@@ -0,0 +1,115 @@
+package rag
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"testing"
+)
+
+// cosineSimilarity 改为归一化索引之前逐个分块计算余弦相似度的实现，作为对照
+func cosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dotProduct, normA, normB float64
+	for i := range a {
+		dotProduct += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return float32(dotProduct / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
+// randomVectors 生成 n 个 dim 维的随机向量
+func randomVectors(rng *rand.Rand, n, dim int) [][]float32 {
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = float32(rng.NormFloat64())
+		}
+		vectors[i] = v
+	}
+	return vectors
+}
+
+// newTestIndex 以向量的副本建立索引，add 会归一化索引中的向量，原始向量保持不变
+func newTestIndex(vectors [][]float32) *vectorIndex {
+	docs := make([]*Document, len(vectors))
+	for i, v := range vectors {
+		docs[i] = &Document{ID: fmt.Sprint(i), Embedding: append([]float32(nil), v...)}
+	}
+	x := &vectorIndex{}
+	x.add(docs)
+	return x
+}
+
+// indexTopK 按新的索引检索，返回得分最高的 k 行
+func indexTopK(x *vectorIndex, query []float32, k int) []scored {
+	q := append([]float32(nil), query...)
+	normalize(q)
+	hits := x.search(q, func(*Document) bool { return true })
+	sort.Slice(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+	return hits[:min(k, len(hits))]
+}
+
+// cosineTopK 按原来的方式逐个计算余弦相似度，返回得分最高的 k 行
+func cosineTopK(vectors [][]float32, query []float32, k int) []scored {
+	hits := make([]scored, 0, len(vectors))
+	for i, v := range vectors {
+		hits = append(hits, scored{row: i, score: cosineSimilarity(query, v)})
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+	return hits[:min(k, len(hits))]
+}
+
+func TestSearchMatchesCosine(t *testing.T) {
+	const topK = 20
+	rng := rand.New(rand.NewPCG(1, 2))
+	// 第二组的元素总数超过 parallelThreshold，覆盖并行评分
+	for _, size := range []struct{ rows, dim int }{{200, 64}, {2000, 384}} {
+		t.Run(fmt.Sprintf("%dx%d", size.rows, size.dim), func(t *testing.T) {
+			vectors := randomVectors(rng, size.rows, size.dim)
+			x := newTestIndex(vectors)
+			for q, query := range randomVectors(rng, 10, size.dim) {
+				got, want := indexTopK(x, query, topK), cosineTopK(vectors, query, topK)
+				for i := range want {
+					if diff := math.Abs(float64(got[i].score - want[i].score)); diff > 1e-5 {
+						t.Fatalf("query %d rank %d: score %f, want %f", q, i, got[i].score, want[i].score)
+					}
+					// 得分几乎相同的行允许交换顺序
+					if got[i].row != want[i].row && math.Abs(float64(want[i].score-cosineSimilarity(query, vectors[got[i].row]))) > 1e-5 {
+						t.Fatalf("query %d rank %d: row %d, want %d", q, i, got[i].row, want[i].row)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	const rows, dim, topK = 10000, 768, 5
+	rng := rand.New(rand.NewPCG(1, 2))
+	vectors := randomVectors(rng, rows, dim)
+	query := randomVectors(rng, 1, dim)[0]
+
+	b.Run("index", func(b *testing.B) {
+		x := newTestIndex(vectors)
+		for b.Loop() {
+			indexTopK(x, query, topK)
+		}
+	})
+	b.Run("float64-cosine", func(b *testing.B) {
+		for b.Loop() {
+			cosineTopK(vectors, query, topK)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+// Package redisclient 创建多副本共享后端使用的 Redis 客户端
+package redisclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// pingTimeout 连接检查超时
+const pingTimeout = 5 * time.Second
+
+// New 创建 Redis 客户端并检查连接
+func New(cfg config.RedisConfig) (*redis.Client, error) {
+	opts := &redis.Options{
+		Addr:     cfg.Addr,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	}
+	if cfg.TLS {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect redis %s: %w", cfg.Addr, err)
+	}
+
+	klog.InfoS("Redis connected", "addr", cfg.Addr, "db", cfg.DB, "prefix", cfg.Prefix)
+	return client, nil
+}
+
+// Key 拼接带前缀的键
+func Key(prefix string, parts ...string) string {
+	key := prefix
+	for _, p := range parts {
+		key += ":" + p
+	}
+	return key
+}
@@ -0,0 +1,164 @@
+// Package router 按任务类型为请求选择模型：简短问答交给响应快的小模型，编程和需要大量工具调用的请求
+// 交给能力更强的模型，长文本总结交给上下文较长的模型，在异构硬件上兼顾质量和延迟
+package router
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// 任务类型
+const (
+	// CategoryQA 简短的问答
+	CategoryQA = "qa"
+	// CategoryCoding 编写、修改或排查代码，通常需要多次调用工具
+	CategoryCoding = "coding"
+	// CategorySummarization 总结、概括较长的文本
+	CategorySummarization = "summarization"
+)
+
+// 分类器
+const (
+	ClassifierHeuristic = "heuristic"
+	ClassifierModel     = "model"
+)
+
+// categories 所有任务类型
+var categories = []string{CategoryQA, CategoryCoding, CategorySummarization}
+
+// Classifier 判断请求的任务类型，无法判断时返回空字符串
+type Classifier interface {
+	Classify(ctx context.Context, text string) (string, error)
+}
+
+// ChatFunc 调用模型，传入提示词返回回答
+type ChatFunc func(ctx context.Context, prompt string) (string, error)
+
+// Router 按任务类型选择模型，nil 表示未启用
+type Router struct {
+	classifier Classifier
+	routes     map[string]string
+}
+
+// New 按配置创建路由，未启用时返回 nil；chat 用于 model 分类器
+func New(cfg config.RoutingConfig, chat ChatFunc) (*Router, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	r := &Router{routes: cfg.Routes}
+	switch cfg.Classifier {
+	case "", ClassifierHeuristic:
+		r.classifier = heuristicClassifier{}
+	case ClassifierModel:
+		r.classifier = &modelClassifier{chat: chat}
+	default:
+		return nil, fmt.Errorf("unknown routing classifier: %s", cfg.Classifier)
+	}
+	for category := range cfg.Routes {
+		if !validCategory(category) {
+			return nil, fmt.Errorf("routing: unknown category %q (expected one of %s)", category, strings.Join(categories, ", "))
+		}
+	}
+
+	klog.InfoS("Model routing enabled", "classifier", cfg.Classifier, "routes", cfg.Routes)
+	return r, nil
+}
+
+// Route 返回请求的任务类型和对应的模型；分类失败、无法分类或该类型未配置模型时模型为空
+func (r *Router) Route(ctx context.Context, text string) (category, model string) {
+	if r == nil {
+		return "", ""
+	}
+	category, err := r.classifier.Classify(ctx, text)
+	if err != nil {
+		klog.ErrorS(err, "Failed to classify request for routing")
+		return "", ""
+	}
+	return category, r.routes[category]
+}
+
+// validCategory 是否为已定义的任务类型
+func validCategory(category string) bool {
+	return slices.Contains(categories, category)
+}
+
+var (
+	// summarizePattern 要求总结、概括的表述
+	summarizePattern = regexp.MustCompile(`(?i)\b(summari[sz]e|summary|tl;?dr|recap|key\s+points)\b|总结|摘要|概括|归纳|提炼|要点`)
+	// codingPattern 代码块、文件路径、报错堆栈和编程相关的表述
+	codingPattern = regexp.MustCompile("(?i)```|\\b(func|def|class|import|package)\\s+\\w|\\w+\\.(go|py|js|ts|java|rs|c|cpp|yaml|json)\\b|\\b(stack\\s*trace|exception|compile|refactor|implement|debug|unit\\s+test|pull\\s+request)\\b|panic:|Traceback|代码|函数|编译|重构|报错|调试|单元测试|实现一个")
+)
+
+// 分类阈值（字符数）
+const (
+	// longText 超过该长度且没有代码特征的文本视为总结任务
+	longText = 2000
+	// shortQuestion 不超过该长度且没有代码特征时视为简短问答
+	shortQuestion = 200
+)
+
+// heuristicClassifier 基于关键词和长度的分类器，不增加模型调用
+type heuristicClassifier struct{}
+
+// Classify 实现 Classifier
+func (heuristicClassifier) Classify(_ context.Context, text string) (string, error) {
+	n := utf8.RuneCountInString(text)
+	switch {
+	case summarizePattern.MatchString(text):
+		return CategorySummarization, nil
+	case codingPattern.MatchString(text):
+		return CategoryCoding, nil
+	case n > longText:
+		return CategorySummarization, nil
+	case n <= shortQuestion:
+		return CategoryQA, nil
+	}
+	return "", nil
+}
+
+// maxClassifyLength 交给模型分类的最大字符数
+const maxClassifyLength = 2000
+
+// classifyPrompt 模型分类提示词
+const classifyPrompt = `判断下面的用户请求属于哪一类任务，只输出类别名称：
+- qa：简短的问答或解释
+- coding：编写、修改、排查代码或需要操作文件、执行命令
+- summarization：总结、概括较长的文本
+- other：以上都不是
+
+请求：
+%s`
+
+// modelClassifier 使用模型判断的分类器，比规则更准确但每个请求会增加一次模型调用
+type modelClassifier struct {
+	chat ChatFunc
+}
+
+// Classify 实现 Classifier
+func (c *modelClassifier) Classify(ctx context.Context, text string) (string, error) {
+	if runes := []rune(text); len(runes) > maxClassifyLength {
+		text = string(runes[:maxClassifyLength])
+	}
+
+	answer, err := c.chat(ctx, fmt.Sprintf(classifyPrompt, text))
+	if err != nil {
+		return "", fmt.Errorf("classify with model: %w", err)
+	}
+	// 模型可能输出额外的标点或说明，只要包含类别名称即可
+	answer = strings.ToLower(answer)
+	for _, category := range categories {
+		if strings.Contains(answer, category) {
+			return category, nil
+		}
+	}
+	return "", nil
+}
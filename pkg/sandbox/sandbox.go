@@ -0,0 +1,223 @@
+// Package sandbox 在受限环境（bubblewrap 或 docker 容器）中执行命令，限制 CPU、内存、网络和可写目录
+package sandbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// BackendNone 直接以当前进程的权限执行
+	BackendNone = "none"
+	// BackendBubblewrap 在 bubblewrap 命名空间中执行
+	BackendBubblewrap = "bubblewrap"
+	// BackendDocker 在一次性 docker 容器中执行
+	BackendDocker = "docker"
+
+	// defaultPidsLimit docker 容器内的最大进程数
+	defaultPidsLimit = 256
+	// waitDelay 命令结束后等待输出管道关闭的时间，避免后台子进程持有管道导致阻塞
+	waitDelay = 2 * time.Second
+)
+
+// systemDirs bubblewrap 中只读挂载的系统目录
+var systemDirs = []string{"/usr", "/bin", "/sbin", "/lib", "/lib64", "/etc"}
+
+// Config 沙箱配置
+type Config struct {
+	Backend string  // none、bubblewrap 或 docker
+	Workdir string  // 唯一可写的目录（绝对路径），在沙箱内路径不变
+	Image   string  // docker 镜像
+	CPUs    float64 // CPU 核数上限，0 表示不限制
+	Memory  int64   // 内存上限（字节），0 表示不限制
+	Network bool    // 是否允许访问网络，默认禁止
+}
+
+// Sandbox 沙箱执行器
+type Sandbox struct {
+	cfg Config
+}
+
+// New 创建沙箱执行器，校验所需的命令是否存在
+func New(cfg Config) (*Sandbox, error) {
+	if cfg.Backend == "" {
+		cfg.Backend = BackendNone
+	}
+	if cfg.Workdir != "" {
+		workdir, err := filepath.Abs(cfg.Workdir)
+		if err != nil {
+			return nil, fmt.Errorf("resolve workdir: %w", err)
+		}
+		cfg.Workdir = workdir
+	}
+
+	switch cfg.Backend {
+	case BackendNone:
+	case BackendBubblewrap:
+		if _, err := exec.LookPath("bwrap"); err != nil {
+			return nil, fmt.Errorf("bubblewrap sandbox: %w", err)
+		}
+		if cfg.CPUs > 0 || cfg.Memory > 0 {
+			if _, err := exec.LookPath("prlimit"); err != nil {
+				return nil, fmt.Errorf("bubblewrap resource limits: %w", err)
+			}
+		}
+	case BackendDocker:
+		if cfg.Image == "" {
+			return nil, fmt.Errorf("docker sandbox requires an image")
+		}
+		if _, err := exec.LookPath("docker"); err != nil {
+			return nil, fmt.Errorf("docker sandbox: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown sandbox backend: %s", cfg.Backend)
+	}
+
+	klog.InfoS("Sandbox created", "backend", cfg.Backend, "workdir", cfg.Workdir, "cpus", cfg.CPUs,
+		"memory", cfg.Memory, "network", cfg.Network)
+	return &Sandbox{cfg: cfg}, nil
+}
+
+// Backend 返回沙箱类型
+func (s *Sandbox) Backend() string {
+	return s.cfg.Backend
+}
+
+// Command 构造在沙箱中以 dir 为工作目录执行 name 的命令，ctx 取消时终止命令
+func (s *Sandbox) Command(ctx context.Context, dir, name string, args ...string) *exec.Cmd {
+	var cmd *exec.Cmd
+	switch s.cfg.Backend {
+	case BackendBubblewrap:
+		bwrap := s.bubblewrapArgs(dir, name, args)
+		if limits := s.rlimitArgs(ctx); len(limits) > 0 {
+			cmd = exec.CommandContext(ctx, "prlimit", slices.Concat(limits, []string{"--", "bwrap"}, bwrap)...)
+		} else {
+			cmd = exec.CommandContext(ctx, "bwrap", bwrap...)
+		}
+	case BackendDocker:
+		container := "ai-agent-sandbox-" + randomSuffix()
+		cmd = exec.CommandContext(ctx, "docker", s.dockerArgs(container, dir, name, args)...)
+		// 终止 docker CLI 不会停止容器，需要显式 kill
+		cmd.Cancel = func() error {
+			if err := exec.Command("docker", "kill", container).Run(); err != nil {
+				klog.V(2).InfoS("Failed to kill sandbox container", "container", container, "err", err)
+			}
+			return cmd.Process.Kill()
+		}
+	default:
+		cmd = exec.CommandContext(ctx, name, args...)
+		cmd.Dir = dir
+	}
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
+
+// bubblewrapArgs 构造 bwrap 参数：系统目录只读，仅 Workdir 可写，隔离所有命名空间并清空环境变量
+func (s *Sandbox) bubblewrapArgs(dir, name string, args []string) []string {
+	bwrap := []string{"--die-with-parent", "--new-session", "--unshare-all"}
+	if s.cfg.Network {
+		bwrap = append(bwrap, "--share-net")
+	}
+	for _, d := range systemDirs {
+		bwrap = append(bwrap, "--ro-bind-try", d, d)
+	}
+	bwrap = append(bwrap, "--proc", "/proc", "--dev", "/dev", "--tmpfs", "/tmp")
+	if s.cfg.Workdir != "" {
+		bwrap = append(bwrap, "--bind", s.cfg.Workdir, s.cfg.Workdir, "--setenv", "HOME", s.cfg.Workdir)
+	}
+	bwrap = append(bwrap,
+		"--clearenv",
+		"--setenv", "PATH", "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"--chdir", dir,
+		"--", name)
+	return append(bwrap, args...)
+}
+
+// rlimitArgs 构造 prlimit 参数。bubblewrap 没有 cgroup，内存通过地址空间上限限制，
+// CPU 通过 CPU 时间上限近似为 cpus × 剩余超时时间
+func (s *Sandbox) rlimitArgs(ctx context.Context) []string {
+	var limits []string
+	if s.cfg.Memory > 0 {
+		limits = append(limits, fmt.Sprintf("--as=%d", s.cfg.Memory))
+	}
+	if deadline, ok := ctx.Deadline(); ok && s.cfg.CPUs > 0 {
+		seconds := int64(math.Ceil(time.Until(deadline).Seconds() * s.cfg.CPUs))
+		limits = append(limits, fmt.Sprintf("--cpu=%d", max(seconds, 1)))
+	}
+	return limits
+}
+
+// dockerArgs 构造 docker run 参数：以当前用户运行，丢弃所有 capability，仅挂载 Workdir
+func (s *Sandbox) dockerArgs(container, dir, name string, args []string) []string {
+	run := []string{"run", "--rm", "-i", "--name", container,
+		"--cap-drop", "ALL", "--security-opt", "no-new-privileges",
+		"--pids-limit", strconv.Itoa(defaultPidsLimit),
+		"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+	}
+	if !s.cfg.Network {
+		run = append(run, "--network", "none")
+	}
+	if s.cfg.CPUs > 0 {
+		run = append(run, "--cpus", strconv.FormatFloat(s.cfg.CPUs, 'f', -1, 64))
+	}
+	if s.cfg.Memory > 0 {
+		run = append(run, "--memory", strconv.FormatInt(s.cfg.Memory, 10))
+	}
+	if s.cfg.Workdir != "" {
+		run = append(run, "-v", s.cfg.Workdir+":"+s.cfg.Workdir, "-e", "HOME="+s.cfg.Workdir)
+	}
+	run = append(run, "-w", dir, s.cfg.Image, name)
+	return append(run, args...)
+}
+
+// randomSuffix 生成容器名称后缀
+func randomSuffix() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// ParseSize 解析内存大小，支持 k/m/g 后缀（1024 进制），如 512m、2g
+func ParseSize(size string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(size))
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "b"), "i")
+	if s == "" {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+
+	multiplier := int64(1)
+	switch s[len(s)-1] {
+	case 'k':
+		multiplier = 1 << 10
+	case 'm':
+		multiplier = 1 << 20
+	case 'g':
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,113 @@
+// Package secrets 解析配置中的外部密钥引用（环境变量、HashiCorp Vault），并支持定期轮换
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// Provider 密钥提供者
+type Provider interface {
+	// Resolve 解析密钥引用，返回密钥值
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// resolver 按引用前缀分发到对应的提供者
+type resolver struct {
+	vault *Vault // 未配置 Vault 时为 nil
+}
+
+// New 根据配置创建密钥提供者，始终支持 env: 引用
+func New(cfg config.SecretsConfig) (Provider, error) {
+	r := &resolver{}
+	if cfg.Provider == "vault" {
+		v, err := NewVault(cfg.Vault)
+		if err != nil {
+			return nil, err
+		}
+		r.vault = v
+	}
+	return r, nil
+}
+
+// Resolve 解析 env:<NAME> 或 vault:<path>#<key>
+func (r *resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	scheme, rest, _ := strings.Cut(ref, ":")
+	switch scheme {
+	case "env":
+		value, ok := os.LookupEnv(rest)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", rest)
+		}
+		return value, nil
+	case "vault":
+		if r.vault == nil {
+			return "", fmt.Errorf("vault provider is not configured")
+		}
+		path, key, ok := strings.Cut(rest, "#")
+		if !ok || path == "" || key == "" {
+			return "", fmt.Errorf("invalid vault reference %q, expected vault:<path>#<key>", ref)
+		}
+		return r.vault.Read(ctx, path, key)
+	default:
+		return "", fmt.Errorf("unsupported secret reference %q", ref)
+	}
+}
+
+// Load 加载配置文件并解析其中的密钥引用，返回原始配置和解析后的配置
+func Load(ctx context.Context, path string) (raw, resolved *config.Config, err error) {
+	raw, err = config.Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	provider, err := New(raw.Secrets)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create secrets provider: %w", err)
+	}
+	resolved, err = raw.ResolveSecrets(ctx, provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	return raw, resolved, nil
+}
+
+// Watch 按 RefreshInterval 重新解析原始配置，密钥变化时以新配置调用 onChange，直到 ctx 结束
+func Watch(ctx context.Context, raw, current *config.Config, onChange func(*config.Config)) {
+	provider, err := New(raw.Secrets)
+	if err != nil {
+		klog.ErrorS(err, "Failed to create secrets provider, rotation disabled")
+		return
+	}
+
+	ticker := time.NewTicker(raw.Secrets.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next, err := raw.ResolveSecrets(ctx, provider)
+		if err != nil {
+			klog.ErrorS(err, "Failed to refresh secrets")
+			continue
+		}
+		if reflect.DeepEqual(next, current) {
+			continue
+		}
+
+		klog.InfoS("Secrets rotated, applying new configuration")
+		current = next
+		onChange(next)
+	}
+}
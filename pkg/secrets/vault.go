@@ -0,0 +1,181 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+const (
+	// vaultTimeout Vault 请求超时
+	vaultTimeout = 10 * time.Second
+	// serviceAccountToken 集群内 ServiceAccount token 文件
+	serviceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// Vault HashiCorp Vault 密钥提供者（支持 KV v1/v2）
+type Vault struct {
+	cfg    config.VaultConfig
+	client *http.Client
+
+	mu    sync.Mutex
+	token string // Kubernetes 登录获得的 token
+}
+
+// NewVault 创建 Vault 提供者，地址和 token 未配置时读取 VAULT_ADDR、VAULT_TOKEN
+func NewVault(cfg config.VaultConfig) (*Vault, error) {
+	if cfg.Address == "" {
+		cfg.Address = os.Getenv("VAULT_ADDR")
+	}
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("vault address is required")
+	}
+	if cfg.Token == "" {
+		cfg.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if cfg.Token == "" && cfg.TokenFile == "" && cfg.KubernetesRole == "" {
+		return nil, fmt.Errorf("vault auth is required: set token, token_file or kubernetes_role")
+	}
+	cfg.Address = strings.TrimSuffix(cfg.Address, "/")
+
+	return &Vault{
+		cfg:    cfg,
+		client: &http.Client{Timeout: vaultTimeout},
+	}, nil
+}
+
+// Read 读取 path 下的 key，KV v2 路径需包含 data/，如 secret/data/ai-agent
+func (v *Vault) Read(ctx context.Context, path, key string) (string, error) {
+	token, err := v.authToken(ctx, false)
+	if err != nil {
+		return "", err
+	}
+
+	body, status, err := v.do(ctx, http.MethodGet, "/v1/"+strings.TrimPrefix(path, "/"), token, nil)
+	if status == http.StatusForbidden && v.cfg.KubernetesRole != "" {
+		// token 过期，重新登录后重试
+		if token, err = v.authToken(ctx, true); err != nil {
+			return "", err
+		}
+		body, status, err = v.do(ctx, http.MethodGet, "/v1/"+strings.TrimPrefix(path, "/"), token, nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("read vault secret %s: %w", path, err)
+	}
+
+	var resp struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("decode vault secret %s: %w", path, err)
+	}
+
+	data := resp.Data
+	if nested, ok := data["data"].(map[string]any); ok {
+		// KV v2：实际数据位于 data.data
+		if _, isMeta := data["metadata"]; isMeta {
+			data = nested
+		}
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in vault secret %s", key, path)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	raw, _ := json.Marshal(value)
+	return string(raw), nil
+}
+
+// authToken 返回访问 Vault 的 token，renew 为 true 时重新登录
+func (v *Vault) authToken(ctx context.Context, renew bool) (string, error) {
+	if v.cfg.KubernetesRole == "" {
+		if v.cfg.TokenFile != "" {
+			data, err := os.ReadFile(v.cfg.TokenFile)
+			if err != nil {
+				return "", fmt.Errorf("read vault token file: %w", err)
+			}
+			return strings.TrimSpace(string(data)), nil
+		}
+		return v.cfg.Token, nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.token != "" && !renew {
+		return v.token, nil
+	}
+
+	jwt, err := os.ReadFile(serviceAccountToken)
+	if err != nil {
+		return "", fmt.Errorf("read service account token: %w", err)
+	}
+	payload, _ := json.Marshal(map[string]string{
+		"role": v.cfg.KubernetesRole,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+	body, _, err := v.do(ctx, http.MethodPost, "/v1/auth/"+v.cfg.KubernetesMount+"/login", "", payload)
+	if err != nil {
+		return "", fmt.Errorf("vault kubernetes login: %w", err)
+	}
+
+	var resp struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault kubernetes login: invalid response")
+	}
+
+	klog.InfoS("Logged in to vault", "address", v.cfg.Address, "role", v.cfg.KubernetesRole)
+	v.token = resp.Auth.ClientToken
+	return v.token, nil
+}
+
+// do 发送 Vault API 请求，返回响应体和状态码
+func (v *Vault) do(ctx context.Context, method, path, token string, payload []byte) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, v.cfg.Address+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, 0, fmt.Errorf("create request: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.cfg.Namespace)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, resp.StatusCode, nil
+}
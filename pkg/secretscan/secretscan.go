@@ -0,0 +1,181 @@
+// Package secretscan 在工具写入文件、应用补丁或提交代码之前扫描内容中的凭证（云厂商密钥、私钥、token 等），
+// 按配置拦截写入或在结果中附带警告
+package secretscan
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/filter"
+)
+
+// ErrSecretDetected 内容中包含疑似凭证，写入被拦截
+var ErrSecretDetected = errors.New("possible secret detected")
+
+const (
+	// ModeBlock 拦截写入
+	ModeBlock = "block"
+	// ModeWarn 允许写入，在结果中附带警告
+	ModeWarn = "warn"
+	// ModeOff 不扫描
+	ModeOff = "off"
+)
+
+// DefaultDetectors 默认使用的检测器，均为 filter 包注册的内置检测器
+var DefaultDetectors = []string{filter.DetectorAPIKey, filter.DetectorPrivateKey, filter.DetectorJWT}
+
+// Config 扫描配置
+type Config struct {
+	Mode      string   // block（默认）、warn 或 off
+	Detectors []string // 检测器名称，为空时使用 DefaultDetectors
+}
+
+// Finding 一处疑似凭证
+type Finding struct {
+	Detector string
+	Line     int    // 所在行号，从 1 开始
+	Excerpt  string // 脱敏后的片段，只保留开头几个字符
+}
+
+// String 返回便于阅读的描述
+func (f Finding) String() string {
+	return fmt.Sprintf("line %d: %s (%s)", f.Line, f.Detector, f.Excerpt)
+}
+
+// namedDetector 带名称的检测器
+type namedDetector struct {
+	name     string
+	detector filter.Detector
+}
+
+// Scanner 凭证扫描器，nil 表示不扫描
+type Scanner struct {
+	block     bool
+	detectors []namedDetector
+}
+
+// New 创建扫描器；mode 为 off 时返回 nil
+func New(cfg Config) (*Scanner, error) {
+	s := &Scanner{}
+	switch cfg.Mode {
+	case "", ModeBlock:
+		s.block = true
+	case ModeWarn:
+	case ModeOff:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("invalid secret scan mode %q", cfg.Mode)
+	}
+
+	names := cfg.Detectors
+	if len(names) == 0 {
+		names = DefaultDetectors
+	}
+	for _, name := range names {
+		d, ok := filter.LookupDetector(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown secret detector %q", name)
+		}
+		s.detectors = append(s.detectors, namedDetector{name: name, detector: d})
+	}
+
+	klog.InfoS("Secret scanning enabled", "mode", cfg.Mode, "detectors", names)
+	return s, nil
+}
+
+// Scan 返回内容中的所有疑似凭证，按位置排序
+func (s *Scanner) Scan(content string) []Finding {
+	if s == nil || content == "" {
+		return nil
+	}
+
+	type match struct {
+		name  string
+		start int
+		end   int
+	}
+	var matches []match
+	for _, d := range s.detectors {
+		for _, m := range d.detector.Find(content) {
+			if isReference(content[m[0]:m[1]]) {
+				continue
+			}
+			matches = append(matches, match{name: d.name, start: m[0], end: m[1]})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	findings := make([]Finding, 0, len(matches))
+	for _, m := range matches {
+		findings = append(findings, Finding{
+			Detector: m.name,
+			Line:     strings.Count(content[:m.start], "\n") + 1,
+			Excerpt:  mask(content[m.start:m.end]),
+		})
+	}
+	return findings
+}
+
+// Check 扫描即将写入 target 的内容：block 模式下发现凭证时返回包装了 ErrSecretDetected 的错误，
+// warn 模式下返回警告文本，没有发现时两者都为空
+func (s *Scanner) Check(target, content string) (string, error) {
+	findings := s.Scan(content)
+	if len(findings) == 0 {
+		return "", nil
+	}
+
+	descs := make([]string, 0, len(findings))
+	for _, f := range findings {
+		descs = append(descs, f.String())
+	}
+	summary := strings.Join(descs, "; ")
+
+	klog.InfoS("Possible secrets in content to be written", "target", target, "findings", len(findings), "block", s.block)
+	if s.block {
+		return "", fmt.Errorf("%w in %s: %s; remove the credential or reference it from an environment variable or secret store", ErrSecretDetected, target, summary)
+	}
+	return fmt.Sprintf("警告：%s 中疑似包含凭证（%s），请确认不会泄露", target, summary), nil
+}
+
+// referenceMarkers 引用外部凭证的写法，如 password: ${DB_PASSWORD}、token: env:GITHUB_TOKEN
+var referenceMarkers = []string{"${", "{{", "env:", "vault:", "secretKeyRef"}
+
+// isReference 匹配内容是否只是对环境变量、模板或密钥存储的引用
+func isReference(s string) bool {
+	for _, marker := range referenceMarkers {
+		if strings.Contains(s, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// mask 只保留开头 4 个字符，避免在结果和日志中再次暴露凭证
+func mask(s string) string {
+	if first, _, ok := strings.Cut(s, "\n"); ok {
+		s = first
+	}
+	runes := []rune(s)
+	if len(runes) > 4 {
+		runes = runes[:4]
+	}
+	return string(runes) + "***"
+}
+
+// PatchAdditions 返回 unified diff 中新增的内容，其他行替换为空行，使 Finding 的行号与补丁一致；
+// 删除的行不参与扫描，移除已泄露的凭证不会被拦截
+func PatchAdditions(patch string) string {
+	lines := strings.Split(patch, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++ ") {
+			lines[i] = line[1:]
+		} else {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,610 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// A2A（Agent-to-Agent）协议 v0.3 的 JSON-RPC 端点：消息提交为后台任务，contextId 对应对话 ID，
+// 回答作为任务的 artifact 返回，进度通过 message/stream 和 tasks/resubscribe 以 SSE 推送
+
+const (
+	// a2aProtocolVersion 实现的 A2A 协议版本
+	a2aProtocolVersion = "0.3.0"
+	// a2aPath JSON-RPC 端点路径
+	a2aPath = "/a2a"
+	// maxA2ABody JSON-RPC 请求体的大小上限，文件以 base64 内嵌在请求中
+	maxA2ABody = 32 << 20
+	// a2aCancelWait 取消进行中的任务时等待任务结束的最长时间
+	a2aCancelWait = 10 * time.Second
+)
+
+// agentCardPaths agent card 的发现路径，agent.json 为 0.3 之前的版本使用的路径
+var agentCardPaths = []string{"/.well-known/agent-card.json", "/.well-known/agent.json"}
+
+// JSON-RPC 和 A2A 定义的错误码
+const (
+	rpcParseError                   = -32700
+	rpcInvalidRequest               = -32600
+	rpcMethodNotFound               = -32601
+	rpcInvalidParams                = -32602
+	rpcInternalError                = -32603
+	a2aTaskNotFound                 = -32001
+	a2aTaskNotCancelable            = -32002
+	a2aPushNotificationNotSupported = -32003
+	a2aUnsupportedOperation         = -32004
+	a2aContentTypeNotSupported      = -32005
+)
+
+// rpcRequest JSON-RPC 请求
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse JSON-RPC 响应，Result 和 Error 只有一个
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError JSON-RPC 错误
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error 实现 error
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// a2aMessage A2A 消息
+type a2aMessage struct {
+	Kind      string    `json:"kind"` // 固定为 message
+	MessageID string    `json:"messageId"`
+	Role      string    `json:"role"` // user 或 agent
+	Parts     []a2aPart `json:"parts"`
+	ContextID string    `json:"contextId,omitempty"`
+	TaskID    string    `json:"taskId,omitempty"`
+}
+
+// a2aPart 消息或 artifact 的一部分：text、file 或 data
+type a2aPart struct {
+	Kind string          `json:"kind"`
+	Text string          `json:"text,omitempty"`
+	File *a2aFile        `json:"file,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// a2aFile 文件内容（base64）或地址
+type a2aFile struct {
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	Bytes    string `json:"bytes,omitempty"`
+	URI      string `json:"uri,omitempty"`
+}
+
+// a2aTask A2A 任务
+type a2aTask struct {
+	Kind      string        `json:"kind"` // 固定为 task
+	ID        string        `json:"id"`
+	ContextID string        `json:"contextId"`
+	Status    a2aStatus     `json:"status"`
+	Artifacts []a2aArtifact `json:"artifacts,omitempty"`
+}
+
+// a2aStatus 任务状态
+type a2aStatus struct {
+	State     string      `json:"state"`
+	Message   *a2aMessage `json:"message,omitempty"`
+	Timestamp string      `json:"timestamp,omitempty"`
+}
+
+// a2aArtifact 任务产出
+type a2aArtifact struct {
+	ArtifactID string    `json:"artifactId"`
+	Name       string    `json:"name,omitempty"`
+	Parts      []a2aPart `json:"parts"`
+}
+
+// a2aStatusUpdate 流式推送的任务状态变化，Final 为 true 时流结束
+type a2aStatusUpdate struct {
+	Kind      string    `json:"kind"` // 固定为 status-update
+	TaskID    string    `json:"taskId"`
+	ContextID string    `json:"contextId"`
+	Status    a2aStatus `json:"status"`
+	Final     bool      `json:"final"`
+}
+
+// a2aArtifactUpdate 流式推送的任务产出
+type a2aArtifactUpdate struct {
+	Kind      string      `json:"kind"` // 固定为 artifact-update
+	TaskID    string      `json:"taskId"`
+	ContextID string      `json:"contextId"`
+	Artifact  a2aArtifact `json:"artifact"`
+	LastChunk bool        `json:"lastChunk"`
+}
+
+// a2aSkill agent card 中的技能
+type a2aSkill struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Examples    []string `json:"examples,omitempty"`
+}
+
+// a2aEndpoint A2A 端点的配置
+type a2aEndpoint struct {
+	cfg    config.A2AConfig
+	server config.ServerConfig
+}
+
+// EnableA2A 启用 A2A 端点，需在 EnableAuth 之前调用：agent card 不需要认证，JSON-RPC 端点与其他 API 一样经过 OIDC 认证
+func (s *Server) EnableA2A(cfg config.A2AConfig, server config.ServerConfig) {
+	s.a2a = &a2aEndpoint{cfg: cfg, server: server}
+	for _, path := range agentCardPaths {
+		s.mux.HandleFunc(path, s.handleAgentCard)
+	}
+	s.mux.HandleFunc(a2aPath, s.handleA2A)
+}
+
+// handleAgentCard GET /.well-known/agent-card.json 返回 agent card：名称、地址、能力、认证方式和技能
+func (s *Server) handleAgentCard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	e := s.a2a
+	endpoint := e.cfg.URL
+	if endpoint == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		endpoint = scheme + "://" + r.Host + a2aPath
+	}
+
+	skills := make([]a2aSkill, 0, len(e.cfg.Skills))
+	for _, skill := range e.cfg.Skills {
+		skills = append(skills, a2aSkill{
+			ID:          skill.ID,
+			Name:        skill.Name,
+			Description: skill.Description,
+			Tags:        append([]string{}, skill.Tags...),
+			Examples:    skill.Examples,
+		})
+	}
+	card := map[string]any{
+		"protocolVersion":    a2aProtocolVersion,
+		"name":               e.server.Name,
+		"description":        e.cfg.Description,
+		"url":                endpoint,
+		"preferredTransport": "JSONRPC",
+		"version":            e.server.Version,
+		"capabilities": map[string]any{
+			"streaming":              true,
+			"pushNotifications":      false,
+			"stateTransitionHistory": false,
+		},
+		"defaultInputModes":  []string{"text/plain", "application/json", "image/*"},
+		"defaultOutputModes": []string{"text/plain", "application/json"},
+		"skills":             skills,
+	}
+	if issuer := e.server.Auth.Issuer; issuer != "" {
+		card["securitySchemes"] = map[string]any{
+			"oidc": map[string]any{
+				"type":             "openIdConnect",
+				"openIdConnectUrl": strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration",
+			},
+		}
+		card["security"] = []map[string][]string{{"oidc": {}}}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
+
+// handleA2A POST /a2a 处理 A2A 的 JSON-RPC 请求
+func (s *Server) handleA2A(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req rpcRequest
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxA2ABody))
+	if err == nil {
+		err = json.Unmarshal(body, &req)
+	}
+	if err != nil {
+		writeRPC(w, nil, nil, &rpcError{Code: rpcParseError, Message: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		writeRPC(w, req.ID, nil, &rpcError{Code: rpcInvalidRequest, Message: "invalid JSON-RPC 2.0 request"})
+		return
+	}
+
+	var result any
+	switch req.Method {
+	case "message/send":
+		result, err = s.a2aSend(r.Context(), req.Params)
+	case "message/stream":
+		s.a2aStream(w, r, req)
+		return
+	case "tasks/get":
+		result, err = s.a2aGetTask(r.Context(), req.Params)
+	case "tasks/cancel":
+		result, err = s.a2aCancelTask(r.Context(), req.Params)
+	case "tasks/resubscribe":
+		s.a2aResubscribe(w, r, req)
+		return
+	case "tasks/pushNotificationConfig/set", "tasks/pushNotificationConfig/get",
+		"tasks/pushNotificationConfig/list", "tasks/pushNotificationConfig/delete":
+		err = &rpcError{Code: a2aPushNotificationNotSupported, Message: "push notifications are not supported"}
+	default:
+		err = &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	writeRPC(w, req.ID, result, a2aError(err))
+}
+
+// a2aSend message/send：提交任务，configuration.blocking 为 true 时等待任务结束后返回
+func (s *Server) a2aSend(ctx context.Context, params json.RawMessage) (*a2aTask, error) {
+	var p struct {
+		Configuration struct {
+			Blocking bool `json:"blocking"`
+		} `json:"configuration"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	task, err := s.a2aSubmit(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if p.Configuration.Blocking {
+		task = s.waitTask(ctx, task)
+	}
+	return a2aTaskFrom(task), nil
+}
+
+// a2aSubmit 把 params 中的消息提交为后台聊天任务
+func (s *Server) a2aSubmit(ctx context.Context, params json.RawMessage) (*agent.Task, error) {
+	var p struct {
+		Message *a2aMessage `json:"message"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.Message == nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "message is required"}
+	}
+	if p.Message.TaskID != "" {
+		// 任务不会进入 input-required 状态，追问通过 contextId 在同一对话中提交新任务
+		return nil, &rpcError{Code: a2aUnsupportedOperation, Message: "tasks do not accept further messages, send a new message with the same contextId"}
+	}
+	req, err := chatRequestFromA2A(p.Message)
+	if err != nil {
+		return nil, err
+	}
+	req.Persona = s.a2a.cfg.Persona
+
+	task, err := s.agent.SubmitTask(ctx, req, agent.TaskOptions{})
+	if err != nil {
+		if !isRequestError(err) && !errors.Is(err, agent.ErrTooManyTasks) {
+			klog.ErrorS(err, "Failed to submit task", "protocol", "a2a")
+		}
+		return nil, err
+	}
+	klog.V(2).InfoS("A2A task submitted", "taskID", task.ID, "contextID", task.ConversationID)
+	return task, nil
+}
+
+// chatRequestFromA2A 把消息转为聊天请求：文本和 JSON 数据拼接为提问，图片随提问发送，文本文件的内容附在提问后
+func chatRequestFromA2A(msg *a2aMessage) (*agent.ChatRequest, error) {
+	req := &agent.ChatRequest{ConversationID: msg.ContextID}
+	var texts []string
+	for _, part := range msg.Parts {
+		switch part.Kind {
+		case "text":
+			texts = append(texts, part.Text)
+		case "data":
+			texts = append(texts, "```json\n"+string(part.Data)+"\n```")
+		case "file":
+			f := part.File
+			if f == nil || f.Bytes == "" {
+				return nil, &rpcError{Code: a2aContentTypeNotSupported, Message: "only files with inline bytes are supported"}
+			}
+			mediaType, _, _ := mime.ParseMediaType(f.MimeType)
+			switch {
+			case strings.HasPrefix(mediaType, "image/"):
+				req.Images = append(req.Images, f.Bytes)
+			case strings.HasPrefix(mediaType, "text/") || mediaType == "application/json":
+				data, err := base64.StdEncoding.DecodeString(f.Bytes)
+				if err != nil {
+					return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("invalid base64 in file %s", f.Name)}
+				}
+				texts = append(texts, fmt.Sprintf("文件 %s：\n```\n%s\n```", f.Name, data))
+			default:
+				return nil, &rpcError{Code: a2aContentTypeNotSupported, Message: "unsupported file type: " + f.MimeType}
+			}
+		default:
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "unknown part kind: " + part.Kind}
+		}
+	}
+	req.Message = strings.TrimSpace(strings.Join(texts, "\n\n"))
+	if req.Message == "" {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "message has no text"}
+	}
+	return req, nil
+}
+
+// a2aGetTask tasks/get：查询任务
+func (s *Server) a2aGetTask(ctx context.Context, params json.RawMessage) (*a2aTask, error) {
+	id, err := a2aTaskID(params)
+	if err != nil {
+		return nil, err
+	}
+	task, err := s.agent.GetTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return a2aTaskFrom(task), nil
+}
+
+// a2aCancelTask tasks/cancel：取消任务并等待其结束，已结束的任务返回 TaskNotCancelable
+func (s *Server) a2aCancelTask(ctx context.Context, params json.RawMessage) (*a2aTask, error) {
+	id, err := a2aTaskID(params)
+	if err != nil {
+		return nil, err
+	}
+	task, err := s.agent.GetTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task.Done() {
+		return nil, &rpcError{Code: a2aTaskNotCancelable, Message: fmt.Sprintf("task %s is already %s", id, task.Status)}
+	}
+	if task, err = s.agent.CancelTask(ctx, id); err != nil {
+		return nil, err
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, a2aCancelWait)
+	defer cancel()
+	return a2aTaskFrom(s.waitTask(waitCtx, task)), nil
+}
+
+// a2aTaskID 解析 params 中的任务 ID
+func a2aTaskID(params json.RawMessage) (string, error) {
+	var p struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.ID == "" {
+		return "", &rpcError{Code: rpcInvalidParams, Message: "task id is required"}
+	}
+	return p.ID, nil
+}
+
+// waitTask 等待任务结束，ctx 结束时返回最新的状态
+func (s *Server) waitTask(ctx context.Context, task *agent.Task) *agent.Task {
+	for !task.Done() {
+		_, info, changed, err := s.agent.TaskEvents(ctx, task.ID, task.Events)
+		if err != nil {
+			return task
+		}
+		task = info
+		if task.Done() {
+			break
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return task
+		}
+	}
+	return task
+}
+
+// a2aStream message/stream：提交任务后以 SSE 推送任务、状态变化和回答
+func (s *Server) a2aStream(w http.ResponseWriter, r *http.Request, req rpcRequest) {
+	task, err := s.a2aSubmit(r.Context(), req.Params)
+	if err != nil {
+		writeRPC(w, req.ID, nil, a2aError(err))
+		return
+	}
+	s.streamTask(w, r, req.ID, task, 0)
+}
+
+// a2aResubscribe tasks/resubscribe：断线后重新订阅任务，推送当前状态和之后的变化
+func (s *Server) a2aResubscribe(w http.ResponseWriter, r *http.Request, req rpcRequest) {
+	id, err := a2aTaskID(req.Params)
+	if err == nil {
+		var task *agent.Task
+		if task, err = s.agent.GetTask(r.Context(), id); err == nil {
+			s.streamTask(w, r, req.ID, task, task.Events)
+			return
+		}
+	}
+	writeRPC(w, req.ID, nil, a2aError(err))
+}
+
+// streamTask 以 SSE 推送任务：先推送任务本身，之后推送第 after 个开始的事件和状态变化，
+// 任务结束时推送回答和 final 状态后关闭连接
+func (s *Server) streamTask(w http.ResponseWriter, r *http.Request, rpcID json.RawMessage, task *agent.Task, after int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeRPC(w, rpcID, nil, &rpcError{Code: rpcInternalError, Message: "streaming not supported"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(result any) {
+		writeSSE(w, "", "", rpcResponse{JSONRPC: "2.0", ID: rpcID, Result: result})
+	}
+	send(a2aTaskFrom(task))
+	flusher.Flush()
+
+	state := a2aState(task.Status)
+	next := after
+	for {
+		events, info, changed, err := s.agent.TaskEvents(r.Context(), task.ID, next)
+		if err != nil {
+			return
+		}
+		for _, ev := range events {
+			next++
+			send(&a2aStatusUpdate{
+				Kind:      "status-update",
+				TaskID:    info.ID,
+				ContextID: info.ConversationID,
+				Status:    a2aStatus{State: "working", Message: a2aEventMessage(info, next, ev), Timestamp: time.Now().UTC().Format(time.RFC3339)},
+			})
+		}
+		if info.Done() {
+			t := a2aTaskFrom(info)
+			for _, artifact := range t.Artifacts {
+				send(&a2aArtifactUpdate{Kind: "artifact-update", TaskID: t.ID, ContextID: t.ContextID, Artifact: artifact, LastChunk: true})
+			}
+			send(&a2aStatusUpdate{Kind: "status-update", TaskID: t.ID, ContextID: t.ContextID, Status: t.Status, Final: true})
+			flusher.Flush()
+			return
+		}
+		if current := a2aState(info.Status); current != state {
+			state = current
+			send(&a2aStatusUpdate{Kind: "status-update", TaskID: info.ID, ContextID: info.ConversationID, Status: a2aTaskFrom(info).Status})
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// a2aTaskFrom 把后台任务转为 A2A 任务：成功时回答（和结构化数据、仓库分析报告）作为 answer artifact，失败时错误放在状态消息中
+func a2aTaskFrom(task *agent.Task) *a2aTask {
+	t := &a2aTask{
+		Kind:      "task",
+		ID:        task.ID,
+		ContextID: task.ConversationID,
+		Status:    a2aStatus{State: a2aState(task.Status), Timestamp: task.CreatedAt.UTC().Format(time.RFC3339)},
+	}
+	if !task.FinishedAt.IsZero() {
+		t.Status.Timestamp = task.FinishedAt.UTC().Format(time.RFC3339)
+	}
+	if task.Status == agent.TaskFailed {
+		t.Status.Message = a2aAgentMessage(task, "error", a2aPart{Kind: "text", Text: task.Error})
+	}
+
+	var parts []a2aPart
+	if res := task.Result; res != nil {
+		parts = append(parts, a2aPart{Kind: "text", Text: res.Response})
+		if len(res.Data) > 0 {
+			parts = append(parts, a2aPart{Kind: "data", Data: res.Data})
+		}
+	}
+	if task.Report != nil {
+		if data, err := json.Marshal(task.Report); err == nil {
+			parts = append(parts, a2aPart{Kind: "data", Data: data})
+		}
+	}
+	if task.Status == agent.TaskSucceeded && len(parts) > 0 {
+		t.Artifacts = []a2aArtifact{{ArtifactID: task.ID + "-answer", Name: "answer", Parts: parts}}
+	}
+	return t
+}
+
+// a2aState 任务状态对应的 A2A 状态
+func a2aState(status agent.TaskStatus) string {
+	switch status {
+	case agent.TaskQueued:
+		return "submitted"
+	case agent.TaskRunning:
+		return "working"
+	case agent.TaskSucceeded:
+		return "completed"
+	case agent.TaskFailed:
+		return "failed"
+	case agent.TaskCanceled:
+		return "canceled"
+	default:
+		return "unknown"
+	}
+}
+
+// a2aEventMessage 把任务的第 n 个事件转为状态消息：模型的中间回复和思考过程为文本，工具调用等为 data
+func a2aEventMessage(task *agent.Task, n int, ev agent.Event) *a2aMessage {
+	id := fmt.Sprintf("event-%d", n)
+	if ev.Type == agent.EventMessage || ev.Type == agent.EventReasoning {
+		return a2aAgentMessage(task, id, a2aPart{Kind: "text", Text: ev.Content})
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return nil
+	}
+	return a2aAgentMessage(task, id, a2aPart{Kind: "data", Data: data})
+}
+
+// a2aAgentMessage 生成任务中 agent 一方的消息，消息 ID 为 <任务 ID>-<id>
+func a2aAgentMessage(task *agent.Task, id string, parts ...a2aPart) *a2aMessage {
+	return &a2aMessage{
+		Kind:      "message",
+		MessageID: task.ID + "-" + id,
+		Role:      "agent",
+		Parts:     parts,
+		ContextID: task.ConversationID,
+		TaskID:    task.ID,
+	}
+}
+
+// a2aError 把 Agent 的错误转为 JSON-RPC 错误
+func a2aError(err error) *rpcError {
+	var rpcErr *rpcError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &rpcErr):
+		return rpcErr
+	case errors.Is(err, agent.ErrTaskNotFound):
+		return &rpcError{Code: a2aTaskNotFound, Message: err.Error()}
+	case isRequestError(err):
+		return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	default:
+		return &rpcError{Code: rpcInternalError, Message: err.Error()}
+	}
+}
+
+// writeRPC 写入 JSON-RPC 响应；协议错误也以 HTTP 200 返回
+func writeRPC(w http.ResponseWriter, id json.RawMessage, result any, rpcErr *rpcError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	resp := rpcResponse{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr}
+	if rpcErr != nil {
+		resp.Result = nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		klog.ErrorS(err, "Failed to encode response")
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+	"github.com/champly/ai-agent/pkg/auth"
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// publicPaths 不需要认证的路径
+var publicPaths = map[string]bool{
+	"/health":             true,
+	"/api/github/webhook": true, // 由 github.webhook_secret 校验签名
+	// A2A 的 agent card 供调用方发现 Agent 和认证方式
+	"/.well-known/agent-card.json": true,
+	"/.well-known/agent.json":      true,
+	// 内置聊天界面的页面，静态资源在 uiPrefix 下
+	"/": true,
+}
+
+// webhookPrefix 入站 webhook 路径前缀，由各 webhook 的 secret 校验，不使用 OIDC 认证
+const webhookPrefix = "/api/webhooks/"
+
+// EnableAuth 启用 OIDC/JWT 认证，需在 Start 之前调用；未配置 issuer 时不做认证
+func (s *Server) EnableAuth(ctx context.Context, cfg config.AuthConfig) error {
+	if cfg.Issuer == "" {
+		return nil
+	}
+
+	authenticator, err := auth.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	s.server.Handler = s.authenticate(authenticator, s.server.Handler)
+	return nil
+}
+
+// authenticate 校验请求的 token，并将用户身份写入请求 context，供对话、租户、工具策略等使用
+func (s *Server) authenticate(authenticator *auth.Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] || strings.HasPrefix(r.URL.Path, webhookPrefix) || strings.HasPrefix(r.URL.Path, uiPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := authenticator.Authenticate(r)
+		if err != nil {
+			klog.InfoS("Authentication failed", "path", r.URL.Path, "remoteAddr", r.RemoteAddr, "err", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ai-agent"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := agent.WithUser(r.Context(), user)
+		tenant, err := s.agent.TenantFor(ctx)
+		if err != nil {
+			klog.InfoS("Request rejected", "path", r.URL.Path, "user", user, "err", err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		var tenantName string
+		if tenant != nil {
+			tenantName = tenant.Name
+		}
+		klog.V(2).InfoS("Request authenticated", "path", r.URL.Path, "user", user, "tenant", tenantName)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+)
+
+// handleComplete 单次补全：不创建对话、不调用工具，适合分类、抽取等一次性任务
+func (s *Server) handleComplete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req agent.CompleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Prompt == "" {
+		http.Error(w, "prompt is required", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := s.agent.Complete(r.Context(), &req)
+	if clientGone(r, err) || writeQuotaError(w, err) || writeSaturatedError(w, err) {
+		return
+	}
+	if err != nil {
+		status := chatErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			klog.ErrorS(err, "Completion failed")
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		klog.ErrorS(err, "Failed to encode response")
+	}
+}
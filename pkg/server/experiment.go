@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+	"github.com/champly/ai-agent/pkg/experiment"
+	"github.com/champly/ai-agent/pkg/store"
+)
+
+// handleListExperiments 列出提示实验及各变体的统计
+func (s *Server) handleListExperiments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	experiments := s.agent.Experiments()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"experiments": experiments,
+		"count":       len(experiments),
+	})
+}
+
+// handleConversationFeedback 提交对对话最近一轮回答的反馈，计入该轮分配到的实验变体
+func (s *Server) handleConversationFeedback(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		Rating int `json:"rating"` // 1 为正面，-1 为负面
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		klog.ErrorS(err, "Failed to decode request")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Rating != 1 && req.Rating != -1 {
+		http.Error(w, "Rating must be 1 or -1", http.StatusBadRequest)
+		return
+	}
+
+	err := s.agent.RateConversation(r.Context(), id, req.Rating)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, agent.ErrConversationForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, experiment.ErrNotAssigned):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		klog.ErrorS(err, "Failed to record feedback", "conversationID", id)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
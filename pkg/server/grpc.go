@@ -0,0 +1,546 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+	"github.com/champly/ai-agent/pkg/auth"
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/quota"
+	"github.com/champly/ai-agent/pkg/server/pb"
+	"github.com/champly/ai-agent/pkg/store"
+	"github.com/champly/ai-agent/pkg/workerpool"
+)
+
+// GRPCServer gRPC API 服务器，与 HTTP API 共用同一个 Agent，认证、租户和错误的处理方式一致
+type GRPCServer struct {
+	pb.UnimplementedAgentServer
+	agent         *agent.Agent
+	addr          string
+	creds         credentials.TransportCredentials
+	authenticator *auth.Authenticator
+
+	mu     sync.Mutex
+	server *grpc.Server
+}
+
+// NewGRPCServer 创建 gRPC API 服务器
+func NewGRPCServer(addr string, ag *agent.Agent) *GRPCServer {
+	return &GRPCServer{agent: ag, addr: addr}
+}
+
+// EnableTLS 启用 TLS（可选 mTLS），需在 Start 之前调用；证书与 HTTP API 相同，文件更新后自动重新加载
+func (s *GRPCServer) EnableTLS(cfg config.TLSConfig) error {
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		return nil
+	}
+	reloader, err := newCertReloader(cfg)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := reloader.tlsConfig()
+	if err != nil {
+		return err
+	}
+	s.creds = credentials.NewTLS(tlsConfig)
+	return nil
+}
+
+// EnableAuth 启用 OIDC/JWT 认证，需在 Start 之前调用；token 放在 authorization 元数据中
+func (s *GRPCServer) EnableAuth(ctx context.Context, cfg config.AuthConfig) error {
+	if cfg.Issuer == "" {
+		return nil
+	}
+	authenticator, err := auth.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	s.authenticator = authenticator
+	return nil
+}
+
+// Start 启动服务器，直到 Stop 或监听失败
+func (s *GRPCServer) Start() error {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, err := s.authenticate(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := s.authenticate(ss.Context())
+			if err != nil {
+				return err
+			}
+			return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		}),
+	}
+	if s.creds != nil {
+		opts = append(opts, grpc.Creds(s.creds))
+	}
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.server = grpc.NewServer(opts...)
+	pb.RegisterAgentServer(s.server, s)
+	srv := s.server
+	s.mu.Unlock()
+
+	klog.InfoS("gRPC API server starting", "addr", s.addr, "tls", s.creds != nil)
+	return srv.Serve(l)
+}
+
+// Stop 停止服务器：等待进行中的调用结束，ctx 结束时强制断开
+func (s *GRPCServer) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	srv := s.server
+	s.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	klog.InfoS("gRPC API server stopping")
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		srv.Stop()
+		return ctx.Err()
+	}
+}
+
+// contextStream 替换了 context（写入用户身份）的服务端流
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context 实现 grpc.ServerStream
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate 校验 authorization 元数据中的 token，并将用户身份写入 context；未启用认证时不做处理
+func (s *GRPCServer) authenticate(ctx context.Context) (context.Context, error) {
+	if s.authenticator == nil {
+		return ctx, nil
+	}
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			header = values[0]
+		}
+	}
+	user, err := s.authenticator.AuthenticateHeader(ctx, header)
+	if err != nil {
+		klog.InfoS("Authentication failed", "protocol", "grpc", "err", err)
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	ctx = agent.WithUser(ctx, user)
+	if _, err := s.agent.TenantFor(ctx); err != nil {
+		klog.InfoS("Request rejected", "protocol", "grpc", "user", user, "err", err)
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return ctx, nil
+}
+
+// Chat 聊天，事件在对话循环中同步推送，最后推送回答
+func (s *GRPCServer) Chat(req *pb.ChatRequest, stream grpc.ServerStreamingServer[pb.ChatEvent]) error {
+	if req.GetMessage() == "" && req.GetTemplate() == "" {
+		return status.Error(codes.InvalidArgument, "message or template is required")
+	}
+	chatReq := chatRequestFromPB(req)
+	// 工具可能并行执行，事件回调需要串行发送
+	var mu sync.Mutex
+	chatReq.OnEvent = func(ev agent.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := stream.Send(&pb.ChatEvent{Payload: &pb.ChatEvent_Event{Event: eventToPB(ev)}}); err != nil {
+			klog.V(2).InfoS("Failed to send chat event", "err", err)
+		}
+	}
+
+	ctx := stream.Context()
+	var resp *agent.ChatResponse
+	var err error
+	if req.GetRag() {
+		resp, err = s.agent.ChatWithRAG(ctx, chatReq)
+	} else {
+		resp, err = s.agent.Chat(ctx, chatReq)
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			klog.ErrorS(err, "Chat failed", "protocol", "grpc", "conversationID", req.GetConversationId())
+		}
+		return grpcError(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return stream.Send(&pb.ChatEvent{Payload: &pb.ChatEvent_Response{Response: chatResponseToPB(resp)}})
+}
+
+// ListTools 返回当前用户可用的工具
+func (s *GRPCServer) ListTools(ctx context.Context, _ *pb.ListToolsRequest) (*pb.ListToolsResponse, error) {
+	resp := &pb.ListToolsResponse{}
+	for _, t := range s.agent.ListTools(ctx) {
+		tool := &pb.Tool{Name: t["name"], Description: t["description"], Source: t["source"]}
+		if caps := t["capabilities"]; caps != "" {
+			tool.Capabilities = strings.Split(caps, ",")
+		}
+		resp.Tools = append(resp.Tools, tool)
+	}
+	return resp, nil
+}
+
+// ListConversations 列出当前用户的对话
+func (s *GRPCServer) ListConversations(ctx context.Context, _ *pb.ListConversationsRequest) (*pb.ListConversationsResponse, error) {
+	summaries, err := s.agent.ListConversations(ctx)
+	if err != nil {
+		klog.ErrorS(err, "Failed to list conversations", "protocol", "grpc")
+		return nil, grpcError(err)
+	}
+	resp := &pb.ListConversationsResponse{}
+	for _, sum := range summaries {
+		resp.Conversations = append(resp.Conversations, &pb.ConversationSummary{
+			Id:           sum.ID,
+			Title:        sum.Title,
+			User:         sum.User,
+			CreatedAt:    timestamp(sum.CreatedAt),
+			UpdatedAt:    timestamp(sum.UpdatedAt),
+			MessageCount: int32(sum.MessageCount),
+		})
+	}
+	return resp, nil
+}
+
+// GetConversation 返回对话的完整消息
+func (s *GRPCServer) GetConversation(ctx context.Context, req *pb.GetConversationRequest) (*pb.Conversation, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "conversation id is required")
+	}
+	rec, err := s.agent.GetConversation(ctx, req.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	conv := &pb.Conversation{
+		Id:        rec.ID,
+		User:      rec.User,
+		CreatedAt: timestamp(rec.CreatedAt),
+		UpdatedAt: timestamp(rec.UpdatedAt),
+		Persona:   rec.Persona,
+		Language:  rec.Language,
+	}
+	for _, m := range rec.Messages {
+		conv.Messages = append(conv.Messages, messageToPB(m))
+	}
+	return conv, nil
+}
+
+// StopConversation 停止对话进行中的请求，被停止的 Chat 返回 ABORTED
+func (s *GRPCServer) StopConversation(ctx context.Context, req *pb.StopConversationRequest) (*pb.StopConversationResponse, error) {
+	if err := s.agent.StopConversation(ctx, req.GetId()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.StopConversationResponse{}, nil
+}
+
+// SubmitTask 提交后台聊天任务
+func (s *GRPCServer) SubmitTask(ctx context.Context, req *pb.SubmitTaskRequest) (*pb.Task, error) {
+	r := req.GetRequest()
+	if r.GetMessage() == "" && r.GetTemplate() == "" {
+		return nil, status.Error(codes.InvalidArgument, "message or template is required")
+	}
+	opts := agent.TaskOptions{RAG: r.GetRag()}
+	if req.GetRunAt() != nil {
+		opts.RunAt = req.GetRunAt().AsTime()
+	}
+	task, err := s.agent.SubmitTask(ctx, chatRequestFromPB(r), opts)
+	if err != nil {
+		if !isRequestError(err) {
+			klog.ErrorS(err, "Failed to submit task", "protocol", "grpc")
+		}
+		return nil, grpcError(err)
+	}
+	return taskToPB(task), nil
+}
+
+// GetTask 查询任务
+func (s *GRPCServer) GetTask(ctx context.Context, req *pb.GetTaskRequest) (*pb.Task, error) {
+	task, err := s.agent.GetTask(ctx, req.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return taskToPB(task), nil
+}
+
+// ListTasks 列出当前用户的任务
+func (s *GRPCServer) ListTasks(ctx context.Context, _ *pb.ListTasksRequest) (*pb.ListTasksResponse, error) {
+	resp := &pb.ListTasksResponse{}
+	for _, task := range s.agent.ListTasks(ctx) {
+		resp.Tasks = append(resp.Tasks, taskToPB(task))
+	}
+	return resp, nil
+}
+
+// CancelTask 取消任务
+func (s *GRPCServer) CancelTask(ctx context.Context, req *pb.CancelTaskRequest) (*pb.Task, error) {
+	task, err := s.agent.CancelTask(ctx, req.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return taskToPB(task), nil
+}
+
+// WatchTask 推送任务的事件，任务结束时推送最终状态后结束，与 GET /api/tasks/{id}/events 一致
+func (s *GRPCServer) WatchTask(req *pb.WatchTaskRequest, stream grpc.ServerStreamingServer[pb.TaskEvent]) error {
+	ctx := stream.Context()
+	next := max(int(req.GetAfter()), 0)
+	for {
+		events, task, changed, err := s.agent.TaskEvents(ctx, req.GetId(), next)
+		if err != nil {
+			return grpcError(err)
+		}
+		for _, ev := range events {
+			next++
+			if err := stream.Send(&pb.TaskEvent{Payload: &pb.TaskEvent_Event{Event: eventToPB(ev)}}); err != nil {
+				return err
+			}
+		}
+		if task.Done() {
+			return stream.Send(&pb.TaskEvent{Payload: &pb.TaskEvent_Task{Task: taskToPB(task)}})
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// grpcError 把 Agent 的错误转为 gRPC 状态，状态码与 HTTP API 的状态码对应；
+// 配额超限和 worker 全忙时附带 RetryInfo，对应 HTTP 的 Retry-After
+func grpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	st := status.New(grpcCode(err), err.Error())
+	var retryAfter time.Duration
+	var exceeded *quota.ExceededError
+	var saturated *workerpool.SaturatedError
+	switch {
+	case errors.As(err, &exceeded):
+		retryAfter = max(time.Until(exceeded.ResetAt), time.Second)
+	case errors.As(err, &saturated):
+		retryAfter = saturated.RetryAfter
+	}
+	if retryAfter > 0 {
+		if detailed, derr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); derr == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
+
+// grpcCode 错误对应的 gRPC 状态码
+func grpcCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, store.ErrNotFound), errors.Is(err, agent.ErrTaskNotFound):
+		return codes.NotFound
+	case errors.Is(err, agent.ErrConversationIdle):
+		return codes.FailedPrecondition
+	case errors.Is(err, agent.ErrTooManyTasks):
+		return codes.Unavailable
+	}
+	switch chatErrorStatus(err) {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// chatRequestFromPB 转换聊天请求，图片转为 base64
+func chatRequestFromPB(req *pb.ChatRequest) *agent.ChatRequest {
+	r := &agent.ChatRequest{
+		Message:          req.GetMessage(),
+		Template:         req.GetTemplate(),
+		Variables:        req.GetVariables(),
+		ConversationID:   req.GetConversationId(),
+		Model:            req.GetModel(),
+		Collection:       req.GetCollection(),
+		NoWait:           req.GetNoWait(),
+		Persona:          req.GetPersona(),
+		Language:         req.GetLanguage(),
+		Validators:       req.GetValidators(),
+		DenyCapabilities: req.GetDenyCapabilities(),
+		ImageIDs:         req.GetImageIds(),
+		Stop:             req.GetStop(),
+		References:       req.GetReferences(),
+	}
+	if req.GetSchema() != "" {
+		r.Schema = json.RawMessage(req.GetSchema())
+	}
+	for _, img := range req.GetImages() {
+		r.Images = append(r.Images, base64.StdEncoding.EncodeToString(img))
+	}
+	return r
+}
+
+// chatResponseToPB 转换聊天响应
+func chatResponseToPB(resp *agent.ChatResponse) *pb.ChatResponse {
+	if resp == nil {
+		return nil
+	}
+	r := &pb.ChatResponse{
+		Response:         resp.Response,
+		Reasoning:        resp.Reasoning,
+		Data:             string(resp.Data),
+		ValidationErrors: resp.ValidationErrors,
+		ConversationId:   resp.ConversationID,
+		Iterations:       int32(resp.Iterations),
+		Persona:          resp.Persona,
+		Language:         resp.Language,
+		Route:            resp.Route,
+		Model:            resp.Model,
+		Experiment:       resp.Experiment,
+		Variant:          resp.Variant,
+	}
+	for _, tc := range resp.ToolCalls {
+		r.ToolCalls = append(r.ToolCalls, &pb.ToolCall{Tool: tc.Tool, Arguments: jsonString(tc.Arguments), Result: tc.Result})
+	}
+	if m := resp.Metrics; m != nil {
+		r.Metrics = &pb.Metrics{
+			PromptTokens:     int32(m.PromptTokens),
+			CompletionTokens: int32(m.CompletionTokens),
+			PromptEvalMs:     m.PromptEvalMs,
+			EvalMs:           m.EvalMs,
+			LoadMs:           m.LoadMs,
+			TotalMs:          m.TotalMs,
+			TokensPerSecond:  m.TokensPerSecond,
+			DoneReason:       m.DoneReason,
+			Truncated:        m.Truncated,
+		}
+	}
+	if e := resp.Escalation; e != nil {
+		r.EscalationPath, r.EscalationReason = e.Path, e.Reason
+	}
+	for _, c := range resp.Citations {
+		r.Citations = append(r.Citations, &pb.Citation{
+			Index:      int32(c.Index),
+			DocumentId: c.DocumentID,
+			ChunkId:    c.ChunkID,
+			Collection: c.Collection,
+			Source:     c.Source,
+			Repo:       c.Repo,
+			Commit:     c.Commit,
+			Score:      c.Score,
+		})
+	}
+	return r
+}
+
+// eventToPB 转换对话事件
+func eventToPB(ev agent.Event) *pb.Event {
+	e := &pb.Event{
+		Type:    string(ev.Type),
+		Tool:    ev.Tool,
+		Result:  ev.Result,
+		Content: ev.Content,
+		Error:   ev.Error,
+	}
+	if ev.Arguments != nil {
+		e.Arguments = jsonString(ev.Arguments)
+	}
+	return e
+}
+
+// messageToPB 转换对话中的消息
+func messageToPB(m api.Message) *pb.Message {
+	msg := &pb.Message{Role: m.Role, Content: m.Content, Thinking: m.Thinking, ToolName: m.ToolName}
+	for _, tc := range m.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, &pb.ToolCall{Tool: tc.Function.Name, Arguments: jsonString(tc.Function.Arguments)})
+	}
+	return msg
+}
+
+// taskToPB 转换任务，失败时附带与同步调用对应的状态码
+func taskToPB(task *agent.Task) *pb.Task {
+	t := &pb.Task{
+		Id:             task.ID,
+		Kind:           task.Kind,
+		Status:         string(task.Status),
+		ConversationId: task.ConversationID,
+		Rag:            task.RAG,
+		CreatedAt:      timestamp(task.CreatedAt),
+		RunAt:          timestamp(task.RunAt),
+		FinishedAt:     timestamp(task.FinishedAt),
+		Attempts:       int32(task.Attempts),
+		Events:         int32(task.Events),
+		Result:         chatResponseToPB(task.Result),
+		Error:          task.Error,
+	}
+	if task.Report != nil {
+		t.Report = jsonString(task.Report)
+	}
+	if task.Status == agent.TaskFailed {
+		t.ErrorCode = int32(grpcCode(task.Err))
+	}
+	return t
+}
+
+// timestamp 转换时间，零值为 nil
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// jsonString 编码为 JSON 字符串，失败时为空
+func jsonString(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
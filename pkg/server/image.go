@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/images"
+)
+
+// maxMultipartOverhead multipart 上传时表单字段和边界额外占用的字节数上限
+const maxMultipartOverhead = 64 << 10
+
+// handleUploadImage 上传图片，请求体为图片内容或 multipart 表单的 file 字段，返回聊天请求 image_ids 中引用的 id
+func (s *Server) handleUploadImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := int64(s.agent.MaxImageBytes())
+	body := http.MaxBytesReader(w, r.Body, limit+maxMultipartOverhead)
+	var data []byte
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		r.Body = body
+		file, _, ferr := r.FormFile("file")
+		if ferr != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(ferr, &tooLarge) {
+				http.Error(w, "Image too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Missing file field: "+ferr.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, err = io.ReadAll(io.LimitReader(file, limit+1))
+	} else {
+		data, err = io.ReadAll(io.LimitReader(body, limit+1))
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Image too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read image", http.StatusBadRequest)
+		return
+	}
+
+	upload, err := s.agent.UploadImage(r.Context(), data)
+	if errors.Is(err, images.ErrInvalid) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		klog.ErrorS(err, "Failed to store image")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(upload)
+}
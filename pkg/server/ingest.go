@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+)
+
+// startIngest 提交后台导入任务，立即返回 202 和任务状态
+func (s *Server) startIngest(w http.ResponseWriter, r *http.Request, collection, source string) {
+	job, err := s.agent.StartIngest(r.Context(), collection, source)
+	if errors.Is(err, agent.ErrTenantForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, agent.ErrTooManyIngestJobs) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		klog.ErrorS(err, "Failed to start ingest job")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/rag/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		klog.ErrorS(err, "Failed to encode response")
+	}
+}
+
+// handleListIngests 列出当前用户的导入任务
+func (s *Server) handleListIngests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobs := s.agent.ListIngests(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"jobs":  jobs,
+		"count": len(jobs),
+	})
+}
+
+// handleIngest GET /api/rag/jobs/{id} 查询导入进度，DELETE 取消导入
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/rag/jobs/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var job *agent.IngestJob
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		job, err = s.agent.GetIngest(r.Context(), id)
+	case http.MethodDelete:
+		job, err = s.agent.CancelIngest(r.Context(), id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if errors.Is(err, agent.ErrIngestJobNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		klog.ErrorS(err, "Failed to get ingest job", "jobID", id)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
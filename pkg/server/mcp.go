@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// mcpPath Streamable HTTP 传输的 MCP 端点路径
+const mcpPath = "/mcp"
+
+// AskAgentInput ask_agent 工具的输入
+type AskAgentInput struct {
+	Question       string `json:"question" jsonschema:"要交给 Agent 处理的问题或任务，Agent 会自行调用工具（Kubernetes、文件、Prometheus 等）后回答"`
+	ConversationID string `json:"conversation_id,omitempty" jsonschema:"继续已有对话时传入上次返回的 conversation_id，为空时开始新对话"`
+	Persona        string `json:"persona,omitempty" jsonschema:"使用的人设，为空时使用默认人设"`
+	RAG            bool   `json:"rag,omitempty" jsonschema:"是否先检索知识库再回答"`
+}
+
+// AskAgentOutput ask_agent 工具的输出
+type AskAgentOutput struct {
+	Answer         string           `json:"answer" jsonschema:"Agent 的回答"`
+	ConversationID string           `json:"conversation_id" jsonschema:"对话 ID，追问时传入"`
+	ToolCalls      []string         `json:"tool_calls,omitempty" jsonschema:"回答过程中依次调用的工具"`
+	Citations      []agent.Citation `json:"citations,omitempty" jsonschema:"检索知识库时提供给模型的参考资料"`
+}
+
+// NewAgentMCPServer 创建把 Agent 本身作为 ask_agent 工具提供的 MCP 服务器，供 IDE 等 MCP 客户端把 Agent 当作后端调用。
+// 调用方的用户身份取自 context，与 HTTP API 一样受租户、配额和工具策略限制
+func NewAgentMCPServer(ag *agent.Agent, cfg config.MCPEndpointConfig, server config.ServerConfig) *mcp.Server {
+	srv := mcp.NewServer(&mcp.Implementation{Name: server.Name, Version: server.Version}, nil)
+	mcp.AddTool(srv, &mcp.Tool{
+		Name:        "ask_agent",
+		Description: "向运维 Agent 提问或委派任务。Agent 会自行规划并调用其工具（Kubernetes、文件、Prometheus、知识库等），返回最终回答；同一对话中追问时传入 conversation_id",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input AskAgentInput) (*mcp.CallToolResult, AskAgentOutput, error) {
+		return askAgent(ctx, ag, cfg, req, input)
+	})
+	return srv
+}
+
+// askAgent 处理 ask_agent 调用：客户端提供 progressToken 时把工具调用等事件作为进度通知推送
+func askAgent(ctx context.Context, ag *agent.Agent, cfg config.MCPEndpointConfig, req *mcp.CallToolRequest, input AskAgentInput) (*mcp.CallToolResult, AskAgentOutput, error) {
+	if input.Question == "" {
+		return nil, AskAgentOutput{}, fmt.Errorf("question is required")
+	}
+	klog.InfoS("MCP tool called: ask_agent", "conversationID", input.ConversationID, "user", agent.UserFromContext(ctx))
+
+	chatReq := &agent.ChatRequest{
+		Message:        input.Question,
+		ConversationID: input.ConversationID,
+		Persona:        input.Persona,
+	}
+	if chatReq.Persona == "" && input.ConversationID == "" {
+		chatReq.Persona = cfg.Persona
+	}
+	if token := req.Params.GetProgressToken(); token != nil {
+		// 工具可能并行执行，进度需要串行发送
+		var mu sync.Mutex
+		var progress float64
+		chatReq.OnEvent = func(ev agent.Event) {
+			message := progressMessage(ev)
+			if message == "" {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			progress++
+			if err := req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+				ProgressToken: token,
+				Progress:      progress,
+				Message:       message,
+			}); err != nil {
+				klog.V(2).InfoS("Failed to send progress notification", "err", err)
+			}
+		}
+	}
+
+	var resp *agent.ChatResponse
+	var err error
+	if input.RAG {
+		resp, err = ag.ChatWithRAG(ctx, chatReq)
+	} else {
+		resp, err = ag.Chat(ctx, chatReq)
+	}
+	if err != nil {
+		return nil, AskAgentOutput{}, err
+	}
+
+	out := AskAgentOutput{Answer: resp.Response, ConversationID: resp.ConversationID}
+	for _, tc := range resp.ToolCalls {
+		out.ToolCalls = append(out.ToolCalls, tc.Tool)
+	}
+	out.Citations = resp.Citations
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: resp.Response}}}, out, nil
+}
+
+// progressMessage 事件对应的进度说明，不需要通知的事件返回空
+func progressMessage(ev agent.Event) string {
+	switch ev.Type {
+	case agent.EventToolCall:
+		return "调用工具 " + ev.Tool
+	case agent.EventToolResult:
+		if ev.Error != "" {
+			return fmt.Sprintf("工具 %s 失败：%s", ev.Tool, ev.Error)
+		}
+		return "工具 " + ev.Tool + " 已返回"
+	case agent.EventEscalation:
+		return "升级到大模型：" + ev.Content
+	default:
+		return ""
+	}
+}
+
+// EnableMCP 在 /mcp 提供 Agent 的 MCP 端点（Streamable HTTP 传输），需在 EnableAuth 之前调用。
+// 使用无状态模式，每个请求独立处理，多副本部署时不需要会话保持
+func (s *Server) EnableMCP(cfg config.MCPEndpointConfig, server config.ServerConfig) {
+	srv := NewAgentMCPServer(s.agent, cfg, server)
+	handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return srv }, &mcp.StreamableHTTPOptions{Stateless: true})
+	s.mux.Handle(mcpPath, handler)
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/ollama"
+)
+
+// handleListModels 列出已查询到的模型信息：上下文长度、能力（tools、vision 等）和所属系列
+func (s *Server) handleListModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	models := s.agent.Models()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"default": s.agent.DefaultModel(),
+		"models":  models,
+		"count":   len(models),
+	})
+}
+
+// handleModelStats 返回各模型的调用次数、token 数、生成速度和因长度限制被截断的次数，
+// 启用升级策略时还返回小模型直接回答和升级到大模型的次数
+func (s *Server) handleModelStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, since := s.agent.ModelStats()
+	body := map[string]any{
+		"models": stats,
+		"since":  since,
+	}
+	if escalation, ok := s.agent.EscalationStats(); ok {
+		body["escalation"] = escalation
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		klog.ErrorS(err, "Failed to encode response")
+	}
+}
+
+// handleLoadModel 在已拉取模型的 Ollama 主机上预先加载模型
+func (s *Server) handleLoadModel(w http.ResponseWriter, r *http.Request) {
+	s.handleModelAction(w, r, "loaded", s.agent.LoadModel)
+}
+
+// handleUnloadModel 从 Ollama 主机的内存中卸载模型
+func (s *Server) handleUnloadModel(w http.ResponseWriter, r *http.Request) {
+	s.handleModelAction(w, r, "unloaded", s.agent.UnloadModel)
+}
+
+// handleModelAction 解析请求中的模型名称并执行 action，model 为空时使用默认模型
+func (s *Server) handleModelAction(w http.ResponseWriter, r *http.Request, status string, action func(context.Context, string) error) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Model string `json:"model"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Model == "" {
+		req.Model = s.agent.DefaultModel()
+	}
+
+	if err := action(r.Context(), req.Model); err != nil {
+		klog.ErrorS(err, "Failed to change model state", "model", req.Model, "status", status)
+		code := http.StatusBadGateway
+		if errors.Is(err, ollama.ErrNoHost) {
+			code = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"model":  req.Model,
+		"status": status,
+	})
+}
+
+// handleBackendStatus 返回各 Ollama 主机已加载的模型、显存占用和请求排队情况
+func (s *Server) handleBackendStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.agent.BackendStatus(r.Context())); err != nil {
+		klog.ErrorS(err, "Failed to encode response")
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+	"github.com/champly/ai-agent/pkg/profile"
+)
+
+// handleProfile GET /api/profile 返回当前用户的资料，PUT 替换资料，DELETE 清空资料。
+// 未启用认证时可以用 ?user= 指定用户（如聊天平台连接器中的用户），不指定时为匿名用户的资料
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if agent.UserFromContext(ctx) == "" {
+		ctx = agent.WithUser(ctx, r.URL.Query().Get("user"))
+	}
+
+	var p *profile.Profile
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		p, err = s.agent.GetProfile(ctx)
+	case http.MethodPut:
+		var req profile.Profile
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			klog.ErrorS(err, "Failed to decode request")
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		p, err = s.agent.UpdateProfile(ctx, &req)
+	case http.MethodDelete:
+		if err = s.agent.DeleteProfile(ctx); err == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch {
+	case errors.Is(err, profile.ErrDisabled):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, profile.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		klog.ErrorS(err, "Failed to handle profile request")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p)
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/prompt"
+)
+
+// handleListPrompts 列出已加载的提示模板和模板库的加载状态
+func (s *Server) handleListPrompts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	prompts, status := s.agent.ListPrompts()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"prompts": prompts,
+		"count":   len(prompts),
+		"status":  status,
+	})
+}
+
+// handlePrompt GET /api/prompts/{name} 获取提示模板，POST /api/prompts/{name}/render 按变量渲染（不调用模型）
+func (s *Server) handlePrompt(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/prompts/")
+	name, render := strings.CutSuffix(name, "/render")
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "Prompt name is required", http.StatusBadRequest)
+		return
+	}
+
+	var result any
+	var err error
+	switch {
+	case !render && r.Method == http.MethodGet:
+		result, err = s.agent.GetPrompt(name)
+	case render && r.Method == http.MethodPost:
+		var req struct {
+			Variables map[string]string `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			klog.ErrorS(err, "Failed to decode request")
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		result, err = s.agent.RenderPrompt(r.Context(), name, req.Variables)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch {
+	case errors.Is(err, prompt.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, prompt.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		klog.ErrorS(err, "Failed to render prompt", "prompt", name)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleListPersonas 列出配置的人设
+func (s *Server) handleListPersonas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	personas := s.agent.ListPersonas()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"personas": personas,
+		"count":    len(personas),
+	})
+}
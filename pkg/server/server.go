@@ -3,10 +3,26 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/champly/ai-agent/pkg/agent"
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/egress"
+	"github.com/champly/ai-agent/pkg/filter"
+	"github.com/champly/ai-agent/pkg/images"
+	"github.com/champly/ai-agent/pkg/locale"
+	"github.com/champly/ai-agent/pkg/policy"
+	"github.com/champly/ai-agent/pkg/prompt"
+	"github.com/champly/ai-agent/pkg/quota"
 	"github.com/champly/ai-agent/pkg/rag"
+	"github.com/champly/ai-agent/pkg/store"
+	"github.com/champly/ai-agent/pkg/validator"
+	"github.com/champly/ai-agent/pkg/workerpool"
 	"k8s.io/klog/v2"
 )
 
@@ -17,6 +33,9 @@ var _ = rag.SearchResult{}
 type Server struct {
 	agent  *agent.Agent
 	server *http.Server
+	mux    *http.ServeMux
+	tls    bool
+	a2a    *a2aEndpoint // 启用 A2A 端点时不为空
 }
 
 // NewServer 创建 API 服务器
@@ -26,14 +45,46 @@ func NewServer(addr string, ag *agent.Agent) *Server {
 	}
 
 	mux := http.NewServeMux()
+	s.mux = mux
 
 	// 路由
 	mux.HandleFunc("/api/chat", s.handleChat)
 	mux.HandleFunc("/api/chat/rag", s.handleChatWithRAG)
+	mux.HandleFunc("/api/chat/stream", s.handleChatStream)
+	mux.HandleFunc("/api/chat/batch", s.handleChatBatch)
+	mux.HandleFunc("/api/complete", s.handleComplete)
+	mux.HandleFunc("/api/tasks", s.handleTasks)
+	mux.HandleFunc("/api/tasks/", s.handleTask)
+	mux.HandleFunc("/api/tasks/analyze", s.handleAnalyzeTask)
+	mux.HandleFunc("/api/tasks/dead-letter", s.handleDeadTasks)
+	mux.HandleFunc("/api/tasks/dead-letter/", s.handleDeadTask)
+	mux.HandleFunc("/api/workflows", s.handleListWorkflows)
+	mux.HandleFunc("/api/workflows/", s.handleRunWorkflow)
+	mux.HandleFunc("/api/prompts", s.handleListPrompts)
+	mux.HandleFunc("/api/prompts/", s.handlePrompt)
+	mux.HandleFunc("/api/experiments", s.handleListExperiments)
+	mux.HandleFunc("/api/personas", s.handleListPersonas)
+	mux.HandleFunc("/api/images", s.handleUploadImage)
+	mux.HandleFunc("/api/models", s.handleListModels)
+	mux.HandleFunc("/api/models/load", s.handleLoadModel)
+	mux.HandleFunc("/api/models/stats", s.handleModelStats)
+	mux.HandleFunc("/api/backend/status", s.handleBackendStatus)
+	mux.HandleFunc("/api/models/unload", s.handleUnloadModel)
+	mux.HandleFunc("/api/webhooks/", s.handleWebhook)
+	mux.HandleFunc("/api/github/webhook", s.handleGitHubWebhook)
 	mux.HandleFunc("/api/rag/add", s.handleRAGAdd)
 	mux.HandleFunc("/api/rag/import", s.handleRAGImport)
+	mux.HandleFunc("/api/rag/ingest", s.handleRAGIngest)
 	mux.HandleFunc("/api/rag/search", s.handleRAGSearch)
+	mux.HandleFunc("/api/rag/jobs", s.handleListIngests)
+	mux.HandleFunc("/api/rag/jobs/", s.handleIngest)
+	mux.HandleFunc("/api/conversations", s.handleListConversations)
+	mux.HandleFunc("/api/conversations/", s.handleGetConversation)
 	mux.HandleFunc("/api/tools", s.handleListTools)
+	mux.HandleFunc("/api/tools/call", s.handleCallTool)
+	mux.HandleFunc("/api/tools/stats", s.handleToolStats)
+	mux.HandleFunc("/api/usage", s.handleUsage)
+	mux.HandleFunc("/api/profile", s.handleProfile)
 	mux.HandleFunc("/health", s.handleHealth)
 
 	s.server = &http.Server{
@@ -44,9 +95,35 @@ func NewServer(addr string, ag *agent.Agent) *Server {
 	return s
 }
 
+// EnableTLS 启用 TLS（可选 mTLS），需在 Start 之前调用；未配置证书时保持明文 HTTP
+func (s *Server) EnableTLS(cfg config.TLSConfig) error {
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		return nil
+	}
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return fmt.Errorf("tls cert_file and key_file must be set together")
+	}
+
+	reloader, err := newCertReloader(cfg)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := reloader.tlsConfig()
+	if err != nil {
+		return err
+	}
+	s.server.TLSConfig = tlsConfig
+	s.tls = true
+	return nil
+}
+
 // Start 启动服务器
 func (s *Server) Start() error {
-	klog.InfoS("HTTP API server starting", "addr", s.server.Addr)
+	klog.InfoS("HTTP API server starting", "addr", s.server.Addr, "tls", s.tls)
+	if s.tls {
+		// 证书由 TLSConfig 提供
+		return s.server.ListenAndServeTLS("", "")
+	}
 	return s.server.ListenAndServe()
 }
 
@@ -72,11 +149,30 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	klog.V(2).InfoS("Received chat request",
-		"message", req.Message,
+		"messageLength", len(req.Message),
 		"conversationID", req.ConversationID)
 
 	// 处理请求
 	resp, err := s.agent.Chat(r.Context(), &req)
+	if clientGone(r, err) || writeQuotaError(w, err) || writeSaturatedError(w, err) {
+		return
+	}
+	if errors.Is(err, agent.ErrConversationForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, agent.ErrConversationBusy) || errors.Is(err, agent.ErrTurnStopped) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, filter.ErrBlocked) || isRequestError(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, agent.ErrStructuredOutput) || errors.Is(err, agent.ErrOutputValidation) {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
 	if err != nil {
 		klog.ErrorS(err, "Chat failed")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -90,9 +186,208 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// batchItemResult 批量聊天中单条请求的结果
+type batchItemResult struct {
+	Index  int `json:"index"`
+	Status int `json:"status"` // 与单条 /api/chat 请求对应的 HTTP 状态码
+	*agent.ChatResponse
+	Error string `json:"error,omitempty"`
+}
+
+// handleChatBatch 批量处理互不相关的聊天请求，返回每条请求各自的结果或错误
+func (s *Server) handleChatBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Model       string               `json:"model,omitempty"` // 未单独指定模型的条目使用该模型
+		Concurrency int                  `json:"concurrency,omitempty"`
+		Items       []*agent.ChatRequest `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		klog.ErrorS(err, "Failed to decode request")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) == 0 {
+		http.Error(w, "At least one item is required", http.StatusBadRequest)
+		return
+	}
+	for i, item := range req.Items {
+		if item == nil || (item.Message == "" && item.Template == "") {
+			http.Error(w, fmt.Sprintf("Item %d: message or template is required", i), http.StatusBadRequest)
+			return
+		}
+		if item.Model == "" {
+			item.Model = req.Model
+		}
+	}
+
+	klog.V(2).InfoS("Received batch chat request", "items", len(req.Items), "concurrency", req.Concurrency)
+
+	results, err := s.agent.ChatBatch(r.Context(), req.Items, req.Concurrency)
+	if errors.Is(err, agent.ErrBatchTooLarge) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		klog.ErrorS(err, "Batch chat failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]batchItemResult, 0, len(results))
+	failed := 0
+	for i, res := range results {
+		item := batchItemResult{Index: i, Status: http.StatusOK, ChatResponse: res.Response}
+		if res.Err != nil {
+			item.Status = chatErrorStatus(res.Err)
+			item.Error = res.Err.Error()
+			failed++
+		}
+		items = append(items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"results":   items,
+		"succeeded": len(items) - failed,
+		"failed":    failed,
+	}); err != nil {
+		klog.ErrorS(err, "Failed to encode response")
+	}
+}
+
+// clientGone 请求因客户端断开而中止时记录日志并返回 true，此时不再写入响应。
+// 进行中的模型调用和工具调用随请求的 context 一起取消，Ollama 随即停止生成
+func clientGone(r *http.Request, err error) bool {
+	if err == nil || r.Context().Err() == nil {
+		return false
+	}
+	klog.InfoS("Client disconnected, request aborted", "path", r.URL.Path, "err", err)
+	return true
+}
+
+// chatErrorStatus 返回聊天错误对应的 HTTP 状态码，与 handleChat 的处理一致
+func chatErrorStatus(err error) int {
+	var exceeded *quota.ExceededError
+	var saturated *workerpool.SaturatedError
+	switch {
+	case errors.As(err, &exceeded):
+		return http.StatusTooManyRequests
+	case errors.As(err, &saturated):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, agent.ErrConversationForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, agent.ErrConversationBusy), errors.Is(err, agent.ErrTurnStopped):
+		return http.StatusConflict
+	case errors.Is(err, filter.ErrBlocked), isRequestError(err):
+		return http.StatusBadRequest
+	case errors.Is(err, agent.ErrStructuredOutput), errors.Is(err, agent.ErrOutputValidation):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// isRequestError 请求中的提示模板不存在、变量不合法、人设或校验规则未配置、输出 Schema 无法解析、语言或工具能力不支持、
+// 图片无效或不存在、模型不支持图片、停止序列不合法
+func isRequestError(err error) bool {
+	return errors.Is(err, prompt.ErrNotFound) || errors.Is(err, prompt.ErrInvalid) ||
+		errors.Is(err, agent.ErrPersonaNotFound) || errors.Is(err, agent.ErrInvalidSchema) ||
+		errors.Is(err, validator.ErrNotFound) || errors.Is(err, locale.ErrUnsupported) ||
+		errors.Is(err, agent.ErrUnknownCapability) || errors.Is(err, images.ErrInvalid) ||
+		errors.Is(err, images.ErrNotFound) || errors.Is(err, agent.ErrModelCapability) ||
+		errors.Is(err, agent.ErrInvalidStop)
+}
+
+// handleListConversations 列出所有对话
+func (s *Server) handleListConversations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conversations, err := s.agent.ListConversations(r.Context())
+	if err != nil {
+		klog.ErrorS(err, "Failed to list conversations")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"conversations": conversations,
+		"count":         len(conversations),
+	})
+}
+
+// handleGetConversation GET /api/conversations/{id} 获取单个对话的完整消息，
+// POST /api/conversations/{id}/stop 停止对话进行中的请求，POST /api/conversations/{id}/feedback 提交反馈
+func (s *Server) handleGetConversation(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/conversations/")
+	id, action, _ := strings.Cut(id, "/")
+	if id == "" {
+		http.Error(w, "Conversation ID is required", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case action == "stop" && r.Method == http.MethodPost:
+		s.handleStopConversation(w, r, id)
+		return
+	case action == "feedback" && r.Method == http.MethodPost:
+		s.handleConversationFeedback(w, r, id)
+		return
+	case action != "" && action != "stop" && action != "feedback":
+		http.NotFound(w, r)
+		return
+	case action != "" || r.Method != http.MethodGet:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rec, err := s.agent.GetConversation(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, agent.ErrConversationForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		klog.ErrorS(err, "Failed to get conversation", "conversationID", id)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rec)
+}
+
+// handleStopConversation 停止对话进行中的请求，被停止的请求返回 409，对话中追加取消标记
+func (s *Server) handleStopConversation(w http.ResponseWriter, r *http.Request, id string) {
+	err := s.agent.StopConversation(r.Context(), id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, agent.ErrConversationForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, agent.ErrConversationIdle):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		klog.ErrorS(err, "Failed to stop conversation", "conversationID", id)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
 // handleListTools 列出所有工具
 func (s *Server) handleListTools(w http.ResponseWriter, r *http.Request) {
-	tools := s.agent.ListTools()
+	tools := s.agent.ListTools(r.Context())
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]any{
@@ -102,6 +397,72 @@ func (s *Server) handleListTools(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleToolStats 返回可用工具的调用次数、失败率、耗时分位数和最近调用时间
+func (s *Server) handleToolStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, since := s.agent.ToolStats(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"tools": stats,
+		"since": since,
+	}); err != nil {
+		klog.ErrorS(err, "Failed to encode response")
+	}
+}
+
+// handleCallTool 直接调用工具（调试用途）
+func (s *Server) handleCallTool(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		klog.ErrorS(err, "Failed to decode request")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		http.Error(w, "Tool name is required", http.StatusBadRequest)
+		return
+	}
+
+	klog.V(2).InfoS("Received tool call request", "tool", req.Name)
+
+	result, err := s.agent.CallTool(r.Context(), req.Name, req.Arguments)
+	if writeQuotaError(w, err) {
+		return
+	}
+	if errors.Is(err, policy.ErrDenied) || errors.Is(err, filter.ErrBlocked) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, agent.ErrMCPServerBusy) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		klog.ErrorS(err, "Tool call failed", "tool", req.Name)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"tool":   req.Name,
+		"result": result,
+	})
+}
+
 // handleChatWithRAG 带 RAG 增强的聊天请求
 func (s *Server) handleChatWithRAG(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -118,11 +479,30 @@ func (s *Server) handleChatWithRAG(w http.ResponseWriter, r *http.Request) {
 	}
 
 	klog.V(2).InfoS("Received RAG chat request",
-		"message", req.Message,
+		"messageLength", len(req.Message),
 		"conversationID", req.ConversationID)
 
 	// 处理请求（top_k 从配置中获取）
 	resp, err := s.agent.ChatWithRAG(r.Context(), &req)
+	if clientGone(r, err) || writeQuotaError(w, err) || writeSaturatedError(w, err) {
+		return
+	}
+	if errors.Is(err, agent.ErrConversationForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, agent.ErrConversationBusy) || errors.Is(err, agent.ErrTurnStopped) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, filter.ErrBlocked) || isRequestError(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, agent.ErrStructuredOutput) || errors.Is(err, agent.ErrOutputValidation) {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
 	if err != nil {
 		klog.ErrorS(err, "RAG Chat failed")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -144,10 +524,11 @@ func (s *Server) handleRAGAdd(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		ID       string            `json:"id"`
-		Content  string            `json:"content"`
-		Chunks   []string          `json:"chunks,omitempty"` // 可选：预分块的内容
-		Metadata map[string]string `json:"metadata,omitempty"`
+		ID         string            `json:"id"`
+		Collection string            `json:"collection,omitempty"`
+		Content    string            `json:"content"`
+		Chunks     []string          `json:"chunks,omitempty"` // 可选：预分块的内容
+		Metadata   map[string]string `json:"metadata,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		klog.ErrorS(err, "Failed to decode request")
@@ -163,10 +544,10 @@ func (s *Server) handleRAGAdd(w http.ResponseWriter, r *http.Request) {
 	var err error
 	if len(req.Chunks) > 0 {
 		// 使用预分块的内容
-		err = s.agent.AddRAGDocumentChunks(r.Context(), req.ID, req.Chunks, req.Metadata)
+		err = s.agent.AddRAGDocumentChunks(r.Context(), req.Collection, req.ID, req.Chunks, req.Metadata)
 	} else if req.Content != "" {
 		// 自动分块
-		err = s.agent.AddRAGDocument(r.Context(), req.ID, req.Content, req.Metadata)
+		err = s.agent.AddRAGDocument(r.Context(), req.Collection, req.ID, req.Content, req.Metadata)
 	} else {
 		http.Error(w, "Content or chunks is required", http.StatusBadRequest)
 		return
@@ -193,7 +574,9 @@ func (s *Server) handleRAGSearch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Query string `json:"query"`
+		Query      string `json:"query"`
+		Collection string `json:"collection,omitempty"`
+		TopK       int    `json:"top_k,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		klog.ErrorS(err, "Failed to decode request")
@@ -206,8 +589,8 @@ func (s *Server) handleRAGSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// top_k 从配置中获取
-	results, err := s.agent.SearchRAG(r.Context(), req.Query)
+	// 未指定 top_k 时从配置中获取
+	results, err := s.agent.SearchRAG(r.Context(), req.Collection, req.Query, req.TopK)
 	if err != nil {
 		klog.ErrorS(err, "Failed to search RAG")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -216,19 +599,21 @@ func (s *Server) handleRAGSearch(w http.ResponseWriter, r *http.Request) {
 
 	// 构建响应
 	type searchResult struct {
-		ID       string            `json:"id"`
-		Content  string            `json:"content"`
-		Score    float32           `json:"score"`
-		Metadata map[string]string `json:"metadata,omitempty"`
+		ID         string            `json:"id"`
+		Collection string            `json:"collection"`
+		Content    string            `json:"content"`
+		Score      float32           `json:"score"`
+		Metadata   map[string]string `json:"metadata,omitempty"`
 	}
 
 	respResults := make([]searchResult, 0, len(results))
 	for _, r := range results {
 		respResults = append(respResults, searchResult{
-			ID:       r.Document.ID,
-			Content:  r.Document.Content,
-			Score:    r.Score,
-			Metadata: r.Document.Metadata,
+			ID:         r.Document.ID,
+			Collection: r.Document.Collection,
+			Content:    r.Document.Content,
+			Score:      r.Score,
+			Metadata:   r.Document.Metadata,
 		})
 	}
 
@@ -262,7 +647,12 @@ func (s *Server) handleRAGImport(w http.ResponseWriter, r *http.Request) {
 
 	klog.InfoS("Importing RAG documents from directory", "dir", req.Dir)
 
-	if err := s.agent.LoadRAGDocumentsFromDir(r.Context(), req.Dir); err != nil {
+	err := s.agent.LoadRAGDocumentsFromDir(r.Context(), req.Dir)
+	if errors.Is(err, agent.ErrTenantForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
 		klog.ErrorS(err, "Failed to import RAG documents")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -275,10 +665,137 @@ func (s *Server) handleRAGImport(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleRAGIngest 从文件、目录或 URL 导入 RAG 文档到指定集合
+func (s *Server) handleRAGIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Source     string `json:"source"`
+		Collection string `json:"collection,omitempty"`
+		// Async 在后台导入，立即返回 202 和任务，通过 /api/rag/jobs/{id} 查询进度
+		Async bool `json:"async,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		klog.ErrorS(err, "Failed to decode request")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Source == "" {
+		http.Error(w, "Source is required", http.StatusBadRequest)
+		return
+	}
+
+	klog.InfoS("Ingesting RAG documents", "source", req.Source, "collection", req.Collection, "async", req.Async)
+
+	if req.Async {
+		s.startIngest(w, r, req.Collection, req.Source)
+		return
+	}
+
+	loaded, err := s.agent.IngestRAG(r.Context(), req.Collection, req.Source)
+	if errors.Is(err, agent.ErrTenantForbidden) || errors.Is(err, egress.ErrDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		klog.ErrorS(err, "Failed to ingest RAG documents")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":        true,
+		"documents":      loaded,
+		"document_count": s.agent.RAGDocumentCount(),
+	})
+}
+
+// handleUsage 查询当前用户的用量和配额；未启用认证时通过 user 参数指定用户
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	if agent.UserFromContext(ctx) == "" {
+		user := r.URL.Query().Get("user")
+		if user == "" {
+			http.Error(w, "User is required", http.StatusBadRequest)
+			return
+		}
+		ctx = agent.WithUser(ctx, user)
+	}
+
+	report, err := s.agent.Usage(ctx)
+	if errors.Is(err, quota.ErrDisabled) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		klog.ErrorS(err, "Failed to get usage")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// writeQuotaError 超出配额时返回 429 和结构化的错误信息，返回是否已处理
+func writeQuotaError(w http.ResponseWriter, err error) bool {
+	var exceeded *quota.ExceededError
+	if !errors.As(err, &exceeded) {
+		return false
+	}
+
+	klog.InfoS("Quota exceeded", "user", exceeded.User, "period", exceeded.Period, "metric", exceeded.Metric)
+	retryAfter := int(time.Until(exceeded.ResetAt).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": exceeded.Error(),
+		"quota": exceeded,
+	})
+	return true
+}
+
+// writeSaturatedError worker 全忙且队列已满时返回 503 和 Retry-After，返回是否已处理
+func writeSaturatedError(w http.ResponseWriter, err error) bool {
+	var saturated *workerpool.SaturatedError
+	if !errors.As(err, &saturated) {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(max(int(saturated.RetryAfter.Seconds()), 1)))
+	http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	return true
+}
+
 // handleHealth 健康检查
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]any{
+		"status":   "ok",
+		"identity": s.agent.Identity(),
+		"leader":   s.agent.IsLeader(),
+		"ollama":   s.agent.OllamaHosts(),
+	}
+	if stats, ok := s.agent.CacheStats(); ok {
+		resp["cache"] = stats
+	}
+	if stats, ok := s.agent.ToolCacheStats(); ok {
+		resp["tool_cache"] = stats
+	}
+	if stats, ok := s.agent.WorkerStats(); ok {
+		resp["workers"] = stats
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "ok",
-	})
+	json.NewEncoder(w).Encode(resp)
 }
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+)
+
+// streamError 流式聊天开始推送后失败时 error 事件的内容
+type streamError struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"` // 与同步调用 /api/chat 对应的 HTTP 状态码
+}
+
+// handleChatStream POST /api/chat/stream 以 Server-Sent Events 推送聊天过程：工具调用等事件以事件类型为 SSE 事件名，
+// 结束时发送 done 事件（ChatResponse）。第一个事件之前失败时与 /api/chat 一样返回 HTTP 错误，之后失败时发送 error 事件
+func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var req struct {
+		agent.ChatRequest
+		RAG bool `json:"rag,omitempty"` // 按 /api/chat/rag 处理
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		klog.ErrorS(err, "Failed to decode request")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	klog.V(2).InfoS("Received chat stream request",
+		"messageLength", len(req.Message),
+		"conversationID", req.ConversationID,
+		"rag", req.RAG)
+
+	// 工具可能并行执行，事件需要串行写入
+	var mu sync.Mutex
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		started = true
+	}
+	req.OnEvent = func(ev agent.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		start()
+		writeSSE(w, "", string(ev.Type), ev)
+		flusher.Flush()
+	}
+
+	var resp *agent.ChatResponse
+	var err error
+	if req.RAG {
+		resp, err = s.agent.ChatWithRAG(r.Context(), &req.ChatRequest)
+	} else {
+		resp, err = s.agent.Chat(r.Context(), &req.ChatRequest)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if clientGone(r, err) {
+		return
+	}
+	if err != nil {
+		status := chatErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			klog.ErrorS(err, "Chat stream failed")
+		}
+		if started {
+			writeSSE(w, "", "error", streamError{Error: err.Error(), Status: status})
+			flusher.Flush()
+			return
+		}
+		if writeQuotaError(w, err) || writeSaturatedError(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	start()
+	writeSSE(w, "", "done", resp)
+	flusher.Flush()
+}
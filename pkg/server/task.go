@@ -0,0 +1,304 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+	"github.com/champly/ai-agent/pkg/analyzer"
+)
+
+// taskView 任务的响应格式
+type taskView struct {
+	*agent.Task
+	ErrorStatus int `json:"error_status,omitempty"` // 失败时与同步调用 /api/chat 对应的 HTTP 状态码
+}
+
+// newTaskView 转换为响应格式
+func newTaskView(task *agent.Task) taskView {
+	view := taskView{Task: task}
+	if task.Status == agent.TaskFailed {
+		view.ErrorStatus = chatErrorStatus(task.Err)
+	}
+	return view
+}
+
+// handleTasks POST 提交后台聊天任务，GET 列出当前用户的任务
+func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.handleSubmitTask(w, r)
+	case http.MethodGet:
+		tasks := s.agent.ListTasks(r.Context())
+		views := make([]taskView, 0, len(tasks))
+		for _, task := range tasks {
+			views = append(views, newTaskView(task))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"tasks": views,
+			"count": len(views),
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSubmitTask 提交后台聊天任务，立即返回 202 和任务 ID
+func (s *Server) handleSubmitTask(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		agent.ChatRequest
+		RAG   bool      `json:"rag,omitempty"`   // 按 /api/chat/rag 处理
+		RunAt time.Time `json:"run_at,omitzero"` // 定时执行（RFC 3339），为空时立即执行
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		klog.ErrorS(err, "Failed to decode request")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Message == "" && req.Template == "" {
+		http.Error(w, "Message or template is required", http.StatusBadRequest)
+		return
+	}
+
+	task, err := s.agent.SubmitTask(r.Context(), &req.ChatRequest, agent.TaskOptions{RAG: req.RAG, RunAt: req.RunAt})
+	if errors.Is(err, agent.ErrTooManyTasks) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if isRequestError(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		klog.ErrorS(err, "Failed to submit task")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/tasks/"+task.ID)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(newTaskView(task)); err != nil {
+		klog.ErrorS(err, "Failed to encode response")
+	}
+}
+
+// handleAnalyzeTask POST /api/tasks/analyze 提交仓库分析任务，立即返回 202 和任务 ID
+func (s *Server) handleAnalyzeTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		analyzer.Request
+		RunAt time.Time `json:"run_at,omitzero"` // 定时执行（RFC 3339），为空时立即执行
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		klog.ErrorS(err, "Failed to decode request")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	task, err := s.agent.SubmitAnalysis(r.Context(), &req.Request, agent.TaskOptions{RunAt: req.RunAt})
+	switch {
+	case errors.Is(err, agent.ErrAnalysisUnavailable):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, agent.ErrNoTenant):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, agent.ErrTooManyTasks):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		klog.ErrorS(err, "Failed to submit analysis task")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/tasks/"+task.ID)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(newTaskView(task)); err != nil {
+		klog.ErrorS(err, "Failed to encode response")
+	}
+}
+
+// handleTask GET /api/tasks/{id} 查询任务，DELETE 取消任务，GET /api/tasks/{id}/events 以 SSE 推送进度
+func (s *Server) handleTask(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/tasks/")
+	id, events := strings.CutSuffix(id, "/events")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Task ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var task *agent.Task
+	var err error
+	switch {
+	case events && r.Method == http.MethodGet:
+		s.handleTaskEvents(w, r, id)
+		return
+	case !events && r.Method == http.MethodGet:
+		task, err = s.agent.GetTask(r.Context(), id)
+	case !events && r.Method == http.MethodDelete:
+		task, err = s.agent.CancelTask(r.Context(), id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if errors.Is(err, agent.ErrTaskNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		klog.ErrorS(err, "Failed to get task", "taskID", id)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newTaskView(task))
+}
+
+// handleDeadTasks GET /api/tasks/dead-letter 列出当前用户转入死信的任务
+func (s *Server) handleDeadTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tasks, err := s.agent.ListDeadTasks(r.Context())
+	if err != nil {
+		klog.ErrorS(err, "Failed to list dead tasks")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"tasks": tasks,
+		"count": len(tasks),
+	})
+}
+
+// handleDeadTask POST /api/tasks/dead-letter/{id}/retry 重新执行死信中的任务，DELETE /api/tasks/dead-letter/{id} 删除
+func (s *Server) handleDeadTask(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/tasks/dead-letter/")
+	id, retry := strings.CutSuffix(id, "/retry")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Task ID is required", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case retry && r.Method == http.MethodPost:
+		task, err := s.agent.RetryDeadTask(r.Context(), id)
+		if errors.Is(err, agent.ErrTaskNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			klog.ErrorS(err, "Failed to retry dead task", "taskID", id)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/tasks/"+task.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(newTaskView(task))
+	case !retry && r.Method == http.MethodDelete:
+		err := s.agent.DiscardDeadTask(r.Context(), id)
+		if errors.Is(err, agent.ErrTaskNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			klog.ErrorS(err, "Failed to discard dead task", "taskID", id)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTaskEvents 以 Server-Sent Events 推送任务的工具调用进度，任务结束时发送 done 事件并关闭连接。
+// 断线重连时通过 Last-Event-ID（或 after 参数）从上次收到的事件之后继续
+func (s *Server) handleTaskEvents(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	after := r.URL.Query().Get("after")
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		after = lastID
+	}
+	next, _ := strconv.Atoi(after)
+	next = max(next, 0)
+
+	started := false
+	for {
+		events, task, changed, err := s.agent.TaskEvents(r.Context(), id, next)
+		if err != nil {
+			if !started {
+				if errors.Is(err, agent.ErrTaskNotFound) {
+					http.Error(w, err.Error(), http.StatusNotFound)
+				} else {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+				}
+			}
+			return
+		}
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+
+		for _, ev := range events {
+			next++
+			writeSSE(w, strconv.Itoa(next), string(ev.Type), ev)
+		}
+		if task.Done() {
+			writeSSE(w, "", "done", newTaskView(task))
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeSSE 写入一条 SSE 事件，id 为空时不设置事件 ID，event 为空时为默认的 message 事件
+func writeSSE(w http.ResponseWriter, id, event string, data any) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		klog.ErrorS(err, "Failed to encode event", "event", event)
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	if event != "" {
+		fmt.Fprintf(w, "event: %s\n", event)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", b)
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// reloadCheckInterval 检查证书文件变化的最小间隔
+const reloadCheckInterval = 10 * time.Second
+
+// certReloader 在证书、私钥或客户端 CA 文件更新后自动重新加载，无需重启服务
+type certReloader struct {
+	cfg config.TLSConfig
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTime   time.Time // 已加载文件中最新的修改时间
+	checked   time.Time // 上次检查时间
+}
+
+// newCertReloader 创建证书加载器并立即加载一次
+func newCertReloader(cfg config.TLSConfig) (*certReloader, error) {
+	r := &certReloader{cfg: cfg}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load 读取证书、私钥和客户端 CA
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load tls key pair: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		data, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client ca: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in client ca %s", r.cfg.ClientCAFile)
+		}
+	}
+
+	r.cert = &cert
+	r.clientCAs = pool
+	r.modTime = r.latestModTime()
+	r.checked = time.Now()
+	return nil
+}
+
+// latestModTime 返回相关文件中最新的修改时间
+func (r *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, path := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// current 返回当前证书和客户端 CA，文件有变化时重新加载（加载失败时继续使用旧证书）
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) >= reloadCheckInterval {
+		r.checked = time.Now()
+		if r.latestModTime().After(r.modTime) {
+			if err := r.load(); err != nil {
+				klog.ErrorS(err, "Failed to reload TLS certificate, keeping the previous one")
+			} else {
+				klog.InfoS("TLS certificate reloaded", "cert", r.cfg.CertFile)
+			}
+		}
+	}
+	return r.cert, r.clientCAs
+}
+
+// tlsConfig 构建服务端 TLS 配置，每次握手时取最新证书
+func (r *certReloader) tlsConfig() (*tls.Config, error) {
+	clientAuth, err := parseClientAuth(r.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, clientCAs := r.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientAuth:   clientAuth,
+				ClientCAs:    clientCAs,
+			}, nil
+		},
+	}, nil
+}
+
+// parseClientAuth 解析客户端证书校验模式，配置了 client_ca_file 时默认要求客户端证书
+func parseClientAuth(cfg config.TLSConfig) (tls.ClientAuthType, error) {
+	switch cfg.ClientAuth {
+	case "":
+		if cfg.ClientCAFile != "" {
+			return tls.RequireAndVerifyClientCert, nil
+		}
+		return tls.NoClientCert, nil
+	case "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		if cfg.ClientCAFile == "" {
+			return 0, fmt.Errorf("client_auth require needs client_ca_file")
+		}
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("unknown client_auth: %s", cfg.ClientAuth)
+	}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiPrefix 内置聊天界面静态资源的路径前缀
+const uiPrefix = "/ui/"
+
+//go:embed ui
+var uiFiles embed.FS
+
+// EnableUI 在 / 提供内置的聊天界面（单页应用，静态资源在 /ui/ 下），需在 EnableAuth 之前调用。
+// 页面和静态资源不需要认证，页面调用的 API 与其他客户端一样经过 server.auth 认证
+func (s *Server) EnableUI() {
+	assets, _ := fs.Sub(uiFiles, "ui")
+	s.mux.Handle(uiPrefix, http.StripPrefix(uiPrefix, http.FileServerFS(assets)))
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		http.ServeFileFS(w, r, assets, "index.html")
+	})
+}
@@ -0,0 +1,427 @@
+// AI Agent 内置聊天界面：通过 /api/chat/stream 实时展示工具调用过程，对话列表、模型和人设来自 HTTP API
+'use strict';
+
+const TOKEN_KEY = 'ai-agent-token';
+const $ = (id) => document.getElementById(id);
+
+const state = {
+  conversationID: '',
+  controller: null, // 进行中请求的 AbortController，停止时中断连接，服务端随之取消本轮
+};
+
+// api 调用 HTTP API，带上保存的 token；状态码不是 2xx 时抛出带错误信息的异常
+async function api(path, options = {}) {
+  const headers = Object.assign({}, options.headers);
+  const token = localStorage.getItem(TOKEN_KEY);
+  if (token) {
+    headers.Authorization = 'Bearer ' + token;
+  }
+  if (options.body) {
+    headers['Content-Type'] = 'application/json';
+  }
+  const resp = await fetch(path, Object.assign({}, options, { headers }));
+  if (resp.status === 401) {
+    setStatus('需要认证，请点击“设置 token”');
+    throw new Error('401 Unauthorized');
+  }
+  if (!resp.ok) {
+    const text = (await resp.text()).trim();
+    let message = text;
+    try {
+      message = JSON.parse(text).error || text;
+    } catch (e) {
+      // 纯文本错误
+    }
+    throw new Error(resp.status + ' ' + message);
+  }
+  return resp;
+}
+
+function setStatus(text) {
+  $('status').textContent = text || '';
+}
+
+function escapeHTML(text) {
+  return String(text).replace(/[&<>"']/g, (c) => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' })[c]);
+}
+
+// renderMarkdown 渲染回答中常用的 Markdown：代码块、行内代码、粗体和段落
+function renderMarkdown(text) {
+  return String(text).split('```').map((part, i) => {
+    if (i % 2 === 1) {
+      const newline = part.indexOf('\n');
+      const code = newline >= 0 ? part.slice(newline + 1) : part;
+      return '<pre><code>' + escapeHTML(code.replace(/\n$/, '')) + '</code></pre>';
+    }
+    return part.split(/\n{2,}/).filter((p) => p.trim()).map((p) => '<p>' + renderInline(p.trim()) + '</p>').join('');
+  }).join('');
+}
+
+function renderInline(text) {
+  return escapeHTML(text)
+    .replace(/`([^`]+)`/g, '<code>$1</code>')
+    .replace(/\*\*([^*]+)\*\*/g, '<strong>$1</strong>')
+    .replace(/\n/g, '<br>');
+}
+
+function formatJSON(value) {
+  if (value === undefined || value === null) {
+    return '';
+  }
+  return typeof value === 'string' ? value : JSON.stringify(value, null, 2);
+}
+
+function scrollToBottom() {
+  const box = $('messages');
+  box.scrollTop = box.scrollHeight;
+}
+
+// addMessage 追加一条消息，返回消息内容的容器
+function addMessage(role, label) {
+  const msg = document.createElement('div');
+  msg.className = 'msg ' + role;
+  msg.innerHTML = '<div class="role">' + escapeHTML(label) + '</div><div class="body"></div>';
+  $('messages').appendChild(msg);
+  scrollToBottom();
+  return msg.querySelector('.body');
+}
+
+function addUser(text) {
+  addMessage('user', '我').textContent = text;
+}
+
+function addError(text) {
+  addMessage('error', '错误').textContent = text;
+}
+
+// addDetails 在 parent 中追加可折叠的块（思考过程、工具调用等）
+function addDetails(parent, className, summary, content) {
+  const el = document.createElement('details');
+  el.className = className;
+  el.innerHTML = '<summary></summary><pre></pre>';
+  el.querySelector('summary').innerHTML = summary;
+  el.querySelector('pre').textContent = content;
+  parent.appendChild(el);
+  scrollToBottom();
+  return el;
+}
+
+// addTool 追加一次工具调用，结果返回后由 setToolResult 更新
+function addTool(parent, name, args) {
+  const summary = '工具 <code>' + escapeHTML(name) + '</code><span class="state">执行中</span>';
+  const el = addDetails(parent, 'tool running', summary, formatJSON(args));
+  el.dataset.tool = name;
+  return el;
+}
+
+function setToolResult(el, result, error) {
+  el.classList.remove('running');
+  el.classList.add(error ? 'failed' : 'done');
+  el.querySelector('.state').textContent = error ? '失败' : '完成';
+  const pre = document.createElement('pre');
+  pre.textContent = error || result || '（无输出）';
+  el.appendChild(pre);
+}
+
+// newTurn 追加一轮助手回答：steps 放思考过程和工具调用，answer 放回答
+function newTurn() {
+  const body = addMessage('assistant', 'Agent');
+  const steps = document.createElement('div');
+  const answer = document.createElement('div');
+  body.append(steps, answer);
+  return { body, steps, answer, answered: false };
+}
+
+function setAnswer(turn, content) {
+  turn.answer.innerHTML = renderMarkdown(content || '（空回答）');
+  turn.answered = true;
+  scrollToBottom();
+}
+
+// addCitations 在回答后列出检索知识库时提供给模型的参考资料
+function addCitations(turn, citations) {
+  const lines = citations.map((c) => {
+    let line = '[' + c.index + '] ' + (c.source || c.document_id);
+    if (c.commit) {
+      line += ' @ ' + c.commit.slice(0, 12);
+    }
+    return line + '（' + c.collection + '，相关度 ' + c.score.toFixed(2) + '）';
+  });
+  addDetails(turn.body, 'citations', '参考资料（' + citations.length + '）', lines.join('\n'));
+}
+
+function setMeta(turn, resp) {
+  const parts = [];
+  if (resp.model) {
+    parts.push(resp.model);
+  }
+  if (resp.persona) {
+    parts.push('人设 ' + resp.persona);
+  }
+  if (resp.iterations) {
+    parts.push(resp.iterations + ' 轮');
+  }
+  if (resp.metrics) {
+    parts.push((resp.metrics.prompt_tokens + resp.metrics.completion_tokens) + ' tokens');
+    if (resp.metrics.truncated) {
+      parts.push('回答被截断');
+    }
+  }
+  if (resp.escalation && resp.escalation.path === 'large') {
+    parts.push('已升级到大模型');
+  }
+  const meta = document.createElement('div');
+  meta.className = 'meta';
+  meta.textContent = parts.join(' · ');
+  turn.body.parentNode.appendChild(meta);
+}
+
+// handleEvent 处理流式聊天的一条事件
+function handleEvent(turn, event, data) {
+  switch (event) {
+    case 'tool_call':
+      addTool(turn.steps, data.tool, data.arguments);
+      break;
+    case 'tool_result': {
+      // 工具可能并行执行，结果对应最早一个同名且仍在执行的调用
+      const el = Array.from(turn.steps.querySelectorAll('details.tool.running')).find((d) => d.dataset.tool === data.tool);
+      if (el) {
+        setToolResult(el, data.result, data.error);
+      }
+      break;
+    }
+    case 'reasoning':
+      addDetails(turn.steps, 'thinking', '思考过程', data.content);
+      break;
+    case 'escalation':
+      addDetails(turn.steps, 'escalation', '升级到大模型', data.content);
+      break;
+    case 'message':
+      setAnswer(turn, data.content);
+      break;
+    case 'done':
+      if (!turn.answered) {
+        setAnswer(turn, data.response);
+      }
+      if (data.citations && data.citations.length) {
+        addCitations(turn, data.citations);
+      }
+      setMeta(turn, data);
+      state.conversationID = data.conversation_id;
+      break;
+    case 'error':
+      addError(data.error);
+      break;
+  }
+}
+
+// readEvents 逐条解析 Server-Sent Events 响应
+async function readEvents(resp, onEvent) {
+  const reader = resp.body.getReader();
+  const decoder = new TextDecoder();
+  let buffer = '';
+  for (;;) {
+    const { value, done } = await reader.read();
+    if (done) {
+      return;
+    }
+    buffer += decoder.decode(value, { stream: true });
+    let end;
+    while ((end = buffer.indexOf('\n\n')) >= 0) {
+      const block = buffer.slice(0, end);
+      buffer = buffer.slice(end + 2);
+      let event = 'message';
+      const data = [];
+      for (const line of block.split('\n')) {
+        if (line.startsWith('event:')) {
+          event = line.slice(6).trim();
+        } else if (line.startsWith('data:')) {
+          data.push(line.slice(5).replace(/^ /, ''));
+        }
+      }
+      if (data.length > 0) {
+        onEvent(event, JSON.parse(data.join('\n')));
+      }
+    }
+  }
+}
+
+function setBusy(busy) {
+  $('send').disabled = busy;
+  $('stop').hidden = !busy;
+}
+
+async function send(text) {
+  setStatus('');
+  addUser(text);
+  const turn = newTurn();
+  const req = { message: text, rag: $('rag').checked };
+  if (state.conversationID) {
+    req.conversation_id = state.conversationID;
+  }
+  if ($('model').value) {
+    req.model = $('model').value;
+  }
+  if ($('persona').value) {
+    req.persona = $('persona').value;
+  }
+
+  state.controller = new AbortController();
+  setBusy(true);
+  try {
+    const resp = await api('/api/chat/stream', { method: 'POST', body: JSON.stringify(req), signal: state.controller.signal });
+    await readEvents(resp, (event, data) => handleEvent(turn, event, data));
+  } catch (err) {
+    addError(err.name === 'AbortError' ? '已停止' : err.message);
+  } finally {
+    state.controller = null;
+    setBusy(false);
+    loadConversations();
+  }
+}
+
+// renderRecord 展示已保存的对话：工具调用与随后的 tool 消息按顺序对应
+function renderRecord(rec) {
+  $('messages').innerHTML = '';
+  let turn = null;
+  const pending = [];
+  for (const m of rec.messages || []) {
+    switch (m.role) {
+      case 'user':
+        addUser(m.content);
+        turn = null;
+        break;
+      case 'assistant':
+        if (!turn) {
+          turn = newTurn();
+        }
+        if (m.thinking) {
+          addDetails(turn.steps, 'thinking', '思考过程', m.thinking);
+        }
+        for (const call of m.tool_calls || []) {
+          pending.push(addTool(turn.steps, call.function.name, call.function.arguments));
+        }
+        if (m.content) {
+          setAnswer(turn, m.content);
+        }
+        break;
+      case 'tool': {
+        const el = pending.shift();
+        if (el) {
+          setToolResult(el, m.content, '');
+        }
+        break;
+      }
+    }
+  }
+}
+
+async function openConversation(id) {
+  if (state.controller) {
+    return;
+  }
+  setStatus('');
+  try {
+    const rec = await (await api('/api/conversations/' + encodeURIComponent(id))).json();
+    state.conversationID = rec.id;
+    $('persona').value = rec.persona || '';
+    renderRecord(rec);
+    highlightConversation();
+  } catch (err) {
+    setStatus(err.message);
+  }
+}
+
+function highlightConversation() {
+  for (const li of $('conversations').children) {
+    li.classList.toggle('active', li.dataset.id === state.conversationID);
+  }
+}
+
+async function loadConversations() {
+  try {
+    const data = await (await api('/api/conversations')).json();
+    const list = (data.conversations || []).sort((a, b) => b.updated_at.localeCompare(a.updated_at));
+    const ul = $('conversations');
+    ul.innerHTML = '';
+    for (const c of list) {
+      const li = document.createElement('li');
+      li.dataset.id = c.id;
+      li.title = c.title || c.id;
+      li.innerHTML = escapeHTML(c.title || c.id) + '<small>' + escapeHTML(new Date(c.updated_at).toLocaleString()) + '</small>';
+      li.addEventListener('click', () => openConversation(c.id));
+      ul.appendChild(li);
+    }
+    highlightConversation();
+  } catch (err) {
+    setStatus(err.message);
+  }
+}
+
+// loadOptions 加载可选的模型和人设
+async function loadOptions() {
+  try {
+    const models = await (await api('/api/models')).json();
+    const select = $('model');
+    select.length = 1;
+    select.options[0].textContent = models.default ? '默认（' + models.default + '）' : '默认';
+    for (const m of models.models || []) {
+      select.add(new Option(m.name, m.name));
+    }
+    const personas = await (await api('/api/personas')).json();
+    $('persona').length = 1;
+    for (const p of personas.personas || []) {
+      const option = new Option(p.name, p.name);
+      option.title = p.description || '';
+      $('persona').add(option);
+    }
+  } catch (err) {
+    setStatus(err.message);
+  }
+}
+
+function newConversation() {
+  if (state.controller) {
+    return;
+  }
+  state.conversationID = '';
+  $('persona').value = '';
+  $('messages').innerHTML = '';
+  highlightConversation();
+  $('input').focus();
+}
+
+$('composer').addEventListener('submit', (e) => {
+  e.preventDefault();
+  const text = $('input').value.trim();
+  if (!text || state.controller) {
+    return;
+  }
+  $('input').value = '';
+  send(text);
+});
+$('input').addEventListener('keydown', (e) => {
+  if (e.key === 'Enter' && !e.shiftKey && !e.isComposing) {
+    e.preventDefault();
+    $('composer').requestSubmit();
+  }
+});
+$('stop').addEventListener('click', () => state.controller && state.controller.abort());
+$('new-chat').addEventListener('click', newConversation);
+$('set-token').addEventListener('click', () => {
+  const token = prompt('OIDC token（启用 server.auth 时需要，留空清除）', localStorage.getItem(TOKEN_KEY) || '');
+  if (token === null) {
+    return;
+  }
+  if (token.trim()) {
+    localStorage.setItem(TOKEN_KEY, token.trim());
+  } else {
+    localStorage.removeItem(TOKEN_KEY);
+  }
+  setStatus('');
+  loadOptions();
+  loadConversations();
+});
+
+loadOptions();
+loadConversations();
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+	"github.com/champly/ai-agent/pkg/webhook"
+)
+
+// maxWebhookBody 入站 webhook 请求体的大小上限
+const maxWebhookBody = 1 << 20
+
+// handleWebhook POST /api/webhooks/{name} 接收外部事件，返回 202 和提交的任务。
+// 不经过 OIDC 认证，由 webhook 自己的 secret 校验来源
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/webhooks/")
+	if name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	tasks, err := s.agent.HandleWebhook(r.Context(), name, r.Header, body)
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, webhook.ErrUnauthorized):
+		klog.InfoS("Webhook rejected", "webhook", name, "remoteAddr", r.RemoteAddr, "err", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	case errors.Is(err, webhook.ErrInvalidPayload):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, agent.ErrTooManyTasks):
+		// 已提交的任务继续执行，发送方重试时未处理的事件会再次提交
+		klog.InfoS("Webhook events dropped", "webhook", name, "submitted", len(tasks), "err", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		klog.ErrorS(err, "Failed to handle webhook", "webhook", name)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{
+		"tasks": ids,
+		"count": len(ids),
+	})
+}
+
+// handleGitHubWebhook POST /api/github/webhook 接收 GitHub 的拉取请求事件，需要审查时提交审查任务，返回 202 和提交的任务。
+// 不经过 OIDC 认证，由 github.webhook_secret 校验签名
+func (s *Server) handleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// GitHub 的拉取请求事件负载较大
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 25<<20))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	task, err := s.agent.HandleGitHubWebhook(r.Context(), r.Header, body)
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, webhook.ErrUnauthorized):
+		klog.InfoS("GitHub webhook rejected", "remoteAddr", r.RemoteAddr, "delivery", r.Header.Get("X-GitHub-Delivery"))
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	case errors.Is(err, webhook.ErrInvalidPayload):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, agent.ErrTooManyTasks):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		klog.ErrorS(err, "Failed to handle GitHub webhook")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ids := []string{}
+	if task != nil {
+		ids = append(ids, task.ID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{
+		"tasks": ids,
+		"count": len(ids),
+	})
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/workflow"
+)
+
+// handleListWorkflows 列出已加载的工作流定义
+func (s *Server) handleListWorkflows(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workflows := s.agent.ListWorkflows()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"workflows": workflows,
+		"count":     len(workflows),
+	})
+}
+
+// handleRunWorkflow POST /api/workflows/{name}/run 同步执行工作流，返回各步骤的输出和最终输出
+func (s *Server) handleRunWorkflow(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/workflows/"), "/run")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Inputs map[string]string `json:"inputs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		klog.ErrorS(err, "Failed to decode request")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	klog.V(2).InfoS("Received workflow run request", "workflow", name, "inputs", len(req.Inputs))
+
+	res, err := s.agent.RunWorkflow(r.Context(), name, req.Inputs, nil)
+	if errors.Is(err, workflow.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, workflow.ErrInvalidInput) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 未开始执行（配额、worker 或输入过滤）
+	if res == nil && err != nil {
+		if writeQuotaError(w, err) || writeSaturatedError(w, err) {
+			return
+		}
+		klog.ErrorS(err, "Workflow failed", "workflow", name)
+		http.Error(w, err.Error(), chatErrorStatus(err))
+		return
+	}
+
+	// 步骤失败时同时返回已执行步骤的结果和撤销记录
+	status := http.StatusOK
+	body := map[string]any{"workflow": res.Workflow, "output": res.Output, "steps": res.Steps}
+	if len(res.Rollback) > 0 {
+		body["rollback"] = res.Rollback
+	}
+	if err != nil {
+		klog.ErrorS(err, "Workflow failed", "workflow", name)
+		status = chatErrorStatus(err)
+		body["error"] = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		klog.ErrorS(err, "Failed to encode response")
+	}
+}
@@ -0,0 +1,190 @@
+package shelltools
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// gitCommit 从命令中识别出的 git commit 调用
+//
+// 识别是尽力而为的近似解析，用于在提交前提醒或拦截凭证，不是安全边界：
+// 不展开变量、别名和 glob，也不解析 sh -c、脚本或 git 别名中的提交
+type gitCommit struct {
+	found    bool // 某条命令的 git 子命令为 commit
+	all      bool // 带 -a/--all，已跟踪文件的未暂存改动也会被提交
+	compound bool // 命令包含多条命令（;、&&、||、|、&、换行、子 shell）或命令替换
+}
+
+var (
+	// commandPrefixes 出现在命令名之前、不改变实际执行程序的关键字和包装命令
+	commandPrefixes = []string{"!", "{", "if", "then", "else", "elif", "do", "while", "until", "time", "command", "exec", "env", "nohup", "nice", "sudo"}
+	// gitValueOptions git 自身的选项中带独立参数值的选项，如 git -c k=v commit
+	gitValueOptions = []string{"-c", "-C", "--git-dir", "--work-tree", "--namespace", "--config-env", "--super-prefix"}
+)
+
+// parseGitCommit 按 shell 的引号规则将命令拆分为多条简单命令，查找子命令为 commit 的 git 调用。
+// 命令名为 git（含路径）或未展开的变量（如 $GIT）时视为 git，跳过 -c k=v、-C dir 等选项后取第一个子命令
+func parseGitCommit(command string) gitCommit {
+	segments, compound := splitShell(strings.TrimSpace(command))
+	c := gitCommit{compound: compound}
+	for _, words := range segments {
+		args, ok := gitArgs(words)
+		if !ok || len(args) == 0 || args[0] != "commit" {
+			continue
+		}
+		c.found = true
+		for _, arg := range args[1:] {
+			if arg == "--" {
+				break
+			}
+			if arg == "--all" || (strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "a")) {
+				c.all = true
+			}
+		}
+	}
+	return c
+}
+
+// gitArgs 返回 git 调用中子命令及其后的参数；命令不是 git 时返回 false
+func gitArgs(words []string) ([]string, bool) {
+	i := 0
+	// 跳过前置的环境变量赋值、关键字和包装命令（及包装命令的选项）
+	for wrapped := false; i < len(words); i++ {
+		w := words[i]
+		if slices.Contains(commandPrefixes, w) {
+			wrapped = true
+		} else if !isAssignment(w) && !(wrapped && strings.HasPrefix(w, "-")) {
+			break
+		}
+	}
+	if i == len(words) {
+		return nil, false
+	}
+	if name := words[i]; filepath.Base(name) != "git" && !strings.HasPrefix(name, "$") {
+		return nil, false
+	}
+	for i++; i < len(words); i++ {
+		w := words[i]
+		if !strings.HasPrefix(w, "-") {
+			return words[i:], true
+		}
+		if slices.Contains(gitValueOptions, w) {
+			i++
+		}
+	}
+	return nil, true
+}
+
+// isAssignment 判断是否为命令前的环境变量赋值，如 GIT_DIR=.git
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && (i == 0 || !('0' <= r && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitShell 在引号之外的 ;、&、|、换行、括号和命令替换处将命令拆分为多条简单命令，
+// 每条命令按单引号、双引号和反斜杠的规则拆分为参数；双引号内的命令替换同样作为单独的命令。
+// compound 表示出现了上述任一分隔符
+func splitShell(command string) (segments [][]string, compound bool) {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+		quote  rune
+		// subst 未结束的命令替换，记录结束符和进入替换前的引号，替换内的命令不受外层引号影响
+		subst []struct{ closer, quote rune }
+	)
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endSegment := func() {
+		compound = true
+		endWord()
+		if len(words) > 0 {
+			segments = append(segments, words)
+			words = nil
+		}
+	}
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		// 命令替换在双引号内外都会执行
+		if quote != '\'' {
+			if n := len(subst); n > 0 && quote == 0 && r == subst[n-1].closer {
+				endSegment()
+				quote = subst[n-1].quote
+				subst = subst[:n-1]
+				continue
+			}
+			if r == '`' || (r == '$' && i+1 < len(runes) && runes[i+1] == '(') {
+				endSegment()
+				closer := ')'
+				if r == '$' {
+					i++
+				} else {
+					closer = '`'
+				}
+				subst = append(subst, struct{ closer, quote rune }{closer, quote})
+				quote = 0
+				continue
+			}
+		}
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+				inWord = true
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\", runes[i+1]):
+				i++
+				word.WriteRune(runes[i])
+				inWord = true
+			default:
+				word.WriteRune(r)
+				inWord = true
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] != '\n' {
+					word.WriteRune(runes[i])
+					inWord = true
+				}
+			}
+		case strings.ContainsRune(";&|\n()", r):
+			endSegment()
+		case r == ' ' || r == '\t':
+			endWord()
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	endWord()
+	if len(words) > 0 {
+		segments = append(segments, words)
+	}
+	return segments, compound
+}
@@ -0,0 +1,299 @@
+// Package shelltools 提供执行 shell 命令和应用补丁的 MCP 工具，命令可以按工具选择在沙箱中执行
+package shelltools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/sandbox"
+	"github.com/champly/ai-agent/pkg/secretscan"
+)
+
+const (
+	// ToolShell 执行 shell 命令的工具名称
+	ToolShell = "run_shell"
+	// ToolPatch 应用补丁的工具名称
+	ToolPatch = "apply_patch"
+
+	// defaultTimeout 默认命令超时
+	defaultTimeout = 60 * time.Second
+	// defaultMaxOutput 默认输出上限（字节）
+	defaultMaxOutput = 64 << 10
+)
+
+// Config shell 工具集配置
+type Config struct {
+	Root      string                      // 工作根目录，命令只能在该目录及其子目录中执行
+	Sandboxes map[string]*sandbox.Sandbox // 每个工具使用的沙箱，未配置的工具不使用沙箱
+	Timeout   time.Duration               // 单条命令最大执行时间
+	MaxOutput int                         // stdout/stderr 各自的最大返回字节数
+	Scanner   *secretscan.Scanner         // 补丁和 git commit 的凭证扫描，为空时不扫描
+}
+
+// Toolset shell 工具集
+type Toolset struct {
+	cfg Config
+}
+
+// New 创建 shell 工具集
+func New(cfg Config) (*Toolset, error) {
+	root, err := filepath.Abs(cfg.Root)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("root %s is not a directory", root)
+	}
+	cfg.Root = root
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxOutput <= 0 {
+		cfg.MaxOutput = defaultMaxOutput
+	}
+
+	sandboxes := make(map[string]*sandbox.Sandbox, 2)
+	for _, tool := range []string{ToolShell, ToolPatch} {
+		sb := cfg.Sandboxes[tool]
+		if sb == nil {
+			if sb, err = sandbox.New(sandbox.Config{Backend: sandbox.BackendNone, Workdir: root}); err != nil {
+				return nil, err
+			}
+		}
+		sandboxes[tool] = sb
+		klog.InfoS("Shell tool created", "tool", tool, "root", root, "sandbox", sb.Backend())
+	}
+	cfg.Sandboxes = sandboxes
+	return &Toolset{cfg: cfg}, nil
+}
+
+// ShellInput run_shell 的输入
+type ShellInput struct {
+	Command        string `json:"command" jsonschema:"要执行的 shell 命令，通过 sh -c 执行"`
+	Workdir        string `json:"workdir,omitempty" jsonschema:"工作目录，相对于根目录，默认为根目录"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty" jsonschema:"超时时间（秒），不超过服务端上限"`
+}
+
+// PatchInput apply_patch 的输入
+type PatchInput struct {
+	Patch   string `json:"patch" jsonschema:"unified diff 格式的补丁内容"`
+	Strip   *int   `json:"strip,omitempty" jsonschema:"去掉路径前缀的层数（patch -p），默认为 1，适用于 git diff 的 a/ b/ 前缀"`
+	Workdir string `json:"workdir,omitempty" jsonschema:"应用补丁的目录，相对于根目录，默认为根目录"`
+	DryRun  bool   `json:"dry_run,omitempty" jsonschema:"只检查补丁能否应用，不修改文件"`
+}
+
+// CommandOutput 命令执行结果
+type CommandOutput struct {
+	Stdout    string `json:"stdout" jsonschema:"标准输出"`
+	Stderr    string `json:"stderr,omitempty" jsonschema:"标准错误"`
+	ExitCode  int    `json:"exit_code" jsonschema:"退出码"`
+	Truncated bool   `json:"truncated,omitempty" jsonschema:"输出是否被截断"`
+	Sandbox   string `json:"sandbox" jsonschema:"执行命令使用的沙箱类型"`
+	Warning   string `json:"warning,omitempty" jsonschema:"安全警告，如写入或提交的内容疑似包含凭证"`
+}
+
+// Register 将工具注册到 MCP Server
+func (t *Toolset) Register(server *mcp.Server) {
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        ToolShell,
+		Description: fmt.Sprintf("在工作目录 %s 中执行 shell 命令（沙箱：%s），用于编译、运行测试、查看文件等", t.cfg.Root, t.backend(ToolShell)),
+	}, t.handleShell)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        ToolPatch,
+		Description: fmt.Sprintf("将 unified diff 格式的补丁应用到工作目录 %s 中的文件（沙箱：%s）", t.cfg.Root, t.backend(ToolPatch)),
+	}, t.handlePatch)
+}
+
+// handleShell 执行 shell 命令
+func (t *Toolset) handleShell(ctx context.Context, req *mcp.CallToolRequest, input ShellInput) (*mcp.CallToolResult, CommandOutput, error) {
+	klog.InfoS("MCP tool called: run_shell", "command", input.Command, "workdir", input.Workdir, "sandbox", t.backend(ToolShell))
+
+	if strings.TrimSpace(input.Command) == "" {
+		return nil, CommandOutput{}, fmt.Errorf("command is required")
+	}
+	dir, err := t.resolveDir(input.Workdir)
+	if err != nil {
+		return nil, CommandOutput{}, err
+	}
+
+	// 提交前扫描将要提交的改动。扫描在命令执行之前进行，同一条命令中先暂存再提交的改动无法被扫描，
+	// 因此开启扫描时 git commit 必须单独执行。命令的识别是尽力而为的（见 gitCommit），不能作为安全控制
+	var warning string
+	if commit := parseGitCommit(input.Command); commit.found {
+		if t.cfg.Scanner != nil && commit.compound {
+			return nil, CommandOutput{}, fmt.Errorf("git commit must be run as a separate command " +
+				"(without ;, &&, ||, |, &, newlines or command substitution) so that the staged changes can be scanned for secrets; " +
+				"stage the changes first, then run git commit on its own")
+		}
+		if warning, err = t.scanCommit(ctx, dir, commit.all); err != nil {
+			return nil, CommandOutput{}, err
+		}
+	}
+
+	out, err := t.run(ctx, ToolShell, dir, nil, input.TimeoutSeconds, "sh", "-c", input.Command)
+	out.Warning = warning
+	return nil, out, err
+}
+
+// handlePatch 使用 patch 命令应用补丁
+func (t *Toolset) handlePatch(ctx context.Context, req *mcp.CallToolRequest, input PatchInput) (*mcp.CallToolResult, CommandOutput, error) {
+	klog.InfoS("MCP tool called: apply_patch", "workdir", input.Workdir, "dryRun", input.DryRun, "sandbox", t.backend(ToolPatch))
+
+	if strings.TrimSpace(input.Patch) == "" {
+		return nil, CommandOutput{}, fmt.Errorf("patch is required")
+	}
+	dir, err := t.resolveDir(input.Workdir)
+	if err != nil {
+		return nil, CommandOutput{}, err
+	}
+	if err := checkPatchPaths(input.Patch); err != nil {
+		return nil, CommandOutput{}, err
+	}
+
+	strip := 1
+	if input.Strip != nil {
+		strip = *input.Strip
+	}
+	args := []string{"-p" + strconv.Itoa(strip), "--batch", "--forward"}
+	if input.DryRun {
+		args = append(args, "--dry-run")
+	}
+
+	// 扫描补丁新增的内容
+	warning, err := t.cfg.Scanner.Check("patch", secretscan.PatchAdditions(input.Patch))
+	if err != nil {
+		return nil, CommandOutput{}, err
+	}
+
+	patch := input.Patch
+	if !strings.HasSuffix(patch, "\n") {
+		patch += "\n"
+	}
+	out, err := t.run(ctx, ToolPatch, dir, strings.NewReader(patch), 0, "patch", args...)
+	out.Warning = warning
+	return nil, out, err
+}
+
+// scanCommit 扫描 git commit 将要提交的改动，all 表示带 -a/--all；无法获取改动（如不是 git 仓库）时跳过扫描
+func (t *Toolset) scanCommit(ctx context.Context, dir string, all bool) (string, error) {
+	if t.cfg.Scanner == nil {
+		return "", nil
+	}
+
+	args := []string{"diff", "--cached", "--no-color", "--no-ext-diff", "-U0"}
+	if all {
+		args[1] = "HEAD"
+	}
+	out, err := t.run(ctx, ToolShell, dir, nil, 0, "git", args...)
+	if err != nil || out.ExitCode != 0 {
+		klog.InfoS("Skip secret scan before commit", "dir", dir, "err", err, "stderr", out.Stderr)
+		return "", nil
+	}
+	if out.Truncated {
+		klog.InfoS("Diff truncated, secret scan before commit is partial", "dir", dir, "limit", t.cfg.MaxOutput)
+	}
+	return t.cfg.Scanner.Check("git commit", secretscan.PatchAdditions(out.Stdout))
+}
+
+// run 在工具对应的沙箱中执行命令，非零退出码作为结果返回给模型
+func (t *Toolset) run(ctx context.Context, tool, dir string, stdin io.Reader, timeoutSeconds int, name string, args ...string) (CommandOutput, error) {
+	timeout := t.cfg.Timeout
+	if d := time.Duration(timeoutSeconds) * time.Second; d > 0 && d < timeout {
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := t.cfg.Sandboxes[tool].Command(ctx, dir, name, args...)
+	stdout := &limitedBuffer{limit: t.cfg.MaxOutput}
+	stderr := &limitedBuffer{limit: t.cfg.MaxOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Stdin = stdin
+
+	out := CommandOutput{Sandbox: t.backend(tool)}
+	if err := cmd.Run(); err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return out, fmt.Errorf("run %s: %w", name, err)
+		}
+		if ctx.Err() != nil {
+			return out, fmt.Errorf("%s timed out after %s", tool, timeout)
+		}
+		out.ExitCode = exitErr.ExitCode()
+	}
+	out.Stdout = stdout.String()
+	out.Stderr = stderr.String()
+	out.Truncated = stdout.truncated || stderr.truncated
+	return out, nil
+}
+
+// backend 返回工具使用的沙箱类型
+func (t *Toolset) backend(tool string) string {
+	return t.cfg.Sandboxes[tool].Backend()
+}
+
+// resolveDir 解析相对于根目录的工作目录，拒绝越出根目录的路径
+func (t *Toolset) resolveDir(workdir string) (string, error) {
+	dir := filepath.Join(t.cfg.Root, workdir)
+	rel, err := filepath.Rel(t.cfg.Root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("access denied: workdir %q is outside %s", workdir, t.cfg.Root)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("workdir %q is not a directory", workdir)
+	}
+	return dir, nil
+}
+
+// checkPatchPaths 拒绝修改绝对路径或包含 .. 的文件，未使用沙箱时这是唯一的保护
+func checkPatchPaths(patch string) error {
+	for line := range strings.Lines(patch) {
+		name, ok := strings.CutPrefix(line, "--- ")
+		if !ok {
+			name, ok = strings.CutPrefix(line, "+++ ")
+		}
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(strings.TrimSpace(name), "\t")
+		if name == "/dev/null" {
+			continue
+		}
+		if filepath.IsAbs(name) || slices.Contains(strings.Split(filepath.ToSlash(name), "/"), "..") {
+			return fmt.Errorf("access denied: patch modifies %q outside the working directory", name)
+		}
+	}
+	return nil
+}
+
+// limitedBuffer 超过上限后丢弃后续输出的缓冲区
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write 实现 io.Writer，始终返回完整长度以免中断命令
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := b.limit - b.Len(); remain < len(p) {
+		b.truncated = true
+		if remain > 0 {
+			b.Buffer.Write(p[:remain])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
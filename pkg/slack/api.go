@@ -0,0 +1,193 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiURL Slack Web API 地址
+const apiURL = "https://slack.com/api/"
+
+// apiTimeout 单次 Web API 请求超时
+const apiTimeout = 30 * time.Second
+
+// client Slack Web API 客户端，只实现用到的几个方法
+type client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// newClient 创建 Web API 客户端
+func newClient() *client {
+	return &client{baseURL: apiURL, http: &http.Client{Timeout: apiTimeout}}
+}
+
+// apiResponse Web API 响应的公共字段
+type apiResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// call 以 JSON 调用 Web API 方法，响应解码到 out；Slack 返回 ok=false 时以 error 字段作为错误
+func (c *client) call(ctx context.Context, token, method string, payload, out any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal %s request: %w", method, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("%s: rate limited, retry after %ss", method, resp.Header.Get("Retry-After"))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", method, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	var result apiResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("%s: decode response: %w", method, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("%s: %s", method, result.Error)
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// openConnection 获取 Socket Mode 的 WebSocket 地址，每个地址只能连接一次
+func (c *client) openConnection(ctx context.Context, appToken string) (string, error) {
+	var out struct {
+		URL string `json:"url"`
+	}
+	if err := c.call(ctx, appToken, "apps.connections.open", struct{}{}, &out); err != nil {
+		return "", err
+	}
+	return out.URL, nil
+}
+
+// authTest 返回机器人 token 对应的用户 ID，用于识别和去掉消息中的 @ 提及
+func (c *client) authTest(ctx context.Context, botToken string) (string, error) {
+	var out struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.call(ctx, botToken, "auth.test", struct{}{}, &out); err != nil {
+		return "", err
+	}
+	return out.UserID, nil
+}
+
+// message chat.postMessage / chat.update 的请求体
+type message struct {
+	Channel     string       `json:"channel"`
+	TS          string       `json:"ts,omitempty"`
+	ThreadTS    string       `json:"thread_ts,omitempty"`
+	Text        string       `json:"text"`
+	Blocks      []block      `json:"blocks,omitempty"`
+	Attachments []attachment `json:"attachments,omitempty"`
+}
+
+// postMessage 发送消息，返回消息的 ts
+func (c *client) postMessage(ctx context.Context, botToken string, msg *message) (string, error) {
+	var out struct {
+		TS string `json:"ts"`
+	}
+	if err := c.call(ctx, botToken, "chat.postMessage", msg, &out); err != nil {
+		return "", err
+	}
+	return out.TS, nil
+}
+
+// updateMessage 替换已发送消息的内容；msg.Blocks、msg.Attachments 为空时清除原有的
+func (c *client) updateMessage(ctx context.Context, botToken string, msg *message) error {
+	payload := struct {
+		*message
+		Blocks      []block      `json:"blocks"`
+		Attachments []attachment `json:"attachments"`
+	}{msg, msg.Blocks, msg.Attachments}
+	if payload.Blocks == nil {
+		payload.Blocks = []block{}
+	}
+	if payload.Attachments == nil {
+		payload.Attachments = []attachment{}
+	}
+	return c.call(ctx, botToken, "chat.update", payload, nil)
+}
+
+// respond 通过斜杠命令的 response_url 发送只有命令发送者可见的消息
+func (c *client) respond(ctx context.Context, responseURL string, msg *message) error {
+	payload := struct {
+		ResponseType string       `json:"response_type"`
+		Text         string       `json:"text"`
+		Blocks       []block      `json:"blocks,omitempty"`
+		Attachments  []attachment `json:"attachments,omitempty"`
+	}{"ephemeral", msg.Text, msg.Blocks, msg.Attachments}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal command response: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("command response: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("command response: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// download 下载消息附带的文件（需要 files:read 权限），超过 maxBytes 时返回错误
+func (c *client) download(ctx context.Context, botToken, url string, maxBytes int) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+botToken)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download file: unexpected status %s", resp.Status)
+	}
+	// 缺少 files:read 权限时 Slack 返回登录页面
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil, fmt.Errorf("download file: access denied, check the files:read scope")
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", err)
+	}
+	if len(data) > maxBytes {
+		return nil, fmt.Errorf("file exceeds limit of %d bytes", maxBytes)
+	}
+	return data, nil
+}
@@ -0,0 +1,206 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/champly/ai-agent/pkg/connector"
+)
+
+// Slack 消息的长度限制
+const (
+	// maxSectionText section 块文本的最大字符数
+	maxSectionText = 3000
+	// maxBlocks 单条消息的最大块数，留出页脚
+	maxBlocks = 48
+	// maxAttachments 展示的工具调用数，更早的调用只计数
+	maxAttachments = 10
+	// maxToolText 工具参数和结果展示的最大字符数
+	maxToolText = 1500
+	// maxFallbackText 通知和不支持块的客户端显示的文本长度
+	maxFallbackText = 3000
+)
+
+// block Block Kit 块，只用到 section 和 context
+type block struct {
+	Type     string  `json:"type"`
+	Text     *text   `json:"text,omitempty"`
+	Elements []*text `json:"elements,omitempty"`
+}
+
+// text Block Kit 文本对象
+type text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// attachment 消息附件，Slack 默认折叠较长的附件内容，用于展示工具调用
+type attachment struct {
+	Color    string   `json:"color,omitempty"`
+	Title    string   `json:"title,omitempty"`
+	Text     string   `json:"text,omitempty"`
+	Footer   string   `json:"footer,omitempty"`
+	MrkdwnIn []string `json:"mrkdwn_in,omitempty"`
+}
+
+// render 把回答状态渲染为消息：回答正文为 section 块，工具调用为附件，页脚为 context 块
+func render(r *connector.Reply) *message {
+	msg := &message{}
+	switch {
+	case r.Err != "":
+		msg.Text = ":warning: 处理失败：" + escape(r.Err)
+		msg.Blocks = []block{section(msg.Text)}
+	case r.Answer != "":
+		body := toMrkdwn(r.Answer)
+		msg.Text = truncate(body, maxFallbackText)
+		for _, chunk := range splitText(body, maxSectionText) {
+			if len(msg.Blocks) == maxBlocks {
+				msg.Blocks[len(msg.Blocks)-1] = section("_（回答过长，其余内容已省略）_")
+				break
+			}
+			msg.Blocks = append(msg.Blocks, section(chunk))
+		}
+	default:
+		msg.Text = "_思考中…_"
+		if n := len(r.Tools); n > 0 && !r.Tools[n-1].Done {
+			msg.Text = fmt.Sprintf("_正在调用 `%s`…_", r.Tools[n-1].Tool)
+		}
+		msg.Blocks = []block{section(msg.Text)}
+	}
+
+	if r.Footer != "" && r.Done {
+		msg.Blocks = append(msg.Blocks, block{Type: "context", Elements: []*text{{Type: "mrkdwn", Text: escape(r.Footer)}}})
+	}
+
+	tools := r.Tools
+	if skipped := len(tools) - maxAttachments; skipped > 0 {
+		msg.Attachments = append(msg.Attachments, attachment{Color: "#cccccc", Text: fmt.Sprintf("还有 %d 次较早的工具调用未显示", skipped)})
+		tools = tools[skipped:]
+	}
+	for _, tc := range tools {
+		msg.Attachments = append(msg.Attachments, toolAttachment(tc))
+	}
+	return msg
+}
+
+// toolAttachment 工具调用的附件：标题为工具名和状态，内容为参数和结果
+func toolAttachment(tc connector.ToolCall) attachment {
+	a := attachment{Title: ":wrench: " + tc.Tool, MrkdwnIn: []string{"text"}}
+	var b strings.Builder
+	if len(tc.Arguments) > 0 {
+		args, _ := json.Marshal(tc.Arguments)
+		fmt.Fprintf(&b, "*参数*\n```%s```\n", escape(truncate(string(args), maxToolText)))
+	}
+	switch {
+	case tc.Error != "":
+		a.Color = "danger"
+		fmt.Fprintf(&b, "*错误*\n```%s```", escape(truncate(tc.Error, maxToolText)))
+	case tc.Done:
+		a.Color = "good"
+		if tc.Result != "" {
+			fmt.Fprintf(&b, "*结果*\n```%s```", escape(truncate(tc.Result, maxToolText)))
+		}
+	default:
+		a.Color = "#439fe0"
+		a.Footer = "执行中…"
+	}
+	a.Text = strings.TrimSpace(b.String())
+	return a
+}
+
+// section 文本 section 块
+func section(s string) block {
+	return block{Type: "section", Text: &text{Type: "mrkdwn", Text: s}}
+}
+
+var (
+	mdHeading = regexp.MustCompile(`(?m)^#{1,6}\s+(.+?)\s*#*$`)
+	mdStrong  = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	mdLink    = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	mdBullet  = regexp.MustCompile(`(?m)^(\s*)[-*+]\s+`)
+	mdStrike  = regexp.MustCompile(`~~(.+?)~~`)
+)
+
+// toMrkdwn 把模型输出的 Markdown 转成 Slack mrkdwn：转义 &、<、>，转换标题、粗体、删除线、链接和列表，代码块内只转义
+func toMrkdwn(s string) string {
+	s = escape(s)
+	parts := strings.Split(s, "```")
+	for i := range parts {
+		if i%2 == 1 {
+			// 代码块去掉语言标记
+			if lang, rest, ok := strings.Cut(parts[i], "\n"); ok && !strings.ContainsAny(lang, " \t") {
+				parts[i] = rest
+			}
+			continue
+		}
+		p := parts[i]
+		p = mdHeading.ReplaceAllString(p, "*$1*")
+		p = mdStrong.ReplaceAllStringFunc(p, func(m string) string {
+			return "*" + m[2:len(m)-2] + "*"
+		})
+		p = mdStrike.ReplaceAllString(p, "~$1~")
+		p = mdLink.ReplaceAllString(p, "<$2|$1>")
+		p = mdBullet.ReplaceAllString(p, "$1• ")
+		parts[i] = p
+	}
+	return strings.Join(parts, "```")
+}
+
+// escape 转义 Slack 文本中的控制字符
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// splitText 按行把文本拆成不超过 limit 个字符的段，代码块被拆开时在两段分别补上结束和开始标记
+func splitText(s string, limit int) []string {
+	var chunks []string
+	var cur strings.Builder
+	inFence := false
+	flush := func() {
+		chunk := cur.String()
+		if inFence {
+			chunk += "```"
+		}
+		if strings.TrimSpace(chunk) != "" {
+			chunks = append(chunks, strings.TrimRight(chunk, "\n"))
+		}
+		cur.Reset()
+		if inFence {
+			cur.WriteString("```\n")
+		}
+	}
+	for _, line := range strings.SplitAfter(s, "\n") {
+		// 单行过长时硬切
+		for utf8.RuneCountInString(line) > limit-8 {
+			runes := []rune(line)
+			head := string(runes[:limit-8])
+			if utf8.RuneCountInString(cur.String())+len([]rune(head)) > limit-4 {
+				flush()
+			}
+			cur.WriteString(head)
+			flush()
+			line = string(runes[limit-8:])
+		}
+		if utf8.RuneCountInString(cur.String())+utf8.RuneCountInString(line) > limit-4 {
+			flush()
+		}
+		cur.WriteString(line)
+		if strings.Count(line, "```")%2 == 1 {
+			inFence = !inFence
+		}
+	}
+	inFence = false
+	flush()
+	return chunks
+}
+
+// truncate 截断到 n 个字符
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
@@ -0,0 +1,340 @@
+// Package slack 通过 Socket Mode 把 Agent 接入 Slack 的连接器：频道中 @ 机器人或私信时回答，每个讨论串对应一个对话；
+// 回答渲染为 Block Kit 消息，工具调用展示为附件，斜杠命令的回复只有发送者可见
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/connector"
+)
+
+const (
+	// pingInterval 向 Slack 发送 WebSocket ping 的间隔，超过 3 倍间隔没有收到任何数据时重新连接
+	pingInterval = 30 * time.Second
+	// eventDedupWindow Slack 重投事件的去重时间
+	eventDedupWindow = 10 * time.Minute
+)
+
+// mentionPattern 消息中的 @ 提及
+var mentionPattern = regexp.MustCompile(`<@[A-Z0-9]+(\|[^>]*)?>`)
+
+// Bot Slack 连接器
+type Bot struct {
+	cfg   config.SlackConfig
+	api   *client
+	botID string
+
+	mu   sync.Mutex
+	seen map[string]time.Time // 已处理的事件 ID -> 收到时间
+}
+
+var _ connector.Connector = (*Bot)(nil)
+
+// New 创建 Slack 连接器
+func New(cfg config.SlackConfig) *Bot {
+	return &Bot{cfg: cfg, api: newClient(), seen: make(map[string]time.Time)}
+}
+
+// Name 实现 connector.Connector
+func (b *Bot) Name() string {
+	return "slack"
+}
+
+// Options 实现 connector.Connector
+func (b *Bot) Options() config.ConnectorConfig {
+	return b.cfg.ConnectorConfig
+}
+
+// Receive 建立一个 Socket Mode 连接并读取消息，直到连接断开（返回错误）或 Slack 要求重连（返回 nil）。
+// 每个副本各自连接，Slack 把每个事件只投递给其中一个连接
+func (b *Bot) Receive(ctx context.Context, handler connector.Handler) error {
+	if b.botID == "" {
+		id, err := b.api.authTest(ctx, b.cfg.BotToken)
+		if err != nil {
+			return fmt.Errorf("verify slack bot token: %w", err)
+		}
+		b.botID = id
+	}
+	return b.connect(ctx, handler)
+}
+
+// Send 实现 connector.Connector：发送到频道或讨论串，命令的私密回复通过 response_url 发送
+func (b *Bot) Send(ctx context.Context, target connector.Target, reply *connector.Reply) (string, error) {
+	msg := render(reply)
+	if target.Private && target.ReplyTo != "" {
+		return "", b.api.respond(ctx, target.ReplyTo, msg)
+	}
+	msg.Channel, msg.ThreadTS = target.Channel, target.Thread
+	return b.api.postMessage(ctx, b.cfg.BotToken, msg)
+}
+
+// Edit 实现 connector.Connector
+func (b *Bot) Edit(ctx context.Context, target connector.Target, id string, reply *connector.Reply) error {
+	msg := render(reply)
+	msg.Channel, msg.TS = target.Channel, id
+	return b.api.updateMessage(ctx, b.cfg.BotToken, msg)
+}
+
+// Typing 实现 connector.Connector：Socket Mode 的机器人不能显示“正在输入”，占位消息中展示进度
+func (b *Bot) Typing(context.Context, connector.Target) error {
+	return nil
+}
+
+// envelope Socket Mode 推送的消息
+type envelope struct {
+	Type       string          `json:"type"`
+	EnvelopeID string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload"`
+	Reason     string          `json:"reason"`
+}
+
+// conn 一个 Socket Mode 连接，写操作需要串行
+type conn struct {
+	ws *websocket.Conn
+	mu sync.Mutex
+}
+
+// write 发送 JSON 消息
+func (c *conn) write(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return c.ws.WriteJSON(v)
+}
+
+// ack 确认收到消息
+func (c *conn) ack(envelopeID string) error {
+	return c.write(map[string]any{"envelope_id": envelopeID})
+}
+
+// connect 建立一个 Socket Mode 连接并读取消息，直到连接断开（返回错误）或 Slack 要求重连（返回 nil）
+func (b *Bot) connect(ctx context.Context, handler connector.Handler) error {
+	url, err := b.api.openConnection(ctx, b.cfg.AppToken)
+	if err != nil {
+		return err
+	}
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return fmt.Errorf("dial slack socket: %w", err)
+	}
+	c := &conn{ws: ws}
+	defer ws.Close()
+
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// ctx 结束时关闭连接以中断阻塞的读取
+	go func() {
+		<-connCtx.Done()
+		ws.Close()
+	}()
+
+	deadline := func() { ws.SetReadDeadline(time.Now().Add(3 * pingInterval)) }
+	deadline()
+	ws.SetPongHandler(func(string) error { deadline(); return nil })
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-connCtx.Done():
+				return
+			case <-ticker.C:
+				c.mu.Lock()
+				err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+				c.mu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		var env envelope
+		if err := ws.ReadJSON(&env); err != nil {
+			return fmt.Errorf("read slack socket: %w", err)
+		}
+		deadline()
+
+		switch env.Type {
+		case "hello":
+			klog.InfoS("Slack connector connected", "botUser", b.botID)
+		case "disconnect":
+			// Slack 定期刷新连接，收到后立即重新连接
+			klog.V(2).InfoS("Slack requested reconnect", "reason", env.Reason)
+			return nil
+		case "events_api":
+			if err := c.ack(env.EnvelopeID); err != nil {
+				return err
+			}
+			b.handleEvent(ctx, env.Payload, handler)
+		case "slash_commands":
+			// 先确认再处理，命令的回复通过 response_url 发送
+			if err := c.ack(env.EnvelopeID); err != nil {
+				return err
+			}
+			b.handleCommand(ctx, env.Payload, handler)
+		default:
+			if env.EnvelopeID != "" {
+				if err := c.ack(env.EnvelopeID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// eventCallback Events API 的事件回调
+type eventCallback struct {
+	EventID string `json:"event_id"`
+	Event   struct {
+		Type        string `json:"type"`
+		Subtype     string `json:"subtype"`
+		User        string `json:"user"`
+		BotID       string `json:"bot_id"`
+		Text        string `json:"text"`
+		Channel     string `json:"channel"`
+		ChannelType string `json:"channel_type"`
+		TS          string `json:"ts"`
+		ThreadTS    string `json:"thread_ts"`
+		Files       []struct {
+			Name        string `json:"name"`
+			Size        int    `json:"size"`
+			Mimetype    string `json:"mimetype"`
+			URLDownload string `json:"url_private_download"`
+		} `json:"files"`
+	} `json:"event"`
+}
+
+// handleEvent 处理 @ 提及和私信：频道中的提及在讨论串中回答，私信直接回答（在讨论串中发送时在讨论串中回答）
+func (b *Bot) handleEvent(ctx context.Context, payload json.RawMessage, handler connector.Handler) {
+	var cb eventCallback
+	if err := json.Unmarshal(payload, &cb); err != nil {
+		klog.ErrorS(err, "Failed to decode Slack event")
+		return
+	}
+	ev := cb.Event
+	// 忽略机器人自己及其他机器人的消息、编辑和删除等子类型
+	if ev.BotID != "" || (ev.Subtype != "" && ev.Subtype != "file_share") || ev.User == "" || ev.User == b.botID {
+		return
+	}
+
+	var threadTS string
+	switch {
+	case ev.Type == "app_mention":
+		if len(b.cfg.Channels) > 0 && !slices.Contains(b.cfg.Channels, ev.Channel) {
+			return
+		}
+		threadTS = ev.ThreadTS
+		if threadTS == "" {
+			threadTS = ev.TS
+		}
+	case ev.Type == "message" && ev.ChannelType == "im":
+		if !b.cfg.DirectMessages {
+			return
+		}
+		threadTS = ev.ThreadTS
+	default:
+		return
+	}
+	if !b.markSeen(cb.EventID) {
+		return
+	}
+
+	msg := &connector.Message{
+		Channel: ev.Channel,
+		Thread:  threadTS,
+		ID:      ev.TS,
+		User:    ev.User,
+		Text: strings.TrimSpace(mentionPattern.ReplaceAllStringFunc(ev.Text, func(m string) string {
+			if strings.HasPrefix(m, "<@"+b.botID) {
+				return ""
+			}
+			return m
+		})),
+	}
+	for _, f := range ev.Files {
+		if f.URLDownload == "" {
+			continue
+		}
+		url := f.URLDownload
+		msg.Files = append(msg.Files, connector.File{
+			Name:  f.Name,
+			Size:  f.Size,
+			Image: strings.HasPrefix(f.Mimetype, "image/"),
+			Download: func(ctx context.Context, maxBytes int) ([]byte, error) {
+				return b.api.download(ctx, b.cfg.BotToken, url, maxBytes)
+			},
+		})
+	}
+	if msg.Text == "" && len(msg.Files) == 0 {
+		return
+	}
+	handler(ctx, msg)
+}
+
+// markSeen 记录事件 ID，Slack 重投的事件返回 false
+func (b *Bot) markSeen(id string) bool {
+	if id == "" {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	for key, t := range b.seen {
+		if now.Sub(t) >= eventDedupWindow {
+			delete(b.seen, key)
+		}
+	}
+	if _, ok := b.seen[id]; ok {
+		return false
+	}
+	b.seen[id] = now
+	return true
+}
+
+// commandPayload 斜杠命令的负载
+type commandPayload struct {
+	Command     string `json:"command"`
+	Text        string `json:"text"`
+	ChannelID   string `json:"channel_id"`
+	UserID      string `json:"user_id"`
+	ResponseURL string `json:"response_url"`
+}
+
+// handleCommand 把斜杠命令转为命令消息：第一个词为命令名，其余为参数，没有参数时显示帮助。
+// 斜杠命令没有消息 ID，以 response_url 作为消息 ID，私密回复通过它发送
+func (b *Bot) handleCommand(ctx context.Context, payload json.RawMessage, handler connector.Handler) {
+	var cmd commandPayload
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		klog.ErrorS(err, "Failed to decode Slack command")
+		return
+	}
+	if cmd.Command != b.cfg.Command {
+		klog.V(2).InfoS("Ignoring unknown Slack command", "command", cmd.Command)
+		return
+	}
+	name, args, _ := strings.Cut(strings.TrimSpace(cmd.Text), " ")
+	if name == "" {
+		name = "help"
+	}
+	handler(ctx, &connector.Message{
+		Channel:       cmd.ChannelID,
+		ID:            cmd.ResponseURL,
+		User:          cmd.UserID,
+		Command:       name,
+		Args:          strings.TrimSpace(args),
+		CommandPrefix: b.cfg.Command + " ",
+	})
+}
@@ -0,0 +1,189 @@
+// Package spill 将过大的工具结果写入临时文件，对话历史中只保留开头的预览和一个句柄，
+// 模型需要时再通过 read_more 工具分段读取，避免整段结果留在对话历史中反复发送给模型。
+// 工具结果经 MCP 以完整字符串返回，转存发生在结果已读入内存之后，不是流式写入，
+// 不能降低单次工具调用的内存峰值
+package spill
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// ErrNotFound 句柄不存在、已过期或属于其他对话
+var ErrNotFound = errors.New("tool result handle not found")
+
+// entry 一个已写入文件的结果
+type entry struct {
+	conversationID string
+	tool           string
+	path           string
+	size           int64
+	createdAt      time.Time
+}
+
+// Store 大结果存储，nil 表示不转存
+type Store struct {
+	dir       string
+	threshold int
+	preview   int
+	ttl       time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// New 在 cfg.Dir（默认系统临时目录）下创建本进程专用的子目录；未启用时返回 nil
+func New(cfg config.ToolResultConfig) (*Store, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	parent := cfg.Dir
+	if parent == "" {
+		parent = os.TempDir()
+	}
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return nil, fmt.Errorf("create tool result dir: %w", err)
+	}
+	// 句柄只保存在内存中，每个进程使用独立的子目录，Close 时整体删除
+	dir, err := os.MkdirTemp(parent, "ai-agent-tool-results-")
+	if err != nil {
+		return nil, fmt.Errorf("create tool result dir: %w", err)
+	}
+
+	klog.InfoS("Large tool results spill to disk", "dir", dir, "threshold", cfg.Threshold, "preview", cfg.PreviewBytes)
+	return &Store{
+		dir:       dir,
+		threshold: cfg.Threshold,
+		preview:   cfg.PreviewBytes,
+		ttl:       cfg.TTL,
+		entries:   make(map[string]*entry),
+	}, nil
+}
+
+// Spill 结果超过阈值时写入文件，返回用于放入对话历史的预览和读取说明；未超过阈值或写入失败时原样返回。
+// 调用方持有的 result 不受影响，本次请求的事件和工具调用记录中仍是完整结果
+func (s *Store) Spill(conversationID, tool, result string) string {
+	if s == nil || len(result) <= s.threshold {
+		return result
+	}
+	s.sweep()
+
+	handle := newHandle()
+	path := filepath.Join(s.dir, handle)
+	if err := os.WriteFile(path, []byte(result), 0o600); err != nil {
+		klog.ErrorS(err, "Failed to spill tool result, keeping it in memory", "tool", tool)
+		return result
+	}
+
+	s.mu.Lock()
+	s.entries[handle] = &entry{
+		conversationID: conversationID,
+		tool:           tool,
+		path:           path,
+		size:           int64(len(result)),
+		createdAt:      time.Now(),
+	}
+	s.mu.Unlock()
+
+	preview := result[:cutUTF8(result, s.preview)]
+	klog.V(2).InfoS("Tool result spilled", "tool", tool, "handle", handle, "bytes", len(result))
+	return fmt.Sprintf("%s\n\n[结果共 %d 字节，以上为前 %d 字节。如需后续内容，调用 read_more 工具，参数 handle=%q、offset=%d]",
+		preview, len(result), len(preview), handle, len(preview))
+}
+
+// Read 从 offset 开始读取最多 limit 字节，只能读取同一对话中转存的结果；
+// 返回的内容不会截断多字节字符，next 为下一次读取的位置，读完时等于 total
+func (s *Store) Read(conversationID, handle string, offset, limit int64) (content string, next, total int64, err error) {
+	if s == nil {
+		return "", 0, 0, ErrNotFound
+	}
+	s.mu.Lock()
+	e, ok := s.entries[handle]
+	s.mu.Unlock()
+	if !ok || e.conversationID != conversationID {
+		return "", 0, 0, fmt.Errorf("%w: %s", ErrNotFound, handle)
+	}
+	if offset < 0 || offset > e.size {
+		return "", 0, 0, fmt.Errorf("offset %d out of range [0, %d]", offset, e.size)
+	}
+	// 单次最多读取一个阈值的长度，避免重新把整段结果放进对话
+	if limit <= 0 {
+		limit = int64(s.preview)
+	}
+	limit = min(max(limit, utf8.UTFMax), int64(s.threshold))
+
+	f, err := os.Open(e.path)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("open tool result: %w", err)
+	}
+	defer f.Close()
+
+	// 多读 utf8.UTFMax 字节，用于判断结尾的字符是否完整
+	buf := make([]byte, min(limit+utf8.UTFMax, e.size-offset))
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", 0, 0, fmt.Errorf("read tool result: %w", err)
+	}
+	buf = buf[:n]
+	if int64(len(buf)) > limit {
+		buf = buf[:cutUTF8(string(buf), int(limit))]
+	}
+	return string(buf), offset + int64(len(buf)), e.size, nil
+}
+
+// sweep 删除超过有效期的结果
+func (s *Store) sweep() {
+	if s.ttl <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for handle, e := range s.entries {
+		if time.Since(e.createdAt) > s.ttl {
+			os.Remove(e.path)
+			delete(s.entries, handle)
+		}
+	}
+}
+
+// Close 删除所有转存的结果及本进程的子目录
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = make(map[string]*entry)
+	return os.RemoveAll(s.dir)
+}
+
+// cutUTF8 返回不超过 n 且不截断多字节字符的切分位置
+func cutUTF8(s string, n int) int {
+	if n >= len(s) {
+		return len(s)
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
+
+// newHandle 生成随机句柄
+func newHandle() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
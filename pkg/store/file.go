@@ -0,0 +1,114 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// validID 对话 ID 只允许安全字符，防止路径穿越
+var validID = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// FileStore 基于本地目录的对话存储，每个对话一个 JSON 文件
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore 创建文件存储
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create conversation directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path 返回对话文件路径
+func (s *FileStore) path(id string) (string, error) {
+	if !validID.MatchString(id) || strings.Contains(id, "..") {
+		return "", fmt.Errorf("invalid conversation id: %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// Load 加载对话
+func (s *FileStore) Load(id string) (*Record, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read conversation: %w", err)
+	}
+
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("parse conversation %s: %w", id, err)
+	}
+	return &rec, nil
+}
+
+// Save 保存对话（写临时文件后重命名）
+func (s *FileStore) Save(rec *Record) error {
+	path, err := s.path(rec.ID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal conversation: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write conversation: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// List 列出所有对话
+func (s *FileStore) List() ([]Summary, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read conversation directory: %w", err)
+	}
+
+	summaries := make([]Summary, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		rec, err := s.Load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			klog.ErrorS(err, "Failed to load conversation", "file", entry.Name())
+			continue
+		}
+		summaries = append(summaries, Summarize(rec))
+	}
+
+	sortSummaries(summaries)
+	return summaries, nil
+}
+
+// Delete 删除对话
+func (s *FileStore) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("delete conversation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/redisclient"
+)
+
+// redisTimeout 单次 Redis 操作超时
+const redisTimeout = 5 * time.Second
+
+// RedisStore 基于 Redis 的对话存储，多个副本共享同一份对话历史
+// 对话记录保存在 <prefix>:conversation:<id>，摘要保存在 <prefix>:conversations 哈希中用于列表。
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore 创建 Redis 存储
+func NewRedisStore(cfg config.RedisConfig) (*RedisStore, error) {
+	client, err := redisclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStore{client: client, prefix: cfg.Prefix}, nil
+}
+
+// Shared 对话可能被其他副本修改
+func (s *RedisStore) Shared() bool {
+	return true
+}
+
+// recordKey 返回对话记录的键
+func (s *RedisStore) recordKey(id string) string {
+	return redisclient.Key(s.prefix, "conversation", id)
+}
+
+// summaryKey 返回摘要哈希的键
+func (s *RedisStore) summaryKey() string {
+	return redisclient.Key(s.prefix, "conversations")
+}
+
+// Load 加载对话
+func (s *RedisStore) Load(id string) (*Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, s.recordKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read conversation: %w", err)
+	}
+
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("parse conversation %s: %w", id, err)
+	}
+	return &rec, nil
+}
+
+// Save 保存对话及其摘要
+func (s *RedisStore) Save(rec *Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal conversation: %w", err)
+	}
+	summary, err := json.Marshal(Summarize(rec))
+	if err != nil {
+		return fmt.Errorf("marshal summary: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.recordKey(rec.ID), data, 0)
+		pipe.HSet(ctx, s.summaryKey(), rec.ID, summary)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("write conversation: %w", err)
+	}
+	return nil
+}
+
+// List 列出所有对话
+func (s *RedisStore) List() ([]Summary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	values, err := s.client.HGetAll(ctx, s.summaryKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("read conversation summaries: %w", err)
+	}
+
+	summaries := make([]Summary, 0, len(values))
+	for id, data := range values {
+		var summary Summary
+		if err := json.Unmarshal([]byte(data), &summary); err != nil {
+			klog.ErrorS(err, "Failed to parse conversation summary", "conversationID", id)
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+
+	sortSummaries(summaries)
+	return summaries, nil
+}
+
+// Delete 删除对话
+func (s *RedisStore) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.recordKey(id))
+		pipe.HDel(ctx, s.summaryKey(), id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete conversation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Package store 实现对话历史的持久化存储
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// ErrNotFound 对话不存在
+var ErrNotFound = errors.New("conversation not found")
+
+// Record 持久化的对话记录
+type Record struct {
+	ID        string        `json:"id"`
+	User      string        `json:"user,omitempty"` // 创建对话的用户，为空表示未启用认证
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Persona   string        `json:"persona,omitempty"`  // 对话选择的人设
+	Language  string        `json:"language,omitempty"` // 对话选择的回答语言
+	Profile   string        `json:"profile,omitempty"`  // 对话开始时注入系统提示的用户资料
+	Messages  []api.Message `json:"messages"`
+}
+
+// Summary 对话摘要，用于列表展示
+type Summary struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	User         string    `json:"user,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	MessageCount int       `json:"message_count"`
+}
+
+// Store 对话存储接口
+type Store interface {
+	// Load 加载对话，不存在时返回 ErrNotFound
+	Load(id string) (*Record, error)
+	// Save 保存（覆盖）对话
+	Save(rec *Record) error
+	// List 列出所有对话摘要，按更新时间倒序
+	List() ([]Summary, error)
+	// Delete 删除对话
+	Delete(id string) error
+}
+
+// IsShared 存储是否被多个副本共享（对话可能被其他副本修改，不能只依赖进程内缓存）
+func IsShared(s Store) bool {
+	shared, ok := s.(interface{ Shared() bool })
+	return ok && shared.Shared()
+}
+
+// titleLength 标题最大字符数
+const titleLength = 60
+
+// Summarize 根据对话记录生成摘要，标题取第一条用户消息
+func Summarize(rec *Record) Summary {
+	s := Summary{
+		ID:           rec.ID,
+		User:         rec.User,
+		CreatedAt:    rec.CreatedAt,
+		UpdatedAt:    rec.UpdatedAt,
+		MessageCount: len(rec.Messages),
+	}
+	for _, msg := range rec.Messages {
+		if msg.Role == "user" {
+			title := []rune(msg.Content)
+			if len(title) > titleLength {
+				title = append(title[:titleLength], '…')
+			}
+			s.Title = string(title)
+			break
+		}
+	}
+	return s
+}
+
+// sortSummaries 按更新时间倒序排列
+func sortSummaries(summaries []Summary) {
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt)
+	})
+}
+
+// New 根据配置创建对话存储，memory 类型返回 nil（对话仅保存在进程内存中）
+func New(cfg config.ConversationConfig, redisCfg config.RedisConfig) (Store, error) {
+	switch cfg.Store {
+	case "", "memory":
+		return nil, nil
+	case "file":
+		return NewFileStore(cfg.Dir)
+	case "redis":
+		return NewRedisStore(redisCfg)
+	default:
+		return nil, fmt.Errorf("unknown conversation store: %s", cfg.Store)
+	}
+}
@@ -0,0 +1,216 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiURL Telegram Bot API 地址
+const apiURL = "https://api.telegram.org"
+
+// client Bot API 客户端，只实现用到的几个方法
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// newClient 创建 Bot API 客户端；长轮询的请求超时由调用方的 context 控制
+func newClient(token string) *client {
+	return &client{baseURL: apiURL, token: token, http: &http.Client{Timeout: 2 * time.Minute}}
+}
+
+// APIError Bot API 返回的错误
+type APIError struct {
+	Method      string
+	Code        int
+	Description string
+	Retry       time.Duration // 频率限制时建议的等待时间
+}
+
+// Error 实现 error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram %s: %d %s", e.Method, e.Code, e.Description)
+}
+
+// RetryAfter 实现 connector.RetryAfter
+func (e *APIError) RetryAfter() time.Duration {
+	return e.Retry
+}
+
+// call 以 JSON 调用 Bot API 方法，result 字段解码到 out
+func (c *client) call(ctx context.Context, method string, payload, out any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal %s request: %w", method, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/bot"+c.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// 错误信息中的地址包含 token
+		return fmt.Errorf("telegram %s: request failed: %w", method, unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		ErrorCode   int             `json:"error_code"`
+		Description string          `json:"description"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&result); err != nil {
+		return fmt.Errorf("telegram %s: decode response: %w", method, err)
+	}
+	if !result.OK {
+		return &APIError{Method: method, Code: result.ErrorCode, Description: result.Description, Retry: time.Duration(result.Parameters.RetryAfter) * time.Second}
+	}
+	if out != nil {
+		return json.Unmarshal(result.Result, out)
+	}
+	return nil
+}
+
+// unwrapURLError 去掉 *url.Error 中包含 token 的请求地址
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// update getUpdates 返回的更新，只关心消息
+type update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *message `json:"message"`
+}
+
+// message 收到的消息
+type message struct {
+	MessageID int64  `json:"message_id"`
+	Chat      chat   `json:"chat"`
+	From      *user  `json:"from"`
+	Text      string `json:"text"`
+	Caption   string `json:"caption"`
+	Document  *struct {
+		FileID   string `json:"file_id"`
+		FileName string `json:"file_name"`
+		FileSize int    `json:"file_size"`
+	} `json:"document"`
+	Photo []struct {
+		FileID   string `json:"file_id"`
+		FileSize int    `json:"file_size"`
+	} `json:"photo"`
+}
+
+// chat 消息所在的聊天
+type chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"` // private、group、supergroup 或 channel
+}
+
+// user 消息发送者
+type user struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	IsBot    bool   `json:"is_bot"`
+}
+
+// getMe 返回机器人的用户名，用于去掉群组消息中的 @ 提及
+func (c *client) getMe(ctx context.Context) (string, error) {
+	var me user
+	if err := c.call(ctx, "getMe", struct{}{}, &me); err != nil {
+		return "", err
+	}
+	return me.Username, nil
+}
+
+// getUpdates 长轮询获取 offset 之后的更新，没有更新时最多等待 timeout
+func (c *client) getUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]update, error) {
+	var updates []update
+	err := c.call(ctx, "getUpdates", map[string]any{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	return updates, err
+}
+
+// sendMessage 发送纯文本消息，replyTo 不为 0 时作为对该消息的回复，返回消息 ID
+func (c *client) sendMessage(ctx context.Context, chatID, replyTo int64, text string) (int64, error) {
+	payload := map[string]any{"chat_id": chatID, "text": text}
+	if replyTo != 0 {
+		payload["reply_parameters"] = map[string]any{"message_id": replyTo, "allow_sending_without_reply": true}
+	}
+	var sent message
+	if err := c.call(ctx, "sendMessage", payload, &sent); err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
+}
+
+// editMessageText 替换已发送消息的文本，内容没有变化时不返回错误
+func (c *client) editMessageText(ctx context.Context, chatID, messageID int64, text string) error {
+	err := c.call(ctx, "editMessageText", map[string]any{"chat_id": chatID, "message_id": messageID, "text": text}, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && strings.Contains(apiErr.Description, "message is not modified") {
+		return nil
+	}
+	return err
+}
+
+// sendTyping 显示“正在输入”，持续约 5 秒
+func (c *client) sendTyping(ctx context.Context, chatID int64) error {
+	return c.call(ctx, "sendChatAction", map[string]any{"chat_id": chatID, "action": "typing"}, nil)
+}
+
+// download 下载文件，超过 maxBytes 时返回错误
+func (c *client) download(ctx context.Context, fileID string, maxBytes int) ([]byte, error) {
+	var file struct {
+		FilePath string `json:"file_path"`
+		FileSize int    `json:"file_size"`
+	}
+	if err := c.call(ctx, "getFile", map[string]any{"file_id": fileID}, &file); err != nil {
+		return nil, err
+	}
+	if file.FileSize > maxBytes {
+		return nil, fmt.Errorf("file size %d exceeds limit of %d bytes", file.FileSize, maxBytes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/file/bot"+c.token+"/"+file.FilePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download file: unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", err)
+	}
+	if len(data) > maxBytes {
+		return nil, fmt.Errorf("file exceeds limit of %d bytes", maxBytes)
+	}
+	return data, nil
+}
@@ -0,0 +1,280 @@
+// Package telegram 通过 Bot API 长轮询把 Agent 接入 Telegram 的连接器：每个聊天对应一个对话，超长回答拆成多条消息，
+// 文件和图片交给 Agent 按 uploads 配置处理，未授权的聊天只收到一次拒绝消息
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/connector"
+)
+
+const (
+	// pollTimeout 长轮询等待新消息的时间
+	pollTimeout = 30 * time.Second
+	// maxMessageLength 单条消息的最大字符数（Bot API 限制为 4096）
+	maxMessageLength = 4000
+	// maxToolLines 回答过程中展示的工具调用数
+	maxToolLines = 10
+)
+
+// Bot Telegram 连接器
+type Bot struct {
+	cfg      config.TelegramConfig
+	api      *client
+	username string
+	offset   int64 // 下一次获取的更新 ID，重连后不重复处理
+
+	mu     sync.Mutex
+	denied map[int64]bool // 已发送过拒绝消息的聊天
+}
+
+var (
+	_ connector.Connector = (*Bot)(nil)
+	_ connector.Exclusive = (*Bot)(nil)
+)
+
+// New 创建 Telegram 连接器
+func New(cfg config.TelegramConfig) *Bot {
+	return &Bot{cfg: cfg, api: newClient(cfg.Token), denied: make(map[int64]bool)}
+}
+
+// Name 实现 connector.Connector
+func (b *Bot) Name() string {
+	return "telegram"
+}
+
+// Options 实现 connector.Connector
+func (b *Bot) Options() config.ConnectorConfig {
+	return b.cfg.ConnectorConfig
+}
+
+// Exclusive 实现 connector.Exclusive：Bot API 只允许一个连接获取更新，多副本时只在主节点运行
+func (b *Bot) Exclusive() bool {
+	return true
+}
+
+// Receive 长轮询获取消息直到 ctx 结束或请求失败
+func (b *Bot) Receive(ctx context.Context, handler connector.Handler) error {
+	if b.username == "" {
+		username, err := b.api.getMe(ctx)
+		if err != nil {
+			return err
+		}
+		b.username = username
+		klog.InfoS("Telegram bot verified", "bot", username)
+	}
+
+	for ctx.Err() == nil {
+		pollCtx, cancel := context.WithTimeout(ctx, pollTimeout+30*time.Second)
+		updates, err := b.api.getUpdates(pollCtx, b.offset, pollTimeout)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for _, u := range updates {
+			b.offset = max(b.offset, u.UpdateID+1)
+			if u.Message != nil {
+				b.dispatch(ctx, u.Message, handler)
+			}
+		}
+	}
+	return nil
+}
+
+// dispatch 把消息转为连接器消息：/ 开头的为命令（/start 同 /help），文件和图片在处理时才下载
+func (b *Bot) dispatch(ctx context.Context, msg *message, handler connector.Handler) {
+	if msg.From != nil && msg.From.IsBot {
+		return
+	}
+	chatID := msg.Chat.ID
+	if !slices.Contains(b.cfg.AllowedChats, chatID) {
+		go b.deny(ctx, chatID)
+		return
+	}
+
+	text := msg.Text
+	if text == "" {
+		text = msg.Caption
+	}
+	m := &connector.Message{
+		Channel: strconv.FormatInt(chatID, 10),
+		ID:      strconv.FormatInt(msg.MessageID, 10),
+		Text:    strings.TrimSpace(strings.ReplaceAll(text, "@"+b.username, "")),
+	}
+	if msg.From != nil {
+		m.User = strconv.FormatInt(msg.From.ID, 10)
+	}
+	if command, ok := strings.CutPrefix(m.Text, "/"); ok {
+		name, args, _ := strings.Cut(command, " ")
+		if name == "start" {
+			name = "help"
+		}
+		m.Command, m.Args, m.CommandPrefix, m.Text = name, strings.TrimSpace(args), "/", ""
+	}
+
+	if d := msg.Document; d != nil {
+		m.Files = append(m.Files, b.file(d.FileID, d.FileName, d.FileSize, false))
+	}
+	if n := len(msg.Photo); n > 0 {
+		// 同一图片的多个尺寸按从小到大排列，取不超过大小限制的最大尺寸
+		i := n - 1
+		for i > 0 && msg.Photo[i].FileSize > b.cfg.MaxFileBytes {
+			i--
+		}
+		m.Files = append(m.Files, b.file(msg.Photo[i].FileID, "photo.jpg", msg.Photo[i].FileSize, true))
+	}
+	if m.Command == "" && m.Text == "" && len(m.Files) == 0 {
+		return
+	}
+	handler(ctx, m)
+}
+
+// file 消息附带的文件
+func (b *Bot) file(fileID, name string, size int, image bool) connector.File {
+	return connector.File{
+		Name:  name,
+		Size:  size,
+		Image: image,
+		Download: func(ctx context.Context, maxBytes int) ([]byte, error) {
+			return b.api.download(ctx, fileID, maxBytes)
+		},
+	}
+}
+
+// Send 实现 connector.Connector：超长时拆成多条，第一条作为对原消息的回复，返回第一条的 ID
+func (b *Bot) Send(ctx context.Context, target connector.Target, reply *connector.Reply) (string, error) {
+	chatID, err := strconv.ParseInt(target.Channel, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid telegram chat id %q", target.Channel)
+	}
+	replyTo, _ := strconv.ParseInt(target.ReplyTo, 10, 64)
+	parts := splitMessage(render(reply), maxMessageLength)
+	first, err := b.api.sendMessage(ctx, chatID, replyTo, parts[0])
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(first, 10), b.sendRest(ctx, chatID, parts[1:])
+}
+
+// Edit 实现 connector.Connector：替换第一条消息的文本，超长的部分作为新消息发送
+func (b *Bot) Edit(ctx context.Context, target connector.Target, id string, reply *connector.Reply) error {
+	chatID, err := strconv.ParseInt(target.Channel, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid telegram chat id %q", target.Channel)
+	}
+	messageID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid telegram message id %q", id)
+	}
+	parts := splitMessage(render(reply), maxMessageLength)
+	if err := b.api.editMessageText(ctx, chatID, messageID, parts[0]); err != nil {
+		return err
+	}
+	return b.sendRest(ctx, chatID, parts[1:])
+}
+
+// sendRest 依次发送拆分后的其余部分
+func (b *Bot) sendRest(ctx context.Context, chatID int64, parts []string) error {
+	for _, part := range parts {
+		if _, err := b.api.sendMessage(ctx, chatID, 0, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Typing 实现 connector.Connector
+func (b *Bot) Typing(ctx context.Context, target connector.Target) error {
+	chatID, err := strconv.ParseInt(target.Channel, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid telegram chat id %q", target.Channel)
+	}
+	return b.api.sendTyping(ctx, chatID)
+}
+
+// render 把回答状态渲染为纯文本：完成后为回答和页脚，回答过程中为最近的工具调用
+func render(r *connector.Reply) string {
+	switch {
+	case r.Err != "":
+		return "处理失败：" + r.Err
+	case r.Answer != "":
+		if r.Done && r.Footer != "" {
+			return strings.TrimSpace(r.Answer) + "\n\n— " + r.Footer
+		}
+		return r.Answer
+	}
+
+	lines := []string{"思考中…"}
+	tools := r.Tools
+	if skipped := len(tools) - maxToolLines; skipped > 0 {
+		lines = append(lines, fmt.Sprintf("（还有 %d 次较早的工具调用未显示）", skipped))
+		tools = tools[skipped:]
+	}
+	for _, tc := range tools {
+		status := "执行中…"
+		switch {
+		case tc.Error != "":
+			status = "失败"
+		case tc.Done:
+			status = "完成"
+		}
+		lines = append(lines, fmt.Sprintf("🔧 %s：%s", tc.Tool, status))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// deny 未授权的聊天只回复一次，告知其聊天 ID 以便加入 allowed_chats
+func (b *Bot) deny(ctx context.Context, chatID int64) {
+	b.mu.Lock()
+	first := !b.denied[chatID]
+	b.denied[chatID] = true
+	b.mu.Unlock()
+	if !first {
+		return
+	}
+	klog.InfoS("Telegram message from unauthorized chat ignored", "chat", chatID)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+	if _, err := b.api.sendMessage(ctx, chatID, 0, fmt.Sprintf("此聊天未被授权使用该机器人（chat ID：%d）", chatID)); err != nil {
+		klog.ErrorS(err, "Failed to send Telegram message", "chat", chatID)
+	}
+}
+
+// splitMessage 把文本拆成不超过 limit 个字符的段，优先在空行处拆分，其次在换行处，过长的单行硬切
+func splitMessage(text string, limit int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return []string{"（空回答）"}
+	}
+	var parts []string
+	for utf8.RuneCountInString(text) > limit {
+		runes := []rune(text)
+		head := string(runes[:limit])
+		cut := strings.LastIndex(head, "\n\n")
+		if cut < len(head)/2 {
+			cut = strings.LastIndex(head, "\n")
+		}
+		if cut < len(head)/2 {
+			cut = len(head)
+		}
+		parts = append(parts, strings.TrimSpace(head[:cut]))
+		text = strings.TrimSpace(text[cut:])
+	}
+	if text != "" {
+		parts = append(parts, text)
+	}
+	return parts
+}
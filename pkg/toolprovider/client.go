@@ -0,0 +1,198 @@
+// Package toolprovider 通过 gRPC 接入远程工具：组织内部的工具以 ToolProvider 服务（pb/toolprovider.proto）
+// 的形式部署在各自的服务中，Agent 启动时通过 mTLS 连接这些服务并注册它们提供的工具，
+// 无需在 Agent 所在主机上启动 stdio 进程。
+//
+// 用 Go 编写的服务可以直接提供以 pkg/tools 定义的工具：
+//
+//	creds, err := toolprovider.ServerCredentials("server.crt", "server.key", "client-ca.crt")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	log.Fatal(toolprovider.ListenAndServe(":9443", creds, tool))
+package toolprovider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/toolprovider/pb"
+)
+
+// Provider 已连接的远程工具服务
+type Provider struct {
+	name        string
+	conn        *grpc.ClientConn
+	client      pb.ToolProviderClient
+	tools       []*pb.Tool
+	callTimeout time.Duration
+}
+
+// Name 服务名称
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Tools 服务提供的工具
+func (p *Provider) Tools() []*pb.Tool {
+	return p.tools
+}
+
+// Call 调用服务的工具，超过 call_timeout 或 ctx 取消时返回错误
+func (p *Provider) Call(ctx context.Context, name string, args map[string]any) (string, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("marshal arguments: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.callTimeout)
+	defer cancel()
+
+	resp, err := p.client.CallTool(ctx, &pb.CallToolRequest{Name: name, Arguments: string(data)})
+	if err != nil {
+		return "", fmt.Errorf("grpc tool provider %s: %w", p.name, err)
+	}
+	if resp.GetError() != "" {
+		return "", errors.New(resp.GetError())
+	}
+	return resp.GetResult(), nil
+}
+
+// dial 连接服务并获取工具列表；连接断开后 gRPC 会自动重连，工具列表只在启动时获取
+func dial(ctx context.Context, cfg config.GRPCToolConfig) (*Provider, error) {
+	creds, err := clientCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.NewClient(cfg.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Provider{
+		name:        cfg.Name,
+		conn:        conn,
+		client:      pb.NewToolProviderClient(conn),
+		callTimeout: cfg.CallTimeout,
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.DialTimeout)
+	defer cancel()
+	// 等待连接建立，而不是在首次连接失败时立即返回
+	resp, err := p.client.ListTools(ctx, &pb.ListToolsRequest{}, grpc.WaitForReady(true))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("list tools: %w", err)
+	}
+	p.tools = resp.GetTools()
+	return p, nil
+}
+
+// clientCredentials 客户端的传输凭证；客户端证书在每次 TLS 握手时重新读取，轮换证书后重连即可生效
+func clientCredentials(cfg config.GRPCToolConfig) (credentials.TransportCredentials, error) {
+	if cfg.Insecure {
+		return insecure.NewCredentials(), nil
+	}
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+	}
+	if cfg.CAFile != "" {
+		pool, err := loadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		// 启动时先加载一次，尽早发现证书配置错误
+		if _, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile); err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsCfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("load client certificate: %w", err)
+			}
+			return &cert, nil
+		}
+	}
+	return credentials.NewTLS(tlsCfg), nil
+}
+
+// loadCertPool 读取 PEM 格式的 CA 证书
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read ca file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in ca file %s", path)
+	}
+	return pool, nil
+}
+
+// Manager 已连接的远程工具服务，nil 表示未配置
+type Manager struct {
+	providers []*Provider
+}
+
+// Start 同时连接所有配置的服务，单个服务连接失败时记录日志并跳过；未配置服务时返回 nil
+func Start(ctx context.Context, cfgs []config.GRPCToolConfig) *Manager {
+	if len(cfgs) == 0 {
+		return nil
+	}
+	providers := make([]*Provider, len(cfgs))
+	var wg sync.WaitGroup
+	for i, cfg := range cfgs {
+		wg.Go(func() {
+			p, err := dial(ctx, cfg)
+			if err != nil {
+				klog.ErrorS(err, "Failed to connect gRPC tool provider", "provider", cfg.Name, "address", cfg.Address)
+				return
+			}
+			klog.InfoS("gRPC tool provider connected", "provider", cfg.Name, "address", cfg.Address, "tools", len(p.tools))
+			providers[i] = p
+		})
+	}
+	wg.Wait()
+
+	m := &Manager{}
+	for _, p := range providers {
+		if p != nil {
+			m.providers = append(m.providers, p)
+		}
+	}
+	return m
+}
+
+// Providers 返回已连接的服务
+func (m *Manager) Providers() []*Provider {
+	if m == nil {
+		return nil
+	}
+	return m.providers
+}
+
+// Close 关闭所有连接
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+	for _, p := range m.providers {
+		if err := p.conn.Close(); err != nil {
+			klog.ErrorS(err, "Failed to close gRPC tool provider connection", "provider", p.name)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package toolprovider
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"github.com/champly/ai-agent/pkg/toolprovider/pb"
+	"github.com/champly/ai-agent/pkg/tools"
+)
+
+// server 以 pkg/tools 定义的工具实现 ToolProvider 服务
+type server struct {
+	pb.UnimplementedToolProviderServer
+	tools map[string]*tools.Tool
+	specs []*pb.Tool
+}
+
+// NewServer 返回提供这些工具的 ToolProvider 服务，用 pb.RegisterToolProviderServer 注册到已有的 gRPC 服务器
+func NewServer(ts ...*tools.Tool) (pb.ToolProviderServer, error) {
+	s := &server{tools: make(map[string]*tools.Tool, len(ts))}
+	for _, t := range ts {
+		if _, ok := s.tools[t.Name()]; ok {
+			return nil, fmt.Errorf("duplicate tool name: %s", t.Name())
+		}
+		s.tools[t.Name()] = t
+		schema, err := json.Marshal(t.InputSchema())
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: %w", t.Name(), err)
+		}
+		s.specs = append(s.specs, &pb.Tool{Name: t.Name(), Description: t.Description(), InputSchema: string(schema)})
+	}
+	return s, nil
+}
+
+// ListenAndServe 在 addr 上提供这些工具，直到监听失败
+func ListenAndServe(addr string, creds credentials.TransportCredentials, ts ...*tools.Tool) error {
+	svc, err := NewServer(ts...)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(grpc.Creds(creds))
+	pb.RegisterToolProviderServer(srv, svc)
+	return srv.Serve(l)
+}
+
+// ServerCredentials 服务端的 mTLS 凭证：要求客户端提供由 clientCAFile 签发的证书
+func ServerCredentials(certFile, keyFile, clientCAFile string) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+	pool, err := loadCertPool(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(&tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}), nil
+}
+
+// ListTools 返回提供的工具
+func (s *server) ListTools(context.Context, *pb.ListToolsRequest) (*pb.ListToolsResponse, error) {
+	return &pb.ListToolsResponse{Tools: s.specs}, nil
+}
+
+// CallTool 调用工具，工具返回的错误放在响应的 error 中
+func (s *server) CallTool(ctx context.Context, req *pb.CallToolRequest) (*pb.CallToolResponse, error) {
+	tool, ok := s.tools[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "tool not found: %s", req.GetName())
+	}
+	var args map[string]any
+	if req.GetArguments() != "" {
+		if err := json.Unmarshal([]byte(req.GetArguments()), &args); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid arguments: %v", err)
+		}
+	}
+	result, err := tool.Call(ctx, args)
+	if err != nil {
+		return &pb.CallToolResponse{Error: err.Error()}, nil
+	}
+	return &pb.CallToolResponse{Result: result}, nil
+}
@@ -0,0 +1,121 @@
+// Package tools 把普通的 Go 函数注册为 Agent 工具：参数的 JSON Schema 由参数结构体的 json 和 jsonschema 标签生成，
+// 嵌入本项目的程序无需另写 MCP 服务器进程即可添加自定义工具。
+//
+//	type WeatherArgs struct {
+//		City string `json:"city" jsonschema:"城市名称"`
+//		Days int    `json:"days,omitempty" jsonschema:"预报天数，默认 1"`
+//	}
+//
+//	tool, err := tools.New("get_weather", "查询城市天气", func(ctx context.Context, in WeatherArgs) (string, error) {
+//		...
+//	})
+//	err = ag.RegisterTool(tool)
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// namePattern 工具名允许的字符（与 Ollama 和 MCP 的工具名要求一致）
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Tool 由 Go 函数实现的工具
+type Tool struct {
+	name        string
+	description string
+	schema      map[string]any
+	resolved    *jsonschema.Resolved
+	call        func(ctx context.Context, args []byte) (string, error)
+}
+
+// New 以函数 fn 创建工具。In 为参数结构体：json 标签决定参数名，jsonschema 标签为参数说明，
+// 带 omitempty 或 omitzero 的字段为可选参数，其余为必填。fn 返回 string 时直接作为工具结果，
+// 其他类型序列化为 JSON；返回错误时错误信息作为工具调用失败的原因交给模型
+func New[In, Out any](name, description string, fn func(ctx context.Context, in In) (Out, error)) (*Tool, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid tool name %q", name)
+	}
+	schema, err := jsonschema.For[In](nil)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %w", name, err)
+	}
+	if schema.Type != "object" {
+		return nil, fmt.Errorf("tool %s: arguments must be a struct", name)
+	}
+	// 模型常会多传参数，忽略未定义的参数而不是拒绝调用
+	schema.AdditionalProperties = nil
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %w", name, err)
+	}
+
+	// 工具定义使用与 MCP 工具相同的 map 形式
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %w", name, err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("tool %s: %w", name, err)
+	}
+
+	return &Tool{
+		name:        name,
+		description: description,
+		schema:      m,
+		resolved:    resolved,
+		call: func(ctx context.Context, args []byte) (string, error) {
+			var in In
+			if err := json.Unmarshal(args, &in); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			out, err := fn(ctx, in)
+			if err != nil {
+				return "", err
+			}
+			if s, ok := any(out).(string); ok {
+				return s, nil
+			}
+			result, err := json.Marshal(out)
+			if err != nil {
+				return "", fmt.Errorf("marshal result: %w", err)
+			}
+			return string(result), nil
+		},
+	}, nil
+}
+
+// Name 工具名
+func (t *Tool) Name() string {
+	return t.name
+}
+
+// Description 工具说明
+func (t *Tool) Description() string {
+	return t.description
+}
+
+// InputSchema 参数的 JSON Schema
+func (t *Tool) InputSchema() map[string]any {
+	return t.schema
+}
+
+// Call 按 Schema 校验参数后调用函数
+func (t *Tool) Call(ctx context.Context, args map[string]any) (string, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	if err := t.resolved.Validate(args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	return t.call(ctx, data)
+}
@@ -0,0 +1,111 @@
+// Package toolstats 按工具统计调用次数、失败率、耗时分位数和最近一次调用时间，
+// 用于找出长期未使用的工具和不稳定的工具服务。统计保存在内存中，每个副本各自统计，重启后清零
+package toolstats
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencyWindow 计算耗时分位数时保留的最近调用数
+const latencyWindow = 512
+
+// Stats 工具的调用统计
+type Stats struct {
+	Tool     string     `json:"tool"`
+	Source   string     `json:"source"`
+	Calls    int64      `json:"calls"`
+	Errors   int64      `json:"errors"`
+	LastUsed *time.Time `json:"last_used,omitempty"` // 从未调用时为空
+
+	ErrorRate float64 `json:"error_rate"`
+	// 最近 latencyWindow 次调用的耗时分位数（毫秒）
+	P50Ms float64 `json:"p50_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+}
+
+// entry 单个工具的累计数据
+type entry struct {
+	calls    int64
+	errors   int64
+	lastUsed time.Time
+	// latencies 最近调用的耗时，写满后从 next 处循环覆盖
+	latencies []time.Duration
+	next      int
+}
+
+// Recorder 工具调用统计
+type Recorder struct {
+	mu    sync.Mutex
+	tools map[string]*entry
+	since time.Time
+}
+
+// New 创建统计
+func New() *Recorder {
+	return &Recorder{tools: make(map[string]*entry), since: time.Now()}
+}
+
+// Since 开始统计的时间
+func (r *Recorder) Since() time.Time {
+	return r.since
+}
+
+// Record 记录一次工具调用：d 为执行耗时，failed 表示执行失败
+func (r *Recorder) Record(tool string, d time.Duration, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.tools[tool]
+	if !ok {
+		e = &entry{}
+		r.tools[tool] = e
+	}
+	e.calls++
+	if failed {
+		e.errors++
+	}
+	e.lastUsed = time.Now()
+	if len(e.latencies) < latencyWindow {
+		e.latencies = append(e.latencies, d)
+	} else {
+		e.latencies[e.next] = d
+		e.next = (e.next + 1) % latencyWindow
+	}
+}
+
+// Get 返回工具的统计，source 为工具当前的来源；从未调用时只有名称和来源
+func (r *Recorder) Get(tool, source string) Stats {
+	r.mu.Lock()
+	e, ok := r.tools[tool]
+	var latencies []time.Duration
+	s := Stats{Tool: tool, Source: source}
+	if ok {
+		s.Calls, s.Errors = e.calls, e.errors
+		lastUsed := e.lastUsed
+		s.LastUsed = &lastUsed
+		latencies = slices.Clone(e.latencies)
+	}
+	r.mu.Unlock()
+
+	if s.Calls > 0 {
+		s.ErrorRate = float64(s.Errors) / float64(s.Calls)
+	}
+	slices.Sort(latencies)
+	s.P50Ms = percentile(latencies, 0.50)
+	s.P95Ms = percentile(latencies, 0.95)
+	s.P99Ms = percentile(latencies, 0.99)
+	return s
+}
+
+// percentile 已排序耗时的分位数（最近秩法），单位毫秒
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	i = min(max(i, 0), len(sorted)-1)
+	return float64(sorted[i].Microseconds()) / 1000
+}
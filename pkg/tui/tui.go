@@ -0,0 +1,281 @@
+// Package tui 实现基于 bubbletea 的终端交互界面
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/champly/ai-agent/pkg/agent"
+)
+
+// 最多保留的工具活动条目数
+const maxActivities = 200
+
+var (
+	paneStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("240"))
+	focusStyle = paneStyle.BorderForeground(lipgloss.Color("63"))
+	titleStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63"))
+	userStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("39"))
+	botStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("170"))
+	errStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	dimStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+)
+
+// eventMsg 对话循环事件
+type eventMsg agent.Event
+
+// replyMsg 对话完成
+type replyMsg struct {
+	resp *agent.ChatResponse
+	err  error
+}
+
+// activity 工具活动记录
+type activity struct {
+	tool     string
+	args     string
+	result   string
+	failed   bool
+	finished bool
+	at       time.Time
+}
+
+// model bubbletea 模型
+type model struct {
+	ctx     context.Context
+	agent   *agent.Agent
+	program *tea.Program
+	model   string
+
+	conversationID string
+	busy           bool
+	transcript     strings.Builder
+	activities     []activity
+
+	chat    viewport.Model
+	tools   viewport.Model
+	preview viewport.Model
+	input   textarea.Model
+
+	width  int
+	height int
+}
+
+// Run 启动 TUI，直到用户退出
+func Run(ctx context.Context, ag *agent.Agent, modelName string) error {
+	input := textarea.New()
+	input.Placeholder = "输入消息，Enter 发送，Ctrl+C 退出"
+	input.ShowLineNumbers = false
+	input.SetHeight(3)
+	input.Focus()
+
+	m := &model{
+		ctx:     ctx,
+		agent:   ag,
+		model:   modelName,
+		chat:    viewport.New(0, 0),
+		tools:   viewport.New(0, 0),
+		preview: viewport.New(0, 0),
+		input:   input,
+	}
+
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx))
+	m.program = p
+
+	_, err := p.Run()
+	return err
+}
+
+// Init 实现 tea.Model
+func (m *model) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+// Update 实现 tea.Model
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.layout()
+
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC, tea.KeyEsc:
+			return m, tea.Quit
+		case tea.KeyEnter:
+			if cmd := m.send(); cmd != nil {
+				cmds = append(cmds, cmd)
+			}
+			return m, tea.Batch(cmds...)
+		case tea.KeyPgUp, tea.KeyPgDown:
+			var cmd tea.Cmd
+			m.chat, cmd = m.chat.Update(msg)
+			return m, cmd
+		}
+
+	case eventMsg:
+		m.handleEvent(agent.Event(msg))
+
+	case replyMsg:
+		m.busy = false
+		if msg.err != nil {
+			m.appendTranscript(errStyle.Render("错误: " + msg.err.Error()))
+		} else {
+			m.conversationID = msg.resp.ConversationID
+			m.appendTranscript(botStyle.Render("Agent") + "\n" + msg.resp.Response)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	cmds = append(cmds, cmd)
+
+	return m, tea.Batch(cmds...)
+}
+
+// View 实现 tea.Model
+func (m *model) View() string {
+	if m.width == 0 {
+		return "加载中..."
+	}
+
+	status := dimStyle.Render(fmt.Sprintf("model: %s  conversation: %s", m.model, m.conversationID))
+	if m.busy {
+		status += "  " + titleStyle.Render("思考中...")
+	}
+
+	left := focusStyle.Render(titleStyle.Render("对话") + "\n" + m.chat.View())
+	right := lipgloss.JoinVertical(lipgloss.Left,
+		paneStyle.Render(titleStyle.Render("工具调用")+"\n"+m.tools.View()),
+		paneStyle.Render(titleStyle.Render("工具输出")+"\n"+m.preview.View()),
+	)
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.JoinHorizontal(lipgloss.Top, left, right),
+		paneStyle.Render(m.input.View()),
+		status,
+	)
+}
+
+// layout 根据窗口大小计算各面板尺寸
+func (m *model) layout() {
+	// 边框占用 2 列/行，标题占用 1 行
+	inputHeight := m.input.Height() + 2
+	bodyHeight := m.height - inputHeight - 1
+	leftWidth := m.width * 3 / 5
+	rightWidth := m.width - leftWidth
+
+	m.chat.Width = leftWidth - 2
+	m.chat.Height = bodyHeight - 3
+
+	rightInner := rightWidth - 2
+	toolsHeight := bodyHeight / 2
+	m.tools.Width = rightInner
+	m.tools.Height = toolsHeight - 3
+	m.preview.Width = rightInner
+	m.preview.Height = bodyHeight - toolsHeight - 3
+
+	m.input.SetWidth(m.width - 2)
+
+	m.refresh()
+}
+
+// send 发送当前输入
+func (m *model) send() tea.Cmd {
+	text := strings.TrimSpace(m.input.Value())
+	if text == "" || m.busy {
+		return nil
+	}
+	m.input.Reset()
+	m.busy = true
+	m.appendTranscript(userStyle.Render("You") + "\n" + text)
+
+	req := &agent.ChatRequest{
+		Message:        text,
+		ConversationID: m.conversationID,
+		Model:          m.model,
+		OnEvent: func(ev agent.Event) {
+			m.program.Send(eventMsg(ev))
+		},
+	}
+
+	return func() tea.Msg {
+		resp, err := m.agent.Chat(m.ctx, req)
+		return replyMsg{resp: resp, err: err}
+	}
+}
+
+// handleEvent 处理对话循环事件
+func (m *model) handleEvent(ev agent.Event) {
+	switch ev.Type {
+	case agent.EventToolCall:
+		args, _ := json.Marshal(ev.Arguments)
+		m.activities = append(m.activities, activity{
+			tool: ev.Tool,
+			args: string(args),
+			at:   time.Now(),
+		})
+		if len(m.activities) > maxActivities {
+			m.activities = m.activities[len(m.activities)-maxActivities:]
+		}
+	case agent.EventToolResult:
+		// 匹配最近一次未完成的同名调用
+		for i := len(m.activities) - 1; i >= 0; i-- {
+			act := &m.activities[i]
+			if act.tool == ev.Tool && !act.finished {
+				act.finished = true
+				act.result = ev.Result
+				act.failed = ev.Error != ""
+				break
+			}
+		}
+	}
+	m.refresh()
+}
+
+// appendTranscript 追加对话内容
+func (m *model) appendTranscript(text string) {
+	if m.transcript.Len() > 0 {
+		m.transcript.WriteString("\n\n")
+	}
+	m.transcript.WriteString(text)
+	m.refresh()
+}
+
+// refresh 刷新面板内容
+func (m *model) refresh() {
+	wrap := lipgloss.NewStyle().Width(m.chat.Width)
+	m.chat.SetContent(wrap.Render(m.transcript.String()))
+	m.chat.GotoBottom()
+
+	var sb strings.Builder
+	for _, act := range m.activities {
+		state := dimStyle.Render("…")
+		switch {
+		case act.failed:
+			state = errStyle.Render("✗")
+		case act.finished:
+			state = "✓"
+		}
+		fmt.Fprintf(&sb, "%s %s %s %s\n", dimStyle.Render(act.at.Format("15:04:05")), state, act.tool, dimStyle.Render(act.args))
+	}
+	m.tools.SetContent(lipgloss.NewStyle().Width(m.tools.Width).Render(sb.String()))
+	m.tools.GotoBottom()
+
+	if n := len(m.activities); n > 0 {
+		last := m.activities[n-1]
+		m.preview.SetContent(lipgloss.NewStyle().Width(m.preview.Width).Render(last.result))
+	}
+}
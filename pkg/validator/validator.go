@@ -0,0 +1,172 @@
+// Package validator 最终回答的校验规则：正则、JSON、最大长度和语言。
+// 规则不通过时返回给模型的修改要求，由 Agent 要求模型重新回答
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// ErrNotFound 请求指定的校验规则未配置
+var ErrNotFound = errors.New("output validator not found")
+
+// Validator 单条校验规则
+type Validator struct {
+	cfg config.OutputValidatorConfig
+	re  *regexp.Regexp
+}
+
+// Name 规则名称
+func (v *Validator) Name() string {
+	return v.cfg.Name
+}
+
+// Check 校验回答，不通过时返回的错误为给模型的修改要求
+func (v *Validator) Check(content string) error {
+	var problem string
+	switch v.cfg.Type {
+	case "regex":
+		if v.re.MatchString(content) == v.cfg.Forbid {
+			problem = fmt.Sprintf("回答需要匹配正则表达式 %s", v.cfg.Pattern)
+			if v.cfg.Forbid {
+				problem = fmt.Sprintf("回答不能包含匹配正则表达式 %s 的内容", v.cfg.Pattern)
+			}
+		}
+	case "json":
+		if !json.Valid([]byte(strings.TrimSpace(content))) {
+			problem = "回答必须是合法的 JSON，不要包含 JSON 以外的任何文字或代码块标记"
+		}
+	case "max_length":
+		if n := utf8.RuneCountInString(content); n > v.cfg.MaxLength {
+			problem = fmt.Sprintf("回答过长（%d 字符），请精简到 %d 字符以内", n, v.cfg.MaxLength)
+		}
+	case "language":
+		if !isLanguage(content, v.cfg.Language) {
+			problem = "请使用" + languageNames[v.cfg.Language] + "回答"
+		}
+	}
+	if problem == "" {
+		return nil
+	}
+	if v.cfg.Message != "" {
+		problem = v.cfg.Message
+	}
+	return fmt.Errorf("%s: %s", v.cfg.Name, problem)
+}
+
+// languageNames 支持的语言
+var languageNames = map[string]string{"zh": "中文", "en": "英文"}
+
+// isLanguage 粗略判断回答的语言：比较汉字数和英文单词数（一个汉字大致相当于一个单词），忽略代码块。
+// 中文要求汉字占比不低于 30%（技术回答中常夹杂英文命令和名词），英文要求汉字占比不超过 10%
+func isLanguage(content, language string) bool {
+	var han, words int
+	for i, part := range strings.Split(content, "```") {
+		if i%2 == 1 {
+			continue
+		}
+		inWord := false
+		for _, r := range part {
+			isLetter := unicode.IsLetter(r) && !unicode.Is(unicode.Han, r)
+			if unicode.Is(unicode.Han, r) {
+				han++
+			} else if isLetter && !inWord {
+				words++
+			}
+			inWord = isLetter
+		}
+	}
+	total := han + words
+	if total == 0 {
+		return true
+	}
+	ratio := float64(han) / float64(total)
+	if language == "zh" {
+		return ratio >= 0.3
+	}
+	return ratio <= 0.1
+}
+
+// Set 配置的校验规则，nil 表示未配置
+type Set struct {
+	byName   map[string]*Validator
+	defaults []*Validator
+}
+
+// New 编译校验规则，未配置时返回 nil
+func New(cfgs []config.OutputValidatorConfig) (*Set, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+	s := &Set{byName: make(map[string]*Validator, len(cfgs))}
+	for _, cfg := range cfgs {
+		v := &Validator{cfg: cfg}
+		switch cfg.Type {
+		case "regex":
+			if cfg.Pattern == "" {
+				return nil, fmt.Errorf("output validator %s: pattern is required", cfg.Name)
+			}
+			re, err := regexp.Compile(cfg.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("output validator %s: %w", cfg.Name, err)
+			}
+			v.re = re
+		case "json":
+		case "max_length":
+			if cfg.MaxLength <= 0 {
+				return nil, fmt.Errorf("output validator %s: max_length must be positive", cfg.Name)
+			}
+		case "language":
+			if _, ok := languageNames[cfg.Language]; !ok {
+				return nil, fmt.Errorf("output validator %s: unsupported language %q", cfg.Name, cfg.Language)
+			}
+		default:
+			return nil, fmt.Errorf("output validator %s: unknown type %q", cfg.Name, cfg.Type)
+		}
+		s.byName[cfg.Name] = v
+		if cfg.Default {
+			s.defaults = append(s.defaults, v)
+		}
+	}
+	return s, nil
+}
+
+// Select 返回对所有请求生效的规则加上 names 指定的规则（去重），names 中有未配置的规则时返回 ErrNotFound
+func (s *Set) Select(names []string) ([]*Validator, error) {
+	var selected []*Validator
+	if s != nil {
+		selected = append(selected, s.defaults...)
+	}
+	for _, name := range names {
+		var v *Validator
+		if s != nil {
+			v = s.byName[name]
+		}
+		if v == nil {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		if !slices.Contains(selected, v) {
+			selected = append(selected, v)
+		}
+	}
+	return selected, nil
+}
+
+// Check 按顺序执行规则，返回所有不通过的规则给出的修改要求
+func Check(validators []*Validator, content string) []string {
+	var problems []string
+	for _, v := range validators {
+		if err := v.Check(content); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
@@ -0,0 +1,88 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/champly/ai-agent/pkg/agent"
+)
+
+// notifyTimeout 推送请求超时
+const notifyTimeout = 30 * time.Second
+
+// notifier 诊断结果推送
+type notifier struct {
+	webhookURL string
+	slackURL   string
+	client     *http.Client
+}
+
+// newNotifier 创建推送器，未配置任何地址时仅记录日志
+func newNotifier(webhookURL, slackURL string) *notifier {
+	return &notifier{
+		webhookURL: webhookURL,
+		slackURL:   slackURL,
+		client:     &http.Client{Timeout: notifyTimeout},
+	}
+}
+
+// webhookPayload 通用 Webhook 请求体
+type webhookPayload struct {
+	Incident       Incident `json:"incident"`
+	ConversationID string   `json:"conversation_id"`
+	Analysis       string   `json:"analysis"`
+}
+
+// notify 推送诊断结果到已配置的目标
+func (n *notifier) notify(ctx context.Context, incident Incident, resp *agent.ChatResponse) error {
+	var errs []error
+	if n.webhookURL != "" {
+		payload := webhookPayload{
+			Incident:       incident,
+			ConversationID: resp.ConversationID,
+			Analysis:       resp.Response,
+		}
+		if err := n.post(ctx, n.webhookURL, payload); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+	}
+	if n.slackURL != "" {
+		text := fmt.Sprintf("*Kubernetes 告警诊断* `%s/%s %s` (%s)\n> %s\n\n%s\n\n_conversation: %s_",
+			incident.Namespace, incident.Kind, incident.Name, incident.Reason, incident.Message,
+			resp.Response, resp.ConversationID)
+		if err := n.post(ctx, n.slackURL, map[string]string{"text": text}); err != nil {
+			errs = append(errs, fmt.Errorf("slack: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// post 以 JSON 格式发送 POST 请求
+func (n *notifier) post(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+// Package watcher 监听 Kubernetes 告警事件，自动发起诊断对话并推送结果
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/agent"
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/k8stools"
+)
+
+// analyzeTimeout 单次诊断对话超时
+const analyzeTimeout = 10 * time.Minute
+
+// Incident 触发诊断的告警事件
+type Incident struct {
+	Namespace string    `json:"namespace"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+	Count     int32     `json:"count"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// key 去重键：同一对象的同一原因视为同一告警
+func (i Incident) key() string {
+	return i.Namespace + "/" + i.Kind + "/" + i.Name + "/" + i.Reason
+}
+
+// Watcher Kubernetes 事件监听器
+type Watcher struct {
+	cfg        config.WatcherConfig
+	agent      *agent.Agent
+	clientset  kubernetes.Interface
+	namespaces []string
+	selector   labels.Selector
+	prompt     *template.Template
+	notifier   *notifier
+
+	mu   sync.Mutex
+	seen map[string]time.Time // 去重键 -> 最近一次触发时间
+}
+
+// New 创建事件监听器
+func New(cfg config.WatcherConfig, ag *agent.Agent) (*Watcher, error) {
+	restCfg, namespace, err := k8stools.LoadRESTConfig(cfg.Kubeconfig, "")
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("create kubernetes client: %w", err)
+	}
+
+	selector := labels.Everything()
+	if cfg.LabelSelector != "" {
+		selector, err = labels.Parse(cfg.LabelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("parse label selector: %w", err)
+		}
+	}
+
+	prompt, err := template.New("prompt").Parse(cfg.Prompt)
+	if err != nil {
+		return nil, fmt.Errorf("parse watcher prompt: %w", err)
+	}
+
+	namespaces := cfg.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{namespace}
+	}
+	if slices.Contains(namespaces, k8stools.AllNamespaces) {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	return &Watcher{
+		cfg:        cfg,
+		agent:      ag,
+		clientset:  clientset,
+		namespaces: namespaces,
+		selector:   selector,
+		prompt:     prompt,
+		notifier:   newNotifier(cfg.WebhookURL, cfg.SlackWebhookURL),
+		seen:       make(map[string]time.Time),
+	}, nil
+}
+
+// Run 开始监听直到 ctx 结束，适合作为主节点后台任务运行
+func (w *Watcher) Run(ctx context.Context) {
+	started := time.Now()
+	queue := make(chan Incident, w.cfg.QueueSize)
+
+	var wg sync.WaitGroup
+	for range w.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case incident := <-queue:
+					w.analyze(ctx, incident)
+				}
+			}
+		}()
+	}
+
+	for _, ns := range w.namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(w.clientset, 0,
+			informers.WithNamespace(ns),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.FieldSelector = fields.OneTermEqualSelector("type", corev1.EventTypeWarning).String()
+			}))
+
+		handle := func(obj any) {
+			event, ok := obj.(*corev1.Event)
+			if !ok || eventTime(event).Before(started) {
+				return
+			}
+			w.handleEvent(ctx, event, queue)
+		}
+		factory.Core().V1().Events().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    handle,
+			UpdateFunc: func(_, obj any) { handle(obj) },
+		})
+		factory.Start(ctx.Done())
+		defer factory.Shutdown()
+	}
+
+	klog.InfoS("Kubernetes event watcher started", "namespaces", w.namespaces, "reasons", w.cfg.Reasons)
+	<-ctx.Done()
+	wg.Wait()
+	klog.InfoS("Kubernetes event watcher stopped")
+}
+
+// handleEvent 过滤事件并加入诊断队列
+func (w *Watcher) handleEvent(ctx context.Context, event *corev1.Event, queue chan<- Incident) {
+	if !slices.Contains(w.cfg.Reasons, event.Reason) {
+		return
+	}
+
+	incident := Incident{
+		Namespace: event.InvolvedObject.Namespace,
+		Kind:      event.InvolvedObject.Kind,
+		Name:      event.InvolvedObject.Name,
+		Reason:    event.Reason,
+		Message:   event.Message,
+		Count:     event.Count,
+		Timestamp: eventTime(event),
+	}
+	if incident.Namespace == "" {
+		incident.Namespace = event.Namespace
+	}
+
+	if !w.matchSelector(ctx, incident) || !w.markSeen(incident) {
+		return
+	}
+
+	select {
+	case queue <- incident:
+		klog.InfoS("Kubernetes incident queued", "namespace", incident.Namespace, "kind", incident.Kind,
+			"name", incident.Name, "reason", incident.Reason)
+	default:
+		klog.InfoS("Watcher queue full, incident dropped", "namespace", incident.Namespace, "kind", incident.Kind,
+			"name", incident.Name, "reason", incident.Reason)
+	}
+}
+
+// matchSelector 检查事件关联的 Pod 是否匹配标签选择器
+func (w *Watcher) matchSelector(ctx context.Context, incident Incident) bool {
+	if w.selector.Empty() {
+		return true
+	}
+	if incident.Kind != "Pod" {
+		return false
+	}
+
+	pod, err := w.clientset.CoreV1().Pods(incident.Namespace).Get(ctx, incident.Name, metav1.GetOptions{})
+	if err != nil {
+		klog.V(2).InfoS("Failed to get pod for event", "namespace", incident.Namespace, "name", incident.Name, "err", err)
+		return false
+	}
+	return w.selector.Matches(labels.Set(pod.Labels))
+}
+
+// markSeen 记录告警触发时间，冷却期内的重复告警返回 false
+func (w *Watcher) markSeen(incident Incident) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	for key, t := range w.seen {
+		if now.Sub(t) >= w.cfg.Cooldown {
+			delete(w.seen, key)
+		}
+	}
+
+	key := incident.key()
+	if _, ok := w.seen[key]; ok {
+		return false
+	}
+	w.seen[key] = now
+	return true
+}
+
+// analyze 发起诊断对话并推送结果
+func (w *Watcher) analyze(ctx context.Context, incident Incident) {
+	var prompt bytes.Buffer
+	if err := w.prompt.Execute(&prompt, incident); err != nil {
+		klog.ErrorS(err, "Failed to render watcher prompt")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, analyzeTimeout)
+	defer cancel()
+
+	klog.InfoS("Analyzing Kubernetes incident", "namespace", incident.Namespace, "kind", incident.Kind,
+		"name", incident.Name, "reason", incident.Reason)
+	resp, err := w.agent.Chat(ctx, &agent.ChatRequest{Message: prompt.String()})
+	if err != nil {
+		klog.ErrorS(err, "Failed to analyze Kubernetes incident", "namespace", incident.Namespace, "name", incident.Name)
+		return
+	}
+
+	if err := w.notifier.notify(ctx, incident, resp); err != nil {
+		klog.ErrorS(err, "Failed to send incident analysis", "namespace", incident.Namespace, "name", incident.Name)
+		return
+	}
+	klog.InfoS("Kubernetes incident analyzed", "namespace", incident.Namespace, "name", incident.Name,
+		"conversationID", resp.ConversationID)
+}
+
+// eventTime 返回事件最近一次发生的时间
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
@@ -0,0 +1,253 @@
+// Package webhook 接收外部系统的入站 webhook（Alertmanager、GitHub 或任意 JSON），
+// 校验请求来源后按配置的模板把事件渲染为提示，交给 Agent 作为后台任务处理
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+var (
+	// ErrNotFound webhook 未配置
+	ErrNotFound = errors.New("webhook not found")
+	// ErrUnauthorized 签名或 token 校验失败
+	ErrUnauthorized = errors.New("invalid webhook signature")
+	// ErrInvalidPayload 负载无法解析或模板渲染失败
+	ErrInvalidPayload = errors.New("invalid webhook payload")
+)
+
+// Alert Alertmanager 负载中的单条告警
+type Alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// alertmanagerPayload Alertmanager webhook 负载（version 4）
+type alertmanagerPayload struct {
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []*Alert          `json:"alerts"`
+}
+
+// Data 提示模板的数据
+type Data struct {
+	Webhook string // webhook 名称
+	Event   string // github 为 X-GitHub-Event，alertmanager 为告警状态，json 为空
+	Action  string // github 负载中的 action
+	Payload any    // 解码后的完整负载
+	Alert   *Alert // alertmanager 格式下当前处理的告警，每条告警单独生成一个提示
+}
+
+// funcs 提示模板中可用的函数
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.MarshalIndent(v, "", "  ")
+		return string(b), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"truncate": func(n int, s string) string {
+		if len(s) <= n {
+			return s
+		}
+		return strings.ToValidUTF8(s[:n], "")
+	},
+}
+
+// Hook 一个已配置的 webhook
+type Hook struct {
+	Config config.WebhookConfig
+
+	prompt *template.Template
+
+	mu     sync.Mutex
+	recent map[[sha256.Size]byte]time.Time // 提示摘要到上次处理时间，用于 cooldown 去重
+}
+
+// Receiver 已配置的入站 webhook，nil 表示未配置
+type Receiver struct {
+	hooks map[string]*Hook
+}
+
+// New 编译所有 webhook 的提示模板；未配置 webhook 时返回 nil
+func New(cfgs []config.WebhookConfig) (*Receiver, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+
+	r := &Receiver{hooks: make(map[string]*Hook, len(cfgs))}
+	for _, cfg := range cfgs {
+		t, err := template.New(cfg.Name).Funcs(funcs).Option("missingkey=zero").Parse(cfg.Prompt)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %s: parse prompt: %w", cfg.Name, err)
+		}
+		r.hooks[cfg.Name] = &Hook{Config: cfg, prompt: t, recent: make(map[[sha256.Size]byte]time.Time)}
+	}
+
+	klog.InfoS("Webhooks configured", "count", len(r.hooks))
+	return r, nil
+}
+
+// Receive 校验请求并返回需要处理的提示；事件被过滤或在 cooldown 内重复时返回空列表
+func (r *Receiver) Receive(name string, header http.Header, body []byte) (*Hook, []string, error) {
+	var hook *Hook
+	if r != nil {
+		hook = r.hooks[name]
+	}
+	if hook == nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err := hook.verify(header, body); err != nil {
+		return nil, nil, err
+	}
+
+	events, err := hook.parse(header, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prompts := make([]string, 0, len(events))
+	for _, data := range events {
+		if !hook.accepts(data) {
+			klog.V(2).InfoS("Webhook event ignored", "webhook", name, "event", data.Event, "action", data.Action)
+			continue
+		}
+		var sb strings.Builder
+		if err := hook.prompt.Execute(&sb, data); err != nil {
+			return nil, nil, fmt.Errorf("%w: render prompt: %v", ErrInvalidPayload, err)
+		}
+		prompt := sb.String()
+		if !hook.claim(prompt) {
+			klog.V(2).InfoS("Webhook event suppressed by cooldown", "webhook", name, "event", data.Event)
+			continue
+		}
+		prompts = append(prompts, prompt)
+	}
+	return hook, prompts, nil
+}
+
+// verify 校验请求来源：github 使用 HMAC-SHA256 签名，其他格式使用 Bearer token
+func (h *Hook) verify(header http.Header, body []byte) error {
+	if h.Config.Format == "github" {
+		sig, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+		if !ok {
+			return ErrUnauthorized
+		}
+		got, err := hex.DecodeString(sig)
+		if err != nil {
+			return ErrUnauthorized
+		}
+		mac := hmac.New(sha256.New, []byte(h.Config.Secret))
+		mac.Write(body)
+		if !hmac.Equal(got, mac.Sum(nil)) {
+			return ErrUnauthorized
+		}
+		return nil
+	}
+
+	token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.Config.Secret)) != 1 {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// parse 按格式解析负载，每个元素生成一个提示
+func (h *Hook) parse(header http.Header, body []byte) ([]*Data, error) {
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	base := Data{Webhook: h.Config.Name, Payload: payload}
+
+	switch h.Config.Format {
+	case "alertmanager":
+		var am alertmanagerPayload
+		if err := json.Unmarshal(body, &am); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+		events := make([]*Data, 0, len(am.Alerts))
+		for _, alert := range am.Alerts {
+			if alert == nil {
+				continue
+			}
+			data := base
+			data.Event = alert.Status
+			data.Alert = alert
+			events = append(events, &data)
+		}
+		return events, nil
+
+	case "github":
+		base.Event = header.Get("X-GitHub-Event")
+		if base.Event == "" {
+			return nil, fmt.Errorf("%w: missing X-GitHub-Event header", ErrInvalidPayload)
+		}
+		// 创建 webhook 时 GitHub 发送的测试事件
+		if base.Event == "ping" {
+			return nil, nil
+		}
+		if obj, ok := payload.(map[string]any); ok {
+			base.Action, _ = obj["action"].(string)
+		}
+		return []*Data{&base}, nil
+	}
+	return []*Data{&base}, nil
+}
+
+// accepts 事件是否在 events 列表中，github 事件可按 事件 或 事件.action 匹配
+func (h *Hook) accepts(data *Data) bool {
+	if len(h.Config.Events) == 0 {
+		return true
+	}
+	if slices.Contains(h.Config.Events, data.Event) {
+		return true
+	}
+	return data.Action != "" && slices.Contains(h.Config.Events, data.Event+"."+data.Action)
+}
+
+// claim 记录提示的处理时间，cooldown 内已处理过相同提示时返回 false
+func (h *Hook) claim(prompt string) bool {
+	if h.Config.Cooldown <= 0 {
+		return true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	for key, at := range h.recent {
+		if now.Sub(at) >= h.Config.Cooldown {
+			delete(h.recent, key)
+		}
+	}
+	key := sha256.Sum256([]byte(prompt))
+	if _, ok := h.recent[key]; ok {
+		return false
+	}
+	h.recent[key] = now
+	return true
+}
@@ -0,0 +1,295 @@
+// Package workerpool 限制同时处理的聊天请求数：固定数量的 worker 从有界队列中取任务执行，
+// 队列已满时立即拒绝，避免突发请求同时压到单卡 Ollama 上。
+// 队列按优先级（见 priority 包）出队，可以为交互请求保留 worker，避免批量任务占满 worker 池
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+	"github.com/champly/ai-agent/pkg/priority"
+)
+
+var (
+	// ErrSaturated worker 全忙且队列已满
+	ErrSaturated = errors.New("server is busy")
+	// ErrClosed worker 池已关闭
+	ErrClosed = errors.New("worker pool is closed")
+)
+
+// SaturatedError 队列已满，附带建议的重试等待时间
+type SaturatedError struct {
+	Queued     int
+	RetryAfter time.Duration
+}
+
+// Error 实现 error
+func (e *SaturatedError) Error() string {
+	return fmt.Sprintf("%s: %d requests queued, retry after %s", ErrSaturated, e.Queued, e.RetryAfter)
+}
+
+// Is 使 errors.Is(err, ErrSaturated) 成立
+func (e *SaturatedError) Is(target error) bool {
+	return target == ErrSaturated
+}
+
+// Stats worker 池状态
+type Stats struct {
+	Workers   int   `json:"workers"`
+	Reserved  int   `json:"reserved,omitempty"`
+	Busy      int64 `json:"busy"`
+	Queued    int64 `json:"queued"`
+	QueueSize int   `json:"queue_size"`
+	Completed int64 `json:"completed"`
+	Rejected  int64 `json:"rejected"`
+	// QueuedByPriority 各优先级排队中的请求数
+	QueuedByPriority map[string]int `json:"queued_by_priority"`
+}
+
+// 任务状态，由 Pool.mu 保护
+const (
+	jobQueued = iota
+	jobRunning
+	jobCanceled
+)
+
+// job 排队中的任务
+type job struct {
+	ctx   context.Context
+	fn    func(context.Context) error
+	class priority.Class
+	state int
+	done  chan error
+}
+
+// Pool 有界 worker 池，nil 表示不限制并发
+type Pool struct {
+	workers    int
+	reserved   int
+	queueSize  int
+	retryAfter time.Duration
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queues [priority.Classes][]*job // 各优先级的排队任务，先进先出
+	queued int
+	low    int // 执行中的非交互任务数
+	closed bool
+
+	busy      atomic.Int64
+	completed atomic.Int64
+	rejected  atomic.Int64
+
+	wg sync.WaitGroup
+}
+
+// New 创建并启动 worker 池；concurrency <= 0 时返回 nil
+func New(cfg config.WorkerPoolConfig) *Pool {
+	if cfg.Concurrency <= 0 {
+		return nil
+	}
+
+	p := &Pool{
+		workers:    cfg.Concurrency,
+		reserved:   cfg.Reserved,
+		queueSize:  cfg.QueueSize,
+		retryAfter: cfg.RetryAfter,
+	}
+	p.cond = sync.NewCond(&p.mu)
+	for range cfg.Concurrency {
+		p.wg.Add(1)
+		go p.work()
+	}
+
+	klog.InfoS("Chat worker pool started", "workers", cfg.Concurrency, "reserved", cfg.Reserved, "queueSize", cfg.QueueSize)
+	return p
+}
+
+// work 按优先级执行队列中的任务
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		var j *job
+		for !p.closed {
+			if j = p.nextLocked(); j != nil {
+				break
+			}
+			p.cond.Wait()
+		}
+		if j == nil {
+			p.mu.Unlock()
+			return
+		}
+		j.state = jobRunning
+		if j.class != priority.Interactive {
+			p.low++
+		}
+		p.mu.Unlock()
+
+		p.busy.Add(1)
+		err := j.fn(j.ctx)
+		p.busy.Add(-1)
+		p.completed.Add(1)
+
+		p.mu.Lock()
+		if j.class != priority.Interactive {
+			p.low--
+			// 释放了非交互任务的名额，唤醒可能在等待名额的 worker
+			p.cond.Broadcast()
+		}
+		p.mu.Unlock()
+		j.done <- err
+	}
+}
+
+// nextLocked 取出优先级最高的任务；非交互任务占用的 worker 达到 concurrency - reserved 时
+// 只取交互任务。调用方需持有 p.mu
+func (p *Pool) nextLocked() *job {
+	for _, c := range priority.All() {
+		if len(p.queues[c]) == 0 {
+			continue
+		}
+		if c != priority.Interactive && p.low >= p.workers-p.reserved {
+			return nil
+		}
+		j := p.queues[c][0]
+		p.queues[c] = p.queues[c][1:]
+		p.queued--
+		return j
+	}
+	return nil
+}
+
+// Do 排队执行 fn 并等待完成，优先级取自 ctx（见 priority.FromContext）。
+// 队列已满时，如果队列中有更低优先级的任务则挤掉其中最后入队的一个，否则立即返回 *SaturatedError；
+// 排队期间 ctx 取消时放弃执行，已经开始执行的任务会等待其结束
+func (p *Pool) Do(ctx context.Context, fn func(context.Context) error) error {
+	if p == nil {
+		return fn(ctx)
+	}
+
+	j := &job{ctx: ctx, fn: fn, class: priority.FromContext(ctx), done: make(chan error, 1)}
+	if err := p.enqueue(j); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-j.done:
+		return err
+	case <-ctx.Done():
+		p.mu.Lock()
+		if j.state == jobQueued {
+			p.removeLocked(j)
+			p.mu.Unlock()
+			return ctx.Err()
+		}
+		p.mu.Unlock()
+		// 已开始执行（或已被挤出队列），由 fn 自行响应取消
+		return <-j.done
+	}
+}
+
+// enqueue 将任务加入对应优先级的队列
+func (p *Pool) enqueue(j *job) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+
+	if p.queued >= p.queueSize {
+		victim := p.victimLocked(j.class)
+		if victim == nil {
+			p.rejected.Add(1)
+			klog.V(2).InfoS("Chat worker pool saturated", "queued", p.queued, "priority", j.class)
+			return &SaturatedError{Queued: p.queued, RetryAfter: p.retryAfter}
+		}
+		p.removeLocked(victim)
+		p.rejected.Add(1)
+		klog.V(2).InfoS("Queued request preempted by higher priority", "priority", victim.class, "by", j.class)
+		victim.done <- &SaturatedError{Queued: p.queued, RetryAfter: p.retryAfter}
+	}
+
+	p.queues[j.class] = append(p.queues[j.class], j)
+	p.queued++
+	p.cond.Signal()
+	return nil
+}
+
+// victimLocked 返回优先级低于 class 的排队任务中最低优先级、最后入队的一个，没有时返回 nil。
+// 调用方需持有 p.mu
+func (p *Pool) victimLocked(class priority.Class) *job {
+	for c := priority.Classes - 1; c > int(class); c-- {
+		if q := p.queues[c]; len(q) > 0 {
+			return q[len(q)-1]
+		}
+	}
+	return nil
+}
+
+// removeLocked 将排队中的任务移出队列并标记为已取消，调用方需持有 p.mu
+func (p *Pool) removeLocked(j *job) {
+	q := p.queues[j.class]
+	if i := slices.Index(q, j); i >= 0 {
+		p.queues[j.class] = slices.Delete(q, i, i+1)
+		p.queued--
+	}
+	j.state = jobCanceled
+}
+
+// Stats 返回当前状态
+func (p *Pool) Stats() Stats {
+	if p == nil {
+		return Stats{}
+	}
+	p.mu.Lock()
+	queued := p.queued
+	byPriority := make(map[string]int, priority.Classes)
+	for _, c := range priority.All() {
+		byPriority[c.String()] = len(p.queues[c])
+	}
+	p.mu.Unlock()
+
+	return Stats{
+		Workers:          p.workers,
+		Reserved:         p.reserved,
+		Busy:             p.busy.Load(),
+		Queued:           int64(queued),
+		QueueSize:        p.queueSize,
+		Completed:        p.completed.Load(),
+		Rejected:         p.rejected.Load(),
+		QueuedByPriority: byPriority,
+	}
+}
+
+// Close 等待执行中的任务结束后停止 worker，仍在排队的任务返回 ErrClosed
+func (p *Pool) Close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for c := range p.queues {
+		for _, j := range p.queues[c] {
+			j.state = jobCanceled
+			j.done <- ErrClosed
+		}
+		p.queues[c] = nil
+	}
+	p.queued = 0
+}
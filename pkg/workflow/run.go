@@ -0,0 +1,272 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Runner 执行各类步骤所需的能力，由 Agent 提供，权限策略、配额和租户限制与普通请求一致
+type Runner interface {
+	// Prompt 单轮模型调用，返回回答
+	Prompt(ctx context.Context, model, system, prompt string) (string, error)
+	// CallTool 调用工具，返回结果文本
+	CallTool(ctx context.Context, name string, args map[string]any) (string, error)
+	// SearchRAG 检索知识库，返回拼接好的参考资料
+	SearchRAG(ctx context.Context, collection, query string, topK int) (string, error)
+}
+
+// StepResult 单个步骤的执行结果
+type StepResult struct {
+	ID       string        `json:"id"`
+	Type     string        `json:"type"`
+	Output   string        `json:"output"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// RollbackResult 单个撤销操作的执行结果
+type RollbackResult struct {
+	Step     string        `json:"step"` // 被撤销的步骤
+	Tool     string        `json:"tool"`
+	Output   string        `json:"output"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Result 工作流的执行结果，Steps 按执行顺序排列（分支跳过的步骤不出现）；
+// 执行失败并撤销了已完成的步骤时，Rollback 按撤销的顺序排列
+type Result struct {
+	Workflow string           `json:"workflow"`
+	Output   string           `json:"output"`
+	Steps    []StepResult     `json:"steps"`
+	Rollback []RollbackResult `json:"rollback,omitempty"`
+}
+
+// undo 已记录的撤销操作
+type undo struct {
+	step string
+	tool string
+	args map[string]any
+}
+
+// data 模板数据
+type data struct {
+	Inputs map[string]string
+	Steps  map[string]string
+}
+
+// Run 执行工作流。步骤失败（未设置 continue_on_error）时停止并返回错误，Result 中保留已执行的步骤，
+// 并按相反顺序执行已完成步骤记录的撤销操作；onStep 可选，每个步骤结束时调用
+func (e *Engine) Run(ctx context.Context, runner Runner, name string, inputs map[string]string, onStep func(StepResult)) (*Result, error) {
+	def, err := e.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	values, err := def.resolveInputs(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &data{Inputs: values, Steps: make(map[string]string, len(def.Steps))}
+	res := &Result{Workflow: def.Name}
+	var undos []undo
+	if err := e.runSteps(ctx, runner, def, d, res, &undos, onStep); err != nil {
+		res.Output = ""
+		if len(undos) > 0 {
+			res.Rollback = rollback(ctx, runner, def.Name, undos)
+		}
+		return res, err
+	}
+
+	if def.output != nil {
+		out, err := render(def.output, d)
+		if err != nil {
+			return res, err
+		}
+		res.Output = out
+	}
+	return res, nil
+}
+
+// runSteps 依次执行步骤，res.Output 为最后执行的步骤的输出，成功步骤的撤销操作追加到 undos
+func (e *Engine) runSteps(ctx context.Context, runner Runner, def *Definition, d *data, res *Result, undos *[]undo, onStep func(StepResult)) error {
+	for i, executed := 0, 0; i < len(def.Steps); executed++ {
+		if executed >= e.maxSteps {
+			return fmt.Errorf("workflow %s exceeded %d steps", def.Name, e.maxSteps)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		step := def.Steps[i]
+		start := time.Now()
+		output, target, err := step.run(ctx, runner, d)
+		sr := StepResult{ID: step.ID, Type: step.Type, Output: output, Duration: time.Since(start)}
+		if err != nil {
+			sr.Error = err.Error()
+		}
+		res.Steps = append(res.Steps, sr)
+		if onStep != nil {
+			onStep(sr)
+		}
+		klog.V(2).InfoS("Workflow step finished", "workflow", def.Name, "step", step.ID, "duration", sr.Duration, "error", sr.Error)
+
+		if err != nil && !step.ContinueOnError {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+		d.Steps[step.ID] = output
+		res.Output = output
+
+		// 成功的步骤按当前数据记录撤销操作
+		if err == nil && step.Rollback != nil {
+			args, err := step.renderArgs("rollback.args.", step.Rollback.Args, d)
+			if err != nil {
+				return fmt.Errorf("step %s: rollback: %w", step.ID, err)
+			}
+			*undos = append(*undos, undo{step: step.ID, tool: step.Rollback.Tool, args: args})
+		}
+
+		switch target {
+		case "":
+			i++
+		case End:
+			i = len(def.Steps)
+		default:
+			i = def.index[target]
+		}
+	}
+	return nil
+}
+
+// rollback 按相反顺序执行撤销操作，单个操作失败时记录错误并继续。
+// 请求被取消时仍然执行，避免留下只完成一半的修改
+func rollback(ctx context.Context, runner Runner, workflow string, undos []undo) []RollbackResult {
+	ctx = context.WithoutCancel(ctx)
+	results := make([]RollbackResult, 0, len(undos))
+	for _, u := range slices.Backward(undos) {
+		start := time.Now()
+		output, err := runner.CallTool(ctx, u.tool, u.args)
+		rr := RollbackResult{Step: u.step, Tool: u.tool, Output: output, Duration: time.Since(start)}
+		if err != nil {
+			rr.Error = err.Error()
+			klog.ErrorS(err, "Workflow rollback failed", "workflow", workflow, "step", u.step, "tool", u.tool)
+		} else {
+			klog.V(2).InfoS("Workflow step rolled back", "workflow", workflow, "step", u.step, "tool", u.tool)
+		}
+		results = append(results, rr)
+	}
+	return results
+}
+
+// resolveInputs 校验输入并填充默认值
+func (d *Definition) resolveInputs(inputs map[string]string) (map[string]string, error) {
+	values := make(map[string]string, len(d.Inputs))
+	for _, in := range d.Inputs {
+		value, ok := inputs[in.Name]
+		switch {
+		case ok:
+			values[in.Name] = value
+		case in.Required:
+			return nil, fmt.Errorf("%w: %s is required", ErrInvalidInput, in.Name)
+		default:
+			values[in.Name] = in.Default
+		}
+	}
+	for name := range inputs {
+		if _, ok := values[name]; !ok {
+			return nil, fmt.Errorf("%w: unknown input %s", ErrInvalidInput, name)
+		}
+	}
+	return values, nil
+}
+
+// run 执行步骤，返回输出和跳转目标（为空表示下一步）
+func (s *Step) run(ctx context.Context, runner Runner, d *data) (output, target string, err error) {
+	target = s.Next
+	switch s.Type {
+	case StepLLM:
+		prompt, err := s.render("prompt", d)
+		if err != nil {
+			return "", "", err
+		}
+		system, err := s.render("system", d)
+		if err != nil {
+			return "", "", err
+		}
+		output, err = runner.Prompt(ctx, s.Model, system, prompt)
+		return output, target, err
+
+	case StepTool:
+		args, err := s.renderArgs("args.", s.Args, d)
+		if err != nil {
+			return "", "", err
+		}
+		output, err = runner.CallTool(ctx, s.Tool, args)
+		return output, target, err
+
+	case StepRAG:
+		query, err := s.render("query", d)
+		if err != nil {
+			return "", "", err
+		}
+		output, err = runner.SearchRAG(ctx, s.Collection, query, s.TopK)
+		return output, target, err
+
+	case StepCondition:
+		cond, err := s.render("if", d)
+		if err != nil {
+			return "", "", err
+		}
+		ok, err := strconv.ParseBool(strings.TrimSpace(cond))
+		if err != nil {
+			return "", "", fmt.Errorf("condition must render to true or false, got %q", cond)
+		}
+		if ok {
+			return "true", s.Then, nil
+		}
+		return "false", s.Else, nil
+	}
+	return "", "", fmt.Errorf("unknown step type %q", s.Type)
+}
+
+// renderArgs 渲染参数中的字符串模板，其他类型原样保留
+func (s *Step) renderArgs(prefix string, args map[string]any, d *data) (map[string]any, error) {
+	args = maps.Clone(args)
+	for key := range args {
+		if _, ok := s.templates[prefix+key]; !ok {
+			continue
+		}
+		value, err := s.render(prefix+key, d)
+		if err != nil {
+			return nil, err
+		}
+		args[key] = value
+	}
+	return args, nil
+}
+
+// render 渲染步骤中的模板字段，未设置的字段为空字符串
+func (s *Step) render(field string, d *data) (string, error) {
+	t, ok := s.templates[field]
+	if !ok {
+		return "", nil
+	}
+	return render(t, d)
+}
+
+// render 渲染模板
+func render(t *template.Template, d *data) (string, error) {
+	var sb strings.Builder
+	if err := t.Execute(&sb, d); err != nil {
+		return "", fmt.Errorf("render %s: %w", t.Name(), err)
+	}
+	return sb.String(), nil
+}
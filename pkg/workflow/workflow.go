@@ -0,0 +1,339 @@
+// Package workflow 按 YAML 定义的固定步骤执行多阶段任务：模型提示、工具调用、RAG 检索和条件分支，
+// 前一步的输出通过模板传给后续步骤。适合流程固定、需要可重复执行的任务，不依赖模型自行规划
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/klog/v2"
+
+	"github.com/champly/ai-agent/pkg/config"
+)
+
+// 步骤类型
+const (
+	// StepLLM 单轮模型提示（不使用工具）
+	StepLLM = "llm"
+	// StepTool 调用一个工具
+	StepTool = "tool"
+	// StepRAG 检索知识库，输出为参考资料文本
+	StepRAG = "rag"
+	// StepCondition 按条件跳转，输出为 true 或 false
+	StepCondition = "condition"
+)
+
+// End 作为跳转目标时结束工作流
+const End = "end"
+
+var (
+	// ErrNotFound 工作流不存在
+	ErrNotFound = errors.New("workflow not found")
+	// ErrInvalidInput 缺少必填输入或输入未定义
+	ErrInvalidInput = errors.New("invalid workflow input")
+)
+
+// stepIDPattern 步骤 ID 需能在模板中以 .Steps.<id> 引用
+var stepIDPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Input 工作流输入参数
+type Input struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Required    bool   `yaml:"required,omitempty" json:"required,omitempty"`
+	Default     string `yaml:"default,omitempty" json:"default,omitempty"`
+}
+
+// Step 工作流步骤。字符串字段均为 text/template 模板，可引用 .Inputs.<name> 和 .Steps.<id>
+type Step struct {
+	ID   string `yaml:"id" json:"id"`
+	Type string `yaml:"type" json:"type"`
+
+	// llm
+	Prompt string `yaml:"prompt,omitempty" json:"prompt,omitempty"`
+	System string `yaml:"system,omitempty" json:"system,omitempty"`
+	Model  string `yaml:"model,omitempty" json:"model,omitempty"` // 为空时使用默认模型
+
+	// tool：Args 中的字符串值按模板渲染，其他类型原样传递
+	Tool string         `yaml:"tool,omitempty" json:"tool,omitempty"`
+	Args map[string]any `yaml:"args,omitempty" json:"args,omitempty"`
+
+	// rag
+	Query      string `yaml:"query,omitempty" json:"query,omitempty"`
+	Collection string `yaml:"collection,omitempty" json:"collection,omitempty"`
+	TopK       int    `yaml:"top_k,omitempty" json:"top_k,omitempty"`
+
+	// condition：If 渲染为 true 时跳转到 Then，否则跳转到 Else，为空时继续下一步
+	If   string `yaml:"if,omitempty" json:"if,omitempty"`
+	Then string `yaml:"then,omitempty" json:"then,omitempty"`
+	Else string `yaml:"else,omitempty" json:"else,omitempty"`
+
+	// Next 执行完成后跳转的步骤，为空时继续下一步，end 结束
+	Next string `yaml:"next,omitempty" json:"next,omitempty"`
+	// ContinueOnError 步骤失败时记录错误并继续，输出为空
+	ContinueOnError bool `yaml:"continue_on_error,omitempty" json:"continue_on_error,omitempty"`
+	// Rollback 撤销该步骤效果的操作，仅用于 tool 步骤。步骤成功后按当时的数据渲染并记录，
+	// 之后的步骤失败时按相反顺序执行已记录的撤销操作
+	Rollback *Rollback `yaml:"rollback,omitempty" json:"rollback,omitempty"`
+
+	templates map[string]*template.Template // 预编译的模板，键为字段名（Args 为 args.<key>，撤销操作为 rollback.args.<key>）
+}
+
+// Rollback 撤销操作（一次工具调用），Args 中的字符串值按模板渲染，可引用该步骤自身的输出
+type Rollback struct {
+	Tool string         `yaml:"tool" json:"tool"`
+	Args map[string]any `yaml:"args,omitempty" json:"args,omitempty"`
+}
+
+// Definition 工作流定义
+type Definition struct {
+	Name        string  `yaml:"name" json:"name"`
+	Description string  `yaml:"description,omitempty" json:"description,omitempty"`
+	Inputs      []Input `yaml:"inputs,omitempty" json:"inputs,omitempty"`
+	Steps       []*Step `yaml:"steps" json:"steps"`
+	// Output 工作流的最终输出模板，为空时使用最后执行的步骤的输出
+	Output string `yaml:"output,omitempty" json:"output,omitempty"`
+
+	output *template.Template
+	index  map[string]int // 步骤 ID 到下标
+}
+
+// Parse 解析并校验工作流定义，所有模板在此时编译
+func Parse(data []byte) (*Definition, error) {
+	var def Definition
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("parse workflow: %w", err)
+	}
+	if err := def.compile(); err != nil {
+		if def.Name != "" {
+			return nil, fmt.Errorf("workflow %s: %w", def.Name, err)
+		}
+		return nil, err
+	}
+	return &def, nil
+}
+
+// compile 校验定义并编译模板
+func (d *Definition) compile() error {
+	if d.Name == "" {
+		return fmt.Errorf("workflow name is required")
+	}
+	if len(d.Steps) == 0 {
+		return fmt.Errorf("at least one step is required")
+	}
+
+	for _, in := range d.Inputs {
+		if in.Name == "" {
+			return fmt.Errorf("input name is required")
+		}
+	}
+
+	d.index = make(map[string]int, len(d.Steps))
+	for i, step := range d.Steps {
+		if step == nil {
+			return fmt.Errorf("step %d is empty", i)
+		}
+		if !stepIDPattern.MatchString(step.ID) || step.ID == End {
+			return fmt.Errorf("step %d: invalid id %q", i, step.ID)
+		}
+		if _, ok := d.index[step.ID]; ok {
+			return fmt.Errorf("duplicate step id %q", step.ID)
+		}
+		d.index[step.ID] = i
+	}
+
+	for _, step := range d.Steps {
+		if err := step.compile(); err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+		for _, target := range []string{step.Then, step.Else, step.Next} {
+			if _, ok := d.index[target]; target != "" && target != End && !ok {
+				return fmt.Errorf("step %s: unknown target %q", step.ID, target)
+			}
+		}
+	}
+
+	if d.Output != "" {
+		t, err := newTemplate("output", d.Output)
+		if err != nil {
+			return err
+		}
+		d.output = t
+	}
+	return nil
+}
+
+// compile 校验步骤字段并编译模板
+func (s *Step) compile() error {
+	fields := map[string]string{}
+	switch s.Type {
+	case StepLLM:
+		if s.Prompt == "" {
+			return fmt.Errorf("prompt is required")
+		}
+		fields["prompt"], fields["system"] = s.Prompt, s.System
+	case StepTool:
+		if s.Tool == "" {
+			return fmt.Errorf("tool is required")
+		}
+		addArgs(fields, "args.", s.Args)
+		if s.Rollback != nil {
+			if s.Rollback.Tool == "" {
+				return fmt.Errorf("rollback tool is required")
+			}
+			addArgs(fields, "rollback.args.", s.Rollback.Args)
+		}
+	case StepRAG:
+		if s.Query == "" {
+			return fmt.Errorf("query is required")
+		}
+		fields["query"] = s.Query
+	case StepCondition:
+		if s.If == "" {
+			return fmt.Errorf("if is required")
+		}
+		if s.Next != "" {
+			return fmt.Errorf("condition step uses then/else instead of next")
+		}
+		fields["if"] = s.If
+	default:
+		return fmt.Errorf("unknown step type %q", s.Type)
+	}
+	if s.Type != StepCondition && (s.Then != "" || s.Else != "") {
+		return fmt.Errorf("then/else are only valid for condition steps")
+	}
+	if s.Type != StepTool && s.Rollback != nil {
+		return fmt.Errorf("rollback is only valid for tool steps")
+	}
+
+	s.templates = make(map[string]*template.Template, len(fields))
+	for name, text := range fields {
+		if text == "" {
+			continue
+		}
+		t, err := newTemplate(name, text)
+		if err != nil {
+			return err
+		}
+		s.templates[name] = t
+	}
+	return nil
+}
+
+// addArgs 将参数中的字符串值作为模板字段加入 fields，键为 prefix+参数名
+func addArgs(fields map[string]string, prefix string, args map[string]any) {
+	for key, value := range args {
+		if str, ok := value.(string); ok {
+			fields[prefix+key] = str
+		}
+	}
+}
+
+// funcs 模板中可用的函数
+var funcs = template.FuncMap{
+	"contains":  strings.Contains,
+	"hasPrefix": strings.HasPrefix,
+	"hasSuffix": strings.HasSuffix,
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"trim":      strings.TrimSpace,
+	"truncate": func(n int, s string) string {
+		if len(s) <= n {
+			return s
+		}
+		return strings.ToValidUTF8(s[:n], "")
+	},
+}
+
+// newTemplate 编译模板，未定义的输入和尚未执行的步骤渲染为空字符串
+func newTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(text)
+}
+
+// Engine 已加载的工作流，nil 表示未配置
+type Engine struct {
+	defs     map[string]*Definition
+	maxSteps int
+}
+
+// Load 加载 cfg.Dir 下所有 .yaml/.yml 工作流定义；未配置目录时返回 nil
+func Load(cfg config.WorkflowConfig) (*Engine, error) {
+	if cfg.Dir == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(cfg.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		klog.InfoS("Workflow directory not found, no workflows loaded", "dir", cfg.Dir)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read workflow dir: %w", err)
+	}
+
+	e := &Engine{defs: make(map[string]*Definition), maxSteps: cfg.MaxSteps}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(cfg.Dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read workflow %s: %w", path, err)
+		}
+		def, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if _, ok := e.defs[def.Name]; ok {
+			return nil, fmt.Errorf("%s: duplicate workflow name %q", path, def.Name)
+		}
+		e.defs[def.Name] = def
+	}
+
+	klog.InfoS("Workflows loaded", "dir", cfg.Dir, "count", len(e.defs))
+	return e, nil
+}
+
+// NewEngine 由已解析的定义创建引擎，用于不从目录加载的工作流（如组合工具）
+func NewEngine(defs []*Definition, maxSteps int) (*Engine, error) {
+	e := &Engine{defs: make(map[string]*Definition, len(defs)), maxSteps: maxSteps}
+	for _, def := range defs {
+		if _, ok := e.defs[def.Name]; ok {
+			return nil, fmt.Errorf("duplicate workflow name %q", def.Name)
+		}
+		e.defs[def.Name] = def
+	}
+	return e, nil
+}
+
+// List 返回所有工作流定义，按名称排序
+func (e *Engine) List() []*Definition {
+	if e == nil {
+		return nil
+	}
+	defs := make([]*Definition, 0, len(e.defs))
+	for _, def := range e.defs {
+		defs = append(defs, def)
+	}
+	slices.SortFunc(defs, func(a, b *Definition) int { return strings.Compare(a.Name, b.Name) })
+	return defs
+}
+
+// Get 按名称查找工作流
+func (e *Engine) Get(name string) (*Definition, error) {
+	if e != nil {
+		if def, ok := e.defs[name]; ok {
+			return def, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+}